@@ -0,0 +1,138 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// Reconnects returns a condition met when the tunnel starts reconnecting
+// more than n times within the given window. It is met again once the
+// reconnects have dropped back to n or fewer within the window.
+func Reconnects(n int, window time.Duration) Condition {
+	return &reconnects{n: n, window: window}
+}
+
+type reconnects struct {
+	n      int
+	window time.Duration
+	times  []time.Time
+	fired  bool
+}
+
+func (c *reconnects) Event(e openvpn.Event, now time.Time) (string, bool) {
+	se, ok := e.(*openvpn.StateEvent)
+	if !ok || openvpn.State(se.NewState()) != openvpn.StateReconnecting {
+		return "", false
+	}
+	c.times = append(c.times, now)
+	return c.Tick(now)
+}
+
+func (c *reconnects) Tick(now time.Time) (string, bool) {
+	for len(c.times) > 0 && now.Sub(c.times[0]) > c.window {
+		c.times = c.times[1:]
+	}
+	if len(c.times) <= c.n {
+		c.fired = false
+		return "", false
+	}
+	if c.fired {
+		return "", false
+	}
+	c.fired = true
+	return fmt.Sprintf("%d reconnects in %s", len(c.times), c.window), true
+}
+
+// NoTraffic returns a condition met when the tunnel has been connected for
+// the given duration without its byte counts changing, which suggests
+// that it has stalled without OpenVPN noticing. It requires byte count
+// events to be enabled at an interval shorter than d, and is met again
+// once traffic resumes and stalls once more.
+func NoTraffic(d time.Duration) Condition {
+	return &noTraffic{d: d}
+}
+
+type noTraffic struct {
+	d                 time.Duration
+	connected         bool
+	since             time.Time
+	bytesIn, bytesOut int
+	fired             bool
+}
+
+func (c *noTraffic) Event(e openvpn.Event, now time.Time) (string, bool) {
+	switch e := e.(type) {
+	case *openvpn.StateEvent:
+		connected := openvpn.State(e.NewState()) == openvpn.StateConnected
+		if connected != c.connected {
+			c.connected, c.since, c.fired = connected, now, false
+		}
+	case *openvpn.ByteCountEvent:
+		if e.ClientId() != "" {
+			break
+		}
+		if e.BytesIn() != c.bytesIn || e.BytesOut() != c.bytesOut {
+			c.bytesIn, c.bytesOut = e.BytesIn(), e.BytesOut()
+			c.since, c.fired = now, false
+		}
+	}
+	return c.Tick(now)
+}
+
+func (c *noTraffic) Tick(now time.Time) (string, bool) {
+	if !c.connected || c.fired || now.Sub(c.since) < c.d {
+		return "", false
+	}
+	c.fired = true
+	return fmt.Sprintf("no traffic for %s while connected", now.Sub(c.since).Round(time.Second)), true
+}
+
+// AuthFailure returns a condition met when authentication fails, as
+// recognized by openvpn.ClassifyEvent. OpenVPN reports a failure in
+// several ways, so it is met once for each attempt to authenticate.
+func AuthFailure() Condition {
+	return &authFailure{}
+}
+
+type authFailure struct {
+	fired bool
+}
+
+func (c *authFailure) Event(e openvpn.Event, now time.Time) (string, bool) {
+	if se, ok := e.(*openvpn.StateEvent); ok {
+		switch openvpn.State(se.NewState()) {
+		case openvpn.StateAuth, openvpn.StateConnected:
+			c.fired = false
+		}
+	}
+	err := openvpn.ClassifyEvent(e)
+	if c.fired || !errors.Is(err, openvpn.ErrAuthFailed) {
+		return "", false
+	}
+	c.fired = true
+	return err.Error(), true
+}
+
+func (c *authFailure) Tick(now time.Time) (string, bool) {
+	return "", false
+}
+
+// Match returns a condition met by each event for which fn returns a
+// non-empty message, for alerts on single events such as a FatalEvent.
+func Match(fn func(openvpn.Event) string) Condition {
+	return match(fn)
+}
+
+type match func(openvpn.Event) string
+
+func (m match) Event(e openvpn.Event, now time.Time) (string, bool) {
+	msg := m(e)
+	return msg, msg != ""
+}
+
+func (m match) Tick(now time.Time) (string, bool) {
+	return "", false
+}
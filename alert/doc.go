@@ -0,0 +1,18 @@
+// Package alert raises alerts from the events of an OpenVPN process, so
+// that the conditions worth alerting on are defined in one place rather
+// than in each consumer.
+//
+// An Engine evaluates a set of rules, each pairing a Condition with
+// a callback. Conditions are provided for the commonest alerts, such as
+// repeated reconnects or a connected tunnel that carries no traffic, and
+// others can be written by implementing Condition or using Match:
+//
+//	var engine alert.Engine
+//	engine.Add("flapping", alert.Reconnects(3, 5*time.Minute), notify)
+//	engine.Add("stalled", alert.NoTraffic(2*time.Minute), notify)
+//	engine.Add("auth", alert.AuthFailure(), notify)
+//	go engine.Run(ctx, 10*time.Second)
+//
+// The engine's HandleEvent method should then be passed each event
+// received from the client's event channel.
+package alert
@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// Alert is raised when a rule's condition is met.
+type Alert struct {
+	// Rule is the name the rule was added with.
+	Rule string
+
+	// Time is when the condition was met, and Message describes it, such
+	// as "4 reconnects in 5m0s".
+	Time    time.Time
+	Message string
+
+	// Event is the event that met the condition, or nil if it was met by
+	// the passage of time.
+	Event openvpn.Event
+}
+
+// Condition is a condition that an Engine evaluates over the events of an
+// OpenVPN process.
+//
+// Conditions should fire once each time they become true, rather than for
+// as long as they hold, so that each problem is alerted on once. They are
+// only called by one goroutine at a time.
+type Condition interface {
+	// Event updates the condition with an event received at the given
+	// time, returning a message and true if the condition has now been
+	// met.
+	Event(e openvpn.Event, now time.Time) (string, bool)
+
+	// Tick evaluates the condition at the given time, for conditions met
+	// by no event occurring for a while, returning a message and true if
+	// it has now been met.
+	Tick(now time.Time) (string, bool)
+}
+
+// Engine evaluates rules over a stream of events, calling each rule's
+// callback when its condition is met.
+//
+// The zero value is an engine with no rules, ready to use. An Engine is
+// safe for concurrent use.
+type Engine struct {
+	mu    sync.Mutex
+	rules []*rule
+}
+
+type rule struct {
+	name string
+	cond Condition
+	fn   func(Alert)
+}
+
+// Add adds a rule with the given name, calling fn with an Alert whenever
+// cond is met. Callbacks are called without any locks held, in the order
+// the rules were added, and should return quickly since they hold up the
+// handling of events.
+func (en *Engine) Add(name string, cond Condition, fn func(Alert)) {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	en.rules = append(en.rules, &rule{name: name, cond: cond, fn: fn})
+}
+
+// HandleEvent passes the given event to the condition of each rule, and
+// calls the functions of the rules whose conditions are met before it
+// returns, with Alerts holding the event.
+func (en *Engine) HandleEvent(e openvpn.Event) {
+	now := time.Now()
+	en.evaluate(e, now, func(c Condition) (string, bool) { return c.Event(e, now) })
+}
+
+// Check evaluates the rules at the given time, for conditions met by no
+// event occurring for a while.
+func (en *Engine) Check(now time.Time) {
+	en.evaluate(nil, now, func(c Condition) (string, bool) { return c.Tick(now) })
+}
+
+// Run calls Check at the given interval until ctx is cancelled, returning
+// ctx.Err(). The interval bounds how late alerts from conditions such as
+// NoTraffic may be raised.
+func (en *Engine) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			en.Check(now)
+		}
+	}
+}
+
+func (en *Engine) evaluate(e openvpn.Event, now time.Time, eval func(Condition) (string, bool)) {
+	var fired []func()
+	en.mu.Lock()
+	for _, r := range en.rules {
+		if msg, ok := eval(r.cond); ok {
+			a := Alert{Rule: r.name, Time: now, Message: msg, Event: e}
+			fn := r.fn
+			fired = append(fired, func() { fn(a) })
+		}
+	}
+	en.mu.Unlock()
+
+	for _, fn := range fired {
+		fn()
+	}
+}
@@ -0,0 +1,117 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestConditions(t *testing.T) {
+	type step struct {
+		at    time.Duration
+		event string // or empty for a tick
+		want  string
+	}
+	tests := []struct {
+		cond  Condition
+		steps []step
+	}{
+		{
+			Reconnects(2, time.Minute),
+			[]step{
+				{0, "STATE:1,RECONNECTING,ping-restart,,", ""},
+				{10 * time.Second, "STATE:2,RECONNECTING,ping-restart,,", ""},
+				{20 * time.Second, "STATE:3,RECONNECTING,ping-restart,,", "3 reconnects in 1m0s"},
+				{30 * time.Second, "STATE:4,RECONNECTING,ping-restart,,", ""},
+				{100 * time.Second, "", ""},
+				{110 * time.Second, "STATE:5,RECONNECTING,ping-restart,,", ""},
+				{115 * time.Second, "STATE:6,RECONNECTING,ping-restart,,", ""},
+				{120 * time.Second, "STATE:7,RECONNECTING,ping-restart,,", "3 reconnects in 1m0s"},
+			},
+		},
+		{
+			NoTraffic(time.Minute),
+			[]step{
+				{0, "", ""},
+				{0, "STATE:1,CONNECTED,SUCCESS,,", ""},
+				{30 * time.Second, "BYTECOUNT:100,200", ""},
+				{60 * time.Second, "BYTECOUNT:100,200", ""},
+				{90 * time.Second, "", "no traffic for 1m0s while connected"},
+				{95 * time.Second, "", ""},
+				{100 * time.Second, "BYTECOUNT:300,200", ""},
+				{130 * time.Second, "STATE:2,RECONNECTING,ping-restart,,", ""},
+				{300 * time.Second, "", ""},
+			},
+		},
+		{
+			AuthFailure(),
+			[]step{
+				{0, "STATE:1,AUTH,,,", ""},
+				{1, "PASSWORD:Verification Failed: 'Auth'", "authentication failed: Verification Failed: 'Auth'"},
+				{2, "STATE:2,EXITING,auth-failure,,", ""},
+				{3, "STATE:3,AUTH,,,", ""},
+				{4, "STATE:4,EXITING,auth-failure,,", "authentication failed: auth-failure"},
+			},
+		},
+		{
+			Match(func(e openvpn.Event) string {
+				if _, ok := e.(*openvpn.FatalEvent); ok {
+					return "fatal"
+				}
+				return ""
+			}),
+			[]step{
+				{0, "HOLD:Waiting for hold release:0", ""},
+				{1, "FATAL:Cannot open TUN/TAP dev", "fatal"},
+				{2, "", ""},
+			},
+		},
+	}
+
+	start := time.Unix(1000, 0)
+	for i, test := range tests {
+		for j, s := range test.steps {
+			now := start.Add(s.at)
+			var msg string
+			var ok bool
+			if s.event == "" {
+				msg, ok = test.cond.Tick(now)
+			} else {
+				msg, ok = test.cond.Event(openvpn.ParseEvent([]byte(s.event)), now)
+			}
+			if ok != (s.want != "") || msg != s.want {
+				t.Errorf("test %d step %d got %q, %v; want %q", i, j, msg, ok, s.want)
+			}
+		}
+	}
+}
+
+func TestEngine(t *testing.T) {
+	var en Engine
+	var got []Alert
+	record := func(a Alert) { got = append(got, a) }
+	en.Add("auth", AuthFailure(), record)
+	en.Add("stalled", NoTraffic(time.Minute), record)
+	en.Add("fatal", Match(func(e openvpn.Event) string {
+		if fe, ok := e.(*openvpn.FatalEvent); ok {
+			return fe.String()
+		}
+		return ""
+	}), record)
+
+	auth := openvpn.ParseEvent([]byte("PASSWORD:Verification Failed: 'Auth'"))
+	en.HandleEvent(openvpn.ParseEvent([]byte("STATE:1,CONNECTED,SUCCESS,,")))
+	en.HandleEvent(auth)
+	en.Check(time.Now().Add(2 * time.Minute))
+
+	if len(got) != 2 {
+		t.Fatalf("got %d alerts; want 2", len(got))
+	}
+	if got[0].Rule != "auth" || got[0].Event != auth {
+		t.Errorf("got first alert %+v; want auth failure", got[0])
+	}
+	if got[1].Rule != "stalled" || got[1].Event != nil {
+		t.Errorf("got second alert %+v; want stall", got[1])
+	}
+}
@@ -0,0 +1,314 @@
+package capability
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Feature is an optional feature that an OpenVPN binary may have been built
+// with, as reported in square brackets by openvpn --version.
+type Feature string
+
+// Features commonly reported by OpenVPN builds.
+const (
+	FeatureLZO      Feature = "LZO"
+	FeatureLZ4      Feature = "LZ4"
+	FeaturePKCS11   Feature = "PKCS11"
+	FeatureAEAD     Feature = "AEAD"
+	FeatureDCO      Feature = "DCO"
+	FeatureEPoll    Feature = "EPOLL"
+	FeatureMHPkt    Feature = "MH/PKTINFO"
+	FeatureMHRecvDA Feature = "MH/RECVDA"
+)
+
+// Cipher is a data channel cipher reported by openvpn --show-ciphers.
+type Cipher struct {
+	Name string
+
+	// Deprecated is true for ciphers that OpenVPN lists as having
+	// a block size which is too small for safe use.
+	Deprecated bool
+}
+
+// Capabilities describes what a particular OpenVPN binary is able to do.
+type Capabilities struct {
+	Version Version
+
+	// Platform is the build target triple, such as "x86_64-pc-linux-gnu".
+	Platform string
+
+	// SSLLibrary is the name of the TLS library OpenVPN was built against,
+	// such as "OpenSSL" or "mbed TLS".
+	SSLLibrary string
+
+	// LibraryVersions is the free-form description of the versions of
+	// the libraries OpenVPN is linked with.
+	LibraryVersions string
+
+	// DCOVersion is the version of the data channel offload kernel module
+	// or driver, as reported by OpenVPN. It is empty if the binary does not
+	// support DCO, and "N/A" if it does but the kernel support is missing.
+	DCOVersion string
+
+	// Features is the set of optional features the binary was built with.
+	Features map[Feature]bool
+
+	// BuildFlags are the compile time defines, such as
+	// "enable_management" mapped to "yes".
+	BuildFlags map[string]string
+
+	// Ciphers are the ciphers supported for the data channel. This is
+	// populated only if the list could be retrieved from the binary.
+	Ciphers []Cipher
+}
+
+// Detect runs the given OpenVPN binary to determine its capabilities. If
+// binary is empty, "openvpn" is located via the PATH environment variable.
+func Detect(ctx context.Context, binary string) (*Capabilities, error) {
+	if binary == "" {
+		binary = "openvpn"
+	}
+
+	out, err := runInfo(ctx, binary, "--version")
+	if err != nil {
+		return nil, err
+	}
+	caps, err := parseVersionOutput(out)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ciphers are a nice-to-have, so we tolerate failure here rather
+	// than failing the whole detection.
+	if out, err := runInfo(ctx, binary, "--show-ciphers"); err == nil {
+		caps.Ciphers = parseCiphers(out)
+	}
+
+	return caps, nil
+}
+
+// runInfo runs an informational OpenVPN command and returns its output.
+//
+// Older versions of OpenVPN exit with a non-zero status after printing
+// their version information, so the exit status is ignored whenever the
+// process produced some output.
+func runInfo(ctx context.Context, binary string, arg string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, binary, arg).Output()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && len(out) > 0) {
+		return nil, fmt.Errorf("error running %s %s: %s", binary, arg, err)
+	}
+	return out, nil
+}
+
+// Has returns true if the binary was built with the given feature.
+func (c *Capabilities) Has(f Feature) bool {
+	return c.Features[f]
+}
+
+// DCOAvailable returns true if the binary supports data channel offload
+// and OpenVPN was able to find the necessary kernel support when it was
+// run. OpenVPN may still decide not to use DCO if the configuration uses
+// options which are incompatible with it.
+func (c *Capabilities) DCOAvailable() bool {
+	return c.Has(FeatureDCO) && c.DCOVersion != "" && c.DCOVersion != "N/A"
+}
+
+// BuildFlag returns the value of the given compile time define, or the
+// empty string if the binary did not report it.
+func (c *Capabilities) BuildFlag(name string) string {
+	return c.BuildFlags[name]
+}
+
+// HasCipher returns true if the given cipher is available for the data
+// channel. The comparison is case-insensitive, as it is in OpenVPN.
+//
+// If the cipher list could not be determined then HasCipher optimistically
+// returns true.
+func (c *Capabilities) HasCipher(name string) bool {
+	if c.Ciphers == nil {
+		return true
+	}
+	for _, cipher := range c.Ciphers {
+		if strings.EqualFold(cipher.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// directiveVersions records the release that introduced configuration
+// directives that are too new to assume are always available.
+var directiveVersions = map[string]Version{
+	"compress":              {Major: 2, Minor: 4},
+	"tls-crypt":             {Major: 2, Minor: 4},
+	"pull-filter":           {Major: 2, Minor: 4},
+	"data-ciphers":          {Major: 2, Minor: 5},
+	"tls-crypt-v2":          {Major: 2, Minor: 5},
+	"auth-gen-token":        {Major: 2, Minor: 4},
+	"peer-fingerprint":      {Major: 2, Minor: 6},
+	"disable-dco":           {Major: 2, Minor: 6},
+	"dns":                   {Major: 2, Minor: 6},
+	"data-ciphers-fallback": {Major: 2, Minor: 5},
+}
+
+// commandVersions records the release that introduced management
+// commands that are too new to assume are always available.
+var commandVersions = map[string]Version{
+	"client-pending-auth": {Major: 2, Minor: 5},
+	"cr-response":         {Major: 2, Minor: 5},
+	"pk-sig":              {Major: 2, Minor: 4},
+	"remote-entry-count":  {Major: 2, Minor: 6},
+	"remote-entry-get":    {Major: 2, Minor: 6},
+}
+
+// SupportsDirective returns true if the binary understands the given
+// configuration directive, written without its leading dashes.
+//
+// Only directives that were introduced relatively recently are checked;
+// the result is true for any directive this package doesn't know about.
+func (c *Capabilities) SupportsDirective(name string) bool {
+	if name == "disable-dco" && !c.Has(FeatureDCO) {
+		return false
+	}
+	return c.supports(directiveVersions, name)
+}
+
+// SupportsCommand returns true if the binary's management interface
+// understands the given command.
+//
+// Only commands that were introduced relatively recently are checked;
+// the result is true for any command this package doesn't know about.
+func (c *Capabilities) SupportsCommand(name string) bool {
+	if c.BuildFlag("enable_management") == "no" {
+		return false
+	}
+	return c.supports(commandVersions, name)
+}
+
+func (c *Capabilities) supports(table map[string]Version, name string) bool {
+	since, known := table[name]
+	if !known {
+		return true
+	}
+	return c.Version.Compare(since) >= 0
+}
+
+func parseVersionOutput(out []byte) (*Capabilities, error) {
+	caps := &Capabilities{
+		Features:   map[Feature]bool{},
+		BuildFlags: map[string]string{},
+	}
+
+	foundVersion := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case !foundVersion && strings.HasPrefix(line, "OpenVPN "):
+			if err := caps.parseBanner(line); err != nil {
+				return nil, err
+			}
+			foundVersion = true
+		case strings.HasPrefix(line, "library versions:"):
+			caps.LibraryVersions = strings.TrimSpace(line[len("library versions:"):])
+		case strings.HasPrefix(line, "DCO version:"):
+			caps.DCOVersion = strings.TrimSpace(line[len("DCO version:"):])
+		case strings.HasPrefix(line, "Compile time defines:"):
+			for _, define := range strings.Fields(line[len("Compile time defines:"):]) {
+				name, value := define, ""
+				if eqIdx := strings.IndexByte(define, '='); eqIdx != -1 {
+					name, value = define[:eqIdx], define[eqIdx+1:]
+				}
+				caps.BuildFlags[name] = value
+			}
+		}
+	}
+
+	if !foundVersion {
+		return nil, fmt.Errorf("output does not contain an OpenVPN version")
+	}
+
+	return caps, nil
+}
+
+// parseBanner parses the first line of openvpn --version, which looks like
+//
+//	OpenVPN 2.6.3 x86_64-pc-linux-gnu [SSL (OpenSSL)] [LZO] [LZ4] [DCO]
+//
+// possibly followed by a build date.
+func (c *Capabilities) parseBanner(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return fmt.Errorf("malformed OpenVPN version banner %q", line)
+	}
+
+	version, err := ParseVersion(fields[1])
+	if err != nil {
+		return err
+	}
+	c.Version = version
+
+	if len(fields) > 2 && !strings.HasPrefix(fields[2], "[") {
+		c.Platform = fields[2]
+	}
+
+	rest := line
+	for {
+		start := strings.IndexByte(rest, '[')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], ']')
+		if end == -1 {
+			break
+		}
+		flag := rest[start+1 : start+end]
+		rest = rest[start+end+1:]
+
+		if strings.HasPrefix(flag, "SSL (") && strings.HasSuffix(flag, ")") {
+			c.SSLLibrary = flag[len("SSL (") : len(flag)-1]
+			continue
+		}
+		c.Features[Feature(flag)] = true
+	}
+
+	return nil
+}
+
+// parseCiphers parses the output of openvpn --show-ciphers, in which each
+// cipher is listed on its own line followed by a parenthesized description.
+// Ciphers listed after the warning about small block sizes are considered
+// deprecated.
+func parseCiphers(out []byte) []Cipher {
+	ciphers := []Cipher{}
+	deprecated := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.Contains(line, "deprecated") || strings.Contains(line, "less than 128 bits") {
+			deprecated = true
+			continue
+		}
+
+		parenIdx := strings.Index(line, " (")
+		if parenIdx < 1 || !strings.HasSuffix(line, ")") {
+			continue
+		}
+		name := strings.TrimSpace(line[:parenIdx])
+		if strings.ContainsAny(name, " \t") {
+			continue
+		}
+		ciphers = append(ciphers, Cipher{Name: name, Deprecated: deprecated})
+	}
+
+	return ciphers
+}
@@ -0,0 +1,179 @@
+package capability
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const versionOutput26 = `OpenVPN 2.6.3 x86_64-pc-linux-gnu [SSL (OpenSSL)] [LZO] [LZ4] [EPOLL] [PKCS11] [MH/PKTINFO] [AEAD] [DCO]
+library versions: OpenSSL 3.0.2 15 Mar 2022, LZO 2.10
+DCO version: N/A
+Originally developed by James Yonan
+Copyright (C) 2002-2023 OpenVPN Inc <sales@openvpn.net>
+Compile time defines: enable_async_push=no enable_comp_stub=no enable_management=yes enable_lz4=yes
+`
+
+const versionOutput24 = `OpenVPN 2.4.7 x86_64-pc-linux-gnu [SSL (OpenSSL)] [LZO] [LZ4] [EPOLL] [PKCS11] [MH/PKTINFO] [AEAD] built on Feb 20 2019
+library versions: OpenSSL 1.1.1f  31 Mar 2020, LZO 2.10
+`
+
+const ciphersOutput = `The following ciphers and cipher modes are available for use
+with OpenVPN.  Each cipher shown below may be used as a
+parameter to the --data-ciphers (or --cipher) option. In static
+key mode only CBC mode is allowed.
+See also openssl list -cipher-algorithms
+
+AES-128-CBC  (128 bit key, 128 bit block)
+AES-256-GCM  (256 bit key, 128 bit block, TLS client/server mode only)
+CHACHA20-POLY1305  (256 bit key, stream cipher, TLS client/server mode only)
+
+The following ciphers have a block size of less than 128 bits,
+and are therefore deprecated.  Do not use unless you have to.
+
+BF-CBC  (128 bit key by default, 64 bit block)
+`
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "2.6.3", want: Version{Major: 2, Minor: 6, Patch: 3}},
+		{input: "2.6_rc2", want: Version{Major: 2, Minor: 6, Suffix: "_rc2"}},
+		{input: "2.4.12_git", want: Version{Major: 2, Minor: 4, Patch: 12, Suffix: "_git"}},
+		{input: "2", wantErr: true},
+		{input: "", wantErr: true},
+		{input: "x.y", wantErr: true},
+		{input: "2.6.3.1", wantErr: true},
+	}
+
+	for i, test := range tests {
+		got, err := ParseVersion(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("test %d succeeded; want error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d failed: %s", i, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("test %d got %#v; want %#v", i, got, test.want)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	v := Version{Major: 2, Minor: 5, Patch: 1}
+
+	if !v.AtLeast(2, 5) {
+		t.Errorf("%s is not at least 2.5", v)
+	}
+	if !v.AtLeast(2, 4) {
+		t.Errorf("%s is not at least 2.4", v)
+	}
+	if v.AtLeast(2, 6) {
+		t.Errorf("%s is at least 2.6", v)
+	}
+	if got := v.Compare(Version{Major: 2, Minor: 5, Patch: 1, Suffix: "_git"}); got != 0 {
+		t.Errorf("Compare ignoring suffix returned %d; want 0", got)
+	}
+}
+
+func TestParseVersionOutput(t *testing.T) {
+	caps, err := parseVersionOutput([]byte(versionOutput26))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := caps.Version, (Version{Major: 2, Minor: 6, Patch: 3}); got != want {
+		t.Errorf("Version is %s; want %s", got, want)
+	}
+	if got, want := caps.Platform, "x86_64-pc-linux-gnu"; got != want {
+		t.Errorf("Platform is %q; want %q", got, want)
+	}
+	if got, want := caps.SSLLibrary, "OpenSSL"; got != want {
+		t.Errorf("SSLLibrary is %q; want %q", got, want)
+	}
+	if got, want := caps.LibraryVersions, "OpenSSL 3.0.2 15 Mar 2022, LZO 2.10"; got != want {
+		t.Errorf("LibraryVersions is %q; want %q", got, want)
+	}
+	for _, f := range []Feature{FeatureLZO, FeatureLZ4, FeaturePKCS11, FeatureDCO, FeatureMHPkt} {
+		if !caps.Has(f) {
+			t.Errorf("feature %s is missing", f)
+		}
+	}
+	if got, want := caps.DCOVersion, "N/A"; got != want {
+		t.Errorf("DCOVersion is %q; want %q", got, want)
+	}
+	if caps.DCOAvailable() {
+		t.Errorf("DCO is available without kernel support")
+	}
+	if got, want := caps.BuildFlag("enable_management"), "yes"; got != want {
+		t.Errorf("enable_management is %q; want %q", got, want)
+	}
+	if !caps.SupportsDirective("data-ciphers") {
+		t.Errorf("2.6 does not support data-ciphers")
+	}
+	if !caps.SupportsCommand("remote-entry-count") {
+		t.Errorf("2.6 does not support remote-entry-count")
+	}
+
+	caps, err = parseVersionOutput([]byte(versionOutput24))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Has(FeatureDCO) || caps.DCOAvailable() {
+		t.Errorf("2.4 claims DCO support")
+	}
+	if caps.SupportsDirective("data-ciphers") {
+		t.Errorf("2.4 supports data-ciphers")
+	}
+	if caps.SupportsCommand("client-pending-auth") {
+		t.Errorf("2.4 supports client-pending-auth")
+	}
+	if !caps.SupportsCommand("hold") {
+		t.Errorf("2.4 does not support hold")
+	}
+
+	if _, err := parseVersionOutput([]byte("Usage: something else\n")); err == nil {
+		t.Errorf("parsing non-OpenVPN output succeeded")
+	}
+}
+
+func TestDCOAvailable(t *testing.T) {
+	out := strings.Replace(versionOutput26, "DCO version: N/A", "DCO version: 0.2.20230426", 1)
+	caps, err := parseVersionOutput([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.DCOAvailable() {
+		t.Errorf("DCO is unavailable with kernel module version %q", caps.DCOVersion)
+	}
+}
+
+func TestParseCiphers(t *testing.T) {
+	got := parseCiphers([]byte(ciphersOutput))
+	want := []Cipher{
+		{Name: "AES-128-CBC"},
+		{Name: "AES-256-GCM"},
+		{Name: "CHACHA20-POLY1305"},
+		{Name: "BF-CBC", Deprecated: true},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong ciphers\ngot  %#v\nwant %#v", got, want)
+	}
+
+	caps := &Capabilities{Ciphers: got}
+	if !caps.HasCipher("aes-256-gcm") {
+		t.Errorf("HasCipher is case-sensitive")
+	}
+	if caps.HasCipher("DES-CBC") {
+		t.Errorf("HasCipher reports unlisted cipher")
+	}
+}
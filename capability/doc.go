@@ -0,0 +1,7 @@
+// Package capability determines what a particular OpenVPN binary supports,
+// by running it in its informational modes and parsing the results.
+//
+// The resulting Capabilities can be consulted before generating
+// configuration or issuing management commands, so that callers can avoid
+// relying on features that the installed OpenVPN version lacks.
+package capability
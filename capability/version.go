@@ -0,0 +1,84 @@
+package capability
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is an OpenVPN release version, such as 2.6.3.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+
+	// Suffix is any pre-release marker following the numeric version,
+	// such as "_rc2" or "_git". It is ignored when comparing versions.
+	Suffix string
+}
+
+// ParseVersion parses a version string as printed by openvpn --version,
+// such as "2.6.3" or "2.6_rc2".
+func ParseVersion(s string) (Version, error) {
+	var v Version
+
+	end := 0
+	for end < len(s) && (s[end] == '.' || (s[end] >= '0' && s[end] <= '9')) {
+		end++
+	}
+	v.Suffix = s[end:]
+
+	parts := strings.Split(s[:end], ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid OpenVPN version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid OpenVPN version %q", s)
+		}
+		*nums[i] = n
+	}
+
+	return v, nil
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is older than, the
+// same as, or newer than o. Suffixes are not considered.
+func (v Version) Compare(o Version) int {
+	switch {
+	case v.Major != o.Major:
+		return sign(v.Major - o.Major)
+	case v.Minor != o.Minor:
+		return sign(v.Minor - o.Minor)
+	default:
+		return sign(v.Patch - o.Patch)
+	}
+}
+
+// AtLeast returns true if v is the given major.minor release or newer.
+func (v Version) AtLeast(major, minor int) bool {
+	return v.Compare(Version{Major: major, Minor: minor}) >= 0
+}
+
+// IsZero returns true if v is the zero Version, indicating an unknown
+// version.
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d%s", v.Major, v.Minor, v.Patch, v.Suffix)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,13 @@
+// Package cloudevents wraps the events of OpenVPN processes in CloudEvents
+// 1.0 envelopes, so that they can be sent over any transport that carries
+// CloudEvents, such as Knative, Azure Event Grid or Amazon EventBridge.
+//
+// An Encoder produces an Envelope for each event, whose type is derived
+// from the type of the event, such as "com.nordsecurity.gopenvpn.StateEvent",
+// whose source identifies the OpenVPN instance, whose subject identifies
+// the client of a server the event concerns, if any, and whose data is the
+// event encoded by openvpn.MarshalEvent. Envelopes can be sent in the
+// structured content mode by marshaling them to JSON with the
+// StructuredContentType, or in the binary content mode over HTTP using
+// SetHTTPHeaders.
+package cloudevents
@@ -0,0 +1,133 @@
+package cloudevents
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// SpecVersion is the version of the CloudEvents specification implemented.
+const SpecVersion = "1.0"
+
+// DefaultTypePrefix is prepended to the name of the type of each event to
+// form the type of its envelope when Encoder.TypePrefix is empty.
+const DefaultTypePrefix = "com.nordsecurity.gopenvpn."
+
+// StructuredContentType is the content type of an Envelope encoded as JSON
+// in the structured content mode.
+const StructuredContentType = "application/cloudevents+json; charset=utf-8"
+
+// Envelope is a CloudEvent carrying an OpenVPN event.
+type Envelope struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Encoder wraps events in envelopes. The zero value is not usable: Source
+// must be set.
+type Encoder struct {
+	// Source identifies the OpenVPN instance the events come from, as a
+	// URI reference such as "/gateways/eu-west-1" or
+	// "urn:gopenvpn:office".
+	Source string
+
+	// TypePrefix is prepended to the name of the type of each event, and
+	// defaults to DefaultTypePrefix.
+	TypePrefix string
+}
+
+// Envelope returns the envelope of an event that happened at the given
+// time, with a random id.
+func (enc *Encoder) Envelope(e openvpn.Event, t time.Time) (Envelope, error) {
+	data, err := openvpn.MarshalEvent(e)
+	if err != nil {
+		return Envelope{}, err
+	}
+	prefix := enc.TypePrefix
+	if prefix == "" {
+		prefix = DefaultTypePrefix
+	}
+	return Envelope{
+		SpecVersion:     SpecVersion,
+		ID:              newID(),
+		Source:          enc.Source,
+		Type:            prefix + openvpn.EventTypeName(e),
+		Subject:         Subject(e),
+		Time:            t,
+		DataContentType: "application/json",
+		Data:            data,
+	}, nil
+}
+
+// Marshal returns the JSON encoding of the envelope of an event that
+// happened at the given time, in the structured content mode.
+func (enc *Encoder) Marshal(e openvpn.Event, t time.Time) ([]byte, error) {
+	env, err := enc.Envelope(e, t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// Subject returns the subject of the envelope of an event, which
+// identifies the client of a server it concerns, as its common name and
+// client id separated by a slash, such as "alice/7", or just its client id
+// if the event does not give the common name. It returns the empty string
+// for events not concerning a particular client.
+func Subject(e openvpn.Event) string {
+	var cn string
+	var cid int64
+	switch e := e.(type) {
+	case *openvpn.ClientEvent:
+		cn, cid = e.Env().Get("common_name"), e.ClientID()
+	case *openvpn.ByteCountEvent:
+		id, err := strconv.ParseInt(e.ClientId(), 10, 64)
+		if err != nil {
+			return ""
+		}
+		cid = id
+	case *openvpn.DuplicateClientEvent:
+		cn, cid = e.New.CommonName, e.New.ClientID
+	default:
+		return ""
+	}
+	if cn == "" {
+		return strconv.FormatInt(cid, 10)
+	}
+	return cn + "/" + strconv.FormatInt(cid, 10)
+}
+
+// SetHTTPHeaders sets the headers of an HTTP request or response carrying
+// the envelope in the binary content mode, whose body must then be the
+// envelope's Data.
+func SetHTTPHeaders(h http.Header, env Envelope) {
+	h.Set("Ce-Specversion", env.SpecVersion)
+	h.Set("Ce-Id", env.ID)
+	h.Set("Ce-Source", env.Source)
+	h.Set("Ce-Type", env.Type)
+	if env.Subject != "" {
+		h.Set("Ce-Subject", env.Subject)
+	}
+	h.Set("Ce-Time", env.Time.Format(time.RFC3339Nano))
+	h.Set("Content-Type", env.DataContentType)
+}
+
+// newID returns a random version 4 UUID.
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
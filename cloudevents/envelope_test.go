@@ -0,0 +1,73 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestSubject(t *testing.T) {
+	tests := []struct {
+		event openvpn.Event
+		want  string
+	}{
+		{openvpn.ParseEvent([]byte("STATE:1234,CONNECTED,SUCCESS,,")), ""},
+		{openvpn.ParseEvent([]byte("BYTECOUNT_CLI:7,10,20")), "7"},
+		{openvpn.ParseEvent([]byte("BYTECOUNT:10,20")), ""},
+		{&openvpn.DuplicateClientEvent{CommonName: "bob", New: openvpn.ConnectedClient{ClientID: 9, CommonName: "bob"}}, "bob/9"},
+	}
+	for i, test := range tests {
+		if got := Subject(test.event); got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	enc := &Encoder{Source: "/gateways/eu-west-1"}
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	buf, err := enc.Marshal(openvpn.ParseEvent([]byte("STATE:1234,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")), when)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"specversion":     "1.0",
+		"source":          "/gateways/eu-west-1",
+		"type":            "com.nordsecurity.gopenvpn.StateEvent",
+		"time":            "2024-05-01T12:00:00Z",
+		"datacontenttype": "application/json",
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("got %s %v; want %v", name, got[name], value)
+		}
+	}
+	if _, ok := got["subject"]; ok {
+		t.Errorf("got subject %v; want none", got["subject"])
+	}
+	if id, _ := got["id"].(string); !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("got id %q; want a UUID", id)
+	}
+	if data, _ := got["data"].(map[string]interface{}); data["state"] != "CONNECTED" {
+		t.Errorf("got data %v", got["data"])
+	}
+
+	env, err := enc.Envelope(openvpn.ParseEvent([]byte("BYTECOUNT_CLI:7,10,20")), when)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.Header{}
+	SetHTTPHeaders(h, env)
+	if h.Get("ce-subject") != "7" || h.Get("ce-type") != "com.nordsecurity.gopenvpn.ByteCountEvent" || h.Get("content-type") != "application/json" {
+		t.Errorf("got headers %v", h)
+	}
+}
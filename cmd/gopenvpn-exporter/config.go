@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListen       = ":9176"
+	defaultPollInterval = 15 * time.Second
+)
+
+type config struct {
+	Listen  string   `json:"listen"`
+	Targets []target `json:"targets"`
+}
+
+// target is a management interface to collect metrics from.
+type target struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+
+	// Server is true if the target is an OpenVPN server, whose client list
+	// is polled every PollInterval.
+	Server       bool     `json:"server"`
+	PollInterval duration `json:"poll_interval"`
+}
+
+// duration is a time.Duration given in JSON as a string such as "30s".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func loadConfig(path string) (config, error) {
+	var cfg config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// parseTarget parses a target given on the command line as an address,
+// optionally preceded by a name and an equals sign.
+func parseTarget(arg string, server bool) target {
+	t := target{Address: arg, Server: server}
+	if idx := strings.IndexByte(arg, '='); idx != -1 {
+		t.Name, t.Address = arg[:idx], arg[idx+1:]
+	}
+	return t
+}
+
+// validate fills in defaults and checks that the targets can be told apart.
+func (c *config) validate() error {
+	if c.Listen == "" {
+		c.Listen = defaultListen
+	}
+	if len(c.Targets) == 0 {
+		return errors.New("no targets given")
+	}
+	names := map[string]bool{}
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if t.Address == "" {
+			return fmt.Errorf("target %d has no address", i+1)
+		}
+		if t.Name == "" {
+			t.Name = t.Address
+		}
+		if t.PollInterval <= 0 {
+			t.PollInterval = duration(defaultPollInterval)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate target name %q", t.Name)
+		}
+		names[t.Name] = true
+	}
+	return nil
+}
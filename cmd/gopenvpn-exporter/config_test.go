@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want target
+	}{
+		{"127.0.0.1:7505", target{Address: "127.0.0.1:7505"}},
+		{"office=127.0.0.1:7505", target{Name: "office", Address: "127.0.0.1:7505"}},
+		{"uplink=/run/openvpn/uplink.sock", target{Name: "uplink", Address: "/run/openvpn/uplink.sock"}},
+	}
+	for i, test := range tests {
+		if got := parseTarget(test.arg, false); got != test.want {
+			t.Errorf("test %d got %+v; want %+v", i, got, test.want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.json")
+	data := `{
+		"targets": [
+			{"name": "office", "address": "127.0.0.1:7505", "server": true, "poll_interval": "30s"},
+			{"address": "/run/openvpn/uplink.sock"}
+		]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := config{
+		Listen: defaultListen,
+		Targets: []target{
+			{Name: "office", Address: "127.0.0.1:7505", Server: true, PollInterval: duration(30 * time.Second)},
+			{Name: "/run/openvpn/uplink.sock", Address: "/run/openvpn/uplink.sock", PollInterval: duration(defaultPollInterval)},
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v; want %+v", cfg, want)
+	}
+
+	cfg.Targets = append(cfg.Targets, target{Name: "office", Address: "127.0.0.1:7506"})
+	if err := cfg.validate(); err == nil {
+		t.Errorf("duplicate target names were accepted")
+	}
+}
@@ -0,0 +1,100 @@
+// Command gopenvpn-exporter serves Prometheus metrics for one or more
+// OpenVPN processes, gathered through their management interfaces.
+//
+// Usage:
+//
+//	gopenvpn-exporter [-listen addr] [-server] [-config file] [name=]address...
+//
+// Each address is that of a management interface, in the form accepted by
+// openvpn.Dial, and may be preceded by a name to identify it in the target
+// label of its metrics, which otherwise defaults to the address. With
+// -server, the targets given on the command line are treated as OpenVPN
+// servers, whose client lists are polled for per-client metrics.
+//
+// Targets may instead, or as well, be listed in a JSON config file:
+//
+//	{
+//		"listen": ":9176",
+//		"targets": [
+//			{"name": "office", "address": "127.0.0.1:7505", "server": true, "poll_interval": "30s"},
+//			{"name": "uplink", "address": "/run/openvpn/uplink.sock"}
+//		]
+//	}
+//
+// The metrics are served at /metrics. The exporter reconnects to targets
+// that go away, retaining their metrics meanwhile, so it can be started
+// before the OpenVPN processes it watches.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/NordSecurity/gopenvpn/metrics"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func main() {
+	listen := flag.String("listen", "", "address to serve metrics on (default "+defaultListen+")")
+	server := flag.Bool("server", false, "treat the targets given as arguments as OpenVPN servers")
+	configPath := flag.String("config", "", "JSON file listing the targets")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [name=]address...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var cfg config
+	if *configPath != "" {
+		var err error
+		if cfg, err = loadConfig(*configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, arg := range flag.Args() {
+		cfg.Targets = append(cfg.Targets, parseTarget(arg, *server))
+	}
+	if *listen != "" {
+		cfg.Listen = *listen
+	}
+	if err := cfg.validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	reg := prometheus.NewRegistry()
+	for _, t := range cfg.Targets {
+		t := t
+		c := &metrics.Collector{
+			Session:     &openvpn.Session{},
+			ConstLabels: prometheus.Labels{"target": t.Name},
+		}
+		if t.Server {
+			c.Registry = &openvpn.ClientRegistry{}
+		}
+		reg.MustRegister(c)
+		go t.run(ctx, c.Session, c.Registry)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: cfg.Listen, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	log.Printf("serving metrics for %d targets on %s", len(cfg.Targets), cfg.Listen)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+const (
+	// retryInterval is how long to wait before reconnecting to a target.
+	retryInterval = 5 * time.Second
+
+	// byteCountInterval is how often targets are asked to report their
+	// byte counts.
+	byteCountInterval = 5 * time.Second
+)
+
+// run keeps the session and registry, if not nil, up to date with the
+// target until ctx is cancelled, reconnecting whenever the connection is
+// lost.
+func (t target) run(ctx context.Context, s *openvpn.Session, r *openvpn.ClientRegistry) {
+	for {
+		if err := t.watch(ctx, s, r); err != nil {
+			log.Printf("%s: %v", t.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// watch connects to the target and handles its events until the
+// connection is lost or ctx is cancelled.
+func (t target) watch(ctx context.Context, s *openvpn.Session, r *openvpn.ClientRegistry) error {
+	events := make(chan openvpn.Event, 64)
+	client, err := openvpn.Dial(t.Address, events)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		client.Close()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range events {
+			s.HandleEvent(e)
+			if r != nil {
+				r.HandleEvent(e)
+			}
+		}
+	}()
+
+	if err := t.setUp(client, s); err != nil {
+		cancel()
+		<-done
+		return err
+	}
+	if r != nil {
+		go r.Poll(ctx, client, time.Duration(t.PollInterval))
+	}
+	log.Printf("%s: connected to %s", t.Name, t.Address)
+	<-done
+	return nil
+}
+
+// setUp enables the events the session needs and brings it up to date.
+func (t target) setUp(client *openvpn.MgmtClient, s *openvpn.Session) error {
+	if err := client.SetStateEvents(true); err != nil {
+		return err
+	}
+	if err := client.SetByteCountEvents(byteCountInterval); err != nil {
+		return err
+	}
+	state, err := client.LatestState()
+	if err != nil {
+		return err
+	}
+	s.HandleEvent(state)
+	return nil
+}
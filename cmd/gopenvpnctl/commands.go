@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// byteCountInterval is how often byte counts are reported while watching
+// them.
+const byteCountInterval = 5 * time.Second
+
+// signals are the signals that may be sent using the signal command.
+var signals = []string{"SIGHUP", "SIGTERM", "SIGUSR1", "SIGUSR2"}
+
+// watchKinds are the kinds of event that may be watched, other than "all"
+// and "off".
+var watchKinds = []string{"state", "bytecount", "log", "echo"}
+
+var errUsage = errors.New("usage")
+
+// command is one of the commands understood by gopenvpnctl.
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(c *ctl, args []string) error
+
+	// complete, if set, returns the candidates for the command's
+	// arguments.
+	complete func() []string
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "status", usage: "status", help: "show the process and connection state", run: (*ctl).status},
+		{name: "clients", usage: "clients", help: "list the clients connected to a server", run: (*ctl).clients},
+		{name: "kill", usage: "kill target [message]", help: "disconnect a client by client id, common name or real address", run: (*ctl).kill},
+		{
+			name: "signal", usage: "signal name", help: "send a signal to the OpenVPN process", run: (*ctl).signal,
+			complete: func() []string { return signals },
+		},
+		{name: "log", usage: "log [n]", help: "show the last n messages of the log history", run: (*ctl).log},
+		{
+			name: "watch", usage: "watch [kind...]", help: "show events of the given kinds as they happen", run: (*ctl).watch,
+			complete: func() []string { return append([]string{"all", "off"}, watchKinds...) },
+		},
+	}
+}
+
+// ctl runs commands against a management client, writing their output to
+// out.
+type ctl struct {
+	client *openvpn.MgmtClient
+
+	mu       sync.Mutex
+	out      io.Writer
+	watching map[string]bool
+}
+
+func newCtl(client *openvpn.MgmtClient, out io.Writer) *ctl {
+	return &ctl{client: client, out: out, watching: map[string]bool{}}
+}
+
+// setOutput changes where the output of commands and events is written.
+func (c *ctl) setOutput(out io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.out = out
+}
+
+func (c *ctl) printf(format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.out, format, args...)
+}
+
+// run runs the command given by args, the first of which is its name.
+func (c *ctl) run(args []string) error {
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(c, args[1:])
+		if err == errUsage {
+			return fmt.Errorf("usage: %s", cmd.usage)
+		}
+		return err
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func (c *ctl) status(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	pid, err := c.client.Pid()
+	if err != nil {
+		return err
+	}
+	version, err := c.client.Version()
+	if err != nil {
+		return err
+	}
+	state, err := c.client.LatestState()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "pid:\t%d\n", pid)
+	fmt.Fprintf(w, "version:\t%s\n", version.OpenVPN)
+	fmt.Fprintf(w, "state:\t%s\n", state.NewState())
+	if desc := state.Description(); desc != "" {
+		fmt.Fprintf(w, "description:\t%s\n", desc)
+	}
+	if addr := state.LocalTunnelAddr(); addr != "" {
+		fmt.Fprintf(w, "local address:\t%s\n", addr)
+	}
+	if addr := state.RemoteAddr(); addr != "" {
+		fmt.Fprintf(w, "remote address:\t%s\n", addr)
+	}
+	return w.Flush()
+}
+
+func (c *ctl) clients(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	clients, err := c.client.ClientList()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tCOMMON NAME\tREAL ADDRESS\tVIRTUAL ADDRESS\tRECEIVED\tSENT\tCONNECTED SINCE\n")
+	for _, cl := range clients {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%s\n", cl.ClientID, cl.CommonName, cl.RealAddress,
+			cl.VirtualAddress, cl.BytesReceived, cl.BytesSent, cl.ConnectedSince.Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func (c *ctl) kill(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	cid, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		if len(args) > 1 {
+			return errors.New("a message can only be given with a client id")
+		}
+		return c.client.Kill(args[0])
+	}
+	var message string
+	if len(args) > 1 {
+		message = args[1]
+	}
+	return c.client.ClientKill(cid, message)
+}
+
+func (c *ctl) signal(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	name := strings.ToUpper(args[0])
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	for _, s := range signals {
+		if s == name {
+			return c.client.SendSignal(name)
+		}
+	}
+	return fmt.Errorf("unsupported signal %q", args[0])
+}
+
+func (c *ctl) log(args []string) error {
+	n := 20
+	switch len(args) {
+	case 0:
+	case 1:
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			return errUsage
+		}
+	default:
+		return errUsage
+	}
+	history, err := c.client.RecentLog(n)
+	if err != nil {
+		return err
+	}
+	for _, e := range history {
+		c.printf("%s %s %s\n", e.RawTimestamp(), e.Flags(), e.Message())
+	}
+	return nil
+}
+
+func (c *ctl) watch(args []string) error {
+	if len(args) == 0 {
+		args = []string{"state"}
+	}
+	if len(args) == 1 && args[0] == "off" {
+		return c.setWatching(watchKinds, false)
+	}
+	var kinds []string
+	for _, arg := range args {
+		if arg == "all" {
+			kinds = watchKinds
+			break
+		}
+		if !contains(watchKinds, arg) {
+			return fmt.Errorf("unknown kind of event %q", arg)
+		}
+		kinds = append(kinds, arg)
+	}
+	return c.setWatching(kinds, true)
+}
+
+// setWatching enables or disables the OpenVPN events of the given kinds,
+// and their printing.
+func (c *ctl) setWatching(kinds []string, on bool) error {
+	for _, kind := range kinds {
+		var err error
+		switch kind {
+		case "state":
+			err = c.client.SetStateEvents(on)
+		case "bytecount":
+			interval := byteCountInterval
+			if !on {
+				interval = 0
+			}
+			err = c.client.SetByteCountEvents(interval)
+		case "log":
+			err = c.client.SetLogEvents(on)
+		case "echo":
+			err = c.client.SetEchoEvents(on)
+		}
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.watching[kind] = on
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// printEvents prints the events received from the client while any of
+// them are being watched, until the channel is closed. Events of kinds
+// that cannot be enabled or disabled, such as holds and fatal errors, are
+// printed while any kind is watched.
+func (c *ctl) printEvents(events <-chan openvpn.Event) {
+	for e := range events {
+		kind := eventKind(e)
+		c.mu.Lock()
+		show := c.watching[kind]
+		if kind == "" {
+			for _, on := range c.watching {
+				show = show || on
+			}
+		}
+		if show {
+			fmt.Fprintf(c.out, "%s %s\n", time.Now().Format(time.TimeOnly), e)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// eventKind returns the kind of event watched to show e, or the empty
+// string for other events.
+func eventKind(e openvpn.Event) string {
+	switch e.(type) {
+	case *openvpn.StateEvent:
+		return "state"
+	case *openvpn.ByteCountEvent:
+		return "bytecount"
+	case *openvpn.LogEvent:
+		return "log"
+	case *openvpn.EchoEvent:
+		return "echo"
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/gopenvpntest"
+)
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		ok   bool
+	}{
+		{"st", "status ", true},
+		{"", "", true}, // the candidates share no prefix
+		{"si", "signal ", true},
+		{"signal SIGU", "signal SIGUSR", true},
+		{"signal SIGUSR2", "signal SIGUSR2 ", true},
+		{"watch b", "watch bytecount ", true},
+		{"kill ", "", false},
+		{"nothing", "", false},
+	}
+	for i, test := range tests {
+		got, pos, ok := complete(test.line, len(test.line))
+		if ok != test.ok || got != test.want || ok && pos != len(got) {
+			t.Errorf("test %d got %q, %d, %v; want %q, %v", i, got, pos, ok, test.want, test.ok)
+		}
+	}
+}
+
+// fakeOpenVPN returns a ctl connected to a fake OpenVPN process, which
+// replies to each command with the reply given for it, or "SUCCESS: ok",
+// and a function returning the commands received.
+func fakeOpenVPN(t *testing.T, replies map[string]gopenvpntest.Reply) (*ctl, *strings.Builder, func() []string) {
+	srv := &gopenvpntest.Server{}
+	t.Cleanup(func() { srv.Close() })
+	srv.Handle("", gopenvpntest.Success("ok"))
+	for cmd, reply := range replies {
+		srv.Handle(cmd, reply)
+	}
+	out := &strings.Builder{}
+	return newCtl(srv.Client(), out), out, srv.Commands
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		args    []string
+		sent    []string
+		wantErr bool
+	}{
+		{[]string{"kill", "7"}, []string{"client-kill 7"}, false},
+		{[]string{"kill", "7", "HALT"}, []string{`client-kill 7 "HALT"`}, false},
+		{[]string{"kill", "alice"}, []string{`kill "alice"`}, false},
+		{[]string{"kill", "alice", "HALT"}, nil, true},
+		{[]string{"signal", "usr1"}, []string{`signal "SIGUSR1"`}, false},
+		{[]string{"signal", "SIGKILL"}, nil, true},
+		{[]string{"watch"}, []string{"state on"}, false},
+		{[]string{"watch", "log", "bytecount"}, []string{"log on", "bytecount 5"}, false},
+		{[]string{"watch", "off"}, []string{"state off", "bytecount 0", "log off", "echo off"}, false},
+		{[]string{"watch", "everything"}, nil, true},
+		{[]string{"log", "x"}, nil, true},
+		{[]string{"frobnicate"}, nil, true},
+	}
+	for i, test := range tests {
+		c, _, sent := fakeOpenVPN(t, nil)
+		err := c.run(test.args)
+		if (err != nil) != test.wantErr {
+			t.Errorf("test %d got error %v; want error %v", i, err, test.wantErr)
+		}
+		got := sent()
+		if strings.Join(got, "\n") != strings.Join(test.sent, "\n") {
+			t.Errorf("test %d sent %q; want %q", i, got, test.sent)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	c, out, _ := fakeOpenVPN(t, map[string]gopenvpntest.Reply{
+		"pid":     gopenvpntest.Success("pid=1234"),
+		"version": gopenvpntest.Lines("OpenVPN Version: OpenVPN 2.6.8 x86_64-pc-linux-gnu", "Management Version: 5"),
+		"state":   gopenvpntest.Lines("1700000000,CONNECTED,SUCCESS,10.8.0.2,203.0.113.1,1194,,"),
+	})
+	if err := c.run([]string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1234", "OpenVPN 2.6.8", "CONNECTED", "10.8.0.2", "203.0.113.1"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("status output %q does not contain %q", out.String(), want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Command gopenvpnctl controls an OpenVPN process through its management
+// interface.
+//
+// Usage:
+//
+//	gopenvpnctl [-addr address] [-reason text] [command [args...]]
+//
+// The address is that of the management interface, in the form accepted by
+// openvpn.Dial, and defaults to the value of $GOPENVPN_ADDR, or to
+// 127.0.0.1:7505. The commands are:
+//
+//	status                  show the process and connection state
+//	clients                 list the clients connected to a server
+//	kill target [message]   disconnect a client by client id, common
+//	                        name or real address
+//	signal name             send SIGHUP, SIGTERM, SIGUSR1 or SIGUSR2
+//	log [n]                 show the last n messages of the log history
+//	watch [kind...]         show events as they happen, where each kind
+//	                        is one of state, bytecount, log, echo or all
+//
+// Given a command, gopenvpnctl runs it and exits, except that watch runs
+// until interrupted. Without one, it reads commands interactively, with
+// line editing and tab completion when run in a terminal; there, watch
+// shows events alongside the prompt until "watch off" is given.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+const defaultAddr = "127.0.0.1:7505"
+
+func main() {
+	addr := flag.String("addr", "", "management interface address (default $GOPENVPN_ADDR or "+defaultAddr+")")
+	reason := flag.String("reason", "", "reason recorded for commands in the OpenVPN process's audit log")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [command [args...]]\n\ncommands:\n", os.Args[0])
+		for _, cmd := range commands {
+			fmt.Fprintf(flag.CommandLine.Output(), "  %-24s %s\n", cmd.usage, cmd.help)
+		}
+		fmt.Fprintf(flag.CommandLine.Output(), "\nflags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+
+	if *addr == "" {
+		*addr = os.Getenv("GOPENVPN_ADDR")
+	}
+	if *addr == "" {
+		*addr = defaultAddr
+	}
+
+	events := make(chan openvpn.Event, 64)
+	client, err := openvpn.Dial(*addr, events)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+	if *reason != "" {
+		client = client.WithReason(*reason)
+	}
+
+	c := newCtl(client, os.Stdout)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.printEvents(events)
+	}()
+
+	if flag.NArg() == 0 {
+		if err := c.repl(os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := c.run(flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+	if flag.Arg(0) == "watch" {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			log.Fatal("management connection closed")
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const prompt = "gopenvpnctl> "
+
+// replCommands are the commands understood only in interactive mode.
+var replCommands = []string{"help", "quit"}
+
+// repl reads and runs commands from in until it is exhausted or the quit
+// command is given. If in is a terminal, it is put into raw mode for line
+// editing and completion.
+func (c *ctl) repl(in *os.File) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			if !c.runLine(scanner.Text()) {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, os.Stdout}, prompt)
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return complete(line, pos)
+	}
+	// Events are written through the terminal so that the prompt and the
+	// line being edited are redrawn after them.
+	c.setOutput(t)
+	defer c.setOutput(os.Stdout)
+
+	for {
+		line, err := t.ReadLine()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !c.runLine(line) {
+			return nil
+		}
+	}
+}
+
+// runLine runs the command on the given line, printing any error, and
+// returns false if it is the quit command.
+func (c *ctl) runLine(line string) bool {
+	args := strings.Fields(line)
+	switch {
+	case len(args) == 0:
+	case args[0] == "quit" || args[0] == "exit":
+		return false
+	case args[0] == "help":
+		for _, cmd := range commands {
+			c.printf("%-24s %s\n", cmd.usage, cmd.help)
+		}
+		c.printf("%-24s %s\n", "quit", "exit gopenvpnctl")
+	default:
+		if err := c.run(args); err != nil {
+			c.printf("error: %s\n", err)
+		}
+	}
+	return true
+}
+
+// complete completes the word ending at pos in line, returning the new
+// line and position and true if there are candidates for it. The word is
+// extended to the longest prefix common to the candidates, followed by a
+// space if there is only one.
+func complete(line string, pos int) (string, int, bool) {
+	if pos != len(line) {
+		return "", 0, false
+	}
+	words := strings.Fields(line)
+	word := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		word = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	var candidates []string
+	if len(words) == 0 {
+		for _, cmd := range commands {
+			candidates = append(candidates, cmd.name)
+		}
+		candidates = append(candidates, replCommands...)
+	} else {
+		for _, cmd := range commands {
+			if cmd.name == words[0] && cmd.complete != nil {
+				candidates = cmd.complete()
+			}
+		}
+	}
+
+	var matches []string
+	for _, cand := range candidates {
+		if strings.HasPrefix(cand, word) {
+			matches = append(matches, cand)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	sort.Strings(matches)
+	prefix := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(matches) == 1 {
+		prefix += " "
+	}
+	line = line[:len(line)-len(word)] + prefix
+	return line, len(line), true
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"io"
+	"strings"
+)
+
+// accessServerPrefix introduces the metadata comments in profiles
+// generated by OpenVPN Access Server.
+const accessServerPrefix = "OVPN_ACCESS_SERVER_"
+
+// AccessServerProfile is a profile generated by OpenVPN Access Server,
+// along with the metadata Access Server embeds in it as comments.
+type AccessServerProfile struct {
+	Config *Config
+
+	// Metadata are the values of the OVPN_ACCESS_SERVER_ comments, keyed by
+	// the remainder of the name, such as "PROFILE" or "WSHOST". Multi-line
+	// values delimited by _START and _STOP comments, such as
+	// WEB_CA_BUNDLE, are joined with newlines.
+	Metadata map[string]string
+
+	// Name is the profile name, such as "alice@vpn.example.com", without
+	// any "/AUTOLOGIN" suffix.
+	Name string
+
+	// Username and Server are the parts of Name, if it has the usual
+	// "user@server" form.
+	Username string
+	Server   string
+
+	// WebServer is the host and port of the Access Server web service,
+	// from which updated profiles can be downloaded.
+	WebServer string
+
+	// Autologin is true for autologin profiles, which connect using only
+	// the certificate they contain, as opposed to user-locked profiles
+	// which also require a username and password.
+	Autologin bool
+}
+
+// ParseAccessServer parses a profile generated by OpenVPN Access Server.
+// Profiles that lack Access Server metadata are also accepted, although
+// the resulting metadata is then empty.
+func ParseAccessServer(r io.Reader) (*AccessServerProfile, error) {
+	c, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &AccessServerProfile{
+		Config:   c,
+		Metadata: accessServerMetadata(c),
+	}
+
+	p.Name = p.Metadata["PROFILE"]
+	if name := strings.TrimSuffix(p.Name, "/AUTOLOGIN"); name != p.Name {
+		p.Name = name
+		p.Autologin = true
+	} else {
+		p.Autologin = p.Metadata["PROFILE"] != "" && !c.Has("auth-user-pass")
+	}
+	if at := strings.LastIndexByte(p.Name, '@'); at != -1 {
+		p.Username = p.Name[:at]
+		p.Server = p.Name[at+1:]
+	}
+	p.WebServer = p.Metadata["WSHOST"]
+	return p, nil
+}
+
+// accessServerMetadata extracts the metadata comments from c.
+func accessServerMetadata(c *Config) map[string]string {
+	var comments []string
+	for _, d := range c.Directives {
+		comments = append(comments, d.Comments...)
+	}
+	comments = append(comments, c.Trailer...)
+
+	metadata := map[string]string{}
+	var block string
+	var blockLines []string
+	for _, comment := range comments {
+		text := strings.TrimSpace(strings.TrimLeft(comment, "#;"))
+		if block != "" {
+			if text == accessServerPrefix+block+"_STOP" {
+				metadata[block] = strings.Join(blockLines, "\n")
+				block, blockLines = "", nil
+			} else {
+				blockLines = append(blockLines, text)
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(text, accessServerPrefix) {
+			continue
+		}
+		text = text[len(accessServerPrefix):]
+		if eq := strings.IndexByte(text, '='); eq != -1 {
+			metadata[text[:eq]] = text[eq+1:]
+		} else if strings.HasSuffix(text, "_START") {
+			block = strings.TrimSuffix(text, "_START")
+		}
+	}
+	return metadata
+}
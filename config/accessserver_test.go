@@ -0,0 +1,67 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const accessServerProfile = `# Automatically generated OpenVPN client config file
+# Generated on Mon Jul 10 12:00:00 2023 by vpn.example.com
+# Define the profile name of this particular configuration file
+# OVPN_ACCESS_SERVER_PROFILE=alice@vpn.example.com/AUTOLOGIN
+# OVPN_ACCESS_SERVER_CLI_PREF_ALLOW_WEB_IMPORT=True
+# OVPN_ACCESS_SERVER_WSHOST=vpn.example.com:443
+# OVPN_ACCESS_SERVER_WEB_CA_BUNDLE_START
+# -----BEGIN CERTIFICATE-----
+# MIIB
+# -----END CERTIFICATE-----
+# OVPN_ACCESS_SERVER_WEB_CA_BUNDLE_STOP
+# OVPN_ACCESS_SERVER_IS_OPENVPN_WEB_CA=0
+setenv FORWARD_COMPATIBLE 1
+client
+server-poll-timeout 4
+nobind
+remote vpn.example.com 1194 udp
+dev tun
+<ca>
+CA
+</ca>
+`
+
+func TestParseAccessServer(t *testing.T) {
+	p, err := ParseAccessServer(strings.NewReader(accessServerProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Autologin {
+		t.Errorf("got user-locked profile; want autologin")
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Name", p.Name, "alice@vpn.example.com"},
+		{"Username", p.Username, "alice"},
+		{"Server", p.Server, "vpn.example.com"},
+		{"WebServer", p.WebServer, "vpn.example.com:443"},
+		{"WEB_CA_BUNDLE", p.Metadata["WEB_CA_BUNDLE"], "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"},
+		{"IS_OPENVPN_WEB_CA", p.Metadata["IS_OPENVPN_WEB_CA"], "0"},
+		{"remote", p.Config.Value("remote"), "vpn.example.com"},
+	}
+	for i, test := range tests {
+		if test.got != test.want {
+			t.Errorf("test %d %s got %q; want %q", i, test.name, test.got, test.want)
+		}
+	}
+
+	locked := strings.Replace(accessServerProfile, "/AUTOLOGIN", "", 1) + "auth-user-pass\n"
+	p, err = ParseAccessServer(strings.NewReader(locked))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Autologin {
+		t.Errorf("got autologin profile; want user-locked")
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NordSecurity/gopenvpn/capability"
+)
+
+// cipherDirectives are the directives whose parameter is a colon-separated
+// list of data channel ciphers.
+var cipherDirectives = []string{"data-ciphers", "ncp-ciphers", "data-ciphers-fallback", "cipher"}
+
+// dcoCiphers are the only data channel ciphers supported by data channel
+// offload.
+var dcoCiphers = map[string]bool{
+	"AES-128-GCM":       true,
+	"AES-192-GCM":       true,
+	"AES-256-GCM":       true,
+	"CHACHA20-POLY1305": true,
+}
+
+// CheckCiphers cross-checks the cipher and compression settings in c
+// against the capabilities of the OpenVPN binary that will use it, as
+// returned by capability.Detect, and against current security guidance.
+// Findings have the following codes:
+//
+//   - "unsupported-cipher" (SeverityError) for a cipher the binary lacks
+//   - "unsupported-directive" (SeverityError) for a cipher or compression
+//     directive the binary is too old to understand
+//   - "unsupported-compression" (SeverityError) for a compression
+//     algorithm the binary was built without
+//   - "deprecated-cipher" (SeverityWarning) for a cipher the binary itself
+//     lists as deprecated
+//   - "no-aead-cipher" (SeverityWarning) if no AEAD cipher can be
+//     negotiated
+//   - "legacy-cipher" (SeverityInfo) for a cipher directive without
+//     data-ciphers, under versions that negotiate ciphers
+//   - "dco-cipher" and "dco-compression" (SeverityInfo) for settings that
+//     prevent the use of data channel offload where it is available
+//
+// Weak ciphers and the security problems with compression are reported by
+// Lint, and so are not repeated here.
+func CheckCiphers(c *Config, caps *capability.Capabilities) []Finding {
+	l := &linter{}
+	negotiates := caps.Version.IsZero() || caps.Version.AtLeast(2, 5)
+
+	for _, name := range []string{"data-ciphers", "data-ciphers-fallback", "compress", "allow-compression"} {
+		if d := c.Get(name); d != nil && !caps.SupportsDirective(name) {
+			l.add(SeverityError, "unsupported-directive", d, fmt.Sprintf("%s is not supported by OpenVPN %s", name, caps.Version))
+		}
+	}
+
+	aead := false
+	// negotiated are the ciphers that may be negotiated, and negotiatedBy
+	// the directive each was given by.
+	var negotiated []string
+	var negotiatedBy []*Directive
+	for _, name := range cipherDirectives {
+		for _, d := range c.GetAll(name) {
+			for _, cipher := range strings.Split(d.Arg(0), ":") {
+				if cipher == "" {
+					continue
+				}
+				if isAEAD(cipher) {
+					aead = true
+				}
+				if name != "data-ciphers-fallback" {
+					negotiated = append(negotiated, cipher)
+					negotiatedBy = append(negotiatedBy, d)
+				}
+
+				switch {
+				case !caps.HasCipher(cipher):
+					l.add(SeverityError, "unsupported-cipher", d, fmt.Sprintf("cipher %s is not supported by this OpenVPN binary", cipher))
+				case !isWeakCipher(cipher) && deprecatedCipher(caps, cipher):
+					l.suggest(SeverityWarning, "deprecated-cipher", d, fmt.Sprintf("cipher %s is deprecated by this OpenVPN binary", cipher), "use an AEAD cipher such as AES-256-GCM")
+				}
+			}
+		}
+	}
+	if len(negotiated) > 0 && !aead {
+		l.suggest(SeverityWarning, "no-aead-cipher", nil, "no AEAD cipher such as AES-256-GCM is configured, so packets use slower and weaker CBC encryption",
+			"add AES-256-GCM to data-ciphers")
+	}
+	if d := c.Get("cipher"); d != nil && negotiates && !c.Has("data-ciphers") && !c.Has("ncp-ciphers") {
+		l.suggest(SeverityInfo, "legacy-cipher", d, "cipher is used only as a fallback for peers that cannot negotiate ciphers", "use data-ciphers instead")
+	}
+
+	for _, d := range c.GetAll("comp-lzo") {
+		if d.Arg(0) != "no" && !caps.Has(capability.FeatureLZO) {
+			l.add(SeverityError, "unsupported-compression", d, "LZO compression is not supported by this OpenVPN binary")
+		}
+	}
+	for _, d := range c.GetAll("compress") {
+		switch alg := d.Arg(0); {
+		case alg == "lzo" && !caps.Has(capability.FeatureLZO):
+			l.add(SeverityError, "unsupported-compression", d, "LZO compression is not supported by this OpenVPN binary")
+		case strings.HasPrefix(alg, "lz4") && !caps.Has(capability.FeatureLZ4):
+			l.add(SeverityError, "unsupported-compression", d, "LZ4 compression is not supported by this OpenVPN binary")
+		}
+	}
+
+	if caps.DCOAvailable() && !c.Has("disable-dco") {
+		for i, cipher := range negotiated {
+			if !dcoCiphers[strings.ToUpper(cipher)] {
+				l.add(SeverityInfo, "dco-cipher", negotiatedBy[i], fmt.Sprintf("cipher %s is not supported by data channel offload", cipher))
+			}
+		}
+		for _, name := range []string{"comp-lzo", "compress"} {
+			if d := c.Get(name); d != nil {
+				l.suggest(SeverityInfo, "dco-compression", d, fmt.Sprintf("%s prevents the use of data channel offload", name), "remove it")
+			}
+		}
+	}
+
+	return l.findings
+}
+
+func isAEAD(cipher string) bool {
+	cipher = strings.ToUpper(cipher)
+	return strings.HasSuffix(cipher, "-GCM") || cipher == "CHACHA20-POLY1305"
+}
+
+func deprecatedCipher(caps *capability.Capabilities, name string) bool {
+	for _, c := range caps.Ciphers {
+		if strings.EqualFold(c.Name, name) {
+			return c.Deprecated
+		}
+	}
+	return false
+}
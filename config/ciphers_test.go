@@ -0,0 +1,95 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/capability"
+)
+
+func TestCheckCiphers(t *testing.T) {
+	modern := &capability.Capabilities{
+		Version:    capability.Version{Major: 2, Minor: 6, Patch: 3},
+		DCOVersion: "2.0.0",
+		Features:   map[capability.Feature]bool{capability.FeatureLZ4: true, capability.FeatureDCO: true},
+		Ciphers: []capability.Cipher{
+			{Name: "AES-256-GCM"},
+			{Name: "AES-128-GCM"},
+			{Name: "AES-256-CBC"},
+			{Name: "CAMELLIA-256-CBC", Deprecated: true},
+		},
+	}
+	old := &capability.Capabilities{
+		Version:  capability.Version{Major: 2, Minor: 4, Patch: 12},
+		Features: map[capability.Feature]bool{capability.FeatureLZO: true},
+	}
+
+	tests := []struct {
+		config string
+		caps   *capability.Capabilities
+		want   []string
+	}{
+		{"data-ciphers AES-256-GCM:AES-128-GCM\n", modern, nil},
+		{"data-ciphers AES-256-GCM:CHACHA20-POLY1305\n", modern, []string{"unsupported-cipher"}},
+		{"data-ciphers AES-256-CBC\n", modern, []string{"no-aead-cipher", "dco-cipher"}},
+		{"data-ciphers AES-256-GCM:CAMELLIA-256-CBC\n", modern, []string{"deprecated-cipher", "dco-cipher"}},
+		{"cipher AES-256-GCM\n", modern, []string{"legacy-cipher"}},
+		{"cipher AES-256-GCM\ndisable-dco\ncomp-lzo\n", modern, []string{"legacy-cipher", "unsupported-compression"}},
+		{"data-ciphers AES-256-GCM\ncompress lz4-v2\n", modern, []string{"dco-compression"}},
+		{"data-ciphers AES-256-GCM\n", old, []string{"unsupported-directive"}},
+		{"cipher AES-256-CBC\ncompress lz4\n", old, []string{"no-aead-cipher", "unsupported-compression"}},
+	}
+
+	for i, test := range tests {
+		c, err := Parse(strings.NewReader(test.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range CheckCiphers(c, test.caps) {
+			got = append(got, f.Code)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestCheckCiphersDCODirective(t *testing.T) {
+	caps := &capability.Capabilities{
+		Version:    capability.Version{Major: 2, Minor: 6, Patch: 3},
+		DCOVersion: "2.0.0",
+		Features:   map[capability.Feature]bool{capability.FeatureDCO: true},
+		Ciphers:    []capability.Cipher{{Name: "AES-256-GCM"}, {Name: "AES-256-CBC"}},
+	}
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"data-ciphers AES-256-CBC\n", "data-ciphers"},
+		{"cipher AES-256-CBC\n", "cipher"},
+		{"data-ciphers AES-256-GCM\nncp-ciphers AES-256-CBC\n", "ncp-ciphers"},
+	}
+
+	for i, test := range tests {
+		c, err := Parse(strings.NewReader(test.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range CheckCiphers(c, caps) {
+			if f.Code != "dco-cipher" {
+				continue
+			}
+			name := ""
+			if f.Directive != nil {
+				name = f.Directive.Name
+			}
+			got = append(got, name)
+		}
+		if len(got) != 1 || got[0] != test.want {
+			t.Errorf("test %d got dco-cipher findings for %q; want %q", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Proto is a transport protocol for connecting to a server.
+type Proto string
+
+// Transport protocols, as accepted by the proto directive on clients.
+const (
+	ProtoUDP  Proto = "udp"
+	ProtoUDP4 Proto = "udp4"
+	ProtoUDP6 Proto = "udp6"
+	ProtoTCP  Proto = "tcp-client"
+	ProtoTCP4 Proto = "tcp4-client"
+	ProtoTCP6 Proto = "tcp6-client"
+)
+
+// DefaultDataCiphers are the data channel ciphers negotiated by profiles
+// built with ClientProfile and ServerProfile unless others are given,
+// in order of preference.
+var DefaultDataCiphers = []string{"AES-256-GCM", "AES-128-GCM", "CHACHA20-POLY1305"}
+
+// DefaultPort is the port used for remotes given without one.
+const DefaultPort = 1194
+
+// Remote is a server that a client may connect to.
+type Remote struct {
+	Host string
+
+	// Port is the server's port, or zero for DefaultPort.
+	Port int
+
+	// Proto is the transport protocol, or the empty string to use the
+	// profile's default.
+	Proto Proto
+}
+
+func (r Remote) args() []string {
+	port := r.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	args := []string{r.Host, strconv.Itoa(port)}
+	if r.Proto != "" {
+		args = append(args, string(r.Proto))
+	}
+	return args
+}
+
+// ClientProfile builds a complete client configuration from typed inputs.
+// Each method returns the profile so that calls can be chained:
+//
+//	cfg, err := config.NewClientProfile().
+//		Remote("vpn.example.com", 1194).
+//		CA(caPEM).
+//		AuthUserPass().
+//		Management("/run/myapp/openvpn.sock").
+//		Build()
+//
+// The resulting configuration has modern defaults: it verifies that the
+// server has a server certificate, requires TLS 1.2 or later, negotiates
+// only AEAD data channel ciphers and never caches passwords.
+type ClientProfile struct {
+	remotes        []Remote
+	proto          Proto
+	dev            string
+	ciphers        []string
+	credentials    []*Directive
+	userPass       bool
+	userPassFile   string
+	askPassFile    string
+	routes         []netip.Prefix
+	redirect       []string
+	redirectSet    bool
+	dnsServers     []netip.Addr
+	dnsDomains     []string
+	managementAddr string
+	queryRemote    bool
+	httpProxy      *HTTPProxy
+	socksProxy     *SOCKSProxy
+	extra          []*Directive
+	err            error
+}
+
+// NewClientProfile returns a new profile with the default settings.
+func NewClientProfile() *ClientProfile {
+	return &ClientProfile{
+		proto:   ProtoUDP,
+		dev:     "tun",
+		ciphers: DefaultDataCiphers,
+	}
+}
+
+// Remote adds a server to connect to, using the profile's protocol. Port
+// may be zero for DefaultPort. Servers are tried in the order given.
+func (p *ClientProfile) Remote(host string, port int) *ClientProfile {
+	return p.AddRemote(Remote{Host: host, Port: port})
+}
+
+// AddRemote adds a server to connect to.
+func (p *ClientProfile) AddRemote(r Remote) *ClientProfile {
+	if r.Host == "" || r.Port < 0 || r.Port > 65535 {
+		p.setErr(fmt.Errorf("invalid remote %s:%d", r.Host, r.Port))
+		return p
+	}
+	if err := checkLine("remote", r.Host); err != nil {
+		p.setErr(err)
+		return p
+	}
+	p.remotes = append(p.remotes, r)
+	return p
+}
+
+// Proto sets the default transport protocol for remotes. The default is
+// ProtoUDP.
+func (p *ClientProfile) Proto(proto Proto) *ClientProfile {
+	p.proto = proto
+	return p
+}
+
+// Device sets the tunnel device type, "tun" or "tap". The default is "tun".
+func (p *ClientProfile) Device(dev string) *ClientProfile {
+	p.dev = dev
+	return p
+}
+
+// Ciphers sets the data channel ciphers to negotiate, in order of
+// preference. The default is DefaultDataCiphers.
+func (p *ClientProfile) Ciphers(ciphers ...string) *ClientProfile {
+	if len(ciphers) == 0 {
+		p.setErr(errors.New("no data channel ciphers given"))
+		return p
+	}
+	p.ciphers = ciphers
+	return p
+}
+
+// CA sets the PEM-encoded certificate authority used to verify the server,
+// which is included in the profile inline.
+func (p *ClientProfile) CA(pem string) *ClientProfile {
+	return p.credential(&Directive{Name: "ca", Inline: true, Content: pem})
+}
+
+// CAFile sets the file containing the certificate authority used to verify
+// the server.
+func (p *ClientProfile) CAFile(path string) *ClientProfile {
+	return p.credential(&Directive{Name: "ca", Args: []string{path}})
+}
+
+// CertKey sets the PEM-encoded client certificate and private key
+// used to authenticate to the server, which are included in the profile
+// inline.
+func (p *ClientProfile) CertKey(certPEM, keyPEM string) *ClientProfile {
+	p.credential(&Directive{Name: "cert", Inline: true, Content: certPEM})
+	return p.credential(&Directive{Name: "key", Inline: true, Content: keyPEM})
+}
+
+// CertKeyFiles sets the files containing the client certificate and
+// private key used to authenticate to the server.
+func (p *ClientProfile) CertKeyFiles(certPath, keyPath string) *ClientProfile {
+	p.credential(&Directive{Name: "cert", Args: []string{certPath}})
+	return p.credential(&Directive{Name: "key", Args: []string{keyPath}})
+}
+
+// TLSCrypt sets the pre-shared key used to encrypt and authenticate the
+// control channel, which is included in the profile inline.
+func (p *ClientProfile) TLSCrypt(key string) *ClientProfile {
+	return p.credential(&Directive{Name: "tls-crypt", Inline: true, Content: key})
+}
+
+// AuthUserPass makes the client authenticate with a username and password
+// in addition to, or instead of, a client certificate. If the profile has
+// a management interface then the credentials are requested over it, and
+// can be supplied using openvpn.MgmtClient; otherwise OpenVPN prompts for
+// them on the terminal.
+func (p *ClientProfile) AuthUserPass() *ClientProfile {
+	p.userPass = true
+	return p
+}
+
+// AuthUserPassFile is like AuthUserPass, except that the credentials are
+// read from the given file, such as one written by WriteAuthUserPass,
+// rather than being requested.
+func (p *ClientProfile) AuthUserPassFile(path string) *ClientProfile {
+	p.userPass = true
+	p.userPassFile = path
+	return p
+}
+
+// AskPassFile sets the file from which the passphrase for an encrypted
+// private key is read, such as one written by WriteAskPass. Otherwise, the
+// passphrase is requested in the same way as for AuthUserPass.
+func (p *ClientProfile) AskPassFile(path string) *ClientProfile {
+	p.askPassFile = path
+	return p
+}
+
+// Route adds a route to be directed through the tunnel.
+func (p *ClientProfile) Route(network netip.Prefix) *ClientProfile {
+	if !network.IsValid() {
+		p.setErr(fmt.Errorf("invalid route %v", network))
+		return p
+	}
+	p.routes = append(p.routes, network.Masked())
+	return p
+}
+
+// RedirectGateway directs all traffic through the tunnel, using the given
+// redirect-gateway flags. The default flag "def1" is used if none are given.
+func (p *ClientProfile) RedirectGateway(flags ...string) *ClientProfile {
+	if len(flags) == 0 {
+		flags = []string{"def1"}
+	}
+	p.redirect = flags
+	p.redirectSet = true
+	return p
+}
+
+// DNS sets the DNS servers to use while connected.
+func (p *ClientProfile) DNS(servers ...netip.Addr) *ClientProfile {
+	p.dnsServers = append(p.dnsServers, servers...)
+	return p
+}
+
+// DNSDomain adds a DNS search domain to use while connected.
+func (p *ClientProfile) DNSDomain(domain string) *ClientProfile {
+	if err := checkLine("DNS domain", domain); err != nil {
+		p.setErr(err)
+		return p
+	}
+	p.dnsDomains = append(p.dnsDomains, domain)
+	return p
+}
+
+// Management enables the management interface at the given address, using
+// the same conventions as openvpn.Dial, and adds the directives needed by
+// this module: OpenVPN waits for a management client to release it before
+// connecting, and passwords are requested over the management interface.
+func (p *ClientProfile) Management(addr string) *ClientProfile {
+	if _, err := managementArgs(addr); err != nil {
+		p.setErr(err)
+		return p
+	}
+	p.managementAddr = addr
+	return p
+}
+
+// QueryRemote makes OpenVPN ask the management client which remote to
+// connect to, as answered by RemoteResponder. It requires Management.
+func (p *ClientProfile) QueryRemote() *ClientProfile {
+	p.queryRemote = true
+	return p
+}
+
+// HTTPProxy makes the client connect through an HTTP proxy. This requires
+// a TCP transport protocol. If the proxy credentials are requested
+// interactively and the profile has a management interface, they are
+// requested over it in the same way as for AuthUserPass.
+func (p *ClientProfile) HTTPProxy(proxy HTTPProxy) *ClientProfile {
+	if err := proxy.validate(); err != nil {
+		p.setErr(err)
+		return p
+	}
+	p.httpProxy = &proxy
+	return p
+}
+
+// SOCKSProxy makes the client connect through a SOCKS5 proxy.
+func (p *ClientProfile) SOCKSProxy(proxy SOCKSProxy) *ClientProfile {
+	p.socksProxy = &proxy
+	return p
+}
+
+// Directive adds an arbitrary directive to the end of the profile, for
+// settings not covered by the other methods.
+func (p *ClientProfile) Directive(name string, args ...string) *ClientProfile {
+	for _, s := range append([]string{name}, args...) {
+		if err := checkLine(name+" directive", s); err != nil {
+			p.setErr(err)
+			return p
+		}
+	}
+	p.extra = append(p.extra, &Directive{Name: name, Args: args})
+	return p
+}
+
+// Build returns the configuration described by the profile, or the first
+// error from the preceding calls. It is an error for the profile to have no
+// remotes or no certificate authority, or for any parameter to contain a
+// line break.
+func (p *ClientProfile) Build() (*Config, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if len(p.remotes) == 0 {
+		return nil, errors.New("client profile has no remotes")
+	}
+	if p.queryRemote && p.managementAddr == "" {
+		return nil, errors.New("querying remotes requires a management interface")
+	}
+	if p.httpProxy != nil && p.socksProxy != nil {
+		return nil, errors.New("client profile cannot use both an HTTP and a SOCKS proxy")
+	}
+	if p.httpProxy != nil {
+		for _, r := range p.remotes {
+			proto := r.Proto
+			if proto == "" {
+				proto = p.proto
+			}
+			if protoFamily(proto) != "tcp" {
+				return nil, fmt.Errorf("HTTP proxy requires TCP, but remote %s uses %s", r.Host, proto)
+			}
+		}
+	}
+	if !hasDirective(p.credentials, "ca") {
+		return nil, errors.New("client profile has no certificate authority")
+	}
+
+	c := &Config{}
+	c.Add("client")
+	c.Add("dev", p.dev)
+	c.Add("proto", string(p.proto))
+	for _, r := range p.remotes {
+		c.Add("remote", r.args()...)
+	}
+	c.Add("resolv-retry", "infinite")
+	c.Add("nobind")
+	c.Add("persist-key")
+	c.Add("persist-tun")
+
+	c.Add("remote-cert-tls", "server")
+	c.Add("tls-version-min", "1.2")
+	c.Add("data-ciphers", strings.Join(p.ciphers, ":"))
+	c.Add("auth-nocache")
+	if p.userPassFile != "" {
+		c.Add("auth-user-pass", p.userPassFile)
+	} else if p.userPass {
+		c.Add("auth-user-pass")
+	}
+	if p.askPassFile != "" {
+		c.Add("askpass", p.askPassFile)
+	}
+
+	if p.httpProxy != nil {
+		c.SetHTTPProxy(p.httpProxy)
+	}
+	if p.socksProxy != nil {
+		if err := c.SetSOCKSProxy(p.socksProxy); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, route := range p.routes {
+		d := routeDirective(route)
+		c.Add(d.Name, d.Args...)
+	}
+	if p.redirectSet {
+		c.Add("redirect-gateway", p.redirect...)
+	}
+	for _, server := range p.dnsServers {
+		if server.Is4() {
+			c.Add("dhcp-option", "DNS", server.String())
+		} else {
+			c.Add("dhcp-option", "DNS6", server.String())
+		}
+	}
+	for _, domain := range p.dnsDomains {
+		c.Add("dhcp-option", "DOMAIN", domain)
+	}
+
+	if p.managementAddr != "" {
+		args, _ := managementArgs(p.managementAddr)
+		c.Add("management", args...)
+		c.Add("management-hold")
+		if p.queryRemote {
+			c.Add("management-query-remote")
+		}
+		if (p.userPass && p.userPassFile == "") || p.proxyPrompts() {
+			c.Add("management-query-passwords")
+		}
+		if p.userPass && p.userPassFile == "" {
+			c.Add("auth-retry", "interact")
+		}
+	}
+
+	c.Add("verb", "3")
+	c.Directives = append(c.Directives, p.extra...)
+	c.Directives = append(c.Directives, p.credentials...)
+	for _, d := range c.Directives {
+		if err := d.checkArgs(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// credential sets a credential directive, replacing any previous one with
+// the same name.
+func (p *ClientProfile) credential(d *Directive) *ClientProfile {
+	for i, existing := range p.credentials {
+		if existing.Name == d.Name {
+			p.credentials[i] = d
+			return p
+		}
+	}
+	p.credentials = append(p.credentials, d)
+	return p
+}
+
+// proxyPrompts returns true if OpenVPN requests proxy credentials
+// interactively.
+func (p *ClientProfile) proxyPrompts() bool {
+	return (p.httpProxy != nil && p.httpProxy.prompts()) || (p.socksProxy != nil && p.socksProxy.AuthFile == ProxyCredentialsPrompt)
+}
+
+func (p *ClientProfile) setErr(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// checkLine returns an error if s, which is used in the given part of a
+// profile, contains a line break, which would let it add directives of its
+// own.
+func checkLine(what, s string) error {
+	if strings.ContainsAny(s, "\r\n") {
+		return fmt.Errorf("%s %q contains a line break", what, s)
+	}
+	return nil
+}
+
+// managementArgs returns the parameters of the management directive for
+// the given address, as described for openvpn.Dial.
+func managementArgs(addr string) ([]string, error) {
+	if strings.HasPrefix(addr, "/") {
+		return []string{addr, "unix"}, nil
+	}
+
+	sepIdx := strings.LastIndexByte(addr, ':')
+	if sepIdx == -1 {
+		return nil, fmt.Errorf("invalid management address %q", addr)
+	}
+	host := strings.Trim(addr[:sepIdx], "[]")
+	return []string{host, addr[sepIdx+1:]}, nil
+}
+
+// prefixMask4 returns the IPv4 netmask for the given prefix length.
+func prefixMask4(bits int) [4]byte {
+	var mask [4]byte
+	for i := 0; i < bits; i++ {
+		mask[i/8] |= 0x80 >> (i % 8)
+	}
+	return mask
+}
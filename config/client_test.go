@@ -0,0 +1,88 @@
+package config
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClientProfile(t *testing.T) {
+	c, err := NewClientProfile().
+		Remote("vpn1.example.com", 0).
+		AddRemote(Remote{Host: "vpn2.example.com", Port: 443, Proto: ProtoTCP}).
+		CA("CA\n").
+		TLSCrypt("TC\n").
+		AuthUserPass().
+		Route(netip.MustParsePrefix("10.1.2.3/16")).
+		Route(netip.MustParsePrefix("fd00::/64")).
+		RedirectGateway().
+		DNS(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("fd00::1")).
+		DNSDomain("corp.example.com").
+		Management("127.0.0.1:7505").
+		Directive("mssfix", "1400").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `client
+dev tun
+proto udp
+remote vpn1.example.com 1194
+remote vpn2.example.com 443 tcp-client
+resolv-retry infinite
+nobind
+persist-key
+persist-tun
+remote-cert-tls server
+tls-version-min 1.2
+data-ciphers AES-256-GCM:AES-128-GCM:CHACHA20-POLY1305
+auth-nocache
+auth-user-pass
+route 10.1.0.0 255.255.0.0
+route-ipv6 fd00::/64
+redirect-gateway def1
+dhcp-option DNS 10.0.0.1
+dhcp-option DNS6 fd00::1
+dhcp-option DOMAIN corp.example.com
+management 127.0.0.1 7505
+management-hold
+management-query-passwords
+auth-retry interact
+verb 3
+mssfix 1400
+<ca>
+CA
+</ca>
+<tls-crypt>
+TC
+</tls-crypt>
+`
+	if got := c.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if findings := Lint(c); len(findings) != 0 {
+		t.Errorf("got lint findings %v; want none", findings)
+	}
+}
+
+func TestClientProfileErrors(t *testing.T) {
+	tests := []*ClientProfile{
+		NewClientProfile().CA("CA\n"),
+		NewClientProfile().Remote("vpn.example.com", 0),
+		NewClientProfile().Remote("", 0).CA("CA\n"),
+		NewClientProfile().Remote("vpn.example.com", 70000).CA("CA\n"),
+		NewClientProfile().Remote("vpn.example.com", 0).CA("CA\n").Management("localhost"),
+		NewClientProfile().Remote("vpn.example.com", 0).CA("CA\n").Ciphers(),
+		NewClientProfile().Remote("vpn.example.com\nscript-security 2\nup /tmp/evil.sh", 1194).CA("CA\n"),
+		NewClientProfile().Remote("vpn.example.com", 0).CA("CA\n").DNSDomain("example.com\r\nup /tmp/evil.sh"),
+		NewClientProfile().Remote("vpn.example.com", 0).CA("CA\n").Directive("setenv", "FOO", "a\nup /tmp/evil.sh"),
+		NewClientProfile().Remote("vpn.example.com", 0).CA("CA\n").Directive("verb 3\nup", "/tmp/evil.sh"),
+		NewClientProfile().Remote("vpn.example.com", 0).CAFile("ca.crt\nup /tmp/evil.sh"),
+	}
+
+	for i, test := range tests {
+		if _, err := test.Build(); err == nil {
+			t.Errorf("test %d got no error", i)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Directive is a single configuration directive, such as "remote
+// vpn.example.com 1194 udp".
+type Directive struct {
+	// Name is the name of the directive, without any leading "--".
+	Name string
+
+	// Args are the directive's parameters, with any quoting and escaping
+	// removed.
+	Args []string
+
+	// Inline is true if the directive is an inline block, such as
+	// "<ca>...</ca>", in which case Content holds the text of the block
+	// and Args is empty.
+	Inline  bool
+	Content string
+
+	// Comments are the comment lines immediately preceding the directive,
+	// including their leading "#" or ";".
+	Comments []string
+
+	// Line is the line number the directive was parsed from, or zero if
+	// it was not parsed from a file.
+	Line int
+}
+
+// Arg returns the directive's i'th parameter, or the empty string if it has
+// too few parameters.
+func (d *Directive) Arg(i int) string {
+	if i < 0 || i >= len(d.Args) {
+		return ""
+	}
+	return d.Args[i]
+}
+
+// Int returns the directive's i'th parameter as an integer.
+func (d *Directive) Int(i int) (int, error) {
+	if i < 0 || i >= len(d.Args) {
+		return 0, fmt.Errorf("%s has no parameter %d", d.Name, i+1)
+	}
+	v, err := strconv.Atoi(d.Args[i])
+	if err != nil {
+		return 0, fmt.Errorf("%s parameter %d: %q is not an integer", d.Name, i+1, d.Args[i])
+	}
+	return v, nil
+}
+
+// Config is an OpenVPN configuration, as an ordered list of directives.
+// Directives may appear more than once, as is usual for "remote" and
+// "route".
+type Config struct {
+	Directives []*Directive
+
+	// Trailer are any comment lines following the last directive.
+	Trailer []string
+}
+
+// Get returns the first directive with the given name, or nil if there is
+// none.
+func (c *Config) Get(name string) *Directive {
+	for _, d := range c.Directives {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// GetAll returns all of the directives with the given name, in order.
+func (c *Config) GetAll(name string) []*Directive {
+	var ret []*Directive
+	for _, d := range c.Directives {
+		if d.Name == name {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// Has returns true if there is at least one directive with the given name.
+func (c *Config) Has(name string) bool {
+	return c.Get(name) != nil
+}
+
+// Value returns the first parameter of the first directive with the given
+// name, or the empty string if there is no such directive.
+func (c *Config) Value(name string) string {
+	if d := c.Get(name); d != nil {
+		return d.Arg(0)
+	}
+	return ""
+}
+
+// Add appends a new directive to the end of the configuration, returning
+// the new directive.
+func (c *Config) Add(name string, args ...string) *Directive {
+	d := &Directive{Name: name, Args: args}
+	c.Directives = append(c.Directives, d)
+	return d
+}
+
+// Set replaces the parameters of the first directive with the given name,
+// removing any other directives of the same name, or appends a new
+// directive if there is none. It returns the resulting directive.
+func (c *Config) Set(name string, args ...string) *Directive {
+	var first *Directive
+	c.filter(func(d *Directive) bool {
+		if d.Name != name {
+			return true
+		}
+		if first != nil {
+			return false
+		}
+		first = d
+		return true
+	})
+
+	if first == nil {
+		return c.Add(name, args...)
+	}
+	first.Args = args
+	return first
+}
+
+// Remove removes all of the directives with the given name, returning the
+// number removed.
+func (c *Config) Remove(name string) int {
+	return c.filter(func(d *Directive) bool {
+		return d.Name != name
+	})
+}
+
+// filter removes the directives for which keep returns false, returning the
+// number removed.
+func (c *Config) filter(keep func(*Directive) bool) int {
+	kept := c.Directives[:0]
+	for _, d := range c.Directives {
+		if keep(d) {
+			kept = append(kept, d)
+		}
+	}
+	n := len(c.Directives) - len(kept)
+	for i := len(kept); i < len(c.Directives); i++ {
+		c.Directives[i] = nil
+	}
+	c.Directives = kept
+	return n
+}
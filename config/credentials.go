@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteAuthUserPass writes a file for use with the auth-user-pass directive,
+// containing the given username and password, with permissions that allow
+// only the current user to read it. The file is created in dir, or in the
+// default directory for temporary files if dir is empty.
+//
+// It returns the path to the file and a function that shreds and removes
+// it, which should be called once OpenVPN no longer needs the file. Note
+// that OpenVPN re-reads the file on reconnection if auth-nocache is used.
+func WriteAuthUserPass(dir, username, password string) (string, func() error, error) {
+	if strings.ContainsAny(username, "\r\n") || strings.ContainsAny(password, "\r\n") {
+		return "", nil, errors.New("username and password must not contain line breaks")
+	}
+	return writeSecret(dir, "auth-", username+"\n"+password+"\n")
+}
+
+// WriteAskPass writes a file for use with the askpass directive, containing
+// the passphrase for an encrypted private key. It is otherwise the same as
+// WriteAuthUserPass.
+func WriteAskPass(dir, passphrase string) (string, func() error, error) {
+	if strings.ContainsAny(passphrase, "\r\n") {
+		return "", nil, errors.New("passphrase must not contain line breaks")
+	}
+	return writeSecret(dir, "askpass-", passphrase+"\n")
+}
+
+// writeSecret writes content to a new file readable only by the current
+// user. The file is written under a temporary name and then renamed, so
+// that it is never visible with partial content.
+func writeSecret(dir, prefix, content string) (string, func() error, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	f, err := os.CreateTemp(dir, "."+prefix+"*.tmp")
+	if err != nil {
+		return "", nil, err
+	}
+	tmpPath := f.Name()
+	fail := func(err error) (string, func() error, error) {
+		f.Close()
+		os.Remove(tmpPath)
+		return "", nil, err
+	}
+
+	if err := restrictFile(f); err != nil {
+		return fail(err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		return fail(err)
+	}
+	if err := f.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := f.Close(); err != nil {
+		return fail(err)
+	}
+
+	path := filepath.Join(dir, strings.TrimSuffix(filepath.Base(tmpPath)[1:], ".tmp"))
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", nil, err
+	}
+	return path, func() error { return shred(path) }, nil
+}
+
+// shred overwrites a file with zeros before removing it. This is a best
+// effort: on copy-on-write and journaling filesystems, and on flash
+// storage, the original content may survive elsewhere on the device.
+func shred(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	info, err := f.Stat()
+	if err == nil {
+		_, err = io.CopyN(f, zeroReader{}, info.Size())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(path); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
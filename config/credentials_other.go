@@ -0,0 +1,12 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+)
+
+// restrictFile ensures that only the current user can access f.
+func restrictFile(f *os.File) error {
+	return f.Chmod(0o600)
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWriteAuthUserPass(t *testing.T) {
+	dir := t.TempDir()
+	path, cleanup, err := WriteAuthUserPass(dir, "alice", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "alice\ns3cret\n"; string(content) != want {
+		t.Errorf("got %q; want %q", content, want)
+	}
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("got mode %v; want 0600", info.Mode().Perm())
+	}
+
+	c, err := NewClientProfile().Remote("vpn.example.com", 0).CA("CA\n").
+		AuthUserPassFile(path).Management("127.0.0.1:7505").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Value("auth-user-pass"); got != path {
+		t.Errorf("got auth-user-pass %q; want %q", got, path)
+	}
+	if c.Has("management-query-passwords") {
+		t.Errorf("got management-query-passwords with credentials file")
+	}
+
+	if err := cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("got %d files after cleanup; want none", len(entries))
+	}
+	if err := cleanup(); err != nil {
+		t.Errorf("second cleanup got %v; want nil", err)
+	}
+}
+
+func TestWriteAuthUserPassInvalid(t *testing.T) {
+	if _, _, err := WriteAuthUserPass(t.TempDir(), "alice", "line\nbreak"); err == nil || !strings.Contains(err.Error(), "line breaks") {
+		t.Errorf("got %v; want line break error", err)
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/NordSecurity/gopenvpn/capability"
+)
+
+// deprecation describes a directive that is deprecated or has been removed
+// from OpenVPN.
+type deprecation struct {
+	name string
+
+	// deprecated and removed are the major and minor versions in which the
+	// directive was deprecated and removed, with removed zero if it has not
+	// been and deprecated zero if the version is unknown.
+	deprecated [2]int
+	removed    [2]int
+
+	suggestion string
+
+	// applies, if set, restricts the deprecation to some uses of the
+	// directive.
+	applies func(d *Directive) bool
+}
+
+var deprecations = []deprecation{
+	{name: "comp-lzo", deprecated: [2]int{2, 4}, suggestion: "remove it, or use \"compress stub-v2\" if the server requires compression framing"},
+	{name: "compress", deprecated: [2]int{2, 5}, suggestion: "use \"compress stub-v2\" or remove it; compression is vulnerable to VORACLE attacks",
+		applies: func(d *Directive) bool {
+			alg := d.Arg(0)
+			return alg != "stub" && alg != "stub-v2" && alg != "migrate"
+		}},
+	{name: "ns-cert-type", deprecated: [2]int{2, 4}, removed: [2]int{2, 6}, suggestion: "use \"remote-cert-tls server\" on clients or \"remote-cert-tls client\" on servers"},
+	{name: "tls-remote", deprecated: [2]int{2, 3}, removed: [2]int{2, 4}, suggestion: "use verify-x509-name"},
+	{name: "key-method", deprecated: [2]int{2, 4}, removed: [2]int{2, 5}, suggestion: "remove it; key method 2 is the only one supported"},
+	{name: "no-iv", deprecated: [2]int{2, 4}, removed: [2]int{2, 5}, suggestion: "remove it"},
+	{name: "no-replay", deprecated: [2]int{2, 4}, removed: [2]int{2, 7}, suggestion: "remove it and use an AEAD cipher"},
+	{name: "tun-ipv6", deprecated: [2]int{2, 4}, removed: [2]int{2, 5}, suggestion: "remove it; IPv6 is always enabled"},
+	{name: "max-routes", deprecated: [2]int{2, 4}, removed: [2]int{2, 5}, suggestion: "remove it; the route limit is dynamic"},
+	{name: "client-cert-not-required", deprecated: [2]int{2, 4}, removed: [2]int{2, 5}, suggestion: "use \"verify-client-cert none\""},
+	{name: "ifconfig-pool-linear", deprecated: [2]int{2, 4}, removed: [2]int{2, 5}, suggestion: "use \"topology p2p\""},
+	{name: "compat-names", deprecated: [2]int{2, 4}, removed: [2]int{2, 5}, suggestion: "remove it and update scripts to the current name format"},
+	{name: "no-name-remapping", deprecated: [2]int{2, 4}, removed: [2]int{2, 5}, suggestion: "remove it and update scripts to the current name format"},
+	{name: "mtu-dynamic", removed: [2]int{2, 4}, suggestion: "use fragment or mssfix"},
+	{name: "dhcp-release", deprecated: [2]int{2, 4}, removed: [2]int{2, 5}, suggestion: "remove it"},
+	{name: "ncp-ciphers", deprecated: [2]int{2, 5}, suggestion: "use data-ciphers"},
+	{name: "ncp-disable", deprecated: [2]int{2, 5}, removed: [2]int{2, 6}, suggestion: "use data-ciphers with a single cipher"},
+	{name: "keysize", deprecated: [2]int{2, 5}, removed: [2]int{2, 6}, suggestion: "use a cipher with a fixed key size"},
+	{name: "prng", deprecated: [2]int{2, 5}, removed: [2]int{2, 6}, suggestion: "remove it"},
+	{name: "secret", deprecated: [2]int{2, 6}, suggestion: "use TLS mode, or peer-fingerprint for simple point-to-point setups"},
+	{name: "opt-verify", deprecated: [2]int{2, 6}, suggestion: "remove it"},
+}
+
+// CheckDeprecated returns findings for the directives in c that are
+// deprecated in, or have been removed from, the given OpenVPN version,
+// such as from capability.Detect. Removed directives, which will prevent
+// OpenVPN from starting, are reported with SeverityError and the code
+// "removed-option", while deprecated ones are reported with
+// SeverityWarning and the code "deprecated-option". Each finding has
+// a Suggestion for a replacement.
+//
+// If version is zero, every known deprecation and removal is reported.
+func CheckDeprecated(c *Config, version capability.Version) []Finding {
+	var findings []Finding
+	for _, d := range c.Directives {
+		for _, dep := range deprecations {
+			if d.Name != dep.name || (dep.applies != nil && !dep.applies(d)) {
+				continue
+			}
+
+			f := Finding{Directive: d, Suggestion: dep.suggestion}
+			switch {
+			case dep.removed != [2]int{} && atLeast(version, dep.removed):
+				f.Severity = SeverityError
+				f.Code = "removed-option"
+				f.Message = fmt.Sprintf("%s was removed in OpenVPN %d.%d", d.Name, dep.removed[0], dep.removed[1])
+			case atLeast(version, dep.deprecated):
+				f.Severity = SeverityWarning
+				f.Code = "deprecated-option"
+				f.Message = fmt.Sprintf("%s is deprecated since OpenVPN %d.%d", d.Name, dep.deprecated[0], dep.deprecated[1])
+				if dep.deprecated == [2]int{} {
+					f.Message = d.Name + " is deprecated"
+				}
+			default:
+				continue
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// atLeast is like Version.AtLeast, except that the zero version is treated
+// as newer than any other.
+func atLeast(v capability.Version, mm [2]int) bool {
+	return v.IsZero() || v.AtLeast(mm[0], mm[1])
+}
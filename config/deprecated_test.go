@@ -0,0 +1,74 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/capability"
+)
+
+func TestCheckDeprecated(t *testing.T) {
+	const profile = "client\ncomp-lzo\ncompress stub-v2\nns-cert-type server\nncp-ciphers AES-256-GCM\ntls-remote server\n"
+
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{"2.3.18", []string{"tls-remote deprecated-option"}},
+		{"2.4.12", []string{"comp-lzo deprecated-option", "ns-cert-type deprecated-option", "tls-remote removed-option"}},
+		{"2.5.9", []string{"comp-lzo deprecated-option", "ns-cert-type deprecated-option", "ncp-ciphers deprecated-option", "tls-remote removed-option"}},
+		{"2.6.3", []string{"comp-lzo deprecated-option", "ns-cert-type removed-option", "ncp-ciphers deprecated-option", "tls-remote removed-option"}},
+		{"", []string{"comp-lzo deprecated-option", "ns-cert-type removed-option", "ncp-ciphers deprecated-option", "tls-remote removed-option"}},
+	}
+
+	c, err := Parse(strings.NewReader(profile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, test := range tests {
+		var v capability.Version
+		if test.version != "" {
+			if v, err = capability.ParseVersion(test.version); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var got []string
+		for _, f := range CheckDeprecated(c, v) {
+			if f.Suggestion == "" {
+				t.Errorf("test %d %s has no suggestion", i, f.Directive.Name)
+			}
+			got = append(got, f.Directive.Name+" "+f.Code)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestCheckDeprecatedMessages(t *testing.T) {
+	tests := []struct {
+		profile, version string
+		want             string
+	}{
+		{"ncp-ciphers AES-256-GCM\n", "2.5.9", "ncp-ciphers is deprecated since OpenVPN 2.5"},
+		{"mtu-dynamic\n", "2.3.18", "mtu-dynamic is deprecated"},
+		{"mtu-dynamic\n", "2.4.12", "mtu-dynamic was removed in OpenVPN 2.4"},
+	}
+
+	for i, test := range tests {
+		c, err := Parse(strings.NewReader(test.profile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := capability.ParseVersion(test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		findings := CheckDeprecated(c, v)
+		if len(findings) != 1 || findings[0].Message != test.want {
+			t.Errorf("test %d got %v; want %q", i, findings, test.want)
+		}
+	}
+}
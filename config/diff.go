@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+// Kinds of change reported by Diff.
+const (
+	Added ChangeKind = iota
+	Removed
+	Changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", int(k))
+	}
+}
+
+// Change is a difference between two configurations.
+type Change struct {
+	Kind ChangeKind
+	Name string
+
+	// Old is the directive in the original configuration, or nil if it was
+	// added. New is the directive in the new configuration, or nil if it
+	// was removed.
+	Old *Directive
+	New *Directive
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return "+ " + summary(c.New)
+	case Removed:
+		return "- " + summary(c.Old)
+	default:
+		return "~ " + summary(c.Old) + " -> " + summary(c.New)
+	}
+}
+
+// summary returns the directive as a single line, omitting the content of
+// inline blocks, which may be long or secret.
+func summary(d *Directive) string {
+	if d.Inline {
+		return "<" + d.Name + ">"
+	}
+	return d.String()
+}
+
+// Diff returns the changes required to turn configuration a into b. Comments
+// and the order of directives are ignored, except that when a directive
+// appears several times with different parameters, such as remote, the
+// occurrences that differ are paired up in order as changes.
+//
+// Changes are listed in the order that directive names first appear in a,
+// followed by those that appear only in b.
+func Diff(a, b *Config) []Change {
+	var names []string
+	seen := map[string]bool{}
+	for _, c := range []*Config{a, b} {
+		for _, d := range c.Directives {
+			if !seen[d.Name] {
+				seen[d.Name] = true
+				names = append(names, d.Name)
+			}
+		}
+	}
+
+	var changes []Change
+	for _, name := range names {
+		olds := unmatched(a.GetAll(name), b.GetAll(name))
+		news := unmatched(b.GetAll(name), a.GetAll(name))
+
+		i := 0
+		for ; i < len(olds) && i < len(news); i++ {
+			changes = append(changes, Change{Kind: Changed, Name: name, Old: olds[i], New: news[i]})
+		}
+		for _, d := range olds[i:] {
+			changes = append(changes, Change{Kind: Removed, Name: name, Old: d})
+		}
+		for _, d := range news[i:] {
+			changes = append(changes, Change{Kind: Added, Name: name, New: d})
+		}
+	}
+	return changes
+}
+
+// unmatched returns the directives in ds that have no equal counterpart in
+// others, matching each counterpart at most once.
+func unmatched(ds, others []*Directive) []*Directive {
+	used := make([]bool, len(others))
+	var ret []*Directive
+outer:
+	for _, d := range ds {
+		for i, other := range others {
+			if !used[i] && d.Equal(other) {
+				used[i] = true
+				continue outer
+			}
+		}
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+// Equal returns true if d and other have the same name, parameters and
+// inline content. Comments and line numbers are not compared.
+func (d *Directive) Equal(other *Directive) bool {
+	if d.Name != other.Name || d.Inline != other.Inline || d.Content != other.Content || len(d.Args) != len(other.Args) {
+		return false
+	}
+	for i := range d.Args {
+		if d.Args[i] != other.Args[i] {
+			return false
+		}
+	}
+	return true
+}
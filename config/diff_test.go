@@ -0,0 +1,37 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := Parse(strings.NewReader("client\n# comment\ndev tun\nremote a 1194\nremote b 1194\nverb 3\ncomp-lzo\n<ca>\nOLD\n</ca>\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Parse(strings.NewReader("client\ndev tun\nremote b 1194\nremote c 443\nverb 4\n<ca>\nNEW\n</ca>\nauth-nocache\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range Diff(a, b) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"~ remote a 1194 -> remote c 443",
+		"~ verb 3 -> verb 4",
+		"- comp-lzo",
+		"~ <ca> -> <ca>",
+		"+ auth-nocache",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	if changes := Diff(a, a); len(changes) != 0 {
+		t.Errorf("got changes %v comparing with itself; want none", changes)
+	}
+}
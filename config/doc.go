@@ -0,0 +1,8 @@
+// Package config reads, inspects and modifies OpenVPN configuration files,
+// such as the .ovpn profiles distributed to clients.
+//
+// A configuration is represented as a Config, which is an ordered list of
+// directives along with the comments attached to them. This is a faithful
+// model of the file rather than an interpretation of it, so directives
+// that this package knows nothing about are retained unchanged.
+package config
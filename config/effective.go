@@ -0,0 +1,323 @@
+package config
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// pushReplyPrefix introduces the options in the log message OpenVPN writes
+// on receiving them from the server.
+const pushReplyPrefix = "PUSH_REPLY,"
+
+// EffectiveConfig is the network configuration in force for a connected
+// client, combining its own configuration with the options pushed by the
+// server.
+type EffectiveConfig struct {
+	// Device is the name of the tunnel device, if known.
+	Device string
+
+	// MTU is the MTU of the tunnel device, or zero if not known.
+	MTU int
+
+	// Local and LocalIPv6 are the client's tunnel addresses, along with the
+	// length of the tunnel network prefix.
+	Local     netip.Prefix
+	LocalIPv6 netip.Prefix
+
+	// Remote is the server's tunnel address, in the net30 and p2p
+	// topologies.
+	Remote netip.Addr
+
+	// Gateway is the server's address as the gateway for routes through
+	// the tunnel, if known.
+	Gateway netip.Addr
+
+	// Routes are the networks routed through the tunnel, both IPv4 and
+	// IPv6.
+	Routes []netip.Prefix
+
+	// RedirectGateway is true if all traffic is directed through the tunnel,
+	// in which case RedirectGatewayFlags are the flags given to
+	// redirect-gateway, if known.
+	RedirectGateway      bool
+	RedirectGatewayFlags []string
+
+	DNS     []netip.Addr
+	Domains []string
+
+	// Pushed are the options pushed by the server, in the order received.
+	Pushed []string
+}
+
+// Effective computes the effective configuration from the client's own
+// configuration, the options pushed by the server, as returned by
+// PushedOptions, and the environment sent with the "up" UpDownEvent. Any
+// of these may be nil if not available.
+//
+// The environment reflects what OpenVPN actually configured, so the
+// corresponding information in it takes precedence over that determined
+// from the options. Pushed options are otherwise applied in the same way as
+// OpenVPN applies them, and are subject to any route-nopull and pull-filter
+// directives in the client's configuration.
+func Effective(static *Config, pushed []string, env openvpn.Env) *EffectiveConfig {
+	ec := &EffectiveConfig{Pushed: pushed}
+	if static == nil {
+		static = &Config{}
+	}
+
+	// topology affects the interpretation of ifconfig, so is found first.
+	topology := static.Value("topology")
+	for _, option := range pushed {
+		if fields, _ := splitLine(option); len(fields) > 1 && fields[0] == "topology" {
+			topology = fields[1]
+		}
+	}
+
+	for _, d := range static.Directives {
+		ec.apply(d, topology)
+	}
+	for _, option := range pushed {
+		fields, err := splitLine(option)
+		if err != nil || len(fields) == 0 || !acceptPushed(static, option, fields[0]) {
+			continue
+		}
+		ec.apply(&Directive{Name: fields[0], Args: fields[1:]}, topology)
+	}
+
+	if env != nil {
+		ec.applyEnv(env)
+	}
+	return ec
+}
+
+// apply updates the configuration according to a single directive.
+func (ec *EffectiveConfig) apply(d *Directive, topology string) {
+	switch d.Name {
+	case "dev":
+		ec.Device = d.Arg(0)
+	case "tun-mtu":
+		if mtu, err := d.Int(0); err == nil {
+			ec.MTU = mtu
+		}
+	case "ifconfig":
+		local, err := netip.ParseAddr(d.Arg(0))
+		if err != nil {
+			return
+		}
+		if topology == string(TopologySubnet) {
+			if bits, ok := maskBits(d.Arg(1)); ok {
+				ec.Local = netip.PrefixFrom(local, bits)
+			}
+		} else {
+			ec.Local = netip.PrefixFrom(local, 32)
+			ec.Remote, _ = netip.ParseAddr(d.Arg(1))
+		}
+	case "ifconfig-ipv6":
+		ec.LocalIPv6, _ = netip.ParsePrefix(d.Arg(0))
+	case "route-gateway":
+		if gw, err := netip.ParseAddr(d.Arg(0)); err == nil {
+			ec.Gateway = gw
+		}
+	case "route":
+		network, err := netip.ParseAddr(d.Arg(0))
+		if err != nil {
+			return
+		}
+		bits := 32
+		if len(d.Args) > 1 && d.Arg(1) != "default" {
+			var ok bool
+			if bits, ok = maskBits(d.Arg(1)); !ok {
+				return
+			}
+		}
+		ec.addRoute(netip.PrefixFrom(network, bits))
+	case "route-ipv6":
+		if network, err := netip.ParsePrefix(d.Arg(0)); err == nil {
+			ec.addRoute(network)
+		}
+	case "redirect-gateway":
+		ec.RedirectGateway = true
+		ec.RedirectGatewayFlags = d.Args
+	case "dhcp-option":
+		ec.applyDHCPOption(d.Arg(0), d.Arg(1))
+	}
+}
+
+func (ec *EffectiveConfig) applyDHCPOption(kind, value string) {
+	switch kind {
+	case "DNS", "DNS6":
+		if addr, err := netip.ParseAddr(value); err == nil {
+			ec.DNS = append(ec.DNS, addr)
+		}
+	case "DOMAIN", "DOMAIN-SEARCH":
+		ec.Domains = append(ec.Domains, value)
+	}
+}
+
+// applyEnv updates the configuration from the variables OpenVPN sets for
+// the up script.
+func (ec *EffectiveConfig) applyEnv(env openvpn.Env) {
+	if dev := env.Get("dev"); dev != "" {
+		ec.Device = dev
+	}
+	if mtu, err := strconv.Atoi(env.Get("tun_mtu")); err == nil {
+		ec.MTU = mtu
+	}
+
+	if local, err := netip.ParseAddr(env.Get("ifconfig_local")); err == nil {
+		if bits, ok := maskBits(env.Get("ifconfig_netmask")); ok {
+			ec.Local = netip.PrefixFrom(local, bits)
+		} else {
+			ec.Local = netip.PrefixFrom(local, 32)
+		}
+	}
+	if remote, err := netip.ParseAddr(env.Get("ifconfig_remote")); err == nil {
+		ec.Remote = remote
+	}
+	if local, err := netip.ParseAddr(env.Get("ifconfig_ipv6_local")); err == nil {
+		if bits, err := strconv.Atoi(env.Get("ifconfig_ipv6_netbits")); err == nil {
+			ec.LocalIPv6 = netip.PrefixFrom(local, bits)
+		}
+	}
+	if gw, err := netip.ParseAddr(env.Get("route_vpn_gateway")); err == nil {
+		ec.Gateway = gw
+	}
+
+	networks := env.Indexed("route_network_")
+	networks6 := env.Indexed("route_ipv6_network_")
+	if len(networks) > 0 || len(networks6) > 0 {
+		ec.Routes = nil
+		masks := env.Indexed("route_netmask_")
+		for i, network := range networks {
+			addr, err := netip.ParseAddr(network)
+			if err != nil || i >= len(masks) {
+				continue
+			}
+			if bits, ok := maskBits(masks[i]); ok {
+				ec.addRoute(netip.PrefixFrom(addr, bits))
+			}
+		}
+		for _, network := range networks6 {
+			if prefix, err := netip.ParsePrefix(network); err == nil {
+				ec.addRoute(prefix)
+			}
+		}
+	}
+
+	if env.Get("route_redirect_gateway_ipv4") != "" || env.Get("route_redirect_gateway_ipv6") != "" {
+		ec.RedirectGateway = true
+	}
+
+	if foreign := env.Indexed("foreign_option_"); len(foreign) > 0 {
+		ec.DNS = nil
+		ec.Domains = nil
+		for _, option := range foreign {
+			fields := strings.Fields(option)
+			if len(fields) == 3 && fields[0] == "dhcp-option" {
+				ec.applyDHCPOption(fields[1], fields[2])
+			}
+		}
+	}
+}
+
+func (ec *EffectiveConfig) addRoute(network netip.Prefix) {
+	network = network.Masked()
+	for _, existing := range ec.Routes {
+		if existing == network {
+			return
+		}
+	}
+	ec.Routes = append(ec.Routes, network)
+}
+
+// acceptPushed returns true if the client's configuration allows the given
+// pushed option, according to its route-nopull and pull-filter directives.
+func acceptPushed(static *Config, option, name string) bool {
+	if static.Has("route-nopull") && (name == "route" || name == "route-ipv6" || name == "dhcp-option" || name == "redirect-gateway") {
+		return false
+	}
+
+	// The first matching filter applies.
+	for _, d := range static.GetAll("pull-filter") {
+		if strings.HasPrefix(option, d.Arg(1)) {
+			return d.Arg(0) == "accept"
+		}
+	}
+	return true
+}
+
+// maskBits returns the prefix length of an IPv4 netmask.
+func maskBits(mask string) (int, bool) {
+	addr, err := netip.ParseAddr(mask)
+	if err != nil || !addr.Is4() {
+		return 0, false
+	}
+	b := addr.As4()
+	ones, bits := net.IPv4Mask(b[0], b[1], b[2], b[3]).Size()
+	return ones, bits != 0
+}
+
+// PushedOptions extracts the options pushed by the server from the log
+// message OpenVPN writes on receiving them, returning false if the log
+// event is not such a message. Log events must be enabled with
+// MgmtClient.SetLogEvents, at verbosity 3 or above, for these to be
+// received.
+func PushedOptions(e *openvpn.LogEvent) ([]string, bool) {
+	msg := e.Message()
+	idx := strings.Index(msg, pushReplyPrefix)
+	if idx == -1 {
+		return nil, false
+	}
+	reply := strings.TrimRight(msg[idx+len(pushReplyPrefix):], "'")
+	return strings.Split(reply, ","), true
+}
+
+// EffectiveTracker maintains the effective configuration of a client from
+// its management events.
+type EffectiveTracker struct {
+	static *Config
+
+	mu     sync.Mutex
+	pushed []string
+	env    openvpn.Env
+}
+
+// NewEffectiveTracker returns a tracker for a client with the given
+// configuration, which may be nil if it isn't known.
+func NewEffectiveTracker(static *Config) *EffectiveTracker {
+	return &EffectiveTracker{static: static}
+}
+
+// HandleEvent updates the tracker from a management event. The tracker
+// uses the log messages reporting pushed options and the "up"
+// UpDownEvent; other events are ignored.
+func (t *EffectiveTracker) HandleEvent(e openvpn.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch e := e.(type) {
+	case *openvpn.LogEvent:
+		if pushed, ok := PushedOptions(e); ok {
+			t.pushed = pushed
+		}
+	case *openvpn.UpDownEvent:
+		if e.Direction() == "UP" {
+			t.env = e.Env()
+		} else {
+			t.pushed = nil
+			t.env = nil
+		}
+	}
+}
+
+// Effective returns the current effective configuration.
+func (t *EffectiveTracker) Effective() *EffectiveConfig {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Effective(t.static, t.pushed, t.env)
+}
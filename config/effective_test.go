@@ -0,0 +1,113 @@
+package config
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestPushedOptions(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+		ok   bool
+	}{
+		{
+			"LOG:1689000000,,PUSH: Received control message: 'PUSH_REPLY,route-gateway 10.8.0.1,topology subnet,ifconfig 10.8.0.2 255.255.255.0'",
+			[]string{"route-gateway 10.8.0.1", "topology subnet", "ifconfig 10.8.0.2 255.255.255.0"},
+			true,
+		},
+		{"LOG:1689000000,I,Initialization Sequence Completed", nil, false},
+	}
+
+	for i, test := range tests {
+		e := openvpn.ParseEvent([]byte(test.raw)).(*openvpn.LogEvent)
+		got, ok := PushedOptions(e)
+		if ok != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %q, %v; want %q, %v", i, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestEffective(t *testing.T) {
+	static, err := Parse(strings.NewReader("client\ndev tun\nroute 192.168.0.0 255.255.0.0\ndhcp-option DNS 10.0.0.53\npull-filter ignore \"dhcp-option DOMAIN\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed := []string{
+		"route-gateway 10.8.0.1",
+		"topology subnet",
+		"route 10.10.0.0 255.255.0.0",
+		"route-ipv6 fd00:10::/64",
+		"redirect-gateway def1 bypass-dhcp",
+		"dhcp-option DNS 10.8.0.1",
+		"dhcp-option DOMAIN ignored.example.com",
+		"ifconfig 10.8.0.2 255.255.255.0",
+		"ifconfig-ipv6 fd00:8::2/64 fd00:8::1",
+		"tun-mtu 1400",
+	}
+
+	got := Effective(static, pushed, nil)
+	want := &EffectiveConfig{
+		Device:               "tun",
+		MTU:                  1400,
+		Local:                netip.MustParsePrefix("10.8.0.2/24"),
+		LocalIPv6:            netip.MustParsePrefix("fd00:8::2/64"),
+		Gateway:              netip.MustParseAddr("10.8.0.1"),
+		Routes:               []netip.Prefix{netip.MustParsePrefix("192.168.0.0/16"), netip.MustParsePrefix("10.10.0.0/16"), netip.MustParsePrefix("fd00:10::/64")},
+		RedirectGateway:      true,
+		RedirectGatewayFlags: []string{"def1", "bypass-dhcp"},
+		DNS:                  []netip.Addr{netip.MustParseAddr("10.0.0.53"), netip.MustParseAddr("10.8.0.1")},
+		Pushed:               pushed,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+
+	env := openvpn.Env{
+		"dev":                   "tun3",
+		"tun_mtu":               "1500",
+		"ifconfig_local":        "10.8.0.2",
+		"ifconfig_netmask":      "255.255.255.0",
+		"route_vpn_gateway":     "10.8.0.1",
+		"route_network_1":       "10.10.0.0",
+		"route_netmask_1":       "255.255.0.0",
+		"foreign_option_1":      "dhcp-option DNS 10.8.0.1",
+		"foreign_option_2":      "dhcp-option DOMAIN corp.example.com",
+		"ifconfig_ipv6_local":   "fd00:8::2",
+		"ifconfig_ipv6_netbits": "64",
+	}
+	got = Effective(static, pushed, env)
+	want.Device = "tun3"
+	want.MTU = 1500
+	want.Routes = []netip.Prefix{netip.MustParsePrefix("10.10.0.0/16")}
+	want.DNS = []netip.Addr{netip.MustParseAddr("10.8.0.1")}
+	want.Domains = []string{"corp.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with env got %+v; want %+v", got, want)
+	}
+}
+
+func TestEffectiveRouteNoPull(t *testing.T) {
+	static, err := Parse(strings.NewReader("client\nroute-nopull\nroute 192.168.0.0 255.255.0.0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Effective(static, []string{"route 10.10.0.0 255.255.0.0", "redirect-gateway def1", "topology net30", "ifconfig 10.8.0.6 10.8.0.5"}, nil)
+
+	if want := []netip.Prefix{netip.MustParsePrefix("192.168.0.0/16")}; !reflect.DeepEqual(got.Routes, want) {
+		t.Errorf("got routes %v; want %v", got.Routes, want)
+	}
+	if got.RedirectGateway {
+		t.Errorf("got redirect gateway; want none")
+	}
+	if want := netip.MustParsePrefix("10.8.0.6/32"); got.Local != want {
+		t.Errorf("got local %v; want %v", got.Local, want)
+	}
+	if want := netip.MustParseAddr("10.8.0.5"); got.Remote != want {
+		t.Errorf("got remote %v; want %v", got.Remote, want)
+	}
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inlineFileNames are the directives whose file parameter may instead be
+// given as an inline block, mapped to the file name used when extracting
+// the block to a file.
+var inlineFileNames = map[string]string{
+	"ca":             "ca.crt",
+	"cert":           "client.crt",
+	"key":            "client.key",
+	"extra-certs":    "extra-certs.crt",
+	"dh":             "dh.pem",
+	"tls-auth":       "ta.key",
+	"tls-crypt":      "tls-crypt.key",
+	"tls-crypt-v2":   "tls-crypt-v2.key",
+	"secret":         "static.key",
+	"crl-verify":     "crl.pem",
+	"auth-user-pass": "auth-user-pass.txt",
+}
+
+// CanInline returns true if the named directive accepts an inline block in
+// place of a file name.
+func CanInline(name string) bool {
+	_, ok := inlineFileNames[name]
+	return ok
+}
+
+// InlineFiles replaces each directive that refers to a file which could
+// instead be given inline, such as "ca ca.crt", with an inline block
+// containing the file's contents, producing a self-contained "unified"
+// profile. Relative file names are resolved against dir.
+//
+// The key direction parameter of tls-auth is moved to a key-direction
+// directive, since inline blocks cannot have parameters.
+func (c *Config) InlineFiles(dir string) error {
+	var directions []*Directive
+	for _, d := range c.Directives {
+		if d.Inline || !CanInline(d.Name) || !refersToFile(d) {
+			continue
+		}
+
+		path := d.Arg(0)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if d.Name == "tls-auth" && len(d.Args) > 1 && !c.Has("key-direction") {
+			directions = append(directions, &Directive{Name: "key-direction", Args: []string{d.Arg(1)}})
+		}
+		d.Inline = true
+		d.Content = string(content)
+		d.Args = nil
+	}
+
+	c.Directives = append(c.Directives, directions...)
+	return nil
+}
+
+// ExtractInline writes the content of each inline block that could instead
+// be given as a file to a file in dir, and replaces the block with a
+// reference to that file. It returns the paths of the files written, which
+// are created with mode 0600 since they typically contain private keys.
+//
+// Existing files are not overwritten, so dir would normally be a new
+// directory used only for this profile.
+func (c *Config) ExtractInline(dir string) ([]string, error) {
+	var written []string
+	used := map[string]int{}
+	for _, d := range c.Directives {
+		base, ok := inlineFileNames[d.Name]
+		if !d.Inline || !ok {
+			continue
+		}
+
+		// A directive such as extra-certs may be repeated, so number any
+		// subsequent files.
+		used[base]++
+		name := base
+		if n := used[base]; n > 1 {
+			ext := filepath.Ext(base)
+			name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(base, ext), n, ext)
+		}
+
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return written, err
+		}
+		_, err = f.WriteString(d.Content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return written, err
+		}
+		written = append(written, path)
+
+		d.Inline = false
+		d.Content = ""
+		d.Args = []string{path}
+	}
+	return written, nil
+}
+
+// refersToFile returns true if the directive's first parameter is a file
+// name, rather than one of the special values some directives accept.
+func refersToFile(d *Directive) bool {
+	switch path := d.Arg(0); {
+	case path == "":
+		return false
+	case d.Name == "dh" && path == "none":
+		return false
+	case d.Name == "crl-verify" && d.Arg(1) == "dir":
+		return false
+	case d.Name == "auth-user-pass" && path == "stdin":
+		return false
+	}
+	return true
+}
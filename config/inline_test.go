@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const unifiedProfile = `client
+dev tun
+remote vpn.example.com 1194
+key-direction 1
+<ca>
+-----BEGIN CERTIFICATE-----
+# not a comment
+-----END CERTIFICATE-----
+</ca>
+<tls-auth>
+-----BEGIN OpenVPN Static key V1-----
+-----END OpenVPN Static key V1-----
+</tls-auth>
+verb 3
+`
+
+func TestParseInline(t *testing.T) {
+	c, err := Parse(strings.NewReader(unifiedProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := c.Get("ca")
+	if ca == nil || !ca.Inline {
+		t.Fatalf("got ca %+v; want inline block", ca)
+	}
+	if want := "-----BEGIN CERTIFICATE-----\n# not a comment\n-----END CERTIFICATE-----\n"; ca.Content != want {
+		t.Errorf("got ca content %q; want %q", ca.Content, want)
+	}
+	if ca.Line != 5 {
+		t.Errorf("got ca line %d; want 5", ca.Line)
+	}
+	if d := c.Get("verb"); d == nil || d.Line != 14 {
+		t.Errorf("got verb %+v; want line 14", d)
+	}
+
+	if got := c.String(); got != unifiedProfile {
+		t.Errorf("round trip got %q; want %q", got, unifiedProfile)
+	}
+}
+
+func TestParseInlineErrors(t *testing.T) {
+	tests := []string{
+		"<ca>\n-----BEGIN CERTIFICATE-----\n",
+		"</ca>\n",
+		"<ca\n",
+	}
+
+	for i, test := range tests {
+		if _, err := Parse(strings.NewReader(test)); err == nil {
+			t.Errorf("test %d got no error", i)
+		}
+	}
+}
+
+func TestInlineFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ca.crt"), []byte("CA\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "ta.key"), []byte("TA\n"), 0o600)
+
+	c, err := Parse(strings.NewReader("ca ca.crt\ntls-auth ta.key 1\ndh none\nauth-user-pass\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InlineFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "<ca>\nCA\n</ca>\n<tls-auth>\nTA\n</tls-auth>\ndh none\nauth-user-pass\nkey-direction 1\n"
+	if got := c.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	extractDir := t.TempDir()
+	written, err := c.ExtractInline(extractDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 2 {
+		t.Fatalf("got %d files written; want 2", len(written))
+	}
+
+	caPath := filepath.Join(extractDir, "ca.crt")
+	if got := c.Value("ca"); got != caPath {
+		t.Errorf("got ca %q; want %q", got, caPath)
+	}
+	content, err := os.ReadFile(caPath)
+	if err != nil || string(content) != "CA\n" {
+		t.Errorf("got ca file %q, %v; want %q", content, err, "CA\n")
+	}
+	if info, err := os.Stat(caPath); err == nil && info.Mode().Perm() != 0o600 {
+		t.Errorf("got ca file mode %v; want 0600", info.Mode().Perm())
+	}
+
+	if _, err := c.ExtractInline(extractDir); err != nil {
+		t.Errorf("second extraction got %v; want nothing left to extract", err)
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity indicates how serious a Finding is.
+type Severity int
+
+// Severities of findings, in increasing order of seriousness.
+const (
+	// SeverityInfo findings are suggestions that don't affect the security
+	// or function of the tunnel.
+	SeverityInfo Severity = iota
+
+	// SeverityWarning findings are likely to weaken security or cause
+	// problems with some servers.
+	SeverityWarning
+
+	// SeverityError findings prevent the configuration from working.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Finding is a problem found in a configuration.
+type Finding struct {
+	Severity Severity
+
+	// Code is a short, stable identifier for the kind of problem, such as
+	// "missing-remote", suitable for filtering or translating findings.
+	Code string
+
+	// Directive is the directive the finding relates to, or nil if it
+	// relates to the configuration as a whole, for example because
+	// a directive is missing.
+	Directive *Directive
+
+	// Message is a human-readable description of the problem.
+	Message string
+
+	// Suggestion, if not empty, is a human-readable description of how to
+	// fix the problem.
+	Suggestion string
+}
+
+func (f Finding) String() string {
+	if f.Directive != nil && f.Directive.Line != 0 {
+		return fmt.Sprintf("line %d: %s: %s", f.Directive.Line, f.Severity, f.Message)
+	}
+	return fmt.Sprintf("%s: %s", f.Severity, f.Message)
+}
+
+// weakCiphers are data channel ciphers with 64-bit blocks or otherwise
+// known weaknesses, as lower-case prefixes.
+var weakCiphers = []string{"bf-", "des-", "des3", "rc2-", "rc4", "cast5-", "idea-", "seed-", "none"}
+
+// weakDigests are HMAC digests that should no longer be used for packet
+// authentication, in lower case.
+var weakDigests = map[string]bool{"md4": true, "md5": true, "none": true}
+
+// Lint checks the configuration for missing, contradictory or dangerous
+// directives, returning findings in the order the checks are made. Each
+// finding has a Suggestion for fixing it. It checks only the configuration
+// itself, not the files it refers to.
+func Lint(c *Config) []Finding {
+	l := &linter{}
+
+	client := c.Has("client") || (c.Has("tls-client") && c.Has("pull"))
+	server := c.Has("server") || c.Has("server-ipv6") || c.Has("server-bridge") || c.Has("mode")
+
+	if client && server {
+		l.suggest(SeverityError, "client-and-server", c.Get("client"), "configuration has both client and server directives",
+			"remove client, or the server directives")
+	}
+	if !c.Has("dev") {
+		l.suggest(SeverityError, "missing-dev", nil, "no dev directive specifying the tunnel device type", "add \"dev tun\"")
+	}
+	if client && !c.Has("remote") {
+		l.suggest(SeverityError, "missing-remote", nil, "client configuration has no remote directive", "add a remote directive giving the server's address")
+	}
+	if c.Has("tls-auth") && c.Has("tls-crypt") {
+		l.suggest(SeverityError, "tls-auth-and-tls-crypt", c.Get("tls-crypt"), "tls-auth and tls-crypt cannot be used together", "remove tls-auth")
+	}
+	if d := c.Get("explicit-exit-notify"); d != nil && strings.HasPrefix(c.Value("proto"), "tcp") {
+		l.suggest(SeverityError, "exit-notify-tcp", d, "explicit-exit-notify can only be used with UDP", "remove it")
+	}
+
+	if client && !c.Has("remote-cert-tls") && !c.Has("verify-x509-name") && !c.Has("peer-fingerprint") {
+		l.suggest(SeverityWarning, "no-server-verification", nil,
+			"server certificate is not verified with remote-cert-tls, verify-x509-name or peer-fingerprint, allowing other clients to impersonate the server",
+			"add \"remote-cert-tls server\"")
+	}
+	for _, d := range c.GetAll("comp-lzo") {
+		if d.Arg(0) != "no" {
+			l.suggest(SeverityWarning, "compression", d, "comp-lzo enables compression, which is vulnerable to VORACLE attacks and unsupported by modern servers",
+				"remove it, or use \"compress stub-v2\" if the server requires compression framing")
+		}
+	}
+	for _, d := range c.GetAll("compress") {
+		if alg := d.Arg(0); alg != "stub" && alg != "stub-v2" && alg != "migrate" {
+			l.suggest(SeverityWarning, "compression", d, "compress enables compression, which is vulnerable to VORACLE attacks",
+				"use \"compress stub-v2\" or remove it")
+		}
+	}
+	if d := c.Get("auth-user-pass"); d != nil && !c.Has("auth-nocache") {
+		l.suggest(SeverityWarning, "auth-cached", d, "auth-user-pass without auth-nocache keeps the password in memory for the life of the process", "add auth-nocache")
+	}
+	for _, name := range []string{"cipher", "data-ciphers", "ncp-ciphers", "data-ciphers-fallback"} {
+		for _, d := range c.GetAll(name) {
+			for _, cipher := range strings.Split(d.Arg(0), ":") {
+				if isWeakCipher(cipher) {
+					l.suggest(SeverityWarning, "weak-cipher", d, fmt.Sprintf("cipher %s is weak", cipher), "use an AEAD cipher such as AES-256-GCM")
+				}
+			}
+		}
+	}
+	if d := c.Get("auth"); d != nil && weakDigests[strings.ToLower(d.Arg(0))] {
+		l.suggest(SeverityWarning, "weak-digest", d, fmt.Sprintf("digest %s is weak", d.Arg(0)), "use \"auth SHA256\", or an AEAD cipher, which doesn't use it")
+	}
+	if d := c.Get("tls-version-min"); d != nil && (d.Arg(0) == "1.0" || d.Arg(0) == "1.1") {
+		l.suggest(SeverityWarning, "weak-tls-version", d, fmt.Sprintf("TLS %s is deprecated", d.Arg(0)), "use \"tls-version-min 1.2\"")
+	}
+	if d := c.Get("script-security"); d != nil && d.Arg(0) == "3" {
+		l.suggest(SeverityWarning, "script-security", d, "script-security 3 passes passwords to scripts in environment variables",
+			"use \"script-security 2\", and the via-file method of auth-user-pass-verify")
+	}
+	if client && !c.Has("nobind") && !c.Has("lport") && !c.Has("bind") {
+		l.suggest(SeverityInfo, "missing-nobind", nil, "client binds to a fixed local port, preventing multiple instances", "add nobind")
+	}
+
+	return l.findings
+}
+
+func isWeakCipher(cipher string) bool {
+	cipher = strings.ToLower(cipher)
+	for _, prefix := range weakCiphers {
+		if strings.HasPrefix(cipher, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// linter accumulates findings.
+type linter struct {
+	findings []Finding
+}
+
+func (l *linter) add(severity Severity, code string, d *Directive, msg string) {
+	l.suggest(severity, code, d, msg, "")
+}
+
+// suggest is like add, with a suggestion for fixing the problem.
+func (l *linter) suggest(severity Severity, code string, d *Directive, msg, suggestion string) {
+	l.findings = append(l.findings, Finding{
+		Severity:   severity,
+		Code:       code,
+		Directive:  d,
+		Message:    msg,
+		Suggestion: suggestion,
+	})
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	const good = "client\ndev tun\nremote vpn.example.com 1194\nnobind\nremote-cert-tls server\n"
+
+	tests := []struct {
+		config string
+		want   []string
+	}{
+		{good, nil},
+		{"client\ndev tun\nnobind\nremote-cert-tls server\n", []string{"missing-remote"}},
+		{"client\nremote a\nnobind\nremote-cert-tls server\n", []string{"missing-dev"}},
+		{"client\nserver 10.8.0.0 255.255.255.0\n" + good[len("client\n"):], []string{"client-and-server"}},
+		{good + "tls-auth ta.key 1\ntls-crypt tc.key\n", []string{"tls-auth-and-tls-crypt"}},
+		{good + "proto tcp-client\nexplicit-exit-notify\n", []string{"exit-notify-tcp"}},
+		{"client\ndev tun\nremote a\nnobind\n", []string{"no-server-verification"}},
+		{good + "comp-lzo\n", []string{"compression"}},
+		{good + "comp-lzo no\ncompress stub-v2\n", nil},
+		{good + "compress lz4-v2\n", []string{"compression"}},
+		{good + "auth-user-pass\n", []string{"auth-cached"}},
+		{good + "auth-user-pass\nauth-nocache\n", nil},
+		{good + "cipher BF-CBC\ndata-ciphers AES-256-GCM:DES-EDE3-CBC\n", []string{"weak-cipher", "weak-cipher"}},
+		{good + "data-ciphers AES-256-GCM:CHACHA20-POLY1305\n", nil},
+		{good + "auth MD5\n", []string{"weak-digest"}},
+		{good + "tls-version-min 1.0\n", []string{"weak-tls-version"}},
+		{good + "script-security 3\n", []string{"script-security"}},
+		{"client\ndev tun\nremote a\nremote-cert-tls server\n", []string{"missing-nobind"}},
+		{"server 10.8.0.0 255.255.255.0\ndev tun\n", nil},
+	}
+
+	for i, test := range tests {
+		c, err := Parse(strings.NewReader(test.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range Lint(c) {
+			if f.Suggestion == "" {
+				t.Errorf("test %d %s has no suggestion", i, f.Code)
+			}
+			got = append(got, f.Code)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxLineLength is the longest line that Parse accepts.
+const maxLineLength = 1 << 20
+
+// ParseError describes a syntax error in a configuration file.
+type ParseError struct {
+	// File is the name of the file being parsed, if known.
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// ParseFile parses the named configuration file.
+func ParseFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := Parse(f)
+	if perr, ok := err.(*ParseError); ok {
+		perr.File = path
+	}
+	return c, err
+}
+
+// Parse parses an OpenVPN configuration from r.
+//
+// The syntax is that accepted by OpenVPN itself: each line contains
+// a directive name followed by its parameters, separated by whitespace.
+// Parameters may be enclosed in double quotes, within which backslash
+// escapes are interpreted, or in single quotes, within which they are not.
+// A "#" or ";" at the start of a parameter begins a comment that runs to
+// the end of the line. A name may optionally be prefixed with "--", as on
+// the command line.
+//
+// Inline blocks, such as the certificate between "<ca>" and "</ca>", are
+// parsed as directives with Inline set.
+//
+// As an extension, a line ending in a backslash is continued on the next
+// line. This does not apply within inline blocks.
+func Parse(r io.Reader) (*Config, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLength)
+
+	c := &Config{}
+	var comments []string
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		startLine := lineNum
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if startLine == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		for continued(line) && scanner.Scan() {
+			lineNum++
+			line = line[:len(line)-1] + strings.TrimRight(scanner.Text(), "\r")
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case trimmed[0] == '#' || trimmed[0] == ';':
+			comments = append(comments, trimmed)
+			continue
+		case trimmed[0] == '<':
+			name, ok := inlineBlockName(trimmed)
+			if !ok {
+				return nil, &ParseError{Line: startLine, Msg: fmt.Sprintf("malformed inline block tag %s", trimmed)}
+			}
+			var content strings.Builder
+			closed := false
+			for scanner.Scan() {
+				lineNum++
+				contentLine := strings.TrimRight(scanner.Text(), "\r")
+				if strings.TrimSpace(contentLine) == "</"+name+">" {
+					closed = true
+					break
+				}
+				content.WriteString(contentLine)
+				content.WriteByte('\n')
+			}
+			if !closed {
+				if err := scanner.Err(); err != nil {
+					return nil, err
+				}
+				return nil, &ParseError{Line: startLine, Msg: fmt.Sprintf("inline block <%s> is not closed", name)}
+			}
+			c.Directives = append(c.Directives, &Directive{
+				Name:     name,
+				Comments: comments,
+				Line:     startLine,
+				Inline:   true,
+				Content:  content.String(),
+			})
+			comments = nil
+			continue
+		}
+
+		fields, err := splitLine(line)
+		if err != nil {
+			return nil, &ParseError{Line: startLine, Msg: err.Error()}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := fields[0]
+		if len(name) >= 3 && strings.HasPrefix(name, "--") {
+			name = name[2:]
+		}
+		var args []string
+		if len(fields) > 1 {
+			args = fields[1:]
+		}
+		c.Directives = append(c.Directives, &Directive{
+			Name:     name,
+			Args:     args,
+			Comments: comments,
+			Line:     startLine,
+		})
+		comments = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	c.Trailer = comments
+	return c, nil
+}
+
+// inlineBlockName returns the directive name from the opening tag of an
+// inline block, such as "<ca>".
+func inlineBlockName(tag string) (string, bool) {
+	if len(tag) < 3 || tag[len(tag)-1] != '>' || tag[1] == '/' {
+		return "", false
+	}
+	name := tag[1 : len(tag)-1]
+	if strings.ContainsAny(name, "<> \t") {
+		return "", false
+	}
+	return name, true
+}
+
+// continued returns true if line ends in an unescaped backslash.
+func continued(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// splitLine splits a configuration line into fields, following the rules
+// of OpenVPN's parse_line.
+func splitLine(line string) ([]string, error) {
+	const (
+		initial = iota
+		unquoted
+		doubleQuoted
+		singleQuoted
+	)
+
+	var fields []string
+	var field strings.Builder
+	state := initial
+	backslash := false
+	for _, r := range line {
+		if !backslash && r == '\\' && state != singleQuoted {
+			backslash = true
+			continue
+		}
+
+		switch state {
+		case initial:
+			switch {
+			case isSpace(r) && !backslash:
+			case (r == '#' || r == ';') && !backslash:
+				return fields, nil
+			case r == '"' && !backslash:
+				state = doubleQuoted
+			case r == '\'' && !backslash:
+				state = singleQuoted
+			default:
+				field.WriteRune(r)
+				state = unquoted
+			}
+		case unquoted:
+			if isSpace(r) && !backslash {
+				fields = append(fields, field.String())
+				field.Reset()
+				state = initial
+			} else {
+				field.WriteRune(r)
+			}
+		case doubleQuoted:
+			if r == '"' && !backslash {
+				fields = append(fields, field.String())
+				field.Reset()
+				state = initial
+			} else {
+				field.WriteRune(r)
+			}
+		case singleQuoted:
+			if r == '\'' {
+				fields = append(fields, field.String())
+				field.Reset()
+				state = initial
+			} else {
+				field.WriteRune(r)
+			}
+		}
+		backslash = false
+	}
+
+	switch state {
+	case unquoted:
+		fields = append(fields, field.String())
+	case doubleQuoted, singleQuoted:
+		return nil, fmt.Errorf("unterminated quoted parameter")
+	}
+	return fields, nil
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\v' || r == '\f'
+}
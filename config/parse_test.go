@@ -0,0 +1,129 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{"remote vpn.example.com 1194 udp", []string{"remote", "vpn.example.com", "1194", "udp"}, false},
+		{"  \tverb   3  ", []string{"verb", "3"}, false},
+		{"remote a 1194 # primary", []string{"remote", "a", "1194"}, false},
+		{"remote a 1194 ; primary", []string{"remote", "a", "1194"}, false},
+		{"setenv FOO bar#baz", []string{"setenv", "FOO", "bar#baz"}, false},
+		{`ca "C:\\Program Files\\OpenVPN\\ca.crt"`, []string{"ca", `C:\Program Files\OpenVPN\ca.crt`}, false},
+		{`ca 'C:\Program Files\OpenVPN\ca.crt'`, []string{"ca", `C:\Program Files\OpenVPN\ca.crt`}, false},
+		{`ca C:\\ca.crt`, []string{"ca", `C:\ca.crt`}, false},
+		{`setenv FOO "a \"quoted\" word"`, []string{"setenv", "FOO", `a "quoted" word`}, false},
+		{`setenv FOO a\ b`, []string{"setenv", "FOO", "a b"}, false},
+		{`setenv FOO \#notcomment`, []string{"setenv", "FOO", "#notcomment"}, false},
+		{`setenv FOO ""`, []string{"setenv", "FOO", ""}, false},
+		{`setenv FOO "unterminated`, nil, true},
+		{`# only a comment`, nil, false},
+	}
+
+	for i, test := range tests {
+		got, err := splitLine(test.line)
+		if (err != nil) != test.wantErr {
+			t.Errorf("test %d got error %v; want error %v", i, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	input := "\ufeff# Example profile\r\n" +
+		"; managed by provisioning\n" +
+		"client\n" +
+		"\n" +
+		"--dev tun\n" +
+		"remote vpn1.example.com 1194 udp\n" +
+		"# fallback\n" +
+		"remote vpn2.example.com \\\n" +
+		"    443 tcp\n" +
+		"verb 3\n" +
+		"# end\n"
+
+	c, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Config{
+		Directives: []*Directive{
+			{Name: "client", Comments: []string{"# Example profile", "; managed by provisioning"}, Line: 3},
+			{Name: "dev", Args: []string{"tun"}, Line: 5},
+			{Name: "remote", Args: []string{"vpn1.example.com", "1194", "udp"}, Line: 6},
+			{Name: "remote", Args: []string{"vpn2.example.com", "443", "tcp"}, Comments: []string{"# fallback"}, Line: 8},
+			{Name: "verb", Args: []string{"3"}, Line: 10},
+		},
+		Trailer: []string{"# end"},
+	}
+	for i := range want.Directives {
+		if i >= len(c.Directives) {
+			t.Fatalf("got %d directives; want %d", len(c.Directives), len(want.Directives))
+		}
+		if got := c.Directives[i]; !reflect.DeepEqual(got, want.Directives[i]) {
+			t.Errorf("directive %d got %+v; want %+v", i, got, want.Directives[i])
+		}
+	}
+	if len(c.Directives) != len(want.Directives) {
+		t.Errorf("got %d directives; want %d", len(c.Directives), len(want.Directives))
+	}
+	if !reflect.DeepEqual(c.Trailer, want.Trailer) {
+		t.Errorf("got trailer %q; want %q", c.Trailer, want.Trailer)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse(strings.NewReader("client\nsetenv FOO \"bar\n"))
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("got error %v; want *ParseError", err)
+	}
+	if perr.Line != 2 {
+		t.Errorf("got line %d; want 2", perr.Line)
+	}
+}
+
+func TestConfigEdit(t *testing.T) {
+	c, err := Parse(strings.NewReader("remote a 1194\nremote b 1194\nverb 3\nport 1194\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.Value("verb"); got != "3" {
+		t.Errorf("got verb %q; want %q", got, "3")
+	}
+	if port, err := c.Get("port").Int(0); err != nil || port != 1194 {
+		t.Errorf("got port %d, %v; want 1194", port, err)
+	}
+	if got := len(c.GetAll("remote")); got != 2 {
+		t.Errorf("got %d remotes; want 2", got)
+	}
+
+	c.Set("remote", "c", "443")
+	c.Set("verb", "4")
+	c.Set("nobind")
+	if n := c.Remove("port"); n != 1 {
+		t.Errorf("removed %d port directives; want 1", n)
+	}
+
+	var got []string
+	for _, d := range c.Directives {
+		got = append(got, strings.Join(append([]string{d.Name}, d.Args...), " "))
+	}
+	want := []string{"remote c 443", "verb 4", "nobind"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
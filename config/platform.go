@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// windowsOnly are directives that OpenVPN accepts only on Windows.
+var windowsOnly = map[string]bool{
+	"windows-driver":    true,
+	"block-outside-dns": true,
+	"register-dns":      true,
+	"ip-win32":          true,
+	"route-method":      true,
+	"tap-sleep":         true,
+	"win-sys":           true,
+	"dhcp-renew":        true,
+	"dhcp-pre-release":  true,
+	"dhcp-release":      true,
+	"show-net-up":       true,
+	"allow-nonadmin":    true,
+	"service":           true,
+}
+
+// unixOnly are directives that OpenVPN accepts only on Unix-like systems.
+var unixOnly = map[string]bool{
+	"user":   true,
+	"group":  true,
+	"chroot": true,
+	"daemon": true,
+	"syslog": true,
+	"mlock":  true,
+}
+
+// Adapt modifies the configuration to suit the given platform, identified
+// by its runtime.GOOS value, so that a single profile can be used
+// everywhere. It returns a finding for each change made, with
+// SeverityInfo and the code "platform-adapted", and for each problem that
+// it could not fix, with SeverityError and the code "platform-unsupported".
+//
+// Directives that OpenVPN rejects on the platform are removed. In
+// addition:
+//
+//   - On Windows, tap devices are given the tap-windows6 driver, which is the
+//     only one supporting them, and block-outside-dns is added when all
+//     traffic is redirected, to prevent DNS leaks through other interfaces.
+//   - On macOS, tun devices are changed to utun, since the tun kernel
+//     extension is no longer available.
+//   - On Linux, utun devices are changed to tun, and iproute is removed
+//     unless it names an absolute path.
+func Adapt(c *Config, goos string) []Finding {
+	var findings []Finding
+	changed := func(d *Directive, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Severity:  SeverityInfo,
+			Code:      "platform-adapted",
+			Directive: d,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	windows := goos == "windows"
+	c.filter(func(d *Directive) bool {
+		linux := goos == "linux" || goos == "android"
+		if (windows && unixOnly[d.Name]) || (!windows && windowsOnly[d.Name]) || (!linux && d.Name == "iproute") {
+			changed(d, "removed %s, which is not supported on %s", d.Name, goos)
+			return false
+		}
+		if d.Name == "dev-node" && windows != isWindowsDevNode(d.Arg(0)) {
+			changed(d, "removed dev-node %s, which is not a %s device", d.Arg(0), goos)
+			return false
+		}
+		return true
+	})
+
+	dev := c.Get("dev")
+	switch goos {
+	case "windows":
+		if dev != nil && strings.HasPrefix(dev.Arg(0), "tap") {
+			if driver := c.Get("windows-driver"); driver == nil || driver.Arg(0) != "tap-windows6" {
+				changed(c.Set("windows-driver", "tap-windows6"), "using the tap-windows6 driver, which is required for tap devices")
+			}
+		}
+		if c.Has("redirect-gateway") && !c.Has("block-outside-dns") {
+			changed(c.Add("block-outside-dns"), "added block-outside-dns to prevent DNS leaks")
+		}
+
+	case "darwin", "ios":
+		if dev != nil && strings.HasPrefix(dev.Arg(0), "tap") {
+			findings = append(findings, Finding{
+				Severity:  SeverityError,
+				Code:      "platform-unsupported",
+				Directive: dev,
+				Message:   "tap devices are not supported on " + goos,
+			})
+		} else if dev != nil && strings.HasPrefix(dev.Arg(0), "tun") {
+			old := dev.Arg(0)
+			dev.Args = []string{"u" + old}
+			changed(dev, "changed dev %s to %s", old, dev.Arg(0))
+		}
+
+	case "linux", "android":
+		if dev != nil && strings.HasPrefix(dev.Arg(0), "utun") {
+			old := dev.Arg(0)
+			dev.Args = []string{old[1:]}
+			changed(dev, "changed dev %s to %s", old, dev.Arg(0))
+		}
+		c.filter(func(d *Directive) bool {
+			if d.Name == "iproute" && !strings.HasPrefix(d.Arg(0), "/") {
+				changed(d, "removed iproute %s, which is not an absolute path", d.Arg(0))
+				return false
+			}
+			return true
+		})
+	}
+
+	return findings
+}
+
+// isWindowsDevNode returns true if name looks like a Windows adapter name
+// or GUID, rather than a device path.
+func isWindowsDevNode(name string) bool {
+	return !strings.HasPrefix(name, "/")
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAdapt(t *testing.T) {
+	const profile = "client\ndev tun\nuser nobody\ngroup nogroup\nblock-outside-dns\nwindows-driver wintun\ndev-node MyTap\niproute ip\nredirect-gateway def1\n"
+
+	tests := []struct {
+		goos    string
+		profile string
+		want    string
+		codes   int
+	}{
+		{"windows", profile, "client\ndev tun\nblock-outside-dns\nwindows-driver wintun\ndev-node MyTap\nredirect-gateway def1\n", 3},
+		{"windows", "dev tap\nwindows-driver wintun\nredirect-gateway def1\n", "dev tap\nwindows-driver tap-windows6\nredirect-gateway def1\nblock-outside-dns\n", 2},
+		{"darwin", profile, "client\ndev utun\nuser nobody\ngroup nogroup\nredirect-gateway def1\n", 5},
+		{"linux", profile, "client\ndev tun\nuser nobody\ngroup nogroup\nredirect-gateway def1\n", 4},
+		{"linux", "dev utun3\niproute /usr/sbin/ip\n", "dev tun3\niproute /usr/sbin/ip\n", 1},
+	}
+
+	for i, test := range tests {
+		c, err := Parse(strings.NewReader(test.profile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		findings := Adapt(c, test.goos)
+		if got := c.String(); got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+		if len(findings) != test.codes {
+			t.Errorf("test %d got %d findings %v; want %d", i, len(findings), findings, test.codes)
+		}
+	}
+
+	c, _ := Parse(strings.NewReader("dev tap\n"))
+	findings := Adapt(c, "darwin")
+	if len(findings) != 1 || findings[0].Code != "platform-unsupported" {
+		t.Errorf("tap on darwin got %v; want platform-unsupported", findings)
+	}
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ProxyAuth is an HTTP proxy authentication method.
+type ProxyAuth string
+
+// HTTP proxy authentication methods accepted by the http-proxy directive.
+const (
+	ProxyAuthNone  ProxyAuth = "none"
+	ProxyAuthBasic ProxyAuth = "basic"
+	ProxyAuthNTLM  ProxyAuth = "ntlm"
+)
+
+// ProxyCredentialsPrompt is the AuthFile value that makes OpenVPN request
+// proxy credentials interactively, over the management interface if it
+// is configured to query passwords.
+const ProxyCredentialsPrompt = "stdin"
+
+// HTTPProxy is an HTTP proxy to connect to the server through, as
+// configured by the http-proxy and http-proxy-option directives.
+type HTTPProxy struct {
+	Host string
+	Port int
+
+	// AuthFile is the file containing the username and password for the
+	// proxy, such as one written by WriteAuthUserPass, or
+	// ProxyCredentialsPrompt. It is empty if the proxy does not require
+	// authentication.
+	AuthFile string
+
+	// Auth is the authentication method. If empty, OpenVPN uses basic
+	// authentication when AuthFile is set.
+	Auth ProxyAuth
+
+	// AutoAuth makes OpenVPN determine the authentication method from
+	// the proxy's response, requesting credentials only if needed. In this
+	// case AuthFile and Auth are ignored. NoCleartext prevents automatic
+	// authentication from sending the password in the clear using basic
+	// authentication.
+	AutoAuth    bool
+	NoCleartext bool
+
+	// Version is the HTTP version to use, such as "1.1", or empty for
+	// OpenVPN's default of "1.0".
+	Version string
+
+	// Agent is the User-Agent to send, or empty for none.
+	Agent string
+
+	// Headers are additional headers to send, as name and value pairs.
+	Headers [][2]string
+}
+
+// SOCKSProxy is a SOCKS5 proxy to connect to the server through, as
+// configured by the socks-proxy directive.
+type SOCKSProxy struct {
+	Host string
+
+	// Port is the proxy's port, or zero for OpenVPN's default of 1080.
+	Port int
+
+	// AuthFile is as for HTTPProxy.AuthFile.
+	AuthFile string
+}
+
+// HTTPProxy returns the HTTP proxy configured in c, or nil if there is
+// none.
+func (c *Config) HTTPProxy() *HTTPProxy {
+	d := c.Get("http-proxy")
+	if d == nil {
+		return nil
+	}
+
+	p := &HTTPProxy{Host: d.Arg(0)}
+	p.Port, _ = strconv.Atoi(d.Arg(1))
+	switch arg := d.Arg(2); arg {
+	case "auto":
+		p.AutoAuth = true
+	case "auto-nct":
+		p.AutoAuth = true
+		p.NoCleartext = true
+	default:
+		p.AuthFile = arg
+		p.Auth = ProxyAuth(d.Arg(3))
+	}
+
+	for _, opt := range c.GetAll("http-proxy-option") {
+		switch opt.Arg(0) {
+		case "VERSION":
+			p.Version = opt.Arg(1)
+		case "AGENT":
+			p.Agent = opt.Arg(1)
+		case "CUSTOM-HEADER":
+			p.Headers = append(p.Headers, [2]string{opt.Arg(1), opt.Arg(2)})
+		}
+	}
+	return p
+}
+
+// SetHTTPProxy replaces the HTTP proxy configuration in c, removing it if
+// p is nil.
+func (c *Config) SetHTTPProxy(p *HTTPProxy) error {
+	if p != nil {
+		if err := p.validate(); err != nil {
+			return err
+		}
+	}
+
+	c.Remove("http-proxy")
+	c.Remove("http-proxy-option")
+	if p == nil {
+		return nil
+	}
+
+	c.Add("http-proxy", p.args()...)
+	if p.Version != "" {
+		c.Add("http-proxy-option", "VERSION", p.Version)
+	}
+	if p.Agent != "" {
+		c.Add("http-proxy-option", "AGENT", p.Agent)
+	}
+	for _, h := range p.Headers {
+		c.Add("http-proxy-option", "CUSTOM-HEADER", h[0], h[1])
+	}
+	return nil
+}
+
+// SOCKSProxy returns the SOCKS proxy configured in c, or nil if there is
+// none.
+func (c *Config) SOCKSProxy() *SOCKSProxy {
+	d := c.Get("socks-proxy")
+	if d == nil {
+		return nil
+	}
+	p := &SOCKSProxy{Host: d.Arg(0), AuthFile: d.Arg(2)}
+	p.Port, _ = strconv.Atoi(d.Arg(1))
+	return p
+}
+
+// SetSOCKSProxy replaces the SOCKS proxy configuration in c, removing it if
+// p is nil.
+func (c *Config) SetSOCKSProxy(p *SOCKSProxy) error {
+	if p != nil && (p.Host == "" || p.Port < 0 || p.Port > 65535) {
+		return fmt.Errorf("invalid SOCKS proxy %s:%d", p.Host, p.Port)
+	}
+
+	c.Remove("socks-proxy")
+	if p == nil {
+		return nil
+	}
+
+	port := p.Port
+	if port == 0 {
+		port = 1080
+	}
+	args := []string{p.Host, strconv.Itoa(port)}
+	if p.AuthFile != "" {
+		args = append(args, p.AuthFile)
+	}
+	c.Add("socks-proxy", args...)
+	return nil
+}
+
+func (p *HTTPProxy) validate() error {
+	if p.Host == "" || p.Port <= 0 || p.Port > 65535 {
+		return fmt.Errorf("invalid HTTP proxy %s:%d", p.Host, p.Port)
+	}
+	if !p.AutoAuth && p.Auth != "" && p.Auth != ProxyAuthNone && p.AuthFile == "" {
+		return errors.New("HTTP proxy authentication requires an auth file")
+	}
+	return nil
+}
+
+func (p *HTTPProxy) args() []string {
+	args := []string{p.Host, strconv.Itoa(p.Port)}
+	switch {
+	case p.AutoAuth && p.NoCleartext:
+		args = append(args, "auto-nct")
+	case p.AutoAuth:
+		args = append(args, "auto")
+	case p.AuthFile != "":
+		args = append(args, p.AuthFile)
+		if p.Auth != "" {
+			args = append(args, string(p.Auth))
+		}
+	}
+	return args
+}
+
+// prompts returns true if OpenVPN requests credentials for the proxy
+// interactively.
+func (p *HTTPProxy) prompts() bool {
+	return p.AutoAuth || p.AuthFile == ProxyCredentialsPrompt
+}
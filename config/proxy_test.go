@@ -0,0 +1,84 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPProxy(t *testing.T) {
+	tests := []struct {
+		proxy HTTPProxy
+		want  string
+	}{
+		{
+			HTTPProxy{Host: "proxy", Port: 8080},
+			"http-proxy proxy 8080\n",
+		},
+		{
+			HTTPProxy{Host: "proxy", Port: 8080, AuthFile: "/etc/openvpn/proxy.txt", Auth: ProxyAuthNTLM, Version: "1.1"},
+			"http-proxy proxy 8080 /etc/openvpn/proxy.txt ntlm\nhttp-proxy-option VERSION 1.1\n",
+		},
+		{
+			HTTPProxy{Host: "proxy", Port: 3128, AutoAuth: true, NoCleartext: true, Agent: "Example/1.0", Headers: [][2]string{{"X-Tenant", "acme corp"}}},
+			"http-proxy proxy 3128 auto-nct\nhttp-proxy-option AGENT Example/1.0\nhttp-proxy-option CUSTOM-HEADER X-Tenant \"acme corp\"\n",
+		},
+	}
+
+	for i, test := range tests {
+		c := &Config{}
+		c.Add("http-proxy", "old", "1")
+		if err := c.SetHTTPProxy(&test.proxy); err != nil {
+			t.Fatal(err)
+		}
+		if got := c.String(); got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+
+		reparsed, err := Parse(strings.NewReader(c.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := reparsed.HTTPProxy(); !reflect.DeepEqual(*got, test.proxy) {
+			t.Errorf("test %d round trip got %+v; want %+v", i, *got, test.proxy)
+		}
+	}
+
+	c := &Config{}
+	if err := c.SetHTTPProxy(&HTTPProxy{Host: "proxy", Port: 8080, Auth: ProxyAuthBasic}); err == nil {
+		t.Errorf("basic auth without auth file got no error")
+	}
+}
+
+func TestSOCKSProxy(t *testing.T) {
+	c := &Config{}
+	if err := c.SetSOCKSProxy(&SOCKSProxy{Host: "127.0.0.1", AuthFile: ProxyCredentialsPrompt}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.String(), "socks-proxy 127.0.0.1 1080 stdin\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if got, want := *c.SOCKSProxy(), (SOCKSProxy{Host: "127.0.0.1", Port: 1080, AuthFile: "stdin"}); got != want {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestClientProfileProxy(t *testing.T) {
+	c, err := NewClientProfile().Remote("vpn.example.com", 443).Proto(ProtoTCP).CA("CA\n").
+		HTTPProxy(HTTPProxy{Host: "proxy", Port: 8080, AutoAuth: true}).
+		Management("127.0.0.1:7505").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Get("http-proxy").String(); got != "http-proxy proxy 8080 auto" {
+		t.Errorf("got %q", got)
+	}
+	if !c.Has("management-query-passwords") {
+		t.Errorf("proxy credentials are not requested over management")
+	}
+
+	if _, err := NewClientProfile().Remote("vpn.example.com", 0).CA("CA\n").
+		HTTPProxy(HTTPProxy{Host: "proxy", Port: 8080}).Build(); err == nil {
+		t.Errorf("HTTP proxy over UDP got no error")
+	}
+}
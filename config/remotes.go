@@ -0,0 +1,402 @@
+package config
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// DefaultRetryAfter is how long a RemoteList avoids a remote after
+// a failure, when RemoteList.RetryAfter is zero.
+const DefaultRetryAfter = time.Minute
+
+// RemoteStatus is a remote in a RemoteList, along with the information
+// strategies use to choose between remotes.
+type RemoteStatus struct {
+	Remote
+
+	// Index is the remote's position in the list.
+	Index int
+
+	// Weight is the relative preference for the remote, used by
+	// WeightedStrategy. It defaults to 1.
+	Weight int
+
+	// Latency is the most recently measured latency to the remote, or
+	// zero if it has not been measured.
+	Latency time.Duration
+
+	// Failures is the number of consecutive failed attempts to connect to
+	// the remote, the most recent of which was at FailedAt.
+	Failures int
+	FailedAt time.Time
+}
+
+// Strategy chooses which remote a client should connect to next.
+type Strategy interface {
+	// Select returns the index within candidates of the remote to use.
+	// Candidates are given in list order, and there is always at least
+	// one.
+	Select(candidates []RemoteStatus) int
+}
+
+// StrategyFunc adapts a function to the Strategy interface.
+type StrategyFunc func(candidates []RemoteStatus) int
+
+// Select calls f(candidates).
+func (f StrategyFunc) Select(candidates []RemoteStatus) int {
+	return f(candidates)
+}
+
+// OrderedStrategy selects the first candidate, trying remotes in list order
+// as OpenVPN itself does.
+var OrderedStrategy Strategy = StrategyFunc(func([]RemoteStatus) int { return 0 })
+
+// RoundRobinStrategy returns a strategy that selects each remote in turn.
+func RoundRobinStrategy() Strategy {
+	last := -1
+	return StrategyFunc(func(candidates []RemoteStatus) int {
+		for i, c := range candidates {
+			if c.Index > last {
+				last = c.Index
+				return i
+			}
+		}
+		last = candidates[0].Index
+		return 0
+	})
+}
+
+// LowestLatencyStrategy selects the candidate with the lowest measured
+// latency, as set using RemoteList.SetLatency. Remotes with no measured
+// latency are selected only if none has been measured.
+var LowestLatencyStrategy Strategy = StrategyFunc(func(candidates []RemoteStatus) int {
+	best := 0
+	for i, c := range candidates {
+		if c.Latency != 0 && (candidates[best].Latency == 0 || c.Latency < candidates[best].Latency) {
+			best = i
+		}
+	}
+	return best
+})
+
+// WeightedStrategy selects a candidate at random, in proportion to its
+// weight.
+var WeightedStrategy Strategy = StrategyFunc(func(candidates []RemoteStatus) int {
+	total := 0
+	for _, c := range candidates {
+		total += c.Weight
+	}
+	if total <= 0 {
+		return 0
+	}
+	n := rand.Intn(total)
+	for i, c := range candidates {
+		if n < c.Weight {
+			return i
+		}
+		n -= c.Weight
+	}
+	return 0
+})
+
+// ClosestStrategy returns a strategy that selects the candidate with the
+// smallest distance, as reported by the given function. This is intended
+// for geographic selection, with distance calculated from the locations of
+// the client and of each remote.
+func ClosestStrategy(distance func(Remote) float64) Strategy {
+	return StrategyFunc(func(candidates []RemoteStatus) int {
+		best, bestDist := 0, distance(candidates[0].Remote)
+		for i, c := range candidates[1:] {
+			if d := distance(c.Remote); d < bestDist {
+				best, bestDist = i+1, d
+			}
+		}
+		return best
+	})
+}
+
+// RemoteList is an ordered list of remotes along with their connection
+// history, from which remotes are selected using a Strategy. It is safe
+// for concurrent use.
+type RemoteList struct {
+	// Strategy selects between the available remotes. If nil,
+	// OrderedStrategy is used.
+	Strategy Strategy
+
+	// RetryAfter is how long a remote is avoided after a failure, doubling
+	// with each consecutive failure. If zero, DefaultRetryAfter is used.
+	RetryAfter time.Duration
+
+	mu      sync.Mutex
+	remotes []RemoteStatus
+}
+
+// NewRemoteList returns a list containing the given remotes.
+func NewRemoteList(remotes ...Remote) *RemoteList {
+	l := &RemoteList{}
+	for _, r := range remotes {
+		l.Add(r)
+	}
+	return l
+}
+
+// RemotesFromConfig returns the remotes listed in a client configuration,
+// with the port and protocol defaulted from the port, rport and proto
+// directives as OpenVPN does.
+func RemotesFromConfig(c *Config) []Remote {
+	defaultPort := DefaultPort
+	for _, name := range []string{"port", "rport"} {
+		if d := c.Get(name); d != nil {
+			if port, err := d.Int(0); err == nil {
+				defaultPort = port
+			}
+		}
+	}
+	defaultProto := Proto(c.Value("proto"))
+
+	var ret []Remote
+	for _, d := range c.GetAll("remote") {
+		r := Remote{Host: d.Arg(0), Port: defaultPort, Proto: defaultProto}
+		if port, err := strconv.Atoi(d.Arg(1)); err == nil {
+			r.Port = port
+		}
+		if proto := d.Arg(2); proto != "" {
+			r.Proto = Proto(proto)
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+// Add appends a remote to the end of the list.
+func (l *RemoteList) Add(r Remote) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.remotes = append(l.remotes, RemoteStatus{Remote: r, Weight: 1})
+	l.reindex()
+}
+
+// Remove removes a remote from the list, returning false if it is not in
+// the list.
+func (l *RemoteList) Remove(r Remote) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := l.find(r)
+	if i == -1 {
+		return false
+	}
+	l.remotes = append(l.remotes[:i], l.remotes[i+1:]...)
+	l.reindex()
+	return true
+}
+
+// Remotes returns the current status of each remote, in list order.
+func (l *RemoteList) Remotes() []RemoteStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]RemoteStatus(nil), l.remotes...)
+}
+
+// MoveToFront moves a remote to the start of the list, returning false if
+// it is not in the list.
+func (l *RemoteList) MoveToFront(r Remote) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := l.find(r)
+	if i == -1 {
+		return false
+	}
+	moved := l.remotes[i]
+	copy(l.remotes[1:i+1], l.remotes[:i])
+	l.remotes[0] = moved
+	l.reindex()
+	return true
+}
+
+// SetWeight sets the weight of a remote, as used by WeightedStrategy.
+func (l *RemoteList) SetWeight(r Remote, weight int) bool {
+	return l.update(r, func(s *RemoteStatus) { s.Weight = weight })
+}
+
+// SetLatency records the measured latency to a remote, as used by
+// LowestLatencyStrategy.
+func (l *RemoteList) SetLatency(r Remote, latency time.Duration) bool {
+	return l.update(r, func(s *RemoteStatus) { s.Latency = latency })
+}
+
+// MarkFailed records a failed attempt to connect to a remote, causing it
+// to be avoided for a while.
+func (l *RemoteList) MarkFailed(r Remote) bool {
+	return l.update(r, func(s *RemoteStatus) {
+		s.Failures++
+		s.FailedAt = time.Now()
+	})
+}
+
+// MarkSucceeded records a successful connection to a remote, clearing its
+// failures.
+func (l *RemoteList) MarkSucceeded(r Remote) bool {
+	return l.update(r, func(s *RemoteStatus) {
+		s.Failures = 0
+		s.FailedAt = time.Time{}
+	})
+}
+
+// Select chooses a remote to connect to using the list's strategy, from
+// among those using the given transport protocol, which may be empty to
+// consider all remotes. Remotes that have failed recently are avoided,
+// unless all of them have. It returns false if there are no suitable
+// remotes.
+func (l *RemoteList) Select(proto Proto) (Remote, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var matching, available []RemoteStatus
+	now := time.Now()
+	for _, s := range l.remotes {
+		if proto != "" && s.Proto != "" && protoFamily(s.Proto) != protoFamily(proto) {
+			continue
+		}
+		matching = append(matching, s)
+		if s.Failures == 0 || now.Sub(s.FailedAt) >= l.backoff(s.Failures) {
+			available = append(available, s)
+		}
+	}
+	if len(available) == 0 {
+		available = matching
+	}
+	if len(available) == 0 {
+		return Remote{}, false
+	}
+
+	strategy := l.Strategy
+	if strategy == nil {
+		strategy = OrderedStrategy
+	}
+	i := strategy.Select(available)
+	if i < 0 || i >= len(available) {
+		i = 0
+	}
+	return available[i].Remote, true
+}
+
+// Apply replaces the remote directives in c with the remotes in the list,
+// in list order.
+func (l *RemoteList) Apply(c *Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c.Remove("remote")
+	for _, s := range l.remotes {
+		c.Add("remote", s.args()...)
+	}
+}
+
+func (l *RemoteList) backoff(failures int) time.Duration {
+	d := l.RetryAfter
+	if d <= 0 {
+		d = DefaultRetryAfter
+	}
+	for i := 1; i < failures && d < time.Hour; i++ {
+		d *= 2
+	}
+	return d
+}
+
+// update applies fn to the status of the given remote, returning false if
+// it is not in the list.
+func (l *RemoteList) update(r Remote, fn func(*RemoteStatus)) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := l.find(r)
+	if i == -1 {
+		return false
+	}
+	fn(&l.remotes[i])
+	return true
+}
+
+// find must be called with l.mu held.
+func (l *RemoteList) find(r Remote) int {
+	for i, s := range l.remotes {
+		if s.Remote == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// reindex must be called with l.mu held.
+func (l *RemoteList) reindex() {
+	for i := range l.remotes {
+		l.remotes[i].Index = i
+	}
+}
+
+// protoFamily returns "udp" or "tcp" for the given protocol.
+func protoFamily(p Proto) string {
+	if strings.HasPrefix(string(p), "tcp") {
+		return "tcp"
+	}
+	return "udp"
+}
+
+// RemoteResponder answers the RemoteEvents sent by an OpenVPN client
+// running with --management-query-remote, directing it to the remote
+// chosen by a RemoteList. It records a failure for a remote if OpenVPN
+// moves on to another one without connecting, and a success once it
+// connects, which requires state events to be enabled.
+//
+// A remote can be modified only to one using the same transport protocol
+// as OpenVPN offered, so remotes using other protocols are selected only
+// when OpenVPN reaches them in its own list.
+type RemoteResponder struct {
+	List   *RemoteList
+	Client *openvpn.MgmtClient
+
+	mu      sync.Mutex
+	pending *Remote
+}
+
+// HandleEvent responds to RemoteEvents and tracks StateEvents, ignoring
+// other events. It returns an error if the response could not be sent.
+func (r *RemoteResponder) HandleEvent(e openvpn.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch e := e.(type) {
+	case *openvpn.StateEvent:
+		if e.NewState() == "CONNECTED" && r.pending != nil {
+			r.List.MarkSucceeded(*r.pending)
+			r.pending = nil
+		}
+		return nil
+
+	case *openvpn.RemoteEvent:
+		if r.pending != nil {
+			r.List.MarkFailed(*r.pending)
+			r.pending = nil
+		}
+
+		selected, ok := r.List.Select(Proto(e.Proto()))
+		if !ok {
+			return r.Client.RemoteAccept()
+		}
+		r.pending = &selected
+
+		port := selected.Port
+		if port == 0 {
+			port = DefaultPort
+		}
+		if selected.Host == e.Host() && port == e.Port() {
+			return r.Client.RemoteAccept()
+		}
+		return r.Client.RemoteModify(selected.Host, port)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestRemotesFromConfig(t *testing.T) {
+	c, err := Parse(strings.NewReader("proto tcp-client\nport 443\nremote a\nremote b 1194\nremote c 1195 udp\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := RemotesFromConfig(c)
+	want := []Remote{
+		{Host: "a", Port: 443, Proto: ProtoTCP},
+		{Host: "b", Port: 1194, Proto: ProtoTCP},
+		{Host: "c", Port: 1195, Proto: ProtoUDP},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestRemoteListSelect(t *testing.T) {
+	a := Remote{Host: "a", Port: 1194, Proto: ProtoUDP}
+	b := Remote{Host: "b", Port: 1194, Proto: ProtoUDP}
+	c := Remote{Host: "c", Port: 443, Proto: ProtoTCP}
+
+	l := NewRemoteList(a, b, c)
+	selectHosts := func(n int, proto Proto) string {
+		var hosts []string
+		for i := 0; i < n; i++ {
+			r, _ := l.Select(proto)
+			hosts = append(hosts, r.Host)
+		}
+		return strings.Join(hosts, "")
+	}
+
+	tests := []struct {
+		setup func()
+		proto Proto
+		want  string
+	}{
+		{func() {}, "", "aaa"},
+		{func() {}, ProtoTCP, "ccc"},
+		{func() { l.MarkFailed(a) }, ProtoUDP, "bbb"},
+		{func() { l.MarkFailed(b) }, ProtoUDP, "aaa"},
+		{func() { l.MarkSucceeded(a); l.MarkSucceeded(b); l.MoveToFront(b) }, "", "bbb"},
+		{func() { l.Strategy = RoundRobinStrategy() }, "", "bacba"},
+		{func() { l.Strategy = LowestLatencyStrategy }, "", "bbb"},
+		{func() { l.SetLatency(c, 20*time.Millisecond); l.SetLatency(a, 30*time.Millisecond) }, "", "ccc"},
+		{func() { l.Strategy = WeightedStrategy; l.SetWeight(a, 0); l.SetWeight(b, 0) }, "", "ccc"},
+		{func() { l.Strategy = ClosestStrategy(func(r Remote) float64 { return float64(r.Host[0]) }) }, "", "aaa"},
+	}
+
+	for i, test := range tests {
+		test.setup()
+		n := len(test.want)
+		if got := selectHosts(n, test.proto); got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+
+	cfg := &Config{}
+	cfg.Add("remote", "old")
+	l.Apply(cfg)
+	if got, want := cfg.String(), "remote b 1194 udp\nremote a 1194 udp\nremote c 443 tcp-client\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestRemoteResponder(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	eventCh := make(chan openvpn.Event, 10)
+	client := openvpn.NewClient(clientConn, eventCh)
+	defer client.Close()
+
+	commands := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(serverConn)
+		for scanner.Scan() {
+			commands <- scanner.Text()
+			serverConn.Write([]byte("SUCCESS: remote command succeeded\n"))
+		}
+	}()
+
+	a := Remote{Host: "a", Port: 1194, Proto: ProtoUDP}
+	b := Remote{Host: "b", Port: 1195, Proto: ProtoUDP}
+	l := NewRemoteList(a, b)
+	l.MoveToFront(b)
+	r := &RemoteResponder{List: l, Client: client}
+
+	events := []string{
+		"REMOTE:a,1194,udp",
+		"REMOTE:b,1195,udp",
+		"STATE:1689000000,CONNECTED,SUCCESS,10.8.0.2,1.2.3.4",
+	}
+	want := []string{`remote MOD "b" 1195`, `remote MOD "a" 1194`, ""}
+	for i, raw := range events {
+		if err := r.HandleEvent(openvpn.ParseEvent([]byte(raw))); err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+		if want[i] == "" {
+			continue
+		}
+		if got := <-commands; got != want[i] {
+			t.Errorf("event %d sent %q; want %q", i, got, want[i])
+		}
+	}
+
+	// b failed, since OpenVPN moved on without connecting, while a
+	// connected successfully.
+	want2 := []int{1, 0}
+	for i, s := range l.Remotes() {
+		if s.Failures != want2[i] {
+			t.Errorf("remote %s has %d failures; want %d", s.Host, s.Failures, want2[i])
+		}
+	}
+}
@@ -0,0 +1,542 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProtoTCPServer is the transport protocol for servers accepting TCP
+// connections. Servers accepting UDP use ProtoUDP, ProtoUDP4 or ProtoUDP6.
+const ProtoTCPServer Proto = "tcp-server"
+
+// Topology is the way addresses are allocated to clients in the tunnel
+// network.
+type Topology string
+
+// Topologies accepted by the topology directive.
+const (
+	TopologySubnet Topology = "subnet"
+	TopologyNet30  Topology = "net30"
+	TopologyP2P    Topology = "p2p"
+)
+
+// Default keepalive settings used by ServerProfile.
+const (
+	DefaultKeepaliveInterval = 10 * time.Second
+	DefaultKeepaliveTimeout  = 2 * time.Minute
+)
+
+// ClientConfig is the server-side configuration for a single client,
+// identified by the common name of its certificate, as written to the
+// client-config-dir.
+type ClientConfig struct {
+	CommonName string
+
+	// Address and AddressIPv6, if valid, are static tunnel addresses for
+	// the client.
+	Address     netip.Addr
+	AddressIPv6 netip.Addr
+
+	// Networks are subnets behind the client that the server routes to
+	// it, for site-to-site setups.
+	Networks []netip.Prefix
+
+	// PushReset clears the options the server pushes to all clients, so
+	// that only the client's own Push options are sent.
+	PushReset bool
+
+	// Push are additional options pushed to this client, such as
+	// "route 10.2.0.0 255.255.0.0". They must not contain quotes or line
+	// breaks.
+	Push []string
+
+	// Disable prevents the client from connecting.
+	Disable bool
+}
+
+// ServerProfile builds a server configuration from typed inputs, in the
+// same way as ClientProfile builds a client configuration:
+//
+//	cfg, err := config.NewServerProfile().
+//		Network(netip.MustParsePrefix("10.8.0.0/24")).
+//		CA(caPEM).
+//		CertKey(certPEM, keyPEM).
+//		PushDNS(netip.MustParseAddr("10.8.0.1")).
+//		Build()
+//
+// Per-client settings are added with Client and written out with
+// WriteClientConfigDir.
+type ServerProfile struct {
+	network        netip.Prefix
+	networkIPv6    netip.Prefix
+	port           int
+	proto          Proto
+	dev            string
+	topology       Topology
+	ciphers        []string
+	credentials    []*Directive
+	push           []string
+	keepalive      [2]time.Duration
+	maxClients     int
+	clientToClient bool
+	ccdDir         string
+	clients        []ClientConfig
+	managementAddr string
+	managementAuth bool
+	extra          []*Directive
+	err            error
+}
+
+// NewServerProfile returns a new profile with the default settings.
+func NewServerProfile() *ServerProfile {
+	return &ServerProfile{
+		port:      DefaultPort,
+		proto:     ProtoUDP,
+		dev:       "tun",
+		topology:  TopologySubnet,
+		ciphers:   DefaultDataCiphers,
+		keepalive: [2]time.Duration{DefaultKeepaliveInterval, DefaultKeepaliveTimeout},
+	}
+}
+
+// Network sets the IPv4 tunnel network, from which the server takes the
+// first address and allocates the rest to clients.
+func (p *ServerProfile) Network(network netip.Prefix) *ServerProfile {
+	if !network.IsValid() || !network.Addr().Is4() {
+		p.setErr(fmt.Errorf("invalid IPv4 network %v", network))
+		return p
+	}
+	p.network = network.Masked()
+	return p
+}
+
+// NetworkIPv6 sets the IPv6 tunnel network, in addition to or instead of
+// the IPv4 one.
+func (p *ServerProfile) NetworkIPv6(network netip.Prefix) *ServerProfile {
+	if !network.IsValid() || !network.Addr().Is6() {
+		p.setErr(fmt.Errorf("invalid IPv6 network %v", network))
+		return p
+	}
+	p.networkIPv6 = network.Masked()
+	return p
+}
+
+// Port sets the port to listen on. The default is DefaultPort.
+func (p *ServerProfile) Port(port int) *ServerProfile {
+	if port <= 0 || port > 65535 {
+		p.setErr(fmt.Errorf("invalid port %d", port))
+		return p
+	}
+	p.port = port
+	return p
+}
+
+// Proto sets the transport protocol. The default is ProtoUDP.
+func (p *ServerProfile) Proto(proto Proto) *ServerProfile {
+	p.proto = proto
+	return p
+}
+
+// Device sets the tunnel device type, "tun" or "tap". The default is "tun".
+func (p *ServerProfile) Device(dev string) *ServerProfile {
+	p.dev = dev
+	return p
+}
+
+// Topology sets the tunnel topology. The default is TopologySubnet.
+func (p *ServerProfile) Topology(t Topology) *ServerProfile {
+	p.topology = t
+	return p
+}
+
+// Ciphers sets the data channel ciphers to negotiate, in order of
+// preference. The default is DefaultDataCiphers.
+func (p *ServerProfile) Ciphers(ciphers ...string) *ServerProfile {
+	if len(ciphers) == 0 {
+		p.setErr(errors.New("no data channel ciphers given"))
+		return p
+	}
+	p.ciphers = ciphers
+	return p
+}
+
+// CA sets the PEM-encoded certificate authority used to verify clients,
+// which is included in the configuration inline.
+func (p *ServerProfile) CA(pem string) *ServerProfile {
+	return p.credential(&Directive{Name: "ca", Inline: true, Content: pem})
+}
+
+// CAFile sets the file containing the certificate authority used to verify
+// clients.
+func (p *ServerProfile) CAFile(path string) *ServerProfile {
+	return p.credential(&Directive{Name: "ca", Args: []string{path}})
+}
+
+// CertKey sets the PEM-encoded server certificate and private key, which
+// are included in the configuration inline.
+func (p *ServerProfile) CertKey(certPEM, keyPEM string) *ServerProfile {
+	p.credential(&Directive{Name: "cert", Inline: true, Content: certPEM})
+	return p.credential(&Directive{Name: "key", Inline: true, Content: keyPEM})
+}
+
+// CertKeyFiles sets the files containing the server certificate and
+// private key.
+func (p *ServerProfile) CertKeyFiles(certPath, keyPath string) *ServerProfile {
+	p.credential(&Directive{Name: "cert", Args: []string{certPath}})
+	return p.credential(&Directive{Name: "key", Args: []string{keyPath}})
+}
+
+// TLSCrypt sets the pre-shared key used to encrypt and authenticate the
+// control channel, which is included in the configuration inline.
+func (p *ServerProfile) TLSCrypt(key string) *ServerProfile {
+	return p.credential(&Directive{Name: "tls-crypt", Inline: true, Content: key})
+}
+
+// Push adds an option to push to all clients, such as "route-gateway dhcp".
+// The option must not contain quotes or line breaks.
+func (p *ServerProfile) Push(option string) *ServerProfile {
+	if err := checkPushOption(option); err != nil {
+		p.setErr(err)
+		return p
+	}
+	p.push = append(p.push, option)
+	return p
+}
+
+// PushRoute pushes a route through the tunnel to all clients.
+func (p *ServerProfile) PushRoute(network netip.Prefix) *ServerProfile {
+	if !network.IsValid() {
+		p.setErr(fmt.Errorf("invalid route %v", network))
+		return p
+	}
+	return p.Push(routeOption(network.Masked()))
+}
+
+// PushRedirectGateway pushes redirect-gateway with the given flags, or
+// "def1" if none are given, directing all client traffic through the
+// tunnel.
+func (p *ServerProfile) PushRedirectGateway(flags ...string) *ServerProfile {
+	if len(flags) == 0 {
+		flags = []string{"def1"}
+	}
+	return p.Push("redirect-gateway " + strings.Join(flags, " "))
+}
+
+// PushDNS pushes DNS servers to all clients.
+func (p *ServerProfile) PushDNS(servers ...netip.Addr) *ServerProfile {
+	for _, server := range servers {
+		if server.Is4() {
+			p.Push("dhcp-option DNS " + server.String())
+		} else {
+			p.Push("dhcp-option DNS6 " + server.String())
+		}
+	}
+	return p
+}
+
+// Keepalive sets how often the server and clients ping each other, and how
+// long without a ping before the connection is considered dead. The
+// defaults are DefaultKeepaliveInterval and DefaultKeepaliveTimeout.
+func (p *ServerProfile) Keepalive(interval, timeout time.Duration) *ServerProfile {
+	if interval < time.Second || timeout < interval {
+		p.setErr(fmt.Errorf("invalid keepalive %v %v", interval, timeout))
+		return p
+	}
+	p.keepalive = [2]time.Duration{interval, timeout}
+	return p
+}
+
+// MaxClients limits the number of simultaneously connected clients.
+func (p *ServerProfile) MaxClients(n int) *ServerProfile {
+	p.maxClients = n
+	return p
+}
+
+// ClientToClient allows clients to reach each other through the tunnel.
+func (p *ServerProfile) ClientToClient() *ServerProfile {
+	p.clientToClient = true
+	return p
+}
+
+// ClientConfigDir sets the directory from which the server reads
+// per-client configuration, as written by WriteClientConfigDir.
+func (p *ServerProfile) ClientConfigDir(dir string) *ServerProfile {
+	p.ccdDir = dir
+	return p
+}
+
+// Client adds per-client configuration, written to the client-config-dir
+// by WriteClientConfigDir. Routes for the client's Networks are added to
+// the server configuration.
+func (p *ServerProfile) Client(cc ClientConfig) *ServerProfile {
+	if cc.CommonName == "" || cc.CommonName == "." || cc.CommonName == ".." || strings.ContainsAny(cc.CommonName, "/\\\r\n") {
+		p.setErr(fmt.Errorf("invalid common name %q", cc.CommonName))
+		return p
+	}
+	for _, option := range cc.Push {
+		if err := checkPushOption(option); err != nil {
+			p.setErr(fmt.Errorf("%s: %w", cc.CommonName, err))
+			return p
+		}
+	}
+	p.clients = append(p.clients, cc)
+	return p
+}
+
+// Management enables the management interface at the given address, using
+// the same conventions as openvpn.Dial.
+func (p *ServerProfile) Management(addr string) *ServerProfile {
+	if _, err := managementArgs(addr); err != nil {
+		p.setErr(err)
+		return p
+	}
+	p.managementAddr = addr
+	return p
+}
+
+// ManagementClientAuth makes the server defer client authentication to
+// the management client. It requires Management.
+func (p *ServerProfile) ManagementClientAuth() *ServerProfile {
+	p.managementAuth = true
+	return p
+}
+
+// Directive adds an arbitrary directive to the end of the configuration,
+// for settings not covered by the other methods.
+func (p *ServerProfile) Directive(name string, args ...string) *ServerProfile {
+	for _, s := range append([]string{name}, args...) {
+		if err := checkLine(name+" directive", s); err != nil {
+			p.setErr(err)
+			return p
+		}
+	}
+	p.extra = append(p.extra, &Directive{Name: name, Args: args})
+	return p
+}
+
+// Build returns the server configuration described by the profile, or the
+// first error from the preceding calls. It is an error for the profile to
+// have no tunnel network, to lack a certificate authority, certificate or
+// key, or for any parameter to contain a line break.
+func (p *ServerProfile) Build() (*Config, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if !p.network.IsValid() && !p.networkIPv6.IsValid() {
+		return nil, errors.New("server profile has no tunnel network")
+	}
+	for _, name := range []string{"ca", "cert", "key"} {
+		if !hasDirective(p.credentials, name) {
+			return nil, fmt.Errorf("server profile has no %s", name)
+		}
+	}
+	if p.managementAuth && p.managementAddr == "" {
+		return nil, errors.New("management client auth requires a management interface")
+	}
+	if len(p.clients) > 0 && p.ccdDir == "" {
+		return nil, errors.New("per-client configuration requires a client config dir")
+	}
+
+	c := &Config{}
+	c.Add("port", strconv.Itoa(p.port))
+	c.Add("proto", string(p.proto))
+	c.Add("dev", p.dev)
+	c.Add("topology", string(p.topology))
+	if p.network.IsValid() {
+		c.Add("server", p.network.Addr().String(), netmask(p.network).String())
+	}
+	if p.networkIPv6.IsValid() {
+		c.Add("server-ipv6", p.networkIPv6.String())
+	}
+	c.Add("keepalive", seconds(p.keepalive[0]), seconds(p.keepalive[1]))
+	if p.maxClients > 0 {
+		c.Add("max-clients", strconv.Itoa(p.maxClients))
+	}
+	if p.clientToClient {
+		c.Add("client-to-client")
+	}
+	c.Add("persist-key")
+	c.Add("persist-tun")
+
+	c.Add("dh", "none")
+	c.Add("tls-version-min", "1.2")
+	c.Add("data-ciphers", strings.Join(p.ciphers, ":"))
+	if strings.HasPrefix(string(p.proto), "udp") {
+		c.Add("explicit-exit-notify", "1")
+	}
+
+	for _, option := range p.push {
+		c.Add("push", option)
+	}
+	if p.ccdDir != "" {
+		c.Add("client-config-dir", p.ccdDir)
+	}
+	for _, cc := range p.clients {
+		for _, network := range cc.Networks {
+			d := routeDirective(network.Masked())
+			c.Add(d.Name, d.Args...)
+		}
+	}
+
+	if p.managementAddr != "" {
+		args, _ := managementArgs(p.managementAddr)
+		c.Add("management", args...)
+		if p.managementAuth {
+			c.Add("management-client-auth")
+		}
+	}
+
+	c.Add("verb", "3")
+	c.Directives = append(c.Directives, p.extra...)
+	c.Directives = append(c.Directives, p.credentials...)
+	for _, d := range c.Directives {
+		if err := d.checkArgs(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// ClientConfig returns the client-config-dir file for the given client. It
+// fails if any of the client's Push options contains a quote or line break.
+func (p *ServerProfile) ClientConfig(cc ClientConfig) (*Config, error) {
+	for _, option := range cc.Push {
+		if err := checkPushOption(option); err != nil {
+			return nil, fmt.Errorf("%s: %w", cc.CommonName, err)
+		}
+	}
+
+	c := &Config{}
+	if cc.Disable {
+		c.Add("disable")
+		return c, nil
+	}
+	if cc.PushReset {
+		c.Add("push-reset")
+	}
+
+	if cc.Address.IsValid() {
+		if !p.network.Contains(cc.Address) {
+			return nil, fmt.Errorf("%s: address %v is not within %v", cc.CommonName, cc.Address, p.network)
+		}
+		if p.topology == TopologySubnet {
+			c.Add("ifconfig-push", cc.Address.String(), netmask(p.network).String())
+		} else {
+			c.Add("ifconfig-push", cc.Address.String(), cc.Address.Next().String())
+		}
+	}
+	if cc.AddressIPv6.IsValid() {
+		if !p.networkIPv6.Contains(cc.AddressIPv6) {
+			return nil, fmt.Errorf("%s: address %v is not within %v", cc.CommonName, cc.AddressIPv6, p.networkIPv6)
+		}
+		local := netip.PrefixFrom(cc.AddressIPv6, p.networkIPv6.Bits())
+		c.Add("ifconfig-ipv6-push", local.String(), p.networkIPv6.Addr().Next().String())
+	}
+
+	for _, network := range cc.Networks {
+		network = network.Masked()
+		if network.Addr().Is4() {
+			c.Add("iroute", network.Addr().String(), netmask(network).String())
+		} else {
+			c.Add("iroute-ipv6", network.String())
+		}
+	}
+	for _, option := range cc.Push {
+		c.Add("push", option)
+	}
+	return c, nil
+}
+
+// WriteClientConfigDir writes a file to the client-config-dir for each of
+// the clients added with Client, named after the client's common name.
+// The directory is created if necessary.
+func (p *ServerProfile) WriteClientConfigDir() error {
+	if p.ccdDir == "" {
+		return errors.New("server profile has no client config dir")
+	}
+	if err := os.MkdirAll(p.ccdDir, 0o755); err != nil {
+		return err
+	}
+
+	for _, cc := range p.clients {
+		c, err := p.ClientConfig(cc)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(p.ccdDir, cc.CommonName)
+		if err := os.WriteFile(path, []byte(c.String()), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ServerProfile) credential(d *Directive) *ServerProfile {
+	for i, existing := range p.credentials {
+		if existing.Name == d.Name {
+			p.credentials[i] = d
+			return p
+		}
+	}
+	p.credentials = append(p.credentials, d)
+	return p
+}
+
+func (p *ServerProfile) setErr(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// checkPushOption returns an error if the given option to push contains a
+// quote or line break, which the client would parse as the end of the
+// option or the start of another.
+func checkPushOption(option string) error {
+	if err := checkLine("pushed option", option); err != nil {
+		return err
+	}
+	if strings.ContainsRune(option, '"') {
+		return fmt.Errorf("pushed option %q contains a quote", option)
+	}
+	return nil
+}
+
+func hasDirective(ds []*Directive, name string) bool {
+	for _, d := range ds {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// routeDirective returns the route or route-ipv6 directive for the given
+// network.
+func routeDirective(network netip.Prefix) *Directive {
+	if network.Addr().Is4() {
+		return &Directive{Name: "route", Args: []string{network.Addr().String(), netmask(network).String()}}
+	}
+	return &Directive{Name: "route-ipv6", Args: []string{network.String()}}
+}
+
+// routeOption returns the route or route-ipv6 directive for the given
+// network as a single string, for pushing.
+func routeOption(network netip.Prefix) string {
+	d := routeDirective(network)
+	return d.Name + " " + strings.Join(d.Args, " ")
+}
+
+// netmask returns the netmask of an IPv4 network.
+func netmask(network netip.Prefix) netip.Addr {
+	return netip.AddrFrom4(prefixMask4(network.Bits()))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(d / time.Second))
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerProfile(t *testing.T) {
+	ccd := filepath.Join(t.TempDir(), "ccd")
+	p := NewServerProfile().
+		Network(netip.MustParsePrefix("10.8.0.0/24")).
+		NetworkIPv6(netip.MustParsePrefix("fd00:8::/64")).
+		CAFile("/etc/openvpn/ca.crt").
+		CertKeyFiles("/etc/openvpn/server.crt", "/etc/openvpn/server.key").
+		PushRoute(netip.MustParsePrefix("192.168.1.0/24")).
+		PushDNS(netip.MustParseAddr("10.8.0.1")).
+		ClientConfigDir(ccd).
+		Client(ClientConfig{
+			CommonName:  "site-a",
+			Address:     netip.MustParseAddr("10.8.0.10"),
+			AddressIPv6: netip.MustParseAddr("fd00:8::10"),
+			Networks:    []netip.Prefix{netip.MustParsePrefix("192.168.50.0/24")},
+			Push:        []string{"route 192.168.60.0 255.255.255.0"},
+		}).
+		Client(ClientConfig{CommonName: "revoked", Disable: true}).
+		Management("/run/openvpn/server.sock").
+		ManagementClientAuth()
+
+	c, err := p.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `port 1194
+proto udp
+dev tun
+topology subnet
+server 10.8.0.0 255.255.255.0
+server-ipv6 fd00:8::/64
+keepalive 10 120
+persist-key
+persist-tun
+dh none
+tls-version-min 1.2
+data-ciphers AES-256-GCM:AES-128-GCM:CHACHA20-POLY1305
+explicit-exit-notify 1
+push "route 192.168.1.0 255.255.255.0"
+push "dhcp-option DNS 10.8.0.1"
+client-config-dir ` + ccd + `
+route 192.168.50.0 255.255.255.0
+management /run/openvpn/server.sock unix
+management-client-auth
+verb 3
+ca /etc/openvpn/ca.crt
+cert /etc/openvpn/server.crt
+key /etc/openvpn/server.key
+`
+	if got := c.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := p.WriteClientConfigDir(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"site-a", "ifconfig-push 10.8.0.10 255.255.255.0\nifconfig-ipv6-push fd00:8::10/64 fd00:8::1\niroute 192.168.50.0 255.255.255.0\npush \"route 192.168.60.0 255.255.255.0\"\n"},
+		{"revoked", "disable\n"},
+	}
+	for i, test := range tests {
+		got, err := os.ReadFile(filepath.Join(ccd, test.name))
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestServerProfileErrors(t *testing.T) {
+	network := netip.MustParsePrefix("10.8.0.0/24")
+	tests := []*ServerProfile{
+		NewServerProfile().CAFile("ca").CertKeyFiles("c", "k"),
+		NewServerProfile().Network(network).CertKeyFiles("c", "k"),
+		NewServerProfile().Network(netip.MustParsePrefix("fd00::/64")).CAFile("ca").CertKeyFiles("c", "k"),
+		NewServerProfile().Network(network).CAFile("ca").CertKeyFiles("c", "k").ManagementClientAuth(),
+		NewServerProfile().Network(network).CAFile("ca").CertKeyFiles("c", "k").Client(ClientConfig{CommonName: "a"}),
+		NewServerProfile().Network(network).CAFile("ca").CertKeyFiles("c", "k").ClientConfigDir("ccd").Client(ClientConfig{CommonName: "../a"}),
+		NewServerProfile().Network(network).CAFile("ca").CertKeyFiles("c", "k").Push("route-gateway dhcp\nscript-security 2"),
+		NewServerProfile().Network(network).CAFile("ca").CertKeyFiles("c", "k").Push(`setenv FOO "a b"`),
+		NewServerProfile().Network(network).CAFile("ca").CertKeyFiles("c", "k").ClientConfigDir("ccd").Client(ClientConfig{CommonName: "a", Push: []string{"route 10.2.0.0 255.255.0.0\r\nup /tmp/evil.sh"}}),
+		NewServerProfile().Network(network).CAFile("ca").CertKeyFiles("c", "k").ClientConfigDir("ccd").Client(ClientConfig{CommonName: "a\nb"}),
+		NewServerProfile().Network(network).CAFile("ca").CertKeyFiles("c", "k").Directive("setenv", "FOO", "a\nup /tmp/evil.sh"),
+	}
+
+	for i, test := range tests {
+		if _, err := test.Build(); err == nil {
+			t.Errorf("test %d got no error", i)
+		}
+	}
+
+	p := NewServerProfile().Network(network)
+	if _, err := p.ClientConfig(ClientConfig{CommonName: "a", Address: netip.MustParseAddr("10.9.0.1")}); err == nil {
+		t.Errorf("address outside network got no error")
+	}
+	if _, err := p.ClientConfig(ClientConfig{CommonName: "a", Push: []string{`dhcp-option DOMAIN "x"`}}); err == nil {
+		t.Errorf("pushed option with a quote got no error")
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTo writes the configuration to w in OpenVPN syntax, one directive
+// per line in the order they appear in c.Directives, each preceded by its
+// comments. Parameters are quoted only where necessary, so the output of
+// parsing and then writing a typical hand-written file differs from the
+// original only in whitespace and in the removal of trailing comments.
+//
+// OpenVPN directives can't span lines, so WriteTo returns an error without
+// writing anything if a parameter contains a line break.
+func (c *Config) WriteTo(w io.Writer) (int64, error) {
+	for _, d := range c.Directives {
+		if err := d.checkArgs(); err != nil {
+			return 0, err
+		}
+	}
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, d := range c.Directives {
+		for _, comment := range d.Comments {
+			cw.writeLine(comment)
+		}
+		cw.writeLine(d.String())
+	}
+	for _, comment := range c.Trailer {
+		cw.writeLine(comment)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// String returns the configuration in OpenVPN syntax, as written by
+// WriteTo, or the empty string if WriteTo would fail.
+func (c *Config) String() string {
+	var b strings.Builder
+	c.WriteTo(&b)
+	return b.String()
+}
+
+// String returns the directive in OpenVPN syntax, without its comments or
+// a trailing newline. This is a single line, except for inline blocks.
+func (d *Directive) String() string {
+	var b strings.Builder
+	if d.Inline {
+		b.WriteString("<" + d.Name + ">\n")
+		b.WriteString(d.Content)
+		if d.Content != "" && !strings.HasSuffix(d.Content, "\n") {
+			b.WriteByte('\n')
+		}
+		b.WriteString("</" + d.Name + ">")
+		return b.String()
+	}
+
+	b.WriteString(d.Name)
+	for _, arg := range d.Args {
+		b.WriteByte(' ')
+		b.WriteString(Quote(arg))
+	}
+	return b.String()
+}
+
+// checkArgs returns an error if any of the directive's parameters contains
+// a line break, which would end the directive early.
+func (d *Directive) checkArgs() error {
+	if d.Inline {
+		return nil
+	}
+	for _, arg := range d.Args {
+		if strings.ContainsAny(arg, "\r\n") {
+			return fmt.Errorf("parameter %q of %s contains a line break", arg, d.Name)
+		}
+	}
+	return nil
+}
+
+// Quote returns arg in a form that OpenVPN parses as a single parameter
+// with the value arg, adding double quotes and backslash escapes only if
+// they are needed. No quoting allows a parameter to contain a line break.
+func Quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\r\v\f\"'\\") && arg[0] != '#' && arg[0] != ';' {
+		return arg
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range arg {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// countingWriter writes lines to a buffered writer, keeping track of the
+// number of bytes written and the first error encountered.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) writeLine(line string) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.WriteString(line + "\n")
+	cw.n += int64(n)
+	cw.err = err
+}
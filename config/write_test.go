@@ -0,0 +1,87 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"vpn.example.com", "vpn.example.com"},
+		{"", `""`},
+		{"a b", `"a b"`},
+		{`C:\ca.crt`, `"C:\\ca.crt"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"it's", `"it's"`},
+		{"#hash", `"#hash"`},
+		{"a#b", "a#b"},
+	}
+
+	for i, test := range tests {
+		got := Quote(test.arg)
+		if got != test.want {
+			t.Errorf("test %d got %s; want %s", i, got, test.want)
+		}
+		fields, err := splitLine("x " + got)
+		if err != nil || len(fields) != 2 || fields[1] != test.arg {
+			t.Errorf("test %d %s parses as %q, %v; want %q", i, got, fields, err, test.arg)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	input := "# Example profile\n" +
+		"client\n" +
+		"--dev   tun\n" +
+		"remote vpn.example.com 1194 udp # primary\n" +
+		"ca 'C:\\Program Files\\OpenVPN\\ca.crt'\n" +
+		"setenv FOO \"\"\n" +
+		"# end\n"
+	want := "# Example profile\n" +
+		"client\n" +
+		"dev tun\n" +
+		"remote vpn.example.com 1194 udp\n" +
+		"ca \"C:\\\\Program Files\\\\OpenVPN\\\\ca.crt\"\n" +
+		"setenv FOO \"\"\n" +
+		"# end\n"
+
+	c, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.String()
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	reparsed, err := Parse(strings.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reparsed.String() != got {
+		t.Errorf("second round trip got %q; want %q", reparsed.String(), got)
+	}
+	for i, d := range reparsed.Directives {
+		if !reflect.DeepEqual(d.Args, c.Directives[i].Args) {
+			t.Errorf("directive %d got args %q; want %q", i, d.Args, c.Directives[i].Args)
+		}
+	}
+}
+
+func TestWriteLineBreak(t *testing.T) {
+	tests := []string{"a\nb", "a\rb", "vpn.example.com\nscript-security 2"}
+
+	for i, arg := range tests {
+		c := &Config{}
+		c.Add("client")
+		c.Add("remote", arg, "1194")
+		var b strings.Builder
+		if n, err := c.WriteTo(&b); err == nil || n != 0 || b.Len() != 0 {
+			t.Errorf("test %d wrote %q, %v; want an error", i, b.String(), err)
+		}
+	}
+}
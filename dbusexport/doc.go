@@ -0,0 +1,31 @@
+// Package dbusexport exports the state and statistics of OpenVPN tunnels
+// on D-Bus, and lets them be connected and disconnected through it, so
+// that desktop applications such as tray icons and shell extensions can
+// integrate with them the way they do with NetworkManager's VPN plugins.
+// It is only available on Linux.
+//
+// Each tunnel is exported by an Exporter as an object under ObjectPrefix,
+// implementing the Interface interface:
+//
+//	Methods:
+//	  Connect()
+//	  Disconnect()
+//	  Reconnect()
+//	Signals:
+//	  StateChanged(s from, s to, s description)
+//	Properties (read-only, with PropertiesChanged emitted on change):
+//	  State s, LocalAddress s, RemoteAddress s, LastError s,
+//	  BytesIn x, BytesOut x, ConnectedSince x (Unix seconds, or 0)
+//
+// The methods are carried out by the tunnel's openvpn.Session, which must
+// have a Client, and fail with org.freedesktop.DBus.Error.Failed if the
+// session does. Typically the exporter is used on the system bus by a
+// privileged service, with a bus policy allowing desktop users to call it,
+// or on the session bus by a per-user daemon:
+//
+//	conn, _ := dbus.ConnectSystemBus()
+//	conn.RequestName(dbusexport.BusName, dbus.NameFlagDoNotQueue)
+//	exp := &dbusexport.Exporter{Session: session, Name: "work"}
+//	exp.Export(conn)
+//	go exp.Run(ctx, time.Second)
+package dbusexport
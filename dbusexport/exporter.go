@@ -0,0 +1,258 @@
+//go:build linux
+
+package dbusexport
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// The well-known bus name suggested for services exporting tunnels, the
+// interface implemented by each tunnel's object, and the prefix of their
+// object paths.
+const (
+	BusName      = "com.nordsecurity.gopenvpn"
+	Interface    = "com.nordsecurity.gopenvpn.Tunnel"
+	ObjectPrefix = "/com/nordsecurity/gopenvpn/Tunnel/"
+)
+
+// DefaultTimeout is how long the Connect, Disconnect and Reconnect methods
+// wait for the tunnel when Exporter.Timeout is zero.
+const DefaultTimeout = time.Minute
+
+// ObjectPath returns the path of the object exporting the named tunnel.
+// Characters not allowed in object paths are escaped as an underscore
+// followed by two hex digits, as systemd does.
+func ObjectPath(name string) dbus.ObjectPath {
+	var b strings.Builder
+	b.WriteString(ObjectPrefix)
+	if name == "" {
+		b.WriteByte('_')
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return dbus.ObjectPath(b.String())
+}
+
+// Exporter exports a tunnel on D-Bus. The fields must be set before Export
+// is called, and not changed afterwards.
+type Exporter struct {
+	Session *openvpn.Session
+
+	// Name identifies the tunnel, and determines its object path as
+	// described for ObjectPath.
+	Name string
+
+	// Timeout limits how long a method call waits for the tunnel, and
+	// defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	conn   *dbus.Conn
+	props  *prop.Properties
+	remove func()
+	err    error
+}
+
+// Export exports the tunnel's object on the given connection, and begins
+// emitting StateChanged signals as the session changes state. The
+// properties reflect the session as of the call, and are refreshed by
+// Update.
+func (e *Exporter) Export(conn *dbus.Conn) error {
+	path := ObjectPath(e.Name)
+	methods := &tunnel{e}
+	if err := conn.Export(methods, path, Interface); err != nil {
+		return err
+	}
+	props, err := prop.Export(conn, path, prop.Map{Interface: e.properties()})
+	if err != nil {
+		conn.Export(nil, path, Interface)
+		return err
+	}
+	node := &introspect.Node{
+		Name: string(path),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       Interface,
+				Methods:    introspect.Methods(methods),
+				Properties: props.Introspection(Interface),
+				Signals: []introspect.Signal{{
+					Name: "StateChanged",
+					Args: []introspect.Arg{
+						{Name: "from", Type: "s"},
+						{Name: "to", Type: "s"},
+						{Name: "description", Type: "s"},
+					},
+				}},
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		e.unexport(conn, path)
+		return err
+	}
+
+	e.mu.Lock()
+	e.conn, e.props = conn, props
+	e.mu.Unlock()
+	e.remove = e.Session.RegisterTransitionHook("", "", e.transition)
+	return nil
+}
+
+// Close stops emitting signals and removes the tunnel's object from the
+// connection.
+func (e *Exporter) Close() error {
+	if e.remove != nil {
+		e.remove()
+	}
+	e.mu.Lock()
+	conn := e.conn
+	e.conn, e.props = nil, nil
+	e.mu.Unlock()
+	if conn != nil {
+		e.unexport(conn, ObjectPath(e.Name))
+	}
+	return nil
+}
+
+func (e *Exporter) unexport(conn *dbus.Conn, path dbus.ObjectPath) {
+	for _, iface := range []string{Interface, "org.freedesktop.DBus.Properties", "org.freedesktop.DBus.Introspectable"} {
+		conn.Export(nil, path, iface)
+	}
+}
+
+// Update refreshes the properties from the session, emitting
+// PropertiesChanged for those that changed.
+func (e *Exporter) Update() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.props == nil {
+		return
+	}
+	for name, p := range e.properties() {
+		if e.props.GetMust(Interface, name) != p.Value {
+			e.props.SetMust(Interface, name, p.Value)
+		}
+	}
+}
+
+// Run calls Update at the given interval until ctx is cancelled, returning
+// the context's error. The interval determines how often
+// PropertiesChanged is emitted for the byte counts.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			e.Update()
+		}
+	}
+}
+
+// Err returns the most recent error encountered while emitting a signal,
+// if any.
+func (e *Exporter) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// properties returns the properties of the tunnel reflecting the current
+// state of the session.
+func (e *Exporter) properties() map[string]*prop.Prop {
+	snap := e.Session.Snapshot()
+	var lastErr string
+	if snap.LastError != nil {
+		lastErr = snap.LastError.Error()
+	}
+	var since int64
+	if !snap.ConnectedSince.IsZero() {
+		since = snap.ConnectedSince.Unix()
+	}
+	values := map[string]interface{}{
+		"State":          string(snap.State),
+		"LocalAddress":   snap.LocalTunnelAddr,
+		"RemoteAddress":  snap.RemoteAddr,
+		"LastError":      lastErr,
+		"BytesIn":        snap.BytesIn,
+		"BytesOut":       snap.BytesOut,
+		"ConnectedSince": since,
+	}
+	props := make(map[string]*prop.Prop, len(values))
+	for name, v := range values {
+		props[name] = &prop.Prop{Value: v, Emit: prop.EmitTrue}
+	}
+	return props
+}
+
+// transition emits StateChanged for a transition of the session and
+// updates the properties.
+func (e *Exporter) transition(t openvpn.Transition) {
+	e.mu.Lock()
+	conn := e.conn
+	e.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	var description string
+	if t.Event != nil {
+		description = t.Event.Description()
+	}
+	err := conn.Emit(ObjectPath(e.Name), Interface+".StateChanged", string(t.From), string(t.To), description)
+	if err != nil {
+		e.mu.Lock()
+		e.err = err
+		e.mu.Unlock()
+	}
+	e.Update()
+}
+
+func (e *Exporter) call(fn func(context.Context) error) *dbus.Error {
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := fn(ctx); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// tunnel holds the methods exported on D-Bus, which must be exported
+// methods but are not part of the Exporter's API.
+type tunnel struct {
+	e *Exporter
+}
+
+func (t *tunnel) Connect() *dbus.Error {
+	return t.e.call(t.e.Session.Connect)
+}
+
+func (t *tunnel) Disconnect() *dbus.Error {
+	return t.e.call(t.e.Session.Disconnect)
+}
+
+func (t *tunnel) Reconnect() *dbus.Error {
+	return t.e.call(t.e.Session.Reconnect)
+}
@@ -0,0 +1,124 @@
+//go:build linux
+
+package dbusexport
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/godbus/dbus/v5"
+)
+
+func TestObjectPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want dbus.ObjectPath
+	}{
+		{"work", ObjectPrefix + "work"},
+		{"eu-west.1", ObjectPrefix + "eu_2dwest_2e1"},
+		{"", ObjectPrefix + "_"},
+	}
+
+	for i, test := range tests {
+		got := ObjectPath(test.name)
+		if got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+		if !got.IsValid() {
+			t.Errorf("test %d path %q is invalid", i, got)
+		}
+	}
+}
+
+// startBus starts a private session bus, returning its address.
+func startBus(t *testing.T) string {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not available")
+	}
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start dbus-daemon: %s", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Skipf("dbus-daemon did not start: %s", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestExporter(t *testing.T) {
+	addr := startBus(t)
+	server, client := connect(t, addr), connect(t, addr)
+
+	session := &openvpn.Session{}
+	session.HandleEvent(openvpn.ParseEvent([]byte("STATE:1234,CONNECTING,,,")))
+	exp := &Exporter{Session: session, Name: "work"}
+	if err := exp.Export(server); err != nil {
+		t.Fatal(err)
+	}
+	defer exp.Close()
+
+	path := ObjectPath("work")
+	if err := client.AddMatchSignal(dbus.WithMatchObjectPath(path), dbus.WithMatchInterface(Interface)); err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 10)
+	client.Signal(signals)
+
+	obj := client.Object(server.Names()[0], path)
+	state, err := obj.GetProperty(Interface + ".State")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Value() != "CONNECTING" {
+		t.Errorf("got state %v; want CONNECTING", state.Value())
+	}
+
+	session.HandleEvent(openvpn.ParseEvent([]byte("STATE:1235,CONNECTED,SUCCESS,10.8.0.2,1.2.3.4")))
+	select {
+	case sig := <-signals:
+		want := []interface{}{"CONNECTING", "CONNECTED", "SUCCESS"}
+		if sig.Name != Interface+".StateChanged" || len(sig.Body) != len(want) {
+			t.Fatalf("got signal %s %v", sig.Name, sig.Body)
+		}
+		for i := range want {
+			if sig.Body[i] != want[i] {
+				t.Errorf("signal argument %d got %v; want %v", i, sig.Body[i], want[i])
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no StateChanged signal")
+	}
+	local, err := obj.GetProperty(Interface + ".LocalAddress")
+	if err != nil || local.Value() != "10.8.0.2" {
+		t.Errorf("got local address %v, %v; want 10.8.0.2", local.Value(), err)
+	}
+
+	// The session has no client, so it cannot be controlled.
+	err = obj.Call(Interface+".Disconnect", 0).Err
+	if dbusErr, ok := err.(dbus.Error); !ok || dbusErr.Name != "org.freedesktop.DBus.Error.Failed" {
+		t.Errorf("got error %v from Disconnect; want Failed", err)
+	}
+}
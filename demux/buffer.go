@@ -0,0 +1,52 @@
+package demux
+
+import "sync"
+
+// minBufferSize is the smallest buffer allocated for a message, so that
+// buffers are likely to be big enough for reuse by the next one.
+const minBufferSize = 128
+
+// Message buffers are drawn from a pool shared by all connections. The
+// pool holds pointers, which holders recycles in turn, so that neither
+// getting nor releasing a buffer allocates in the steady state.
+var (
+	buffers sync.Pool
+	holders sync.Pool
+)
+
+// getBuffer returns an empty buffer with capacity for at least n bytes.
+func getBuffer(n int) []byte {
+	if h, ok := buffers.Get().(*[]byte); ok {
+		buf := *h
+		*h = nil
+		holders.Put(h)
+		if cap(buf) >= n {
+			return buf[:0]
+		}
+	}
+	if n < minBufferSize {
+		n = minBufferSize
+	}
+	return make([]byte, 0, n)
+}
+
+// Release returns a buffer received from Demultiplex to the pool, for
+// reuse by later messages. It may also be passed any slice of such a
+// buffer, such as one retained by an event parsed from it.
+//
+// Each buffer sent by Demultiplex belongs to its recipient, which may keep
+// it for as long as it likes. Releasing it is optional, and only reduces
+// the allocations made for later messages, but once released neither the
+// caller nor anything the caller shared it with may use it again, since
+// its contents will be overwritten.
+func Release(buf []byte) {
+	if cap(buf) == 0 {
+		return
+	}
+	h, ok := holders.Get().(*[]byte)
+	if !ok {
+		h = new([]byte)
+	}
+	*h = buf[:0]
+	buffers.Put(h)
+}
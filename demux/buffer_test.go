@@ -0,0 +1,72 @@
+package demux
+
+import (
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	tests := []struct {
+		release int
+		get     int
+	}{
+		{0, 10},
+		{10, 10},
+		{minBufferSize * 2, 10},
+		{10, minBufferSize * 4},
+		{minBufferSize, minBufferSize},
+	}
+
+	for i, test := range tests {
+		if test.release > 0 {
+			buf := make([]byte, test.release)
+			Release(buf[1:])
+		} else {
+			Release(nil)
+		}
+		buf := getBuffer(test.get)
+		if len(buf) != 0 {
+			t.Errorf("test %d got buffer of length %d; want 0", i, len(buf))
+		}
+		if cap(buf) < test.get {
+			t.Errorf("test %d got buffer of capacity %d; want at least %d", i, cap(buf), test.get)
+		}
+	}
+}
+
+func TestDemultiplex_release(t *testing.T) {
+	// Released buffers are reused for later messages, which must not
+	// disturb the contents of those that weren't released.
+	var input []string
+	for i := 0; i < 100; i++ {
+		input = append(input, ">BYTECOUNT_CLI:1,2,3", "SUCCESS: pid=1234")
+	}
+	replyCh := make(chan []byte)
+	eventCh := make(chan []byte)
+	go Demultiplex(mockReader(input), replyCh, eventCh)
+
+	var kept [][]byte
+	for replyCh != nil || eventCh != nil {
+		select {
+		case msg, ok := <-replyCh:
+			if !ok {
+				replyCh = nil
+				continue
+			}
+			kept = append(kept, msg)
+		case msg, ok := <-eventCh:
+			if !ok {
+				eventCh = nil
+				continue
+			}
+			if got, want := string(msg), "BYTECOUNT_CLI:1,2,3"; got != want {
+				t.Errorf("got event %q; want %q", got, want)
+			}
+			Release(msg)
+		}
+	}
+	for _, msg := range kept {
+		if got, want := string(msg), "SUCCESS: pid=1234"; got != want {
+			t.Errorf("got reply %q; want %q", got, want)
+		}
+	}
+}
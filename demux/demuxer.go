@@ -17,7 +17,10 @@ var readErrSynthEvent = []byte("FATAL:Error reading from OpenVPN")
 //
 // The buffers written to replyCh are entire raw message lines (without the
 // trailing newlines), while the buffers written to eventCh are the raw
-// event strings with the prototcol's leading '>' indicator omitted.
+// event strings with the prototcol's leading '>' indicator omitted. Each
+// buffer is a separate buffer owned by the recipient, so the recipient may
+// retain it without copying, or pass it to Release once it is finished
+// with it to save allocating another for a later message.
 //
 // The caller should usually provide buffered channels of sufficient buffer
 // depth so that the reply channel will not be starved by slow event
@@ -32,6 +35,13 @@ var readErrSynthEvent = []byte("FATAL:Error reading from OpenVPN")
 // synthetic message will have the error message "Error reading from OpenVPN".
 func Demultiplex(r io.Reader, replyCh, eventCh chan<- []byte) {
 	scanner := bufio.NewScanner(r)
+
+	// The scanner's own buffer is only needed until we return, so it can
+	// be reused by the next connection.
+	lineBuf := getBuffer(4096)
+	defer Release(lineBuf)
+	scanner.Buffer(lineBuf[:cap(lineBuf)], bufio.MaxScanTokenSize)
+
 	for scanner.Scan() {
 		buf := scanner.Bytes()
 
@@ -41,6 +51,10 @@ func Demultiplex(r io.Reader, replyCh, eventCh chan<- []byte) {
 			continue
 		}
 
+		// The scanner reuses its buffer for each line, so we must take
+		// a copy before handing it off to another goroutine.
+		buf = append(getBuffer(len(buf)), buf...)
+
 		// Asynchronous messages always start with > to differentiate
 		// them from replies.
 		if buf[0] == '>' {
@@ -54,8 +68,10 @@ func Demultiplex(r io.Reader, replyCh, eventCh chan<- []byte) {
 
 	if err := scanner.Err(); err != nil {
 		// Generate a synthetic FATAL event so that the caller can
-		// see that the connection was not gracefully closed.
-		eventCh <- readErrSynthEvent
+		// see that the connection was not gracefully closed. It is
+		// copied like any other message, in case the recipient releases
+		// it.
+		eventCh <- append(getBuffer(len(readErrSynthEvent)), readErrSynthEvent...)
 	}
 
 	close(eventCh)
@@ -151,6 +151,7 @@ func captureMsgs(r io.Reader) (replies, events []string) {
 		case msg, ok := <-replyCh:
 			if ok {
 				replies = append(replies, string(msg))
+				Release(msg)
 			} else {
 				replyCh = nil
 			}
@@ -158,6 +159,7 @@ func captureMsgs(r io.Reader) (replies, events []string) {
 		case msg, ok := <-eventCh:
 			if ok {
 				events = append(events, string(msg))
+				Release(msg)
 			} else {
 				eventCh = nil
 			}
@@ -0,0 +1,24 @@
+// Package gopenvpntest provides a fake OpenVPN management interface, for
+// testing programs that use package openvpn without running a real
+// OpenVPN daemon.
+//
+// A Server accepts management connections, answers each command with a
+// canned reply, and emits asynchronous events on demand:
+//
+//	srv, err := gopenvpntest.NewServer()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer srv.Close()
+//	srv.Handle("state", gopenvpntest.Lines("1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1"))
+//	srv.Handle("hold", gopenvpntest.Error("no hold"))
+//
+//	events := make(chan openvpn.Event, 10)
+//	client, err := openvpn.Dial(srv.Addr, events)
+//	...
+//	srv.Emit("STATE:1,RECONNECTING,ping-restart,,")
+//
+// Commands without a reply of their own are answered as OpenVPN answers
+// unknown commands, with an error. Commands() lists the commands the
+// server has received, for checking what a program sent.
+package gopenvpntest
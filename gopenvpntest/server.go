@@ -0,0 +1,335 @@
+package gopenvpntest
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// DefaultGreeting is the INFO message sent to each new connection when
+// Server.Greeting is empty, as sent by OpenVPN itself.
+const DefaultGreeting = "INFO:OpenVPN Management Interface Version 5 -- type 'help' for more info"
+
+// unknownCommand is the error OpenVPN returns for commands it doesn't
+// recognize, and so the reply to commands without a handler.
+const unknownCommand = "unknown command, enter 'help' for more options"
+
+// blockCommands are the commands whose arguments are followed by lines of
+// payload ending with END, which are received as a single command.
+var blockCommands = []string{"client-auth", "client-pf", "pk-sig", "rsa-sig", "certificate"}
+
+// Reply is a canned reply to a command, made with Success, Error or Lines.
+type Reply struct {
+	text string
+}
+
+// Success returns a single-line reply reporting success with the given
+// message, as for most commands.
+func Success(msg string) Reply {
+	return Reply{"SUCCESS: " + msg + "\n"}
+}
+
+// Error returns a single-line reply reporting failure with the given
+// message, which the client returns as an openvpn.ErrorFromServer.
+func Error(msg string) Reply {
+	return Reply{"ERROR: " + msg + "\n"}
+}
+
+// Lines returns a multi-line reply of the given lines, terminated by END,
+// as for commands such as state, status and log.
+func Lines(lines ...string) Reply {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteString("END\n")
+	return Reply{b.String()}
+}
+
+// HandlerFunc returns the reply to a command. It is called with the
+// command as received, without its line ending; a command with a payload,
+// such as client-auth, is given with each line of the payload, up to and
+// including END, on a line of its own.
+type HandlerFunc func(command string) Reply
+
+// Server is a fake OpenVPN management interface. It answers each command
+// it receives with the reply given for it by Handle or HandleFunc, or
+// with an error if there is none, and emits events when Emit is called.
+//
+// NewServer returns a server listening on a loopback TCP port, for
+// clients made with openvpn.Dial. The zero value is a server with no
+// listener, whose connections are made with Pipe or ServeConn.
+//
+// A Server is safe for concurrent use.
+type Server struct {
+	// Addr is the address of the server's listener, if it has one, such
+	// as "127.0.0.1:37485".
+	Addr string
+
+	// Greeting is the message sent to each new connection, as an event,
+	// before anything else. If empty, DefaultGreeting is used. It must be
+	// set before the first connection is made.
+	Greeting string
+
+	l  net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+	commands []string
+	conns    map[*conn]struct{}
+	closed   bool
+}
+
+// conn is a connection to the server.
+type conn struct {
+	rwc io.ReadWriteCloser
+
+	// mu serializes writes, so that events emitted while a command is
+	// being handled aren't interleaved with its reply.
+	mu sync.Mutex
+}
+
+// NewServer returns a server listening on a loopback TCP port, given by
+// its Addr. It must be closed with Close once the test has finished.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Addr: l.Addr().String(), l: l}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.ServeConn(c)
+	}
+}
+
+// Handle arranges for the given reply to be sent to each subsequent
+// command matching command. A command matches if it is the same as
+// command or, failing that, if its first word is, so that for example a
+// reply handled for "state" answers both "state" and "state on". A reply
+// handled for the empty command answers all commands that match no
+// other.
+func (s *Server) Handle(command string, reply Reply) {
+	s.HandleFunc(command, func(string) Reply { return reply })
+}
+
+// HandleFunc is like Handle, except that the reply to each matching
+// command is returned by fn, which may for example emit events with Emit
+// before the reply is sent.
+func (s *Server) HandleFunc(command string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers == nil {
+		s.handlers = map[string]HandlerFunc{}
+	}
+	s.handlers[command] = fn
+}
+
+// Commands returns the commands received so far, on any connection, in
+// the order they were received. They are given as for HandlerFunc.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Emit sends the given event, such as "STATE:1,CONNECTED,SUCCESS,,", to
+// each open connection, as OpenVPN sends real-time notifications. The
+// leading ">" is added. It blocks until each connection has accepted the
+// event, so the receiving clients' events must be drained.
+func (s *Server) Emit(event string) error {
+	msg := ">" + event + "\n"
+	var errs []error
+	for _, c := range s.openConns() {
+		if err := c.write(msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Pipe returns the client's end of a new in-memory connection to the
+// server, which can be passed to openvpn.NewClient.
+func (s *Server) Pipe() net.Conn {
+	server, client := net.Pipe()
+	s.ServeConn(server)
+	return client
+}
+
+// Client returns a management client connected to the server by Pipe,
+// whose events are discarded, for tests that only send commands. It is
+// disconnected by CloseConns or Close.
+func (s *Server) Client() *openvpn.MgmtClient {
+	events := make(chan openvpn.Event, 10)
+	go func() {
+		for range events {
+		}
+	}()
+	return openvpn.NewClient(s.Pipe(), events)
+}
+
+// ServeConn serves the given connection until it is closed, by the
+// client, by CloseConns or by Close. It returns immediately, serving the
+// connection in the background.
+func (s *Server) ServeConn(rwc io.ReadWriteCloser) {
+	c := &conn{rwc: rwc}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		rwc.Close()
+		return
+	}
+	if s.conns == nil {
+		s.conns = map[*conn]struct{}{}
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer s.removeConn(c)
+		s.serve(c)
+	}()
+}
+
+func (s *Server) serve(c *conn) {
+	greeting := s.Greeting
+	if greeting == "" {
+		greeting = DefaultGreeting
+	}
+	if err := c.write(">" + greeting + "\n"); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(c.rwc)
+	for scanner.Scan() {
+		cmd := strings.TrimRight(scanner.Text(), "\r")
+		if cmd == "" {
+			continue
+		}
+		if isBlockCommand(cmd) {
+			lines := []string{cmd}
+			for scanner.Scan() {
+				line := strings.TrimRight(scanner.Text(), "\r")
+				lines = append(lines, line)
+				if line == "END" {
+					break
+				}
+			}
+			cmd = strings.Join(lines, "\n")
+		}
+
+		fn := s.handle(cmd)
+		if fn == nil {
+			if verb := firstWord(cmd); verb == "quit" || verb == "exit" {
+				return
+			}
+			if err := c.write(Error(unknownCommand).text); err != nil {
+				return
+			}
+			continue
+		}
+		if err := c.write(fn(cmd).text); err != nil {
+			return
+		}
+	}
+}
+
+// handle records a received command and returns its handler, or nil if
+// there is none.
+func (s *Server) handle(cmd string) HandlerFunc {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, cmd)
+	firstLine, _, _ := strings.Cut(cmd, "\n")
+	if fn, ok := s.handlers[firstLine]; ok {
+		return fn
+	}
+	if fn, ok := s.handlers[firstWord(cmd)]; ok {
+		return fn
+	}
+	return s.handlers[""]
+}
+
+func isBlockCommand(cmd string) bool {
+	verb := firstWord(cmd)
+	for _, block := range blockCommands {
+		if verb == block {
+			return true
+		}
+	}
+	return false
+}
+
+func firstWord(cmd string) string {
+	if i := strings.IndexAny(cmd, " \n"); i != -1 {
+		return cmd[:i]
+	}
+	return cmd
+}
+
+func (s *Server) openConns() []*conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+func (s *Server) removeConn(c *conn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	c.rwc.Close()
+}
+
+// CloseConns closes all of the server's open connections, as if OpenVPN
+// had exited, while leaving it ready to accept new ones.
+func (s *Server) CloseConns() {
+	for _, c := range s.openConns() {
+		c.rwc.Close()
+	}
+}
+
+// Close stops the server's listener, if any, and closes all of its
+// connections, waiting for them to finish.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	var err error
+	if s.l != nil {
+		err = s.l.Close()
+	}
+	s.CloseConns()
+	s.wg.Wait()
+	return err
+}
+
+func (c *conn) write(msg string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := io.WriteString(c.rwc, msg)
+	return err
+}
@@ -0,0 +1,118 @@
+package gopenvpntest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestServer(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Handle("state", Lines("1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1"))
+	srv.Handle("hold release", Error("no hold"))
+	srv.Handle("client-auth", Success("client-auth command succeeded"))
+	srv.HandleFunc("signal", func(string) Reply {
+		srv.Emit("STATE:2,RECONNECTING,SIGHUP,,")
+		return Success("signal SIGHUP thrown")
+	})
+
+	events := make(chan openvpn.Event, 10)
+	client, err := openvpn.Dial(srv.Addr, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, want := <-events, "INFO: OpenVPN Management Interface Version 5 -- type 'help' for more info"; e.String() != want {
+		t.Errorf("got greeting %q; want %q", e, want)
+	}
+
+	state, err := client.LatestState()
+	if err != nil || state.NewState() != "CONNECTED" {
+		t.Errorf("LatestState returned %v, %v; want CONNECTED", state, err)
+	}
+	if err := client.HoldRelease(); err == nil || err.Error() != "no hold" {
+		t.Errorf("HoldRelease returned %v; want no hold", err)
+	}
+	if err := client.SetEchoEvents(true); err == nil {
+		t.Errorf("unhandled command succeeded")
+	}
+	if err := client.ClientAuth(1, 2, []string{"push \"route 10.0.0.0\""}); err != nil {
+		t.Errorf("ClientAuth returned %v", err)
+	}
+	if err := client.SendSignal("SIGHUP"); err != nil {
+		t.Errorf("SendSignal returned %v", err)
+	}
+	if e, ok := (<-events).(*openvpn.StateEvent); !ok || e.NewState() != "RECONNECTING" {
+		t.Errorf("got event %v; want RECONNECTING", e)
+	}
+
+	want := []string{
+		"state",
+		"hold release",
+		"echo on",
+		"client-auth 1 2\npush \"route 10.0.0.0\"\nEND",
+		`signal "SIGHUP"`,
+	}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+
+	srv.CloseConns()
+	for range events {
+	}
+}
+
+func TestServerPipe(t *testing.T) {
+	var srv Server
+	defer srv.Close()
+	srv.Greeting = "INFO:test"
+
+	events := make(chan openvpn.Event, 10)
+	client := openvpn.NewClient(srv.Pipe(), events)
+	if e := <-events; e.String() != "INFO: test" {
+		t.Errorf("got greeting %q; want \"INFO: test\"", e)
+	}
+
+	tests := []string{
+		"HOLD:Waiting for hold release:0",
+		"BYTECOUNT:1,2",
+		"PASSWORD:Need 'Auth' username/password",
+	}
+	for _, event := range tests {
+		if err := srv.Emit(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, event := range tests {
+		e := <-events
+		if _, ok := e.(*openvpn.UnknownEvent); ok {
+			t.Errorf("test %d got unknown event for %q", i, event)
+		}
+	}
+
+	client.Close()
+	for range events {
+	}
+}
+
+func TestServerDefaultHandler(t *testing.T) {
+	var srv Server
+	defer srv.Close()
+	srv.Handle("", Success("ok"))
+	srv.Handle("kill", Error("common name not found"))
+
+	client := srv.Client()
+	if err := client.HoldRelease(); err != nil {
+		t.Errorf("HoldRelease returned %v", err)
+	}
+	if err := client.Kill("alice"); err == nil || err.Error() != "common name not found" {
+		t.Errorf("Kill returned %v; want common name not found", err)
+	}
+	if got, want := srv.Commands(), []string{"hold release", `kill "alice"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: bridge.proto
+
+package bridgepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Bridge_StreamEvents_FullMethodName = "/gopenvpn.bridge.v1.Bridge/StreamEvents"
+	Bridge_Command_FullMethodName      = "/gopenvpn.bridge.v1.Bridge/Command"
+	Bridge_ListTunnels_FullMethodName  = "/gopenvpn.bridge.v1.Bridge/ListTunnels"
+	Bridge_ListClients_FullMethodName  = "/gopenvpn.bridge.v1.Bridge/ListClients"
+)
+
+// BridgeClient is the client API for Bridge service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BridgeClient interface {
+	// StreamEvents streams the events of the selected tunnels as they are
+	// received. Events are dropped for streams that fall too far behind.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Bridge_StreamEventsClient, error)
+	// Command sends a command to the management interface of a tunnel.
+	Command(ctx context.Context, in *CommandRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	// ListTunnels returns the status of each tunnel.
+	ListTunnels(ctx context.Context, in *ListTunnelsRequest, opts ...grpc.CallOption) (*ListTunnelsResponse, error)
+	// ListClients returns the clients connected to a tunnel that is an
+	// OpenVPN server.
+	ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error)
+}
+
+type bridgeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBridgeClient(cc grpc.ClientConnInterface) BridgeClient {
+	return &bridgeClient{cc}
+}
+
+func (c *bridgeClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Bridge_StreamEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Bridge_ServiceDesc.Streams[0], Bridge_StreamEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &bridgeStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Bridge_StreamEventsClient interface {
+	Recv() (*TunnelEvent, error)
+	grpc.ClientStream
+}
+
+type bridgeStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *bridgeStreamEventsClient) Recv() (*TunnelEvent, error) {
+	m := new(TunnelEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *bridgeClient) Command(ctx context.Context, in *CommandRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, Bridge_Command_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bridgeClient) ListTunnels(ctx context.Context, in *ListTunnelsRequest, opts ...grpc.CallOption) (*ListTunnelsResponse, error) {
+	out := new(ListTunnelsResponse)
+	err := c.cc.Invoke(ctx, Bridge_ListTunnels_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bridgeClient) ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error) {
+	out := new(ListClientsResponse)
+	err := c.cc.Invoke(ctx, Bridge_ListClients_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BridgeServer is the server API for Bridge service.
+// All implementations must embed UnimplementedBridgeServer
+// for forward compatibility
+type BridgeServer interface {
+	// StreamEvents streams the events of the selected tunnels as they are
+	// received. Events are dropped for streams that fall too far behind.
+	StreamEvents(*StreamEventsRequest, Bridge_StreamEventsServer) error
+	// Command sends a command to the management interface of a tunnel.
+	Command(context.Context, *CommandRequest) (*CommandResponse, error)
+	// ListTunnels returns the status of each tunnel.
+	ListTunnels(context.Context, *ListTunnelsRequest) (*ListTunnelsResponse, error)
+	// ListClients returns the clients connected to a tunnel that is an
+	// OpenVPN server.
+	ListClients(context.Context, *ListClientsRequest) (*ListClientsResponse, error)
+	mustEmbedUnimplementedBridgeServer()
+}
+
+// UnimplementedBridgeServer must be embedded to have forward compatible implementations.
+type UnimplementedBridgeServer struct {
+}
+
+func (UnimplementedBridgeServer) StreamEvents(*StreamEventsRequest, Bridge_StreamEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedBridgeServer) Command(context.Context, *CommandRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Command not implemented")
+}
+func (UnimplementedBridgeServer) ListTunnels(context.Context, *ListTunnelsRequest) (*ListTunnelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTunnels not implemented")
+}
+func (UnimplementedBridgeServer) ListClients(context.Context, *ListClientsRequest) (*ListClientsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListClients not implemented")
+}
+func (UnimplementedBridgeServer) mustEmbedUnimplementedBridgeServer() {}
+
+// UnsafeBridgeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BridgeServer will
+// result in compilation errors.
+type UnsafeBridgeServer interface {
+	mustEmbedUnimplementedBridgeServer()
+}
+
+func RegisterBridgeServer(s grpc.ServiceRegistrar, srv BridgeServer) {
+	s.RegisterService(&Bridge_ServiceDesc, srv)
+}
+
+func _Bridge_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BridgeServer).StreamEvents(m, &bridgeStreamEventsServer{stream})
+}
+
+type Bridge_StreamEventsServer interface {
+	Send(*TunnelEvent) error
+	grpc.ServerStream
+}
+
+type bridgeStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *bridgeStreamEventsServer) Send(m *TunnelEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Bridge_Command_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BridgeServer).Command(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bridge_Command_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BridgeServer).Command(ctx, req.(*CommandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bridge_ListTunnels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTunnelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BridgeServer).ListTunnels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bridge_ListTunnels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BridgeServer).ListTunnels(ctx, req.(*ListTunnelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bridge_ListClients_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClientsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BridgeServer).ListClients(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bridge_ListClients_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BridgeServer).ListClients(ctx, req.(*ListClientsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Bridge_ServiceDesc is the grpc.ServiceDesc for Bridge service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Bridge_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gopenvpn.bridge.v1.Bridge",
+	HandlerType: (*BridgeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Command",
+			Handler:    _Bridge_Command_Handler,
+		},
+		{
+			MethodName: "ListTunnels",
+			Handler:    _Bridge_ListTunnels_Handler,
+		},
+		{
+			MethodName: "ListClients",
+			Handler:    _Bridge_ListClients_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Bridge_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "bridge.proto",
+}
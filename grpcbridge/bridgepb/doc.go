@@ -0,0 +1,5 @@
+// Package bridgepb holds the protocol buffer definitions of the service
+// implemented by package grpcbridge, generated from bridge.proto.
+package bridgepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative bridge.proto
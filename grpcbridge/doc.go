@@ -0,0 +1,22 @@
+// Package grpcbridge serves the tunnels of a launcher.Pool over gRPC, so
+// that components not written in Go can drive OpenVPN daemons through
+// this module.
+//
+// The service, defined in bridgepb/bridge.proto, streams the events of
+// the tunnels, sends commands to them, and reports their status and the
+// clients connected to those that are servers. A Server implements it:
+//
+//	events := make(chan launcher.TunnelEvent, 64)
+//	pool := &launcher.Pool{Events: events}
+//	bridge := grpcbridge.NewServer(pool)
+//	go bridge.Run(events)
+//
+//	srv := grpc.NewServer()
+//	bridgepb.RegisterBridgeServer(srv, bridge)
+//	go srv.Serve(listener)
+//	pool.Run(ctx)
+//
+// The service has no authentication of its own, so it should be served
+// over a Unix socket or with TLS client authentication configured on the
+// grpc.Server.
+package grpcbridge
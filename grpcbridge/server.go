@@ -0,0 +1,222 @@
+package grpcbridge
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/grpcbridge/bridgepb"
+	"github.com/NordSecurity/gopenvpn/launcher"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultStreamBuffer is the number of events buffered for each event
+// stream when Server.StreamBuffer is zero.
+const DefaultStreamBuffer = 256
+
+// Manager is the set of tunnels driven by a Server. It is implemented by
+// *launcher.Pool.
+type Manager interface {
+	Client(name string) *openvpn.MgmtClient
+	Status() []launcher.TunnelStatus
+	Clients(name string) ([]openvpn.ConnectedClient, error)
+}
+
+// Server implements bridgepb.BridgeServer for the tunnels of a Manager.
+type Server struct {
+	bridgepb.UnimplementedBridgeServer
+
+	// StreamBuffer is the number of events buffered for each event
+	// stream, beyond which events are dropped for that stream rather than
+	// holding up the others. It defaults to DefaultStreamBuffer.
+	StreamBuffer int
+
+	manager Manager
+	bus     openvpn.EventBus
+}
+
+// NewServer returns a server for the tunnels of the given manager. Its
+// events must be passed to Publish or Run to be streamed.
+func NewServer(m Manager) *Server {
+	return &Server{manager: m}
+}
+
+// tunnelEvent is a TunnelEvent published on the server's bus.
+type tunnelEvent struct {
+	launcher.TunnelEvent
+	received time.Time
+}
+
+func (e *tunnelEvent) String() string {
+	return e.Tunnel + ": " + openvpn.RedactedString(e.Event)
+}
+
+// Publish streams an event to the clients streaming the events of its
+// tunnel.
+func (s *Server) Publish(e launcher.TunnelEvent) {
+	s.bus.Publish(&tunnelEvent{TunnelEvent: e, received: time.Now()})
+}
+
+// Run publishes each event received from events until that channel is
+// closed, such as when Pool.Run returns, and then ends all event streams.
+func (s *Server) Run(events <-chan launcher.TunnelEvent) {
+	for e := range events {
+		s.Publish(e)
+	}
+	s.bus.Close()
+}
+
+// StreamEvents implements bridgepb.BridgeServer.
+func (s *Server) StreamEvents(req *bridgepb.StreamEventsRequest, stream bridgepb.Bridge_StreamEventsServer) error {
+	names := map[string]bool{}
+	for _, name := range req.Tunnels {
+		names[name] = true
+	}
+	filter := func(e openvpn.Event) bool {
+		te := e.(*tunnelEvent)
+		if len(names) > 0 && !names[te.Tunnel] {
+			return false
+		}
+		for k, v := range req.Tags {
+			if te.Tags[k] != v {
+				return false
+			}
+		}
+		return true
+	}
+	buffer := s.StreamBuffer
+	if buffer <= 0 {
+		buffer = DefaultStreamBuffer
+	}
+	sub := s.bus.SubscribeLossy(filter, buffer)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case e, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(eventProto(e.(*tunnelEvent))); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Command implements bridgepb.BridgeServer.
+func (s *Server) Command(ctx context.Context, req *bridgepb.CommandRequest) (*bridgepb.CommandResponse, error) {
+	if err := s.checkTunnel(req.Tunnel); err != nil {
+		return nil, err
+	}
+	client := s.manager.Client(req.Tunnel)
+	if client == nil {
+		return nil, status.Errorf(codes.Unavailable, "tunnel %q is not connected", req.Tunnel)
+	}
+	if req.Reason != "" {
+		client = client.WithReason(req.Reason)
+	}
+
+	var err error
+	switch cmd := req.Command.(type) {
+	case *bridgepb.CommandRequest_Signal:
+		err = client.SendSignal(cmd.Signal.Name)
+	case *bridgepb.CommandRequest_HoldRelease:
+		err = client.HoldRelease()
+	case *bridgepb.CommandRequest_ClientKill:
+		err = client.ClientKill(cmd.ClientKill.ClientId, cmd.ClientKill.Message)
+	case *bridgepb.CommandRequest_Kill:
+		err = client.Kill(cmd.Kill.Target)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "no command given")
+	}
+	if err != nil {
+		return nil, commandError(err)
+	}
+	return &bridgepb.CommandResponse{}, nil
+}
+
+// ListTunnels implements bridgepb.BridgeServer.
+func (s *Server) ListTunnels(ctx context.Context, req *bridgepb.ListTunnelsRequest) (*bridgepb.ListTunnelsResponse, error) {
+	resp := &bridgepb.ListTunnelsResponse{}
+	for _, ts := range s.manager.Status() {
+		t := &bridgepb.Tunnel{
+			Name:  ts.Name,
+			State: ts.State,
+			Pid:   int32(ts.Pid),
+			Tags:  ts.Tags,
+		}
+		if ts.Err != nil {
+			t.Error = ts.Err.Error()
+		}
+		resp.Tunnels = append(resp.Tunnels, t)
+	}
+	return resp, nil
+}
+
+// ListClients implements bridgepb.BridgeServer.
+func (s *Server) ListClients(ctx context.Context, req *bridgepb.ListClientsRequest) (*bridgepb.ListClientsResponse, error) {
+	clients, err := s.manager.Clients(req.Tunnel)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	resp := &bridgepb.ListClientsResponse{}
+	for _, cc := range clients {
+		c := &bridgepb.Client{
+			ClientId:           cc.ClientID,
+			CommonName:         cc.CommonName,
+			Username:           cc.Username,
+			RealAddress:        cc.RealAddress,
+			VirtualAddress:     cc.VirtualAddress,
+			VirtualIpv6Address: cc.VirtualIPv6Address,
+			BytesReceived:      cc.BytesReceived,
+			BytesSent:          cc.BytesSent,
+			Established:        cc.Established,
+		}
+		if !cc.ConnectedSince.IsZero() {
+			c.ConnectedSince = timestamppb.New(cc.ConnectedSince)
+		}
+		resp.Clients = append(resp.Clients, c)
+	}
+	return resp, nil
+}
+
+// checkTunnel fails with codes.NotFound if there is no tunnel with the
+// given name.
+func (s *Server) checkTunnel(name string) error {
+	for _, ts := range s.manager.Status() {
+		if ts.Name == name {
+			return nil
+		}
+	}
+	return status.Errorf(codes.NotFound, "no tunnel named %q", name)
+}
+
+func eventProto(e *tunnelEvent) *bridgepb.TunnelEvent {
+	pb := &bridgepb.TunnelEvent{
+		Tunnel:   e.Tunnel,
+		Tags:     e.Tags,
+		Received: timestamppb.New(e.received),
+		Type:     openvpn.EventTypeName(e.Event),
+		Text:     openvpn.RedactedString(e.Event),
+	}
+	if se, ok := e.Event.(*openvpn.StateEvent); ok {
+		pb.State = se.NewState()
+	}
+	return pb
+}
+
+// commandError returns the gRPC error for a failed command, distinguishing
+// errors reported by OpenVPN from failures of the connection.
+func commandError(err error) error {
+	var serverErr openvpn.ErrorFromServer
+	if errors.As(err, &serverErr) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
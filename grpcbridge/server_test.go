@@ -0,0 +1,180 @@
+package grpcbridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/gopenvpntest"
+	"github.com/NordSecurity/gopenvpn/grpcbridge/bridgepb"
+	"github.com/NordSecurity/gopenvpn/launcher"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeManager struct {
+	client  *openvpn.MgmtClient
+	clients []openvpn.ConnectedClient
+}
+
+func (m *fakeManager) Client(name string) *openvpn.MgmtClient {
+	if name != "office" {
+		return nil
+	}
+	return m.client
+}
+
+func (m *fakeManager) Status() []launcher.TunnelStatus {
+	return []launcher.TunnelStatus{
+		{Name: "office", State: "CONNECTED", Pid: 42, Tags: map[string]string{"role": "server"}},
+		{Name: "uplink", Err: fmt.Errorf("exited")},
+	}
+}
+
+func (m *fakeManager) Clients(name string) ([]openvpn.ConnectedClient, error) {
+	if name != "office" {
+		return nil, fmt.Errorf("no tunnel named %q", name)
+	}
+	return m.clients, nil
+}
+
+func startBridge(t *testing.T, s *Server) bridgepb.BridgeClient {
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	bridgepb.RegisterBridgeServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return bridgepb.NewBridgeClient(conn)
+}
+
+func TestServerCommand(t *testing.T) {
+	ovpn := &gopenvpntest.Server{}
+	defer ovpn.Close()
+	ovpn.Handle("", gopenvpntest.Success("ok"))
+	ovpn.Handle("kill", gopenvpntest.Error("common name not found"))
+	client, sent := ovpn.Client(), ovpn.Commands
+	bc := startBridge(t, NewServer(&fakeManager{client: client}))
+	ctx := context.Background()
+
+	tests := []struct {
+		req  *bridgepb.CommandRequest
+		code codes.Code
+	}{
+		{&bridgepb.CommandRequest{Tunnel: "office", Command: &bridgepb.CommandRequest_Signal{Signal: &bridgepb.Signal{Name: "SIGUSR1"}}}, codes.OK},
+		{&bridgepb.CommandRequest{Tunnel: "office", Command: &bridgepb.CommandRequest_ClientKill{ClientKill: &bridgepb.ClientKill{ClientId: 7, Message: "HALT"}}}, codes.OK},
+		{&bridgepb.CommandRequest{Tunnel: "office", Command: &bridgepb.CommandRequest_Kill{Kill: &bridgepb.Kill{Target: "alice"}}}, codes.FailedPrecondition},
+		{&bridgepb.CommandRequest{Tunnel: "office"}, codes.InvalidArgument},
+		{&bridgepb.CommandRequest{Tunnel: "uplink", Command: &bridgepb.CommandRequest_HoldRelease{HoldRelease: &bridgepb.HoldRelease{}}}, codes.Unavailable},
+		{&bridgepb.CommandRequest{Tunnel: "nowhere", Command: &bridgepb.CommandRequest_HoldRelease{HoldRelease: &bridgepb.HoldRelease{}}}, codes.NotFound},
+	}
+	for i, test := range tests {
+		_, err := bc.Command(ctx, test.req)
+		if got := status.Code(err); got != test.code {
+			t.Errorf("test %d got code %s (%v); want %s", i, got, err, test.code)
+		}
+	}
+
+	want := []string{`signal "SIGUSR1"`, `client-kill 7 "HALT"`, `kill "alice"`}
+	if got := sent(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("sent %q; want %q", got, want)
+	}
+}
+
+func TestServerList(t *testing.T) {
+	since := time.Unix(1714564800, 0)
+	m := &fakeManager{clients: []openvpn.ConnectedClient{
+		{ClientID: 1, CommonName: "alice", VirtualAddress: "10.8.0.2", ConnectedSince: since, Established: true},
+	}}
+	bc := startBridge(t, NewServer(m))
+	ctx := context.Background()
+
+	tunnels, err := bc.ListTunnels(ctx, &bridgepb.ListTunnelsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tunnels.Tunnels) != 2 {
+		t.Fatalf("got %d tunnels; want 2", len(tunnels.Tunnels))
+	}
+	if tun := tunnels.Tunnels[0]; tun.Name != "office" || tun.State != "CONNECTED" || tun.Pid != 42 || tun.Tags["role"] != "server" {
+		t.Errorf("got first tunnel %v", tun)
+	}
+	if tun := tunnels.Tunnels[1]; tun.Name != "uplink" || tun.Error != "exited" {
+		t.Errorf("got second tunnel %v", tun)
+	}
+
+	clients, err := bc.ListClients(ctx, &bridgepb.ListClientsRequest{Tunnel: "office"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients.Clients) != 1 {
+		t.Fatalf("got %d clients; want 1", len(clients.Clients))
+	}
+	c := clients.Clients[0]
+	if c.ClientId != 1 || c.CommonName != "alice" || c.VirtualAddress != "10.8.0.2" || !c.Established || !c.ConnectedSince.AsTime().Equal(since) {
+		t.Errorf("got client %v", c)
+	}
+
+	_, err = bc.ListClients(ctx, &bridgepb.ListClientsRequest{Tunnel: "nowhere"})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("got code %s for unknown tunnel; want %s", got, codes.NotFound)
+	}
+}
+
+func TestServerStreamEvents(t *testing.T) {
+	s := NewServer(&fakeManager{})
+	bc := startBridge(t, s)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := bc.StreamEvents(ctx, &bridgepb.StreamEventsRequest{Tags: map[string]string{"role": "server"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan launcher.TunnelEvent)
+	go s.Run(events)
+
+	server := map[string]string{"role": "server"}
+	// The subscription is made once the stream reaches the server, so
+	// keep publishing until the first event arrives.
+	go func() {
+		for ctx.Err() == nil {
+			events <- launcher.TunnelEvent{Tunnel: "uplink", Event: openvpn.ParseEvent([]byte("STATE:1,CONNECTING,,,"))}
+			events <- launcher.TunnelEvent{Tunnel: "office", Tags: server, Event: openvpn.ParseEvent([]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.1,"))}
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(events)
+	}()
+
+	e, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Tunnel != "office" || e.Type != "StateEvent" || e.State != "CONNECTED" || e.Tags["role"] != "server" || e.Received == nil {
+		t.Errorf("got event %v", e)
+	}
+}
+
+func TestEventProtoRedacts(t *testing.T) {
+	e := &tunnelEvent{TunnelEvent: launcher.TunnelEvent{
+		Tunnel: "uplink",
+		Event:  openvpn.ParseEvent([]byte("PASSWORD:Auth-Token:s3cret")),
+	}}
+	if got := eventProto(e).Text; strings.Contains(got, "s3cret") {
+		t.Errorf("event text %q contains the auth token", got)
+	}
+}
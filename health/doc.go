@@ -0,0 +1,12 @@
+// Package health serves the health of an OpenVPN tunnel over HTTP, for
+// liveness and readiness probes and for load balancers.
+//
+// A Checker grades the Health reported by a Session as up, degraded or
+// down, and its Healthz and Readyz methods return http.Handlers reporting
+// the result as plain text or JSON, which can be mounted in an
+// application's existing mux:
+//
+//	checker := &health.Checker{Session: session}
+//	mux.Handle("/healthz", checker.Healthz())
+//	mux.Handle("/readyz", checker.Readyz())
+package health
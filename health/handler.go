@@ -0,0 +1,149 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// DefaultMaxReconnects is the number of recent reconnects above which a
+// tunnel is degraded when Checker.MaxReconnects is zero.
+const DefaultMaxReconnects = 3
+
+// Status grades the health of a tunnel.
+type Status string
+
+const (
+	// StatusUp means the tunnel is connected and stable.
+	StatusUp Status = "up"
+
+	// StatusDegraded means the tunnel is connected, but has reconnected
+	// repeatedly or hasn't reported any events for a while.
+	StatusDegraded Status = "degraded"
+
+	// StatusDown means the tunnel is not connected, or its management
+	// interface is not.
+	StatusDown Status = "down"
+)
+
+// Report is the result of a health check, as written by the handlers in
+// JSON form.
+type Report struct {
+	Status Status `json:"status"`
+
+	// Reason explains why the tunnel is degraded or down, and is empty if
+	// it is up.
+	Reason string `json:"reason,omitempty"`
+
+	// The remaining fields are copied from openvpn.Health.
+	ManagementConnected bool          `json:"management_connected"`
+	State               openvpn.State `json:"state,omitempty"`
+	StateSince          time.Time     `json:"state_since"`
+	RecentReconnects    int           `json:"recent_reconnects"`
+	LastError           string        `json:"last_error,omitempty"`
+}
+
+// Checker grades the health of a Session.
+type Checker struct {
+	Session *openvpn.Session
+
+	// MaxReconnects is the number of reconnects within the session's
+	// ReconnectWindow above which the tunnel is degraded. It defaults to
+	// DefaultMaxReconnects, and is disabled if negative.
+	MaxReconnects int
+
+	// StaleAfter is how long the tunnel may go without reporting any
+	// event before it is degraded. It should be longer than the interval
+	// of byte count events, if they are enabled, and is disabled if zero.
+	StaleAfter time.Duration
+}
+
+// Check returns a report on the health of the session.
+func (c *Checker) Check() Report {
+	h := c.Session.Health()
+	r := Report{
+		Status:              StatusUp,
+		ManagementConnected: h.ManagementConnected,
+		State:               h.State,
+		StateSince:          h.StateSince,
+		RecentReconnects:    h.RecentReconnects,
+	}
+	if h.LastError != nil {
+		r.LastError = h.LastError.Error()
+	}
+
+	maxReconnects := c.MaxReconnects
+	if maxReconnects == 0 {
+		maxReconnects = DefaultMaxReconnects
+	}
+	switch {
+	case !h.ManagementConnected:
+		r.Status, r.Reason = StatusDown, "management interface not connected"
+	case h.State == "":
+		r.Status, r.Reason = StatusDown, "no state reported"
+	case h.State != openvpn.StateConnected:
+		r.Status, r.Reason = StatusDown, "tunnel is "+strings.ToLower(string(h.State))
+		if r.LastError != "" {
+			r.Reason += ": " + r.LastError
+		}
+	case maxReconnects > 0 && h.RecentReconnects > maxReconnects:
+		r.Status, r.Reason = StatusDegraded, fmt.Sprintf("%d recent reconnects", h.RecentReconnects)
+	case c.StaleAfter > 0 && h.SinceLastEvent > c.StaleAfter:
+		r.Status, r.Reason = StatusDegraded, fmt.Sprintf("no events for %s", h.SinceLastEvent.Round(time.Second))
+	}
+	return r
+}
+
+// Healthz returns a handler for liveness probes, which fails with 503
+// Service Unavailable only if the management interface is not connected,
+// since a tunnel that is down but still managed will be reconnected by
+// OpenVPN itself.
+func (c *Checker) Healthz() http.Handler {
+	return c.handler(func(r Report) bool { return r.ManagementConnected })
+}
+
+// Readyz returns a handler for readiness probes, which fails with 503
+// Service Unavailable unless the tunnel is up or degraded.
+func (c *Checker) Readyz() http.Handler {
+	return c.handler(func(r Report) bool { return r.Status != StatusDown })
+}
+
+// handler returns a handler writing the report with a status code
+// depending on whether ok accepts it. The report is written as JSON if the
+// request accepts application/json or has the query parameter
+// format=json, and otherwise as a line of text such as "up" or
+// "down: management interface not connected".
+func (c *Checker) handler(ok func(Report) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := c.Check()
+		code := http.StatusOK
+		if !ok(report) {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if wantJSON(req) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		if report.Reason != "" {
+			fmt.Fprintf(w, "%s: %s\n", report.Status, report.Reason)
+		} else {
+			fmt.Fprintln(w, report.Status)
+		}
+	})
+}
+
+func wantJSON(req *http.Request) bool {
+	if req.URL.Query().Get("format") == "json" {
+		return true
+	}
+	return strings.Contains(req.Header.Get("Accept"), "application/json")
+}
@@ -0,0 +1,110 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestChecker(t *testing.T) {
+	tests := []struct {
+		events  []string
+		max     int
+		status  Status
+		reason  string
+		healthz int
+		readyz  int
+	}{
+		{
+			status:  StatusDown,
+			reason:  "management interface not connected",
+			healthz: http.StatusServiceUnavailable,
+			readyz:  http.StatusServiceUnavailable,
+		},
+		{
+			events:  []string{"HOLD:Waiting for hold release:0"},
+			status:  StatusDown,
+			reason:  "no state reported",
+			healthz: http.StatusOK,
+			readyz:  http.StatusServiceUnavailable,
+		},
+		{
+			events:  []string{"STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1"},
+			status:  StatusUp,
+			healthz: http.StatusOK,
+			readyz:  http.StatusOK,
+		},
+		{
+			events:  []string{"STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1", "STATE:2,RECONNECTING,ping-restart,,"},
+			status:  StatusDown,
+			reason:  "tunnel is reconnecting: connection timed out (ping-restart): ping-restart",
+			healthz: http.StatusOK,
+			readyz:  http.StatusServiceUnavailable,
+		},
+		{
+			events: []string{
+				"STATE:1,RECONNECTING,ping-restart,,",
+				"STATE:2,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+				"STATE:3,RECONNECTING,ping-restart,,",
+				"STATE:4,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+			},
+			max:     1,
+			status:  StatusDegraded,
+			reason:  "2 recent reconnects",
+			healthz: http.StatusOK,
+			readyz:  http.StatusOK,
+		},
+	}
+
+	for i, tt := range tests {
+		s := &openvpn.Session{}
+		for _, raw := range tt.events {
+			s.HandleEvent(openvpn.ParseEvent([]byte(raw)))
+		}
+		c := &Checker{Session: s, MaxReconnects: tt.max}
+		if r := c.Check(); r.Status != tt.status || r.Reason != tt.reason {
+			t.Errorf("test %d got %s %q; want %s %q", i, r.Status, r.Reason, tt.status, tt.reason)
+		}
+
+		for _, probe := range []struct {
+			handler http.Handler
+			code    int
+		}{{c.Healthz(), tt.healthz}, {c.Readyz(), tt.readyz}} {
+			w := httptest.NewRecorder()
+			probe.handler.ServeHTTP(w, httptest.NewRequest("GET", "/?format=json", nil))
+			var r Report
+			if err := json.Unmarshal(w.Body.Bytes(), &r); err != nil {
+				t.Errorf("test %d got malformed JSON: %s", i, err)
+			}
+			if w.Code != probe.code || r.Status != tt.status {
+				t.Errorf("test %d got %d %s; want %d %s", i, w.Code, r.Status, probe.code, tt.status)
+			}
+		}
+	}
+}
+
+func TestHandlerPlain(t *testing.T) {
+	s := &openvpn.Session{}
+	s.HandleEvent(openvpn.ParseEvent([]byte("STATE:1,AUTH,,,")))
+	c := &Checker{Session: s}
+
+	w := httptest.NewRecorder()
+	c.Readyz().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if got, want := w.Body.String(), "down: tunnel is auth\n"; got != want {
+		t.Errorf("got body %q; want %q", got, want)
+	}
+	if got, want := w.Header().Get("Content-Type"), "text/plain; charset=utf-8"; got != want {
+		t.Errorf("got content type %q; want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	req.Header.Set("Accept", "application/json")
+	c.Readyz().ServeHTTP(w, req)
+	if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("got content type %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,309 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/launcher"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// maxBodySize limits the size of the bodies of POST requests.
+const maxBodySize = 1 << 16
+
+// ReasonHeader is the request header whose value, if present, is recorded
+// as the reason for the commands sent by mutating requests, as for
+// MgmtClient.WithReason.
+const ReasonHeader = "X-Audit-Reason"
+
+// safeSignals are the signals that may be sent using the API, none of
+// which stop the OpenVPN process.
+var safeSignals = []string{"SIGHUP", "SIGUSR1", "SIGUSR2"}
+
+// Manager is the set of tunnels served by an API. It is implemented by
+// *launcher.Pool.
+type Manager interface {
+	Client(name string) *openvpn.MgmtClient
+	Status() []launcher.TunnelStatus
+	Clients(name string) ([]openvpn.ConnectedClient, error)
+}
+
+// Middleware wraps a handler, such as to authenticate requests before
+// passing them on.
+type Middleware func(http.Handler) http.Handler
+
+// API serves the tunnels of a Manager as JSON endpoints.
+type API struct {
+	// Middleware, if set, wraps every endpoint, and MutatingMiddleware,
+	// if set, further wraps the endpoints that act on the tunnels. They
+	// must be set before Register is called.
+	Middleware         Middleware
+	MutatingMiddleware Middleware
+
+	manager Manager
+}
+
+// New returns an API serving the tunnels of the given manager.
+func New(m Manager) *API {
+	return &API{manager: m}
+}
+
+// Tunnel is the status of a tunnel, as returned by the API.
+type Tunnel struct {
+	Name  string            `json:"name"`
+	State string            `json:"state"`
+	Pid   int               `json:"pid,omitempty"`
+	Error string            `json:"error,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+// Client is a client connected to a server, as returned by the API.
+type Client struct {
+	ClientID           int64     `json:"client_id"`
+	CommonName         string    `json:"common_name"`
+	Username           string    `json:"username,omitempty"`
+	RealAddress        string    `json:"real_address"`
+	VirtualAddress     string    `json:"virtual_address,omitempty"`
+	VirtualIPv6Address string    `json:"virtual_ipv6_address,omitempty"`
+	BytesReceived      int64     `json:"bytes_received"`
+	BytesSent          int64     `json:"bytes_sent"`
+	ConnectedSince     time.Time `json:"connected_since"`
+	Established        bool      `json:"established"`
+}
+
+// KillRequest is the body of a request to disconnect clients of a server.
+// Either ClientID or Target must be set.
+type KillRequest struct {
+	// ClientID disconnects the client with the given id, sending Message,
+	// if set, as the reason, as for MgmtClient.ClientKill.
+	ClientID *int64 `json:"client_id,omitempty"`
+	Message  string `json:"message,omitempty"`
+
+	// Target disconnects the clients with the given common name or real
+	// address, as for MgmtClient.Kill.
+	Target string `json:"target,omitempty"`
+}
+
+// SignalRequest is the body of a request to send a signal to the OpenVPN
+// process, which must be one of SIGHUP, SIGUSR1 or SIGUSR2.
+type SignalRequest struct {
+	Signal string `json:"signal"`
+}
+
+// Register registers the API's endpoints on mux, with paths beginning
+// with prefix, such as "/api", which may be empty.
+func (a *API) Register(mux *http.ServeMux, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux.Handle(prefix+"/tunnels", a.wrap(http.HandlerFunc(a.listTunnels), false))
+	mux.Handle(prefix+"/tunnels/", http.StripPrefix(prefix+"/tunnels/", a.wrap(http.HandlerFunc(a.tunnel), false)))
+}
+
+func (a *API) wrap(h http.Handler, mutating bool) http.Handler {
+	if mutating && a.MutatingMiddleware != nil {
+		h = a.MutatingMiddleware(h)
+	}
+	if a.Middleware != nil {
+		h = a.Middleware(h)
+	}
+	return h
+}
+
+func (a *API) listTunnels(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	ret := []Tunnel{}
+	for _, ts := range a.manager.Status() {
+		ret = append(ret, tunnelJSON(ts))
+	}
+	writeJSON(w, http.StatusOK, ret)
+}
+
+// tunnel serves the endpoints for a single tunnel, whose paths have had
+// the prefix up to the tunnel's name removed.
+func (a *API) tunnel(w http.ResponseWriter, r *http.Request) {
+	name, op, _ := strings.Cut(r.URL.Path, "/")
+	ts, ok := a.status(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no tunnel named %q", name))
+		return
+	}
+
+	switch op {
+	case "":
+		if allowMethod(w, r, http.MethodGet) {
+			writeJSON(w, http.StatusOK, tunnelJSON(ts))
+		}
+	case "clients":
+		if allowMethod(w, r, http.MethodGet) {
+			a.listClients(w, name)
+		}
+	case "kill", "signal", "hold-release":
+		a.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if allowMethod(w, r, http.MethodPost) {
+				a.command(w, r, name, op)
+			}
+		}), true).ServeHTTP(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown endpoint %q", op))
+	}
+}
+
+func (a *API) status(name string) (launcher.TunnelStatus, bool) {
+	for _, ts := range a.manager.Status() {
+		if ts.Name == name {
+			return ts, true
+		}
+	}
+	return launcher.TunnelStatus{}, false
+}
+
+func (a *API) listClients(w http.ResponseWriter, name string) {
+	clients, err := a.manager.Clients(name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	ret := []Client{}
+	for _, cc := range clients {
+		ret = append(ret, Client{
+			ClientID:           cc.ClientID,
+			CommonName:         cc.CommonName,
+			Username:           cc.Username,
+			RealAddress:        cc.RealAddress,
+			VirtualAddress:     cc.VirtualAddress,
+			VirtualIPv6Address: cc.VirtualIPv6Address,
+			BytesReceived:      cc.BytesReceived,
+			BytesSent:          cc.BytesSent,
+			ConnectedSince:     cc.ConnectedSince,
+			Established:        cc.Established,
+		})
+	}
+	writeJSON(w, http.StatusOK, ret)
+}
+
+// command performs one of the mutating operations on the named tunnel.
+func (a *API) command(w http.ResponseWriter, r *http.Request, name, op string) {
+	var do func(*openvpn.MgmtClient) error
+	switch op {
+	case "kill":
+		var req KillRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		switch {
+		case req.ClientID != nil && req.Target == "":
+			do = func(c *openvpn.MgmtClient) error { return c.ClientKill(*req.ClientID, req.Message) }
+		case req.ClientID == nil && req.Target != "":
+			do = func(c *openvpn.MgmtClient) error { return c.Kill(req.Target) }
+		default:
+			writeError(w, http.StatusBadRequest, errors.New("exactly one of client_id and target must be given"))
+			return
+		}
+	case "signal":
+		var req SignalRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if !isSafeSignal(req.Signal) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("signal must be one of %s", strings.Join(safeSignals, ", ")))
+			return
+		}
+		do = func(c *openvpn.MgmtClient) error { return c.SendSignal(req.Signal) }
+	case "hold-release":
+		do = (*openvpn.MgmtClient).HoldRelease
+	}
+
+	client := a.manager.Client(name)
+	if client == nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("tunnel %q is not connected", name))
+		return
+	}
+	if reason := r.Header.Get(ReasonHeader); reason != "" {
+		client = client.WithReason(reason)
+	}
+	if err := do(client); err != nil {
+		var serverErr openvpn.ErrorFromServer
+		if errors.As(err, &serverErr) {
+			writeError(w, http.StatusConflict, err)
+		} else {
+			writeError(w, http.StatusBadGateway, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BearerToken returns middleware that accepts only requests bearing one
+// of the given tokens in an "Authorization: Bearer" header, rejecting
+// others with 401 Unauthorized. Empty tokens are ignored, so that an unset
+// token never matches a request with an empty one.
+func BearerToken(tokens ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if ok {
+				for _, token := range tokens {
+					if token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		})
+	}
+}
+
+func tunnelJSON(ts launcher.TunnelStatus) Tunnel {
+	t := Tunnel{Name: ts.Name, State: ts.State, Pid: ts.Pid, Tags: ts.Tags}
+	if ts.Err != nil {
+		t.Error = ts.Err.Error()
+	}
+	return t
+}
+
+func isSafeSignal(sig string) bool {
+	for _, s := range safeSignals {
+		if sig == s {
+			return true
+		}
+	}
+	return false
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method || (method == http.MethodGet && r.Method == http.MethodHead) {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("malformed request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,116 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/gopenvpntest"
+	"github.com/NordSecurity/gopenvpn/launcher"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+type fakeManager struct {
+	client *openvpn.MgmtClient
+}
+
+func (m *fakeManager) Client(name string) *openvpn.MgmtClient {
+	if name != "office" {
+		return nil
+	}
+	return m.client
+}
+
+func (m *fakeManager) Status() []launcher.TunnelStatus {
+	return []launcher.TunnelStatus{
+		{Name: "office", State: "CONNECTED", Pid: 42},
+		{Name: "uplink", Err: fmt.Errorf("exited")},
+	}
+}
+
+func (m *fakeManager) Clients(name string) ([]openvpn.ConnectedClient, error) {
+	return []openvpn.ConnectedClient{
+		{ClientID: 1, CommonName: "alice", RealAddress: "192.0.2.10:51000", ConnectedSince: time.Unix(1714564800, 0).UTC()},
+	}, nil
+}
+
+func TestAPI(t *testing.T) {
+	ovpn := &gopenvpntest.Server{}
+	defer ovpn.Close()
+	ovpn.Handle("", gopenvpntest.Success("ok"))
+	ovpn.Handle("kill", gopenvpntest.Error("common name not found"))
+	client, sent := ovpn.Client(), ovpn.Commands
+	api := New(&fakeManager{client: client})
+	api.Middleware = BearerToken("viewer", "operator")
+	api.MutatingMiddleware = BearerToken("operator")
+	mux := http.NewServeMux()
+	api.Register(mux, "/api/")
+
+	tests := []struct {
+		method, path, token, body string
+		code                      int
+		want                      string
+	}{
+		{"GET", "/api/tunnels", "", "", 401, `{"error":"unauthorized"}`},
+		{"GET", "/api/tunnels", "viewer", "", 200, `[{"name":"office","state":"CONNECTED","pid":42},{"name":"uplink","state":"","error":"exited"}]`},
+		{"GET", "/api/tunnels/office", "viewer", "", 200, `{"name":"office","state":"CONNECTED","pid":42}`},
+		{"GET", "/api/tunnels/nowhere", "viewer", "", 404, `{"error":"no tunnel named \"nowhere\""}`},
+		{"GET", "/api/tunnels/office/clients", "viewer", "", 200,
+			`[{"client_id":1,"common_name":"alice","real_address":"192.0.2.10:51000","bytes_received":0,"bytes_sent":0,"connected_since":"2024-05-01T12:00:00Z","established":false}]`},
+		{"POST", "/api/tunnels/office", "viewer", "", 405, `{"error":"method POST not allowed"}`},
+		{"POST", "/api/tunnels/office/signal", "viewer", `{"signal":"SIGUSR1"}`, 401, `{"error":"unauthorized"}`},
+		{"POST", "/api/tunnels/office/signal", "operator", `{"signal":"SIGTERM"}`, 400, `{"error":"signal must be one of SIGHUP, SIGUSR1, SIGUSR2"}`},
+		{"POST", "/api/tunnels/office/signal", "operator", `{"signal":"SIGUSR1"}`, 204, ``},
+		{"POST", "/api/tunnels/office/kill", "operator", `{"client_id":1,"message":"HALT"}`, 204, ``},
+		{"POST", "/api/tunnels/office/kill", "operator", `{"target":"bob"}`, 409, `{"error":"common name not found"}`},
+		{"POST", "/api/tunnels/office/kill", "operator", `{}`, 400, `{"error":"exactly one of client_id and target must be given"}`},
+		{"POST", "/api/tunnels/office/hold-release", "operator", ``, 204, ``},
+		{"POST", "/api/tunnels/uplink/hold-release", "operator", ``, 503, `{"error":"tunnel \"uplink\" is not connected"}`},
+		{"GET", "/api/tunnels/office/other", "viewer", "", 404, `{"error":"unknown endpoint \"other\""}`},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if got := strings.TrimSpace(w.Body.String()); w.Code != test.code || got != test.want {
+			t.Errorf("test %d got %d %s; want %d %s", i, w.Code, got, test.code, test.want)
+		}
+	}
+
+	want := []string{`signal "SIGUSR1"`, `client-kill 1 "HALT"`, `kill "bob"`, "hold release"}
+	if got := sent(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("sent %q; want %q", got, want)
+	}
+}
+
+func TestBearerTokenEmpty(t *testing.T) {
+	handler := BearerToken("", "operator")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		header string
+		code   int
+	}{
+		{"", 401},
+		{"Bearer ", 401},
+		{"Bearer operator", 204},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			req.Header.Set("Authorization", test.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != test.code {
+			t.Errorf("test %d got %d; want %d", i, w.Code, test.code)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Package httpapi serves the status of the tunnels of a launcher.Pool, and
+// a few safe operations on them, as a JSON API, as a building block for
+// web dashboards.
+//
+// An API registers the following endpoints on a mux under a prefix:
+//
+//	GET  /tunnels                     the status of each tunnel
+//	GET  /tunnels/{name}              the status of one tunnel
+//	GET  /tunnels/{name}/clients      the clients connected to a server
+//	POST /tunnels/{name}/kill         disconnect clients of a server
+//	POST /tunnels/{name}/signal       send SIGHUP, SIGUSR1 or SIGUSR2
+//	POST /tunnels/{name}/hold-release release the hold on the process
+//
+// The bodies of the POST requests are described by KillRequest and
+// SignalRequest. Errors are reported with a suitable status code and
+// a body such as {"error": "no tunnel named \"office\""}.
+//
+// The API has no authentication of its own. Middleware, such as that
+// returned by BearerToken, can be set to authenticate requests, and
+// MutatingMiddleware to further restrict the POST endpoints:
+//
+//	api := httpapi.New(pool)
+//	api.Middleware = httpapi.BearerToken(viewerToken, operatorToken)
+//	api.MutatingMiddleware = httpapi.BearerToken(operatorToken)
+//	api.Register(mux, "/api")
+package httpapi
@@ -0,0 +1,55 @@
+// Package queue provides the bounded queue used by the packages that
+// deliver events to external systems in the background.
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Queue is a bounded FIFO queue of items waiting to be delivered. Adding
+// an item never blocks: it is dropped, and counted by Dropped, if the
+// queue is full. The zero value is a queue with no capacity, which is set
+// by the first call to Init.
+type Queue[T any] struct {
+	once    sync.Once
+	ch      chan T
+	dropped atomic.Uint64
+}
+
+// Init sets the capacity of the queue to size, or to defaultSize if size
+// isn't positive, and returns the queue. Only the first call has any
+// effect, so it can be called before each use of the queue, by owners
+// whose queue size is configured after they are created.
+func (q *Queue[T]) Init(size, defaultSize int) *Queue[T] {
+	q.once.Do(func() {
+		if size <= 0 {
+			size = defaultSize
+		}
+		q.ch = make(chan T, size)
+	})
+	return q
+}
+
+// Add adds an item to the queue, returning false if it was dropped
+// because the queue was full.
+func (q *Queue[T]) Add(item T) bool {
+	select {
+	case q.ch <- item:
+		return true
+	default:
+		q.dropped.Add(1)
+		return false
+	}
+}
+
+// C returns the channel from which queued items are received, in the
+// order they were added.
+func (q *Queue[T]) C() <-chan T {
+	return q.ch
+}
+
+// Dropped returns the number of items dropped because the queue was full.
+func (q *Queue[T]) Dropped() uint64 {
+	return q.dropped.Load()
+}
@@ -0,0 +1,23 @@
+package queue
+
+import "testing"
+
+func TestQueue(t *testing.T) {
+	var q Queue[int]
+	q.Init(0, 2)
+	q.Init(10, 10)
+
+	for i := 0; i < 3; i++ {
+		if got, want := q.Add(i), i < 2; got != want {
+			t.Errorf("test %d got %v; want %v", i, got, want)
+		}
+	}
+	if got := q.Dropped(); got != 1 {
+		t.Errorf("dropped %d items; want 1", got)
+	}
+	for want := 0; want < 2; want++ {
+		if got := <-q.C(); got != want {
+			t.Errorf("received %d; want %d", got, want)
+		}
+	}
+}
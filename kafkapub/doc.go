@@ -0,0 +1,23 @@
+// Package kafkapub publishes the events of OpenVPN processes to Kafka, so
+// that the events of large fleets of servers can feed analytics pipelines.
+//
+// A Producer queues each event as a message whose value is the event
+// encoded by openvpn.MarshalEvent, and whose key is the instance it came
+// from or the common name of the client it concerns, so that the events
+// for each are kept in order on a single partition by a hashing balancer.
+// Its Run method writes the queued messages in batches:
+//
+//	prod := &kafkapub.Producer{
+//		Writer: &kafka.Writer{
+//			Addr:         kafka.TCP("kafka:9092"),
+//			Balancer:     &kafka.Hash{},
+//			BatchTimeout: 10 * time.Millisecond,
+//		},
+//		Topic:    "openvpn.events",
+//		Instance: "vpn1",
+//	}
+//	go prod.Run(ctx)
+//
+// The producer's HandleEvent method should then be passed each event
+// received from the client's event channel.
+package kafkapub
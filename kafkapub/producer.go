@@ -0,0 +1,226 @@
+package kafkapub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/internal/queue"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/segmentio/kafka-go"
+)
+
+// Defaults for the fields of a Producer.
+const (
+	DefaultBatchSize    = 100
+	DefaultBatchTimeout = time.Second
+	DefaultQueueSize    = 4096
+)
+
+// Headers set on each message, naming the instance the event came from
+// and the type of the event.
+const (
+	InstanceHeader  = "openvpn-instance"
+	EventTypeHeader = "openvpn-event-type"
+)
+
+// Writer writes messages to Kafka. It is implemented by *kafka.Writer.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// PartitionKey selects the key of each message, which determines its
+// partition.
+type PartitionKey int
+
+const (
+	// ByInstance keys each message by the producer's instance, so that
+	// all of the events of a server are kept in order.
+	ByInstance PartitionKey = iota
+
+	// ByCommonName keys the messages for events concerning a client of
+	// a server by the client's common name, so that the events of each
+	// client are kept in order while those of a busy server are spread
+	// across partitions. Other messages are keyed by the instance.
+	ByCommonName
+)
+
+// Producer publishes events to Kafka. The fields must not be changed once
+// it is in use.
+type Producer struct {
+	Writer Writer
+
+	// Topic is the topic of each message, in which "{type}" is replaced
+	// with the type of the event, such as "StateEvent". If empty, the
+	// messages have no topic, and the Writer must have one.
+	Topic string
+
+	// Instance identifies the server or tunnel the events come from.
+	Instance string
+
+	// PartitionBy selects the key of each message.
+	PartitionBy PartitionKey
+
+	// Registry, if set, is used with ByCommonName to find the common names
+	// of the clients of per-client ByteCountEvents, which give only the
+	// client id.
+	Registry *openvpn.ClientRegistry
+
+	// Filter, if set, selects the events to publish.
+	Filter openvpn.EventFilter
+
+	// BatchSize is the largest number of messages written at once, and
+	// BatchTimeout how long to wait for a batch to fill before writing it
+	// regardless.
+	BatchSize    int
+	BatchTimeout time.Duration
+
+	// QueueSize is the number of messages queued to be written, beyond
+	// which events are dropped, as counted by Dropped.
+	QueueSize int
+
+	// OnDeliveryFailure, if set, is called with each message that could
+	// not be written, and the reason, such as to log it or to write it
+	// elsewhere.
+	OnDeliveryFailure func(msg kafka.Message, err error)
+
+	pending queue.Queue[kafka.Message]
+}
+
+func (p *Producer) queue() *queue.Queue[kafka.Message] {
+	return p.pending.Init(p.QueueSize, DefaultQueueSize)
+}
+
+// HandleEvent encodes the given event as a message and queues it to be
+// written by Run, if it is accepted by the filter. It never blocks,
+// dropping the message if the queue is full. A message that can't be
+// encoded is passed to OnDeliveryFailure. The event isn't retained, so it
+// may be released with openvpn.ReleaseEvent once HandleEvent returns.
+func (p *Producer) HandleEvent(e openvpn.Event) {
+	if p.Filter != nil && !p.Filter(e) {
+		return
+	}
+	msg, err := p.Message(e, time.Now())
+	if err != nil {
+		if p.OnDeliveryFailure != nil {
+			p.OnDeliveryFailure(msg, err)
+		}
+		return
+	}
+	p.queue().Add(msg)
+}
+
+// Dropped returns the number of events dropped because the queue was
+// full.
+func (p *Producer) Dropped() uint64 {
+	return p.pending.Dropped()
+}
+
+// Message returns the message publishing an event that happened at the
+// given time.
+func (p *Producer) Message(e openvpn.Event, t time.Time) (kafka.Message, error) {
+	typeName := openvpn.EventTypeName(e)
+	msg := kafka.Message{
+		Topic: strings.ReplaceAll(p.Topic, "{type}", typeName),
+		Key:   []byte(p.key(e)),
+		Time:  t,
+		Headers: []kafka.Header{
+			{Key: InstanceHeader, Value: []byte(p.Instance)},
+			{Key: EventTypeHeader, Value: []byte(typeName)},
+		},
+	}
+	var err error
+	msg.Value, err = openvpn.MarshalEvent(e)
+	return msg, err
+}
+
+// key returns the key of the message publishing an event.
+func (p *Producer) key(e openvpn.Event) string {
+	if p.PartitionBy != ByCommonName {
+		return p.Instance
+	}
+	var cn string
+	switch e := e.(type) {
+	case *openvpn.ClientEvent:
+		cn = e.Env().Get("common_name")
+		if cn == "" && p.Registry != nil {
+			cc, _ := p.Registry.Client(e.ClientID())
+			cn = cc.CommonName
+		}
+	case *openvpn.ByteCountEvent:
+		if cid, err := strconv.ParseInt(e.ClientId(), 10, 64); err == nil && p.Registry != nil {
+			cc, _ := p.Registry.Client(cid)
+			cn = cc.CommonName
+		}
+	case *openvpn.DuplicateClientEvent:
+		cn = e.CommonName
+	}
+	if cn == "" {
+		return p.Instance
+	}
+	return cn
+}
+
+// Run writes queued messages in batches, in the order they were queued,
+// until ctx is cancelled, returning ctx.Err(). Messages still queued are
+// then discarded.
+func (p *Producer) Run(ctx context.Context) error {
+	queued := p.queue().C()
+	size := p.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	timeout := p.BatchTimeout
+	if timeout <= 0 {
+		timeout = DefaultBatchTimeout
+	}
+
+	batch := make([]kafka.Message, 0, size)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg := <-queued:
+			batch = append(batch[:0], msg)
+		}
+
+		timer := time.NewTimer(timeout)
+	fill:
+		for len(batch) < size {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case msg := <-queued:
+				batch = append(batch, msg)
+			case <-timer.C:
+				break fill
+			}
+		}
+		timer.Stop()
+		p.write(ctx, batch)
+	}
+}
+
+// write writes a batch of messages, reporting those that could not be
+// written.
+func (p *Producer) write(ctx context.Context, batch []kafka.Message) {
+	err := p.Writer.WriteMessages(ctx, batch...)
+	if err == nil || ctx.Err() != nil || p.OnDeliveryFailure == nil {
+		return
+	}
+	var errs kafka.WriteErrors
+	if errors.As(err, &errs) && len(errs) == len(batch) {
+		for i, err := range errs {
+			if err != nil {
+				p.OnDeliveryFailure(batch[i], err)
+			}
+		}
+		return
+	}
+	for _, msg := range batch {
+		p.OnDeliveryFailure(msg, err)
+	}
+}
@@ -0,0 +1,106 @@
+package kafkapub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/segmentio/kafka-go"
+)
+
+type fakeWriter struct {
+	mu      sync.Mutex
+	batches [][]kafka.Message
+	fail    bool
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches = append(w.batches, append([]kafka.Message(nil), msgs...))
+	if !w.fail {
+		return nil
+	}
+	errs := make(kafka.WriteErrors, len(msgs))
+	errs[0] = errors.New("leader not available")
+	return errs
+}
+
+func (w *fakeWriter) written() [][]kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.batches
+}
+
+func TestMessage(t *testing.T) {
+	registry := &openvpn.ClientRegistry{}
+	registry.Sync([]openvpn.ClientStatus{{ClientID: 7, CommonName: "alice"}})
+
+	tests := []struct {
+		partitionBy PartitionKey
+		raw         string
+		topic, key  string
+	}{
+		{ByInstance, "BYTECOUNT_CLI:7,10,20", "openvpn.ByteCountEvent", "vpn1"},
+		{ByCommonName, "BYTECOUNT_CLI:7,10,20", "openvpn.ByteCountEvent", "alice"},
+		{ByCommonName, "BYTECOUNT_CLI:8,10,20", "openvpn.ByteCountEvent", "vpn1"},
+		{ByCommonName, "STATE:1234,CONNECTED,SUCCESS,,", "openvpn.StateEvent", "vpn1"},
+	}
+	for i, test := range tests {
+		p := &Producer{Topic: "openvpn.{type}", Instance: "vpn1", PartitionBy: test.partitionBy, Registry: registry}
+		msg, err := p.Message(openvpn.ParseEvent([]byte(test.raw)), time.Now())
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+		if msg.Topic != test.topic || string(msg.Key) != test.key {
+			t.Errorf("test %d got topic %q, key %q; want %q, %q", i, msg.Topic, msg.Key, test.topic, test.key)
+		}
+		if len(msg.Headers) != 2 || string(msg.Headers[0].Value) != "vpn1" {
+			t.Errorf("test %d got headers %v", i, msg.Headers)
+		}
+	}
+}
+
+func TestProducer(t *testing.T) {
+	w := &fakeWriter{fail: true}
+	var mu sync.Mutex
+	var failed []kafka.Message
+	p := &Producer{
+		Writer:       w,
+		Instance:     "vpn1",
+		BatchSize:    3,
+		BatchTimeout: 20 * time.Millisecond,
+		OnDeliveryFailure: func(msg kafka.Message, err error) {
+			mu.Lock()
+			failed = append(failed, msg)
+			mu.Unlock()
+		},
+	}
+	for i := 0; i < 4; i++ {
+		p.HandleEvent(openvpn.ParseEvent([]byte("BYTECOUNT:10,20")))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	failures := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(failed)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for failures() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	batches := w.written()
+	if len(batches) != 2 || len(batches[0]) != 3 || len(batches[1]) != 1 {
+		t.Fatalf("got batches %v; want batches of 3 and 1", batches)
+	}
+	if n := failures(); n != 2 {
+		t.Errorf("got %d failed messages; want 2", n)
+	}
+}
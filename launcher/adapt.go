@@ -0,0 +1,44 @@
+package launcher
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/NordSecurity/gopenvpn/config"
+)
+
+// adaptConfig writes a copy of the configuration file adapted to the
+// current platform using config.Adapt, and points opts.ConfigFile at the
+// copy. The returned function removes the copy.
+func (opts *Options) adaptConfig() (func(), error) {
+	path := opts.ConfigFile
+	if !filepath.IsAbs(path) && opts.Dir != "" {
+		path = filepath.Join(opts.Dir, path)
+	}
+	c, err := config.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config.Adapt(c, runtime.GOOS)
+
+	// The configuration may contain inline keys, so the copy is created
+	// readable only by us, as os.CreateTemp does.
+	f, err := os.CreateTemp("", "openvpn-*.conf")
+	if err != nil {
+		return nil, err
+	}
+	remove := func() { os.Remove(f.Name()) }
+
+	_, err = c.WriteTo(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		remove()
+		return nil, err
+	}
+
+	opts.ConfigFile = f.Name()
+	return remove, nil
+}
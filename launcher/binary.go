@@ -0,0 +1,100 @@
+package launcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrBinaryNotFound is returned by FindBinary if no OpenVPN executable can
+// be found.
+var ErrBinaryNotFound = errors.New("OpenVPN executable not found")
+
+// ChecksumError is returned by VerifyBinary if an executable does not have
+// the expected checksum.
+type ChecksumError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s has SHA-256 %s; expected %s", e.Path, e.Actual, e.Expected)
+}
+
+// FindBinary locates the OpenVPN executable, first by searching the PATH
+// environment variable and then by looking in the locations where it is
+// installed by the usual packages for the current platform. On Windows,
+// the install location recorded in the registry by the OpenVPN installer
+// is also consulted.
+//
+// Every candidate location is examined, so unlike exec.LookPath this finds
+// binaries in directories such as /usr/sbin that are often missing from
+// the PATH of unprivileged users.
+func FindBinary() (string, error) {
+	if path, err := exec.LookPath(DefaultBinary); err == nil {
+		return path, nil
+	}
+
+	for _, path := range binaryCandidates() {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	return "", ErrBinaryNotFound
+}
+
+// VerifyBinary checks that the file at the given path has the expected
+// SHA-256 checksum, given in hexadecimal, returning a *ChecksumError if
+// it does not.
+func VerifyBinary(path, sha256Hex string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if expected := strings.ToLower(strings.TrimSpace(sha256Hex)); actual != expected {
+		return &ChecksumError{Path: path, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// resolveBinary locates the binary if Binary is empty, and verifies its
+// checksum if BinarySHA256 is set. Binary is replaced by the absolute path
+// of the binary, as found on PATH if it is a bare name, so that the binary
+// verified is the one run.
+func (opts *Options) resolveBinary() error {
+	if opts.Binary == "" {
+		path, err := FindBinary()
+		if err != nil {
+			return err
+		}
+		opts.Binary = path
+	}
+	path, err := exec.LookPath(opts.Binary)
+	if err != nil {
+		return err
+	}
+	if opts.Binary, err = filepath.Abs(path); err != nil {
+		return err
+	}
+
+	if opts.BinarySHA256 != "" {
+		return VerifyBinary(opts.Binary, opts.BinarySHA256)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package launcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestVerifyBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openvpn")
+	if err := os.WriteFile(path, []byte("hello\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	const helloSHA256 = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	tests := []struct {
+		sum      string
+		mismatch bool
+	}{
+		{helloSHA256, false},
+		{"5891B5B522D5DF086D0FF0B110FBD9D21BB4FC7163AF34D08286A2E846F6BE03", false},
+		{" " + helloSHA256 + "\n", false},
+		{"0000000000000000000000000000000000000000000000000000000000000000", true},
+	}
+
+	for i, test := range tests {
+		err := VerifyBinary(path, test.sum)
+		var sumErr *ChecksumError
+		if got := errors.As(err, &sumErr); got != test.mismatch {
+			t.Errorf("test %d got error %v; want mismatch %v", i, err, test.mismatch)
+			continue
+		}
+		if sumErr != nil && sumErr.Actual != helloSHA256 {
+			t.Errorf("test %d got actual %q; want %q", i, sumErr.Actual, helloSHA256)
+		}
+	}
+
+	if err := VerifyBinary(filepath.Join(t.TempDir(), "missing"), helloSHA256); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file got %v; want not exist error", err)
+	}
+}
+
+func TestResolveBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("executables need an extension on Windows")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "openvpn")
+	if err := os.WriteFile(path, []byte("hello\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	const helloSHA256 = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	opts := Options{Binary: "openvpn", BinarySHA256: helloSHA256}
+	if err := opts.resolveBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Binary != path {
+		t.Errorf("resolved binary to %q; want %q", opts.Binary, path)
+	}
+
+	opts = Options{Binary: "missing-openvpn", BinarySHA256: helloSHA256}
+	if err := opts.resolveBinary(); err == nil {
+		t.Errorf("resolved a binary not on PATH")
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+
+package launcher
+
+import (
+	"runtime"
+)
+
+func binaryCandidates() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{
+			"/opt/homebrew/sbin/openvpn",
+			"/usr/local/sbin/openvpn",
+			"/opt/local/sbin/openvpn",
+		}
+	case "freebsd", "dragonfly", "openbsd", "netbsd":
+		return []string{
+			"/usr/local/sbin/openvpn",
+			"/usr/pkg/sbin/openvpn",
+			"/usr/sbin/openvpn",
+		}
+	default:
+		return []string{
+			"/usr/sbin/openvpn",
+			"/sbin/openvpn",
+			"/usr/local/sbin/openvpn",
+			"/usr/bin/openvpn",
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package launcher
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+func binaryCandidates() []string {
+	var ret []string
+
+	// The OpenVPN installer records its location under this key, both as
+	// the key's default value and, in newer versions, as exe_path.
+	if k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\OpenVPN`, registry.QUERY_VALUE); err == nil {
+		if path, _, err := k.GetStringValue("exe_path"); err == nil && path != "" {
+			ret = append(ret, path)
+		}
+		if dir, _, err := k.GetStringValue(""); err == nil && dir != "" {
+			ret = append(ret, filepath.Join(dir, "bin", "openvpn.exe"))
+		}
+		k.Close()
+	}
+
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+		if dir := os.Getenv(env); dir != "" {
+			ret = append(ret, filepath.Join(dir, "OpenVPN", "bin", "openvpn.exe"))
+		}
+	}
+	return ret
+}
@@ -0,0 +1,13 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package launcher
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+func spawnCredential(userName, groupName string) (*syscall.SysProcAttr, error) {
+	return nil, fmt.Errorf("SpawnUser and SpawnGroup are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,69 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package launcher
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+func spawnCredential(userName, groupName string) (*syscall.SysProcAttr, error) {
+	cred := &syscall.Credential{
+		Uid: uint32(syscall.Getuid()),
+		Gid: uint32(syscall.Getgid()),
+	}
+
+	if userName != "" {
+		u, err := lookupUser(userName)
+		if err != nil {
+			return nil, err
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid %q for user %q", u.Uid, userName)
+		}
+		cred.Uid = uint32(uid)
+
+		// Unless a group is given explicitly, the process runs with
+		// the primary group of the selected user.
+		gid, err := strconv.ParseUint(u.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid %q for user %q", u.Gid, userName)
+		}
+		cred.Gid = uint32(gid)
+	}
+
+	if groupName != "" {
+		g, err := lookupGroup(groupName)
+		if err != nil {
+			return nil, err
+		}
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid %q for group %q", g.Gid, groupName)
+		}
+		cred.Gid = uint32(gid)
+	}
+
+	// Supplementary groups are not inherited from the caller, since a
+	// privileged caller is likely to have groups the target does not.
+	cred.Groups = []uint32{}
+
+	return &syscall.SysProcAttr{Credential: cred}, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return user.LookupId(name)
+	}
+	return user.Lookup(name)
+}
+
+func lookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return user.LookupGroupId(name)
+	}
+	return user.LookupGroup(name)
+}
@@ -0,0 +1,252 @@
+package launcher
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// DefaultDiagnosticsDepth is the number of events and log lines retained for
+// crash diagnostics when Supervisor.DiagnosticsDepth is zero.
+const DefaultDiagnosticsDepth = 100
+
+// DefaultDiagnosticsBytes is the most bytes of log lines retained for crash
+// diagnostics when Supervisor.DiagnosticsBytes is zero.
+const DefaultDiagnosticsBytes = 1 << 20
+
+// redactedBlocks are the inline configuration blocks whose contents are
+// secret and so are omitted from diagnostics.
+var redactedBlocks = map[string]bool{
+	"key":                  true,
+	"secret":               true,
+	"pkcs12":               true,
+	"tls-auth":             true,
+	"tls-crypt":            true,
+	"tls-crypt-v2":         true,
+	"auth-user-pass":       true,
+	"http-proxy-user-pass": true,
+}
+
+// Diagnostics describes the circumstances of an OpenVPN process exiting
+// unexpectedly, to help with working out why it did so.
+type Diagnostics struct {
+	// Time is when the exit was detected.
+	Time time.Time
+
+	// Pid is the process id of the process that exited.
+	Pid int
+
+	// Args are the command line arguments the process was started with.
+	// They are not known for an adopted process.
+	Args []string
+
+	// ExitCode is the exit status of the process, or -1 if it was killed
+	// by a signal or its status is unknown.
+	ExitCode int
+
+	// ExitSignal is the name of the signal that killed the process, if any.
+	ExitSignal string
+
+	// ExitErr is the error returned by Process.Wait.
+	ExitErr error
+
+	// Events are the most recent management events received from the
+	// process, oldest first.
+	Events []RecordedEvent
+
+	// Log are the most recent lines written by the process to its standard
+	// output and standard error, along with any log messages received as
+	// management events, oldest first.
+	Log []string
+
+	// Config is the content of the process's configuration file, with
+	// the contents of inline blocks containing secrets removed. It is
+	// empty if the configuration file could not be read.
+	Config string
+}
+
+// RecordedEvent is a management event along with the time it was received.
+type RecordedEvent struct {
+	Time  time.Time
+	Event openvpn.Event
+}
+
+// recorder retains the recent history of a supervised process for use in
+// diagnostics. It is an io.Writer so that it can capture process output.
+type recorder struct {
+	mu       sync.Mutex
+	depth    int
+	maxBytes int
+	events   []RecordedEvent
+	lines    []string
+	size     int
+	partial  []byte
+}
+
+func newRecorder(depth, maxBytes int) *recorder {
+	if depth <= 0 {
+		depth = DefaultDiagnosticsDepth
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultDiagnosticsBytes
+	}
+	return &recorder{depth: depth, maxBytes: maxBytes}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf := append(r.partial, p...)
+	for {
+		nlIdx := bytes.IndexByte(buf, '\n')
+		if nlIdx == -1 {
+			break
+		}
+		r.addLine(string(bytes.TrimRight(buf[:nlIdx], "\r")))
+		buf = buf[nlIdx+1:]
+	}
+	// A line that never ends is cut short, rather than buffered without
+	// limit.
+	if len(buf) > r.maxBytes {
+		r.addLine(string(buf[:r.maxBytes]))
+		buf = nil
+	}
+	r.partial = append([]byte(nil), buf...)
+	return len(p), nil
+}
+
+func (r *recorder) recordEvent(e openvpn.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The event is also passed on to the supervisor's consumer, which
+	// may release it, so a copy is kept.
+	r.events = append(r.events, RecordedEvent{Time: time.Now(), Event: openvpn.CopyEvent(e)})
+	if len(r.events) > r.depth {
+		r.events = r.events[len(r.events)-r.depth:]
+	}
+	if log, ok := e.(*openvpn.LogEvent); ok {
+		r.addLine(log.Message())
+	}
+}
+
+// addLine must be called with r.mu held.
+func (r *recorder) addLine(line string) {
+	if len(line) > r.maxBytes {
+		line = line[:r.maxBytes]
+	}
+	r.lines = append(r.lines, line)
+	r.size += len(line)
+	drop := 0
+	for len(r.lines)-drop > r.depth || r.size > r.maxBytes {
+		r.size -= len(r.lines[drop])
+		drop++
+	}
+	if drop > 0 {
+		r.lines = append(r.lines[:0], r.lines[drop:]...)
+	}
+}
+
+// diagnostics assembles a Diagnostics for the given exited process.
+func (r *recorder) diagnostics(p *Process) *Diagnostics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := &Diagnostics{
+		Time:     time.Now(),
+		Pid:      p.Pid(),
+		ExitCode: -1,
+		ExitErr:  p.Wait(),
+		Events:   append([]RecordedEvent(nil), r.events...),
+		Log:      append([]string(nil), r.lines...),
+	}
+	if len(r.partial) > 0 {
+		d.Log = append(d.Log, string(r.partial))
+	}
+
+	if !p.Adopted() {
+		d.Args, _ = p.opts.args(p.opts.Capabilities)
+		d.ExitCode, d.ExitSignal = exitDetails(d.ExitErr)
+	}
+
+	if path := p.opts.ConfigFile; path != "" {
+		if !filepath.IsAbs(path) && p.opts.Dir != "" {
+			path = filepath.Join(p.opts.Dir, path)
+		}
+		if raw, err := os.ReadFile(path); err == nil {
+			d.Config = redactConfig(string(raw))
+		}
+	}
+
+	return d
+}
+
+// exitDetails extracts the exit status and signal from the error returned
+// by exec.Cmd.Wait.
+func exitDetails(err error) (code int, signal string) {
+	if err == nil {
+		return 0, ""
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return -1, ""
+	}
+
+	// syscall.WaitStatus has these methods on all of the platforms that
+	// define it, but it isn't defined everywhere.
+	type signaled interface {
+		Signaled() bool
+		Signal() syscall.Signal
+	}
+	if ws, ok := exitErr.Sys().(signaled); ok && ws.Signaled() {
+		return -1, ws.Signal().String()
+	}
+	return exitErr.ExitCode(), ""
+}
+
+// redactConfig removes the contents of inline blocks containing secrets
+// from an OpenVPN configuration file.
+func redactConfig(config string) string {
+	var b strings.Builder
+	redacting := ""
+	for _, line := range strings.SplitAfter(config, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if redacting != "" {
+			if trimmed == "</"+redacting+">" {
+				b.WriteString("[redacted]\n")
+				b.WriteString(line)
+				redacting = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "<") && strings.HasSuffix(trimmed, ">") && !strings.HasPrefix(trimmed, "</") {
+			if name := trimmed[1 : len(trimmed)-1]; redactedBlocks[name] {
+				redacting = name
+			}
+		}
+		b.WriteString(line)
+	}
+	if redacting != "" {
+		b.WriteString("[redacted]\n")
+	}
+	return b.String()
+}
+
+// teeWriter returns a writer that writes to both w and r, or only to r if
+// w is nil.
+func teeWriter(w io.Writer, r *recorder) io.Writer {
+	if w == nil {
+		return r
+	}
+	return io.MultiWriter(w, r)
+}
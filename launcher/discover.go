@@ -0,0 +1,247 @@
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// DefaultSocketPatterns are glob patterns matching the locations where
+// OpenVPN management sockets are conventionally created by distribution
+// packaging and service units.
+var DefaultSocketPatterns = []string{
+	"/run/openvpn/*.sock",
+	"/run/openvpn-client/*.sock",
+	"/run/openvpn-server/*.sock",
+	"/var/run/openvpn/*.sock",
+}
+
+// Instance describes an OpenVPN process that may already be running
+// on the system, as found by one of the discovery functions.
+type Instance struct {
+	// Pid is the process id, or zero if it is not yet known.
+	Pid int
+
+	// Args are the command line arguments of the process, excluding the
+	// program name, or nil if they are not known.
+	Args []string
+
+	// ManagementAddr is the address of the process's management interface,
+	// using the same conventions as Options.ManagementAddr, or the empty
+	// string if the process has no management interface or its address is
+	// not known.
+	ManagementAddr string
+
+	// ConfigFile is the configuration file given on the command line,
+	// if any.
+	ConfigFile string
+
+	// NetNS is the network namespace the management interface must be
+	// reached from, if not the caller's own. Discovery functions never
+	// populate this field, but a caller may set it before calling Attach.
+	NetNS string
+}
+
+// FindByPidFile locates an OpenVPN process using a pid file, such as the
+// one written by OpenVPN's --writepid option.
+//
+// The command line of the process is retrieved from the process table
+// where possible, in order to populate the other fields of the result.
+func FindByPidFile(path string) (*Instance, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil || pid <= 0 {
+		return nil, fmt.Errorf("%s does not contain a valid pid", path)
+	}
+
+	insts, err := FindProcesses()
+	if err == nil {
+		for _, inst := range insts {
+			if inst.Pid == pid {
+				return inst, nil
+			}
+		}
+	}
+
+	// We can still try to attach using a management address the caller
+	// knows by other means, so a missing process table entry isn't fatal.
+	return &Instance{Pid: pid}, nil
+}
+
+// FindProcesses searches the process table for running OpenVPN processes.
+//
+// The management address of each process is determined from its command
+// line, so it is not populated for processes whose management interface
+// is configured only in a configuration file.
+func FindProcesses() ([]*Instance, error) {
+	procs, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*Instance
+	for _, proc := range procs {
+		if len(proc.argv) == 0 || filepath.Base(proc.argv[0]) != DefaultBinary {
+			continue
+		}
+		inst := &Instance{Pid: proc.pid}
+		inst.setArgs(proc.argv[1:])
+		ret = append(ret, inst)
+	}
+	return ret, nil
+}
+
+// FindSockets returns instances for each of the Unix domain sockets
+// matching the given glob patterns, or DefaultSocketPatterns if none are
+// given. The pid of each instance is not known until it is attached.
+func FindSockets(patterns ...string) ([]*Instance, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultSocketPatterns
+	}
+
+	var ret []*Instance
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.Mode()&os.ModeSocket == 0 {
+				continue
+			}
+			ret = append(ret, &Instance{ManagementAddr: match})
+		}
+	}
+	return ret, nil
+}
+
+// setArgs populates the instance from an OpenVPN command line.
+func (inst *Instance) setArgs(args []string) {
+	inst.Args = args
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--config":
+			if i+1 < len(args) {
+				inst.ConfigFile = args[i+1]
+			}
+		case "--management":
+			if i+2 < len(args) {
+				if args[i+2] == "unix" {
+					inst.ManagementAddr = args[i+1]
+				} else if strings.ContainsRune(args[i+1], ':') {
+					inst.ManagementAddr = "[" + args[i+1] + "]:" + args[i+2]
+				} else {
+					inst.ManagementAddr = args[i+1] + ":" + args[i+2]
+				}
+			}
+		}
+	}
+
+	// OpenVPN also accepts a config file as its only argument.
+	if inst.ConfigFile == "" && len(args) == 1 && !strings.HasPrefix(args[0], "--") {
+		inst.ConfigFile = args[0]
+	}
+}
+
+// Attach connects to the management interface of an already-running
+// OpenVPN process and verifies that it is the expected process, returning
+// a Process representing it.
+//
+// If inst.Pid is set then it must match the pid reported over the
+// management interface. On success, the management connection used for
+// verification is retained by the returned Process so that it can be
+// claimed by a Supervisor, or by the caller using Process.Client, without
+// disconnecting and potentially disturbing the tunnel.
+//
+// If ctx is cancelled before verification completes then the management
+// connection is abandoned and an error is returned.
+func Attach(ctx context.Context, inst *Instance) (*Process, error) {
+	if inst.ManagementAddr == "" {
+		return nil, fmt.Errorf("instance has no known management address")
+	}
+
+	conn, err := DialNetNS(inst.NetNS, inst.ManagementAddr)
+	if err != nil {
+		return nil, err
+	}
+	eventCh := make(chan openvpn.Event, attachEventBuffer)
+	client := openvpn.NewClient(conn, eventCh)
+
+	verified := make(chan struct{})
+	defer close(verified)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-verified:
+		}
+	}()
+
+	pid, err := client.Pid()
+	if err == nil {
+		_, err = client.Version()
+	}
+	if err == nil && inst.Pid != 0 && pid != inst.Pid {
+		err = fmt.Errorf("management interface belongs to pid %d, not %d", pid, inst.Pid)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	p := &Process{
+		opts: Options{
+			ManagementAddr: inst.ManagementAddr,
+			NetNS:          inst.NetNS,
+		},
+		proc:    proc,
+		adopted: true,
+		done:    make(chan struct{}),
+		client:  client,
+		events:  eventCh,
+	}
+	go func() {
+		watchExit(proc)
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// attachEventBuffer is the depth of the event channel created for the
+// management connection retained by Attach, which must be sufficient to
+// hold any events that arrive before the connection is claimed.
+const attachEventBuffer = 64
+
+// Client returns the management connection retained by Attach, along with
+// its event channel, transferring responsibility for both to the caller.
+// It returns nil if the process was not adopted or if the connection has
+// already been claimed.
+func (p *Process) Client() (*openvpn.MgmtClient, <-chan openvpn.Event) {
+	client, events := p.client, p.events
+	p.client, p.events = nil, nil
+	return client, events
+}
+
+type processEntry struct {
+	pid  int
+	argv []string
+}
@@ -0,0 +1,147 @@
+package launcher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInstanceSetArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantAddr string
+		wantConf string
+	}{
+		{
+			args:     []string{"--config", "/etc/openvpn/client.conf", "--management", "127.0.0.1", "7505"},
+			wantAddr: "127.0.0.1:7505",
+			wantConf: "/etc/openvpn/client.conf",
+		},
+		{
+			args:     []string{"--management", "::1", "7505"},
+			wantAddr: "[::1]:7505",
+		},
+		{
+			args:     []string{"--management", "/run/openvpn/client.sock", "unix", "--daemon"},
+			wantAddr: "/run/openvpn/client.sock",
+		},
+		{
+			args:     []string{"client.conf"},
+			wantConf: "client.conf",
+		},
+		{
+			args: []string{"--management"},
+		},
+	}
+
+	for i, test := range tests {
+		inst := &Instance{}
+		inst.setArgs(test.args)
+
+		if !reflect.DeepEqual(inst.Args, test.args) {
+			t.Errorf("test %d Args is %q; want %q", i, inst.Args, test.args)
+		}
+		if got, want := inst.ManagementAddr, test.wantAddr; got != want {
+			t.Errorf("test %d ManagementAddr is %q; want %q", i, got, want)
+		}
+		if got, want := inst.ConfigFile, test.wantConf; got != want {
+			t.Errorf("test %d ConfigFile is %q; want %q", i, got, want)
+		}
+	}
+}
+
+func TestFindByPidFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "openvpn.pid")
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := FindByPidFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Pid != os.Getpid() {
+		t.Errorf("Pid is %d; want %d", inst.Pid, os.Getpid())
+	}
+
+	if err := os.WriteFile(path, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindByPidFile(path); err == nil {
+		t.Errorf("invalid pid file was accepted")
+	}
+}
+
+func TestAttach(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "mgmt.sock")
+	l, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %s", err)
+	}
+	defer l.Close()
+	go serveFakeManagement(l, os.Getpid())
+
+	socks, err := FindSockets(filepath.Join(filepath.Dir(addr), "*.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(socks) != 1 || socks[0].ManagementAddr != addr {
+		t.Fatalf("FindSockets returned %v; want %s", socks, addr)
+	}
+
+	if _, err := Attach(context.Background(), &Instance{ManagementAddr: addr, Pid: 1}); err == nil {
+		t.Errorf("attached to instance with the wrong pid")
+	}
+
+	p, err := Attach(context.Background(), socks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Adopted() {
+		t.Errorf("attached process is not adopted")
+	}
+	if p.Pid() != os.Getpid() {
+		t.Errorf("Pid is %d; want %d", p.Pid(), os.Getpid())
+	}
+
+	client, events := p.Client()
+	if client == nil || events == nil {
+		t.Fatalf("attached process has no management connection")
+	}
+	if client, _ := p.Client(); client != nil {
+		t.Errorf("management connection was claimed twice")
+	}
+	client.Close()
+}
+
+// serveFakeManagement answers just enough of the management protocol to
+// satisfy Attach.
+func serveFakeManagement(l net.Listener, pid int) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			fmt.Fprintf(conn, ">INFO:OpenVPN Management Interface Version 5 -- type 'help' for more info\n")
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				switch scanner.Text() {
+				case "pid":
+					fmt.Fprintf(conn, "SUCCESS: pid=%d\n", pid)
+				case "version":
+					fmt.Fprintf(conn, "OpenVPN Version: OpenVPN 2.6.3 x86_64-pc-linux-gnu\nManagement Interface Version: 5\nEND\n")
+				default:
+					fmt.Fprintf(conn, "ERROR: unknown command\n")
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,10 @@
+// Package launcher starts OpenVPN daemon processes on behalf of a management
+// program.
+//
+// The launcher is deliberately thin: it is responsible for constructing the
+// command line, spawning the process with the requested operating system
+// attributes and reporting on its exit. Communicating with the running
+// daemon is the job of the management client in package openvpn, which can
+// connect to the management interface that the launcher asks OpenVPN
+// to expose.
+package launcher
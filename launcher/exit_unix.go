@@ -0,0 +1,30 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package launcher
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// exitPollInterval is how often watchExit checks whether an adopted
+// process is still running.
+const exitPollInterval = time.Second
+
+// watchExit blocks until the given process exits.
+//
+// Unix systems only allow a process to wait for its own children, so
+// adopted processes are instead polled using the null signal.
+func watchExit(proc *os.Process) {
+	for {
+		err := proc.Signal(syscall.Signal(0))
+		if errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH) {
+			return
+		}
+		// EPERM means the process exists but belongs to another user,
+		// which is expected if OpenVPN has dropped its privileges.
+		time.Sleep(exitPollInterval)
+	}
+}
@@ -0,0 +1,98 @@
+package launcher
+
+import (
+	"fmt"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// SetTags replaces the tags of the named tunnel, which are attached to its
+// events and status and can be used to select tunnels in Fleet, for
+// example to group them by region or role.
+func (p *Pool) SetTags(name string, tags map[string]string) error {
+	copied := make(map[string]string, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, exists := p.tunnels[name]
+	if !exists {
+		return fmt.Errorf("no tunnel named %q", name)
+	}
+	t.tags = copied
+	return nil
+}
+
+// FleetStatus summarizes a set of the tunnels in a Pool, as returned by
+// Pool.Fleet.
+type FleetStatus struct {
+	// Tunnels is the number of tunnels in the set, and Connected the
+	// number of those in the CONNECTED state.
+	Tunnels   int
+	Connected int
+
+	// NotConnected lists the names of the tunnels that are not in the
+	// CONNECTED state, in the order they were added to the pool.
+	NotConnected []string
+
+	// ConnectedClients is the total number of clients connected to those
+	// tunnels that are servers, which requires them to be running with
+	// --management-client-auth.
+	ConnectedClients int
+
+	// Throughput is the total current data transfer rate of the tunnels,
+	// including the clients of servers, which requires byte count events
+	// to be enabled, such as with Supervisor.ByteCountInterval.
+	Throughput openvpn.Rate
+}
+
+// Fleet summarizes the tunnels that have all of the given tags, or all of
+// the tunnels if tags is empty, for use by controllers managing many
+// daemons.
+func (p *Pool) Fleet(tags map[string]string) FleetStatus {
+	p.mu.Lock()
+	var selected []*poolTunnel
+	for _, name := range p.order {
+		t := p.tunnels[name]
+		if hasTags(t.tags, tags) {
+			selected = append(selected, t)
+		}
+	}
+	states := make([]string, len(selected))
+	for i, t := range selected {
+		states[i] = t.state
+	}
+	p.mu.Unlock()
+
+	var fs FleetStatus
+	for i, t := range selected {
+		fs.Tunnels++
+		if states[i] == string(openvpn.StateConnected) {
+			fs.Connected++
+		} else {
+			fs.NotConnected = append(fs.NotConnected, t.name)
+		}
+		fs.ConnectedClients += t.clients.Len()
+
+		if r, ok := t.rates.Tunnel(); ok {
+			fs.Throughput.In += r.Current.In
+			fs.Throughput.Out += r.Current.Out
+		}
+		for _, r := range t.rates.Clients() {
+			fs.Throughput.In += r.Current.In
+			fs.Throughput.Out += r.Current.Out
+		}
+	}
+	return fs
+}
+
+func hasTags(have, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := have[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
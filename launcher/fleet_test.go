@@ -0,0 +1,59 @@
+package launcher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestPoolFleet(t *testing.T) {
+	p := &Pool{}
+	for _, name := range []string{"eu-1", "eu-2", "us-1"} {
+		if err := p.Add(name, Options{ManagementAddr: "/run/" + name + ".sock"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.SetTags("eu-1", map[string]string{"region": "eu"})
+	p.SetTags("eu-2", map[string]string{"region": "eu"})
+	p.SetTags("us-1", map[string]string{"region": "us"})
+	if err := p.SetTags("ap-1", nil); err == nil {
+		t.Errorf("tagging unknown tunnel succeeded")
+	}
+
+	feed := func(name string, raws ...string) {
+		for _, raw := range raws {
+			te := p.handleEvent(p.tunnels[name], openvpn.ParseEvent([]byte(raw)))
+			if te.Tunnel != name || te.Tags["region"] == "" {
+				t.Errorf("event from %s tagged as %s %v", name, te.Tunnel, te.Tags)
+			}
+		}
+	}
+	feed("eu-1", "STATE:1,CONNECTED,SUCCESS,10.8.0.1,", "CLIENT:CONNECT,1,0", "CLIENT:CONNECT,2,0")
+	feed("eu-2", "STATE:1,RECONNECTING,ping-restart,,")
+	feed("us-1", "STATE:1,CONNECTED,SUCCESS,10.9.0.1,", "CLIENT:CONNECT,1,0")
+
+	now := time.Now()
+	p.tunnels["eu-1"].rates.Update(1, 0, 0, now.Add(-time.Second))
+	p.tunnels["eu-1"].rates.Update(1, 1000, 2000, now)
+
+	eu := p.Fleet(map[string]string{"region": "eu"})
+	want := FleetStatus{
+		Tunnels:          2,
+		Connected:        1,
+		NotConnected:     []string{"eu-2"},
+		ConnectedClients: 2,
+		Throughput:       openvpn.Rate{In: 1000, Out: 2000},
+	}
+	if !reflect.DeepEqual(eu, want) {
+		t.Errorf("got eu fleet %+v; want %+v", eu, want)
+	}
+
+	if all := p.Fleet(nil); all.Tunnels != 3 || all.Connected != 2 || all.ConnectedClients != 3 {
+		t.Errorf("got fleet %+v; want 3 tunnels, 2 connected and 3 clients", all)
+	}
+	if got := p.Status()[2].Tags["region"]; got != "us" {
+		t.Errorf("us-1 status has region %q; want us", got)
+	}
+}
@@ -0,0 +1,394 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/NordSecurity/gopenvpn/capability"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// DefaultBinary is the name of the OpenVPN executable, as searched for by
+// FindBinary.
+const DefaultBinary = "openvpn"
+
+// Options describes how an OpenVPN process should be launched.
+type Options struct {
+	// Binary is the path to the OpenVPN executable. If empty, it is
+	// located using FindBinary.
+	Binary string
+
+	// BinarySHA256, if set, is the expected SHA-256 checksum of Binary in
+	// hexadecimal. Start refuses to launch a binary with any other
+	// checksum, returning a *ChecksumError.
+	//
+	// The check happens immediately before launching, so it cannot
+	// protect against a binary that an attacker can modify at will; it
+	// is intended to catch unexpected substitution or corruption of
+	// binaries in locations writable only by trusted users.
+	BinarySHA256 string
+
+	// ConfigFile, if set, is passed to OpenVPN using --config.
+	ConfigFile string
+
+	// ManagementAddr, if set, asks OpenVPN to expose a management interface
+	// at the given address. It uses the same conventions as openvpn.Dial:
+	// either a host and port separated by a colon, or an absolute path to
+	// a Unix domain socket.
+	ManagementAddr string
+
+	// ManagementHold, if set, asks OpenVPN to wait for a management client
+	// to release a hold before it begins connecting. This avoids missing
+	// any events between the process starting and the client connecting.
+	ManagementHold bool
+
+	// Args are additional command line arguments that are appended after
+	// those generated from the other options.
+	Args []string
+
+	// Dir is the working directory of the process. If empty, the process
+	// runs in the calling process's current directory.
+	Dir string
+
+	// Env is the environment of the process. If nil, the process inherits
+	// the environment of the calling process.
+	Env []string
+
+	// Stdout and Stderr receive the output of the process. If nil, the
+	// output is discarded. A LogFile may be used to keep the output on disk
+	// without it growing indefinitely.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// User and Group are passed to OpenVPN as --user and --group, causing
+	// the daemon to drop its privileges once initialization is complete.
+	// This is the usual choice, because OpenVPN typically needs elevated
+	// privileges to create its tun/tap device.
+	User  string
+	Group string
+
+	// Chroot is passed to OpenVPN as --chroot, causing the daemon to
+	// chroot into the given directory once initialization is complete.
+	// Paths that OpenVPN must access after initialization, such as
+	// a Unix domain management socket, are relative to this root.
+	Chroot string
+
+	// SpawnUser and SpawnGroup cause the process itself to be started with
+	// the given user and group identity, rather than inheriting the identity
+	// of the calling process. Each may be a name or a numeric id.
+	//
+	// Unlike User and Group, OpenVPN never runs with the caller's privileges
+	// at all in this case, so the given identity must already be able to
+	// perform whatever initialization the configuration requires.
+	//
+	// These options are only supported on Unix systems.
+	SpawnUser  string
+	SpawnGroup string
+
+	// NetNS, if set, causes the process to be started inside the given
+	// Linux network namespace, so that its tun device, routes and sockets
+	// all belong to that namespace. The namespace may be given either as
+	// a name managed by "ip netns" or as an absolute path to a namespace
+	// file, such as /proc/<pid>/ns/net.
+	//
+	// A TCP management interface is then reachable only from inside the
+	// namespace; Process.Dial takes care of this automatically. Callers
+	// connecting by other means may prefer to use a Unix domain socket
+	// for ManagementAddr, which is reachable from any namespace.
+	NetNS string
+
+	// DCO controls whether OpenVPN uses data channel offload, moving
+	// encryption and decryption of tunnel traffic into the kernel.
+	DCO DCOMode
+
+	// Limits constrains the resources available to the process.
+	Limits ResourceLimits
+
+	// ValidateConfig causes Start to check the configuration using
+	// Validate before launching the process, returning its ConfigErrors
+	// rather than starting a process that would immediately fail.
+	ValidateConfig bool
+
+	// AdaptConfig causes ConfigFile to be adapted to the current platform
+	// using config.Adapt, so that a profile written for one platform can
+	// be used on another. The adapted configuration is written to
+	// a temporary file, which is removed when the process exits.
+	AdaptConfig bool
+
+	// Capabilities describes the OpenVPN binary, if already known. If nil
+	// and some other option requires it, Start detects the capabilities of
+	// the binary before launching it.
+	Capabilities *capability.Capabilities
+}
+
+// DCOMode selects whether OpenVPN may use data channel offload.
+type DCOMode int
+
+const (
+	// DCOAuto leaves the decision to OpenVPN, which uses DCO when it is
+	// supported by the binary, the kernel and the configuration.
+	DCOAuto DCOMode = iota
+
+	// DCODisable prevents OpenVPN from using DCO, by passing --disable-dco
+	// to OpenVPN versions that support it.
+	DCODisable
+
+	// DCORequire causes Start to fail if the binary or the kernel lacks
+	// support for DCO. OpenVPN may still fall back to not using DCO if the
+	// configuration contains options that are incompatible with it, which
+	// the management client reports using an openvpn.DCOFallbackEvent.
+	DCORequire
+)
+
+// ErrDCOUnavailable is returned by Start when Options.DCO is DCORequire but
+// data channel offload is not available.
+var ErrDCOUnavailable = errors.New("data channel offload is not available")
+
+// managedFlags are the OpenVPN options that the launcher manages itself
+// and so which must not also appear in Options.Args.
+var managedFlags = []string{"--user", "--group", "--chroot", "--disable-dco"}
+
+// Process is an OpenVPN process, either started by Start or found running
+// and adopted using Attach.
+type Process struct {
+	opts    Options
+	proc    *os.Process
+	adopted bool
+	done    chan struct{}
+	err     error
+
+	// client and events are the management connection that was used to
+	// verify an adopted process, retained until it is claimed by
+	// a Supervisor so that the connection is not interrupted.
+	client *openvpn.MgmtClient
+	events <-chan openvpn.Event
+}
+
+// Start launches a new OpenVPN process as described by opts.
+//
+// If ctx is cancelled before the process exits then the process will
+// be killed.
+func Start(ctx context.Context, opts Options) (*Process, error) {
+	if err := opts.resolveBinary(); err != nil {
+		return nil, err
+	}
+
+	caps, err := opts.capabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts.Capabilities = caps
+
+	// runOpts is opts with the adapted configuration, if any. The process
+	// retains the original options, so that diagnostics refer to the
+	// original configuration file.
+	runOpts := opts
+	removeConfig := func() {}
+	if opts.AdaptConfig && opts.ConfigFile != "" {
+		if removeConfig, err = runOpts.adaptConfig(); err != nil {
+			return nil, err
+		}
+		runOpts.AdaptConfig = false
+	}
+	started := false
+	defer func() {
+		if !started {
+			removeConfig()
+		}
+	}()
+
+	if opts.ValidateConfig {
+		if err := Validate(ctx, runOpts); err != nil {
+			return nil, err
+		}
+	}
+
+	args, err := runOpts.args(caps)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, opts.Binary, args...)
+	cmd.Dir = opts.Dir
+	cmd.Env = opts.Env
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+
+	if !opts.Limits.isZero() {
+		if err := checkLimits(&opts.Limits); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.SpawnUser != "" || opts.SpawnGroup != "" {
+		attr, err := spawnCredential(opts.SpawnUser, opts.SpawnGroup)
+		if err != nil {
+			return nil, err
+		}
+		cmd.SysProcAttr = attr
+	}
+
+	if opts.NetNS != "" {
+		err = inNetNS(opts.NetNS, cmd.Start)
+	} else {
+		err = cmd.Start()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cleanup := func() {}
+	if !opts.Limits.isZero() {
+		cleanup, err = applyLimits(cmd.Process.Pid, &opts.Limits)
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, err
+		}
+	}
+	started = true
+
+	p := &Process{
+		opts: opts,
+		proc: cmd.Process,
+		done: make(chan struct{}),
+	}
+	go func() {
+		p.err = cmd.Wait()
+		cleanup()
+		removeConfig()
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// Pid returns the operating system process id of the process.
+func (p *Process) Pid() int {
+	return p.proc.Pid
+}
+
+// Signal sends a signal to the process.
+func (p *Process) Signal(sig os.Signal) error {
+	return p.proc.Signal(sig)
+}
+
+// Adopted returns true if the process was not started by this program but
+// was instead found running and adopted using Attach.
+func (p *Process) Adopted() bool {
+	return p.adopted
+}
+
+// Done returns a channel that is closed once the process has exited.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Dial connects to the management interface of the process, as configured
+// by Options.ManagementAddr, from within the process's network namespace.
+//
+// OpenVPN opens its management interface shortly after starting, so
+// a caller connecting immediately after Start may need to retry until
+// the connection succeeds.
+//
+// See the openvpn.NewClient docs for discussion about the requirements
+// for eventCh.
+func (p *Process) Dial(eventCh chan<- openvpn.Event) (*openvpn.MgmtClient, error) {
+	if p.opts.ManagementAddr == "" {
+		return nil, fmt.Errorf("process was started without a management interface")
+	}
+
+	conn, err := DialNetNS(p.opts.NetNS, p.opts.ManagementAddr)
+	if err != nil {
+		return nil, err
+	}
+	return openvpn.NewClient(conn, eventCh), nil
+}
+
+// Wait blocks until the process has exited and then returns the error
+// describing its exit, if any, as documented for exec.Cmd.Wait.
+//
+// The exit status of an adopted process cannot be determined, so in that
+// case Wait always returns nil.
+func (p *Process) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// capabilities returns the capabilities of the binary if they are needed
+// by any of the options, detecting them if they weren't provided.
+func (opts *Options) capabilities(ctx context.Context) (*capability.Capabilities, error) {
+	if opts.Capabilities != nil || opts.DCO == DCOAuto {
+		return opts.Capabilities, nil
+	}
+	return capability.Detect(ctx, opts.Binary)
+}
+
+// args returns the command line arguments for the process. caps may be nil
+// if no options that depend on it have been set.
+func (opts *Options) args(caps *capability.Capabilities) ([]string, error) {
+	for _, arg := range opts.Args {
+		for _, flag := range managedFlags {
+			if arg == flag {
+				return nil, fmt.Errorf("%s must be set using launcher options, not Args", flag)
+			}
+		}
+	}
+
+	var args []string
+	if opts.ConfigFile != "" {
+		args = append(args, "--config", opts.ConfigFile)
+	}
+	if opts.ManagementAddr != "" {
+		mgmt, err := managementArgs(opts.ManagementAddr)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, mgmt...)
+	}
+	if opts.ManagementHold {
+		args = append(args, "--management-hold")
+	}
+	if opts.User != "" {
+		args = append(args, "--user", opts.User)
+	}
+	if opts.Group != "" {
+		args = append(args, "--group", opts.Group)
+	}
+	if opts.Chroot != "" {
+		args = append(args, "--chroot", opts.Chroot)
+	}
+	switch opts.DCO {
+	case DCODisable:
+		// Versions that lack DCO support also lack the option to
+		// disable it, and will refuse to start if given it.
+		if caps.SupportsDirective("disable-dco") {
+			args = append(args, "--disable-dco")
+		}
+	case DCORequire:
+		if !caps.DCOAvailable() {
+			return nil, ErrDCOUnavailable
+		}
+	}
+	args = append(args, opts.Args...)
+
+	return args, nil
+}
+
+func managementArgs(addr string) ([]string, error) {
+	if addr[0] == '/' {
+		return []string{"--management", addr, "unix"}, nil
+	}
+
+	sepIdx := strings.LastIndexByte(addr, ':')
+	if sepIdx == -1 {
+		return nil, fmt.Errorf("invalid management address %q", addr)
+	}
+	host := strings.Trim(addr[:sepIdx], "[]")
+	return []string{"--management", host, addr[sepIdx+1:]}, nil
+}
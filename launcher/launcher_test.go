@@ -0,0 +1,160 @@
+package launcher
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/capability"
+)
+
+func TestOptionsArgs(t *testing.T) {
+	tests := []struct {
+		opts     Options
+		caps     *capability.Capabilities
+		wantArgs []string
+		wantErr  bool
+	}{
+		{
+			opts:     Options{},
+			wantArgs: nil,
+		},
+		{
+			opts: Options{
+				ConfigFile:     "client.conf",
+				ManagementAddr: "127.0.0.1:7505",
+				ManagementHold: true,
+			},
+			wantArgs: []string{
+				"--config", "client.conf",
+				"--management", "127.0.0.1", "7505",
+				"--management-hold",
+			},
+		},
+		{
+			opts: Options{
+				ManagementAddr: "[::1]:7505",
+			},
+			wantArgs: []string{"--management", "::1", "7505"},
+		},
+		{
+			opts: Options{
+				ManagementAddr: "/run/openvpn.sock",
+			},
+			wantArgs: []string{"--management", "/run/openvpn.sock", "unix"},
+		},
+		{
+			opts: Options{
+				ManagementAddr: "localhost",
+			},
+			wantErr: true,
+		},
+		{
+			opts: Options{
+				User:   "nobody",
+				Group:  "nogroup",
+				Chroot: "/var/empty",
+				Args:   []string{"--verb", "3"},
+			},
+			wantArgs: []string{
+				"--user", "nobody",
+				"--group", "nogroup",
+				"--chroot", "/var/empty",
+				"--verb", "3",
+			},
+		},
+		{
+			opts: Options{
+				Args: []string{"--user", "nobody"},
+			},
+			wantErr: true,
+		},
+		{
+			opts: Options{
+				DCO: DCODisable,
+			},
+			caps:     dcoCapabilities("N/A"),
+			wantArgs: []string{"--disable-dco"},
+		},
+		{
+			opts: Options{
+				DCO: DCODisable,
+			},
+			caps:     &capability.Capabilities{Version: capability.Version{Major: 2, Minor: 5}},
+			wantArgs: nil,
+		},
+		{
+			opts: Options{
+				DCO: DCORequire,
+			},
+			caps:     dcoCapabilities("0.2.20230426"),
+			wantArgs: nil,
+		},
+		{
+			opts: Options{
+				DCO: DCORequire,
+			},
+			caps:    dcoCapabilities("N/A"),
+			wantErr: true,
+		},
+	}
+
+	for i, test := range tests {
+		args, err := test.opts.args(test.caps)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("test %d succeeded; want error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d failed: %s", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("test %d args\ngot  %q\nwant %q", i, args, test.wantArgs)
+		}
+	}
+}
+
+func dcoCapabilities(dcoVersion string) *capability.Capabilities {
+	return &capability.Capabilities{
+		Version:    capability.Version{Major: 2, Minor: 6},
+		Features:   map[capability.Feature]bool{capability.FeatureDCO: true},
+		DCOVersion: dcoVersion,
+	}
+}
+
+func TestAdaptConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "client.conf"), []byte("client\nblock-outside-dns\nuser nobody\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{ConfigFile: "client.conf", Dir: dir}
+	remove, err := opts.adaptConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remove()
+
+	got, err := os.ReadFile(opts.ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "client\nuser nobody\n"
+	if runtime.GOOS == "windows" {
+		want = "client\nblock-outside-dns\n"
+	}
+	if string(got) != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	remove()
+	if _, err := os.Stat(opts.ConfigFile); !os.IsNotExist(err) {
+		t.Errorf("adapted config not removed: %v", err)
+	}
+}
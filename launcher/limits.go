@@ -0,0 +1,38 @@
+package launcher
+
+// ResourceLimits constrains the resources available to an OpenVPN process.
+//
+// The limits are applied immediately after the process starts, so there is
+// a brief window in which the process runs without them. Launching with
+// Options.ManagementHold ensures that OpenVPN does nothing of consequence
+// during that window.
+type ResourceLimits struct {
+	// NoFile is the maximum number of open file descriptors
+	// (RLIMIT_NOFILE). Zero leaves the inherited limit unchanged.
+	NoFile uint64
+
+	// Core is the maximum size in bytes of a core dump (RLIMIT_CORE).
+	// A pointer to zero disables core dumps, while nil leaves the
+	// inherited limit unchanged.
+	Core *uint64
+
+	// Cgroup, if set, is the path of a cgroup relative to the root of the
+	// Linux cgroup v2 hierarchy, such as "openvpn/tunnel0". The cgroup is
+	// created if necessary, the process is moved into it, and it is
+	// removed again after the process exits if it was created by us.
+	// The caller must be permitted to manage the given cgroup.
+	Cgroup string
+
+	// MemoryMax is the maximum memory usage in bytes of the processes in
+	// Cgroup. Zero means no limit.
+	MemoryMax uint64
+
+	// CPUMax is the maximum CPU time available to the processes in Cgroup,
+	// as a number of CPUs; for example 0.5 allows half of one CPU. Zero
+	// means no limit.
+	CPUMax float64
+}
+
+func (l *ResourceLimits) isZero() bool {
+	return l.NoFile == 0 && l.Core == nil && l.Cgroup == "" && l.MemoryMax == 0 && l.CPUMax == 0
+}
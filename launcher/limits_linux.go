@@ -0,0 +1,96 @@
+//go:build linux
+
+package launcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupRoot is where the cgroup v2 hierarchy is conventionally mounted.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cpuMaxPeriod is the accounting period, in microseconds, used when
+// writing cpu.max.
+const cpuMaxPeriod = 100000
+
+func checkLimits(l *ResourceLimits) error {
+	if (l.MemoryMax != 0 || l.CPUMax != 0) && l.Cgroup == "" {
+		return fmt.Errorf("MemoryMax and CPUMax require Cgroup to be set")
+	}
+	return nil
+}
+
+// applyLimits applies the given limits to a running process, returning
+// a function that cleans up any resources created for it once the process
+// has exited.
+func applyLimits(pid int, l *ResourceLimits) (func(), error) {
+	if l.NoFile != 0 {
+		if err := setRlimit(pid, unix.RLIMIT_NOFILE, l.NoFile); err != nil {
+			return nil, fmt.Errorf("cannot set open file limit: %s", err)
+		}
+	}
+	if l.Core != nil {
+		if err := setRlimit(pid, unix.RLIMIT_CORE, *l.Core); err != nil {
+			return nil, fmt.Errorf("cannot set core dump limit: %s", err)
+		}
+	}
+
+	cleanup := func() {}
+	if l.Cgroup != "" {
+		dir := filepath.Join(cgroupRoot, filepath.Clean("/"+l.Cgroup))
+		err := os.Mkdir(dir, 0o755)
+		created := err == nil
+		if err != nil && !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("cannot create cgroup: %s", err)
+		}
+		if created {
+			// The kernel refuses to remove a cgroup that still has
+			// members, so this can only succeed once the process has
+			// exited.
+			cleanup = func() { os.Remove(dir) }
+		}
+
+		if err := writeCgroupLimits(dir, l); err != nil {
+			cleanup()
+			return nil, err
+		}
+		if err := writeCgroupFile(dir, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+	return cleanup, nil
+}
+
+func setRlimit(pid int, resource int, value uint64) error {
+	lim := unix.Rlimit{Cur: value, Max: value}
+	return unix.Prlimit(pid, resource, &lim, nil)
+}
+
+func writeCgroupLimits(dir string, l *ResourceLimits) error {
+	if l.MemoryMax != 0 {
+		if err := writeCgroupFile(dir, "memory.max", strconv.FormatUint(l.MemoryMax, 10)); err != nil {
+			return err
+		}
+	}
+	if l.CPUMax != 0 {
+		quota := int64(l.CPUMax * cpuMaxPeriod)
+		if err := writeCgroupFile(dir, "cpu.max", fmt.Sprintf("%d %d", quota, cpuMaxPeriod)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o644); err != nil {
+		return fmt.Errorf("cannot write cgroup %s: %s", name, err)
+	}
+	return nil
+}
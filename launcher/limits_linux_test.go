@@ -0,0 +1,56 @@
+//go:build linux
+
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+)
+
+func TestLimitsNoFile(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var noCore uint64
+	p, err := Start(ctx, Options{
+		Binary: sleep,
+		Args:   []string{"10"},
+		Limits: ResourceLimits{NoFile: 123, Core: &noCore},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cancel()
+		p.Wait()
+	}()
+
+	limits, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", p.Pid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`Max open files\s+123\s+123`).Match(limits) {
+		t.Errorf("open file limit was not applied:\n%s", limits)
+	}
+	if !regexp.MustCompile(`Max core file size\s+0\s+0`).Match(limits) {
+		t.Errorf("core dump limit was not applied:\n%s", limits)
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	if err := checkLimits(&ResourceLimits{MemoryMax: 1 << 20}); err == nil {
+		t.Errorf("MemoryMax without Cgroup was accepted")
+	}
+	if err := checkLimits(&ResourceLimits{Cgroup: "openvpn", CPUMax: 0.5}); err != nil {
+		t.Errorf("CPUMax with Cgroup was rejected: %s", err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package launcher
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func checkLimits(l *ResourceLimits) error {
+	return fmt.Errorf("resource limits are not supported on %s", runtime.GOOS)
+}
+
+func applyLimits(pid int, l *ResourceLimits) (func(), error) {
+	return nil, checkLimits(l)
+}
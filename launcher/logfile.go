@@ -0,0 +1,250 @@
+package launcher
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logFileTimeFormat is the format of the timestamp added to the names of
+// rotated log files. It sorts lexically in time order.
+const logFileTimeFormat = "20060102T150405.000"
+
+// LogFile is an io.WriteCloser that appends to a file, rotating it when it
+// grows too large or too old and deleting old rotated files. It is intended
+// for use as Options.Stdout and Options.Stderr, so that the output of
+// a long-running process can be kept without filling the disk. A single
+// LogFile may safely be used for both.
+//
+// A rotated file is renamed by adding the time of rotation to its name,
+// so "openvpn.log" becomes, for example, "openvpn.log.20230710T120000.000",
+// with ".gz" appended if it is compressed.
+//
+// The zero values of the limits disable the corresponding behaviour, so a
+// LogFile with only Path set never rotates.
+type LogFile struct {
+	// Path is the name of the file to write to. It is created if necessary,
+	// and appended to if it already exists.
+	Path string
+
+	// MaxSize is the size in bytes at which the file is rotated. A single
+	// write larger than MaxSize is never split, so files may exceed this
+	// size slightly.
+	MaxSize int64
+
+	// MaxAge is the period after which the file is rotated, measured from
+	// when it was opened.
+	MaxAge time.Duration
+
+	// MaxBackups is the number of rotated files to keep. Older files are
+	// deleted.
+	MaxBackups int
+
+	// MaxBackupAge is how long rotated files are kept before they are
+	// deleted.
+	MaxBackupAge time.Duration
+
+	// Compress causes rotated files to be compressed with gzip. Compression
+	// happens in the background, so writing is not held up.
+	Compress bool
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	// bgMu serializes the compression and pruning of rotated files, and
+	// bgWG tracks it so that Close can wait for it to finish.
+	bgMu sync.Mutex
+	bgWG sync.WaitGroup
+}
+
+// Write writes p to the file, first rotating the file if required.
+func (l *LogFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return 0, err
+		}
+	}
+	if l.needsRotation(len(p)) {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file immediately, for example in response to SIGHUP
+// from an external log rotation tool.
+func (l *LogFile) Rotate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	return l.rotate()
+}
+
+// Close closes the file, waiting for any background compression to finish.
+// A subsequent Write reopens it.
+func (l *LogFile) Close() error {
+	l.mu.Lock()
+	var err error
+	if l.file != nil {
+		err = l.file.Close()
+		l.file = nil
+	}
+	l.mu.Unlock()
+
+	l.bgWG.Wait()
+	return err
+}
+
+func (l *LogFile) needsRotation(n int) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.MaxSize > 0 && l.size+int64(n) > l.MaxSize {
+		return true
+	}
+	return l.MaxAge > 0 && time.Since(l.opened) >= l.MaxAge
+}
+
+// open must be called with l.mu held.
+func (l *LogFile) open() error {
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.file = f
+	l.size = info.Size()
+	l.opened = time.Now()
+	return nil
+}
+
+// rotate must be called with l.mu held and the file open.
+func (l *LogFile) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+
+	rotated := l.Path + "." + time.Now().Format(logFileTimeFormat)
+	if err := os.Rename(l.Path, rotated); err != nil {
+		return err
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	l.bgWG.Add(1)
+	go func() {
+		defer l.bgWG.Done()
+		l.bgMu.Lock()
+		defer l.bgMu.Unlock()
+
+		if l.Compress {
+			compressLogFile(rotated)
+		}
+		l.prune()
+	}()
+	return nil
+}
+
+// prune deletes the rotated files that exceed the retention limits. It must
+// be called with l.bgMu held.
+func (l *LogFile) prune() {
+	if l.MaxBackups <= 0 && l.MaxBackupAge <= 0 {
+		return
+	}
+
+	backups := l.backups()
+	for i, backup := range backups {
+		expired := l.MaxBackupAge > 0 && time.Since(backup.time) > l.MaxBackupAge
+		excess := l.MaxBackups > 0 && len(backups)-i > l.MaxBackups
+		if expired || excess {
+			os.Remove(backup.path)
+		}
+	}
+}
+
+type logBackup struct {
+	path string
+	time time.Time
+}
+
+// backups returns the rotated files, oldest first.
+func (l *LogFile) backups() []logBackup {
+	dir, base := filepath.Split(l.Path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil
+	}
+
+	var ret []logBackup
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		stamp := strings.TrimSuffix(name[len(base)+1:], ".gz")
+		t, err := time.ParseInLocation(logFileTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, logBackup{path: filepath.Join(dir, name), time: t})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].time.Before(ret[j].time)
+	})
+	return ret
+}
+
+// compressLogFile replaces the given file with a gzipped copy. The original
+// is left in place if compression fails.
+func compressLogFile(path string) {
+	src, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(path + ".gz")
+		return
+	}
+	os.Remove(path)
+}
@@ -0,0 +1,84 @@
+package launcher
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	l := &LogFile{
+		Path:       filepath.Join(dir, "openvpn.log"),
+		MaxSize:    10,
+		MaxBackups: 2,
+		Compress:   true,
+	}
+
+	// Each write after the first exceeds MaxSize and so rotates the file.
+	for _, line := range []string{"line one\n", "line two\n", "line three\n", "line four\n"} {
+		if _, err := l.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := os.ReadFile(l.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(current), "line four\n"; got != want {
+		t.Errorf("got current %q; want %q", got, want)
+	}
+
+	backups := l.backups()
+	want := []string{"line two\n", "line three\n"}
+	if len(backups) != len(want) {
+		t.Fatalf("got %d backups; want %d", len(backups), len(want))
+	}
+	for i, backup := range backups {
+		if !strings.HasSuffix(backup.path, ".gz") {
+			t.Errorf("backup %d %s is not compressed", i, backup.path)
+			continue
+		}
+		f, err := os.Open(backup.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(zr)
+		f.Close()
+		if string(got) != want[i] {
+			t.Errorf("backup %d got %q; want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestLogFileAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openvpn.log")
+	if err := os.WriteFile(path, []byte("existing\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &LogFile{Path: path, MaxSize: 100}
+	l.Write([]byte("appended\n"))
+	l.Close()
+
+	got, _ := os.ReadFile(path)
+	if want := "existing\nappended\n"; string(got) != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if backups := l.backups(); len(backups) != 0 {
+		t.Errorf("got %d backups; want none", len(backups))
+	}
+}
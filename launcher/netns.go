@@ -0,0 +1,46 @@
+package launcher
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// netNSDir is where "ip netns" creates its named network namespaces.
+const netNSDir = "/var/run/netns"
+
+// netNSPath returns the filesystem path of a network namespace, which may
+// be given either as a name managed by "ip netns" or as an absolute path
+// such as /proc/<pid>/ns/net.
+func netNSPath(name string) string {
+	if strings.ContainsRune(name, '/') {
+		return name
+	}
+	return filepath.Join(netNSDir, name)
+}
+
+// DialNetNS connects to a management interface inside the given network
+// namespace, using the same address conventions as openvpn.Dial.
+//
+// A TCP management port exposed by an OpenVPN process running in another
+// network namespace is not reachable from the caller's namespace, so the
+// connection must be made from inside it. Unix domain sockets are not
+// subject to network namespaces, so for these DialNetNS is equivalent to
+// dialing directly. If netns is empty, the caller's namespace is used.
+func DialNetNS(netns, addr string) (net.Conn, error) {
+	proto := "tcp"
+	if len(addr) > 0 && addr[0] == '/' {
+		proto = "unix"
+	}
+	if netns == "" || proto == "unix" {
+		return net.Dial(proto, addr)
+	}
+
+	var conn net.Conn
+	err := inNetNS(netns, func() error {
+		var err error
+		conn, err = net.Dial(proto, addr)
+		return err
+	})
+	return conn, err
+}
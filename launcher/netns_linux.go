@@ -0,0 +1,74 @@
+//go:build linux
+
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// inNetNS calls fn on an OS thread that has temporarily joined the given
+// network namespace. Child processes started and sockets created by fn
+// belong to that namespace, even after the thread leaves it again.
+func inNetNS(name string, fn func() error) error {
+	target, err := os.Open(netNSPath(name))
+	if err != nil {
+		return fmt.Errorf("cannot open network namespace %q: %s", name, err)
+	}
+	defer target.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		// Namespace membership belongs to the OS thread, so we need
+		// exclusive use of one for the duration.
+		runtime.LockOSThread()
+
+		orig, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			errCh <- fmt.Errorf("cannot open current network namespace: %s", err)
+			return
+		}
+		defer orig.Close()
+
+		if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			errCh <- fmt.Errorf("cannot enter network namespace %q: %s", name, err)
+			return
+		}
+
+		fnErr := fn()
+
+		// If we can't restore the original namespace then we leave the
+		// thread locked, which causes the runtime to terminate it when
+		// this goroutine exits rather than reusing it for other work.
+		if err := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); err == nil {
+			runtime.UnlockOSThread()
+		}
+		errCh <- fnErr
+	}()
+
+	return <-errCh
+}
+
+// MoveLink moves the network interface with the given name into the named
+// network namespace, using the "ip" command from iproute2.
+//
+// This is an alternative to Options.NetNS for situations where OpenVPN
+// itself must remain in the caller's namespace, for example because it
+// must reach the VPN server through interfaces that exist only there.
+// Moving an interface discards its addresses and routes, so in this
+// mode OpenVPN should usually be run with --ifconfig-noexec and
+// --route-noexec, leaving the caller to configure the interface inside
+// the target namespace once it has been moved.
+func MoveLink(ifname, netns string) error {
+	out, err := exec.Command("ip", "link", "set", "dev", ifname, "netns", netns).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot move %s to network namespace %q: %s: %s", ifname, netns, err, out)
+	}
+	return nil
+}
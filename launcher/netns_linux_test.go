@@ -0,0 +1,41 @@
+//go:build linux
+
+package launcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInNetNSMissing(t *testing.T) {
+	called := false
+	err := inNetNS(filepath.Join(t.TempDir(), "missing"), func() error {
+		called = true
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "cannot open network namespace") {
+		t.Errorf("inNetNS returned %v; want cannot open network namespace", err)
+	}
+	if called {
+		t.Errorf("inNetNS called its function")
+	}
+}
+
+func TestInNetNS(t *testing.T) {
+	// Joining a namespace, even our own, needs CAP_SYS_ADMIN.
+	if os.Geteuid() != 0 {
+		t.Skip("entering a network namespace requires root")
+	}
+	self := fmt.Sprintf("/proc/%d/ns/net", os.Getpid())
+	want := errors.New("from fn")
+	if err := inNetNS(self, func() error { return want }); err != want {
+		if strings.Contains(fmt.Sprint(err), "cannot enter") {
+			t.Skipf("cannot enter network namespace: %v", err)
+		}
+		t.Errorf("inNetNS returned %v; want the function's error", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package launcher
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func inNetNS(name string, fn func() error) error {
+	return fmt.Errorf("network namespaces are not supported on %s", runtime.GOOS)
+}
+
+// MoveLink moves the network interface with the given name into the named
+// network namespace. It is supported only on Linux.
+func MoveLink(ifname, netns string) error {
+	return fmt.Errorf("network namespaces are not supported on %s", runtime.GOOS)
+}
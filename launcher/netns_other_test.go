@@ -0,0 +1,26 @@
+//go:build !linux
+
+package launcher
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNetNSUnsupported(t *testing.T) {
+	called := false
+	err := inNetNS("vpn", func() error {
+		called = true
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), runtime.GOOS) {
+		t.Errorf("inNetNS returned %v; want unsupported on %s", err, runtime.GOOS)
+	}
+	if called {
+		t.Errorf("inNetNS called its function")
+	}
+	if err := MoveLink("tun0", "vpn"); err == nil {
+		t.Errorf("MoveLink succeeded")
+	}
+}
@@ -0,0 +1,80 @@
+package launcher
+
+import (
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestNetNSPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"vpn", filepath.Join(netNSDir, "vpn")},
+		{"/proc/1234/ns/net", "/proc/1234/ns/net"},
+		{"/var/run/netns/other", "/var/run/netns/other"},
+		{"rel/path", "rel/path"},
+	}
+	for i, test := range tests {
+		if got := netNSPath(test.name); got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
+
+// acceptOne accepts and closes a single connection to l.
+func acceptOne(l net.Listener) {
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+}
+
+func TestDialNetNSDefault(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	acceptOne(l)
+
+	// An empty namespace dials from the caller's own.
+	conn, err := DialNetNS("", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestDialNetNSUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not used on Windows")
+	}
+	path := filepath.Join(t.TempDir(), "mgmt.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	acceptOne(l)
+
+	// Unix sockets ignore network namespaces, so the namespace, which
+	// doesn't exist, isn't entered.
+	conn, err := DialNetNS("no-such-netns", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := conn.RemoteAddr().Network(); got != "unix" {
+		t.Errorf("dialed %s; want unix", got)
+	}
+	conn.Close()
+}
+
+func TestDialNetNSMissing(t *testing.T) {
+	if _, err := DialNetNS(filepath.Join(t.TempDir(), "missing"), "127.0.0.1:1194"); err == nil {
+		t.Errorf("dialed inside a missing network namespace")
+	}
+}
@@ -0,0 +1,285 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// TunnelEvent is an event received from one of the tunnels in a Pool.
+type TunnelEvent struct {
+	// Tunnel is the name the tunnel was given when added to the pool.
+	Tunnel string
+
+	// Tags are the tags of the tunnel when the event was received, as set
+	// with Pool.SetTags. The map must not be modified.
+	Tags  map[string]string
+	Event openvpn.Event
+}
+
+// TunnelStatus is a summary of the current status of one of the tunnels
+// in a Pool.
+type TunnelStatus struct {
+	Name string
+
+	// State is the most recent connection state reported by the tunnel's
+	// StateEvents, such as "CONNECTED", or the empty string if no state
+	// has been reported. State events must be enabled, typically in
+	// Pool.OnConnect, for this to be populated.
+	State string
+
+	// Pid is the process id of the tunnel's OpenVPN process, or zero if
+	// there is currently no process.
+	Pid int
+
+	// Err is the most recent error encountered while launching or
+	// connecting to the tunnel's process, if any.
+	Err error
+
+	// Tags are the tags of the tunnel, as set with Pool.SetTags. The map
+	// must not be modified.
+	Tags map[string]string
+}
+
+// Pool manages a set of separately-configured OpenVPN tunnels, each of
+// which is kept running by its own Supervisor.
+type Pool struct {
+	// Events receives the events from all of the tunnels, tagged with the
+	// name of the tunnel they came from. It must be drained constantly, as
+	// described in the openvpn.NewClient docs, while Run is running. It is
+	// closed when Run returns. It must be set before Run is called.
+	Events chan<- TunnelEvent
+
+	// OnConnect, if set, is called with each new management client for
+	// each tunnel, as for Supervisor.OnConnect.
+	OnConnect func(name string, client *openvpn.MgmtClient) error
+
+	// StartInterval is the minimum delay between starting successive
+	// tunnels, to avoid all of them connecting at the same moment.
+	StartInterval time.Duration
+
+	// Logger, if set, is used as the Logger of each tunnel's Supervisor,
+	// with a "tunnel" attribute giving the name of the tunnel.
+	Logger *slog.Logger
+
+	mu        sync.Mutex
+	tunnels   map[string]*poolTunnel
+	order     []string
+	ctx       context.Context
+	wg        sync.WaitGroup
+	nextStart time.Time
+}
+
+type poolTunnel struct {
+	name   string
+	sup    *Supervisor
+	cancel context.CancelFunc
+	state  string
+
+	// tags is replaced rather than modified, so that it can be shared
+	// with events and status.
+	tags map[string]string
+
+	clients openvpn.ClientRegistry
+	rates   openvpn.RateTracker
+}
+
+// Add adds a new tunnel to the pool, launched as described by opts. The
+// name identifies the tunnel in events and status, and must be unique
+// within the pool.
+//
+// If the pool is already running then the tunnel is started immediately,
+// subject to StartInterval.
+func (p *Pool) Add(name string, opts Options) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tunnels == nil {
+		p.tunnels = map[string]*poolTunnel{}
+	}
+	if _, exists := p.tunnels[name]; exists {
+		return fmt.Errorf("duplicate tunnel name %q", name)
+	}
+
+	t := &poolTunnel{
+		name: name,
+		sup:  &Supervisor{Options: opts},
+	}
+	if p.Logger != nil {
+		t.sup.Logger = p.Logger.With("tunnel", name)
+	}
+	if p.OnConnect != nil {
+		t.sup.OnConnect = func(client *openvpn.MgmtClient) error {
+			return p.OnConnect(name, client)
+		}
+	}
+	p.tunnels[name] = t
+	p.order = append(p.order, name)
+
+	if p.ctx != nil {
+		p.start(t)
+	}
+	return nil
+}
+
+// Remove stops the named tunnel and removes it from the pool.
+func (p *Pool) Remove(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t, exists := p.tunnels[name]
+	if !exists {
+		return fmt.Errorf("no tunnel named %q", name)
+	}
+	if t.cancel != nil {
+		t.cancel()
+	}
+	delete(p.tunnels, name)
+	for i, n := range p.order {
+		if n == name {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Client returns the current management client for the named tunnel, or
+// nil if there is no such tunnel or it is not currently connected.
+func (p *Pool) Client(name string) *openvpn.MgmtClient {
+	p.mu.Lock()
+	t := p.tunnels[name]
+	p.mu.Unlock()
+
+	if t == nil {
+		return nil
+	}
+	return t.sup.Client()
+}
+
+// Clients returns the clients connected to the named tunnel, if it is an
+// OpenVPN server running with --management-client-auth, ordered by client
+// id. It fails if there is no such tunnel.
+func (p *Pool) Clients(name string) ([]openvpn.ConnectedClient, error) {
+	p.mu.Lock()
+	t := p.tunnels[name]
+	p.mu.Unlock()
+
+	if t == nil {
+		return nil, fmt.Errorf("no tunnel named %q", name)
+	}
+	return t.clients.Clients(), nil
+}
+
+// Status returns the status of each tunnel, in the order they were added.
+func (p *Pool) Status() []TunnelStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ret := make([]TunnelStatus, 0, len(p.order))
+	for _, name := range p.order {
+		t := p.tunnels[name]
+		status := TunnelStatus{
+			Name:  name,
+			State: t.state,
+			Err:   t.sup.Err(),
+			Tags:  t.tags,
+		}
+		if proc := t.sup.Process(); proc != nil {
+			status.Pid = proc.Pid()
+		}
+		ret = append(ret, status)
+	}
+	return ret
+}
+
+// CountByState returns the number of tunnels in each connection state, as
+// reported by Status, for a quick summary of the health of the pool.
+func (p *Pool) CountByState() map[string]int {
+	counts := map[string]int{}
+	for _, status := range p.Status() {
+		counts[status.State]++
+	}
+	return counts
+}
+
+// Run starts all of the tunnels added so far, staggered by StartInterval,
+// and keeps them running until ctx is cancelled. It then waits for all of
+// the tunnels to stop and returns the context's error. It returns an error
+// immediately if Events is nil.
+func (p *Pool) Run(ctx context.Context) error {
+	if p.Events == nil {
+		return errors.New("pool has no Events channel")
+	}
+
+	p.mu.Lock()
+	p.ctx = ctx
+	for _, name := range p.order {
+		p.start(p.tunnels[name])
+	}
+	p.mu.Unlock()
+
+	<-ctx.Done()
+
+	p.mu.Lock()
+	p.ctx = nil
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	close(p.Events)
+	return ctx.Err()
+}
+
+// start launches the supervisor for the given tunnel. The caller must
+// hold p.mu.
+func (p *Pool) start(t *poolTunnel) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	t.cancel = cancel
+
+	now := time.Now()
+	if p.nextStart.Before(now) {
+		p.nextStart = now
+	}
+	delay := p.nextStart.Sub(now)
+	p.nextStart = p.nextStart.Add(p.StartInterval)
+
+	events := make(chan openvpn.Event, supervisorEventBuffer)
+	t.sup.Events = events
+
+	p.wg.Add(2)
+	go func() {
+		defer p.wg.Done()
+		select {
+		case <-ctx.Done():
+			close(events)
+			return
+		case <-time.After(delay):
+		}
+		t.sup.Run(ctx)
+	}()
+	go func() {
+		defer p.wg.Done()
+		for event := range events {
+			p.Events <- p.handleEvent(t, event)
+		}
+	}()
+}
+
+// handleEvent updates the tunnel's status from one of its events, and
+// returns the event tagged for delivery to p.Events.
+func (p *Pool) handleEvent(t *poolTunnel, event openvpn.Event) TunnelEvent {
+	t.clients.HandleEvent(event)
+	t.rates.HandleEvent(event)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if st, ok := event.(*openvpn.StateEvent); ok {
+		t.state = st.NewState()
+	}
+	return TunnelEvent{Tunnel: t.name, Tags: t.tags, Event: event}
+}
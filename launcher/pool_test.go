@@ -0,0 +1,49 @@
+package launcher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPoolMembership(t *testing.T) {
+	p := &Pool{}
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := p.Add(name, Options{ManagementAddr: "/run/" + name + ".sock"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Add("b", Options{}); err == nil {
+		t.Errorf("duplicate tunnel name was accepted")
+	}
+	if err := p.Remove("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Remove("b"); err == nil {
+		t.Errorf("removing unknown tunnel succeeded")
+	}
+
+	status := p.Status()
+	if len(status) != 2 || status[0].Name != "a" || status[1].Name != "c" {
+		t.Fatalf("wrong status %#v", status)
+	}
+	if got := p.CountByState()[""]; got != 2 {
+		t.Errorf("%d tunnels have no state; want 2", got)
+	}
+	if p.Client("a") != nil {
+		t.Errorf("tunnel that was never started has a client")
+	}
+}
+
+func TestPoolRunWithoutEvents(t *testing.T) {
+	p := &Pool{}
+	if err := p.Add("a", Options{ManagementAddr: "/run/a.sock"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background()); err == nil {
+		t.Errorf("Run without Events succeeded")
+	}
+	if p.Client("a") != nil {
+		t.Errorf("tunnel was started without Events")
+	}
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package launcher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+func listProcesses() ([]processEntry, error) {
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []processEntry
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if err != nil {
+			continue
+		}
+		// Processes can exit while we're scanning, and we may not be
+		// permitted to inspect others, so errors here are not fatal.
+		cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+
+		var argv []string
+		for _, arg := range bytes.Split(bytes.TrimSuffix(cmdline, []byte{0}), []byte{0}) {
+			argv = append(argv, string(arg))
+		}
+		ret = append(ret, processEntry{pid: pid, argv: argv})
+	}
+	return ret, nil
+}
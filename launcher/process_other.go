@@ -0,0 +1,19 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+func listProcesses() ([]processEntry, error) {
+	return nil, fmt.Errorf("searching the process table is not supported on %s", runtime.GOOS)
+}
+
+// watchExit blocks until the given process exits. On these systems
+// it's possible to wait for processes other than our own children.
+func watchExit(proc *os.Process) {
+	proc.Wait()
+}
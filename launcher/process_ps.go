@@ -0,0 +1,36 @@
+//go:build aix || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+
+package launcher
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// listProcesses uses ps, since these systems have no portable equivalent
+// of Linux's /proc/<pid>/cmdline. ps doesn't preserve the boundaries
+// between arguments, so arguments containing spaces will be split.
+func listProcesses() ([]processEntry, error) {
+	out, err := exec.Command("ps", "-axww", "-o", "pid=", "-o", "command=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []processEntry
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ret = append(ret, processEntry{pid: pid, argv: fields[1:]})
+	}
+	return ret, nil
+}
@@ -0,0 +1,335 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// Default restart delays used when the corresponding Supervisor fields
+// are zero.
+const (
+	DefaultRestartDelay    = time.Second
+	DefaultMaxRestartDelay = time.Minute
+)
+
+// dialRetryInterval is how often the supervisor retries connecting to the
+// management interface of a newly-started process.
+const dialRetryInterval = 100 * time.Millisecond
+
+// discardLogger is a logger whose level is too high for any message to be
+// enabled, used when Supervisor.Logger is nil.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.Level(math.MaxInt)}))
+
+// supervisorEventBuffer is the depth of the event channel created for each
+// management connection, whose events are then forwarded to the
+// supervisor's own event channel.
+const supervisorEventBuffer = 64
+
+// Supervisor keeps an OpenVPN process running, restarting it whenever
+// it exits, and maintains a management connection to it.
+//
+// The process is launched as described by Options, which must therefore
+// set ManagementAddr. Alternatively, an already-running process can be
+// adopted using Adopt or Attach before calling Run, in which case the
+// supervisor takes over that process and launches a new one only once the
+// adopted process has exited.
+type Supervisor struct {
+	// Options describes how to launch the OpenVPN process.
+	Options Options
+
+	// Events receives the events from each successive management
+	// connection. It must be drained constantly, as described in the
+	// openvpn.NewClient docs, while Run is running. It is closed when Run
+	// returns. The events received belong to the receiver, which may pass
+	// them to openvpn.ReleaseEvent. It must be set before Run is called.
+	Events chan<- openvpn.Event
+
+	// OnConnect, if set, is called with each new management client, for
+	// example to enable the desired event types and then release the
+	// management hold. It is called from the same goroutine as Run.
+	OnConnect func(*openvpn.MgmtClient) error
+
+	// ByteCountInterval, if not zero, enables byte count events at the
+	// given interval on each new management client, before OnConnect is
+	// called, so that traffic statistics are kept up to date across
+	// restarts without OnConnect having to enable them. A server then
+	// also reports the byte counts of each of its clients.
+	ByteCountInterval time.Duration
+
+	// RestartDelay is the delay before restarting a process that has
+	// exited. The delay doubles after each consecutive failure to
+	// establish a management connection, up to MaxRestartDelay.
+	RestartDelay    time.Duration
+	MaxRestartDelay time.Duration
+
+	// OnCrash, if set, is called with diagnostic information whenever the
+	// supervised process exits other than as a result of cancelling the
+	// context passed to Run, for example so that the information can be
+	// sent to a support system. It is called from the same goroutine as Run,
+	// before the process is restarted.
+	OnCrash func(*Diagnostics)
+
+	// DiagnosticsDepth is the number of recent events and log lines to
+	// include in the diagnostics passed to OnCrash. If zero,
+	// DefaultDiagnosticsDepth is used.
+	DiagnosticsDepth int
+
+	// DiagnosticsBytes is the most bytes of log lines to retain for the
+	// diagnostics passed to OnCrash, discarding the oldest lines beyond
+	// it, so that a process writing very long lines can't use unbounded
+	// memory. If zero, DefaultDiagnosticsBytes is used.
+	DiagnosticsBytes int
+
+	// Logger, if set, receives diagnostics about the processes supervised,
+	// such as each launch, connection and exit, and the delay before each
+	// restart. It is also set as the logger of each management client, as
+	// described for openvpn.MgmtClient.SetLogger.
+	Logger *slog.Logger
+
+	mu      sync.Mutex
+	proc    *Process
+	client  *openvpn.MgmtClient
+	adopted *Process
+	lastErr error
+}
+
+// Adopt arranges for the supervisor to take over the given process, as
+// returned by Attach, rather than launching a new one when Run is called.
+// If the process retains a management connection from Attach then the
+// supervisor claims it.
+func (s *Supervisor) Adopt(p *Process) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adopted = p
+}
+
+// Attach is a convenience wrapper that calls the package-level Attach
+// function and then adopts the resulting process.
+func (s *Supervisor) Attach(ctx context.Context, inst *Instance) error {
+	p, err := Attach(ctx, inst)
+	if err != nil {
+		return err
+	}
+	s.Adopt(p)
+	return nil
+}
+
+// Process returns the currently-supervised process, or nil if there is
+// none.
+func (s *Supervisor) Process() *Process {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.proc
+}
+
+// Client returns the current management client, or nil if the supervisor
+// is not currently connected.
+func (s *Supervisor) Client() *openvpn.MgmtClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.client
+}
+
+// Err returns the most recent error encountered while launching or
+// connecting to a process, if any.
+func (s *Supervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Run supervises processes until ctx is cancelled, and then returns the
+// context's error. It returns an error immediately if Events is nil.
+//
+// Processes launched by the supervisor are killed when ctx is cancelled.
+// An adopted process was not started by us and so is left running.
+func (s *Supervisor) Run(ctx context.Context) error {
+	if s.Events == nil {
+		return errors.New("supervisor has no Events channel")
+	}
+	defer close(s.Events)
+
+	delay := s.restartDelay()
+	for {
+		s.mu.Lock()
+		p := s.adopted
+		s.adopted = nil
+		s.mu.Unlock()
+
+		var rec *recorder
+		if s.OnCrash != nil {
+			rec = newRecorder(s.DiagnosticsDepth, s.DiagnosticsBytes)
+		}
+
+		var err error
+		if p == nil {
+			opts := s.Options
+			if rec != nil {
+				opts.Stdout = teeWriter(opts.Stdout, rec)
+				opts.Stderr = teeWriter(opts.Stderr, rec)
+			}
+			p, err = Start(ctx, opts)
+			if err == nil {
+				s.log().Info("started OpenVPN", "pid", p.Pid())
+			}
+		} else {
+			s.log().Info("adopted OpenVPN", "pid", p.Pid())
+		}
+		if err == nil {
+			s.setProcess(p, nil)
+			err = s.superviseProcess(ctx, p, rec)
+			s.setProcess(nil, nil)
+
+			if ctx.Err() == nil {
+				<-p.Done()
+				s.log().Warn("OpenVPN exited", "pid", p.Pid(), "error", p.Wait())
+				if rec != nil {
+					s.OnCrash(rec.diagnostics(p))
+				}
+			}
+		}
+
+		if err != nil {
+			s.setErr(err)
+			if ctx.Err() == nil {
+				s.log().Warn("failed to run OpenVPN", "error", err)
+			}
+		} else {
+			delay = s.restartDelay()
+		}
+		if ctx.Err() == nil {
+			s.log().Info("restarting OpenVPN", "delay", delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if err != nil {
+			delay = s.nextDelay(delay)
+		}
+	}
+}
+
+// superviseProcess connects to the given process and forwards events from
+// it until it exits. It returns an error if no connection could be made.
+//
+// If rec is not nil then each event is recorded in it.
+func (s *Supervisor) superviseProcess(ctx context.Context, p *Process, rec *recorder) error {
+	client, events := p.Client()
+	if client == nil {
+		var err error
+		client, events, err = s.dial(ctx, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	forwarded := make(chan struct{})
+	go func() {
+		for event := range events {
+			if rec != nil {
+				rec.recordEvent(event)
+			}
+			s.Events <- event
+		}
+		close(forwarded)
+	}()
+
+	s.setProcess(p, client)
+	if s.Logger != nil {
+		client.SetLogger(s.Logger)
+	}
+	s.log().Debug("connected to management interface", "pid", p.Pid())
+	if s.ByteCountInterval > 0 {
+		if err := client.SetByteCountEvents(s.ByteCountInterval); err != nil {
+			s.setErr(err)
+			s.log().Warn("failed to enable byte count events", "error", err)
+		}
+	}
+	if s.OnConnect != nil {
+		if err := s.OnConnect(client); err != nil {
+			s.setErr(err)
+			s.log().Warn("OnConnect failed", "error", err)
+		}
+	}
+
+	select {
+	case <-p.Done():
+	case <-ctx.Done():
+		if p.Adopted() {
+			client.Close()
+		}
+	}
+	<-forwarded
+	return nil
+}
+
+// dial connects to the management interface of a newly-launched process,
+// retrying until it succeeds or until the process exits.
+func (s *Supervisor) dial(ctx context.Context, p *Process) (*openvpn.MgmtClient, <-chan openvpn.Event, error) {
+	for {
+		eventCh := make(chan openvpn.Event, supervisorEventBuffer)
+		client, err := p.Dial(eventCh)
+		if err == nil {
+			return client, eventCh, nil
+		}
+		s.log().Debug("management interface not yet available", "pid", p.Pid(), "error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-p.Done():
+			return nil, nil, err
+		case <-time.After(dialRetryInterval):
+		}
+	}
+}
+
+// log returns the supervisor's logger, or one that discards all messages.
+func (s *Supervisor) log() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return discardLogger
+}
+
+func (s *Supervisor) setProcess(p *Process, client *openvpn.MgmtClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.proc = p
+	s.client = client
+}
+
+func (s *Supervisor) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+}
+
+func (s *Supervisor) restartDelay() time.Duration {
+	if s.RestartDelay > 0 {
+		return s.RestartDelay
+	}
+	return DefaultRestartDelay
+}
+
+func (s *Supervisor) nextDelay(delay time.Duration) time.Duration {
+	limit := s.MaxRestartDelay
+	if limit <= 0 {
+		limit = DefaultMaxRestartDelay
+	}
+	delay *= 2
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
@@ -0,0 +1,68 @@
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/gopenvpntest"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestSupervisorByteCountInterval(t *testing.T) {
+	srv, err := gopenvpntest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Handle("", gopenvpntest.Success("ok"))
+	srv.Handle("pid", gopenvpntest.Success(fmt.Sprintf("pid=%d", os.Getpid())))
+	srv.Handle("version", gopenvpntest.Lines("OpenVPN Version: OpenVPN 2.6.8 x86_64-pc-linux-gnu", "Management Version: 5"))
+
+	events := make(chan openvpn.Event, 10)
+	go func() {
+		for range events {
+		}
+	}()
+	var seen [][]string
+	s := &Supervisor{
+		Events:            events,
+		ByteCountInterval: 5 * time.Second,
+	}
+
+	// Each connection to a process, such as after a restart, enables byte
+	// counts before OnConnect is called.
+	for i := 0; i < 2; i++ {
+		p, err := Attach(context.Background(), &Instance{ManagementAddr: srv.Addr})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		s.OnConnect = func(client *openvpn.MgmtClient) error {
+			seen = append(seen, srv.Commands())
+			cancel()
+			return nil
+		}
+		if err := s.superviseProcess(ctx, p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := [][]string{
+		{"pid", "version", "bytecount 5"},
+		{"pid", "version", "bytecount 5", "pid", "version", "bytecount 5"},
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("commands sent before OnConnect were %q; want %q", seen, want)
+	}
+}
+
+func TestSupervisorRunWithoutEvents(t *testing.T) {
+	s := &Supervisor{Options: Options{ManagementAddr: "/run/a.sock"}}
+	if err := s.Run(context.Background()); err == nil {
+		t.Errorf("Run without Events succeeded")
+	}
+}
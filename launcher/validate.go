@@ -0,0 +1,158 @@
+package launcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ConfigError describes a problem OpenVPN found with its configuration.
+type ConfigError struct {
+	// File and Line identify where the offending directive appears, with
+	// File set to "[CMD-LINE]" for the command line. Both are empty if
+	// OpenVPN didn't report a location.
+	File string
+	Line int
+
+	// Directive is the name of the offending directive, if known.
+	Directive string
+
+	// Message is the complete error message reported by OpenVPN.
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	if e.File == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// ConfigErrors lists every problem OpenVPN reported with its
+// configuration, in the order reported. errors.As finds the first of them
+// as a *ConfigError.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual errors, for errors.Is and errors.As.
+func (e ConfigErrors) Unwrap() []error {
+	ret := make([]error, len(e))
+	for i, err := range e {
+		ret[i] = err
+	}
+	return ret
+}
+
+// optionsErrorPrefix marks the messages OpenVPN prints when rejecting its
+// configuration.
+const optionsErrorPrefix = "Options error: "
+
+var (
+	// "Unrecognized option or missing or extra parameter(s) in client.conf:12: foo (2.6.3)"
+	optionsErrorDirective = regexp.MustCompile(` in (\S+):(\d+): (\S+)`)
+
+	// "In [CMD-LINE]:1: Error opening configuration file: x.conf"
+	optionsErrorLocation = regexp.MustCompile(`^In (\S+):(\d+): `)
+)
+
+// Validate asks OpenVPN to parse the configuration described by opts,
+// without connecting or creating any network devices, and returns
+// ConfigErrors describing each problem found, if any. It also fails if
+// OpenVPN exits unsuccessfully without reporting a problem, such as when
+// the binary is broken.
+//
+// This works by running OpenVPN with --show-ciphers added to the command
+// line, which causes it to exit immediately after parsing. It therefore
+// catches syntax errors such as unknown directives or wrong parameter
+// counts, but not problems that are detected only while connecting, such
+// as unreadable certificate files.
+func Validate(ctx context.Context, opts Options) error {
+	if err := opts.resolveBinary(); err != nil {
+		return err
+	}
+
+	caps, err := opts.capabilities(ctx)
+	if err != nil {
+		return err
+	}
+	if opts.AdaptConfig && opts.ConfigFile != "" {
+		removeConfig, err := opts.adaptConfig()
+		if err != nil {
+			return err
+		}
+		defer removeConfig()
+	}
+	args, err := opts.args(caps)
+	if err != nil {
+		return err
+	}
+	args = append(args, "--show-ciphers")
+
+	cmd := exec.CommandContext(ctx, opts.Binary, args...)
+	cmd.Dir = opts.Dir
+	cmd.Env = opts.Env
+	out, err := cmd.CombinedOutput()
+
+	if configErrs := parseConfigErrors(out); configErrs != nil {
+		return configErrs
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("OpenVPN failed while parsing its configuration: %w: %s", err, lastLine(out))
+	}
+	return err
+}
+
+// lastLine returns the last non-empty line of the given output.
+func lastLine(out []byte) []byte {
+	out = bytes.TrimRight(out, "\r\n")
+	if idx := bytes.LastIndexByte(out, '\n'); idx != -1 {
+		out = out[idx+1:]
+	}
+	return bytes.TrimSpace(out)
+}
+
+// parseConfigErrors returns the options errors in the given OpenVPN
+// output, or nil if there are none.
+func parseConfigErrors(out []byte) ConfigErrors {
+	var ret ConfigErrors
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		idx := strings.Index(line, optionsErrorPrefix)
+		if idx == -1 {
+			continue
+		}
+		msg := line[idx+len(optionsErrorPrefix):]
+
+		configErr := &ConfigError{Message: msg}
+		if m := optionsErrorDirective.FindStringSubmatch(msg); m != nil {
+			configErr.File = m[1]
+			configErr.Line, _ = strconv.Atoi(m[2])
+			configErr.Directive = m[3]
+		} else if m := optionsErrorLocation.FindStringSubmatch(msg); m != nil {
+			configErr.File = m[1]
+			configErr.Line, _ = strconv.Atoi(m[2])
+			configErr.Message = msg[len(m[0]):]
+		} else if strings.HasPrefix(msg, "--") {
+			fields := strings.Fields(msg)
+			configErr.Directive = strings.TrimPrefix(fields[0], "--")
+		}
+		ret = append(ret, configErr)
+	}
+	return ret
+}
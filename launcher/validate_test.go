@@ -0,0 +1,70 @@
+package launcher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseConfigError(t *testing.T) {
+	tests := []struct {
+		output string
+		want   ConfigErrors
+	}{
+		{
+			output: "OpenVPN 2.6.3 x86_64-pc-linux-gnu\nAES-128-CBC  (128 bit key, 128 bit block)\n",
+			want:   nil,
+		},
+		{
+			output: "Options error: Unrecognized option or missing or extra parameter(s) in client.conf:12: comp-lzoo (2.6.3)\nUse --help for more information.\n",
+			want: ConfigErrors{{
+				File:      "client.conf",
+				Line:      12,
+				Directive: "comp-lzoo",
+				Message:   "Unrecognized option or missing or extra parameter(s) in client.conf:12: comp-lzoo (2.6.3)",
+			}},
+		},
+		{
+			output: "Options error: In [CMD-LINE]:1: Error opening configuration file: missing.conf\n",
+			want: ConfigErrors{{
+				File:    "[CMD-LINE]",
+				Line:    1,
+				Message: "Error opening configuration file: missing.conf",
+			}},
+		},
+		{
+			output: "2023-07-10 12:00:00 Options error: --explicit-exit-notify can only be used with --proto udp\n",
+			want: ConfigErrors{{
+				Directive: "explicit-exit-notify",
+				Message:   "--explicit-exit-notify can only be used with --proto udp",
+			}},
+		},
+		{
+			output: "Options error: Unrecognized option or missing or extra parameter(s) in a.conf:3: foo (2.6.3)\nOptions error: --bar can only be used with --baz\n",
+			want: ConfigErrors{
+				{File: "a.conf", Line: 3, Directive: "foo", Message: "Unrecognized option or missing or extra parameter(s) in a.conf:3: foo (2.6.3)"},
+				{Directive: "bar", Message: "--bar can only be used with --baz"},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		got := parseConfigErrors([]byte(test.output))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d\ngot  %#v\nwant %#v", i, got, test.want)
+		}
+	}
+
+	err := &ConfigError{File: "client.conf", Line: 3, Message: "bad"}
+	if got, want := err.Error(), "client.conf:3: bad"; got != want {
+		t.Errorf("Error returned %q; want %q", got, want)
+	}
+	errs := ConfigErrors{err, {Message: "worse"}}
+	if got, want := errs.Error(), "client.conf:3: bad; worse"; got != want {
+		t.Errorf("Error returned %q; want %q", got, want)
+	}
+	var first *ConfigError
+	if !errors.As(error(errs), &first) || first != err {
+		t.Errorf("errors.As found %v; want the first error", first)
+	}
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// namespace prefixes the names of all metrics.
+const namespace = "openvpn"
+
+// states are the states reported by the connection state gauge.
+var states = []openvpn.State{
+	openvpn.StateConnecting,
+	openvpn.StateResolve,
+	openvpn.StateTCPConnect,
+	openvpn.StateWait,
+	openvpn.StateAuth,
+	openvpn.StateAuthPending,
+	openvpn.StateGetConfig,
+	openvpn.StateAssignIP,
+	openvpn.StateAddRoutes,
+	openvpn.StateConnected,
+	openvpn.StateReconnecting,
+	openvpn.StateExiting,
+}
+
+// Collector is a prometheus.Collector reporting on an OpenVPN tunnel or
+// server. Each of its sources is optional, and only the metrics for those
+// that are set are reported:
+//
+//   - Session, for an OpenVPN client, yields the connection state, the
+//     tunnel's byte counts, and its connect and reconnect counts.
+//   - Registry, for an OpenVPN server, yields the number of connected
+//     clients and the traffic of each common name currently connected.
+//   - Accounting, for an OpenVPN server, yields the traffic of each common
+//     name over all of its sessions, in place of that from Registry.
+//
+// The sources must not be changed once the collector is registered.
+type Collector struct {
+	Session    *openvpn.Session
+	Registry   *openvpn.ClientRegistry
+	Accounting *openvpn.Accounting
+
+	// ConstLabels are added to every metric, such as to identify the
+	// tunnel when a process collects from several.
+	ConstLabels prometheus.Labels
+
+	once sync.Once
+	desc descs
+}
+
+type descs struct {
+	up, state            *prometheus.Desc
+	bytesIn, bytesOut    *prometheus.Desc
+	connects, reconnects *prometheus.Desc
+	uptime               *prometheus.Desc
+	clients, cnClients   *prometheus.Desc
+	cnReceived, cnSent   *prometheus.Desc
+}
+
+func (c *Collector) descs() *descs {
+	c.once.Do(func() {
+		d := func(name, help string, labels ...string) *prometheus.Desc {
+			return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, c.ConstLabels)
+		}
+		c.desc = descs{
+			up:         d("management_connected", "Whether the management interface is connected."),
+			state:      d("connection_state", "The connection state of the tunnel, set to 1 for the current state.", "state"),
+			bytesIn:    d("tunnel_bytes_received_total", "Bytes received through the tunnel in its current connection."),
+			bytesOut:   d("tunnel_bytes_sent_total", "Bytes sent through the tunnel in its current connection."),
+			connects:   d("connects_total", "Times the tunnel has connected."),
+			reconnects: d("reconnects_total", "Times the tunnel has started reconnecting."),
+			uptime:     d("tunnel_uptime_seconds", "Time since the tunnel last connected, or 0 if it is not connected."),
+			clients:    d("server_connected_clients", "Clients connected to the server."),
+			cnClients:  d("server_client_connections", "Connections from each common name.", "common_name"),
+			cnReceived: d("server_client_bytes_received_total", "Bytes received from clients with each common name.", "common_name"),
+			cnSent:     d("server_client_bytes_sent_total", "Bytes sent to clients with each common name.", "common_name"),
+		}
+	})
+	return &c.desc
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	d := c.descs()
+	if c.Session != nil {
+		for _, desc := range []*prometheus.Desc{d.up, d.state, d.bytesIn, d.bytesOut, d.connects, d.reconnects, d.uptime} {
+			ch <- desc
+		}
+	}
+	if c.Registry != nil {
+		ch <- d.clients
+		ch <- d.cnClients
+	}
+	if c.Registry != nil || c.Accounting != nil {
+		ch <- d.cnReceived
+		ch <- d.cnSent
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	d := c.descs()
+	if c.Session != nil {
+		c.collectSession(ch, d)
+	}
+
+	type traffic struct {
+		conns          int
+		received, sent int64
+	}
+	byCN := map[string]*traffic{}
+	get := func(cn string) *traffic {
+		if byCN[cn] == nil {
+			byCN[cn] = &traffic{}
+		}
+		return byCN[cn]
+	}
+	if c.Registry != nil {
+		clients := c.Registry.Clients()
+		ch <- prometheus.MustNewConstMetric(d.clients, prometheus.GaugeValue, float64(len(clients)))
+		for _, cc := range clients {
+			t := get(cc.CommonName)
+			t.conns++
+			t.received += cc.BytesReceived
+			t.sent += cc.BytesSent
+		}
+		for cn, t := range byCN {
+			ch <- prometheus.MustNewConstMetric(d.cnClients, prometheus.GaugeValue, float64(t.conns), cn)
+		}
+	}
+	if c.Accounting != nil {
+		byCN = map[string]*traffic{}
+		for _, u := range c.Accounting.All() {
+			t := get(u.CommonName)
+			t.received, t.sent = u.BytesReceived, u.BytesSent
+		}
+	}
+	for cn, t := range byCN {
+		ch <- prometheus.MustNewConstMetric(d.cnReceived, prometheus.CounterValue, float64(t.received), cn)
+		ch <- prometheus.MustNewConstMetric(d.cnSent, prometheus.CounterValue, float64(t.sent), cn)
+	}
+}
+
+func (c *Collector) collectSession(ch chan<- prometheus.Metric, d *descs) {
+	health := c.Session.Health()
+	snap := c.Session.Snapshot()
+	analytics := c.Session.Analytics()
+
+	ch <- prometheus.MustNewConstMetric(d.up, prometheus.GaugeValue, boolValue(health.ManagementConnected))
+	for _, state := range states {
+		ch <- prometheus.MustNewConstMetric(d.state, prometheus.GaugeValue, boolValue(snap.State == state), string(state))
+	}
+	ch <- prometheus.MustNewConstMetric(d.bytesIn, prometheus.CounterValue, float64(snap.BytesIn))
+	ch <- prometheus.MustNewConstMetric(d.bytesOut, prometheus.CounterValue, float64(snap.BytesOut))
+	ch <- prometheus.MustNewConstMetric(d.connects, prometheus.CounterValue, float64(analytics.Connects))
+	ch <- prometheus.MustNewConstMetric(d.reconnects, prometheus.CounterValue, float64(analytics.Reconnects))
+	ch <- prometheus.MustNewConstMetric(d.uptime, prometheus.GaugeValue, snap.Uptime.Seconds())
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectorSession(t *testing.T) {
+	s := &openvpn.Session{}
+	for _, raw := range []string{
+		"STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"STATE:2,RECONNECTING,ping-restart,,",
+		"STATE:3,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"BYTECOUNT:1000,2000",
+	} {
+		s.HandleEvent(openvpn.ParseEvent([]byte(raw)))
+	}
+
+	c := &Collector{Session: s}
+	want := `
+# HELP openvpn_reconnects_total Times the tunnel has started reconnecting.
+# TYPE openvpn_reconnects_total counter
+openvpn_reconnects_total 1
+# HELP openvpn_tunnel_bytes_received_total Bytes received through the tunnel in its current connection.
+# TYPE openvpn_tunnel_bytes_received_total counter
+openvpn_tunnel_bytes_received_total 1000
+# HELP openvpn_tunnel_bytes_sent_total Bytes sent through the tunnel in its current connection.
+# TYPE openvpn_tunnel_bytes_sent_total counter
+openvpn_tunnel_bytes_sent_total 2000
+`
+	names := []string{"openvpn_reconnects_total", "openvpn_tunnel_bytes_received_total", "openvpn_tunnel_bytes_sent_total"}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.CollectAndCount(c, "openvpn_connection_state"); got != len(states) {
+		t.Errorf("got %d connection states; want %d", got, len(states))
+	}
+}
+
+func TestCollectorServer(t *testing.T) {
+	acct := &openvpn.Accounting{}
+	r := &openvpn.ClientRegistry{OnChange: acct.HandleChange}
+	r.Sync([]openvpn.ClientStatus{
+		{ClientID: 1, CommonName: "alice", BytesReceived: 100, BytesSent: 200},
+		{ClientID: 2, CommonName: "alice", BytesReceived: 10, BytesSent: 20},
+		{ClientID: 3, CommonName: "bob", BytesReceived: 1, BytesSent: 2},
+	})
+	r.Sync([]openvpn.ClientStatus{
+		{ClientID: 1, CommonName: "alice", BytesReceived: 100, BytesSent: 200},
+	})
+
+	want := `
+# HELP openvpn_server_client_bytes_received_total Bytes received from clients with each common name.
+# TYPE openvpn_server_client_bytes_received_total counter
+openvpn_server_client_bytes_received_total{common_name="alice",server="vpn1"} 100
+# HELP openvpn_server_client_connections Connections from each common name.
+# TYPE openvpn_server_client_connections gauge
+openvpn_server_client_connections{common_name="alice",server="vpn1"} 1
+# HELP openvpn_server_connected_clients Clients connected to the server.
+# TYPE openvpn_server_connected_clients gauge
+openvpn_server_connected_clients{server="vpn1"} 1
+`
+	names := []string{
+		"openvpn_server_client_bytes_received_total",
+		"openvpn_server_client_connections",
+		"openvpn_server_connected_clients",
+	}
+	c := &Collector{Registry: r, ConstLabels: map[string]string{"server": "vpn1"}}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+
+	// With accounting, traffic covers disconnected clients too.
+	want = `
+# HELP openvpn_server_client_bytes_received_total Bytes received from clients with each common name.
+# TYPE openvpn_server_client_bytes_received_total counter
+openvpn_server_client_bytes_received_total{common_name="alice"} 110
+openvpn_server_client_bytes_received_total{common_name="bob"} 1
+`
+	c = &Collector{Registry: r, Accounting: acct}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), names[0]); err != nil {
+		t.Error(err)
+	}
+}
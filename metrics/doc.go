@@ -0,0 +1,8 @@
+// Package metrics exposes the state tracked by package openvpn as
+// Prometheus metrics.
+//
+// A Collector reads a Session, a ClientRegistry and an Accounting each time
+// it is scraped, so it adds no work between scrapes and needs no events of
+// its own. It implements prometheus.Collector, so it can be registered
+// into an application's existing registry alongside its other metrics.
+package metrics
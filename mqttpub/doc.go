@@ -0,0 +1,23 @@
+// Package mqttpub publishes the events of OpenVPN processes to an MQTT
+// broker, so that deployments already using MQTT for device telemetry can
+// surface the status of their tunnels alongside it.
+//
+// A Publisher sends each event to a topic built from a template naming
+// the instance it came from and the type of the event, such as
+// "openvpn/gw1/StateEvent", with the event encoded by openvpn.MarshalEvent
+// as the payload. The current connection state of the tunnel is also
+// published as a retained message, so that subscribers see it as soon as
+// they subscribe, and SetWill arranges for the broker to replace it should
+// the publisher disconnect unexpectedly:
+//
+//	pub := &mqttpub.Publisher{Instance: "gw1", QoS: 1}
+//	opts := mqtt.NewClientOptions().AddBroker("tcp://broker:1883")
+//	pub.SetWill(opts)
+//	pub.Client = mqtt.NewClient(opts)
+//	if token := pub.Client.Connect(); token.Wait() && token.Error() != nil {
+//		return token.Error()
+//	}
+//
+// The publisher's HandleEvent method should then be passed each event
+// received from the client's event channel.
+package mqttpub
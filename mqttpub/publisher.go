@@ -0,0 +1,164 @@
+package mqttpub
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Default topic templates, used when the corresponding fields of a
+// Publisher are empty. In a template, "{instance}" is replaced with the
+// publisher's instance and "{type}" with the type of the event, such as
+// "StateEvent".
+const (
+	DefaultTopic      = "openvpn/{instance}/{type}"
+	DefaultStateTopic = "openvpn/{instance}/state"
+)
+
+// OfflineState is the state published by the will set with SetWill.
+const OfflineState = "OFFLINE"
+
+// DefaultTimeout is how long a publish waits to be sent or acknowledged
+// when Publisher.Timeout is zero.
+const DefaultTimeout = 5 * time.Second
+
+// errTimeout is reported when a publish is not completed in time.
+var errTimeout = errors.New("timed out publishing to MQTT broker")
+
+// Client publishes messages. It is implemented by mqtt.Client.
+type Client interface {
+	Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token
+}
+
+// State is the payload of the retained message published to the state
+// topic.
+type State struct {
+	Instance string
+	State    string
+
+	// Time is when the state was published, and Description and LocalIP
+	// are taken from the StateEvent reporting the state, if any.
+	Time        time.Time
+	Description string `json:",omitempty"`
+	LocalIP     string `json:",omitempty"`
+}
+
+// Publisher publishes events to an MQTT broker. The fields must not be
+// changed once it is in use.
+type Publisher struct {
+	Client Client
+
+	// Instance identifies the gateway or tunnel the events come from.
+	// The characters "/", "+" and "#" are replaced with underscores where
+	// it is used in topics.
+	Instance string
+
+	// Topic is the template of the topic each event is published to, and
+	// defaults to DefaultTopic.
+	Topic string
+
+	// StateTopic is the template of the topic the current state is
+	// published to as a retained message, and defaults to
+	// DefaultStateTopic. The "{type}" placeholder is not replaced.
+	StateTopic string
+
+	// QoS is the MQTT quality of service of each message: 0 for at most
+	// once, 1 for at least once and 2 for exactly once.
+	QoS byte
+
+	// Filter, if set, selects the events to publish to Topic. The state is
+	// published regardless.
+	Filter openvpn.EventFilter
+
+	// Timeout is how long to wait for each publish to complete, and
+	// defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// OnError, if set, is called when an event could not be published.
+	OnError func(e openvpn.Event, err error)
+}
+
+// HandleEvent publishes the given event to its topic if it is accepted by
+// the filter and, whether or not it is, publishes the new state to the
+// state topic if it is a StateEvent, so that the retained state stays
+// current. Errors are passed to OnError.
+func (p *Publisher) HandleEvent(e openvpn.Event) {
+	if p.Filter == nil || p.Filter(e) {
+		if err := p.Publish(e); err != nil && p.OnError != nil {
+			p.OnError(e, err)
+		}
+	}
+	if st, ok := e.(*openvpn.StateEvent); ok {
+		state := State{
+			Instance:    p.Instance,
+			State:       st.NewState(),
+			Time:        time.Now(),
+			Description: st.Description(),
+			LocalIP:     st.LocalTunnelAddr(),
+		}
+		if err := p.PublishState(state); err != nil && p.OnError != nil {
+			p.OnError(e, err)
+		}
+	}
+}
+
+// Publish publishes an event to its topic.
+func (p *Publisher) Publish(e openvpn.Event) error {
+	payload, err := openvpn.MarshalEvent(e)
+	if err != nil {
+		return err
+	}
+	return p.publish(p.EventTopic(e), false, payload)
+}
+
+// PublishState publishes the given state to the state topic as a retained
+// message, replacing the previous one.
+func (p *Publisher) PublishState(state State) error {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return p.publish(p.topic(p.StateTopic, DefaultStateTopic, ""), true, payload)
+}
+
+// SetWill sets the will of the given client options, so that should the
+// client disconnect without publishing, the broker publishes OfflineState
+// to the state topic as a retained message.
+func (p *Publisher) SetWill(opts *mqtt.ClientOptions) {
+	payload, _ := json.Marshal(State{Instance: p.Instance, State: OfflineState})
+	opts.SetBinaryWill(p.topic(p.StateTopic, DefaultStateTopic, ""), payload, p.QoS, true)
+}
+
+// EventTopic returns the topic the given event is published to.
+func (p *Publisher) EventTopic(e openvpn.Event) string {
+	return p.topic(p.Topic, DefaultTopic, openvpn.EventTypeName(e))
+}
+
+func (p *Publisher) topic(template, def, eventType string) string {
+	if template == "" {
+		template = def
+	}
+	instance := topicReplacer.Replace(p.Instance)
+	if instance == "" {
+		instance = "_"
+	}
+	return strings.NewReplacer("{instance}", instance, "{type}", eventType).Replace(template)
+}
+
+var topicReplacer = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+func (p *Publisher) publish(topic string, retained bool, payload []byte) error {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	token := p.Client.Publish(topic, p.QoS, retained, payload)
+	if !token.WaitTimeout(timeout) {
+		return errTimeout
+	}
+	return token.Error()
+}
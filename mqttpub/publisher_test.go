@@ -0,0 +1,118 @@
+package mqttpub
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type message struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+type fakeToken struct {
+	done chan struct{}
+	err  error
+}
+
+func (t *fakeToken) Wait() bool                       { <-t.done; return true }
+func (t *fakeToken) WaitTimeout(d time.Duration) bool { return d > 0 }
+func (t *fakeToken) Done() <-chan struct{}            { return t.done }
+func (t *fakeToken) Error() error                     { return t.err }
+
+type fakeClient struct {
+	msgs []message
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.msgs = append(c.msgs, message{topic, qos, retained, payload.([]byte)})
+	done := make(chan struct{})
+	close(done)
+	return &fakeToken{done: done}
+}
+
+func TestPublisher(t *testing.T) {
+	tests := []struct {
+		pub    Publisher
+		raw    string
+		topics []string
+
+		// retained is the number of messages at the end that are retained.
+		retained int
+	}{
+		{
+			Publisher{Instance: "gw1"},
+			"BYTECOUNT:10,20",
+			[]string{"openvpn/gw1/ByteCountEvent"},
+			0,
+		},
+		{
+			Publisher{Instance: "eu/gw#1", QoS: 1},
+			"STATE:1234,CONNECTED,SUCCESS,10.8.0.2,",
+			[]string{"openvpn/eu_gw_1/StateEvent", "openvpn/eu_gw_1/state"},
+			1,
+		},
+		{
+			Publisher{
+				Instance:   "gw1",
+				Topic:      "site/{instance}/vpn/{type}",
+				StateTopic: "site/{instance}/vpn",
+				Filter:     func(openvpn.Event) bool { return false },
+			},
+			"STATE:1234,RECONNECTING,ping-restart,,",
+			[]string{"site/gw1/vpn"},
+			1,
+		},
+	}
+
+	for i, test := range tests {
+		client := &fakeClient{}
+		test.pub.Client = client
+		test.pub.HandleEvent(openvpn.ParseEvent([]byte(test.raw)))
+		if len(client.msgs) != len(test.topics) {
+			t.Errorf("test %d got %d messages; want %d", i, len(client.msgs), len(test.topics))
+			continue
+		}
+		for j, m := range client.msgs {
+			if m.topic != test.topics[j] {
+				t.Errorf("test %d message %d got topic %q; want %q", i, j, m.topic, test.topics[j])
+			}
+			if m.qos != test.pub.QoS {
+				t.Errorf("test %d message %d got QoS %d; want %d", i, j, m.qos, test.pub.QoS)
+			}
+			retained := j >= len(test.topics)-test.retained
+			if m.retained != retained {
+				t.Errorf("test %d message %d got retained %v; want %v", i, j, m.retained, retained)
+			}
+		}
+	}
+}
+
+func TestPublishState(t *testing.T) {
+	client := &fakeClient{}
+	p := &Publisher{Client: client, Instance: "gw1"}
+	p.HandleEvent(openvpn.ParseEvent([]byte("STATE:1234,CONNECTED,SUCCESS,10.8.0.2,")))
+
+	var state State
+	if err := json.Unmarshal(client.msgs[1].payload, &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Instance != "gw1" || state.State != "CONNECTED" || state.LocalIP != "10.8.0.2" {
+		t.Errorf("got state %+v", state)
+	}
+
+	opts := mqtt.NewClientOptions()
+	p.SetWill(opts)
+	if opts.WillTopic != "openvpn/gw1/state" || !opts.WillRetained {
+		t.Errorf("got will topic %q retained %v", opts.WillTopic, opts.WillRetained)
+	}
+	if err := json.Unmarshal(opts.WillPayload, &state); err != nil || state.State != OfflineState {
+		t.Errorf("got will payload %q", opts.WillPayload)
+	}
+}
@@ -0,0 +1,18 @@
+// Package natspub publishes the events of OpenVPN processes to NATS, so
+// that a fleet controller can aggregate the events of many gateways by
+// subscribing to their subjects.
+//
+// A Publisher sends each event as a message on a subject naming the
+// instance it came from and the type of the event, such as
+// "openvpn.gw1.StateEvent", with the event encoded by
+// openvpn.MarshalEvent as the body. Messages are published with core NATS,
+// or with JetStream for durable delivery:
+//
+//	nc, _ := nats.Connect(nats.DefaultURL)
+//	js, _ := nc.JetStream()
+//	js.AddStream(natspub.StreamConfig("OPENVPN", natspub.DefaultPrefix))
+//	pub := &natspub.Publisher{Sender: natspub.JetStream(js), Instance: "gw1"}
+//
+// The publisher's HandleEvent method should then be passed each event
+// received from the client's event channel.
+package natspub
@@ -0,0 +1,143 @@
+package natspub
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultPrefix is the first token of the subjects published to when
+// Publisher.Prefix is empty.
+const DefaultPrefix = "openvpn"
+
+// Headers set on each message, naming the instance the event came from
+// and the type of the event.
+const (
+	InstanceHeader  = "Openvpn-Instance"
+	EventTypeHeader = "Openvpn-Event-Type"
+)
+
+// Sender sends messages. It is implemented by *nats.Conn, and by the
+// result of JetStream.
+type Sender interface {
+	PublishMsg(m *nats.Msg) error
+}
+
+// JetStream returns a Sender publishing to JetStream, which waits for each
+// message to be acknowledged by the stream storing it. Each message has
+// a Nats-Msg-Id header, so that the stream discards duplicates should the
+// publish be retried, and the given options are applied to each publish.
+func JetStream(js nats.JetStreamContext, opts ...nats.PubOpt) Sender {
+	return &jetStreamSender{js: js, opts: opts}
+}
+
+type jetStreamSender struct {
+	js   nats.JetStreamContext
+	opts []nats.PubOpt
+}
+
+func (s *jetStreamSender) PublishMsg(m *nats.Msg) error {
+	_, err := s.js.PublishMsg(m, s.opts...)
+	return err
+}
+
+// StreamConfig returns the configuration of a JetStream stream with the
+// given name capturing all the subjects published to with the given
+// prefix, to be adjusted as needed and passed to AddStream.
+func StreamConfig(name, prefix string) *nats.StreamConfig {
+	return &nats.StreamConfig{
+		Name:     name,
+		Subjects: []string{prefix + ".>"},
+	}
+}
+
+// Publisher publishes events to NATS. The fields must not be changed once
+// it is in use.
+type Publisher struct {
+	Sender Sender
+
+	// Prefix is the first token of each subject, and defaults to
+	// DefaultPrefix.
+	Prefix string
+
+	// Instance identifies the gateway or tunnel the events come from, and
+	// is the second token of each subject. Characters not allowed in
+	// subject tokens are replaced with underscores.
+	Instance string
+
+	// Filter, if set, selects the events to publish.
+	Filter openvpn.EventFilter
+
+	// OnError, if set, is called when an event could not be published.
+	OnError func(e openvpn.Event, err error)
+
+	seq atomic.Uint64
+}
+
+// HandleEvent publishes the given event to its subject if it is accepted
+// by the filter, passing any error to OnError. With JetStream, this waits
+// for the event to be acknowledged, so a slow server holds up the handling
+// of events.
+func (p *Publisher) HandleEvent(e openvpn.Event) {
+	if p.Filter != nil && !p.Filter(e) {
+		return
+	}
+	if err := p.Publish(e); err != nil && p.OnError != nil {
+		p.OnError(e, err)
+	}
+}
+
+// Subject returns the subject the given event is published to, such as
+// "openvpn.gw1.StateEvent".
+func (p *Publisher) Subject(e openvpn.Event) string {
+	prefix := p.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	instance := token(p.Instance)
+	if instance == "" {
+		instance = "_"
+	}
+	return prefix + "." + instance + "." + openvpn.EventTypeName(e)
+}
+
+// Publish publishes an event.
+func (p *Publisher) Publish(e openvpn.Event) error {
+	data, err := openvpn.MarshalEvent(e)
+	if err != nil {
+		return err
+	}
+	m := nats.NewMsg(p.Subject(e))
+	m.Data = data
+	m.Header.Set(InstanceHeader, p.Instance)
+	m.Header.Set(EventTypeHeader, openvpn.EventTypeName(e))
+	m.Header.Set(nats.MsgIdHdr, p.msgID())
+	return p.Sender.PublishMsg(m)
+}
+
+// msgID returns a unique id for a message, made of the instance, the time
+// the publisher published its first message and a sequence number.
+func (p *Publisher) msgID() string {
+	seq := p.seq.Add(1)
+	return token(p.Instance) + "-" + strconv.FormatInt(startTime, 36) + "-" + strconv.FormatUint(seq, 36)
+}
+
+// startTime distinguishes the message ids of successive runs of the
+// process.
+var startTime = time.Now().UnixNano()
+
+// token returns s with the characters not allowed in a subject token
+// replaced.
+func token(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '.' || r == '*' || r == '>' || r <= ' ' || r == 0x7f:
+			return '_'
+		}
+		return r
+	}, s)
+}
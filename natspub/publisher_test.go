@@ -0,0 +1,82 @@
+package natspub
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/nats-io/nats.go"
+)
+
+type fakeSender struct {
+	msgs []*nats.Msg
+	err  error
+}
+
+func (s *fakeSender) PublishMsg(m *nats.Msg) error {
+	s.msgs = append(s.msgs, m)
+	return s.err
+}
+
+func TestPublisher(t *testing.T) {
+	tests := []struct {
+		prefix, instance string
+		raw              string
+		subject          string
+	}{
+		{"", "gw1", "STATE:1234,CONNECTED,SUCCESS,,", "openvpn.gw1.StateEvent"},
+		{"fleet", "gw.eu 1", "HOLD:Waiting for hold release", "fleet.gw_eu_1.HoldEvent"},
+		{"", "", "BYTECOUNT:10,20", "openvpn._.ByteCountEvent"},
+	}
+
+	for i, test := range tests {
+		sender := &fakeSender{}
+		p := &Publisher{Sender: sender, Prefix: test.prefix, Instance: test.instance}
+		p.HandleEvent(openvpn.ParseEvent([]byte(test.raw)))
+		if len(sender.msgs) != 1 {
+			t.Errorf("test %d got %d messages; want 1", i, len(sender.msgs))
+			continue
+		}
+		m := sender.msgs[0]
+		if m.Subject != test.subject {
+			t.Errorf("test %d got subject %q; want %q", i, m.Subject, test.subject)
+		}
+		if got := m.Header.Get(InstanceHeader); got != test.instance {
+			t.Errorf("test %d got instance header %q; want %q", i, got, test.instance)
+		}
+		if m.Header.Get(nats.MsgIdHdr) == "" {
+			t.Errorf("test %d has no message id", i)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(m.Data, &body); err != nil {
+			t.Errorf("test %d body is not JSON: %s", i, err)
+		}
+	}
+}
+
+func TestPublisherFilterAndErrors(t *testing.T) {
+	sender := &fakeSender{err: errors.New("no responders")}
+	var failed int
+	p := &Publisher{
+		Sender: sender,
+		Filter: func(e openvpn.Event) bool {
+			_, ok := e.(*openvpn.StateEvent)
+			return ok
+		},
+		OnError: func(e openvpn.Event, err error) { failed++ },
+	}
+	p.HandleEvent(openvpn.ParseEvent([]byte("BYTECOUNT:10,20")))
+	p.HandleEvent(openvpn.ParseEvent([]byte("STATE:1234,CONNECTED,SUCCESS,,")))
+	p.HandleEvent(openvpn.ParseEvent([]byte("STATE:1235,EXITING,SIGTERM,,")))
+
+	if len(sender.msgs) != 2 {
+		t.Fatalf("got %d messages; want 2", len(sender.msgs))
+	}
+	if failed != 2 {
+		t.Errorf("got %d errors; want 2", failed)
+	}
+	if a, b := sender.msgs[0].Header.Get(nats.MsgIdHdr), sender.msgs[1].Header.Get(nats.MsgIdHdr); a == b {
+		t.Errorf("messages have the same id %q", a)
+	}
+}
@@ -0,0 +1,28 @@
+// Package notify formats the events of OpenVPN processes with
+// user-supplied templates and delivers the results to sinks such as
+// webhooks, standard output and commands, so that the format of alerts is
+// kept separate from how they are sent.
+//
+// A Notifier has a list of routes, each selecting events with a filter,
+// rendering them with a Template and sending the result to its sinks.
+// Templates use the syntax of text/template and are executed with a Data
+// value describing the event and, where available, the tunnel's session
+// and the client the event concerns. Templates made with JSON must
+// produce valid JSON, and can use the "json" function to encode values, or
+// embed the event in its JSON form using .JSON:
+//
+//	tmpl, _ := notify.JSON("slack", `{"text": {{printf "%s: %s" .Source .Event | json}}}`)
+//	n := &notify.Notifier{
+//		Source:  "vpn1",
+//		Session: session,
+//		Routes: []notify.Route{{
+//			Filter:   webhook.DefaultFilter,
+//			Template: tmpl,
+//			Sinks:    []notify.Sink{&notify.Webhook{URL: slackURL}},
+//		}},
+//	}
+//	go n.Run(ctx)
+//
+// The notifier's HandleEvent method should then be passed each event
+// received from the client's event channel.
+package notify
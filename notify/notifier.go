@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/internal/queue"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// Defaults for the fields of a Notifier.
+const (
+	DefaultQueueSize = 256
+	DefaultTimeout   = 10 * time.Second
+)
+
+// Route selects the events to notify and how.
+type Route struct {
+	// Filter selects the events the route applies to. If nil, the route
+	// applies to all events.
+	Filter openvpn.EventFilter
+
+	// Template renders the message sent to each of the Sinks.
+	Template *Template
+	Sinks    []Sink
+}
+
+// Notifier renders events and sends them to sinks, as directed by its
+// routes. The fields must not be changed once it is in use.
+type Notifier struct {
+	Routes []Route
+
+	// Source, if set, identifies the tunnel or server in the data given to
+	// templates.
+	Source string
+
+	// Session and Registry, if set, provide the session and client in the
+	// data given to templates.
+	Session  *openvpn.Session
+	Registry *openvpn.ClientRegistry
+
+	// QueueSize is the number of events queued to be notified, beyond
+	// which events are dropped, as counted by Dropped.
+	QueueSize int
+
+	// Timeout limits each attempt to send a message to a sink, and
+	// defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// OnError, if set, is called when an event could not be rendered or
+	// sent by a route.
+	OnError func(route int, err error)
+
+	pending queue.Queue[Data]
+}
+
+func (n *Notifier) queue() *queue.Queue[Data] {
+	return n.pending.Init(n.QueueSize, DefaultQueueSize)
+}
+
+// HandleEvent queues the given event to be rendered and sent by Run, if
+// any route applies to it, along with the session and client as they are
+// now. It never blocks, dropping the event if the queue is full. The event
+// is copied if necessary, so it may be released with openvpn.ReleaseEvent
+// once HandleEvent returns.
+func (n *Notifier) HandleEvent(e openvpn.Event) {
+	for _, r := range n.Routes {
+		if r.Filter == nil || r.Filter(e) {
+			n.queue().Add(n.data(openvpn.CopyEvent(e), time.Now()))
+			return
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was
+// full.
+func (n *Notifier) Dropped() uint64 {
+	return n.pending.Dropped()
+}
+
+// data returns the data for an event handled at the given time.
+func (n *Notifier) data(e openvpn.Event, t time.Time) Data {
+	d := Data{Time: t, Source: n.Source, Event: e, Type: openvpn.EventTypeName(e)}
+	if buf, err := openvpn.MarshalEvent(e); err == nil {
+		d.JSON = string(buf)
+	}
+	if n.Session != nil {
+		snap := n.Session.Snapshot()
+		d.Session = &snap
+	}
+	if n.Registry != nil {
+		cid := int64(-1)
+		switch e := e.(type) {
+		case *openvpn.ClientEvent:
+			cid = e.ClientID()
+		case *openvpn.ByteCountEvent:
+			if id, err := strconv.ParseInt(e.ClientId(), 10, 64); err == nil {
+				cid = id
+			}
+		}
+		if cc, ok := n.Registry.Client(cid); ok {
+			d.Client = &cc
+		}
+	}
+	return d
+}
+
+// Run notifies queued events, in the order they were queued, until ctx is
+// cancelled, returning ctx.Err(). Events still queued are then discarded.
+func (n *Notifier) Run(ctx context.Context) error {
+	queued := n.queue().C()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d := <-queued:
+			n.notify(ctx, d)
+		}
+	}
+}
+
+// Notify renders and sends an event handled at the given time by each
+// route that applies to it, returning the first error encountered, for
+// callers that don't use HandleEvent and Run. Errors are also passed to
+// OnError.
+func (n *Notifier) Notify(ctx context.Context, e openvpn.Event, t time.Time) error {
+	return n.notify(ctx, n.data(e, t))
+}
+
+func (n *Notifier) notify(ctx context.Context, d Data) error {
+	timeout := n.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	var first error
+	fail := func(route int, err error) {
+		if first == nil {
+			first = err
+		}
+		if n.OnError != nil && ctx.Err() == nil {
+			n.OnError(route, err)
+		}
+	}
+	for i, r := range n.Routes {
+		if r.Filter != nil && !r.Filter(d.Event) {
+			continue
+		}
+		msg, err := r.Template.Render(d)
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		for _, sink := range r.Sinks {
+			sendCtx, cancel := context.WithTimeout(ctx, timeout)
+			err := sink.Send(sendCtx, msg)
+			cancel()
+			if err != nil {
+				fail(i, err)
+			}
+		}
+	}
+	return first
+}
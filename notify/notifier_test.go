@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"github.com/NordSecurity/gopenvpn/webhook"
+)
+
+func TestNotifier(t *testing.T) {
+	var posted []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(webhook.SignatureHeader)
+	}))
+	defer srv.Close()
+
+	text, err := Text("text", `{{.Source}}: {{.Event}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := JSON("json", `{"state": {{json .Session.State}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	session := &openvpn.Session{}
+	secret := []byte("secret")
+	n := &Notifier{
+		Source:  "vpn1",
+		Session: session,
+		Routes: []Route{
+			{Template: text, Sinks: []Sink{&Writer{W: &out}}},
+			{
+				Filter: func(e openvpn.Event) bool {
+					_, ok := e.(*openvpn.StateEvent)
+					return ok
+				},
+				Template: js,
+				Sinks:    []Sink{&Webhook{URL: srv.URL, Secret: secret}},
+			},
+		},
+	}
+
+	for _, raw := range []string{"HOLD:Waiting for hold release", "STATE:1234,CONNECTED,SUCCESS,,"} {
+		e := openvpn.ParseEvent([]byte(raw))
+		session.HandleEvent(e)
+		if err := n.Notify(context.Background(), e, time.Now()); err != nil {
+			t.Errorf("Notify failed: %v", err)
+		}
+	}
+	if want := "vpn1: Waiting for hold release\nvpn1: CONNECTED: \n"; out.String() != want {
+		t.Errorf("got output %q; want %q", out.String(), want)
+	}
+	if string(posted) != `{"state":"CONNECTED"}` || !webhook.Verify(secret, posted, signature) {
+		t.Errorf("got posted %q with signature %q", posted, signature)
+	}
+}
+
+func TestExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	path := filepath.Join(t.TempDir(), "out")
+	sink := &Exec{Path: "sh", Args: []string{"-c", `{ echo "$NOTIFY_SOURCE $NOTIFY_TYPE"; cat; } > "$OUT"`}, Env: []string{"OUT=" + path}}
+	msg := Message{Body: []byte("hello"), Data: Data{Source: "vpn1", Type: "StateEvent"}}
+	if err := sink.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "vpn1 StateEvent\nhello" {
+		t.Errorf("got %q", got)
+	}
+
+	sink = &Exec{Path: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}}
+	if err := sink.Send(context.Background(), msg); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("got error %v; want one including the output", err)
+	}
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/NordSecurity/gopenvpn/webhook"
+)
+
+// Sink delivers messages.
+type Sink interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Webhook is a sink posting each message to a URL, with the message's
+// content type. Unlike webhook.Dispatcher, it makes a single attempt.
+type Webhook struct {
+	URL string
+
+	// Header holds additional headers for each request, such as for
+	// authentication.
+	Header http.Header
+
+	// Secret, if set, is used to sign each request as described for
+	// webhook.SignatureHeader.
+	Secret []byte
+
+	// Client is used to make requests, and defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+func (w *Webhook) Send(ctx context.Context, msg Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+	for name, values := range w.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", msg.ContentType)
+	if len(w.Secret) > 0 {
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(w.Secret, msg.Body))
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// Writer is a sink writing each message to W, such as os.Stdout, followed
+// by a newline.
+type Writer struct {
+	W io.Writer
+
+	mu sync.Mutex
+}
+
+func (w *Writer) Send(ctx context.Context, msg Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.W.Write(append(msg.Body[:len(msg.Body):len(msg.Body)], '\n'))
+	return err
+}
+
+// Exec is a sink running a command for each message, with the message on
+// its standard input. The command's environment also has
+// NOTIFY_SOURCE, NOTIFY_TYPE and NOTIFY_CONTENT_TYPE set to the source of
+// the event, the name of its type and the content type of the message.
+type Exec struct {
+	Path string
+	Args []string
+
+	// Env, if set, is the environment of the command, to which the
+	// variables above are added. Otherwise the command inherits the
+	// environment of the process.
+	Env []string
+}
+
+func (e *Exec) Send(ctx context.Context, msg Message) error {
+	cmd := exec.CommandContext(ctx, e.Path, e.Args...)
+	env := e.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env[:len(env):len(env)],
+		"NOTIFY_SOURCE="+msg.Data.Source,
+		"NOTIFY_TYPE="+msg.Data.Type,
+		"NOTIFY_CONTENT_TYPE="+msg.ContentType,
+	)
+	cmd.Stdin = bytes.NewReader(msg.Body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("%s: %w: %s", e.Path, err, bytes.TrimSpace(out))
+		}
+		return fmt.Errorf("%s: %w", e.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// Data is the data a Template is executed with.
+type Data struct {
+	// Time is when the event was handled, and Source the notifier's
+	// Source.
+	Time   time.Time
+	Source string
+
+	// Event is the event, Type the name of its type, such as
+	// "StateEvent", and JSON its encoding by openvpn.MarshalEvent.
+	Event openvpn.Event
+	Type  string
+	JSON  string
+
+	// Session is a snapshot of the notifier's Session when the event was
+	// handled, if it has one.
+	Session *openvpn.Snapshot
+
+	// Client is the client of a server the event concerns, if the
+	// notifier has a Registry in which it was found.
+	Client *openvpn.ConnectedClient
+}
+
+// Message is the result of rendering a Template, as passed to sinks.
+type Message struct {
+	Body        []byte
+	ContentType string
+
+	// Data is the data the template was executed with.
+	Data Data
+}
+
+// funcs are the functions available to templates, in addition to those
+// built into text/template.
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	},
+}
+
+// Template renders events as messages.
+type Template struct {
+	tmpl        *template.Template
+	contentType string
+	json        bool
+}
+
+// Text returns a template producing plain text from the given template
+// text.
+func Text(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl, contentType: "text/plain; charset=utf-8"}, nil
+}
+
+// JSON returns a template producing JSON from the given template text, and
+// failing to render output that is not valid JSON.
+func JSON(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl, contentType: "application/json", json: true}, nil
+}
+
+// Render executes the template with the given data.
+func (t *Template) Render(d Data) (Message, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, d); err != nil {
+		return Message{}, err
+	}
+	if t.json {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, buf.Bytes()); err != nil {
+			return Message{}, fmt.Errorf("template %s produced invalid JSON: %w", t.tmpl.Name(), err)
+		}
+		buf = compact
+	}
+	return Message{Body: buf.Bytes(), ContentType: t.contentType, Data: d}, nil
+}
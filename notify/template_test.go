@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestTemplate(t *testing.T) {
+	data := Data{
+		Time:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Source:  "vpn1",
+		Event:   openvpn.ParseEvent([]byte("STATE:1234,RECONNECTING,ping-restart,,")),
+		Type:    "StateEvent",
+		JSON:    `{"state":"RECONNECTING","type":"StateEvent"}`,
+		Session: &openvpn.Snapshot{State: openvpn.StateReconnecting},
+	}
+	tests := []struct {
+		json    bool
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{false, `{{.Source}} is {{.Session.State}}: {{.Event}}`, "vpn1 is RECONNECTING: RECONNECTING: ping-restart", false},
+		{true, `{"text": {{printf "%s: \"%s\"" .Source .Event | json}},
+			"at": {{json .Time}}}`, `{"text":"vpn1: \"RECONNECTING: ping-restart\"","at":"2024-05-01T12:00:00Z"}`, false},
+		{true, `{"event": {{.JSON}}}`, `{"event":{"state":"RECONNECTING","type":"StateEvent"}}`, false},
+		{true, `{"text": "{{.Event}}}`, "", true},
+		{false, `{{.Client.CommonName}}`, "", true},
+	}
+	for i, test := range tests {
+		newTemplate := Text
+		if test.json {
+			newTemplate = JSON
+		}
+		tmpl, err := newTemplate("test", test.text)
+		if err != nil {
+			t.Errorf("test %d failed to parse: %v", i, err)
+			continue
+		}
+		msg, err := tmpl.Render(data)
+		if (err != nil) != test.wantErr {
+			t.Errorf("test %d got error %v; want error %v", i, err, test.wantErr)
+		}
+		if string(msg.Body) != test.want {
+			t.Errorf("test %d got %q; want %q", i, msg.Body, test.want)
+		}
+	}
+}
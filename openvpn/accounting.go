@@ -0,0 +1,301 @@
+package openvpn
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage is the cumulative data transfer of all of the sessions of clients
+// with a given common name, as tracked by Accounting.
+type Usage struct {
+	CommonName string
+
+	// BytesReceived and BytesSent are from the point of view of the
+	// server, as in ConnectedClient.
+	BytesReceived int64
+	BytesSent     int64
+
+	// Sessions counts the client connections that have been seen.
+	Sessions int
+
+	// LastSeen is when the usage was last updated.
+	LastSeen time.Time
+}
+
+// UsageStore persists the usage tracked by Accounting, for example in
+// a billing or quota system's database.
+type UsageStore interface {
+	// LoadUsage returns all of the stored usage.
+	LoadUsage() ([]Usage, error)
+
+	// SaveUsage stores the given usage, replacing any stored for the same
+	// common names.
+	SaveUsage(usage []Usage) error
+}
+
+// Accounting tracks the cumulative data transfer of the clients of an
+// OpenVPN server by common name, across reconnections, from the changes
+// reported by a ClientRegistry. Its HandleChange method can be called
+// from the registry's OnChange function.
+//
+// The byte counts of each client connection are reported by OpenVPN from
+// the start of the connection, and so fall back to zero when the client
+// reconnects, but may also be reset while the client id stays the same,
+// such as when the server restarts. Accounting adds the increase of each
+// count since the previous change to the usage, treating a decrease as
+// a reset.
+//
+// The counts of connections in progress when Accounting is first used
+// are counted from the start of those connections, which may count some
+// data twice if it was already counted before, such as by a previous
+// process using the same UsageStore, unless the previous process's
+// SessionCounters are restored using RestoreSessionCounters.
+//
+// The zero value tracks usage in memory only. An Accounting is safe for
+// concurrent use.
+type Accounting struct {
+	// Store, if set, persists usage. Usage is loaded from it by Load and
+	// saved to it by Flush.
+	Store UsageStore
+
+	mu       sync.Mutex
+	usage    map[string]*Usage
+	dirty    map[string]bool
+	sessions map[int64]accountingSession
+}
+
+// accountingSession records the byte counts of a client connection when
+// they were last added to the usage.
+type accountingSession struct {
+	commonName     string
+	received, sent int64
+}
+
+// Load replaces the tracked usage with the usage in the Store.
+func (a *Accounting) Load() error {
+	if a.Store == nil {
+		return nil
+	}
+	usage, err := a.Store.LoadUsage()
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usage = make(map[string]*Usage, len(usage))
+	for _, u := range usage {
+		u := u
+		a.usage[u.CommonName] = &u
+	}
+	a.dirty = nil
+	return nil
+}
+
+// HandleChange adds the data transferred by a client since its previous
+// change to the usage of its common name. Clients without a common name
+// are ignored.
+func (a *Accounting) HandleChange(c ClientChange) {
+	cc := c.Client
+	if cc.CommonName == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.sessions == nil {
+		a.sessions = map[int64]accountingSession{}
+	}
+	prev, known := a.sessions[cc.ClientID]
+	if known && prev.commonName != cc.CommonName {
+		// The client id has been reused, such as by a restarted server.
+		known = false
+	}
+	if !known {
+		prev = accountingSession{commonName: cc.CommonName}
+	}
+
+	u := a.usageLocked(cc.CommonName)
+	if !known {
+		u.Sessions++
+	}
+	u.BytesReceived += counterDelta(prev.received, cc.BytesReceived)
+	u.BytesSent += counterDelta(prev.sent, cc.BytesSent)
+	u.LastSeen = time.Now()
+	a.markDirtyLocked(cc.CommonName)
+
+	if c.Kind == ClientDisconnected {
+		delete(a.sessions, cc.ClientID)
+		return
+	}
+	a.sessions[cc.ClientID] = accountingSession{
+		commonName: cc.CommonName,
+		received:   cc.BytesReceived,
+		sent:       cc.BytesSent,
+	}
+}
+
+func (a *Accounting) usageLocked(cn string) *Usage {
+	if a.usage == nil {
+		a.usage = map[string]*Usage{}
+	}
+	u, ok := a.usage[cn]
+	if !ok {
+		u = &Usage{CommonName: cn}
+		a.usage[cn] = u
+	}
+	return u
+}
+
+func (a *Accounting) markDirtyLocked(cn string) {
+	if a.dirty == nil {
+		a.dirty = map[string]bool{}
+	}
+	a.dirty[cn] = true
+}
+
+// counterDelta returns the increase of a byte count from prev to cur,
+// assuming that the counter was reset if it decreased.
+func counterDelta(prev, cur int64) int64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// Usage returns the usage of the given common name, and false if none has
+// been tracked.
+func (a *Accounting) Usage(cn string) (Usage, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u, ok := a.usage[cn]
+	if !ok {
+		return Usage{}, false
+	}
+	return *u, true
+}
+
+// All returns the usage of every common name tracked, ordered by common
+// name.
+func (a *Accounting) All() []Usage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ret := make([]Usage, 0, len(a.usage))
+	for _, u := range a.usage {
+		ret = append(ret, *u)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CommonName < ret[j].CommonName
+	})
+	return ret
+}
+
+// Reset discards the usage of the given common name, for example at the
+// end of a billing period. Data transferred afterwards by connections in
+// progress is counted from the time of the reset.
+func (a *Accounting) Reset(cn string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.usage[cn]; !ok {
+		return
+	}
+	a.usage[cn] = &Usage{CommonName: cn, LastSeen: time.Now()}
+	a.markDirtyLocked(cn)
+}
+
+// Flush saves the usage that has changed since the last Flush to the
+// Store. If saving fails, the usage is saved at the next Flush instead.
+func (a *Accounting) Flush() error {
+	if a.Store == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	changed := make([]Usage, 0, len(a.dirty))
+	for cn := range a.dirty {
+		changed = append(changed, *a.usage[cn])
+	}
+	a.dirty = nil
+	a.mu.Unlock()
+	if len(changed) == 0 {
+		return nil
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return changed[i].CommonName < changed[j].CommonName
+	})
+
+	err := a.Store.SaveUsage(changed)
+	if err != nil {
+		a.mu.Lock()
+		for _, u := range changed {
+			a.markDirtyLocked(u.CommonName)
+		}
+		a.mu.Unlock()
+	}
+	return err
+}
+
+// JSONUsageStore is a UsageStore that keeps usage in a JSON file at the
+// given path, which is replaced atomically each time usage is saved.
+// A missing file holds no usage.
+type JSONUsageStore string
+
+func (s JSONUsageStore) LoadUsage() ([]Usage, error) {
+	buf, err := os.ReadFile(string(s))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var usage []Usage
+	if err := json.Unmarshal(buf, &usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (s JSONUsageStore) SaveUsage(usage []Usage) error {
+	stored, err := s.LoadUsage()
+	if err != nil {
+		return err
+	}
+	merged := make(map[string]Usage, len(stored)+len(usage))
+	for _, u := range stored {
+		merged[u.CommonName] = u
+	}
+	for _, u := range usage {
+		merged[u.CommonName] = u
+	}
+	all := make([]Usage, 0, len(merged))
+	for _, u := range merged {
+		all = append(all, u)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].CommonName < all[j].CommonName
+	})
+
+	buf, err := json.MarshalIndent(all, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(string(s)), filepath.Base(string(s))+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), string(s))
+}
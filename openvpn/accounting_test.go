@@ -0,0 +1,67 @@
+package openvpn
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAccounting(t *testing.T) {
+	store := JSONUsageStore(filepath.Join(t.TempDir(), "usage.json"))
+	a := &Accounting{Store: store}
+	if err := a.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	change := func(kind ClientChangeKind, cid int64, cn string, received, sent int64) {
+		a.HandleChange(ClientChange{Kind: kind, Client: ConnectedClient{
+			ClientID:      cid,
+			CommonName:    cn,
+			BytesReceived: received,
+			BytesSent:     sent,
+		}})
+	}
+	change(ClientConnected, 1, "alice", 0, 0)
+	change(ClientUpdated, 1, "alice", 100, 200)
+	change(ClientConnected, 2, "bob", 10, 20)
+	change(ClientUpdated, 1, "alice", 150, 300)
+	// The server restarted, resetting alice's counters.
+	change(ClientUpdated, 1, "alice", 40, 50)
+	change(ClientDisconnected, 1, "alice", 60, 70)
+	// alice reconnects with a new client id.
+	change(ClientConnected, 3, "alice", 5, 5)
+	change(ClientUpdated, 4, "", 1000, 1000)
+
+	want := []Usage{
+		{CommonName: "alice", BytesReceived: 215, BytesSent: 375, Sessions: 2},
+		{CommonName: "bob", BytesReceived: 10, BytesSent: 20, Sessions: 1},
+	}
+	if got := withoutLastSeen(a.All()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got usage %+v; want %+v", got, want)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	a.Reset("bob")
+	if err := a.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	loaded := &Accounting{Store: store}
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want[1] = Usage{CommonName: "bob"}
+	if got := withoutLastSeen(loaded.All()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got loaded usage %+v; want %+v", got, want)
+	}
+}
+
+func withoutLastSeen(usage []Usage) []Usage {
+	for i := range usage {
+		usage[i].LastSeen = time.Time{}
+	}
+	return usage
+}
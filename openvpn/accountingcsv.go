@@ -0,0 +1,177 @@
+package openvpn
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accountingColumns are the columns of the CSV written by AccountingCSV.
+var accountingColumns = []string{
+	"common_name", "username", "real_address", "virtual_address", "virtual_ipv6_address",
+	"connected", "disconnected", "duration_seconds", "bytes_received", "bytes_sent", "reason",
+}
+
+// SessionRecord is the accounting record of a completed client connection
+// to an OpenVPN server.
+type SessionRecord struct {
+	CommonName         string
+	Username           string
+	RealAddress        string
+	VirtualAddress     string
+	VirtualIPv6Address string
+
+	Connected    time.Time
+	Disconnected time.Time
+
+	// BytesReceived and BytesSent are from the point of view of the
+	// server, as in ConnectedClient.
+	BytesReceived int64
+	BytesSent     int64
+
+	// Reason is why the connection ended, as given by ClientChange.Reason.
+	Reason string
+}
+
+// AccountingCSV writes a SessionRecord as a row of CSV for each client
+// connection that ends, for billing pipelines that ingest flat files. Its
+// HandleChange method can be called from a ClientRegistry's OnChange
+// function.
+//
+// The first row is a header naming the columns: common_name, username,
+// real_address, virtual_address, virtual_ipv6_address, connected,
+// disconnected, duration_seconds, bytes_received, bytes_sent and reason.
+// Times are in RFC 3339 format.
+type AccountingCSV struct {
+	mu     sync.Mutex
+	w      *csv.Writer
+	gz     *gzip.Writer
+	closer io.Closer
+	header bool
+	err    error
+}
+
+// NewAccountingCSV returns an accounting writer writing to w, starting
+// with the header.
+func NewAccountingCSV(w io.Writer) *AccountingCSV {
+	return &AccountingCSV{w: csv.NewWriter(w), header: true}
+}
+
+// OpenAccountingCSV returns an accounting writer appending to the file at
+// the given path, creating it if necessary, and writing the header only if
+// the file is empty. If the path ends in ".gz", the records are compressed
+// with gzip, each write appending a new gzip member, which tools such as
+// gzip and zcat read as a single stream. The file is closed by closing the
+// writer.
+func OpenAccountingCSV(path string) (*AccountingCSV, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	a := &AccountingCSV{closer: f, header: info.Size() == 0}
+	if strings.HasSuffix(path, ".gz") {
+		a.gz = gzip.NewWriter(f)
+		a.w = csv.NewWriter(a.gz)
+	} else {
+		a.w = csv.NewWriter(f)
+	}
+	return a, nil
+}
+
+// HandleChange writes the record of a client that has disconnected,
+// ignoring other changes.
+func (a *AccountingCSV) HandleChange(c ClientChange) {
+	if c.Kind != ClientDisconnected {
+		return
+	}
+	cc := c.Client
+	a.Write(SessionRecord{
+		CommonName:         cc.CommonName,
+		Username:           cc.Username,
+		RealAddress:        cc.RealAddress,
+		VirtualAddress:     cc.VirtualAddress,
+		VirtualIPv6Address: cc.VirtualIPv6Address,
+		Connected:          cc.ConnectedSince,
+		Disconnected:       time.Now(),
+		BytesReceived:      cc.BytesReceived,
+		BytesSent:          cc.BytesSent,
+		Reason:             c.Reason,
+	})
+}
+
+// Write writes a record, flushing it to the underlying writer. It fails if
+// the record could not be written, in which case the error is also kept
+// for Err.
+func (a *AccountingCSV) Write(rec SessionRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.header {
+		a.w.Write(accountingColumns)
+	}
+	var duration string
+	if !rec.Connected.IsZero() && !rec.Disconnected.IsZero() {
+		duration = strconv.FormatInt(int64(rec.Disconnected.Sub(rec.Connected)/time.Second), 10)
+	}
+	a.w.Write([]string{
+		rec.CommonName,
+		rec.Username,
+		rec.RealAddress,
+		rec.VirtualAddress,
+		rec.VirtualIPv6Address,
+		formatRecordTime(rec.Connected),
+		formatRecordTime(rec.Disconnected),
+		duration,
+		strconv.FormatInt(rec.BytesReceived, 10),
+		strconv.FormatInt(rec.BytesSent, 10),
+		rec.Reason,
+	})
+	a.w.Flush()
+	err := a.w.Error()
+	if err == nil && a.gz != nil {
+		err = a.gz.Close()
+		a.gz.Reset(a.closer.(io.Writer))
+	}
+	if err != nil {
+		a.err = err
+		return err
+	}
+	a.header = false
+	return nil
+}
+
+func formatRecordTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Err returns the most recent error encountered while writing records, if
+// any. Writing continues after an error.
+func (a *AccountingCSV) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Close closes the file of an accounting writer returned by
+// OpenAccountingCSV. It does nothing for other accounting writers.
+func (a *AccountingCSV) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
@@ -0,0 +1,89 @@
+package openvpn
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccountingCSV(t *testing.T) {
+	connected := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	alice := SessionRecord{
+		CommonName:     "alice",
+		RealAddress:    "203.0.113.7:51820",
+		VirtualAddress: "10.8.0.6",
+		Connected:      connected,
+		Disconnected:   connected.Add(90 * time.Minute),
+		BytesReceived:  1000,
+		BytesSent:      2000,
+		Reason:         DisconnectReported,
+	}
+	bob := SessionRecord{CommonName: "bob", Reason: DisconnectMissing}
+	want := [][]string{
+		accountingColumns,
+		{"alice", "", "203.0.113.7:51820", "10.8.0.6", "", "2024-03-01T12:00:00Z", "2024-03-01T13:30:00Z", "5400", "1000", "2000", "disconnect"},
+		{"bob", "", "", "", "", "", "", "", "0", "0", "missing"},
+	}
+
+	tests := []string{"accounting.csv", "accounting.csv.gz"}
+	for i, name := range tests {
+		path := filepath.Join(t.TempDir(), name)
+		// Each record is written by a separate writer, as by successive
+		// runs of a server, so that the header is only written once.
+		for _, rec := range []SessionRecord{alice, bob} {
+			a, err := OpenAccountingCSV(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Write(rec); err != nil {
+				t.Errorf("test %d Write failed: %v", i, err)
+			}
+			a.Close()
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		r := csv.NewReader(f)
+		if strings.HasSuffix(name, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = csv.NewReader(gz)
+		}
+		got, err := r.ReadAll()
+		if err != nil {
+			t.Errorf("test %d ReadAll failed: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d got %q; want %q", i, got, want)
+		}
+	}
+}
+
+func TestAccountingCSVHandleChange(t *testing.T) {
+	var buf strings.Builder
+	a := NewAccountingCSV(&buf)
+	r := &ClientRegistry{OnChange: a.HandleChange}
+	r.Sync([]ClientStatus{{ClientID: 1, CommonName: "alice", BytesReceived: 10, BytesSent: 20}})
+	r.Sync(nil)
+
+	rows, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows; want 2", len(rows))
+	}
+	if rows[1][0] != "alice" || rows[1][8] != "10" || rows[1][9] != "20" || rows[1][10] != DisconnectMissing {
+		t.Errorf("got row %q", rows[1])
+	}
+}
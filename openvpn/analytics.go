@@ -0,0 +1,126 @@
+package openvpn
+
+import "time"
+
+// Analytics are statistics about a tunnel's connections, derived from the
+// transitions observed by a Session, for example for SLA reporting.
+//
+// Durations are measured using the timestamps OpenVPN gives its state
+// events, so they are accurate to the second, and include the current
+// state up to the time Analytics was called.
+type Analytics struct {
+	// Connects counts the times the tunnel reached the CONNECTED state,
+	// and Reconnects the times it entered the RECONNECTING state.
+	Connects   int
+	Reconnects int
+
+	// LastSetup is how long the most recent connection took to set up,
+	// from the start of the attempt, such as when the tunnel entered the
+	// CONNECTING or RECONNECTING state, to when it reached CONNECTED.
+	// AverageSetup is the mean over all connections.
+	LastSetup    time.Duration
+	AverageSetup time.Duration
+
+	// FirstConnected is when the tunnel first connected, or the zero time
+	// if it has not.
+	FirstConnected time.Time
+
+	// Downtime is the total time the tunnel has spent in states other than
+	// CONNECTED since it first connected, up to when it began exiting.
+	Downtime time.Duration
+
+	// LongestStable is the longest time the tunnel has remained in the
+	// CONNECTED state.
+	LongestStable time.Duration
+}
+
+// sessionAnalytics accumulates the Analytics of a Session.
+type sessionAnalytics struct {
+	connects, reconnects  int
+	setups                int
+	lastSetup, totalSetup time.Duration
+	firstConnected        time.Time
+	downtime, longest     time.Duration
+
+	// setupStart is when the current attempt to connect began, downSince
+	// when the tunnel last stopped being connected and upSince when it
+	// last connected, each zero if not applicable.
+	setupStart time.Time
+	downSince  time.Time
+	upSince    time.Time
+}
+
+func (a *sessionAnalytics) update(t Transition) {
+	if t.From == StateConnected && !a.upSince.IsZero() {
+		if stable := t.Time.Sub(a.upSince); stable > a.longest {
+			a.longest = stable
+		}
+		a.upSince = time.Time{}
+		a.downSince = t.Time
+	}
+
+	switch t.To {
+	case StateConnected:
+		a.connects++
+		if !a.setupStart.IsZero() {
+			a.lastSetup = t.Time.Sub(a.setupStart)
+			a.totalSetup += a.lastSetup
+			a.setups++
+			a.setupStart = time.Time{}
+		}
+		if !a.downSince.IsZero() {
+			a.downtime += t.Time.Sub(a.downSince)
+			a.downSince = time.Time{}
+		}
+		if a.firstConnected.IsZero() {
+			a.firstConnected = t.Time
+		}
+		a.upSince = t.Time
+	case StateExiting:
+		// The tunnel is shutting down rather than being down, so stop
+		// counting.
+		a.setupStart = time.Time{}
+		if !a.downSince.IsZero() {
+			a.downtime += t.Time.Sub(a.downSince)
+			a.downSince = time.Time{}
+		}
+	case StateConnecting, StateReconnecting:
+		if t.To == StateReconnecting {
+			a.reconnects++
+		}
+		a.setupStart = t.Time
+	default:
+		if a.setupStart.IsZero() {
+			a.setupStart = t.Time
+		}
+	}
+}
+
+// Analytics returns statistics about the tunnel's connections.
+func (s *Session) Analytics() Analytics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := &s.analytics
+	now := time.Now()
+	ret := Analytics{
+		Connects:       a.connects,
+		Reconnects:     a.reconnects,
+		LastSetup:      a.lastSetup,
+		FirstConnected: a.firstConnected,
+		Downtime:       a.downtime,
+		LongestStable:  a.longest,
+	}
+	if a.setups > 0 {
+		ret.AverageSetup = a.totalSetup / time.Duration(a.setups)
+	}
+	if !a.downSince.IsZero() {
+		ret.Downtime += now.Sub(a.downSince)
+	}
+	if !a.upSince.IsZero() {
+		if stable := now.Sub(a.upSince); stable > ret.LongestStable {
+			ret.LongestStable = stable
+		}
+	}
+	return ret
+}
@@ -0,0 +1,38 @@
+package openvpn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionAnalytics(t *testing.T) {
+	s := &Session{}
+	for _, raw := range []string{
+		"STATE:1000,CONNECTING,,,",
+		"STATE:1002,WAIT,,,",
+		"STATE:1004,AUTH,,,",
+		"STATE:1010,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"STATE:1110,RECONNECTING,ping-restart,,",
+		"STATE:1112,WAIT,,,",
+		"STATE:1114,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"STATE:1134,RECONNECTING,SIGUSR1,,",
+		"STATE:1140,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"STATE:1150,EXITING,SIGTERM,,",
+	} {
+		s.HandleEvent(upgradeEvent([]byte(raw)))
+	}
+
+	got := s.Analytics()
+	want := Analytics{
+		Connects:       3,
+		Reconnects:     2,
+		LastSetup:      6 * time.Second,
+		AverageSetup:   20 * time.Second / 3,
+		FirstConnected: time.Unix(1010, 0),
+		Downtime:       10 * time.Second,
+		LongestStable:  100 * time.Second,
+	}
+	if got != want {
+		t.Errorf("got analytics %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,238 @@
+package openvpn
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditRedactedCommands lists the commands whose final argument is a secret
+// that is replaced in audit records, including the pushed auth-token
+// directives that may appear in the payload of client-auth.
+var auditRedactedCommands = []string{
+	"password ",
+	"cr-response ",
+	`push "auth-token `,
+	`push "auth-token-user `,
+}
+
+// AuditRecord describes a command sent to the management interface and
+// the reply it received, as passed to the hook set with SetAuditHook.
+type AuditRecord struct {
+	// Sent is when the command was sent, and Replied when the reply was
+	// received or the command failed.
+	Sent    time.Time
+	Replied time.Time
+
+	// Command is the command as sent, including any multi-line payload,
+	// except that secrets such as passwords are redacted.
+	Command string
+
+	// Reply holds the lines of the reply, without the "SUCCESS: " prefix
+	// of a single-line result, and Error the error the command failed
+	// with, if any.
+	Reply []string
+	Error string `json:",omitempty"`
+
+	// Err is the error the command failed with itself, such as an
+	// ErrorFromServer, for hooks that classify failures. It is not
+	// written to an AuditLog.
+	Err error `json:"-"`
+
+	// Reason is the reason the caller gave for the command using
+	// WithReason, if any.
+	Reason string `json:",omitempty"`
+}
+
+// SetAuditHook arranges for fn to be called with a record of each command
+// subsequently sent by the client, or by any client derived from it using
+// WithReason, such as to pass them to an AuditLog. It is called while the
+// command still holds the connection, so it should return quickly. Passing
+// nil removes the hook.
+func (c *MgmtClient) SetAuditHook(fn func(AuditRecord)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.audit = fn
+}
+
+// WithReason returns a client sharing c's connection whose commands are
+// recorded for auditing with the given reason, such as the name of the
+// operator or the ticket that caused them.
+func (c *MgmtClient) WithReason(reason string) *MgmtClient {
+	return &MgmtClient{mgmtConn: c.mgmtConn, reason: reason}
+}
+
+// startAudit begins the audit record of a command, returning nil if there
+// is no audit hook. It must be called with c.mu held.
+func (c *MgmtClient) startAudit(cmd string) *AuditRecord {
+	if c.audit == nil {
+		return nil
+	}
+	return &AuditRecord{
+		Sent:    time.Now(),
+		Command: redactCommand(cmd),
+		Reason:  c.reason,
+	}
+}
+
+// finishAudit completes an audit record started by startAudit and passes
+// it to the audit hook, and logs and counts the command cmd. It must be
+// called with c.mu held.
+func (c *MgmtClient) finishAudit(rec *AuditRecord, cmd string, reply [][]byte, err error) {
+	c.logCommand(cmd, err)
+	countCommand(err)
+	if rec == nil {
+		return
+	}
+	rec.Replied = time.Now()
+	for _, line := range reply {
+		rec.Reply = append(rec.Reply, string(line))
+	}
+	if err != nil {
+		rec.Error = err.Error()
+		rec.Err = err
+	}
+	c.audit(*rec)
+}
+
+// redactCommand redacts the secrets in each line of a command, including
+// those of its payload, if any.
+func redactCommand(cmd string) string {
+	if !strings.Contains(cmd, "\n") {
+		return redactCommandLine(cmd)
+	}
+	lines := strings.Split(cmd, "\n")
+	for i, line := range lines {
+		lines[i] = redactCommandLine(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func redactCommandLine(cmd string) string {
+	for _, prefix := range auditRedactedCommands {
+		if !strings.HasPrefix(cmd, prefix) {
+			continue
+		}
+		// A pushed directive is quoted as a whole.
+		if strings.HasPrefix(prefix, `push "`) {
+			return prefix + `[redacted]"`
+		}
+		// Keep the quoted realm that precedes a password.
+		if rest := cmd[len(prefix):]; strings.HasPrefix(rest, `"`) {
+			if end := strings.IndexByte(rest[1:], '"'); end != -1 {
+				return prefix + rest[:end+2] + " [redacted]"
+			}
+		}
+		return prefix + "[redacted]"
+	}
+	return cmd
+}
+
+// AuditLog writes audit records to a tamper-evident log, in which each
+// entry contains a hash of its record chained with the hash of the
+// previous entry, so that altering, removing or reordering entries can be
+// detected using VerifyAuditLog. Its Record method can be passed to
+// MgmtClient.SetAuditHook.
+//
+// Each entry is written as a line of JSON.
+type AuditLog struct {
+	mu   sync.Mutex
+	w    io.Writer
+	seq  uint64
+	prev string
+	err  error
+}
+
+// AuditEntry is an entry in an AuditLog.
+type AuditEntry struct {
+	Seq    uint64
+	Record AuditRecord
+
+	// Prev is the hash of the previous entry, or empty for the first, and
+	// Hash the hash of this one, both hex-encoded SHA-256.
+	Prev string
+	Hash string
+}
+
+// NewAuditLog returns an audit log writing to w.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+// Record appends a record to the log.
+func (l *AuditLog) Record(rec AuditRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+
+	l.seq++
+	entry := AuditEntry{Seq: l.seq, Record: rec, Prev: l.prev}
+	entry.Hash, l.err = entry.hash()
+	if l.err != nil {
+		return
+	}
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		l.err = err
+		return
+	}
+	if _, l.err = l.w.Write(append(buf, '\n')); l.err == nil {
+		l.prev = entry.Hash
+	}
+}
+
+// Err returns the first error encountered while writing the log, if any,
+// after which no further records are written.
+func (l *AuditLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+func (e AuditEntry) hash() (string, error) {
+	e.Hash = ""
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyAuditLog reads an audit log written by AuditLog and checks that
+// its entries are intact and in sequence, returning the number of entries
+// read. Truncation of the end of the log cannot be detected, so the count
+// or the hash of the last entry should be kept elsewhere too.
+func VerifyAuditLog(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	var prev string
+	n := 0
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return n, fmt.Errorf("audit log entry %d: %w", n+1, err)
+		}
+		if entry.Seq != uint64(n+1) || entry.Prev != prev {
+			return n, fmt.Errorf("audit log entry %d is out of sequence", n+1)
+		}
+		hash, err := entry.hash()
+		if err != nil {
+			return n, err
+		}
+		if hash != entry.Hash {
+			return n, fmt.Errorf("audit log entry %d has been altered", n+1)
+		}
+		prev = entry.Hash
+		n++
+	}
+	return n, scanner.Err()
+}
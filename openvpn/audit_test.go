@@ -0,0 +1,69 @@
+package openvpn
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	_, client, _ := newFakeServer(t)
+	var buf bytes.Buffer
+	log := NewAuditLog(&buf)
+	var records []AuditRecord
+	client.SetAuditHook(func(rec AuditRecord) {
+		records = append(records, rec)
+		log.Record(rec)
+	})
+
+	if err := client.Auth("alice", `pa"ss word`); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WithReason("ticket 42").ClientKill(3, "HALT"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ClientAuth(4, 1, []string{`push "auth-token s3cret"`, `push "auth-token-user YWxpY2U="`, `push "route 10.0.0.0"`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CRResponse("123456"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, rec := range records {
+		got = append(got, rec.Command+" / "+strings.Join(rec.Reply, ",")+" / "+rec.Reason)
+	}
+	want := []string{
+		`username "Auth" "alice" / ok / `,
+		`password "Auth" [redacted] / ok / `,
+		`client-kill 3 "HALT" / ok / ticket 42`,
+		"client-auth 4 1\n" + `push "auth-token [redacted]"` + "\n" + `push "auth-token-user [redacted]"` + "\n" + `push "route 10.0.0.0"` + "\nEND / ok / ",
+		`cr-response [redacted] / ok / `,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got records %q; want %q", got, want)
+	}
+	if records[0].Sent.IsZero() || records[0].Replied.Before(records[0].Sent) {
+		t.Errorf("record has wrong times %v and %v", records[0].Sent, records[0].Replied)
+	}
+
+	if err := log.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := VerifyAuditLog(bytes.NewReader(buf.Bytes())); n != 5 || err != nil {
+		t.Errorf("VerifyAuditLog returned %d, %v; want 5 entries", n, err)
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	tampered := []string{
+		strings.Replace(buf.String(), "HALT", "RESTART", 1),
+		lines[0] + lines[2] + lines[3],
+		lines[1] + lines[0],
+	}
+	for i, journal := range tampered {
+		if _, err := VerifyAuditLog(strings.NewReader(journal)); err == nil {
+			t.Errorf("test %d: tampered log verified", i)
+		}
+	}
+}
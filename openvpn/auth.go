@@ -0,0 +1,339 @@
+package openvpn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAuthTimeout is the time an Authenticator is given to reach
+// a decision when AuthDriver.Timeout is zero.
+const DefaultAuthTimeout = 30 * time.Second
+
+// ClientAuth responds to a CONNECT or REAUTH ClientEvent by allowing the
+// client to connect. The given directives, if any, are applied to the
+// client as if they appeared in its client-config-dir file.
+func (c *MgmtClient) ClientAuth(cid int64, kid int, config []string) error {
+	var payload bytes.Buffer
+	for _, line := range config {
+		if strings.ContainsAny(line, "\r\n") {
+			return fmt.Errorf("client config directive %q contains a line break", line)
+		}
+		payload.WriteString(line)
+		payload.WriteByte('\n')
+	}
+
+	cmd := fmt.Sprintf("client-auth %d %d", cid, kid)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rec := c.startAudit(cmd + "\n" + payload.String() + "END")
+	err := c.sendCommand([]byte(cmd))
+	if err == nil {
+		err = c.sendCommandPayload(payload.Bytes())
+	}
+	if err != nil {
+		c.finishAudit(rec, cmd, nil, err)
+		return err
+	}
+	result, err := c.readCommandResult()
+	c.finishAudit(rec, cmd, [][]byte{result}, err)
+	return err
+}
+
+// ClientDeny responds to a CONNECT or REAUTH ClientEvent by refusing the
+// client. The reason is written to OpenVPN's log, while clientReason, if
+// not empty, is sent to the client.
+func (c *MgmtClient) ClientDeny(cid int64, kid int, reason, clientReason string) error {
+	cmd := fmt.Sprintf("client-deny %d %d %s", cid, kid, quoteArg(reason))
+	if clientReason != "" {
+		cmd += " " + quoteArg(clientReason)
+	}
+	_, err := c.simpleCommand(cmd)
+	return err
+}
+
+// ClientPendingAuth responds to a CONNECT or REAUTH ClientEvent by telling
+// the client that its authentication is in progress and may take up to the
+// given timeout, for example while the user completes a web login.
+//
+// The extra string is passed to the client, and is typically of the form
+// "WEB_AUTH::<url>" or "CR_TEXT:<flags>:<challenge>". The authentication
+// must later be completed using ClientAuth or ClientDeny, or else OpenVPN
+// denies the client once the timeout expires.
+func (c *MgmtClient) ClientPendingAuth(cid int64, kid int, extra string, timeout time.Duration) error {
+	_, err := c.simpleCommand(fmt.Sprintf("client-pending-auth %d %d %s %d", cid, kid, quoteArg(extra), int(timeout.Seconds())))
+	return err
+}
+
+// ClientInfo describes a client that is asking to be authenticated.
+type ClientInfo struct {
+	ClientID int64
+	KeyID    int
+
+	// Reauth is true if the client is already connected and is
+	// renegotiating its TLS session.
+	Reauth bool
+
+	CommonName  string
+	Username    string
+	Password    string
+	RealAddress string
+
+	// Env is the complete environment OpenVPN sent with the request.
+	Env Env
+}
+
+func clientInfoFromEvent(e *ClientEvent) ClientInfo {
+	var cc ConnectedClient
+	cc.updateFromEnv(e.Env())
+	return ClientInfo{
+		ClientID:    e.ClientID(),
+		KeyID:       e.KeyID(),
+		Reauth:      e.Type() == "REAUTH",
+		CommonName:  cc.CommonName,
+		Username:    cc.Username,
+		Password:    e.Env().Get("password"),
+		RealAddress: cc.RealAddress,
+		Env:         e.Env(),
+	}
+}
+
+// DecisionKind is the kind of answer given by an Authenticator.
+type DecisionKind int
+
+const (
+	DecisionDeny DecisionKind = iota
+	DecisionAccept
+	DecisionPending
+)
+
+// Decision is an Authenticator's answer to a client's request to
+// authenticate. The Accept, Deny and Pending functions construct each
+// kind of decision.
+type Decision struct {
+	Kind DecisionKind
+
+	// Config lists the directives to apply to an accepted client.
+	Config []string
+
+	// Reason and ClientReason explain why a client was denied, as for
+	// MgmtClient.ClientDeny.
+	Reason       string
+	ClientReason string
+
+	// Extra and Timeout describe a pending authentication, as for
+	// MgmtClient.ClientPendingAuth.
+	Extra   string
+	Timeout time.Duration
+}
+
+// Accept returns a decision allowing the client to connect, applying the
+// given directives to it.
+func Accept(config ...string) Decision {
+	return Decision{Kind: DecisionAccept, Config: config}
+}
+
+// Deny returns a decision refusing the client.
+func Deny(reason, clientReason string) Decision {
+	return Decision{Kind: DecisionDeny, Reason: reason, ClientReason: clientReason}
+}
+
+// Pending returns a decision deferring authentication of the client for
+// up to the given timeout. The final decision must be given later using
+// AuthDriver.Resolve.
+func Pending(extra string, timeout time.Duration) Decision {
+	return Decision{Kind: DecisionPending, Extra: extra, Timeout: timeout}
+}
+
+// Authenticator decides whether clients may connect to an OpenVPN server.
+//
+// Authenticate is called on a goroutine of its own for each request, so
+// it may take as long as it needs, up to the deadline of the given
+// context. A non-nil error denies the client.
+type Authenticator interface {
+	Authenticate(ctx context.Context, info ClientInfo) (Decision, error)
+}
+
+// AuthenticatorFunc is an adapter to allow the use of ordinary functions
+// as Authenticators.
+type AuthenticatorFunc func(ctx context.Context, info ClientInfo) (Decision, error)
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, info ClientInfo) (Decision, error) {
+	return f(ctx, info)
+}
+
+// ErrAuthTimeout is passed to AuthDriver.OnError when an Authenticator
+// fails to reach a decision before the timeout.
+var ErrAuthTimeout = errors.New("authentication timed out")
+
+// AuthDriver answers the authentication requests of clients connecting to
+// an OpenVPN server, as reported by ClientEvents, by consulting an
+// Authenticator and then sending the appropriate client-auth, client-deny
+// or client-pending-auth command.
+//
+// The server must be running with --management-client-auth. It then
+// waits for an answer to every request, so an AuthDriver should handle
+// every event received from the server.
+//
+// The driver issues commands from its own goroutines. Since MgmtClient is
+// safe for concurrent use, the client may also be used for other commands
+// meanwhile.
+type AuthDriver struct {
+	Client        *MgmtClient
+	Authenticator Authenticator
+
+	// Timeout is the time the Authenticator is given to answer each
+	// request. If it fails to answer in time then the client is denied.
+	// If zero, DefaultAuthTimeout is used.
+	Timeout time.Duration
+
+	// OnError, if set, is called when the Authenticator returns an error
+	// or times out, or when a command cannot be sent. It may be called
+	// concurrently from multiple goroutines.
+	OnError func(info ClientInfo, err error)
+
+	mu       sync.Mutex
+	cmdMu    sync.Mutex
+	inflight map[int64]*authRequest
+	wg       sync.WaitGroup
+}
+
+// authRequest is an authentication in progress.
+type authRequest struct {
+	cancel context.CancelFunc
+}
+
+// HandleEvent starts authenticating a client if the given event is
+// a CONNECT or REAUTH ClientEvent, and abandons any authentication in
+// progress if it is a DISCONNECT ClientEvent. It returns true if the event
+// was a ClientEvent.
+func (d *AuthDriver) HandleEvent(e Event) bool {
+	ce, ok := e.(*ClientEvent)
+	if !ok {
+		return false
+	}
+
+	switch ce.Type() {
+	case "CONNECT", "REAUTH":
+		d.start(clientInfoFromEvent(ce))
+	case "DISCONNECT":
+		d.mu.Lock()
+		if req, ok := d.inflight[ce.ClientID()]; ok {
+			req.cancel()
+			delete(d.inflight, ce.ClientID())
+		}
+		d.mu.Unlock()
+	}
+	return true
+}
+
+func (d *AuthDriver) start(info ClientInfo) {
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = DefaultAuthTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	req := &authRequest{cancel: cancel}
+
+	d.mu.Lock()
+	if d.inflight == nil {
+		d.inflight = map[int64]*authRequest{}
+	}
+	if prev, ok := d.inflight[info.ClientID]; ok {
+		prev.cancel()
+	}
+	d.inflight[info.ClientID] = req
+	d.mu.Unlock()
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer cancel()
+		d.authenticate(ctx, info)
+
+		d.mu.Lock()
+		if d.inflight[info.ClientID] == req {
+			delete(d.inflight, info.ClientID)
+		}
+		d.mu.Unlock()
+	}()
+}
+
+type authResult struct {
+	decision Decision
+	err      error
+}
+
+func (d *AuthDriver) authenticate(ctx context.Context, info ClientInfo) {
+	// The Authenticator might not honour the context's deadline, so we
+	// wait for it separately to be sure of answering in time.
+	resultCh := make(chan authResult, 1)
+	go func() {
+		decision, err := d.Authenticator.Authenticate(ctx, info)
+		resultCh <- authResult{decision, err}
+	}()
+
+	var result authResult
+	select {
+	case result = <-resultCh:
+	case <-ctx.Done():
+	}
+	if ctx.Err() == context.Canceled {
+		// The client disconnected, or sent a fresh request, so there is
+		// nobody left to answer.
+		return
+	}
+
+	decision, err := result.decision, result.err
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		err = ErrAuthTimeout
+	}
+	if err != nil {
+		d.reportError(info, err)
+		decision = Deny(err.Error(), "")
+	}
+
+	if err := d.Resolve(info.ClientID, info.KeyID, decision); err != nil {
+		d.reportError(info, err)
+	}
+}
+
+// Resolve sends the given decision for the client with the given client
+// and key ids, as reported in its ClientInfo. It is used to complete
+// a pending authentication.
+func (d *AuthDriver) Resolve(cid int64, kid int, decision Decision) error {
+	return d.Do(func(c *MgmtClient) error {
+		switch decision.Kind {
+		case DecisionAccept:
+			return c.ClientAuth(cid, kid, decision.Config)
+		case DecisionPending:
+			return c.ClientPendingAuth(cid, kid, decision.Extra, decision.Timeout)
+		default:
+			return c.ClientDeny(cid, kid, decision.Reason, decision.ClientReason)
+		}
+	})
+}
+
+// Do calls fn with the driver's client while no other commands are being
+// sent by the driver, so that fn can issue a sequence of commands without
+// the driver's decisions being interleaved with them.
+func (d *AuthDriver) Do(fn func(*MgmtClient) error) error {
+	d.cmdMu.Lock()
+	defer d.cmdMu.Unlock()
+	return fn(d.Client)
+}
+
+// Wait waits for all authentications in progress to complete.
+func (d *AuthDriver) Wait() {
+	d.wg.Wait()
+}
+
+func (d *AuthDriver) reportError(info ClientInfo, err error) {
+	if d.OnError != nil {
+		d.OnError(info, err)
+	}
+}
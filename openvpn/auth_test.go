@@ -0,0 +1,163 @@
+package openvpn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers every command sent by a client with SUCCESS,
+// recording the commands it receives.
+type fakeServer struct {
+	conn net.Conn
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *MgmtClient, chan Event) {
+	server, conn := net.Pipe()
+	events := make(chan Event, 10)
+	client := NewClient(conn, events)
+	s := &fakeServer{conn: server}
+	go s.serve()
+	t.Cleanup(func() { server.Close() })
+	return s, client, events
+}
+
+func (s *fakeServer) serve() {
+	scanner := bufio.NewScanner(s.conn)
+	var block []string
+	inBlock := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case inBlock && line == "END":
+			line = strings.Join(append(block, "END"), "|")
+			inBlock, block = false, nil
+		case inBlock:
+			block = append(block, line)
+			continue
+		case strings.HasPrefix(line, "client-auth "):
+			inBlock, block = true, []string{line}
+			continue
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+		if _, err := s.conn.Write([]byte("SUCCESS: ok\n")); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := append([]string(nil), s.commands...)
+	sort.Strings(ret)
+	return ret
+}
+
+func TestAuthDriver(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+
+	var errs []error
+	var errsMu sync.Mutex
+	d := &AuthDriver{
+		Client:  client,
+		Timeout: 50 * time.Millisecond,
+		Authenticator: AuthenticatorFunc(func(ctx context.Context, info ClientInfo) (Decision, error) {
+			switch info.Username {
+			case "alice":
+				if info.Password != "secret" {
+					return Deny("bad password", "wrong \"password\""), nil
+				}
+				return Accept("push \"route 10.1.0.0 255.255.0.0\""), nil
+			case "bob":
+				return Pending("WEB_AUTH::https://example.com/login", time.Minute), nil
+			case "carol":
+				<-ctx.Done()
+				return Decision{}, ctx.Err()
+			case "dave":
+				select {}
+			}
+			return Decision{}, errors.New("unknown user")
+		}),
+		OnError: func(info ClientInfo, err error) {
+			errsMu.Lock()
+			errs = append(errs, err)
+			errsMu.Unlock()
+		},
+	}
+
+	connect := func(cid int, username, password string) {
+		e := &ClientEvent{body: []byte("CONNECT," + strconv.Itoa(cid) + ",0")}
+		e.setEnv(Env{"username": username, "password": password})
+		d.HandleEvent(e)
+	}
+	connect(1, "alice", "secret")
+	connect(2, "alice", "guess")
+	connect(3, "bob", "")
+	connect(4, "carol", "")
+	connect(5, "dave", "")
+	connect(6, "eve", "")
+	d.Wait()
+
+	if err := d.Resolve(3, 0, Accept()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`client-auth 1 0|push "route 10.1.0.0 255.255.0.0"|END`,
+		`client-auth 3 0|END`,
+		`client-deny 2 0 "bad password" "wrong \"password\""`,
+		`client-deny 4 0 "authentication timed out"`,
+		`client-deny 5 0 "authentication timed out"`,
+		`client-deny 6 0 "unknown user"`,
+		`client-pending-auth 3 0 "WEB_AUTH::https://example.com/login" 60`,
+	}
+	if got := server.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands\ngot  %q\nwant %q", got, want)
+	}
+
+	timeouts := 0
+	for _, err := range errs {
+		if errors.Is(err, ErrAuthTimeout) {
+			timeouts++
+		}
+	}
+	if len(errs) != 3 || timeouts != 2 {
+		t.Errorf("got errors %v; want two timeouts and one other", errs)
+	}
+}
+
+func TestAuthDriverDisconnect(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+
+	started := make(chan struct{})
+	d := &AuthDriver{
+		Client: client,
+		Authenticator: AuthenticatorFunc(func(ctx context.Context, info ClientInfo) (Decision, error) {
+			close(started)
+			<-ctx.Done()
+			return Decision{}, ctx.Err()
+		}),
+	}
+	d.HandleEvent(upgradeEvent([]byte("CLIENT:CONNECT,1,0")))
+	<-started
+	d.HandleEvent(upgradeEvent([]byte("CLIENT:DISCONNECT,1")))
+	d.Wait()
+
+	if got := server.Commands(); len(got) != 0 {
+		t.Errorf("got commands %q after disconnect; want none", got)
+	}
+}
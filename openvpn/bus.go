@@ -0,0 +1,255 @@
+package openvpn
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSubscriberOverflow is the error reported by Subscription.Err when a
+// subscription with OverflowDisconnect was closed because it fell behind.
+var ErrSubscriberOverflow = errors.New("subscriber fell behind and was disconnected")
+
+// EventFilter reports whether an event should be delivered to a
+// subscriber of an EventBus. A nil EventFilter accepts all events.
+type EventFilter func(Event) bool
+
+// OverflowPolicy determines what an EventBus does with an event for a
+// subscriber whose buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDisconnect closes the subscription, so that the subscriber
+	// learns that it has missed events rather than silently missing
+	// them. Its Err method then returns ErrSubscriberOverflow.
+	OverflowDisconnect OverflowPolicy = iota
+
+	// OverflowDropNewest discards the new event, keeping those already
+	// buffered.
+	OverflowDropNewest
+
+	// OverflowDropOldest discards the oldest buffered event to make room
+	// for the new one, which suits subscribers such as status displays
+	// that only need the latest events.
+	OverflowDropOldest
+)
+
+// EventBus distributes the events from a single event channel, such as the
+// one given to NewClient, to any number of subscribers, each of which
+// receives the events it is interested in on its own channel.
+//
+// Subscribers may come and go at any time. Each event is delivered to all
+// interested subscribers from a single loop, which never blocks: each
+// subscriber has a bounded buffer, and once it is full the subscriber's
+// OverflowPolicy decides what happens to further events. A slow
+// subscriber therefore cannot hold up the others, or create back-pressure
+// on the source channel and so on the client's replies, as described in
+// the NewClient docs. The events discarded are counted by Dropped.
+//
+// The zero value is an EventBus with no subscribers, ready to use.
+type EventBus struct {
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	list    []*Subscription // snapshot of subs, or nil if it has changed
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// Subscription is a subscriber's registration with an EventBus.
+type Subscription struct {
+	// C receives the subscribed events. It is closed once the subscription
+	// has been closed or the bus has shut down.
+	C <-chan Event
+
+	bus    *EventBus
+	filter EventFilter
+	policy OverflowPolicy
+	ch     chan Event
+
+	dropped atomic.Uint64
+
+	mu       sync.Mutex
+	chClosed bool
+	err      error
+}
+
+// Subscribe registers a new subscriber that will receive each subsequent
+// event accepted by filter on a channel with the given buffer depth, of at
+// least one. The subscription is closed with OverflowDisconnect if the
+// buffer overflows.
+//
+// If the bus has already shut down then the returned subscription's
+// channel is already closed.
+func (b *EventBus) Subscribe(filter EventFilter, buffer int) *Subscription {
+	return b.SubscribePolicy(filter, buffer, OverflowDisconnect)
+}
+
+// SubscribeLossy is like Subscribe, except that events are dropped with
+// OverflowDropNewest rather than the subscription being closed once its
+// buffer is full. The number of events dropped is reported by the
+// subscription's Dropped method.
+func (b *EventBus) SubscribeLossy(filter EventFilter, buffer int) *Subscription {
+	return b.SubscribePolicy(filter, buffer, OverflowDropNewest)
+}
+
+// SubscribePolicy is like Subscribe, except that the given policy is
+// applied once the subscription's buffer is full.
+func (b *EventBus) SubscribePolicy(filter EventFilter, buffer int, policy OverflowPolicy) *Subscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan Event, buffer)
+	s := &Subscription{
+		C:      ch,
+		bus:    b,
+		filter: filter,
+		policy: policy,
+		ch:     ch,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		s.closeChannel()
+		return s
+	}
+	if b.subs == nil {
+		b.subs = map[*Subscription]struct{}{}
+	}
+	b.subs[s] = struct{}{}
+	b.list = nil
+	return s
+}
+
+// Publish delivers the given event to each interested subscriber without
+// blocking, applying each subscriber's OverflowPolicy if its buffer is
+// full.
+func (b *EventBus) Publish(e Event) {
+	b.mu.Lock()
+	if b.list == nil && len(b.subs) > 0 {
+		b.list = make([]*Subscription, 0, len(b.subs))
+		for s := range b.subs {
+			b.list = append(b.list, s)
+		}
+	}
+	subs := b.list
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		if (s.filter == nil || s.filter(e)) && !s.send(e) {
+			s.unsubscribe()
+		}
+	}
+}
+
+// Dropped returns the number of events the bus has discarded because the
+// buffer of a subscription was full, counted once for each subscription
+// that missed each event.
+func (b *EventBus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Run publishes each event received from events until that channel is
+// closed, and then shuts down the bus by calling Close. Since publishing
+// never blocks, events is drained as fast as they arrive.
+func (b *EventBus) Run(events <-chan Event) {
+	for e := range events {
+		b.Publish(e)
+	}
+	b.Close()
+}
+
+// Close shuts down the bus, closing the channels of all of its
+// subscribers. Subsequent subscriptions are closed immediately.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.list = nil
+	b.closed = true
+	b.mu.Unlock()
+
+	for s := range subs {
+		s.Close()
+	}
+}
+
+// Close ends the subscription, so that no further events are delivered to
+// it, and closes its channel. It is safe to call Close more than once, and
+// concurrently with the bus publishing events.
+func (s *Subscription) Close() {
+	s.unsubscribe()
+	s.closeChannel()
+}
+
+// Dropped returns the number of events not delivered to the subscription
+// because its buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Err returns ErrSubscriberOverflow if the subscription was closed by the
+// bus because its buffer overflowed, or nil otherwise.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// send delivers an event to the subscription, returning false if it was
+// disconnected as a result.
+func (s *Subscription) send(e Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chClosed {
+		return true
+	}
+	select {
+	case s.ch <- e:
+		return true
+	default:
+	}
+
+	s.drop()
+	switch s.policy {
+	case OverflowDropOldest:
+		// The bus is the only sender, so once an event has been taken
+		// from the buffer, by us or by the subscriber, there is room.
+		select {
+		case <-s.ch:
+		default:
+		}
+		s.ch <- e
+	case OverflowDisconnect:
+		s.err = ErrSubscriberOverflow
+		s.chClosed = true
+		close(s.ch)
+		return false
+	}
+	return true
+}
+
+func (s *Subscription) drop() {
+	s.dropped.Add(1)
+	s.bus.dropped.Add(1)
+	countDropped()
+}
+
+// unsubscribe removes the subscription from its bus.
+func (s *Subscription) unsubscribe() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		s.bus.list = nil
+	}
+}
+
+func (s *Subscription) closeChannel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.chClosed {
+		s.chClosed = true
+		close(s.ch)
+	}
+}
@@ -0,0 +1,154 @@
+package openvpn
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestEventBus(t *testing.T) {
+	var bus EventBus
+	all := bus.Subscribe(nil, 10)
+	states := bus.Subscribe(func(e Event) bool {
+		_, ok := e.(*StateEvent)
+		return ok
+	}, 10)
+	closed := bus.Subscribe(nil, 0)
+	closed.Close()
+	closed.Close()
+
+	source := make(chan Event, 10)
+	for _, raw := range []string{
+		"STATE:1,CONNECTING,,,",
+		"HOLD:Waiting for hold release",
+		"STATE:2,CONNECTED,SUCCESS,10.0.0.2,192.0.2.1",
+	} {
+		source <- upgradeEvent([]byte(raw))
+	}
+	close(source)
+	bus.Run(source)
+
+	tests := []struct {
+		sub  *Subscription
+		want []string
+	}{
+		{all, []string{"CONNECTING", "Waiting for hold release", "CONNECTED: 192.0.2.1"}},
+		{states, []string{"CONNECTING", "CONNECTED: 192.0.2.1"}},
+		{closed, nil},
+	}
+	for i, test := range tests {
+		var got []string
+		for e := range test.sub.C {
+			got = append(got, e.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+
+	late := bus.Subscribe(nil, 1)
+	if _, ok := <-late.C; ok {
+		t.Errorf("subscription after close was not closed")
+	}
+}
+
+func TestEventBusOverflow(t *testing.T) {
+	tests := []struct {
+		policy  OverflowPolicy
+		want    []string
+		dropped uint64
+		err     error
+	}{
+		{OverflowDisconnect, []string{"1", "2"}, 1, ErrSubscriberOverflow},
+		{OverflowDropNewest, []string{"1", "2"}, 2, nil},
+		{OverflowDropOldest, []string{"3", "4"}, 2, nil},
+	}
+	for i, test := range tests {
+		var bus EventBus
+		sub := bus.SubscribePolicy(nil, 2, test.policy)
+		for _, msg := range []string{"1", "2", "3", "4"} {
+			bus.Publish(&HoldEvent{body: []byte(msg)})
+		}
+		bus.Close()
+
+		var got []string
+		for e := range sub.C {
+			got = append(got, e.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+		if got := sub.Dropped(); got != test.dropped {
+			t.Errorf("test %d got %d events dropped; want %d", i, got, test.dropped)
+		}
+		if got := sub.Err(); got != test.err {
+			t.Errorf("test %d got error %v; want %v", i, got, test.err)
+		}
+	}
+}
+
+func TestEventBusDisconnectUnsubscribes(t *testing.T) {
+	var bus EventBus
+	sub := bus.Subscribe(nil, 1)
+	bus.Publish(&HoldEvent{body: []byte("hold")})
+	bus.Publish(&HoldEvent{body: []byte("hold")})
+
+	bus.mu.Lock()
+	n := len(bus.subs)
+	bus.mu.Unlock()
+	if n != 0 {
+		t.Errorf("bus has %d subscribers after disconnecting; want 0", n)
+	}
+	sub.Close()
+}
+
+func TestEventBusLossy(t *testing.T) {
+	var bus EventBus
+	lossy := bus.SubscribeLossy(nil, 2)
+	other := bus.SubscribeLossy(nil, 3)
+	for i := 0; i < 5; i++ {
+		bus.Publish(&HoldEvent{body: []byte("hold")})
+	}
+	if got := lossy.Dropped(); got != 3 {
+		t.Errorf("got %d events dropped; want 3", got)
+	}
+	if got := other.Dropped(); got != 2 {
+		t.Errorf("got %d events dropped; want 2", got)
+	}
+	if got := bus.Dropped(); got != 5 {
+		t.Errorf("got %d events dropped by bus; want 5", got)
+	}
+	bus.Close()
+	n := 0
+	for range lossy.C {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("got %d events delivered; want 2", n)
+	}
+}
+
+// TestEventBusSharedEvents checks that subscribers can read the same event
+// concurrently, which the race detector would otherwise flag for fields
+// parsed lazily.
+func TestEventBusSharedEvents(t *testing.T) {
+	var bus EventBus
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		sub := bus.Subscribe(nil, 10)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range sub.C {
+				if got := e.String(); got != "CONNECTED: 192.0.2.1" {
+					t.Errorf("got %q; want CONNECTED: 192.0.2.1", got)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		bus.Publish(upgradeEvent([]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+	}
+	bus.Close()
+	wg.Wait()
+}
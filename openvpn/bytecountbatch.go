@@ -0,0 +1,156 @@
+package openvpn
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ByteCountDelta describes the byte counts reported for one of a server's
+// clients during a tick of a ByteCountAggregator.
+type ByteCountDelta struct {
+	// BytesIn and BytesOut are the most recent byte counts.
+	BytesIn, BytesOut int64
+
+	// DeltaIn and DeltaOut are the increase in the byte counts since they
+	// were last passed to OnFlush, or since the client connected. A
+	// decrease in a count, which should never happen, is treated as if it
+	// had started again from zero.
+	DeltaIn, DeltaOut int64
+}
+
+// ByteCountAggregator coalesces the per-client ByteCountEvents reported by
+// a server into a single callback per tick, giving the latest byte counts
+// of each client reported during the tick. A server with tens of thousands
+// of clients reports each of them once per byte count interval, so this
+// saves consumers such as metrics exporters or accounting stores from
+// handling each event separately.
+//
+// An aggregator is safe for concurrent use.
+type ByteCountAggregator struct {
+	// OnFlush is called by Flush with the byte counts reported since the
+	// previous flush, keyed by client id. The map is reused for the next
+	// tick, so it must not be retained or modified after OnFlush returns.
+	OnFlush func(map[int64]ByteCountDelta)
+
+	// flushMu serializes flushes, so that a map isn't reused while
+	// OnFlush may still be using it.
+	flushMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]ByteCountDelta
+	flushed map[int64]ByteCountDelta
+	last    map[int64]ByteCountDelta
+	gone    map[int64]bool
+}
+
+// HandleEvent records the byte counts if the given event is a per-client
+// ByteCountEvent, and forgets the counts of a client that has disconnected
+// if it is a DISCONNECT ClientEvent, returning true if it was either. The
+// caller should pass each event received from the client's event channel.
+// The event is not retained, so it may be released with ReleaseEvent once
+// HandleEvent returns.
+func (a *ByteCountAggregator) HandleEvent(e Event) bool {
+	switch e := e.(type) {
+	case *ByteCountEvent:
+		cid, ok := e.clientID()
+		if !ok {
+			return false
+		}
+		a.Update(cid, int64(e.BytesIn()), int64(e.BytesOut()))
+		return true
+	case *ClientEvent:
+		if e.Type() != "DISCONNECT" {
+			return false
+		}
+		a.forget(e.ClientID())
+		return true
+	}
+	return false
+}
+
+// Update records byte counts for the given client, to be passed to OnFlush
+// at the next flush. HandleEvent calls it for each per-client
+// ByteCountEvent, but it can also be called directly with counts obtained
+// by other means, such as from status polls.
+func (a *ByteCountAggregator) Update(cid int64, bytesIn, bytesOut int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pending == nil {
+		a.pending = map[int64]ByteCountDelta{}
+	}
+	a.pending[cid] = ByteCountDelta{BytesIn: bytesIn, BytesOut: bytesOut}
+	delete(a.gone, cid)
+}
+
+// forget discards what is known of a disconnected client, once any counts
+// still pending for it have been flushed.
+func (a *ByteCountAggregator) forget(cid int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, pending := a.pending[cid]; !pending {
+		delete(a.last, cid)
+		return
+	}
+	if a.gone == nil {
+		a.gone = map[int64]bool{}
+	}
+	a.gone[cid] = true
+}
+
+// Flush passes the byte counts reported since the previous flush to
+// OnFlush, if there are any. Run calls it at each tick, but it can also be
+// called directly.
+func (a *ByteCountAggregator) Flush() {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	a.mu.Lock()
+	batch := a.pending
+	if len(batch) == 0 {
+		a.mu.Unlock()
+		return
+	}
+	if a.last == nil {
+		a.last = map[int64]ByteCountDelta{}
+	}
+	for cid, counts := range batch {
+		prev := a.last[cid]
+		counts.DeltaIn = counterDelta(prev.BytesIn, counts.BytesIn)
+		counts.DeltaOut = counterDelta(prev.BytesOut, counts.BytesOut)
+		batch[cid] = counts
+		if a.gone[cid] {
+			delete(a.last, cid)
+		} else {
+			a.last[cid] = counts
+		}
+	}
+	clear(a.gone)
+
+	// Swap in the map flushed last time, which OnFlush has finished with,
+	// so that we don't allocate a new one each tick.
+	a.pending, a.flushed = a.flushed, batch
+	clear(a.pending)
+	onFlush := a.OnFlush
+	a.mu.Unlock()
+
+	if onFlush != nil {
+		onFlush(batch)
+	}
+}
+
+// Run calls Flush at the given interval until ctx is cancelled, then
+// flushes any remaining counts and returns ctx.Err().
+func (a *ByteCountAggregator) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			a.Flush()
+			return ctx.Err()
+		case <-ticker.C:
+			a.Flush()
+		}
+	}
+}
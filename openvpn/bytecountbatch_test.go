@@ -0,0 +1,87 @@
+package openvpn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestByteCountAggregator(t *testing.T) {
+	var flushes []map[int64]ByteCountDelta
+	a := ByteCountAggregator{
+		OnFlush: func(batch map[int64]ByteCountDelta) {
+			copied := map[int64]ByteCountDelta{}
+			for cid, d := range batch {
+				copied[cid] = d
+			}
+			flushes = append(flushes, copied)
+		},
+	}
+
+	ticks := [][]string{
+		{
+			"BYTECOUNT_CLI:1,100,200",
+			"BYTECOUNT_CLI:2,10,20",
+			"BYTECOUNT_CLI:1,150,250",
+			"BYTECOUNT:5,6",
+		},
+		{},
+		{
+			"BYTECOUNT_CLI:1,160,300",
+			"BYTECOUNT_CLI:2,15,25",
+			"CLIENT:DISCONNECT,2",
+		},
+		{
+			"BYTECOUNT_CLI:2,5,5",
+			"CLIENT:DISCONNECT,1",
+			"BYTECOUNT_CLI:1,40,50",
+		},
+	}
+	want := []map[int64]ByteCountDelta{
+		{
+			1: {BytesIn: 150, BytesOut: 250, DeltaIn: 150, DeltaOut: 250},
+			2: {BytesIn: 10, BytesOut: 20, DeltaIn: 10, DeltaOut: 20},
+		},
+		{
+			1: {BytesIn: 160, BytesOut: 300, DeltaIn: 10, DeltaOut: 50},
+			2: {BytesIn: 15, BytesOut: 25, DeltaIn: 5, DeltaOut: 5},
+		},
+		{
+			// Client 2 disconnected, so its counts start again, and
+			// client 1 reported counts again after disconnecting, so
+			// they are kept.
+			1: {BytesIn: 40, BytesOut: 50, DeltaIn: 40, DeltaOut: 50},
+			2: {BytesIn: 5, BytesOut: 5, DeltaIn: 5, DeltaOut: 5},
+		},
+	}
+
+	for _, tick := range ticks {
+		for _, raw := range tick {
+			a.HandleEvent(upgradeEvent([]byte(raw)))
+		}
+		a.Flush()
+	}
+	if !reflect.DeepEqual(flushes, want) {
+		t.Errorf("got flushes %v; want %v", flushes, want)
+	}
+}
+
+func TestByteCountAggregatorHandleEvent(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"BYTECOUNT_CLI:1,2,3", true},
+		{"BYTECOUNT_CLI:x,2,3", false},
+		{"BYTECOUNT:2,3", false},
+		{"CLIENT:DISCONNECT,1", true},
+		{"CLIENT:ADDRESS,1,10.8.0.2,1", false},
+		{"STATE:1,CONNECTED,SUCCESS,10.8.0.1,", false},
+	}
+
+	var a ByteCountAggregator
+	for i, test := range tests {
+		if got := a.HandleEvent(upgradeEvent([]byte(test.input))); got != test.want {
+			t.Errorf("test %d HandleEvent returned %v; want %v", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package openvpn
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+var (
+	staticChallengeMarker  = []byte(" SC:")
+	dynamicChallengeMarker = []byte("['CRV1:")
+	dynamicChallengeEnd    = []byte("']")
+	textChallengePrefix    = "CR_TEXT:"
+)
+
+// ChallengeKind identifies the protocol used to present a Challenge.
+type ChallengeKind int
+
+const (
+	// StaticChallenge is a challenge configured on the client with
+	// --static-challenge, whose response is sent along with the password.
+	StaticChallenge ChallengeKind = iota
+
+	// DynamicChallenge is a challenge sent by the server in response to
+	// the password using the CRV1 protocol, whose response is sent in
+	// place of the password at the next request.
+	DynamicChallenge
+
+	// TextChallenge is a challenge sent by the server while the client's
+	// authentication is pending, whose response is sent with the
+	// cr-response command.
+	TextChallenge
+)
+
+// Challenge is a request for a response, such as a one-time password,
+// in addition to or in place of a password.
+type Challenge struct {
+	Kind ChallengeKind
+
+	// Text is the challenge to display to the user.
+	Text string
+
+	// Echo is true if the response may be displayed as it is typed.
+	Echo bool
+
+	// Username is the username the server associated with a dynamic
+	// challenge.
+	Username string
+
+	// stateID is the opaque server state of a dynamic challenge, and
+	// concat records the static challenge flag requesting that the
+	// response be appended to the password.
+	stateID string
+	concat  bool
+}
+
+// ChallengeHandler supplies the responses to challenges, for example by
+// prompting the user for a one-time password.
+type ChallengeHandler interface {
+	Respond(c Challenge) (string, error)
+}
+
+// ChallengeFunc is an adapter to allow the use of ordinary functions as
+// ChallengeHandlers.
+type ChallengeFunc func(c Challenge) (string, error)
+
+func (f ChallengeFunc) Respond(c Challenge) (string, error) {
+	return f(c)
+}
+
+// StaticChallenge returns the static challenge included in a request for
+// credentials, if any.
+func (e *PasswordEvent) StaticChallenge() (Challenge, bool) {
+	idx := bytes.Index(e.body, staticChallengeMarker)
+	if !e.NeedsCredentials() || idx == -1 {
+		return Challenge{}, false
+	}
+	rest := string(e.body[idx+len(staticChallengeMarker):])
+	flagStr, text, _ := strings.Cut(rest, ",")
+	flags, _ := strconv.Atoi(flagStr)
+	return Challenge{
+		Kind:   StaticChallenge,
+		Text:   text,
+		Echo:   flags&1 != 0,
+		concat: flags&2 != 0,
+	}, true
+}
+
+// DynamicChallenge returns the CRV1 challenge included in a report of
+// rejected credentials, if any.
+func (e *PasswordEvent) DynamicChallenge() (Challenge, bool) {
+	start := bytes.Index(e.body, dynamicChallengeMarker)
+	if !e.VerificationFailed() || start == -1 {
+		return Challenge{}, false
+	}
+	rest := e.body[start+len(dynamicChallengeMarker):]
+	if end := bytes.LastIndex(rest, dynamicChallengeEnd); end != -1 {
+		rest = rest[:end]
+	}
+
+	parts := strings.SplitN(string(rest), ":", 4)
+	if len(parts) < 4 {
+		return Challenge{}, false
+	}
+	username, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Challenge{}, false
+	}
+	return Challenge{
+		Kind:     DynamicChallenge,
+		Text:     parts[3],
+		Echo:     hasFlag(parts[0], "E"),
+		Username: string(username),
+		stateID:  parts[1],
+	}, true
+}
+
+// TextChallenge returns the challenge carried by a CR_TEXT message, if any.
+func (e *InfoMsgEvent) TextChallenge() (Challenge, bool) {
+	msg := e.Message()
+	if !strings.HasPrefix(msg, textChallengePrefix) {
+		return Challenge{}, false
+	}
+	flags, text, _ := strings.Cut(msg[len(textChallengePrefix):], ":")
+	return Challenge{
+		Kind: TextChallenge,
+		Text: text,
+		Echo: hasFlag(flags, "E"),
+	}, true
+}
+
+// CRResponse answers a TextChallenge.
+func (c *MgmtClient) CRResponse(response string) error {
+	_, err := c.simpleCommand("cr-response " + base64.StdEncoding.EncodeToString([]byte(response)))
+	return err
+}
+
+// staticChallengePassword combines a password with the response to
+// a static challenge in the form OpenVPN expects.
+func staticChallengePassword(c Challenge, password, response string) string {
+	if c.concat {
+		return password + response
+	}
+	enc := base64.StdEncoding
+	return "SCRV1:" + enc.EncodeToString([]byte(password)) + ":" + enc.EncodeToString([]byte(response))
+}
+
+// dynamicChallengePassword returns the password that answers a dynamic
+// challenge.
+func dynamicChallengePassword(c Challenge, response string) string {
+	return "CRV1::" + c.stateID + "::" + response
+}
+
+func hasFlag(flags, flag string) bool {
+	for _, f := range strings.Split(flags, ",") {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
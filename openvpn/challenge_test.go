@@ -0,0 +1,98 @@
+package openvpn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChallengeParsing(t *testing.T) {
+	tests := []struct {
+		raw    string
+		want   Challenge
+		wantOK bool
+	}{
+		{
+			"PASSWORD:Need 'Auth' username/password SC:1,Enter PIN",
+			Challenge{Kind: StaticChallenge, Text: "Enter PIN", Echo: true},
+			true,
+		},
+		{
+			"PASSWORD:Need 'Auth' username/password SC:2,Enter PIN",
+			Challenge{Kind: StaticChallenge, Text: "Enter PIN", concat: true},
+			true,
+		},
+		{
+			"PASSWORD:Verification Failed: 'Auth' ['CRV1:R,E:Om01u7Fh4LrGBS7uh0SWmzwabUiGiW6l:Y3Ix:Please enter token PIN']",
+			Challenge{Kind: DynamicChallenge, Text: "Please enter token PIN", Echo: true, Username: "cr1", stateID: "Om01u7Fh4LrGBS7uh0SWmzwabUiGiW6l"},
+			true,
+		},
+		{
+			"INFOMSG:CR_TEXT:R:Enter the code: from your app",
+			Challenge{Kind: TextChallenge, Text: "Enter the code: from your app"},
+			true,
+		},
+		{"PASSWORD:Need 'Auth' username/password", Challenge{}, false},
+		{"PASSWORD:Verification Failed: 'Auth'", Challenge{}, false},
+		{"PASSWORD:Verification Failed: 'Auth' ['CRV1:R:bad']", Challenge{}, false},
+		{"INFOMSG:WEB_AUTH::https://example.com/", Challenge{}, false},
+	}
+
+	for i, test := range tests {
+		var got Challenge
+		var ok bool
+		switch e := upgradeEvent([]byte(test.raw)).(type) {
+		case *PasswordEvent:
+			if got, ok = e.StaticChallenge(); !ok {
+				got, ok = e.DynamicChallenge()
+			}
+		case *InfoMsgEvent:
+			got, ok = e.TextChallenge()
+		default:
+			t.Fatalf("test %d got %T", i, e)
+		}
+		if ok != test.wantOK || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %+v, %v; want %+v, %v", i, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestCredentialResponderChallenges(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+
+	r := &CredentialResponder{
+		Client:   client,
+		Provider: StaticCredentials{Username: "alice", Password: "secret"},
+		Challenges: ChallengeFunc(func(c Challenge) (string, error) {
+			return "123456", nil
+		}),
+	}
+	for _, raw := range []string{
+		"PASSWORD:Need 'Auth' username/password SC:0,Enter PIN",
+		"PASSWORD:Verification Failed: 'Auth' ['CRV1:R,E:state1:Y3Ix:Enter token']",
+		"PASSWORD:Need 'Auth' username/password",
+		"INFOMSG:CR_TEXT:R,E:Enter the code",
+	} {
+		if err := r.HandleEvent(upgradeEvent([]byte(raw))); err != nil {
+			t.Errorf("%q returned error: %s", raw, err)
+		}
+	}
+
+	want := []string{
+		`cr-response MTIzNDU2`,
+		`password "Auth" "CRV1::state1::123456"`,
+		`password "Auth" "SCRV1:c2VjcmV0:MTIzNDU2"`,
+		`username "Auth" "alice"`,
+		`username "Auth" "cr1"`,
+	}
+	if got := server.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands\ngot  %q\nwant %q", got, want)
+	}
+	if got := r.attempts["Auth"]; got != 1 {
+		t.Errorf("got %d attempts; want 1", got)
+	}
+
+	r.Challenges = nil
+	if err := r.HandleEvent(upgradeEvent([]byte("INFOMSG:CR_TEXT:R:Code"))); err != ErrNoChallengeHandler {
+		t.Errorf("got error %v without a handler; want ErrNoChallengeHandler", err)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NordSecurity/gopenvpn/demux"
@@ -22,16 +26,43 @@ var (
 type StatusFormat string
 
 // StatusFormatDefault openvpn default status format
-// StatusFormatV3 openvpn version 3 status format
+// StatusFormatV2 openvpn version 2 status format, comma-separated
+// StatusFormatV3 openvpn version 3 status format, tab-separated
 const (
 	StatusFormatDefault StatusFormat = ""
+	StatusFormatV2      StatusFormat = "2"
 	StatusFormatV3      StatusFormat = "3"
 )
 
-// MgmtClient .
+// MgmtClient is a client of the OpenVPN management interface.
+//
+// A MgmtClient is safe for concurrent use by multiple goroutines: each
+// command waits for any command already in progress to receive its reply
+// before it is sent, so that for example a single connection can serve
+// both an HTTP API and a metrics poller. Because OpenVPN handles commands
+// one at a time, a slow command, such as retrieving a long status report,
+// delays the others.
 type MgmtClient struct {
+	*mgmtConn
+
+	// reason is attached to the audit records of commands sent through
+	// this client, as set by WithReason.
+	reason string
+}
+
+// mgmtConn is the connection shared by a MgmtClient and the clients
+// derived from it by WithReason.
+type mgmtConn struct {
+	// mu serializes commands, so that each reply is read by the caller
+	// that sent the command it answers.
+	mu      sync.Mutex
 	wc      io.WriteCloser
 	replies <-chan []byte
+	audit   func(AuditRecord)
+
+	// logger is read by the goroutine decoding events, which must not
+	// wait for mu, so it is not guarded by it.
+	logger atomic.Pointer[slog.Logger]
 }
 
 func (m *MgmtClient) Close() error {
@@ -54,7 +85,9 @@ func (m *MgmtClient) Close() error {
 // caller *must* constantly read events from eventCh to avoid its buffer
 // becoming full. Events and replies are received on the same channel
 // from OpenVPN, so if writing to eventCh blocks then this will also block
-// responses from the client's various command methods.
+// responses from the client's various command methods. Passing eventCh to
+// EventBus.Run ensures that it is drained constantly however slow the
+// bus's subscribers are, since the bus never blocks on them.
 //
 // eventCh will be closed to signal the closing of the client connection,
 // whether due to graceful shutdown or to an error. In the case of error,
@@ -62,27 +95,73 @@ func (m *MgmtClient) Close() error {
 // is closed. Connection errors may also concurrently surface as error
 // responses from the client's various command methods, should an error
 // occur while we await a reply.
+//
+// Each event received from eventCh belongs to the receiver. A receiver
+// handling a high volume of events may pass each one to ReleaseEvent once
+// it has finished with it, to reduce allocations.
 func NewClient(conn io.ReadWriteCloser, eventCh chan<- Event) *MgmtClient {
 	replyCh := make(chan []byte)
 	rawEventCh := make(chan []byte) // not buffered because eventCh should be
 
 	go demux.Demultiplex(conn, replyCh, rawEventCh)
 
+	m := &mgmtConn{
+		// replyCh acts as the reader for our ReadWriter, so we only
+		// need to retain the io.Writer for it, so we can send commands.
+		wc:      conn,
+		replies: replyCh,
+	}
+
 	// Get raw events and upgrade them into proper event types before
 	// passing them on to the caller's event channel.
 	go func() {
+		var dec eventDecoder
 		for raw := range rawEventCh {
-			eventCh <- upgradeEvent(raw)
+			countEvent(raw)
+			for _, event := range dec.decode(raw) {
+				m.logEvent(event)
+				eventCh <- event
+			}
 		}
+		if event := dec.envs.flush(); event != nil {
+			eventCh <- event
+		}
+		m.log().Debug("management connection closed")
 		close(eventCh)
 	}()
 
-	return &MgmtClient{
-		// replyCh acts as the reader for our ReadWriter, so we only
-		// need to retain the io.Writer for it, so we can send commands.
-		wc:      conn,
-		replies: replyCh,
+	return &MgmtClient{mgmtConn: m}
+}
+
+// eventDecoder turns the raw asynchronous messages received from OpenVPN
+// into the events emitted by the client.
+type eventDecoder struct {
+	envs envAssembler
+
+	// events is reused between calls to decode, to avoid allocating for
+	// each message.
+	events []Event
+}
+
+// decode returns the events to emit in response to the given message,
+// which may be none if the message is part of an environment block. The
+// returned slice is only valid until the next call.
+func (d *eventDecoder) decode(raw []byte) []Event {
+	event := d.envs.push(upgradeEvent(raw))
+	if event == nil {
+		return nil
+	}
+	events := append(d.events[:0], event)
+	if log, ok := event.(*LogEvent); ok {
+		if fallback := DCOFallbackFromLog(log); fallback != nil {
+			events = append(events, fallback)
+		}
+		if float := PeerFloatFromLog(log); float != nil {
+			events = append(events, float)
+		}
 	}
+	d.events = events
+	return events
 }
 
 // Dial is a convenience wrapper around NewClient that handles the common
@@ -169,6 +248,56 @@ func (c *MgmtClient) SetEchoEvents(on bool) error {
 	return err
 }
 
+// SetLogEvents either enables or disables asynchronous events for messages
+// written to the OpenVPN log.
+//
+// When enabled, a LogEvent will be emitted from the event channel for each
+// new log message. The volume of these events depends on the verbosity
+// OpenVPN is configured with, which can be very high at the more verbose
+// levels. See LogHistory to also retrieve messages logged earlier.
+func (c *MgmtClient) SetLogEvents(on bool) error {
+	var err error
+	if on {
+		_, err = c.simpleCommand("log on")
+	} else {
+		_, err = c.simpleCommand("log off")
+	}
+	return err
+}
+
+// LogHistory retrieves the log messages that OpenVPN has retained in its
+// history buffer, oldest first. This is useful for retrieving messages
+// logged before the management client connected, such as those from the
+// initial startup of the OpenVPN process.
+//
+// The whole history is read into memory, which may be large for a chatty
+// process; RecentLog limits how many messages are retrieved.
+func (c *MgmtClient) LogHistory() ([]*LogEvent, error) {
+	return c.logHistory("log all")
+}
+
+// RecentLog retrieves at most the n most recent log messages that OpenVPN
+// has retained in its history buffer, oldest first.
+func (c *MgmtClient) RecentLog(n int) ([]*LogEvent, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid log message count %d", n)
+	}
+	return c.logHistory(fmt.Sprintf("log %d", n))
+}
+
+func (c *MgmtClient) logHistory(cmd string) ([]*LogEvent, error) {
+	payload, err := c.payloadCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*LogEvent, len(payload))
+	for i, line := range payload {
+		events[i] = &LogEvent{body: line}
+	}
+	return events, nil
+}
+
 // SetByteCountEvents either enables or disables ongoing asynchronous events
 // for information on OpenVPN bandwidth usage.
 //
@@ -205,12 +334,7 @@ func (c *MgmtClient) SendSignal(name string) error {
 // initial state after calling SetStateEvents(true) but before the first
 // state event is delivered.
 func (c *MgmtClient) LatestState() (*StateEvent, error) {
-	err := c.sendCommand([]byte("state"))
-	if err != nil {
-		return nil, err
-	}
-
-	payload, err := c.readCommandResponsePayload()
+	payload, err := c.payloadCommand("state")
 	if err != nil {
 		return nil, err
 	}
@@ -227,20 +351,11 @@ func (c *MgmtClient) LatestState() (*StateEvent, error) {
 // LatestStatus retrieves the current daemon status information, in the same
 // format as that produced by the OpenVPN --status directive.
 func (c *MgmtClient) LatestStatus(statusFormat StatusFormat) ([][]byte, error) {
-	var cmd []byte
-	if statusFormat == StatusFormatDefault {
-		cmd = []byte("status")
-	} else if statusFormat == StatusFormatV3 {
-		cmd = []byte("status 3")
-	} else {
-		return nil, fmt.Errorf("Incorrect 'status' format option")
-	}
-	err := c.sendCommand(cmd)
+	cmd, err := statusCommand(statusFormat)
 	if err != nil {
 		return nil, err
 	}
-
-	payload, err := c.readCommandResponsePayload()
+	payload, err := c.payloadCommand(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -248,6 +363,33 @@ func (c *MgmtClient) LatestStatus(statusFormat StatusFormat) ([][]byte, error) {
 	return payload, nil
 }
 
+// StreamStatus retrieves the current daemon status information like
+// LatestStatus, but passes each line to fn as it is received rather than
+// collecting them all first, so that the status of a server with a great
+// many clients can be processed without holding all of it in memory. The
+// line is only valid until fn returns.
+//
+// If fn returns an error then it is not called again, but the rest of the
+// status is still read before StreamStatus returns the error. The lines
+// are not included in audit records.
+func (c *MgmtClient) StreamStatus(statusFormat StatusFormat, fn func(line []byte) error) error {
+	cmd, err := statusCommand(statusFormat)
+	if err != nil {
+		return err
+	}
+	return c.streamCommand(cmd, fn)
+}
+
+func statusCommand(statusFormat StatusFormat) (string, error) {
+	switch statusFormat {
+	case StatusFormatDefault:
+		return "status", nil
+	case StatusFormatV2, StatusFormatV3:
+		return "status " + string(statusFormat), nil
+	}
+	return "", fmt.Errorf("Incorrect 'status' format option")
+}
+
 // Pid retrieves the process id of the connected OpenVPN process.
 func (c *MgmtClient) Pid() (int, error) {
 	raw, err := c.simpleCommand("pid")
@@ -267,13 +409,91 @@ func (c *MgmtClient) Pid() (int, error) {
 	return pid, nil
 }
 
+// VersionInfo describes the version of a connected OpenVPN process.
+type VersionInfo struct {
+	// OpenVPN is the OpenVPN version banner, in the same format as the
+	// first line printed by openvpn --version.
+	OpenVPN string
+
+	// Management is the version of the management interface protocol.
+	Management int
+}
+
+// Version retrieves the version of the connected OpenVPN process and of
+// its management interface.
+func (c *MgmtClient) Version() (*VersionInfo, error) {
+	payload, err := c.payloadCommand("version")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &VersionInfo{}
+	for _, line := range payload {
+		sepIdx := bytes.Index(line, []byte(": "))
+		if sepIdx == -1 {
+			continue
+		}
+		key, value := line[:sepIdx], line[sepIdx+2:]
+
+		switch {
+		case bytes.Equal(key, []byte("OpenVPN Version")):
+			info.OpenVPN = string(value)
+		case bytes.HasPrefix(key, []byte("Management")):
+			info.Management, _ = strconv.Atoi(string(value))
+		}
+	}
+
+	if info.OpenVPN == "" {
+		return nil, fmt.Errorf("malformed response from OpenVPN")
+	}
+	return info, nil
+}
+
 // Auth sends username and password to the OpenVPN process.
 func (c *MgmtClient) Auth(username, password string) error {
-	_, err := c.simpleCommand(fmt.Sprintf("username \"Auth\" %s", username))
-	if err != nil {
+	return c.Credentials("Auth", username, password)
+}
+
+// Credentials answers a PasswordEvent requesting credentials for the given
+// realm, such as "Auth" or "HTTP Proxy". If username is empty then only
+// the password is sent, as required for realms such as "Private Key".
+//
+// Both values are quoted as needed, so they may contain spaces, quotes
+// and backslashes.
+func (c *MgmtClient) Credentials(realm, username, password string) error {
+	if username != "" {
+		_, err := c.simpleCommand(fmt.Sprintf("username %s %s", quoteArg(realm), quoteArg(username)))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := c.simpleCommand(fmt.Sprintf("password %s %s", quoteArg(realm), quoteArg(password)))
+	return err
+}
+
+// RemoteAccept responds to a RemoteEvent by allowing OpenVPN to connect
+// to the remote it offered.
+func (c *MgmtClient) RemoteAccept() error {
+	_, err := c.simpleCommand("remote ACCEPT")
+	return err
+}
+
+// RemoteSkip responds to a RemoteEvent by making OpenVPN skip the remote it
+// offered and move on to the next one in its list, which will be offered
+// in turn.
+func (c *MgmtClient) RemoteSkip() error {
+	_, err := c.simpleCommand("remote SKIP")
+	return err
+}
+
+// RemoteModify responds to a RemoteEvent by making OpenVPN connect to the
+// given host and port instead of the remote it offered, using the same
+// transport protocol.
+func (c *MgmtClient) RemoteModify(host string, port int) error {
+	if err := checkArg("remote host", host); err != nil {
 		return err
 	}
-	_, err = c.simpleCommand(fmt.Sprintf("password \"Auth\" %s", password))
+	_, err := c.simpleCommand(fmt.Sprintf("remote MOD %s %d", quoteArg(host), port))
 	return err
 }
 
@@ -320,6 +540,8 @@ func (c *MgmtClient) readCommandResult() ([]byte, error) {
 		return nil, ErrorFromServer(message)
 	}
 
+	c.log().Debug("malformed management reply", "reply", string(reply))
+	countMalformed()
 	return nil, fmt.Errorf("malformed result message")
 }
 
@@ -344,10 +566,100 @@ func (c *MgmtClient) readCommandResponsePayload() ([][]byte, error) {
 	return lines, nil
 }
 
+// simpleCommand sends a command and returns its single-line result. Like
+// the other methods pairing a command with its reply, it holds c.mu
+// throughout, so that replies are matched with the commands they answer.
 func (c *MgmtClient) simpleCommand(cmd string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rec := c.startAudit(cmd)
+	err := c.sendCommand([]byte(cmd))
+	if err != nil {
+		c.finishAudit(rec, cmd, nil, err)
+		return nil, err
+	}
+	result, err := c.readCommandResult()
+	c.finishAudit(rec, cmd, [][]byte{result}, err)
+	return result, err
+}
+
+// payloadCommand sends a command and returns its multi-line response.
+func (c *MgmtClient) payloadCommand(cmd string) ([][]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rec := c.startAudit(cmd)
 	err := c.sendCommand([]byte(cmd))
 	if err != nil {
+		c.finishAudit(rec, cmd, nil, err)
 		return nil, err
 	}
-	return c.readCommandResult()
+	payload, err := c.readCommandResponsePayload()
+	c.finishAudit(rec, cmd, payload, err)
+	return payload, err
+}
+
+// streamCommand sends a command and passes each line of its multi-line
+// response to fn as it arrives, releasing each once fn returns. Once fn
+// fails it is not called again, but the response is still read to the
+// end so that later replies are matched with the right commands.
+func (c *MgmtClient) streamCommand(cmd string, fn func(line []byte) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rec := c.startAudit(cmd)
+	err := c.sendCommand([]byte(cmd))
+	if err != nil {
+		c.finishAudit(rec, cmd, nil, err)
+		return err
+	}
+
+	var fnErr error
+	for {
+		line, ok := <-c.replies
+		if !ok {
+			err = fmt.Errorf("connection closed before END recieved")
+			break
+		}
+		if bytes.Equal(line, endMessage) {
+			break
+		}
+		if fnErr == nil {
+			fnErr = fn(line)
+		}
+		demux.Release(line)
+	}
+	c.finishAudit(rec, cmd, nil, err)
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// checkArg fails if a command argument contains a line break, which would
+// otherwise end the command early and inject another one.
+func checkArg(what, arg string) error {
+	if strings.ContainsAny(arg, "\r\n") {
+		return fmt.Errorf("%s %q contains a line break", what, arg)
+	}
+	return nil
+}
+
+// quoteArg quotes a command argument so that OpenVPN's management
+// interface parses it as a single argument. Line breaks cannot be
+// represented, and so are replaced by spaces.
+func quoteArg(arg string) string {
+	var b strings.Builder
+	b.Grow(len(arg) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		switch arg[i] {
+		case '"', '\\':
+			b.WriteByte('\\')
+		case '\r', '\n':
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteByte(arg[i])
+	}
+	b.WriteByte('"')
+	return b.String()
 }
@@ -0,0 +1,74 @@
+package openvpn
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestClientConcurrentCommands(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	events := make(chan Event, 10)
+	client := NewClient(conn, events)
+
+	// The server answers each command with a result naming it, and answers
+	// "state" with a multi-line response, interleaving an event.
+	go func() {
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			cmd := scanner.Text()
+			reply := "SUCCESS: " + cmd + "\n"
+			if cmd == "state" {
+				reply = "1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1\n>BYTECOUNT:1,2\nEND\n"
+			}
+			if _, err := server.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		for range events {
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if j%5 == 0 {
+					state, err := client.LatestState()
+					if err != nil || state.NewState() != "CONNECTED" {
+						t.Errorf("LatestState returned %v, %v", state, err)
+					}
+					continue
+				}
+				cmd := fmt.Sprintf("echo %d %d", i, j)
+				got, err := client.simpleCommand(cmd)
+				if err != nil || string(got) != cmd {
+					t.Errorf("command %q got result %q, %v", cmd, got, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRemoteModify(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+
+	if err := client.RemoteModify("vpn.example.com\nsignal SIGTERM", 1194); err == nil {
+		t.Errorf("RemoteModify accepted a host with a line break")
+	}
+	if err := client.RemoteModify("vpn.example.com", 1194); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := server.Commands(), []string{`remote MOD "vpn.example.com" 1194`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+}
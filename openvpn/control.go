@@ -0,0 +1,297 @@
+package openvpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoClient is returned by Session methods that control the tunnel when
+// the session has no Client.
+var ErrNoClient = errors.New("session has no management client")
+
+// FailureReason classifies the reason a SessionError occurred.
+type FailureReason int
+
+const (
+	// FailureTimeout means that the context expired before the tunnel
+	// reached the desired state.
+	FailureTimeout FailureReason = iota
+
+	// FailureCommand means that the command controlling the tunnel could
+	// not be sent, or was rejected by OpenVPN.
+	FailureCommand
+
+	// FailureExited means that OpenVPN began exiting instead of
+	// reaching the desired state.
+	FailureExited
+
+	// FailureConnectionLost means that the management connection was lost
+	// before the tunnel reached the desired state.
+	FailureConnectionLost
+
+	// FailureConnect means that an attempt to connect failed, so that
+	// OpenVPN began reconnecting.
+	FailureConnect
+)
+
+func (r FailureReason) String() string {
+	switch r {
+	case FailureTimeout:
+		return "timed out"
+	case FailureCommand:
+		return "command failed"
+	case FailureExited:
+		return "OpenVPN exited"
+	case FailureConnectionLost:
+		return "management connection lost"
+	case FailureConnect:
+		return "connection attempt failed"
+	default:
+		return fmt.Sprintf("FailureReason(%d)", int(r))
+	}
+}
+
+// SessionError is returned when a Session fails to bring the tunnel to
+// a desired state.
+type SessionError struct {
+	// Op is the operation that failed, such as "disconnect".
+	Op string
+
+	Reason FailureReason
+
+	// State is the state of the tunnel when the operation failed.
+	State State
+
+	// Err is the underlying error, such as the context's error for
+	// FailureTimeout or the command's error for FailureCommand, if any.
+	Err error
+
+	// Cause is the connection failure reported by OpenVPN since the tunnel
+	// was last connected, if any, as a *ConnectionError.
+	Cause error
+}
+
+func (e *SessionError) Error() string {
+	msg := fmt.Sprintf("%s: %s in state %s", e.Op, e.Reason, e.State)
+	if e.State == "" {
+		msg = fmt.Sprintf("%s: %s", e.Op, e.Reason)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Cause != nil {
+		msg += " (" + e.Cause.Error() + ")"
+	}
+	return msg
+}
+
+func (e *SessionError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error's Cause matches target, so that for example
+// errors.Is(err, ErrAuthFailed) reports whether an operation failed
+// because of an authentication failure, whatever its Reason.
+func (e *SessionError) Is(target error) bool {
+	return e.Cause != nil && errors.Is(e.Cause, target)
+}
+
+// WaitForState waits until the tunnel is in one of the given states,
+// returning the state reached. It returns immediately if the tunnel is
+// already in one of them.
+//
+// It fails with a *SessionError if ctx expires, if OpenVPN begins exiting
+// when EXITING is not one of the given states, or if the management
+// connection is lost.
+func (s *Session) WaitForState(ctx context.Context, states ...State) (State, error) {
+	want := func(st State) bool {
+		for _, w := range states {
+			if st == w {
+				return true
+			}
+		}
+		return false
+	}
+
+	s.mu.Lock()
+	current, seq := s.state, s.evicted+len(s.history)
+	s.mu.Unlock()
+	if want(current) {
+		return current, nil
+	}
+
+	t, _, err := s.waitTransition(ctx, "wait", seq, func(t Transition) bool {
+		return want(t.To)
+	})
+	return t.To, err
+}
+
+// Connect releases the management hold, if OpenVPN is holding, and waits
+// until the tunnel connects.
+//
+// Unlike WaitForState, Connect fails as soon as a connection attempt fails,
+// with a *SessionError whose Cause describes the failure where OpenVPN
+// reported one, so that errors.Is can be used to test for errors such as
+// ErrAuthFailed.
+func (s *Session) Connect(ctx context.Context) error {
+	const op = "connect"
+	if s.Client == nil {
+		return ErrNoClient
+	}
+
+	s.mu.Lock()
+	current, seq := s.state, s.evicted+len(s.history)
+	s.mu.Unlock()
+	if current == StateConnected {
+		return nil
+	}
+
+	if err := s.Client.HoldRelease(); err != nil {
+		return s.sessionError(op, FailureCommand, err)
+	}
+	t, _, err := s.waitTransition(ctx, op, seq, func(t Transition) bool {
+		return t.To == StateConnected || t.To == StateReconnecting
+	})
+	if err != nil {
+		return err
+	}
+	if t.To == StateReconnecting {
+		return s.sessionError(op, FailureConnect, nil)
+	}
+	return nil
+}
+
+// Disconnect asks OpenVPN to exit by sending it SIGTERM, and then waits
+// until it reports the EXITING state or its management connection closes.
+func (s *Session) Disconnect(ctx context.Context) error {
+	const op = "disconnect"
+	if s.Client == nil {
+		return ErrNoClient
+	}
+
+	seq := s.transitionCount()
+	if err := s.Client.SendSignal("SIGTERM"); err != nil {
+		return s.sessionError(op, FailureCommand, err)
+	}
+	_, _, err := s.waitTransition(ctx, op, seq, func(t Transition) bool {
+		return t.To == StateExiting
+	})
+	var serr *SessionError
+	if errors.As(err, &serr) && (serr.Reason == FailureConnectionLost || serr.Reason == FailureExited) {
+		// OpenVPN closes the management connection as it exits.
+		return nil
+	}
+	return err
+}
+
+// Reconnect asks OpenVPN to restart the tunnel by sending it SIGHUP, which
+// also makes it re-read its configuration, and then waits until it has
+// passed through the RECONNECTING state and reconnected.
+func (s *Session) Reconnect(ctx context.Context) error {
+	_, err := s.restart(ctx, "reconnect", "SIGHUP")
+	return err
+}
+
+// SoftRestart asks OpenVPN to restart the connection by sending it
+// SIGUSR1, which, unlike Reconnect, keeps its configuration and, with
+// --persist-tun, the tunnel device, and then waits until it has passed
+// through the RECONNECTING state and reconnected. It returns how long the
+// tunnel took to reconverge, which is measured even if it fails.
+func (s *Session) SoftRestart(ctx context.Context) (time.Duration, error) {
+	return s.restart(ctx, "soft restart", "SIGUSR1")
+}
+
+// restart sends the given signal and then waits for the tunnel to pass
+// through RECONNECTING back to CONNECTED, returning the time that took.
+func (s *Session) restart(ctx context.Context, op, signal string) (time.Duration, error) {
+	if s.Client == nil {
+		return 0, ErrNoClient
+	}
+
+	start := time.Now()
+	seq := s.transitionCount()
+	if err := s.Client.SendSignal(signal); err != nil {
+		return time.Since(start), s.sessionError(op, FailureCommand, err)
+	}
+	_, seq, err := s.waitTransition(ctx, op, seq, func(t Transition) bool {
+		return t.To == StateReconnecting
+	})
+	if err != nil {
+		return time.Since(start), err
+	}
+	_, _, err = s.waitTransition(ctx, op, seq+1, func(t Transition) bool {
+		return t.To == StateConnected
+	})
+	return time.Since(start), err
+}
+
+// transitionCount returns the number of transitions observed so far, which
+// identifies the next transition to be observed.
+func (s *Session) transitionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.evicted + len(s.history)
+}
+
+// waitTransition waits for a transition matching the given predicate,
+// considering only transitions from the given count onwards, and returns
+// it along with its index in the history.
+func (s *Session) waitTransition(ctx context.Context, op string, from int, match func(Transition) bool) (Transition, int, error) {
+	for {
+		s.mu.Lock()
+		// The indexes count the transitions discarded from the history,
+		// so that they are unaffected by its limit; any transitions
+		// discarded before a waiter got to them are skipped.
+		if from < s.evicted {
+			from = s.evicted
+		}
+		for i := from; i < s.evicted+len(s.history); i++ {
+			t := s.history[i-s.evicted]
+			if match(t) {
+				s.mu.Unlock()
+				return t, i, nil
+			}
+			if t.To == StateExiting {
+				s.mu.Unlock()
+				return Transition{}, -1, s.sessionError(op, FailureExited, nil)
+			}
+		}
+		from = s.evicted + len(s.history)
+		if !s.mgmtConnected && !s.lastEvent.IsZero() {
+			s.mu.Unlock()
+			return Transition{}, -1, s.sessionError(op, FailureConnectionLost, nil)
+		}
+		if s.changed == nil {
+			s.changed = make(chan struct{})
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return Transition{}, -1, s.sessionError(op, FailureTimeout, ctx.Err())
+		}
+	}
+}
+
+func (s *Session) sessionError(op string, reason FailureReason, err error) *SessionError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	serr := &SessionError{Op: op, Reason: reason, State: s.state, Err: err}
+	if s.attemptFailed {
+		serr.Cause = s.lastErr
+	}
+	return serr
+}
+
+// notifyLocked wakes up any goroutines waiting in waitTransition. It must
+// be called with s.mu held.
+func (s *Session) notifyLocked() {
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+}
@@ -0,0 +1,167 @@
+package openvpn
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessionReconnect(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+	s := &Session{Client: client}
+	s.HandleEvent(upgradeEvent([]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+
+	go func() {
+		for len(server.Commands()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		for _, raw := range []string{
+			"STATE:2,RECONNECTING,SIGHUP,,",
+			"STATE:3,WAIT,,,",
+			"STATE:4,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		} {
+			s.HandleEvent(upgradeEvent([]byte(raw)))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Reconnect(ctx); err != nil {
+		t.Fatalf("Reconnect failed: %s", err)
+	}
+	if got, want := server.Commands(), []string{`signal "SIGHUP"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+	if got := len(s.History()); got != 4 {
+		t.Errorf("got %d transitions; want 4", got)
+	}
+}
+
+func TestSessionSoftRestart(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+	s := &Session{Client: client}
+	s.HandleEvent(upgradeEvent([]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+
+	go func() {
+		for len(server.Commands()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		s.HandleEvent(upgradeEvent([]byte("STATE:2,RECONNECTING,connection-reset,,")))
+		time.Sleep(20 * time.Millisecond)
+		s.HandleEvent(upgradeEvent([]byte("STATE:3,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	elapsed, err := s.SoftRestart(ctx)
+	if err != nil {
+		t.Fatalf("SoftRestart failed: %s", err)
+	}
+	if elapsed < 20*time.Millisecond {
+		t.Errorf("got elapsed time %v; want at least 20ms", elapsed)
+	}
+	if got, want := server.Commands(), []string{`signal "SIGUSR1"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+}
+
+func TestSessionReconnectTimeout(t *testing.T) {
+	_, client, _ := newFakeServer(t)
+	s := &Session{Client: client}
+	s.HandleEvent(upgradeEvent([]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := s.Reconnect(ctx)
+
+	var serr *SessionError
+	if !errors.As(err, &serr) || serr.Reason != FailureTimeout || serr.State != StateConnected {
+		t.Fatalf("got error %v; want a timeout in CONNECTED", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v does not wrap the context's error", err)
+	}
+}
+
+func TestSessionDisconnect(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+	s := &Session{Client: client}
+	s.HandleEvent(upgradeEvent([]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+
+	go func() {
+		for len(server.Commands()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		s.HandleEvent(upgradeEvent(readErrSynthEvent()))
+	}()
+
+	if err := s.Disconnect(context.Background()); err != nil {
+		t.Fatalf("Disconnect failed: %s", err)
+	}
+	if got, want := server.Commands(), []string{`signal "SIGTERM"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+	if err := (&Session{}).Disconnect(context.Background()); err != ErrNoClient {
+		t.Errorf("got %v without a client; want ErrNoClient", err)
+	}
+}
+
+func TestWaitForState(t *testing.T) {
+	s := &Session{}
+	s.HandleEvent(upgradeEvent([]byte("STATE:1,WAIT,,,")))
+
+	if got, err := s.WaitForState(context.Background(), StateWait); err != nil || got != StateWait {
+		t.Errorf("got %q, %v for the current state; want WAIT", got, err)
+	}
+
+	go s.HandleEvent(upgradeEvent([]byte("STATE:2,EXITING,SIGTERM,,")))
+	_, err := s.WaitForState(context.Background(), StateConnected)
+	var serr *SessionError
+	if !errors.As(err, &serr) || serr.Reason != FailureExited {
+		t.Errorf("got error %v; want FailureExited", err)
+	}
+}
+
+func TestSessionConnect(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+	s := &Session{Client: client}
+	s.HandleEvent(upgradeEvent([]byte("HOLD:Waiting for hold release:0")))
+
+	go func() {
+		for len(server.Commands()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		for _, raw := range []string{
+			"STATE:1,CONNECTING,,,",
+			"STATE:2,WAIT,,,",
+			"STATE:3,AUTH,,,",
+			"PASSWORD:Verification Failed: 'Auth'",
+			"STATE:4,RECONNECTING,auth-failure,,",
+		} {
+			s.HandleEvent(upgradeEvent([]byte(raw)))
+		}
+	}()
+
+	err := s.Connect(context.Background())
+	var serr *SessionError
+	if !errors.As(err, &serr) || serr.Reason != FailureConnect {
+		t.Fatalf("got error %v; want FailureConnect", err)
+	}
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("error %v is not ErrAuthFailed", err)
+	}
+	if got, want := server.Commands(), []string{"hold release"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+
+	// Once connected, the earlier failure no longer applies.
+	s.HandleEvent(upgradeEvent([]byte("STATE:5,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.WaitForState(ctx, StateReconnecting)
+	if err == nil || errors.Is(err, ErrAuthFailed) {
+		t.Errorf("error %v retained a stale cause", err)
+	}
+}
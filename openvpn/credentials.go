@@ -0,0 +1,292 @@
+package openvpn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultMaxCredentialAttempts is the number of times a CredentialResponder
+// supplies credentials for a realm when its MaxAttempts field is zero.
+const DefaultMaxCredentialAttempts = 3
+
+var (
+	// ErrNoCredentials may be returned by a CredentialProvider that has no
+	// credentials for a request, so that the request is left unanswered
+	// for some other part of the application to deal with.
+	ErrNoCredentials = errors.New("no credentials available")
+
+	// ErrNoChallengeHandler is returned by CredentialResponder.HandleEvent
+	// when OpenVPN presents a challenge but the responder has no
+	// ChallengeHandler to answer it.
+	ErrNoChallengeHandler = errors.New("challenge received but no challenge handler is configured")
+
+	// ErrCredentialsRejected is returned by CredentialResponder.HandleEvent
+	// when OpenVPN asks again for credentials that have already been
+	// rejected MaxAttempts times.
+	ErrCredentialsRejected = errors.New("credentials rejected")
+)
+
+// Credentials are a username and password given in answer to
+// a PasswordEvent. Username is ignored for realms that need only
+// a password.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialRequest describes a request from OpenVPN for credentials.
+type CredentialRequest struct {
+	// Realm is the kind of credentials requested, such as "Auth",
+	// "Private Key" or "HTTP Proxy".
+	Realm string
+
+	// NeedsUsername is true if both a username and password are needed,
+	// rather than a password alone.
+	NeedsUsername bool
+
+	// Attempt counts the requests for the realm since the tunnel last
+	// connected, starting at 1. A value greater than 1 means that the
+	// credentials given previously were rejected.
+	Attempt int
+
+	// Event is the event making the request.
+	Event *PasswordEvent
+}
+
+// CredentialProvider supplies the credentials that OpenVPN asks for using
+// PasswordEvents.
+type CredentialProvider interface {
+	Credentials(req CredentialRequest) (Credentials, error)
+}
+
+// CredentialFunc is an adapter to allow the use of ordinary functions as
+// CredentialProviders, for example to prompt the user.
+type CredentialFunc func(req CredentialRequest) (Credentials, error)
+
+func (f CredentialFunc) Credentials(req CredentialRequest) (Credentials, error) {
+	return f(req)
+}
+
+// StaticCredentials is a CredentialProvider that always supplies the same
+// credentials.
+type StaticCredentials Credentials
+
+func (c StaticCredentials) Credentials(req CredentialRequest) (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// FileCredentials is a CredentialProvider that reads credentials from
+// a file each time they are requested, so that the file can be updated
+// while OpenVPN is running.
+//
+// The file has the same format as the one given to OpenVPN's
+// --auth-user-pass option, with the username on the first line and the
+// password on the second. For realms that need only a password, the
+// password is read from the first line instead, as for --askpass.
+type FileCredentials string
+
+func (f FileCredentials) Credentials(req CredentialRequest) (Credentials, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && len(lines) < 2 {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return Credentials{}, err
+	}
+
+	switch {
+	case !req.NeedsUsername && len(lines) >= 1:
+		return Credentials{Password: lines[0]}, nil
+	case req.NeedsUsername && len(lines) >= 2:
+		return Credentials{Username: lines[0], Password: lines[1]}, nil
+	}
+	return Credentials{}, fmt.Errorf("%s does not contain the requested credentials", string(f))
+}
+
+// Keyring is implemented by system secret stores, such as the macOS
+// keychain, the Windows credential manager or the freedesktop.org secret
+// service, for use with KeyringCredentials. This package provides no
+// implementations itself, so as not to depend on any particular one.
+type Keyring interface {
+	Get(service, account string) (string, error)
+}
+
+// KeyringCredentials is a CredentialProvider that supplies Username along
+// with the password stored for it in a Keyring under Service.
+type KeyringCredentials struct {
+	Keyring  Keyring
+	Service  string
+	Username string
+}
+
+func (k KeyringCredentials) Credentials(req CredentialRequest) (Credentials, error) {
+	password, err := k.Keyring.Get(k.Service, k.Username)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{Username: k.Username, Password: password}, nil
+}
+
+// CredentialResponder answers PasswordEvents requesting credentials using
+// a CredentialProvider.
+//
+// When OpenVPN reports that credentials were rejected, the responder asks
+// the provider again at the next request, indicating the number of the
+// attempt, up to MaxAttempts times. The count for each realm is reset once
+// the tunnel connects, which requires state events to be enabled.
+//
+// OpenVPN asks again after a rejection only if it is configured to retry
+// authentication, such as with "auth-retry interact".
+//
+// Challenges, such as for one-time passwords, are answered using the
+// ChallengeHandler in Challenges: the response to a static challenge is
+// sent along with the password, the response to a dynamic (CRV1)
+// challenge is sent in place of the password at the next request, and the
+// response to a CR_TEXT challenge sent in an InfoMsgEvent is sent using
+// MgmtClient.CRResponse.
+//
+// If Tokens is set, auth-tokens pushed by the server are stored there for
+// the username last supplied, and are given in place of the password at
+// later requests, such as after a soft reconnect, until the server rejects
+// them.
+type CredentialResponder struct {
+	Client     *MgmtClient
+	Provider   CredentialProvider
+	Challenges ChallengeHandler
+	Tokens     TokenStore
+
+	// MaxAttempts limits the number of times credentials are supplied for
+	// a realm before giving up. If zero, DefaultMaxCredentialAttempts is
+	// used.
+	MaxAttempts int
+
+	attempts map[string]int
+	dynamic  *Challenge
+
+	// username is the username last supplied for the Auth realm, and
+	// tokenSent is true if a stored token was given in place of its
+	// password since the tunnel last connected.
+	username  string
+	tokenSent bool
+}
+
+// HandleEvent answers the given event if it is a PasswordEvent requesting
+// credentials, and otherwise updates the responder's attempt counts as
+// necessary. The caller should pass each event received from the client's
+// event channel, from a single goroutine.
+//
+// An error is returned if credentials could not be obtained or sent, in
+// which case the request is left unanswered. ErrNoCredentials from the
+// provider is not treated as an error.
+func (r *CredentialResponder) HandleEvent(e Event) error {
+	switch e := e.(type) {
+	case *StateEvent:
+		if State(e.NewState()) == StateConnected {
+			r.attempts = nil
+			r.tokenSent = false
+		}
+		return nil
+	case *InfoMsgEvent:
+		if ch, ok := e.TextChallenge(); ok {
+			resp, err := r.respond(ch)
+			if err != nil {
+				return err
+			}
+			return r.Client.CRResponse(resp)
+		}
+		return nil
+	case *PasswordEvent:
+		return r.handlePassword(e)
+	}
+	return nil
+}
+
+func (r *CredentialResponder) handlePassword(pe *PasswordEvent) error {
+	if handled, err := r.handleAuthToken(pe); handled {
+		return err
+	}
+	if ch, ok := pe.DynamicChallenge(); ok {
+		r.dynamic = &ch
+		return nil
+	}
+	if !pe.NeedsCredentials() {
+		return nil
+	}
+	realm := pe.Realm()
+
+	// The server rejected the password only to present a challenge, so
+	// this request doesn't count as a further attempt.
+	if r.dynamic != nil && realm == "Auth" {
+		ch := *r.dynamic
+		r.dynamic = nil
+		resp, err := r.respond(ch)
+		if err != nil {
+			return err
+		}
+		return r.Client.Credentials(realm, ch.Username, dynamicChallengePassword(ch, resp))
+	}
+	if answered, err := r.answerWithToken(pe); answered || err != nil {
+		return err
+	}
+
+	if r.attempts == nil {
+		r.attempts = map[string]int{}
+	}
+	r.attempts[realm]++
+	attempt := r.attempts[realm]
+
+	limit := r.MaxAttempts
+	if limit <= 0 {
+		limit = DefaultMaxCredentialAttempts
+	}
+	if attempt > limit {
+		return ErrCredentialsRejected
+	}
+
+	req := CredentialRequest{
+		Realm:         realm,
+		NeedsUsername: pe.NeedsUsername(),
+		Attempt:       attempt,
+		Event:         pe,
+	}
+	creds, err := r.Provider.Credentials(req)
+	if errors.Is(err, ErrNoCredentials) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if ch, ok := pe.StaticChallenge(); ok {
+		resp, err := r.respond(ch)
+		if err != nil {
+			return err
+		}
+		creds.Password = staticChallengePassword(ch, creds.Password, resp)
+	}
+
+	if !req.NeedsUsername {
+		creds.Username = ""
+	}
+	if realm == "Auth" && creds.Username != "" {
+		r.username = creds.Username
+	}
+	return r.Client.Credentials(realm, creds.Username, creds.Password)
+}
+
+func (r *CredentialResponder) respond(ch Challenge) (string, error) {
+	if r.Challenges == nil {
+		return "", ErrNoChallengeHandler
+	}
+	return r.Challenges.Respond(ch)
+}
@@ -0,0 +1,141 @@
+package openvpn
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCredentialResponder(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+
+	var attempts []int
+	r := &CredentialResponder{
+		Client:      client,
+		MaxAttempts: 2,
+		Provider: CredentialFunc(func(req CredentialRequest) (Credentials, error) {
+			if req.Realm == "HTTP Proxy" {
+				return Credentials{}, ErrNoCredentials
+			}
+			attempts = append(attempts, req.Attempt)
+			return Credentials{Username: "alice", Password: `p@ss "word"`}, nil
+		}),
+	}
+
+	tests := []struct {
+		raw     string
+		wantErr error
+	}{
+		{"PASSWORD:Need 'Auth' username/password", nil},
+		{"PASSWORD:Verification Failed: 'Auth'", nil},
+		{"PASSWORD:Need 'Auth' username/password", nil},
+		{"PASSWORD:Verification Failed: 'Auth'", nil},
+		{"PASSWORD:Need 'Auth' username/password", ErrCredentialsRejected},
+		{"PASSWORD:Need 'HTTP Proxy' username/password", nil},
+		{"STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1", nil},
+		{"PASSWORD:Need 'Private Key' password", nil},
+	}
+	for i, test := range tests {
+		if err := r.HandleEvent(upgradeEvent([]byte(test.raw))); !errors.Is(err, test.wantErr) {
+			t.Errorf("test %d returned %v; want %v", i, err, test.wantErr)
+		}
+	}
+
+	if want := []int{1, 2, 1}; !reflect.DeepEqual(attempts, want) {
+		t.Errorf("got attempts %v; want %v", attempts, want)
+	}
+	want := []string{
+		`password "Auth" "p@ss \"word\""`,
+		`password "Auth" "p@ss \"word\""`,
+		`password "Private Key" "p@ss \"word\""`,
+		`username "Auth" "alice"`,
+		`username "Auth" "alice"`,
+	}
+	if got := server.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestCredentialResponderTokens(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+
+	calls := 0
+	tokens := &MemoryTokenStore{}
+	r := &CredentialResponder{
+		Client: client,
+		Tokens: tokens,
+		Provider: CredentialFunc(func(req CredentialRequest) (Credentials, error) {
+			calls++
+			return Credentials{Username: "alice", Password: "secret"}, nil
+		}),
+	}
+
+	tests := []struct {
+		raw       string
+		wantToken string
+	}{
+		{"PASSWORD:Need 'Auth' username/password", ""},
+		{"PASSWORD:Auth-Token:tok1", "tok1"},
+		{"STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1", "tok1"},
+		{"PASSWORD:Need 'Auth' username/password", "tok1"},
+		{"PASSWORD:Verification Failed: 'Auth'", ""},
+		{"PASSWORD:Need 'Auth' username/password", ""},
+		{"PASSWORD:Auth-Token:tok2", "tok2"},
+	}
+	for i, test := range tests {
+		if err := r.HandleEvent(upgradeEvent([]byte(test.raw))); err != nil {
+			t.Errorf("test %d returned %v", i, err)
+		}
+		if got, _ := tokens.Token("alice"); got != test.wantToken {
+			t.Errorf("test %d got token %q; want %q", i, got, test.wantToken)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("provider called %d times; want 2", calls)
+	}
+	want := []string{
+		`password "Auth" "secret"`,
+		`password "Auth" "secret"`,
+		`password "Auth" "tok1"`,
+		`username "Auth" "alice"`,
+		`username "Auth" "alice"`,
+		`username "Auth" "alice"`,
+	}
+	if got := server.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong commands\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.txt")
+	if err := os.WriteFile(path, []byte("alice\r\nsecret\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f := FileCredentials(path)
+
+	tests := []struct {
+		req  CredentialRequest
+		want Credentials
+	}{
+		{CredentialRequest{Realm: "Auth", NeedsUsername: true}, Credentials{Username: "alice", Password: "secret"}},
+		{CredentialRequest{Realm: "Private Key"}, Credentials{Password: "alice"}},
+	}
+	for i, test := range tests {
+		got, err := f.Credentials(test.req)
+		if err != nil {
+			t.Errorf("test %d returned error: %s", i, err)
+		} else if got != test.want {
+			t.Errorf("test %d got %+v; want %+v", i, got, test.want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("alice\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Credentials(CredentialRequest{NeedsUsername: true}); err == nil {
+		t.Errorf("missing password did not cause an error")
+	}
+}
@@ -0,0 +1,103 @@
+package openvpn
+
+import "strings"
+
+// DataChannel summarizes the parameters negotiated for the data channel of
+// a tunnel, as recognized in the OpenVPN log by DataChannelFromLog and
+// Session.DataChannel. Fields that were not logged are left empty.
+type DataChannel struct {
+	// Cipher is the data channel cipher, such as "AES-256-GCM".
+	Cipher string
+
+	// Auth is the HMAC digest used to authenticate packets, such as
+	// "SHA256". It is empty for AEAD ciphers, which need none.
+	Auth string
+
+	// Compression is the compression framing in use, such as "lzo" or
+	// "stub", or empty if there is none.
+	Compression string
+
+	// Protocol is the transport protocol, "UDP" or "TCP".
+	Protocol string
+
+	// DCO reports whether the data channel is handled by the kernel using
+	// data channel offload.
+	DCO bool
+}
+
+// DataChannelFromLog returns the data channel parameters recognized in the
+// given log messages, such as those retrieved using MgmtClient.LogHistory,
+// with later messages taking precedence.
+func DataChannelFromLog(events []*LogEvent) DataChannel {
+	var dc DataChannel
+	for _, e := range events {
+		dc.update(e)
+	}
+	return dc
+}
+
+// update records the parameters reported by a log message, returning true
+// if it reported any.
+func (dc *DataChannel) update(e *LogEvent) bool {
+	msg := e.Message()
+	switch {
+	case strings.HasPrefix(msg, "Data Channel: cipher "):
+		// OpenVPN 2.6 and later summarize the parameters in one message,
+		// such as "Data Channel: cipher 'AES-256-CBC', auth 'SHA256',
+		// peer-id: 0, compression: 'lzo'".
+		dc.Cipher, dc.Auth, dc.Compression = "", "", ""
+		for _, field := range strings.Split(strings.TrimPrefix(msg, "Data Channel: "), ", ") {
+			key, value, _ := strings.Cut(field, " ")
+			value = strings.Trim(value, "'")
+			switch strings.TrimSuffix(key, ":") {
+			case "cipher":
+				dc.Cipher = value
+			case "auth":
+				dc.Auth = value
+			case "compression":
+				dc.Compression = value
+			}
+		}
+	case strings.HasPrefix(msg, "Data Channel: using negotiated cipher "):
+		dc.Cipher = quotedValue(msg)
+	case strings.HasPrefix(msg, "Outgoing Data Channel: Cipher "):
+		dc.Cipher = quotedValue(msg)
+	case strings.HasPrefix(msg, "Outgoing Data Channel: Using ") && strings.HasSuffix(msg, " for HMAC authentication"):
+		dc.Auth = quotedValue(msg)
+	case strings.Contains(msg, " link remote: "):
+		// Such as "UDP link remote: ..." or "TCPv4_CLIENT link remote: ...".
+		proto := msg[:strings.Index(msg, " link remote: ")]
+		proto, _, _ = strings.Cut(proto, "_")
+		dc.Protocol = strings.TrimSuffix(strings.TrimSuffix(proto, "v4"), "v6")
+	case strings.HasPrefix(msg, "DCO device ") && strings.HasSuffix(msg, " opened"):
+		dc.DCO = true
+	default:
+		if DCOFallbackFromLog(e) == nil {
+			return false
+		}
+		dc.DCO = false
+	}
+	return true
+}
+
+// quotedValue returns the first single-quoted value in a log message.
+func quotedValue(msg string) string {
+	_, rest, ok := strings.Cut(msg, "'")
+	if !ok {
+		return ""
+	}
+	value, _, _ := strings.Cut(rest, "'")
+	return value
+}
+
+// DataChannel returns the parameters negotiated for the data channel, and
+// false if the tunnel is not connected. They are recognized in the log, so
+// they are only known if log events are enabled and passed to HandleEvent.
+func (s *Session) DataChannel() (DataChannel, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != StateConnected {
+		return DataChannel{}, false
+	}
+	return s.dataChannel, true
+}
@@ -0,0 +1,51 @@
+package openvpn
+
+import "testing"
+
+func TestDataChannelFromLog(t *testing.T) {
+	tests := []struct {
+		msgs []string
+		want DataChannel
+	}{
+		{
+			[]string{
+				"UDPv4 link remote: [AF_INET]192.0.2.1:1194",
+				"DCO device tun0 opened",
+				"Data Channel: cipher 'AES-256-GCM', peer-id: 0",
+			},
+			DataChannel{Cipher: "AES-256-GCM", Protocol: "UDP", DCO: true},
+		},
+		{
+			[]string{
+				"TCP_CLIENT link remote: [AF_INET6]2001:db8::1:443",
+				"Note: --comp-lzo is set, disabling data channel offload.",
+				"Data Channel: cipher 'AES-256-CBC', auth 'SHA256', peer-id: 3, compression: 'lzo'",
+			},
+			DataChannel{Cipher: "AES-256-CBC", Auth: "SHA256", Compression: "lzo", Protocol: "TCP"},
+		},
+		{
+			[]string{
+				"Outgoing Data Channel: Cipher 'AES-128-CBC' initialized with 128 bit key",
+				"Outgoing Data Channel: Using 160 bit message hash 'SHA1' for HMAC authentication",
+				"Initialization Sequence Completed",
+			},
+			DataChannel{Cipher: "AES-128-CBC", Auth: "SHA1"},
+		},
+		{
+			[]string{
+				"Data Channel: cipher 'AES-256-CBC', auth 'SHA256', peer-id: 0",
+				"Data Channel: using negotiated cipher 'CHACHA20-POLY1305'",
+			},
+			DataChannel{Cipher: "CHACHA20-POLY1305", Auth: "SHA256"},
+		},
+	}
+	for i, test := range tests {
+		var events []*LogEvent
+		for _, msg := range test.msgs {
+			events = append(events, &LogEvent{body: []byte("1700000000,I," + msg)})
+		}
+		if got := DataChannelFromLog(events); got != test.want {
+			t.Errorf("test %d got %+v; want %+v", i, got, test.want)
+		}
+	}
+}
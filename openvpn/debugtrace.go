@@ -0,0 +1,171 @@
+package openvpn
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultTraceLimit is the number of entries kept by a DebugTrace whose
+// Limit is zero.
+const DefaultTraceLimit = 10000
+
+// DefaultTraceLimitBytes is the total length of the lines kept by
+// a DebugTrace whose LimitBytes is zero.
+const DefaultTraceLimitBytes = 4 << 20
+
+// TraceEntry is a line sent to or received from OpenVPN, as recorded by
+// a DebugTrace.
+type TraceEntry struct {
+	// Seq numbers the lines in the order they were sent or received,
+	// starting from 1, across both directions.
+	Seq uint64 `json:"seq"`
+
+	// Elapsed is the time from when the trace started to when the line
+	// was sent or received.
+	Elapsed time.Duration `json:"elapsed_ns"`
+
+	// Sent is true for commands sent to OpenVPN, and false for replies
+	// and events received from it.
+	Sent bool `json:"sent"`
+
+	// Line is the line without its line ending, with passwords redacted.
+	Line string `json:"line"`
+}
+
+// Trace is the structured form of a DebugTrace, as returned by its Trace
+// method, suitable for attaching to a bug report.
+type Trace struct {
+	// Started is when the trace started.
+	Started time.Time `json:"started"`
+
+	// Dropped is the number of entries discarded because one of the
+	// trace's limits was reached; the sequence numbers of the remaining
+	// entries start from Dropped+1.
+	Dropped uint64 `json:"dropped"`
+
+	Entries []TraceEntry `json:"entries"`
+}
+
+// DebugTrace records every line on a management connection in memory,
+// annotated with a sequence number and the time since the trace started,
+// so that the exact interleaving of commands, replies and events leading
+// up to a problem can be retrieved for a bug report. Unlike a WireTap, it
+// keeps only the most recent lines, so it can be left enabled.
+//
+// The zero value keeps DefaultTraceLimit entries, of at most
+// DefaultTraceLimitBytes in total. A DebugTrace is safe for concurrent use.
+type DebugTrace struct {
+	// Limit is the number of most recent entries kept, and LimitBytes the
+	// most bytes of lines they may hold, so that a chatty connection such
+	// as one with "log on all" can't use unbounded memory. A line longer
+	// than LimitBytes is truncated. They must be set before Conn is
+	// called.
+	Limit      int
+	LimitBytes int
+
+	mu      sync.Mutex
+	started time.Time
+	seq     uint64
+	in, out []byte
+
+	// entries is a ring of count entries starting at head, holding size
+	// bytes of lines.
+	entries []TraceEntry
+	head    int
+	count   int
+	size    int
+}
+
+// Conn wraps a connection to the management interface so that its traffic
+// is recorded by the trace, which starts on the first call. The result
+// should be passed to NewClient in place of conn.
+func (t *DebugTrace) Conn(conn io.ReadWriteCloser) io.ReadWriteCloser {
+	t.mu.Lock()
+	if t.started.IsZero() {
+		t.started = time.Now()
+	}
+	t.mu.Unlock()
+	return &recordingConn{ReadWriteCloser: conn, record: t.record}
+}
+
+// Trace returns the entries recorded so far, oldest first.
+func (t *DebugTrace) Trace() Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tr := Trace{
+		Started: t.started,
+		Dropped: t.seq - uint64(t.count),
+		Entries: make([]TraceEntry, 0, t.count),
+	}
+	for i := 0; i < t.count; i++ {
+		tr.Entries = append(tr.Entries, t.entries[(t.head+i)%len(t.entries)])
+	}
+	return tr
+}
+
+// WriteJSON writes the result of Trace to w as indented JSON.
+func (t *DebugTrace) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(t.Trace())
+}
+
+// record adds the lines among the given data, sent to OpenVPN if out is
+// true and received from it otherwise.
+func (t *DebugTrace) record(out bool, data []byte, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	partial := &t.in
+	if out {
+		partial = &t.out
+	}
+	splitLines(partial, data, func(line []byte) {
+		t.seq++
+		t.addLocked(TraceEntry{
+			Seq:     t.seq,
+			Elapsed: now.Sub(t.started),
+			Sent:    out,
+			Line:    string(redactWireLine(out, line)),
+		})
+	})
+}
+
+// addLocked adds an entry to the ring, first discarding the oldest entries
+// as needed to stay within the limits. It must be called with t.mu held.
+func (t *DebugTrace) addLocked(entry TraceEntry) {
+	limit, limitBytes := t.Limit, t.LimitBytes
+	if limit <= 0 {
+		limit = DefaultTraceLimit
+	}
+	if limitBytes <= 0 {
+		limitBytes = DefaultTraceLimitBytes
+	}
+	if len(entry.Line) > limitBytes {
+		entry.Line = entry.Line[:limitBytes]
+	}
+
+	for t.count > 0 && (t.count >= limit || t.size+len(entry.Line) > limitBytes) {
+		t.size -= len(t.entries[t.head].Line)
+		t.entries[t.head] = TraceEntry{}
+		t.head = (t.head + 1) % len(t.entries)
+		t.count--
+	}
+
+	if t.count == len(t.entries) {
+		// The ring is full but below the limit, so it can grow, once
+		// its entries are in order.
+		if t.head != 0 {
+			t.entries = append(t.entries[t.head:len(t.entries):len(t.entries)], t.entries[:t.head]...)
+			t.head = 0
+		}
+		t.entries = append(t.entries, entry)
+	} else {
+		t.entries[(t.head+t.count)%len(t.entries)] = entry
+	}
+	t.count++
+	t.size += len(entry.Line)
+}
@@ -0,0 +1,111 @@
+package openvpn
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDebugTrace(t *testing.T) {
+	trace := &DebugTrace{}
+	server, conn := net.Pipe()
+	events := make(chan Event, 10)
+	client := NewClient(trace.Conn(conn), events)
+
+	go func() {
+		server.Write([]byte(">HOLD:Waiting for hold release:0\r\n"))
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			server.Write([]byte("SUCCESS: password is correct\r\n"))
+		}
+	}()
+	<-events
+	if err := client.Credentials("Private Key", "", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	server.Close()
+	for range events {
+	}
+
+	tr := trace.Trace()
+	var got []TraceEntry
+	var last time.Duration
+	for _, e := range tr.Entries {
+		if e.Elapsed < last {
+			t.Errorf("entry %d elapsed %s is before previous %s", e.Seq, e.Elapsed, last)
+		}
+		last = e.Elapsed
+		e.Elapsed = 0
+		got = append(got, e)
+	}
+	want := []TraceEntry{
+		{Seq: 1, Line: ">HOLD:Waiting for hold release:0"},
+		{Seq: 2, Sent: true, Line: `password "Private Key" [redacted]`},
+		{Seq: 3, Line: "SUCCESS: password is correct"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got entries %+v; want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := trace.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Trace
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Entries, tr.Entries) || !decoded.Started.Equal(tr.Started) {
+		t.Errorf("JSON trace doesn't round-trip: got %+v; want %+v", decoded, tr)
+	}
+}
+
+func TestDebugTraceLimit(t *testing.T) {
+	trace := &DebugTrace{Limit: 3}
+	start := time.Unix(1000, 0)
+	trace.started = start
+	trace.record(false, []byte("a\nb\r\nc"), start.Add(time.Second))
+	trace.record(true, []byte("d\ne\n"), start.Add(2*time.Second))
+	trace.record(false, []byte("\n"), start.Add(3*time.Second))
+
+	tr := trace.Trace()
+	want := Trace{
+		Started: start,
+		Dropped: 2,
+		Entries: []TraceEntry{
+			{Seq: 3, Elapsed: 2 * time.Second, Sent: true, Line: "d"},
+			{Seq: 4, Elapsed: 2 * time.Second, Sent: true, Line: "e"},
+			{Seq: 5, Elapsed: 3 * time.Second, Line: "c"},
+		},
+	}
+	if !reflect.DeepEqual(tr, want) {
+		t.Errorf("got %+v; want %+v", tr, want)
+	}
+}
+
+func TestDebugTraceLimitBytes(t *testing.T) {
+	trace := &DebugTrace{LimitBytes: 8}
+	start := time.Unix(1000, 0)
+	trace.started = start
+	trace.record(false, []byte("abc\ndef\n"), start)
+	trace.record(false, []byte("ghij\n"), start)
+	trace.record(true, []byte("0123456789\n"), start)
+
+	tr := trace.Trace()
+	want := []TraceEntry{{Seq: 4, Sent: true, Line: "01234567"}}
+	if tr.Dropped != 3 || !reflect.DeepEqual(tr.Entries, want) {
+		t.Errorf("got %d dropped, entries %+v; want 3 dropped, entries %+v", tr.Dropped, tr.Entries, want)
+	}
+
+	trace.record(false, []byte("a\nb\nc\n"), start)
+	tr = trace.Trace()
+	want = []TraceEntry{{Seq: 5, Line: "a"}, {Seq: 6, Line: "b"}, {Seq: 7, Line: "c"}}
+	if tr.Dropped != 4 || !reflect.DeepEqual(tr.Entries, want) {
+		t.Errorf("got %d dropped, entries %+v; want 4 dropped, entries %+v", tr.Dropped, tr.Entries, want)
+	}
+}
@@ -0,0 +1,69 @@
+package openvpn
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// DNSConfig is the DNS configuration pushed by a server with dhcp-option,
+// as returned by ParseDNSConfig and Session.DNSConfig.
+type DNSConfig struct {
+	// Servers are the addresses of the DNS servers, from the DNS and DNS6
+	// options, in the order they were pushed.
+	Servers []netip.Addr
+
+	// Domain is the connection-specific DNS suffix, from the DOMAIN
+	// option. If it was pushed more than once, the first is used.
+	Domain string
+
+	// SearchDomains are the domains to search when resolving names, from
+	// the DOMAIN-SEARCH option, and, after the first, the DOMAIN option.
+	SearchDomains []string
+}
+
+// IsZero reports whether the config is empty, such that no DNS options
+// were pushed.
+func (c DNSConfig) IsZero() bool {
+	return len(c.Servers) == 0 && c.Domain == "" && len(c.SearchDomains) == 0
+}
+
+// ParseDNSConfig returns the DNS configuration held in the foreign_option_N
+// variables of the given environment, such as that given to a TunnelHooks
+// OnTunnelUp hook. Options it does not recognize, and addresses that are
+// malformed, are ignored.
+func ParseDNSConfig(env Env) DNSConfig {
+	var c DNSConfig
+	for _, opt := range env.Indexed("foreign_option_") {
+		fields := strings.Fields(opt)
+		if len(fields) != 3 || fields[0] != "dhcp-option" {
+			continue
+		}
+		switch value := fields[2]; fields[1] {
+		case "DNS", "DNS6":
+			if addr, err := netip.ParseAddr(value); err == nil {
+				c.Servers = append(c.Servers, addr)
+			}
+		case "DOMAIN":
+			if c.Domain == "" {
+				c.Domain = value
+			} else {
+				c.SearchDomains = append(c.SearchDomains, value)
+			}
+		case "DOMAIN-SEARCH":
+			c.SearchDomains = append(c.SearchDomains, value)
+		}
+	}
+	return c
+}
+
+// DNSConfig returns the DNS configuration pushed by the server, and false
+// if the tunnel is not connected. It is taken from UPDOWN messages, so it
+// is only known if OpenVPN was launched with --management-up-down.
+func (s *Session) DNSConfig() (DNSConfig, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != StateConnected {
+		return DNSConfig{}, false
+	}
+	return s.dns, true
+}
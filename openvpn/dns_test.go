@@ -0,0 +1,75 @@
+package openvpn
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestParseDNSConfig(t *testing.T) {
+	tests := []struct {
+		env  Env
+		want DNSConfig
+	}{
+		{Env{}, DNSConfig{}},
+		{
+			Env{
+				"foreign_option_1": "dhcp-option DNS 10.8.0.1",
+				"foreign_option_2": "dhcp-option DNS6 fd00::1",
+				"foreign_option_3": "dhcp-option DOMAIN corp.example",
+				"foreign_option_4": "dhcp-option DOMAIN-SEARCH example",
+				"foreign_option_5": "dhcp-option DOMAIN lab.example",
+				"foreign_option_6": "dhcp-option NTP 10.8.0.1",
+				"foreign_option_7": "dhcp-option DNS bogus",
+			},
+			DNSConfig{
+				Servers:       []netip.Addr{netip.MustParseAddr("10.8.0.1"), netip.MustParseAddr("fd00::1")},
+				Domain:        "corp.example",
+				SearchDomains: []string{"example", "lab.example"},
+			},
+		},
+		{
+			// The sequence stops at the first missing index.
+			Env{
+				"foreign_option_1": "dhcp-option DNS 10.8.0.1",
+				"foreign_option_3": "dhcp-option DNS 10.8.0.2",
+			},
+			DNSConfig{Servers: []netip.Addr{netip.MustParseAddr("10.8.0.1")}},
+		},
+	}
+	for i, test := range tests {
+		if got := ParseDNSConfig(test.env); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %+v; want %+v", i, got, test.want)
+		}
+	}
+}
+
+func TestSessionDNSConfig(t *testing.T) {
+	var s Session
+	var envs envAssembler
+	lines := []string{
+		"UPDOWN:UP",
+		"UPDOWN:ENV,foreign_option_1=dhcp-option DNS 10.8.0.1",
+		"UPDOWN:ENV,END",
+	}
+	for _, line := range lines {
+		if e := envs.push(upgradeEvent([]byte(line))); e != nil {
+			s.HandleEvent(e)
+		}
+	}
+	if _, ok := s.DNSConfig(); ok {
+		t.Errorf("got DNS config before connecting")
+	}
+
+	s.HandleEvent(upgradeEvent([]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1,1194,,")))
+	want := DNSConfig{Servers: []netip.Addr{netip.MustParseAddr("10.8.0.1")}}
+	if got, ok := s.DNSConfig(); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, %v; want %+v, true", got, ok, want)
+	}
+
+	s.HandleEvent(upgradeEvent([]byte("STATE:2,RECONNECTING,ping-restart,,,,,")))
+	s.HandleEvent(upgradeEvent([]byte("STATE:3,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1,1194,,")))
+	if got, _ := s.DNSConfig(); !got.IsZero() {
+		t.Errorf("got %+v after reconnecting without UPDOWN; want empty", got)
+	}
+}
@@ -0,0 +1,83 @@
+package openvpn
+
+import (
+	"net"
+	"sort"
+	"strconv"
+)
+
+// DuplicateClientEvent reports that a client has connected to a server
+// with the same common name as another client connected from a different
+// real address, as detected by a ClientRegistry.
+//
+// It is not received from OpenVPN, but implements Event so that it can be
+// published along with other events, such as on an EventBus.
+type DuplicateClientEvent struct {
+	CommonName string
+
+	// Existing is the client that was already connected, and New the one
+	// that has just connected or been found to share its common name.
+	Existing ConnectedClient
+	New      ConnectedClient
+}
+
+func (e *DuplicateClientEvent) String() string {
+	var buf [128]byte
+	b := append(buf[:0], "duplicate common name "...)
+	b = strconv.AppendQuote(b, e.CommonName)
+	b = append(b, ": client "...)
+	b = strconv.AppendInt(b, e.Existing.ClientID, 10)
+	b = append(b, " from "...)
+	b = append(b, e.Existing.RealAddress...)
+	b = append(b, " and client "...)
+	b = strconv.AppendInt(b, e.New.ClientID, 10)
+	b = append(b, " from "...)
+	b = append(b, e.New.RealAddress...)
+	return string(b)
+}
+
+// duplicatesLocked returns an event for each other client sharing the
+// common name of cc, which has just been updated from before, from
+// a different host. Nothing is returned unless the update revealed the
+// client's common name or real address, so that each duplicate is
+// reported once. It must be called with r.mu held, after reindexLocked.
+func (r *ClientRegistry) duplicatesLocked(before, cc *ConnectedClient) []*DuplicateClientEvent {
+	if cc.CommonName == "" || cc.RealAddress == "" {
+		return nil
+	}
+	if before.CommonName == cc.CommonName && before.RealAddress == cc.RealAddress {
+		return nil
+	}
+
+	host := addressHost(cc.RealAddress)
+	var ret []*DuplicateClientEvent
+	for cid := range r.byCommonName[cc.CommonName] {
+		other := r.clients[cid]
+		if cid == cc.ClientID || other.RealAddress == "" {
+			continue
+		}
+		if addressHost(other.RealAddress) == host {
+			continue
+		}
+		ret = append(ret, &DuplicateClientEvent{
+			CommonName: cc.CommonName,
+			Existing:   *other,
+			New:        *cc,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Existing.ClientID < ret[j].Existing.ClientID
+	})
+	return ret
+}
+
+// addressHost returns the host part of a real address, so that
+// connections from different ports of the same host are not considered
+// duplicates.
+func addressHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,62 @@
+package openvpn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateClients(t *testing.T) {
+	var dups []string
+	r := &ClientRegistry{OnDuplicate: func(e *DuplicateClientEvent) {
+		dups = append(dups, e.Existing.RealAddress+" "+e.New.RealAddress)
+	}}
+
+	var envs envAssembler
+	for _, raw := range []string{
+		"CLIENT:CONNECT,1,0",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,untrusted_ip=192.0.2.10",
+		"CLIENT:ENV,untrusted_port=50000",
+		"CLIENT:ENV,END",
+		// The same host connecting again is not a duplicate.
+		"CLIENT:CONNECT,2,0",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,untrusted_ip=192.0.2.10",
+		"CLIENT:ENV,untrusted_port=50001",
+		"CLIENT:ENV,END",
+		"CLIENT:CONNECT,3,0",
+		"CLIENT:ENV,common_name=bob",
+		"CLIENT:ENV,untrusted_ip=198.51.100.7",
+		"CLIENT:ENV,untrusted_port=40000",
+		"CLIENT:ENV,END",
+		"CLIENT:CONNECT,4,0",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,untrusted_ip=203.0.113.5",
+		"CLIENT:ENV,untrusted_port=1194",
+		"CLIENT:ENV,END",
+		// Further events for the duplicate don't report it again.
+		"CLIENT:ESTABLISHED,4",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,END",
+	} {
+		if e := envs.push(upgradeEvent([]byte(raw))); e != nil {
+			r.HandleEvent(e)
+		}
+	}
+	r.Sync([]ClientStatus{
+		{ClientID: 1, CommonName: "alice", RealAddress: "192.0.2.10:50000"},
+		{ClientID: 2, CommonName: "alice", RealAddress: "192.0.2.10:50001"},
+		{ClientID: 3, CommonName: "bob", RealAddress: "198.51.100.7:40000"},
+		{ClientID: 4, CommonName: "alice", RealAddress: "203.0.113.5:1194"},
+		{ClientID: 5, CommonName: "bob", RealAddress: "[2001:db8::1]:1194"},
+	})
+
+	want := []string{
+		"192.0.2.10:50000 203.0.113.5:1194",
+		"192.0.2.10:50001 203.0.113.5:1194",
+		"198.51.100.7:40000 [2001:db8::1]:1194",
+	}
+	if !reflect.DeepEqual(dups, want) {
+		t.Errorf("got duplicates %q; want %q", dups, want)
+	}
+}
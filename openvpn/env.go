@@ -0,0 +1,168 @@
+package openvpn
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NordSecurity/gopenvpn/demux"
+)
+
+// Env is a set of environment variables sent by OpenVPN along with certain
+// events, mapping variable names to values.
+//
+// The variables are the same ones OpenVPN sets when running a script for
+// the equivalent situation. See the "Environmental Variables" section of
+// the OpenVPN manual page for details.
+type Env map[string]string
+
+// Get returns the value of the given variable, or the empty string if it
+// is not set.
+func (e Env) Get(name string) string {
+	return e[name]
+}
+
+// Lookup returns the value of the given variable and whether it is set.
+func (e Env) Lookup(name string) (string, bool) {
+	v, ok := e[name]
+	return v, ok
+}
+
+// Indexed returns the values of a family of numbered variables such as
+// route_network_1, route_network_2, etc, given their common prefix
+// ("route_network_"), in index order. The sequence stops at the first
+// missing index, just as scripts conventionally iterate over them.
+func (e Env) Indexed(prefix string) []string {
+	var ret []string
+	for i := 1; ; i++ {
+		v, ok := e[prefix+strconv.Itoa(i)]
+		if !ok {
+			return ret
+		}
+		ret = append(ret, v)
+	}
+}
+
+// Names returns the names of all of the variables, sorted lexically.
+func (e Env) Names() []string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environ returns the variables in the "name=value" form used by os.Environ
+// and exec.Cmd, sorted by name, for passing to an external program.
+func (e Env) Environ() []string {
+	names := e.Names()
+	ret := make([]string, len(names))
+	for i, name := range names {
+		ret[i] = name + "=" + e[name]
+	}
+	return ret
+}
+
+func (e Env) String() string {
+	return strings.Join(e.Environ(), " ")
+}
+
+// envEventReceiver is implemented by events that may be followed by an
+// environment block. hasEnv reports whether a particular event is.
+type envEventReceiver interface {
+	Event
+	hasEnv() bool
+	setEnvLines([][]byte)
+}
+
+// lazyEnv holds the environment of an event as the messages it was sent
+// in, parsing them into an Env only when it is first asked for, so that
+// consumers that only look at the type of a client event don't pay for
+// building a map of its dozens of variables.
+type lazyEnv struct {
+	once sync.Once
+
+	// lines holds the bodies of the ENV messages, each "name=value", or
+	// is nil if there was no environment block.
+	lines [][]byte
+	env   Env
+}
+
+// get returns the environment, parsing it on the first call. It is safe
+// for concurrent use.
+func (l *lazyEnv) get() Env {
+	l.once.Do(func() {
+		if l.lines == nil {
+			return
+		}
+		env := make(Env, len(l.lines))
+		for _, line := range l.lines {
+			e := EnvEvent{body: line}
+			name, value := e.split()
+			env[name] = value
+			// The variable has been copied, so nothing needs the
+			// message any more.
+			demux.Release(line)
+		}
+		l.env, l.lines = env, nil
+	})
+	return l.env
+}
+
+// set replaces the environment with one that has already been parsed.
+func (l *lazyEnv) set(env Env) {
+	l.once.Do(func() {})
+	l.env, l.lines = env, nil
+}
+
+// envAssembler merges environment blocks into the events they follow.
+//
+// OpenVPN sends such an environment as a separate message per variable,
+// followed by an END message, so the assembler holds back the leading
+// event until the block is complete.
+type envAssembler struct {
+	pending envEventReceiver
+	lines   [][]byte
+}
+
+// push accepts the next event received from OpenVPN and returns the event
+// that should be emitted as a result, if any.
+func (a *envAssembler) push(e Event) Event {
+	if recv, ok := e.(envEventReceiver); ok && recv.hasEnv() {
+		// Should never happen, but if a new block begins before the
+		// previous one ends then we'll emit what we got so far rather
+		// than losing the event altogether.
+		prev := a.flush()
+		a.pending = recv
+		a.lines = [][]byte{}
+		return prev
+	}
+
+	envEvent, ok := e.(*EnvEvent)
+	if !ok || a.pending == nil {
+		return e
+	}
+
+	if envEvent.IsEnd() {
+		demux.Release(envEvent.body)
+		return a.flush()
+	}
+	// The variable is parsed only if the event's environment is asked for.
+	a.lines = append(a.lines, envEvent.body)
+	return nil
+}
+
+// flush returns the pending event, if any, with whatever environment has
+// been collected so far.
+func (a *envAssembler) flush() Event {
+	if a.pending == nil {
+		return nil
+	}
+	ret := a.pending
+	ret.setEnvLines(a.lines)
+	a.pending = nil
+	a.lines = nil
+	return ret
+}
@@ -0,0 +1,120 @@
+package openvpn
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestEnvAssembler(t *testing.T) {
+	input := []string{
+		"STATE:1,ASSIGN_IP,,10.8.0.2,",
+		"UPDOWN:UP",
+		"UPDOWN:ENV,dev=tun0",
+		"UPDOWN:ENV,route_network_1=10.0.0.0",
+		"UPDOWN:ENV,route_network_2=10.1.0.0",
+		"UPDOWN:ENV,END",
+		"UPDOWN:ENV,stray=true",
+		"UPDOWN:DOWN",
+		"UPDOWN:ENV,dev=tun0",
+	}
+
+	var a envAssembler
+	var got []Event
+	for _, raw := range input {
+		if event := a.push(upgradeEvent([]byte(raw))); event != nil {
+			got = append(got, event)
+		}
+	}
+	if event := a.flush(); event != nil {
+		got = append(got, event)
+	}
+
+	if len(got) != 4 {
+		t.Fatalf("got %d events; want 4: %v", len(got), got)
+	}
+	if _, ok := got[0].(*StateEvent); !ok {
+		t.Errorf("event 0 is %T; want *StateEvent", got[0])
+	}
+
+	up, ok := got[1].(*UpDownEvent)
+	if !ok {
+		t.Fatalf("event 1 is %T; want *UpDownEvent", got[1])
+	}
+	wantEnv := Env{
+		"dev":             "tun0",
+		"route_network_1": "10.0.0.0",
+		"route_network_2": "10.1.0.0",
+	}
+	if !reflect.DeepEqual(up.Env(), wantEnv) {
+		t.Errorf("wrong env\ngot  %#v\nwant %#v", up.Env(), wantEnv)
+	}
+	if got, want := up.Env().Indexed("route_network_"), []string{"10.0.0.0", "10.1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Indexed returned %q; want %q", got, want)
+	}
+
+	// An environment line outside of a block is passed through as-is.
+	if _, ok := got[2].(*EnvEvent); !ok {
+		t.Errorf("event 2 is %T; want *EnvEvent", got[2])
+	}
+
+	// An incomplete block is flushed when the connection closes.
+	down, ok := got[3].(*UpDownEvent)
+	if !ok {
+		t.Fatalf("event 3 is %T; want *UpDownEvent", got[3])
+	}
+	if got, want := down.Env().Get("dev"), "tun0"; got != want {
+		t.Errorf("dev is %q; want %q", got, want)
+	}
+}
+
+func TestTunnelHooks(t *testing.T) {
+	var ups, downs []Env
+	hooks := &TunnelHooks{
+		OnTunnelUp:   func(env Env) { ups = append(ups, env) },
+		OnTunnelDown: func(env Env) { downs = append(downs, env) },
+	}
+
+	up := &UpDownEvent{body: []byte("UP")}
+	up.setEnv(Env{"dev": "tun0"})
+	down := &UpDownEvent{body: []byte("DOWN")}
+	down.setEnv(Env{"dev": "tun0"})
+	for _, event := range []Event{up, &HoldEvent{}, down} {
+		hooks.HandleEvent(event)
+	}
+
+	if len(ups) != 1 || ups[0].Get("dev") != "tun0" {
+		t.Errorf("OnTunnelUp calls: %v", ups)
+	}
+	if len(downs) != 1 {
+		t.Errorf("OnTunnelDown calls: %v", downs)
+	}
+}
+
+func TestLazyEnv(t *testing.T) {
+	var a envAssembler
+	a.push(upgradeEvent([]byte("CLIENT:CONNECT,1,2")))
+	event := a.push(upgradeEvent([]byte("CLIENT:ENV,END")))
+	if env := event.(*ClientEvent).Env(); env == nil || len(env) != 0 {
+		t.Errorf("empty environment block gave %#v; want empty Env", env)
+	}
+	if env := upgradeEvent([]byte("CLIENT:ADDRESS,1,10.8.0.2,1")).(*ClientEvent).Env(); env != nil {
+		t.Errorf("ADDRESS event gave %#v; want nil Env", env)
+	}
+
+	// The environment may be parsed by concurrent callers.
+	a.push(upgradeEvent([]byte("CLIENT:CONNECT,2,0")))
+	a.push(upgradeEvent([]byte("CLIENT:ENV,common_name=bob")))
+	ce := a.push(upgradeEvent([]byte("CLIENT:ENV,END"))).(*ClientEvent)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := ce.Env().Get("common_name"); got != "bob" {
+				t.Errorf("common_name is %q; want %q", got, "bob")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -1,5 +1,11 @@
 package openvpn
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 type ErrorFromServer []byte
 
 func (err ErrorFromServer) Error() string {
@@ -9,3 +15,99 @@ func (err ErrorFromServer) Error() string {
 func (err ErrorFromServer) String() string {
 	return string(err)
 }
+
+// Connection failures that may be reported by ClassifyEvent, and by the
+// Session methods that wait for the tunnel to reach a state. They are
+// wrapped in a *ConnectionError, so should be tested for using errors.Is.
+var (
+	ErrAuthFailed   = errors.New("authentication failed")
+	ErrTLSHandshake = errors.New("TLS handshake failed")
+	ErrResolve      = errors.New("cannot resolve remote host")
+	ErrPingRestart  = errors.New("connection timed out (ping-restart)")
+	ErrProxy        = errors.New("proxy failure")
+)
+
+// ConnectionError describes a connection failure reported by OpenVPN.
+type ConnectionError struct {
+	// Err is the kind of failure, such as ErrAuthFailed.
+	Err error
+
+	// Message is the text OpenVPN reported, such as a log message or the
+	// reason given for reconnecting.
+	Message string
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err, e.Message)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// failurePatterns map substrings of FATAL and log messages to the kind of
+// failure they report.
+var failurePatterns = []struct {
+	substr string
+	err    error
+}{
+	{"AUTH_FAILED", ErrAuthFailed},
+	{"Cannot resolve host address", ErrResolve},
+	{"TLS Error: ", ErrTLSHandshake},
+	{"VERIFY ERROR", ErrTLSHandshake},
+	{"proxy returned bad", ErrProxy},
+	{"Socks proxy returned", ErrProxy},
+	{"Inactivity timeout (--ping-restart)", ErrPingRestart},
+}
+
+// reconnectReasons map the reasons OpenVPN gives for reconnecting to the
+// kind of failure they report.
+var reconnectReasons = map[string]error{
+	"auth-failure": ErrAuthFailed,
+	"tls-error":    ErrTLSHandshake,
+	"ping-restart": ErrPingRestart,
+}
+
+// ClassifyEvent returns a *ConnectionError if the given event reports
+// a recognized kind of connection failure, and nil otherwise. Failures are
+// recognized in PasswordEvents reporting rejected credentials, in the
+// reasons given by StateEvents for reconnecting or exiting, and in
+// FatalEvents and LogEvents.
+func ClassifyEvent(e Event) error {
+	switch e := e.(type) {
+	case *PasswordEvent:
+		if !e.VerificationFailed() {
+			return nil
+		}
+		if _, ok := e.DynamicChallenge(); ok {
+			// The server is merely asking for more information.
+			return nil
+		}
+		if e.Realm() == "HTTP Proxy" {
+			return &ConnectionError{Err: ErrProxy, Message: string(e.body)}
+		}
+		return &ConnectionError{Err: ErrAuthFailed, Message: string(e.body)}
+	case *StateEvent:
+		switch State(e.NewState()) {
+		case StateReconnecting, StateExiting:
+			if err, ok := reconnectReasons[e.Description()]; ok {
+				return &ConnectionError{Err: err, Message: e.Description()}
+			}
+		}
+		return nil
+	case *FatalEvent:
+		return classifyMessage(string(e.body))
+	case *LogEvent:
+		return classifyMessage(e.Message())
+	}
+	return nil
+}
+
+func classifyMessage(msg string) error {
+	for _, p := range failurePatterns {
+		if strings.Contains(msg, p.substr) {
+			return &ConnectionError{Err: p.err, Message: msg}
+		}
+	}
+	return nil
+}
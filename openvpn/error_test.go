@@ -0,0 +1,43 @@
+package openvpn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClassifyEvent(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want error
+	}{
+		{"PASSWORD:Verification Failed: 'Auth'", ErrAuthFailed},
+		{"PASSWORD:Verification Failed: 'HTTP Proxy'", ErrProxy},
+		{"PASSWORD:Verification Failed: 'Auth' ['CRV1:R,E:state:Y3Ix:Enter PIN']", nil},
+		{"PASSWORD:Need 'Auth' username/password", nil},
+		{"STATE:1,RECONNECTING,tls-error,,", ErrTLSHandshake},
+		{"STATE:1,RECONNECTING,ping-restart,,", ErrPingRestart},
+		{"STATE:1,EXITING,auth-failure,,", ErrAuthFailed},
+		{"STATE:1,RECONNECTING,SIGHUP,,", nil},
+		{"STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1", nil},
+		{"LOG:1,W,RESOLVE: Cannot resolve host address: vpn.example.com:1194 (Name or service not known)", ErrResolve},
+		{"LOG:1,N,TLS Error: TLS key negotiation failed to occur within 60 seconds (check your network connectivity)", ErrTLSHandshake},
+		{"LOG:1,N,HTTP proxy returned bad status", ErrProxy},
+		{"LOG:1,I,Send to HTTP proxy: 'CONNECT vpn.example.com:443 HTTP/1.0'", nil},
+		{"FATAL:AUTH_FAILED", ErrAuthFailed},
+		{"FATAL:Error reading from OpenVPN", nil},
+		{"HOLD:Waiting for hold release", nil},
+	}
+	for i, test := range tests {
+		err := ClassifyEvent(upgradeEvent([]byte(test.raw)))
+		if test.want == nil {
+			if err != nil {
+				t.Errorf("test %d got %v; want nil", i, err)
+			}
+			continue
+		}
+		var cerr *ConnectionError
+		if !errors.As(err, &cerr) || !errors.Is(err, test.want) {
+			t.Errorf("test %d got %v; want a *ConnectionError wrapping %v", i, err, test.want)
+		}
+	}
+}
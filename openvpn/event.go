@@ -2,13 +2,20 @@ package openvpn
 
 import (
 	"bytes"
-	"fmt"
+	"log/slog"
 	"strconv"
+	"strings"
+	"sync"
+)
+
+// eventSep separates the keyword of an event from its body, and fieldSep
+// the fields of the body.
+const (
+	eventSep = ':'
+	fieldSep = ','
 )
 
 var (
-	eventSep            = []byte(":")
-	fieldSep            = []byte(",")
 	byteCountEventKW    = []byte("BYTECOUNT")
 	byteCountCliEventKW = []byte("BYTECOUNT_CLI")
 	clientEventKW       = []byte("CLIENT")
@@ -16,11 +23,21 @@ var (
 	fatalEventKW        = []byte("FATAL")
 	holdEventKW         = []byte("HOLD")
 	infoEventKW         = []byte("INFO")
+	infoMsgEventKW      = []byte("INFOMSG")
 	logEventKW          = []byte("LOG")
 	needOkEventKW       = []byte("NEED-OK")
 	needStrEventKW      = []byte("NEED-STR")
 	passwordEventKW     = []byte("PASSWORD")
+	remoteEventKW       = []byte("REMOTE")
 	stateEventKW        = []byte("STATE")
+	upDownEventKW       = []byte("UPDOWN")
+	dcoFallbackMsg      = "disabling data channel offload"
+	envPrefix           = []byte("ENV,")
+	envEnd              = []byte("END")
+
+	passwordNeedPrefix     = []byte("Need ")
+	passwordFailedPrefix   = []byte("Verification Failed: ")
+	passwordUsernameMarker = []byte("username/password")
 )
 
 type Event interface {
@@ -48,7 +65,7 @@ func (e *UnknownEvent) Body() string {
 }
 
 func (e *UnknownEvent) String() string {
-	return fmt.Sprintf("%s: %s", e.keyword, e.body)
+	return string(e.keyword) + ": " + string(e.body)
 }
 
 // MalformedEvent represents a message from the OpenVPN process that is
@@ -65,7 +82,7 @@ type MalformedEvent struct {
 }
 
 func (e *MalformedEvent) String() string {
-	return fmt.Sprintf("Malformed Event %q", e.raw)
+	return "Malformed Event " + strconv.Quote(string(e.raw))
 }
 
 // HoldEvent is a notification that the OpenVPN process is in a management
@@ -85,25 +102,22 @@ func (e *HoldEvent) String() string {
 type StateEvent struct {
 	body []byte
 
-	// bodyParts is populated only on first request, giving us the
+	// fields is populated only on first request, locating the
 	// separate comma-separated elements of the message. Not all
 	// fields are populated for all states.
-	bodyParts [][]byte
+	fields fieldOffsets
 }
 
 func (e *StateEvent) RawTimestamp() string {
-	parts := e.parts()
-	return string(parts[0])
+	return string(e.field(0))
 }
 
 func (e *StateEvent) NewState() string {
-	parts := e.parts()
-	return string(parts[1])
+	return string(e.field(1))
 }
 
 func (e *StateEvent) Description() string {
-	parts := e.parts()
-	return string(parts[2])
+	return string(e.field(2))
 }
 
 // LocalTunnelAddr returns the IP address of the local interface within
@@ -114,11 +128,14 @@ func (e *StateEvent) Description() string {
 //
 // When both IPv6 and IPv4 addresses are available, the IPv6 one is returned.
 func (e *StateEvent) LocalTunnelAddr() string {
-	parts := e.parts()
-	if len(parts) > 8 { // IPv6
-		return string(parts[8])
+	return string(e.localTunnelAddr())
+}
+
+func (e *StateEvent) localTunnelAddr() []byte {
+	if ipv6 := e.field(8); ipv6 != nil {
+		return ipv6
 	}
-	return string(parts[3])
+	return e.field(3)
 }
 
 // RemoteAddr returns the non-tunnel IP address of the remote
@@ -127,41 +144,30 @@ func (e *StateEvent) LocalTunnelAddr() string {
 // This field is only populated for events whose NewState returns
 // CONNECTED.
 func (e *StateEvent) RemoteAddr() string {
-	parts := e.parts()
-	return string(parts[4])
+	return string(e.field(4))
 }
 
 func (e *StateEvent) String() string {
-	newState := e.NewState()
-	switch newState {
+	// The fields are converted only once, as part of the concatenation,
+	// to avoid allocating for each of them.
+	newState := e.field(1)
+	var detail []byte
+	switch string(newState) {
 	case "ASSIGN_IP":
-		return fmt.Sprintf("%s: %s", newState, e.LocalTunnelAddr())
+		detail = e.localTunnelAddr()
 	case "CONNECTED":
-		return fmt.Sprintf("%s: %s", newState, e.RemoteAddr())
+		detail = e.field(4)
 	default:
-		desc := e.Description()
-		if desc != "" {
-			return fmt.Sprintf("%s: %s", newState, desc)
-		} else {
-			return newState
+		detail = e.field(2)
+		if len(detail) == 0 {
+			return string(newState)
 		}
 	}
+	return string(newState) + ": " + string(detail)
 }
 
-func (e *StateEvent) parts() [][]byte {
-	if e.bodyParts == nil {
-		e.bodyParts = bytes.SplitN(e.body, fieldSep, 9)
-
-		// Prevent crash if the server has sent us a malformed
-		// status message. This should never actually happen if
-		// the server is behaving itself.
-		if len(e.bodyParts) < 8 {
-			expanded := make([][]byte, 8)
-			copy(expanded, e.bodyParts)
-			e.bodyParts = expanded
-		}
-	}
-	return e.bodyParts
+func (e *StateEvent) field(i int) []byte {
+	return e.fields.field(e.body, 9, i)
 }
 
 // EchoEvent is emitted by an OpenVPN process running in client mode when
@@ -178,7 +184,7 @@ type EchoEvent struct {
 }
 
 func (e *EchoEvent) RawTimestamp() string {
-	sepIndex := bytes.Index(e.body, fieldSep)
+	sepIndex := bytes.IndexByte(e.body, fieldSep)
 	if sepIndex == -1 {
 		return ""
 	}
@@ -186,15 +192,19 @@ func (e *EchoEvent) RawTimestamp() string {
 }
 
 func (e *EchoEvent) Message() string {
-	sepIndex := bytes.Index(e.body, fieldSep)
-	if sepIndex == -1 {
-		return ""
-	}
-	return string(e.body[sepIndex+1:])
+	return string(e.message())
 }
 
 func (e *EchoEvent) String() string {
-	return fmt.Sprintf("ECHO: %s", e.Message())
+	return "ECHO: " + string(e.message())
+}
+
+func (e *EchoEvent) message() []byte {
+	sepIndex := bytes.IndexByte(e.body, fieldSep)
+	if sepIndex == -1 {
+		return nil
+	}
+	return e.body[sepIndex+1:]
 }
 
 // ByteCountEvent represents a periodic snapshot of data transfer in bytes
@@ -210,8 +220,12 @@ type ByteCountEvent struct {
 	hasClient bool
 	body      []byte
 
-	// populated on first call to parts()
-	bodyParts [][]byte
+	// scratch is set for events belonging to an EventScratch, which
+	// mustn't be recycled.
+	scratch bool
+
+	// populated on first call to field()
+	fields fieldOffsets
 }
 
 func (e *ByteCountEvent) ClientId() string {
@@ -219,7 +233,16 @@ func (e *ByteCountEvent) ClientId() string {
 		return ""
 	}
 
-	return string(e.parts()[0])
+	return string(e.field(0))
+}
+
+// clientID returns the client id as a number, without the allocation of
+// ClientId, or false if the event isn't for a client or is malformed.
+func (e *ByteCountEvent) clientID() (int64, bool) {
+	if !e.hasClient {
+		return 0, false
+	}
+	return parseDecimal(e.field(0))
 }
 
 func (e *ByteCountEvent) BytesIn() int {
@@ -227,11 +250,10 @@ func (e *ByteCountEvent) BytesIn() int {
 	if e.hasClient {
 		index = 1
 	}
-	str := string(e.parts()[index])
-	val, _ := strconv.Atoi(str)
 	// Ignore error, since this should never happen if OpenVPN is
 	// behaving itself.
-	return val
+	val, _ := parseDecimal(e.field(index))
+	return int(val)
 }
 
 func (e *ByteCountEvent) BytesOut() int {
@@ -239,40 +261,29 @@ func (e *ByteCountEvent) BytesOut() int {
 	if e.hasClient {
 		index = 2
 	}
-	str := string(e.parts()[index])
-	val, _ := strconv.Atoi(str)
 	// Ignore error, since this should never happen if OpenVPN is
 	// behaving itself.
-	return val
+	val, _ := parseDecimal(e.field(index))
+	return int(val)
 }
 
 func (e *ByteCountEvent) String() string {
+	var buf [64]byte
+	b := buf[:0]
 	if e.hasClient {
-		return fmt.Sprintf("Client %s: %d in, %d out", e.ClientId(), e.BytesIn(), e.BytesOut())
-	} else {
-		return fmt.Sprintf("%d in, %d out", e.BytesIn(), e.BytesOut())
+		b = append(b, "Client "...)
+		b = append(b, e.field(0)...)
+		b = append(b, ": "...)
 	}
+	b = strconv.AppendInt(b, int64(e.BytesIn()), 10)
+	b = append(b, " in, "...)
+	b = strconv.AppendInt(b, int64(e.BytesOut()), 10)
+	b = append(b, " out"...)
+	return string(b)
 }
 
-func (e *ByteCountEvent) parts() [][]byte {
-	if e.bodyParts == nil {
-		e.bodyParts = bytes.SplitN(e.body, fieldSep, 4)
-
-		wantCount := 2
-		if e.hasClient {
-			wantCount = 3
-		}
-
-		// Prevent crash if the server has sent us a malformed
-		// message. This should never actually happen if the
-		// server is behaving itself.
-		if len(e.bodyParts) < wantCount {
-			expanded := make([][]byte, wantCount)
-			copy(expanded, e.bodyParts)
-			e.bodyParts = expanded
-		}
-	}
-	return e.bodyParts
+func (e *ByteCountEvent) field(i int) []byte {
+	return e.fields.field(e.body, 4, i)
 }
 
 // PasswordEvent represents a message from the OpenVPN process asking for
@@ -281,8 +292,41 @@ type PasswordEvent struct {
 	body []byte
 }
 
+// Realm returns the kind of credentials the event concerns, such as
+// "Auth", "Private Key" or "HTTP Proxy", or the empty string if the event
+// doesn't name one.
+func (e *PasswordEvent) Realm() string {
+	start := bytes.IndexByte(e.body, '\'')
+	if start == -1 {
+		return ""
+	}
+	end := bytes.IndexByte(e.body[start+1:], '\'')
+	if end == -1 {
+		return ""
+	}
+	return string(e.body[start+1 : start+1+end])
+}
+
+// NeedsCredentials returns true if the event is a request for credentials,
+// rather than for example a report that credentials were rejected.
+func (e *PasswordEvent) NeedsCredentials() bool {
+	return bytes.HasPrefix(e.body, passwordNeedPrefix)
+}
+
+// NeedsUsername returns true if the event is a request for both a username
+// and password, rather than for a password alone.
+func (e *PasswordEvent) NeedsUsername() bool {
+	return e.NeedsCredentials() && bytes.Contains(e.body, passwordUsernameMarker)
+}
+
+// VerificationFailed returns true if the event reports that the credentials
+// most recently given for the event's realm were rejected.
+func (e *PasswordEvent) VerificationFailed() bool {
+	return bytes.HasPrefix(e.body, passwordFailedPrefix)
+}
+
 func (e *PasswordEvent) String() string {
-	return fmt.Sprintf("PASSWORD: %s", string(e.body))
+	return "PASSWORD: " + string(e.body)
 }
 
 // FatalEvent represents a message from the OpenVPN process before exiting.
@@ -291,11 +335,385 @@ type FatalEvent struct {
 }
 
 func (e *FatalEvent) String() string {
-	return fmt.Sprintf("FATAL: %s", string(e.body))
+	return "FATAL: " + string(e.body)
+}
+
+// ParseEvent parses a single asynchronous message from the OpenVPN
+// management interface into an event, as the client does for each event
+// it receives. The message must not include the leading '>' that marks it
+// as asynchronous, nor the trailing newline.
+//
+// ParseEvent never fails: messages that cannot be parsed produce
+// a MalformedEvent. The returned event may retain a reference to raw.
+func ParseEvent(raw []byte) Event {
+	return upgradeEvent(raw)
+}
+
+// EventScratch holds an event of each of the most frequent types, for
+// reuse by ParseEventInto.
+type EventScratch struct {
+	ByteCount ByteCountEvent
+	State     StateEvent
+	Log       LogEvent
+	Echo      EchoEvent
+}
+
+// ParseEventInto is like ParseEvent, but for ByteCountEvents, StateEvents,
+// LogEvents and EchoEvents it resets and refills the event of that type in
+// s and returns a pointer to it, rather than allocating a new event, much
+// as encoding/json decodes into a value supplied by the caller. This suits
+// consumers of high volumes of events that copy out what they need from
+// each one before parsing the next. Other types of event are allocated as
+// for ParseEvent.
+//
+// An event from s is only valid until s is next passed to ParseEventInto,
+// so it must not be retained, such as by passing it to an EventBus. It is
+// never recycled by ReleaseEvent.
+func ParseEventInto(raw []byte, s *EventScratch) Event {
+	return parseEvent(raw, s)
+}
+
+// LogEvent is a message from the OpenVPN log, emitted in real time once
+// enabled using client.SetLogEvents(true).
+type LogEvent struct {
+	body []byte
+
+	// populated on first call to field()
+	fields fieldOffsets
+}
+
+func (e *LogEvent) RawTimestamp() string {
+	return string(e.field(0))
+}
+
+// Flags returns the flags OpenVPN attached to the message, each of which
+// is one of the following characters:
+//
+//	I: informational
+//	F: fatal error
+//	N: non-fatal error
+//	W: warning
+//	D: debug
+func (e *LogEvent) Flags() string {
+	return string(e.field(1))
+}
+
+// LevelFatal is the slog level of LogEvents that OpenVPN flagged as fatal
+// errors, which slog doesn't otherwise distinguish from other errors.
+const LevelFatal = slog.LevelError + 4
+
+// Level returns the slog level corresponding to the most severe of the
+// event's flags: LevelFatal for F, slog.LevelError for N, slog.LevelWarn
+// for W, slog.LevelDebug for D and otherwise slog.LevelInfo.
+func (e *LogEvent) Level() slog.Level {
+	flags := e.Flags()
+	switch {
+	case strings.Contains(flags, "F"):
+		return LevelFatal
+	case strings.Contains(flags, "N"):
+		return slog.LevelError
+	case strings.Contains(flags, "W"):
+		return slog.LevelWarn
+	case strings.Contains(flags, "D"):
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+func (e *LogEvent) Message() string {
+	return string(e.field(2))
+}
+
+func (e *LogEvent) String() string {
+	return "LOG: " + string(e.field(2))
+}
+
+func (e *LogEvent) field(i int) []byte {
+	return e.fields.field(e.body, 3, i)
+}
+
+// DCOFallbackEvent reports that OpenVPN has decided not to use data
+// channel offload, even though it was built with support for it, and
+// gives the reason it logged for that decision.
+//
+// OpenVPN doesn't report this as a distinct message, so the client
+// recognizes it in the log and emits this event immediately after the
+// LogEvent it was derived from. It is therefore only emitted when log
+// events are enabled.
+type DCOFallbackEvent struct {
+	log    *LogEvent
+	reason string
+}
+
+// DCOFallbackFromLog returns a DCOFallbackEvent describing the given log
+// message if it reports a DCO fallback, or nil if it does not. This can be
+// used to inspect log history retrieved using client.LogHistory.
+func DCOFallbackFromLog(e *LogEvent) *DCOFallbackEvent {
+	msg := e.Message()
+	idx := strings.Index(msg, dcoFallbackMsg)
+	if idx == -1 {
+		return nil
+	}
+
+	reason := strings.TrimRight(msg[:idx], " ,")
+	reason = strings.TrimPrefix(reason, "Note: ")
+	return &DCOFallbackEvent{log: e, reason: reason}
+}
+
+// Reason returns the reason OpenVPN gave for not using DCO, such as
+// "Kernel support for ovpn-dco missing".
+func (e *DCOFallbackEvent) Reason() string {
+	return e.reason
+}
+
+// Log returns the log message the event was derived from.
+func (e *DCOFallbackEvent) Log() *LogEvent {
+	return e.log
+}
+
+func (e *DCOFallbackEvent) String() string {
+	return "DCO disabled: " + e.reason
+}
+
+// UpDownEvent is emitted by an OpenVPN process running with the
+// --management-up-down option each time the tunnel comes up or goes down,
+// at the points where it would otherwise run its up and down scripts.
+//
+// The event carries the same environment that OpenVPN would pass to such
+// a script. The environment is sent as a series of separate messages
+// following the event itself, which the client collects before emitting
+// the event, so Env is populated for all events received from a client.
+type UpDownEvent struct {
+	body []byte
+	env  lazyEnv
+}
+
+// Direction returns either "UP" or "DOWN".
+func (e *UpDownEvent) Direction() string {
+	return string(e.body)
+}
+
+// Env returns the script environment for the transition.
+func (e *UpDownEvent) Env() Env {
+	return e.env.get()
+}
+
+func (e *UpDownEvent) String() string {
+	return "UPDOWN: " + string(e.body)
+}
+
+func (e *UpDownEvent) hasEnv() bool {
+	return true
+}
+
+func (e *UpDownEvent) setEnv(env Env) {
+	e.env.set(env)
+}
+
+func (e *UpDownEvent) setEnvLines(lines [][]byte) {
+	e.env.lines = lines
+}
+
+// RemoteEvent is emitted by an OpenVPN client running with the
+// --management-query-remote option each time it is about to connect to
+// a server from its remote list. OpenVPN waits until the management client
+// responds using MgmtClient.RemoteAccept, RemoteSkip or RemoteModify.
+type RemoteEvent struct {
+	body []byte
+
+	// populated on first call to field()
+	fields fieldOffsets
+}
+
+// Host returns the host name or address of the remote being offered.
+func (e *RemoteEvent) Host() string {
+	return string(e.field(0))
+}
+
+// Port returns the port of the remote being offered.
+func (e *RemoteEvent) Port() int {
+	port, _ := parseDecimal(e.field(1))
+	return int(port)
+}
+
+// Proto returns the transport protocol of the remote being offered, such
+// as "udp" or "tcp-client".
+func (e *RemoteEvent) Proto() string {
+	return string(e.field(2))
+}
+
+func (e *RemoteEvent) String() string {
+	return "REMOTE: " + string(e.body)
+}
+
+func (e *RemoteEvent) field(i int) []byte {
+	return e.fields.field(e.body, 3, i)
+}
+
+// ClientEvent is emitted by an OpenVPN server running with the
+// --management-client-auth option to report the progress of each client
+// connection.
+//
+// Every type of event except ADDRESS carries the client's environment,
+// which is sent as a series of separate messages following the event
+// itself and which the client collects before emitting the event.
+type ClientEvent struct {
+	body []byte
+	env  lazyEnv
+
+	// populated on first call to field()
+	fields fieldOffsets
+}
+
+// Type returns the type of client event: "CONNECT", "REAUTH",
+// "ESTABLISHED", "DISCONNECT", "ADDRESS" or "CR_RESPONSE".
+func (e *ClientEvent) Type() string {
+	return string(e.field(0))
+}
+
+// ClientID returns the id OpenVPN assigned to the client connection, or -1
+// if the event is malformed.
+func (e *ClientEvent) ClientID() int64 {
+	cid, ok := parseDecimal(e.field(1))
+	if !ok {
+		return -1
+	}
+	return cid
+}
+
+// KeyID returns the id of the TLS session being authenticated, for CONNECT,
+// REAUTH and CR_RESPONSE events, or -1 for other events.
+func (e *ClientEvent) KeyID() int {
+	switch e.Type() {
+	case "CONNECT", "REAUTH", "CR_RESPONSE":
+	default:
+		return -1
+	}
+	kid, ok := parseDecimal(e.field(2))
+	if !ok {
+		return -1
+	}
+	return int(kid)
+}
+
+// Address returns the address learned for the client, for ADDRESS events,
+// and whether it is the client's primary address.
+func (e *ClientEvent) Address() (addr string, primary bool) {
+	if e.Type() != "ADDRESS" {
+		return "", false
+	}
+	return string(e.field(2)), string(e.field(3)) == "1"
+}
+
+// Response returns the base64-encoded challenge response, for CR_RESPONSE
+// events.
+func (e *ClientEvent) Response() string {
+	if e.Type() != "CR_RESPONSE" {
+		return ""
+	}
+	return string(e.field(3))
+}
+
+// Env returns the client's environment. It is nil for ADDRESS events.
+//
+// The environment is parsed from the messages it was sent in only when Env
+// is first called.
+func (e *ClientEvent) Env() Env {
+	return e.env.get()
+}
+
+func (e *ClientEvent) String() string {
+	return "CLIENT: " + string(e.body)
+}
+
+func (e *ClientEvent) hasEnv() bool {
+	return e.Type() != "ADDRESS"
+}
+
+func (e *ClientEvent) setEnv(env Env) {
+	e.env.set(env)
+}
+
+func (e *ClientEvent) setEnvLines(lines [][]byte) {
+	e.env.lines = lines
+}
+
+func (e *ClientEvent) field(i int) []byte {
+	return e.fields.field(e.body, 4, i)
+}
+
+// InfoMsgEvent carries information that an OpenVPN client has received
+// from its server for presentation to the user, such as a web login URL
+// ("WEB_AUTH::<url>") or a challenge to answer ("CR_TEXT:<flags>:<text>").
+type InfoMsgEvent struct {
+	body []byte
+}
+
+// Message returns the information sent by the server.
+func (e *InfoMsgEvent) Message() string {
+	return string(e.body)
+}
+
+func (e *InfoMsgEvent) String() string {
+	return "INFOMSG: " + string(e.body)
+}
+
+// EnvEvent is a single variable from an environment block following an
+// event such as UpDownEvent or ClientEvent.
+//
+// The client merges environment blocks into the event they belong to, so
+// events of this type are seen only by callers using ParseEvent directly.
+type EnvEvent struct {
+	keyword []byte
+	body    []byte
+}
+
+// Type returns the keyword of the event the variable belongs to, such as
+// "UPDOWN" or "CLIENT".
+func (e *EnvEvent) Type() string {
+	return string(e.keyword)
+}
+
+// IsEnd returns true if this marks the end of the environment block,
+// rather than carrying a variable.
+func (e *EnvEvent) IsEnd() bool {
+	return bytes.Equal(e.body, envEnd)
+}
+
+// Name returns the name of the environment variable.
+func (e *EnvEvent) Name() string {
+	name, _ := e.split()
+	return name
+}
+
+// Value returns the value of the environment variable.
+func (e *EnvEvent) Value() string {
+	_, value := e.split()
+	return value
+}
+
+func (e *EnvEvent) String() string {
+	return string(e.keyword) + ": ENV " + string(e.body)
+}
+
+func (e *EnvEvent) split() (name, value string) {
+	if e.IsEnd() {
+		return "", ""
+	}
+	eqIdx := bytes.IndexByte(e.body, '=')
+	if eqIdx == -1 {
+		return string(e.body), ""
+	}
+	return string(e.body[:eqIdx]), string(e.body[eqIdx+1:])
 }
 
 func upgradeEvent(raw []byte) Event {
-	splitIdx := bytes.Index(raw, eventSep)
+	return parseEvent(raw, nil)
+}
+
+// parseEvent parses a message, reusing the events in s if it isn't nil.
+func parseEvent(raw []byte, s *EventScratch) Event {
+	splitIdx := bytes.IndexByte(raw, eventSep)
 	if splitIdx == -1 {
 		// Should never happen, but we'll handle it robustly if it does.
 		return &MalformedEvent{raw}
@@ -304,22 +722,209 @@ func upgradeEvent(raw []byte) Event {
 	keyword := raw[:splitIdx]
 	body := raw[splitIdx+1:]
 
-	switch {
-	case bytes.Equal(keyword, stateEventKW):
+	kind := lookupKeyword(keyword)
+	if (kind == kindUpDown || kind == kindClient) && bytes.HasPrefix(body, envPrefix) {
+		return &EnvEvent{keyword: keyword, body: body[len(envPrefix):]}
+	}
+
+	// None of the cases copy the message: the events slice their fields
+	// from it as they are needed.
+	switch kind {
+	case kindByteCount, kindByteCountCli:
+		hasClient := kind == kindByteCountCli
+		if s != nil {
+			s.ByteCount = ByteCountEvent{hasClient: hasClient, body: body, scratch: true}
+			return &s.ByteCount
+		}
+		return newByteCountEvent(hasClient, body)
+	case kindState:
+		if s != nil {
+			s.State = StateEvent{body: body}
+			return &s.State
+		}
 		return &StateEvent{body: body}
-	case bytes.Equal(keyword, holdEventKW):
-		return &HoldEvent{body}
-	case bytes.Equal(keyword, echoEventKW):
+	case kindLog:
+		if s != nil {
+			s.Log = LogEvent{body: body}
+			return &s.Log
+		}
+		return &LogEvent{body: body}
+	case kindEcho:
+		if s != nil {
+			s.Echo = EchoEvent{body}
+			return &s.Echo
+		}
 		return &EchoEvent{body}
-	case bytes.Equal(keyword, byteCountEventKW):
-		return &ByteCountEvent{hasClient: false, body: body}
-	case bytes.Equal(keyword, byteCountCliEventKW):
-		return &ByteCountEvent{hasClient: true, body: body}
-	case bytes.Equal(keyword, passwordEventKW):
+	case kindClient:
+		return &ClientEvent{body: body}
+	case kindHold:
+		return &HoldEvent{body}
+	case kindPassword:
 		return &PasswordEvent{body: body}
-	case bytes.Equal(keyword, fatalEventKW):
+	case kindFatal:
 		return &FatalEvent{body: body}
+	case kindUpDown:
+		return &UpDownEvent{body: body}
+	case kindRemote:
+		return &RemoteEvent{body: body}
+	case kindInfoMsg:
+		return &InfoMsgEvent{body: body}
 	default:
 		return &UnknownEvent{keyword, body}
 	}
 }
+
+// eventKind identifies the keyword of an event message, as recognized by
+// lookupKeyword.
+type eventKind uint8
+
+const (
+	kindUnknown eventKind = iota
+	kindByteCount
+	kindByteCountCli
+	kindClient
+	kindEcho
+	kindFatal
+	kindHold
+	kindInfoMsg
+	kindLog
+	kindPassword
+	kindRemote
+	kindState
+	kindUpDown
+)
+
+// lookupKeyword returns the kind of event with the given keyword. It
+// switches on the first byte, which distinguishes all but the BYTECOUNT
+// keywords, so that each message is compared with at most two keywords;
+// a status flood of hundreds of thousands of lines would otherwise spend
+// much of its time comparing each with all of them in turn.
+func lookupKeyword(keyword []byte) eventKind {
+	if len(keyword) == 0 {
+		return kindUnknown
+	}
+	var kind eventKind
+	var want []byte
+	switch keyword[0] {
+	case 'B':
+		if len(keyword) == len(byteCountCliEventKW) {
+			kind, want = kindByteCountCli, byteCountCliEventKW
+		} else {
+			kind, want = kindByteCount, byteCountEventKW
+		}
+	case 'C':
+		kind, want = kindClient, clientEventKW
+	case 'E':
+		kind, want = kindEcho, echoEventKW
+	case 'F':
+		kind, want = kindFatal, fatalEventKW
+	case 'H':
+		kind, want = kindHold, holdEventKW
+	case 'I':
+		kind, want = kindInfoMsg, infoMsgEventKW
+	case 'L':
+		kind, want = kindLog, logEventKW
+	case 'P':
+		kind, want = kindPassword, passwordEventKW
+	case 'R':
+		kind, want = kindRemote, remoteEventKW
+	case 'S':
+		kind, want = kindState, stateEventKW
+	case 'U':
+		kind, want = kindUpDown, upDownEventKW
+	default:
+		return kindUnknown
+	}
+	if !bytes.Equal(keyword, want) {
+		return kindUnknown
+	}
+	return kind
+}
+
+// maxEventFields is the most comma-separated fields any event body is
+// split into, as for StateEvent.
+const maxEventFields = 9
+
+// fieldOffsets locates the comma-separated fields of an event body, so
+// that accessors can slice the fields from the body on demand without
+// allocating. Event types with many instances, such as the BYTECOUNT_CLI
+// events of a busy server, would otherwise spend much of their time in
+// the garbage collector.
+//
+// The body is split at most once, guarded by once, since an event may be
+// shared by several goroutines, such as the subscribers of an EventBus.
+type fieldOffsets struct {
+	once sync.Once
+
+	// n is the number of fields and ends the offset of the end of each
+	// field in the body, once it has been split.
+	n    int8
+	ends [maxEventFields]int32
+}
+
+// field returns field i of body when split into at most max fields, as
+// for bytes.SplitN, or nil if there are too few fields. The body must be
+// the same on every call.
+func (f *fieldOffsets) field(body []byte, max, i int) []byte {
+	f.once.Do(func() { f.split(body, max) })
+	if i >= int(f.n) {
+		// Prevent crash if the server has sent us a malformed
+		// message. This should never actually happen if the
+		// server is behaving itself.
+		return nil
+	}
+	start := 0
+	if i > 0 {
+		start = int(f.ends[i-1]) + 1
+	}
+	return body[start:f.ends[i]]
+}
+
+func (f *fieldOffsets) split(body []byte, max int) {
+	start := 0
+	for int(f.n) < max-1 {
+		idx := bytes.IndexByte(body[start:], fieldSep)
+		if idx == -1 {
+			break
+		}
+		f.ends[f.n] = int32(start + idx)
+		f.n++
+		start += idx + 1
+	}
+	f.ends[f.n] = int32(len(body))
+	f.n++
+}
+
+// parseDecimal parses a signed decimal integer, as for strconv.ParseInt
+// but without first converting the bytes to a string, returning false if
+// b isn't one or is out of range.
+func parseDecimal(b []byte) (int64, bool) {
+	neg := false
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		neg = b[0] == '-'
+		b = b[1:]
+	}
+	if len(b) == 0 {
+		return 0, false
+	}
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		if n > (1<<63)/10 {
+			return 0, false
+		}
+		n = n*10 + uint64(c-'0')
+		if n > 1<<63 {
+			return 0, false
+		}
+	}
+	if neg {
+		return -int64(n), true
+	}
+	if n == 1<<63 {
+		return 0, false
+	}
+	return int64(n), true
+}
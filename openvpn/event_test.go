@@ -2,6 +2,7 @@ package openvpn
 
 import (
 	"fmt"
+	"log/slog"
 	"testing"
 )
 
@@ -350,21 +351,158 @@ func TestByteCountEvent(t *testing.T) {
 	}
 }
 
+func TestByteCountEventAllocs(t *testing.T) {
+	raw := []byte("BYTECOUNT_CLI:12,3456789,987654")
+	var cid int64
+	var in, out int
+	allocs := testing.AllocsPerRun(100, func() {
+		bc := upgradeEvent(raw).(*ByteCountEvent)
+		cid, _ = bc.clientID()
+		in, out = bc.BytesIn(), bc.BytesOut()
+	})
+	if cid != 12 || in != 3456789 || out != 987654 {
+		t.Errorf("got %d, %d, %d; want 12, 3456789, 987654", cid, in, out)
+	}
+	// Only the event itself should be allocated.
+	if allocs > 1 {
+		t.Errorf("parsing BYTECOUNT_CLI made %v allocations; want 1", allocs)
+	}
+}
+
+func TestUpgradeEventAllocs(t *testing.T) {
+	tests := []struct {
+		input  string
+		access func(Event)
+	}{
+		{"STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1", func(e Event) {
+			e.(*StateEvent).field(4)
+		}},
+		{"BYTECOUNT:1,2", func(e Event) {
+			e.(*ByteCountEvent).BytesOut()
+		}},
+		{"ECHO:1,forget-passwords", func(e Event) {
+			e.(*EchoEvent).message()
+		}},
+	}
+
+	for i, test := range tests {
+		raw := []byte(test.input)
+		allocs := testing.AllocsPerRun(10, func() {
+			test.access(upgradeEvent(raw))
+		})
+		// Only the event itself should be allocated.
+		if allocs > 1 {
+			t.Errorf("test %d made %v allocations; want 1", i, allocs)
+		}
+	}
+}
+
+func BenchmarkByteCountEvent(b *testing.B) {
+	raw := []byte("BYTECOUNT_CLI:12,3456789,987654")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bc := upgradeEvent(raw).(*ByteCountEvent)
+		bc.clientID()
+		bc.BytesIn()
+		bc.BytesOut()
+	}
+}
+
+func TestEventStringAllocs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1", "CONNECTED: 192.0.2.1"},
+		{"STATE:1,ASSIGN_IP,,10.8.0.2,,,,,fd00::2", "ASSIGN_IP: fd00::2"},
+		{"STATE:1,RECONNECTING,ping-restart,,", "RECONNECTING: ping-restart"},
+		{"STATE:1,WAIT,,,", "WAIT"},
+		{"BYTECOUNT:1,2", "1 in, 2 out"},
+		{"BYTECOUNT_CLI:3,4,5", "Client 3: 4 in, 5 out"},
+		{"LOG:1,I,hello", "LOG: hello"},
+		{"ECHO:1,forget-passwords", "ECHO: forget-passwords"},
+		{"CLIENT:ADDRESS,1,10.8.0.2,1", "CLIENT: ADDRESS,1,10.8.0.2,1"},
+	}
+
+	for i, test := range tests {
+		event := upgradeEvent([]byte(test.input))
+		var got string
+		allocs := testing.AllocsPerRun(10, func() {
+			got = event.String()
+		})
+		if got != test.want {
+			t.Errorf("test %d String returned %q; want %q", i, got, test.want)
+		}
+		if allocs > 1 {
+			t.Errorf("test %d String made %v allocations; want at most 1", i, allocs)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   int64
+		wantOk bool
+	}{
+		{"0", 0, true},
+		{"123", 123, true},
+		{"-42", -42, true},
+		{"+7", 7, true},
+		{"9223372036854775807", 9223372036854775807, true},
+		{"-9223372036854775808", -9223372036854775808, true},
+		{"9223372036854775808", 0, false},
+		{"99999999999999999999", 0, false},
+		{"", 0, false},
+		{"-", 0, false},
+		{"12a", 0, false},
+		{" 1", 0, false},
+	}
+
+	for i, test := range tests {
+		got, ok := parseDecimal([]byte(test.input))
+		if got != test.want || ok != test.wantOk {
+			t.Errorf("test %d got %d, %v; want %d, %v", i, got, ok, test.want, test.wantOk)
+		}
+	}
+}
+
 func TestPasswordEvent(t *testing.T) {
-	tests := [][]byte{
-		[]byte("PASSWORD:"),
-		[]byte("PASSWORD:Need 'Private Key' password"),
-		[]byte("PASSWORD:Need 'Auth' username/password"),
-		[]byte("PASSWORD:Verification Failed: 'Private Key'"),
-		[]byte("PASSWORD:Verification Failed: 'Auth'"),
-		[]byte("PASSWORD:Verification Failed: 'custom string'"),
+	tests := []struct {
+		input        []byte
+		wantRealm    string
+		wantNeeds    bool
+		wantUsername bool
+		wantFailed   bool
+	}{
+		{[]byte("PASSWORD:"), "", false, false, false},
+		{[]byte("PASSWORD:Need 'Private Key' password"), "Private Key", true, false, false},
+		{[]byte("PASSWORD:Need 'Auth' username/password"), "Auth", true, true, false},
+		{[]byte("PASSWORD:Verification Failed: 'Private Key'"), "Private Key", false, false, true},
+		{[]byte("PASSWORD:Verification Failed: 'Auth'"), "Auth", false, false, true},
+		{[]byte("PASSWORD:Verification Failed: 'custom string'"), "custom string", false, false, true},
+		{[]byte("PASSWORD:Need 'unterminated"), "", true, false, false},
 	}
 
 	for i, test := range tests {
-		event := upgradeEvent(test)
+		event := upgradeEvent(test.input)
 
-		if passwd, ok := event.(*PasswordEvent); !ok {
+		passwd, ok := event.(*PasswordEvent)
+		if !ok {
 			t.Errorf("test %d got %T; want %T", i, event, passwd)
+			continue
+		}
+		if got := passwd.Realm(); got != test.wantRealm {
+			t.Errorf("test %d Realm returned %q; want %q", i, got, test.wantRealm)
+		}
+		if got := passwd.NeedsCredentials(); got != test.wantNeeds {
+			t.Errorf("test %d NeedsCredentials returned %v; want %v", i, got, test.wantNeeds)
+		}
+		if got := passwd.NeedsUsername(); got != test.wantUsername {
+			t.Errorf("test %d NeedsUsername returned %v; want %v", i, got, test.wantUsername)
+		}
+		if got := passwd.VerificationFailed(); got != test.wantFailed {
+			t.Errorf("test %d VerificationFailed returned %v; want %v", i, got, test.wantFailed)
 		}
 	}
 }
@@ -382,3 +520,386 @@ func TestFatalEvent(t *testing.T) {
 		}
 	}
 }
+
+func TestEnvEvent(t *testing.T) {
+	tests := []struct {
+		input     []byte
+		wantType  string
+		wantName  string
+		wantValue string
+		wantEnd   bool
+	}{
+		{
+			input:     []byte("UPDOWN:ENV,dev=tun0"),
+			wantType:  "UPDOWN",
+			wantName:  "dev",
+			wantValue: "tun0",
+		},
+		{
+			input:     []byte("UPDOWN:ENV,foreign_option_1=dhcp-option DNS 10.8.0.1"),
+			wantType:  "UPDOWN",
+			wantName:  "foreign_option_1",
+			wantValue: "dhcp-option DNS 10.8.0.1",
+		},
+		{
+			input:     []byte("UPDOWN:ENV,a=b=c"),
+			wantType:  "UPDOWN",
+			wantName:  "a",
+			wantValue: "b=c",
+		},
+		{
+			input:    []byte("UPDOWN:ENV,novalue"),
+			wantType: "UPDOWN",
+			wantName: "novalue",
+		},
+		{
+			input:    []byte("UPDOWN:ENV,END"),
+			wantType: "UPDOWN",
+			wantEnd:  true,
+		},
+	}
+
+	for i, test := range tests {
+		event := upgradeEvent(test.input)
+
+		env, ok := event.(*EnvEvent)
+		if !ok {
+			t.Errorf("test %d got %T; want %T", i, event, env)
+			continue
+		}
+
+		if got, want := env.Type(), test.wantType; got != want {
+			t.Errorf("test %d Type returned %q; want %q", i, got, want)
+		}
+
+		if got, want := env.IsEnd(), test.wantEnd; got != want {
+			t.Errorf("test %d IsEnd returned %t; want %t", i, got, want)
+		}
+
+		if got, want := env.Name(), test.wantName; got != want {
+			t.Errorf("test %d Name returned %q; want %q", i, got, want)
+		}
+
+		if got, want := env.Value(), test.wantValue; got != want {
+			t.Errorf("test %d Value returned %q; want %q", i, got, want)
+		}
+	}
+}
+
+func TestUpDownEvent(t *testing.T) {
+	tests := []struct {
+		input         []byte
+		wantDirection string
+	}{
+		{
+			input:         []byte("UPDOWN:UP"),
+			wantDirection: "UP",
+		},
+		{
+			input:         []byte("UPDOWN:DOWN"),
+			wantDirection: "DOWN",
+		},
+		{
+			input:         []byte("UPDOWN:"),
+			wantDirection: "",
+		},
+	}
+
+	for i, test := range tests {
+		event := upgradeEvent(test.input)
+
+		ud, ok := event.(*UpDownEvent)
+		if !ok {
+			t.Errorf("test %d got %T; want %T", i, event, ud)
+			continue
+		}
+
+		if got, want := ud.Direction(), test.wantDirection; got != want {
+			t.Errorf("test %d Direction returned %q; want %q", i, got, want)
+		}
+	}
+}
+
+func TestLogEvent(t *testing.T) {
+	tests := []struct {
+		input         []byte
+		wantTimestamp string
+		wantFlags     string
+		wantMessage   string
+		wantLevel     slog.Level
+		wantFallback  string
+	}{
+		{
+			input: []byte("LOG:"),
+		},
+		{
+			input:         []byte("LOG:1689000000,I,Initialization Sequence Completed"),
+			wantTimestamp: "1689000000",
+			wantFlags:     "I",
+			wantMessage:   "Initialization Sequence Completed",
+		},
+		{
+			input:         []byte("LOG:1689000000,W,WARNING: something, with commas"),
+			wantTimestamp: "1689000000",
+			wantFlags:     "W",
+			wantMessage:   "WARNING: something, with commas",
+			wantLevel:     slog.LevelWarn,
+		},
+		{
+			input:         []byte("LOG:1689000000,FD,Exiting due to fatal error"),
+			wantTimestamp: "1689000000",
+			wantFlags:     "FD",
+			wantMessage:   "Exiting due to fatal error",
+			wantLevel:     LevelFatal,
+		},
+		{
+			input:         []byte("LOG:1689000000,N,Options error: Unrecognized option"),
+			wantTimestamp: "1689000000",
+			wantFlags:     "N",
+			wantMessage:   "Options error: Unrecognized option",
+			wantLevel:     slog.LevelError,
+		},
+		{
+			input:         []byte("LOG:1689000000,D,MANAGEMENT: CMD 'state'"),
+			wantTimestamp: "1689000000",
+			wantFlags:     "D",
+			wantMessage:   "MANAGEMENT: CMD 'state'",
+			wantLevel:     slog.LevelDebug,
+		},
+		{
+			input:         []byte("LOG:1689000000,,Note: Kernel support for ovpn-dco missing, disabling data channel offload."),
+			wantTimestamp: "1689000000",
+			wantFlags:     "",
+			wantMessage:   "Note: Kernel support for ovpn-dco missing, disabling data channel offload.",
+			wantFallback:  "Kernel support for ovpn-dco missing",
+		},
+	}
+
+	for i, test := range tests {
+		event := upgradeEvent(test.input)
+
+		log, ok := event.(*LogEvent)
+		if !ok {
+			t.Errorf("test %d got %T; want %T", i, event, log)
+			continue
+		}
+
+		if got, want := log.RawTimestamp(), test.wantTimestamp; got != want {
+			t.Errorf("test %d RawTimestamp returned %q; want %q", i, got, want)
+		}
+
+		if got, want := log.Flags(), test.wantFlags; got != want {
+			t.Errorf("test %d Flags returned %q; want %q", i, got, want)
+		}
+
+		if got, want := log.Message(), test.wantMessage; got != want {
+			t.Errorf("test %d Message returned %q; want %q", i, got, want)
+		}
+
+		if got, want := log.Level(), test.wantLevel; got != want {
+			t.Errorf("test %d Level returned %s; want %s", i, got, want)
+		}
+
+		fallback := DCOFallbackFromLog(log)
+		switch {
+		case test.wantFallback == "" && fallback != nil:
+			t.Errorf("test %d produced DCO fallback %q", i, fallback.Reason())
+		case test.wantFallback != "" && fallback == nil:
+			t.Errorf("test %d produced no DCO fallback", i)
+		case fallback != nil && fallback.Reason() != test.wantFallback:
+			t.Errorf("test %d DCO fallback reason is %q; want %q", i, fallback.Reason(), test.wantFallback)
+		}
+	}
+}
+
+func TestRemoteEvent(t *testing.T) {
+	tests := []struct {
+		input     []byte
+		wantHost  string
+		wantPort  int
+		wantProto string
+	}{
+		{
+			input: []byte("REMOTE:"),
+		},
+		{
+			input:     []byte("REMOTE:vpn.example.com,1194,udp"),
+			wantHost:  "vpn.example.com",
+			wantPort:  1194,
+			wantProto: "udp",
+		},
+		{
+			input:     []byte("REMOTE:2001:db8::1,443,tcp-client"),
+			wantHost:  "2001:db8::1",
+			wantPort:  443,
+			wantProto: "tcp-client",
+		},
+	}
+
+	for i, test := range tests {
+		event := upgradeEvent(test.input)
+
+		remote, ok := event.(*RemoteEvent)
+		if !ok {
+			t.Errorf("test %d got %T; want %T", i, event, remote)
+			continue
+		}
+
+		if got, want := remote.Host(), test.wantHost; got != want {
+			t.Errorf("test %d Host returned %q; want %q", i, got, want)
+		}
+
+		if got, want := remote.Port(), test.wantPort; got != want {
+			t.Errorf("test %d Port returned %d; want %d", i, got, want)
+		}
+
+		if got, want := remote.Proto(), test.wantProto; got != want {
+			t.Errorf("test %d Proto returned %q; want %q", i, got, want)
+		}
+	}
+}
+
+func TestClientEvent(t *testing.T) {
+	tests := []struct {
+		input       []byte
+		wantType    string
+		wantCID     int64
+		wantKID     int
+		wantAddr    string
+		wantPrimary bool
+		wantHasEnv  bool
+	}{
+		{[]byte("CLIENT:CONNECT,5,1"), "CONNECT", 5, 1, "", false, true},
+		{[]byte("CLIENT:REAUTH,5,2"), "REAUTH", 5, 2, "", false, true},
+		{[]byte("CLIENT:ESTABLISHED,5"), "ESTABLISHED", 5, -1, "", false, true},
+		{[]byte("CLIENT:DISCONNECT,5"), "DISCONNECT", 5, -1, "", false, true},
+		{[]byte("CLIENT:ADDRESS,5,10.8.0.6,1"), "ADDRESS", 5, -1, "10.8.0.6", true, false},
+		{[]byte("CLIENT:"), "", -1, -1, "", false, true},
+	}
+
+	for i, test := range tests {
+		event := upgradeEvent(test.input)
+		ce, ok := event.(*ClientEvent)
+		if !ok {
+			t.Errorf("test %d got %T; want %T", i, event, ce)
+			continue
+		}
+		if got := ce.Type(); got != test.wantType {
+			t.Errorf("test %d Type returned %q; want %q", i, got, test.wantType)
+		}
+		if got := ce.ClientID(); got != test.wantCID {
+			t.Errorf("test %d ClientID returned %d; want %d", i, got, test.wantCID)
+		}
+		if got := ce.KeyID(); got != test.wantKID {
+			t.Errorf("test %d KeyID returned %d; want %d", i, got, test.wantKID)
+		}
+		addr, primary := ce.Address()
+		if addr != test.wantAddr || primary != test.wantPrimary {
+			t.Errorf("test %d Address returned %q, %v; want %q, %v", i, addr, primary, test.wantAddr, test.wantPrimary)
+		}
+		if got := ce.hasEnv(); got != test.wantHasEnv {
+			t.Errorf("test %d hasEnv returned %v; want %v", i, got, test.wantHasEnv)
+		}
+	}
+
+	if _, ok := upgradeEvent([]byte("CLIENT:ENV,common_name=alice")).(*EnvEvent); !ok {
+		t.Errorf("CLIENT:ENV was not parsed as an EnvEvent")
+	}
+}
+
+func TestParseEventInto(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"STATE:1,ASSIGN_IP,,10.8.0.2,,,,,fd00::2", "ASSIGN_IP: fd00::2"},
+		// Reusing the StateEvent must not keep the IPv6 address.
+		{"STATE:2,ASSIGN_IP,,10.8.0.3,", "ASSIGN_IP: 10.8.0.3"},
+		{"BYTECOUNT_CLI:3,4,5", "Client 3: 4 in, 5 out"},
+		{"BYTECOUNT:6,7", "6 in, 7 out"},
+		{"LOG:1,I,hello", "LOG: hello"},
+		{"ECHO:1,forget-passwords", "ECHO: forget-passwords"},
+		{"HOLD:Waiting for hold release", "Waiting for hold release"},
+	}
+
+	var s EventScratch
+	for i, test := range tests {
+		event := ParseEventInto([]byte(test.input), &s)
+		if got := event.String(); got != test.want {
+			t.Errorf("test %d String returned %q; want %q", i, got, test.want)
+		}
+	}
+
+	// The scratch events are reused, and never recycled.
+	a := ParseEventInto([]byte("BYTECOUNT:1,2"), &s)
+	ReleaseEvent(a)
+	b := ParseEventInto([]byte("BYTECOUNT:3,4"), &s)
+	if a != b || b != Event(&s.ByteCount) {
+		t.Errorf("ParseEventInto returned %p then %p; want %p", a, b, &s.ByteCount)
+	}
+	if c := upgradeEvent([]byte("BYTECOUNT:5,6")); c == b {
+		t.Errorf("released scratch event was recycled")
+	}
+
+	raw := []byte("BYTECOUNT_CLI:12,3456789,987654")
+	allocs := testing.AllocsPerRun(100, func() {
+		bc := ParseEventInto(raw, &s).(*ByteCountEvent)
+		bc.clientID()
+		bc.BytesIn()
+		bc.BytesOut()
+	})
+	if allocs != 0 {
+		t.Errorf("ParseEventInto made %v allocations; want 0", allocs)
+	}
+}
+
+func BenchmarkParseEvent(b *testing.B) {
+	lines := [][]byte{
+		[]byte("BYTECOUNT_CLI:12,3456789,987654"),
+		[]byte("BYTECOUNT:3456789,987654"),
+		[]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1"),
+		[]byte("LOG:1,I,hello"),
+		[]byte("CLIENT:ESTABLISHED,1,0"),
+		[]byte("INFOMSG:WEB_AUTH::https://example.com"),
+		[]byte("UPDOWN:UP,0"),
+		[]byte("NEED-OK:Need 'token-insertion-request' confirmation MSG:token"),
+	}
+	var s EventScratch
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseEvent(lines[i%len(lines)], &s)
+	}
+}
+
+func TestLookupKeyword(t *testing.T) {
+	tests := []struct {
+		keyword string
+		want    eventKind
+	}{
+		{"BYTECOUNT", kindByteCount},
+		{"BYTECOUNT_CLI", kindByteCountCli},
+		{"CLIENT", kindClient},
+		{"ECHO", kindEcho},
+		{"FATAL", kindFatal},
+		{"HOLD", kindHold},
+		{"INFOMSG", kindInfoMsg},
+		{"LOG", kindLog},
+		{"PASSWORD", kindPassword},
+		{"REMOTE", kindRemote},
+		{"STATE", kindState},
+		{"UPDOWN", kindUpDown},
+		{"", kindUnknown},
+		{"B", kindUnknown},
+		{"BYTECOUNT_CLX", kindUnknown},
+		{"INFO", kindUnknown},
+		{"NEED-OK", kindUnknown},
+		{"STATES", kindUnknown},
+		{"state", kindUnknown},
+	}
+
+	for i, test := range tests {
+		if got := lookupKeyword([]byte(test.keyword)); got != test.want {
+			t.Errorf("test %d got %v; want %v", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package openvpn
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// redactedEnvNames lists the environment variables whose values are
+// replaced when events are marshaled to JSON.
+var redactedEnvNames = []string{"password"}
+
+// MarshalEvent returns the JSON encoding of an event, as an object with
+// a "type" member naming the event's type, such as "StateEvent", along
+// with a member for each of the values its methods return. For example,
+// a StateEvent is encoded as:
+//
+//	{"type":"StateEvent","timestamp":"1714564800","state":"CONNECTED",
+//	 "description":"SUCCESS","local_tunnel_addr":"10.8.0.2","remote_addr":"192.0.2.1"}
+//
+// Events this package doesn't parse, such as those defined by other
+// packages, are encoded with the members produced by encoding/json for
+// their exported fields, and a "text" member holding their description if
+// they have none. Passwords in client environments and challenge responses
+// are redacted, and the body of a PasswordEvent carrying an auth token is
+// omitted.
+func MarshalEvent(e Event) ([]byte, error) {
+	fields, err := eventFields(e)
+	if err != nil {
+		return nil, err
+	}
+	fields["type"] = EventTypeName(e)
+	return json.Marshal(fields)
+}
+
+// RedactedString returns the description of an event given by its String
+// method, with the secrets that MarshalEvent omits redacted, for passing
+// events on to consumers that shouldn't see them.
+func RedactedString(e Event) string {
+	switch e := e.(type) {
+	case *PasswordEvent:
+		if _, ok := e.AuthToken(); ok {
+			return "PASSWORD: " + string(passwordAuthTokenPrefix) + "[redacted]"
+		}
+	case *EnvEvent:
+		if isRedactedEnvName(e.Name()) {
+			return string(e.keyword) + ": ENV " + e.Name() + "=[redacted]"
+		}
+	case *ClientEvent:
+		if e.Type() == "CR_RESPONSE" {
+			return "CLIENT: CR_RESPONSE," + string(e.field(1)) + "," + string(e.field(2)) + ",[redacted]"
+		}
+	}
+	return e.String()
+}
+
+// eventFields returns the members of the JSON encoding of an event, other
+// than its type.
+func eventFields(e Event) (map[string]interface{}, error) {
+	switch e := e.(type) {
+	case *StateEvent:
+		return map[string]interface{}{
+			"timestamp":         e.RawTimestamp(),
+			"state":             e.NewState(),
+			"description":       e.Description(),
+			"local_tunnel_addr": e.LocalTunnelAddr(),
+			"remote_addr":       e.RemoteAddr(),
+		}, nil
+	case *ByteCountEvent:
+		fields := map[string]interface{}{
+			"bytes_in":  e.BytesIn(),
+			"bytes_out": e.BytesOut(),
+		}
+		if id := e.ClientId(); id != "" {
+			fields["client_id"] = id
+		}
+		return fields, nil
+	case *LogEvent:
+		return map[string]interface{}{
+			"timestamp": e.RawTimestamp(),
+			"flags":     e.Flags(),
+			"message":   e.Message(),
+		}, nil
+	case *EchoEvent:
+		return map[string]interface{}{
+			"timestamp": e.RawTimestamp(),
+			"message":   e.Message(),
+		}, nil
+	case *PasswordEvent:
+		fields := map[string]interface{}{
+			"realm":               e.Realm(),
+			"needs_credentials":   e.NeedsCredentials(),
+			"needs_username":      e.NeedsUsername(),
+			"verification_failed": e.VerificationFailed(),
+		}
+		if _, ok := e.AuthToken(); !ok {
+			fields["message"] = string(e.body)
+		}
+		return fields, nil
+	case *HoldEvent:
+		return map[string]interface{}{"message": string(e.body)}, nil
+	case *FatalEvent:
+		return map[string]interface{}{"message": string(e.body)}, nil
+	case *InfoMsgEvent:
+		return map[string]interface{}{"message": e.Message()}, nil
+	case *DCOFallbackEvent:
+		return map[string]interface{}{"reason": e.Reason()}, nil
+	case *UpDownEvent:
+		return map[string]interface{}{
+			"direction": e.Direction(),
+			"env":       redactEnv(e.Env()),
+		}, nil
+	case *RemoteEvent:
+		return map[string]interface{}{
+			"host":  e.Host(),
+			"port":  e.Port(),
+			"proto": e.Proto(),
+		}, nil
+	case *ClientEvent:
+		fields := map[string]interface{}{
+			"client_event": e.Type(),
+			"client_id":    e.ClientID(),
+			"env":          redactEnv(e.Env()),
+		}
+		if kid := e.KeyID(); kid != -1 {
+			fields["key_id"] = kid
+		}
+		if addr, primary := e.Address(); addr != "" {
+			fields["address"] = addr
+			fields["primary"] = primary
+		}
+		if e.Response() != "" {
+			fields["response"] = "[redacted]"
+		}
+		return fields, nil
+	case *EnvEvent:
+		value := e.Value()
+		if isRedactedEnvName(e.Name()) {
+			value = "[redacted]"
+		}
+		return map[string]interface{}{
+			"event": e.Type(),
+			"name":  e.Name(),
+			"value": value,
+			"end":   e.IsEnd(),
+		}, nil
+	case *UnknownEvent:
+		return map[string]interface{}{
+			"keyword": e.Type(),
+			"body":    e.Body(),
+		}, nil
+	case *MalformedEvent:
+		return map[string]interface{}{"raw": string(e.raw)}, nil
+	case *DuplicateClientEvent:
+		dup := *e
+		dup.Existing.Env = redactEnv(dup.Existing.Env)
+		dup.New.Env = redactEnv(dup.New.Env)
+		return structFields(&dup, e)
+	}
+	return structFields(e, e)
+}
+
+// structFields returns the members encoding/json produces for v, or
+// a "text" member describing e if there are none.
+func structFields(v interface{}, e Event) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if buf, err := json.Marshal(v); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(buf, &fields); err != nil {
+		// v isn't encoded as an object.
+		fields = map[string]interface{}{}
+	}
+	if len(fields) == 0 {
+		fields["text"] = e.String()
+	}
+	return fields, nil
+}
+
+func redactEnv(env Env) Env {
+	if env == nil {
+		return nil
+	}
+	ret := make(Env, len(env))
+	for name, value := range env {
+		if isRedactedEnvName(name) {
+			value = "[redacted]"
+		}
+		ret[name] = value
+	}
+	return ret
+}
+
+func isRedactedEnvName(name string) bool {
+	for _, redacted := range redactedEnvNames {
+		if strings.EqualFold(name, redacted) {
+			return true
+		}
+	}
+	return false
+}
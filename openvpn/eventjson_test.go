@@ -0,0 +1,83 @@
+package openvpn
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalEvent(t *testing.T) {
+	clientConnect := &ClientEvent{body: []byte("CONNECT,3,1")}
+	clientConnect.setEnv(Env{"common_name": "alice", "password": "secret"})
+	crResponse := &ClientEvent{body: []byte("CR_RESPONSE,3,1,MTIzNDU2")}
+	crResponse.setEnv(Env{"common_name": "alice"})
+
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{
+			upgradeEvent([]byte("STATE:1714564800,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")),
+			`{"description":"SUCCESS","local_tunnel_addr":"10.8.0.2","remote_addr":"192.0.2.1","state":"CONNECTED","timestamp":"1714564800","type":"StateEvent"}`,
+		},
+		{
+			upgradeEvent([]byte("BYTECOUNT_CLI:3,100,200")),
+			`{"bytes_in":100,"bytes_out":200,"client_id":"3","type":"ByteCountEvent"}`,
+		},
+		{
+			upgradeEvent([]byte("PASSWORD:Auth-Token:abcdef")),
+			`{"needs_credentials":false,"needs_username":false,"realm":"","type":"PasswordEvent","verification_failed":false}`,
+		},
+		{
+			clientConnect,
+			`{"client_event":"CONNECT","client_id":3,"env":{"common_name":"alice","password":"[redacted]"},"key_id":1,"type":"ClientEvent"}`,
+		},
+		{
+			crResponse,
+			`{"client_event":"CR_RESPONSE","client_id":3,"env":{"common_name":"alice"},"key_id":1,"response":"[redacted]","type":"ClientEvent"}`,
+		},
+		{
+			upgradeEvent([]byte("FROB:knob")),
+			`{"body":"knob","keyword":"FROB","type":"UnknownEvent"}`,
+		},
+		{
+			&InactivityWarningEvent{Idle: time.Second, PingRestart: time.Minute},
+			`{"Idle":1000000000,"LastActivity":"0001-01-01T00:00:00Z","PingRestart":60000000000,"type":"InactivityWarningEvent"}`,
+		},
+	}
+	for i, test := range tests {
+		buf, err := MarshalEvent(test.event)
+		if err != nil {
+			t.Errorf("test %d failed: %s", i, err)
+			continue
+		}
+		var got, want interface{}
+		json.Unmarshal(buf, &got)
+		json.Unmarshal([]byte(test.want), &want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("test %d got %s; want %s", i, buf, test.want)
+		}
+	}
+}
+
+func TestRedactedString(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"PASSWORD:Auth-Token:abcdef", "PASSWORD: Auth-Token:[redacted]"},
+		{"PASSWORD:Need 'Auth' username/password", "PASSWORD: Need 'Auth' username/password"},
+		{"CLIENT:ENV,password=secret", "CLIENT: ENV password=[redacted]"},
+		{"CLIENT:ENV,common_name=alice", "CLIENT: ENV common_name=alice"},
+		{"CLIENT:CR_RESPONSE,3,1,MTIzNDU2", "CLIENT: CR_RESPONSE,3,1,[redacted]"},
+		{"CLIENT:CONNECT,3,1", "CLIENT: CONNECT,3,1"},
+		{"STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1", "CONNECTED: 192.0.2.1"},
+	}
+
+	for i, test := range tests {
+		if got := RedactedString(upgradeEvent([]byte(test.input))); got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
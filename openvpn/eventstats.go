@@ -0,0 +1,135 @@
+package openvpn
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventTypeStats describes the events of one type seen by an
+// EventCounter.
+type EventTypeStats struct {
+	// Type is the name of the event's type, such as "StateEvent".
+	Type string
+
+	// Count is the number of events seen, and Rate the rate at which they
+	// are arriving, in events per second, averaged over the counter's
+	// Window.
+	Count uint64
+	Rate  float64
+
+	// Last is when an event of the type was last seen.
+	Last time.Time
+}
+
+// EventCounter counts the events received from OpenVPN by type, and
+// measures the rate at which each type is arriving, so that operators can
+// compare the event volume with how quickly their consumers handle it.
+// Together with EventBus.Dropped, it shows when consumers are too slow and
+// events are being lost.
+//
+// The zero value averages rates over DefaultRateWindow. An EventCounter is
+// safe for concurrent use.
+type EventCounter struct {
+	// Window is the period over which rates are averaged. It must be set
+	// before the first event is handled.
+	Window time.Duration
+
+	mu     sync.Mutex
+	total  uint64
+	byType map[string]*eventTypeCount
+}
+
+type eventTypeCount struct {
+	EventTypeStats
+	// rate is the rate as of Last, which decays as time passes without
+	// events.
+	rate float64
+}
+
+// HandleEvent counts the given event as received now. Every event received
+// from the client's event channel should be passed, for the counts and
+// rates to be complete.
+func (c *EventCounter) HandleEvent(e Event) {
+	c.Count(e, time.Now())
+}
+
+// Count counts an event received at the given time.
+func (c *EventCounter) Count(e Event, now time.Time) {
+	name := EventTypeName(e)
+	window := c.window()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total++
+	if c.byType == nil {
+		c.byType = map[string]*eventTypeCount{}
+	}
+	tc := c.byType[name]
+	if tc == nil {
+		tc = &eventTypeCount{EventTypeStats: EventTypeStats{Type: name}}
+		c.byType[name] = tc
+	}
+	// Each event adds 1/window to an exponentially decaying rate, which
+	// averages to the arrival rate over the window.
+	tc.rate = decayRate(tc.rate, now.Sub(tc.Last), window) + 1/window.Seconds()
+	tc.Count++
+	tc.Last = now
+}
+
+// Total returns the number of events counted.
+func (c *EventCounter) Total() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// Stats returns the counts and rates of each type of event seen, as of the
+// given time, ordered by type.
+func (c *EventCounter) Stats(now time.Time) []EventTypeStats {
+	window := c.window()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ret := make([]EventTypeStats, 0, len(c.byType))
+	for _, tc := range c.byType {
+		stats := tc.EventTypeStats
+		stats.Rate = decayRate(tc.rate, now.Sub(tc.Last), window)
+		ret = append(ret, stats)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Type < ret[j].Type
+	})
+	return ret
+}
+
+func (c *EventCounter) window() time.Duration {
+	if c.Window <= 0 {
+		return DefaultRateWindow
+	}
+	return c.Window
+}
+
+// decayRate returns the rate left after elapsed has passed without events.
+func decayRate(rate float64, elapsed, window time.Duration) float64 {
+	if elapsed <= 0 {
+		return rate
+	}
+	return rate * math.Exp(-elapsed.Seconds()/window.Seconds())
+}
+
+// EventTypeName returns the name of an event's type, without the package
+// name or pointer, such as "StateEvent", as used by EventCounter and
+// MarshalEvent.
+func EventTypeName(e Event) string {
+	t := reflect.TypeOf(e)
+	if t == nil {
+		return "nil"
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
@@ -0,0 +1,50 @@
+package openvpn
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEventCounter(t *testing.T) {
+	c := &EventCounter{Window: time.Second}
+	start := time.Unix(1000, 0)
+	for i := 0; i < 10; i++ {
+		c.Count(upgradeEvent([]byte("BYTECOUNT:1,2")), start.Add(time.Duration(i)*100*time.Millisecond))
+	}
+	c.Count(upgradeEvent([]byte("STATE:1,CONNECTED,SUCCESS,,")), start)
+	c.Count(&DuplicateClientEvent{}, start)
+
+	if got := c.Total(); got != 12 {
+		t.Errorf("got total %d; want 12", got)
+	}
+	tests := []struct {
+		typ     string
+		count   uint64
+		minRate float64
+		maxRate float64
+	}{
+		// Ten events a second settle towards a rate of 10/s.
+		{"ByteCountEvent", 10, 5, 10},
+		{"DuplicateClientEvent", 1, 0.3, 0.5},
+		{"StateEvent", 1, 0.3, 0.5},
+	}
+	got := c.Stats(start.Add(time.Second))
+	if len(got) != len(tests) {
+		t.Fatalf("got %d types; want %d", len(got), len(tests))
+	}
+	for i, test := range tests {
+		s := got[i]
+		if s.Type != test.typ || s.Count != test.count {
+			t.Errorf("test %d got %s %d; want %s %d", i, s.Type, s.Count, test.typ, test.count)
+		}
+		if s.Rate < test.minRate || s.Rate > test.maxRate || math.IsNaN(s.Rate) {
+			t.Errorf("test %d got rate %f; want %f to %f", i, s.Rate, test.minRate, test.maxRate)
+		}
+	}
+
+	// Rates decay once events stop arriving.
+	if rate := c.Stats(start.Add(time.Minute))[0].Rate; rate > 0.001 {
+		t.Errorf("got rate %f a minute later; want about 0", rate)
+	}
+}
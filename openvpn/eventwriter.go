@@ -0,0 +1,136 @@
+package openvpn
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// EventWriter writes each event it handles as a line of JSON, in the form
+// returned by MarshalEvent with an added "time" member holding when it was
+// handled, in RFC 3339 format, so that events can be ingested by tools
+// such as jq, Vector or Fluent Bit.
+//
+// Rotation is left to the writer: a launcher.LogFile can be used to rotate
+// by size or age, or an EventWriter opened with OpenEventWriter can be
+// told to reopen its file using Reopen after an external tool such as
+// logrotate has moved it. SetOutput switches to a new writer altogether.
+type EventWriter struct {
+	// OnRotate, if set, is called with the path of the file after Reopen
+	// has reopened it, such as to log the rotation.
+	OnRotate func(path string)
+
+	mu   sync.Mutex
+	w    io.Writer
+	path string
+	err  error
+}
+
+// NewEventWriter returns an event writer writing to w.
+func NewEventWriter(w io.Writer) *EventWriter {
+	return &EventWriter{w: w}
+}
+
+// OpenEventWriter returns an event writer appending to the file at the
+// given path, creating it if necessary. The file is closed by closing the
+// writer.
+func OpenEventWriter(path string) (*EventWriter, error) {
+	f, err := openEventFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &EventWriter{w: f, path: path}, nil
+}
+
+func openEventFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+}
+
+// HandleEvent writes the given event as handled now. Errors are kept for
+// Err rather than returned, and later events are still written.
+func (w *EventWriter) HandleEvent(e Event) {
+	w.Write(e, time.Now())
+}
+
+// Write writes an event handled at the given time. It fails if the event
+// could not be encoded or written, in which case the error is also kept
+// for Err.
+func (w *EventWriter) Write(e Event, t time.Time) error {
+	fields, err := eventFields(e)
+	if err == nil {
+		fields["type"] = EventTypeName(e)
+		fields["time"] = t.Format(time.RFC3339Nano)
+		var buf bytes.Buffer
+		if err = json.NewEncoder(&buf).Encode(fields); err == nil {
+			w.mu.Lock()
+			_, err = w.w.Write(buf.Bytes())
+			w.mu.Unlock()
+		}
+	}
+	if err != nil {
+		w.mu.Lock()
+		w.err = err
+		w.mu.Unlock()
+	}
+	return err
+}
+
+// Reopen closes and reopens the file of an event writer returned by
+// OpenEventWriter, so that subsequent events are written to a new file
+// once the old one has been moved aside. It does nothing for other event
+// writers.
+func (w *EventWriter) Reopen() error {
+	w.mu.Lock()
+	if w.path == "" {
+		w.mu.Unlock()
+		return nil
+	}
+	f, err := openEventFile(w.path)
+	if err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	old := w.w
+	w.w = f
+	path, onRotate := w.path, w.OnRotate
+	w.mu.Unlock()
+
+	if c, ok := old.(io.Closer); ok {
+		c.Close()
+	}
+	if onRotate != nil {
+		onRotate(path)
+	}
+	return nil
+}
+
+// SetOutput switches the event writer to writing to out, returning the
+// previous writer, which is not closed.
+func (w *EventWriter) SetOutput(out io.Writer) io.Writer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	old := w.w
+	w.w, w.path = out, ""
+	return old
+}
+
+// Err returns the most recent error encountered while writing events, if
+// any. Writing continues after an error.
+func (w *EventWriter) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close closes the event writer's writer, if it is an io.Closer.
+func (w *EventWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package openvpn
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	w, err := OpenEventWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	var rotated []string
+	w.OnRotate = func(p string) { rotated = append(rotated, p) }
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := w.Write(upgradeEvent([]byte("HOLD:Waiting for hold release:0")), at); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write(upgradeEvent([]byte("STATE:1,CONNECTED,SUCCESS,,")), at.Add(time.Second))
+
+	tests := []struct {
+		path, typ, time string
+	}{
+		{path + ".1", "HoldEvent", "2024-05-01T12:00:00Z"},
+		{path, "StateEvent", "2024-05-01T12:00:01Z"},
+	}
+	for i, test := range tests {
+		f, err := os.Open(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		n := 0
+		for scanner.Scan() {
+			n++
+			var got map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+				t.Errorf("test %d got malformed line %q: %s", i, scanner.Text(), err)
+			}
+			if got["type"] != test.typ || got["time"] != test.time {
+				t.Errorf("test %d got %s at %s; want %s at %s", i, got["type"], got["time"], test.typ, test.time)
+			}
+		}
+		f.Close()
+		if n != 1 {
+			t.Errorf("test %d got %d lines; want 1", i, n)
+		}
+	}
+	if len(rotated) != 1 || rotated[0] != path {
+		t.Errorf("OnRotate called with %q; want %q", rotated, path)
+	}
+	if err := w.Err(); err != nil {
+		t.Error(err)
+	}
+}
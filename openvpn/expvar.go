@@ -0,0 +1,98 @@
+package openvpn
+
+import (
+	"bytes"
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// ExpvarName is the name under which PublishExpvar publishes the package's
+// counters.
+const ExpvarName = "gopenvpn"
+
+// counters holds the counters published by PublishExpvar, which are only
+// updated once enabled so that applications not using them pay nothing
+// but a check of enabled.
+var counters struct {
+	once    sync.Once
+	enabled atomic.Bool
+
+	root          expvar.Map
+	events        expvar.Map
+	dropped       expvar.Int
+	malformed     expvar.Int
+	commands      expvar.Int
+	commandErrors expvar.Int
+	reconnects    expvar.Int
+}
+
+// PublishExpvar publishes counters of the activity of all management
+// clients and sessions in the process using package expvar, so that they
+// appear at /debug/vars alongside a service's other variables. They are
+// published as a map named ExpvarName holding:
+//
+//	events          the number of events received, by type, such as "STATE"
+//	events_dropped  the number of events dropped by lossy EventBus subscriptions
+//	malformed_lines the number of events and replies that could not be parsed
+//	commands        the number of commands sent
+//	command_errors  the number of commands that failed
+//	reconnects      the number of times a Session saw its tunnel reconnect
+//
+// Nothing is counted until PublishExpvar is first called, and calling it
+// again has no further effect.
+func PublishExpvar() {
+	counters.once.Do(func() {
+		counters.root.Set("events", &counters.events)
+		counters.root.Set("events_dropped", &counters.dropped)
+		counters.root.Set("malformed_lines", &counters.malformed)
+		counters.root.Set("commands", &counters.commands)
+		counters.root.Set("command_errors", &counters.commandErrors)
+		counters.root.Set("reconnects", &counters.reconnects)
+		expvar.Publish(ExpvarName, &counters.root)
+		counters.enabled.Store(true)
+	})
+}
+
+// countEvent counts a raw event received from OpenVPN.
+func countEvent(raw []byte) {
+	if !counters.enabled.Load() {
+		return
+	}
+	idx := bytes.IndexByte(raw, eventSep)
+	if idx == -1 {
+		counters.malformed.Add(1)
+		return
+	}
+	counters.events.Add(string(raw[:idx]), 1)
+}
+
+// countCommand counts a command sent to OpenVPN, which failed if err is not
+// nil.
+func countCommand(err error) {
+	if !counters.enabled.Load() {
+		return
+	}
+	counters.commands.Add(1)
+	if err != nil {
+		counters.commandErrors.Add(1)
+	}
+}
+
+func countDropped() {
+	if counters.enabled.Load() {
+		counters.dropped.Add(1)
+	}
+}
+
+func countMalformed() {
+	if counters.enabled.Load() {
+		counters.malformed.Add(1)
+	}
+}
+
+func countReconnect() {
+	if counters.enabled.Load() {
+		counters.reconnects.Add(1)
+	}
+}
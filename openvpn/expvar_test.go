@@ -0,0 +1,69 @@
+package openvpn
+
+import (
+	"bufio"
+	"expvar"
+	"net"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	PublishExpvar()
+	PublishExpvar()
+	root := expvar.Get(ExpvarName).(*expvar.Map)
+	value := func(name string) int64 {
+		if v, ok := root.Get(name).(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+	eventCount := func(kind string) int64 {
+		if v, ok := root.Get("events").(*expvar.Map).Get(kind).(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+	commands, errs, malformed := value("commands"), value("command_errors"), value("malformed_lines")
+	states := eventCount("STATE")
+
+	server, conn := net.Pipe()
+	events := make(chan Event, 10)
+	client := NewClient(conn, events)
+	go func() {
+		scanner := bufio.NewScanner(server)
+		scanner.Scan()
+		server.Write([]byte("SUCCESS: pid=1\n"))
+		scanner.Scan()
+		server.Write([]byte("ERROR: unknown command\n"))
+		server.Write([]byte(">STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1\n"))
+		server.Write([]byte(">nonsense\n"))
+		server.Close()
+	}()
+	client.Pid()
+	client.HoldRelease()
+	for range events {
+	}
+
+	tests := []struct {
+		name      string
+		got, want int64
+	}{
+		{"commands", value("commands") - commands, 2},
+		{"command_errors", value("command_errors") - errs, 1},
+		{"malformed_lines", value("malformed_lines") - malformed, 1},
+		{"STATE events", eventCount("STATE") - states, 1},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s increased by %d; want %d", test.name, test.got, test.want)
+		}
+	}
+
+	var s Session
+	reconnects := value("reconnects")
+	s.HandleEvent(ParseEvent([]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+	s.HandleEvent(ParseEvent([]byte("STATE:2,RECONNECTING,ping-restart,,")))
+	if got := value("reconnects") - reconnects; got != 1 {
+		t.Errorf("reconnects increased by %d; want 1", got)
+	}
+}
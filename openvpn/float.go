@@ -0,0 +1,175 @@
+package openvpn
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// realAddressProtocols are the transport prefixes OpenVPN may attach to
+// a real address, such as "udp4:192.0.2.1:1194".
+var realAddressProtocols = []string{"udp4", "udp6", "udp", "tcp4-server", "tcp6-server", "tcp-server", "tcp4", "tcp6", "tcp"}
+
+// PeerFloatEvent reports that a client of a server has floated: its
+// packets now arrive from a different real address, such as when a mobile
+// client moves between networks, but are still authenticated as part of
+// the same session. Requires the server to be running with --float, which
+// is the default for UDP with peer ids.
+//
+// OpenVPN doesn't report this as a distinct message, so the client
+// recognizes it in the log and emits this event immediately after the
+// LogEvent it was derived from. It is therefore only emitted when log
+// events are enabled. A ClientRegistry also reports floats using OnFloat,
+// including those it detects by polling the server's status.
+type PeerFloatEvent struct {
+	// PeerID is the peer id of the client's session, or -1 if it is not
+	// known, and ClientID its client id, or -1 if it is not known. OpenVPN
+	// logs only the peer id, and the status only the client id, so
+	// ClientID is filled in from the log only by a ClientRegistry.
+	PeerID   int64
+	ClientID int64
+
+	CommonName string
+
+	// From and To are the client's previous and new real addresses, in
+	// the same host:port form as ConnectedClient.RealAddress.
+	From string
+	To   string
+
+	log *LogEvent
+}
+
+// PeerFloatFromLog returns a PeerFloatEvent describing the given log
+// message if it reports a client floating, or nil if it does not. This can
+// be used to inspect log history retrieved using client.LogHistory.
+func PeerFloatFromLog(e *LogEvent) *PeerFloatEvent {
+	// Such as "peer 3 (alice) floated from 192.0.2.10:50000 to
+	// [AF_INET]198.51.100.7:40000", possibly with a client prefix.
+	msg := e.Message()
+	idx := strings.Index(msg, ") floated from ")
+	if idx == -1 {
+		return nil
+	}
+	addrs := strings.Fields(msg[idx+len(") floated from "):])
+	if len(addrs) != 3 || addrs[1] != "to" {
+		return nil
+	}
+	start := strings.LastIndex(msg[:idx], "peer ")
+	if start == -1 {
+		return nil
+	}
+	idStr, cn, ok := strings.Cut(msg[start+len("peer "):idx], " (")
+	if !ok {
+		return nil
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return nil
+	}
+	if cn == "UNDEF" {
+		cn = ""
+	}
+	return &PeerFloatEvent{
+		PeerID:     id,
+		ClientID:   -1,
+		CommonName: cn,
+		From:       canonicalRealAddress(addrs[0]),
+		To:         canonicalRealAddress(addrs[2]),
+		log:        e,
+	}
+}
+
+// Log returns the log message the event was derived from, or nil if it was
+// detected by a ClientRegistry polling the server's status.
+func (e *PeerFloatEvent) Log() *LogEvent {
+	return e.log
+}
+
+func (e *PeerFloatEvent) String() string {
+	var buf [128]byte
+	b := append(buf[:0], "peer "...)
+	b = strconv.AppendQuote(b, e.CommonName)
+	b = append(b, " floated from "...)
+	b = append(b, e.From...)
+	b = append(b, " to "...)
+	b = append(b, e.To...)
+	return string(b)
+}
+
+// canonicalRealAddress returns a real address as reported by OpenVPN in
+// the host:port form of net.JoinHostPort, removing any address family or
+// protocol prefix and bracketing IPv6 addresses. Addresses it cannot
+// interpret are returned unchanged.
+func canonicalRealAddress(addr string) string {
+	addr = strings.TrimPrefix(addr, "[AF_INET]")
+	addr = strings.TrimPrefix(addr, "[AF_INET6]")
+	for _, proto := range realAddressProtocols {
+		if strings.HasPrefix(addr, proto+":") {
+			addr = addr[len(proto)+1:]
+			break
+		}
+	}
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		return net.JoinHostPort(host, port)
+	}
+	// IPv6 addresses may be followed by the port without brackets.
+	if idx := strings.LastIndexByte(addr, ':'); idx != -1 {
+		host, port := addr[:idx], addr[idx+1:]
+		if _, err := strconv.Atoi(port); err == nil && net.ParseIP(host) != nil {
+			return net.JoinHostPort(host, port)
+		}
+	}
+	return addr
+}
+
+// peerFloat applies a float reported in the log to the client it concerns,
+// identified by its common name and previous real address.
+func (r *ClientRegistry) peerFloat(e *PeerFloatEvent) []ClientChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cid, ok := r.byReal[e.From]
+	if !ok {
+		return nil
+	}
+	cc := r.clients[cid]
+	if e.CommonName != "" && cc.CommonName != e.CommonName {
+		return nil
+	}
+	float := *e
+	float.ClientID = cid
+	return []ClientChange{r.floatLocked(cc, &float)}
+}
+
+// floatLocked moves a client to the real address a float reports, returning
+// the resulting change. It must be called with r.mu held.
+func (r *ClientRegistry) floatLocked(cc *ConnectedClient, float *PeerFloatEvent) ClientChange {
+	before := *cc
+	cc.RealAddress = float.To
+	r.reindexLocked(&before, cc)
+	return ClientChange{
+		Kind:       ClientUpdated,
+		Client:     *cc,
+		duplicates: r.duplicatesLocked(&before, cc),
+		float:      float,
+	}
+}
+
+// statusFloat returns the float reported by a status poll listing a known
+// client at a different real address, or nil if it has not floated.
+func statusFloat(cc *ConnectedClient, cs ClientStatus) *PeerFloatEvent {
+	if cc.RealAddress == "" || cs.RealAddress == "" {
+		return nil
+	}
+	to := canonicalRealAddress(cs.RealAddress)
+	if to == canonicalRealAddress(cc.RealAddress) {
+		return nil
+	}
+	return &PeerFloatEvent{
+		PeerID:     -1,
+		ClientID:   cc.ClientID,
+		CommonName: cc.CommonName,
+		From:       cc.RealAddress,
+		To:         to,
+	}
+}
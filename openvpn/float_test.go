@@ -0,0 +1,77 @@
+package openvpn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPeerFloatFromLog(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want *PeerFloatEvent
+	}{
+		{"Initialization Sequence Completed", nil},
+		{
+			"peer 3 (alice) floated from 192.0.2.10:50000 to [AF_INET]198.51.100.7:40000",
+			&PeerFloatEvent{PeerID: 3, ClientID: -1, CommonName: "alice", From: "192.0.2.10:50000", To: "198.51.100.7:40000"},
+		},
+		{
+			"alice/192.0.2.10:50000 peer 0 (alice) floated from 192.0.2.10:50000 to [AF_INET6]2001:db8::1:1194",
+			&PeerFloatEvent{PeerID: 0, ClientID: -1, CommonName: "alice", From: "192.0.2.10:50000", To: "[2001:db8::1]:1194"},
+		},
+		{"peer x (alice) floated from 192.0.2.10:50000 to 198.51.100.7:40000", nil},
+	}
+	for i, test := range tests {
+		got := PeerFloatFromLog(&LogEvent{body: []byte("1700000000,I," + test.msg)})
+		if got != nil {
+			got.log = nil
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %+v; want %+v", i, got, test.want)
+		}
+	}
+}
+
+func TestRegistryFloat(t *testing.T) {
+	var floats []PeerFloatEvent
+	r := &ClientRegistry{OnFloat: func(e *PeerFloatEvent) {
+		float := *e
+		float.log = nil
+		floats = append(floats, float)
+	}}
+
+	var envs envAssembler
+	for _, raw := range []string{
+		"CLIENT:CONNECT,1,0",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,untrusted_ip=192.0.2.10",
+		"CLIENT:ENV,untrusted_port=50000",
+		"CLIENT:ENV,END",
+		"LOG:1700000000,,peer 0 (alice) floated from 192.0.2.10:50000 to [AF_INET]198.51.100.7:40000",
+		// A float of an unknown client is ignored.
+		"LOG:1700000001,,peer 1 (bob) floated from 192.0.2.11:50000 to [AF_INET]198.51.100.8:40000",
+	} {
+		e := envs.push(upgradeEvent([]byte(raw)))
+		if log, ok := e.(*LogEvent); ok {
+			e = PeerFloatFromLog(log)
+		}
+		if e != nil {
+			r.HandleEvent(e)
+		}
+	}
+	if _, ok := r.ByRealAddr("198.51.100.7:40000"); !ok {
+		t.Errorf("client not found at its new address")
+	}
+
+	// The float has already been applied, so only the next is reported.
+	r.Sync([]ClientStatus{{ClientID: 1, CommonName: "alice", RealAddress: "198.51.100.7:40000"}})
+	r.Sync([]ClientStatus{{ClientID: 1, CommonName: "alice", RealAddress: "udp4:203.0.113.5:1194"}})
+
+	want := []PeerFloatEvent{
+		{PeerID: 0, ClientID: 1, CommonName: "alice", From: "192.0.2.10:50000", To: "198.51.100.7:40000"},
+		{PeerID: -1, ClientID: 1, CommonName: "alice", From: "198.51.100.7:40000", To: "203.0.113.5:1194"},
+	}
+	if !reflect.DeepEqual(floats, want) {
+		t.Errorf("got floats %+v; want %+v", floats, want)
+	}
+}
@@ -0,0 +1,95 @@
+package openvpn
+
+import "io"
+
+// handlerEventBuffer is the depth of the event channel created by
+// NewClientHandler.
+const handlerEventBuffer = 64
+
+// EventHandler receives events through callbacks as an alternative to
+// reading them from a channel. It may implement any combination of the
+// StateHandler, ByteCountHandler, PasswordHandler, FatalHandler and
+// UnknownHandler interfaces; events for which it has no method are
+// discarded.
+type EventHandler interface{}
+
+// StateHandler is implemented by an EventHandler that wants StateEvents.
+type StateHandler interface {
+	OnState(e *StateEvent)
+}
+
+// ByteCountHandler is implemented by an EventHandler that wants
+// ByteCountEvents.
+type ByteCountHandler interface {
+	OnByteCount(e *ByteCountEvent)
+}
+
+// PasswordHandler is implemented by an EventHandler that wants
+// PasswordEvents.
+type PasswordHandler interface {
+	OnPassword(e *PasswordEvent)
+}
+
+// FatalHandler is implemented by an EventHandler that wants FatalEvents,
+// including the synthetic one emitted when the connection fails.
+type FatalHandler interface {
+	OnFatal(e *FatalEvent)
+}
+
+// UnknownHandler is implemented by an EventHandler that wants all of the
+// events not delivered to one of its other methods, including events of
+// types that have no method of their own such as HoldEvent and LogEvent.
+type UnknownHandler interface {
+	OnUnknown(e Event)
+}
+
+// DispatchEvent calls the method of h appropriate for the given event, if
+// it has one.
+func DispatchEvent(h EventHandler, e Event) {
+	switch e := e.(type) {
+	case *StateEvent:
+		if h, ok := h.(StateHandler); ok {
+			h.OnState(e)
+			return
+		}
+	case *ByteCountEvent:
+		if h, ok := h.(ByteCountHandler); ok {
+			h.OnByteCount(e)
+			return
+		}
+	case *PasswordEvent:
+		if h, ok := h.(PasswordHandler); ok {
+			h.OnPassword(e)
+			return
+		}
+	case *FatalEvent:
+		if h, ok := h.(FatalHandler); ok {
+			h.OnFatal(e)
+			return
+		}
+	}
+	if h, ok := h.(UnknownHandler); ok {
+		h.OnUnknown(e)
+	}
+}
+
+// NewClientHandler is like NewClient but delivers events by calling the
+// methods of h rather than by writing them to a channel.
+//
+// The methods are called one at a time, in the order the events arrive,
+// from a goroutine dedicated to the client. They may call the client's
+// command methods, but should not otherwise block for long: the events
+// arriving in the meantime are buffered only up to a fixed depth, after
+// which command responses are delayed as described in the NewClient docs.
+// NewClientHandlerPool instead handles different types of event
+// concurrently, for handlers that are slow for some of them.
+func NewClientHandler(conn io.ReadWriteCloser, h EventHandler) *MgmtClient {
+	eventCh := make(chan Event, handlerEventBuffer)
+	client := NewClient(conn, eventCh)
+	go func() {
+		for e := range eventCh {
+			DispatchEvent(h, e)
+		}
+	}()
+	return client
+}
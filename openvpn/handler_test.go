@@ -0,0 +1,53 @@
+package openvpn
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+type recordingHandler struct {
+	got  []string
+	done chan struct{}
+}
+
+func (h *recordingHandler) OnState(e *StateEvent) { h.got = append(h.got, "state "+e.NewState()) }
+func (h *recordingHandler) OnByteCount(e *ByteCountEvent) {
+	h.got = append(h.got, "bytecount "+e.String())
+}
+func (h *recordingHandler) OnUnknown(e Event) { h.got = append(h.got, "unknown "+e.String()) }
+func (h *recordingHandler) OnFatal(e *FatalEvent) {
+	h.got = append(h.got, "fatal "+e.String())
+	close(h.done)
+}
+
+func TestNewClientHandler(t *testing.T) {
+	server, conn := net.Pipe()
+	h := &recordingHandler{done: make(chan struct{})}
+	NewClientHandler(conn, h)
+
+	for _, line := range []string{
+		">STATE:1,CONNECTED,SUCCESS,10.0.0.2,192.0.2.1",
+		">BYTECOUNT:10,20",
+		">HOLD:Waiting for hold release",
+		">PASSWORD:Need 'Auth' username/password",
+		">FATAL:oops",
+	} {
+		if _, err := server.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-h.done
+	server.Close()
+
+	want := []string{
+		"state CONNECTED",
+		"bytecount " + upgradeEvent([]byte("BYTECOUNT:10,20")).String(),
+		"unknown Waiting for hold release",
+		"unknown " + upgradeEvent([]byte("PASSWORD:Need 'Auth' username/password")).String(),
+		"fatal FATAL: oops",
+	}
+	if !reflect.DeepEqual(h.got, want) {
+		t.Errorf("got %q; want %q", h.got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package openvpn
+
+import (
+	"io"
+	"sync"
+)
+
+// HandlerPool calls the methods of an EventHandler on a bounded pool of
+// worker goroutines, so that a handler that is slow for one type of event,
+// such as one calling a webhook for each ClientEvent, doesn't hold up the
+// handling of other types of event.
+//
+// Events of the same type, as named by EventTypeName, are always handled by
+// the same worker, so they are handled one at a time and in the order they
+// were dispatched. Events of different types may be handled concurrently,
+// so the handler must be safe for concurrent use. Each type is assigned to
+// a worker as it is first seen, spreading the types evenly over the pool;
+// with fewer workers than types, some types share a worker and so wait for
+// each other.
+type HandlerPool struct {
+	h       EventHandler
+	workers []chan Event
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	byType map[string]chan Event
+}
+
+// NewHandlerPool starts a pool of the given number of workers, at least
+// one, calling the methods of h.
+func NewHandlerPool(h EventHandler, workers int) *HandlerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &HandlerPool{
+		h:       h,
+		workers: make([]chan Event, workers),
+		byType:  map[string]chan Event{},
+	}
+	for i := range p.workers {
+		ch := make(chan Event, handlerEventBuffer)
+		p.workers[i] = ch
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for e := range ch {
+				DispatchEvent(p.h, e)
+			}
+		}()
+	}
+	return p
+}
+
+// Dispatch queues the event for the worker handling its type. If that
+// worker has fallen a fixed depth of events behind then Dispatch blocks
+// until it catches up, so that a persistently slow handler applies
+// back-pressure rather than queueing events without limit.
+func (p *HandlerPool) Dispatch(e Event) {
+	name := EventTypeName(e)
+	p.mu.Lock()
+	ch, ok := p.byType[name]
+	if !ok {
+		ch = p.workers[len(p.byType)%len(p.workers)]
+		p.byType[name] = ch
+	}
+	p.mu.Unlock()
+	ch <- e
+}
+
+// Close waits for the events already dispatched to be handled, and then
+// stops the workers. Dispatch must not be called afterwards.
+func (p *HandlerPool) Close() {
+	for _, ch := range p.workers {
+		close(ch)
+	}
+	p.wg.Wait()
+}
+
+// NewClientHandlerPool is like NewClientHandler but calls the methods of h
+// on a HandlerPool with the given number of workers, so that they may be
+// called concurrently for different types of event. The pool is closed
+// once the connection closes and the last event has been dispatched.
+func NewClientHandlerPool(conn io.ReadWriteCloser, h EventHandler, workers int) *MgmtClient {
+	eventCh := make(chan Event, handlerEventBuffer)
+	client := NewClient(conn, eventCh)
+	pool := NewHandlerPool(h, workers)
+	go func() {
+		for e := range eventCh {
+			pool.Dispatch(e)
+		}
+		pool.Close()
+	}()
+	return client
+}
@@ -0,0 +1,103 @@
+package openvpn
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowStateHandler blocks in OnState until released, recording the other
+// events it receives in the meantime.
+type slowStateHandler struct {
+	release chan struct{}
+
+	mu         sync.Mutex
+	states     []string
+	byteCounts []int
+	byteCount  chan struct{}
+	fatal      chan struct{}
+}
+
+func (h *slowStateHandler) OnState(e *StateEvent) {
+	<-h.release
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.states = append(h.states, e.NewState())
+}
+
+func (h *slowStateHandler) OnByteCount(e *ByteCountEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.byteCounts = append(h.byteCounts, e.BytesIn())
+	h.byteCount <- struct{}{}
+}
+
+func (h *slowStateHandler) OnFatal(e *FatalEvent) {
+	close(h.fatal)
+}
+
+func TestHandlerPool(t *testing.T) {
+	h := &slowStateHandler{
+		release:   make(chan struct{}),
+		byteCount: make(chan struct{}, 10),
+		fatal:     make(chan struct{}),
+	}
+	pool := NewHandlerPool(h, 2)
+
+	pool.Dispatch(upgradeEvent([]byte("STATE:1,CONNECTING,,,")))
+	pool.Dispatch(upgradeEvent([]byte("STATE:2,WAIT,,,")))
+	for i := 0; i < 5; i++ {
+		pool.Dispatch(upgradeEvent([]byte(fmt.Sprintf("BYTECOUNT:%d,0", i))))
+	}
+
+	// The byte counts are handled while the state handler is blocked.
+	for i := 0; i < 5; i++ {
+		select {
+		case <-h.byteCount:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("byte count %d not handled while state handler blocked", i)
+		}
+	}
+
+	pool.Dispatch(upgradeEvent([]byte("STATE:3,CONNECTED,SUCCESS,,")))
+	close(h.release)
+	pool.Close()
+
+	if want := []string{"CONNECTING", "WAIT", "CONNECTED"}; !reflect.DeepEqual(h.states, want) {
+		t.Errorf("got states %q; want %q", h.states, want)
+	}
+	if want := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(h.byteCounts, want) {
+		t.Errorf("got byte counts %v; want %v", h.byteCounts, want)
+	}
+}
+
+func TestNewClientHandlerPool(t *testing.T) {
+	server, conn := net.Pipe()
+	h := &slowStateHandler{
+		release:   make(chan struct{}),
+		byteCount: make(chan struct{}, 10),
+		fatal:     make(chan struct{}),
+	}
+	close(h.release)
+	NewClientHandlerPool(conn, h, 4)
+
+	for _, line := range []string{
+		">STATE:1,CONNECTED,SUCCESS,10.0.0.2,192.0.2.1",
+		">BYTECOUNT:10,20",
+		">FATAL:oops",
+	} {
+		if _, err := server.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-h.fatal:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fatal event not handled")
+	}
+	<-h.byteCount
+	server.Close()
+}
@@ -0,0 +1,185 @@
+package openvpn
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultInactivityWarning is the fraction of the ping-restart timeout
+// after which an InactivityMonitor whose WarnAfter field is zero warns.
+const DefaultInactivityWarning = 0.5
+
+// InactivityWarningEvent reports that no traffic has been received through
+// the tunnel for a large part of the ping-restart timeout, so that OpenVPN
+// is likely to restart the connection soon, as detected by an
+// InactivityMonitor.
+//
+// It is not received from OpenVPN, but implements Event so that it can be
+// published along with other events, such as on an EventBus.
+type InactivityWarningEvent struct {
+	// LastActivity is when traffic was last seen, and Idle how long ago
+	// that was when the warning was raised.
+	LastActivity time.Time
+	Idle         time.Duration
+
+	// PingRestart is the timeout after which OpenVPN will restart the
+	// connection.
+	PingRestart time.Duration
+}
+
+// Remaining returns how long remained before the timeout expired when the
+// warning was raised.
+func (e *InactivityWarningEvent) Remaining() time.Duration {
+	return e.PingRestart - e.Idle
+}
+
+func (e *InactivityWarningEvent) String() string {
+	return fmt.Sprintf("no traffic for %v; ping-restart in %v", e.Idle, e.Remaining())
+}
+
+// InactivityMonitor warns when no traffic has been received through the
+// tunnel of an OpenVPN client for a configurable fraction of its
+// ping-restart timeout, so that the application can act before the
+// connection drops, such as by switching to another server.
+//
+// Traffic is detected from changes in the incoming byte count reported by
+// ByteCountEvents, so byte count events must be enabled with an interval
+// well below the warning threshold. Since OpenVPN sends pings when the
+// tunnel would otherwise be idle, a healthy connection always has some
+// incoming traffic.
+//
+// The zero value is ready to use, and uses the ping-restart timeout pushed
+// by the server. An InactivityMonitor is safe for concurrent use.
+type InactivityMonitor struct {
+	// PingRestart is the ping-restart timeout the client is using. If
+	// zero, the timeout pushed by the server is used, as recognized in the
+	// log, so log events must be enabled.
+	PingRestart time.Duration
+
+	// WarnAfter is the fraction of the timeout after which to warn. If
+	// zero, DefaultInactivityWarning is used.
+	WarnAfter float64
+
+	// OnWarning, if set, is called by Run with each warning.
+	OnWarning func(*InactivityWarningEvent)
+
+	mu           sync.Mutex
+	connected    bool
+	pushed       time.Duration
+	bytesIn      int64
+	lastActivity time.Time
+	warned       bool
+}
+
+// HandleEvent updates the monitor from the given event, returning true if
+// it was a StateEvent, a tunnel ByteCountEvent or a LogEvent reporting the
+// options pushed by the server. The caller should pass each event received
+// from the client's event channel.
+func (m *InactivityMonitor) HandleEvent(e Event) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	switch e := e.(type) {
+	case *StateEvent:
+		m.connected = State(e.NewState()) == StateConnected
+		if m.connected {
+			m.activityLocked(now)
+		}
+		return true
+	case *ByteCountEvent:
+		if e.hasClient {
+			return false
+		}
+		if in := int64(e.BytesIn()); in != m.bytesIn {
+			m.bytesIn = in
+			m.activityLocked(now)
+		}
+		return true
+	case *LogEvent:
+		timeout, ok := pushedPingRestart(e.Message())
+		if ok {
+			m.pushed = timeout
+		}
+		return ok
+	}
+	return false
+}
+
+// activityLocked records that traffic was seen at the given time. It must
+// be called with m.mu held.
+func (m *InactivityMonitor) activityLocked(now time.Time) {
+	m.lastActivity = now
+	m.warned = false
+}
+
+// Check returns a warning if the tunnel has been idle for long enough at
+// the given time, or nil if it has not or the tunnel is not connected. Only
+// one warning is returned for each period of inactivity.
+func (m *InactivityMonitor) Check(now time.Time) *InactivityWarningEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	timeout := m.PingRestart
+	if timeout <= 0 {
+		timeout = m.pushed
+	}
+	if !m.connected || m.warned || timeout <= 0 {
+		return nil
+	}
+	frac := m.WarnAfter
+	if frac <= 0 {
+		frac = DefaultInactivityWarning
+	}
+	idle := now.Sub(m.lastActivity)
+	if idle < time.Duration(frac*float64(timeout)) {
+		return nil
+	}
+	m.warned = true
+	return &InactivityWarningEvent{
+		LastActivity: m.lastActivity,
+		Idle:         idle,
+		PingRestart:  timeout,
+	}
+}
+
+// Run calls Check at the given interval, passing any warning to OnWarning,
+// until ctx is cancelled, returning ctx.Err().
+func (m *InactivityMonitor) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			if w := m.Check(now); w != nil && m.OnWarning != nil {
+				m.OnWarning(w)
+			}
+		}
+	}
+}
+
+// pushedPingRestart returns the ping-restart timeout given in a log message
+// reporting the options pushed by the server, such as "PUSH: Received
+// control message: 'PUSH_REPLY,ping 10,ping-restart 60,...'".
+func pushedPingRestart(msg string) (time.Duration, bool) {
+	idx := strings.Index(msg, "PUSH_REPLY,")
+	if idx == -1 {
+		return 0, false
+	}
+	for _, opt := range strings.Split(msg[idx:], ",") {
+		opt = strings.TrimRight(opt, "'")
+		if !strings.HasPrefix(opt, "ping-restart ") {
+			continue
+		}
+		if n, err := strconv.Atoi(opt[len("ping-restart "):]); err == nil && n > 0 {
+			return time.Duration(n) * time.Second, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,47 @@
+package openvpn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInactivityMonitor(t *testing.T) {
+	var m InactivityMonitor
+	for _, raw := range []string{
+		"LOG:1700000000,,PUSH: Received control message: 'PUSH_REPLY,ping 10,ping-restart 60,ifconfig 10.8.0.2 255.255.255.0'",
+		"STATE:1700000001,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1,1194,,",
+		"BYTECOUNT:100,200",
+	} {
+		m.HandleEvent(upgradeEvent([]byte(raw)))
+	}
+	start := time.Now()
+
+	tests := []struct {
+		raw      string
+		after    time.Duration
+		wantWarn bool
+	}{
+		{"", 20 * time.Second, false},
+		{"", 31 * time.Second, true},
+		// Only one warning is given for each period of inactivity.
+		{"", 40 * time.Second, false},
+		// A byte count with unchanged incoming traffic is not activity.
+		{"BYTECOUNT:100,300", 45 * time.Second, false},
+		{"BYTECOUNT:150,300", 31 * time.Second, true},
+		{"STATE:1700000100,RECONNECTING,ping-restart,,,,,", 90 * time.Second, false},
+	}
+	for i, test := range tests {
+		if test.raw != "" {
+			m.HandleEvent(upgradeEvent([]byte(test.raw)))
+			start = time.Now()
+		}
+		w := m.Check(start.Add(test.after))
+		if (w != nil) != test.wantWarn {
+			t.Errorf("test %d got warning %v; want %v", i, w, test.wantWarn)
+			continue
+		}
+		if w != nil && (w.PingRestart != time.Minute || w.Remaining() > 30*time.Second) {
+			t.Errorf("test %d got %+v", i, w)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package openvpn
+
+import "sort"
+
+// ByCID returns the client with the given client id, and false if it is
+// not known. It is the same as Client.
+func (r *ClientRegistry) ByCID(cid int64) (ConnectedClient, bool) {
+	return r.Client(cid)
+}
+
+// ByCommonName returns the clients with the given common name, ordered by
+// client id.
+func (r *ClientRegistry) ByCommonName(cn string) []ConnectedClient {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cids := r.byCommonName[cn]
+	ret := make([]ConnectedClient, 0, len(cids))
+	for cid := range cids {
+		ret = append(ret, *r.clients[cid])
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ClientID < ret[j].ClientID
+	})
+	return ret
+}
+
+// ByVirtualAddr returns the client assigned the given IPv4 or IPv6 address
+// within the tunnel, and false if there is none.
+func (r *ClientRegistry) ByVirtualAddr(addr string) (ConnectedClient, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookupLocked(r.byVirtual, addr)
+}
+
+// ByRealAddr returns the client connected from the given real address, in
+// the same host:port form as ConnectedClient.RealAddress, and false if
+// there is none.
+func (r *ClientRegistry) ByRealAddr(addr string) (ConnectedClient, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookupLocked(r.byReal, addr)
+}
+
+func (r *ClientRegistry) lookupLocked(index map[string]int64, key string) (ConnectedClient, bool) {
+	cid, ok := index[key]
+	if !ok {
+		return ConnectedClient{}, false
+	}
+	return *r.clients[cid], true
+}
+
+// reindexLocked updates the indexes for a client that has changed from
+// before to after, or has been removed if after is nil. It must be called
+// with r.mu held.
+func (r *ClientRegistry) reindexLocked(before, after *ConnectedClient) {
+	cid := before.ClientID
+	if after != nil {
+		cid = after.ClientID
+	}
+
+	unindex := func(index map[string]int64, key string) {
+		if key != "" && index[key] == cid {
+			delete(index, key)
+		}
+	}
+	if cids := r.byCommonName[before.CommonName]; cids != nil {
+		delete(cids, cid)
+		if len(cids) == 0 {
+			delete(r.byCommonName, before.CommonName)
+		}
+	}
+	unindex(r.byVirtual, before.VirtualAddress)
+	unindex(r.byVirtual, before.VirtualIPv6Address)
+	unindex(r.byReal, before.RealAddress)
+	if after == nil {
+		return
+	}
+
+	if r.byCommonName == nil {
+		r.byCommonName = map[string]map[int64]struct{}{}
+		r.byVirtual = map[string]int64{}
+		r.byReal = map[string]int64{}
+	}
+	index := func(index map[string]int64, key string) {
+		if key != "" {
+			index[key] = cid
+		}
+	}
+	if after.CommonName != "" {
+		cids := r.byCommonName[after.CommonName]
+		if cids == nil {
+			cids = map[int64]struct{}{}
+			r.byCommonName[after.CommonName] = cids
+		}
+		cids[cid] = struct{}{}
+	}
+	index(r.byVirtual, after.VirtualAddress)
+	index(r.byVirtual, after.VirtualIPv6Address)
+	index(r.byReal, after.RealAddress)
+}
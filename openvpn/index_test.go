@@ -0,0 +1,67 @@
+package openvpn
+
+import "testing"
+
+func TestRegistryIndexes(t *testing.T) {
+	r := &ClientRegistry{}
+	var envs envAssembler
+	for _, raw := range []string{
+		"CLIENT:CONNECT,1,0",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,untrusted_ip=192.0.2.10",
+		"CLIENT:ENV,untrusted_port=50000",
+		"CLIENT:ENV,ifconfig_pool_remote_ip=10.8.0.2",
+		"CLIENT:ENV,END",
+		"CLIENT:ADDRESS,1,fd00::2,1",
+		"CLIENT:CONNECT,2,0",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,END",
+		"CLIENT:CONNECT,3,0",
+		"CLIENT:ENV,common_name=bob",
+		"CLIENT:ENV,END",
+	} {
+		if e := envs.push(upgradeEvent([]byte(raw))); e != nil {
+			r.HandleEvent(e)
+		}
+	}
+
+	if got := r.ByCommonName("alice"); len(got) != 2 || got[0].ClientID != 1 || got[1].ClientID != 2 {
+		t.Errorf("ByCommonName(alice) returned %+v; want clients 1 and 2", got)
+	}
+	for _, addr := range []string{"10.8.0.2", "fd00::2"} {
+		if cc, ok := r.ByVirtualAddr(addr); !ok || cc.ClientID != 1 {
+			t.Errorf("ByVirtualAddr(%s) returned %+v, %v; want client 1", addr, cc, ok)
+		}
+	}
+	if cc, ok := r.ByRealAddr("192.0.2.10:50000"); !ok || cc.ClientID != 1 {
+		t.Errorf("ByRealAddr returned %+v, %v; want client 1", cc, ok)
+	}
+	if cc, ok := r.ByCID(3); !ok || cc.CommonName != "bob" {
+		t.Errorf("ByCID(3) returned %+v, %v; want bob", cc, ok)
+	}
+
+	// A poll shows that client 1 was given a new address, and that bob
+	// has gone.
+	r.Sync([]ClientStatus{
+		{ClientID: 1, CommonName: "alice", RealAddress: "192.0.2.10:50000", VirtualAddress: "10.8.0.6"},
+		{ClientID: 2, CommonName: "alice"},
+	})
+	r.HandleEvent(upgradeEvent([]byte("CLIENT:DISCONNECT,3")))
+	if _, ok := r.ByVirtualAddr("10.8.0.2"); ok {
+		t.Errorf("old virtual address is still indexed")
+	}
+	if cc, ok := r.ByVirtualAddr("10.8.0.6"); !ok || cc.ClientID != 1 {
+		t.Errorf("ByVirtualAddr(10.8.0.6) returned %+v, %v; want client 1", cc, ok)
+	}
+	if got := r.ByCommonName("bob"); len(got) != 0 {
+		t.Errorf("ByCommonName(bob) returned %+v after disconnect", got)
+	}
+
+	r.HandleEvent(upgradeEvent([]byte("CLIENT:DISCONNECT,1")))
+	if _, ok := r.ByRealAddr("192.0.2.10:50000"); ok {
+		t.Errorf("real address of disconnected client is still indexed")
+	}
+	if got := r.ByCommonName("alice"); len(got) != 1 || got[0].ClientID != 2 {
+		t.Errorf("ByCommonName(alice) returned %+v; want client 2", got)
+	}
+}
@@ -0,0 +1,170 @@
+package openvpn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// journalRedacted lists the prefixes of events whose remainder is replaced
+// in the journal, so that it doesn't hold credentials: the passwords and
+// challenge responses of clients authenticating to a server, and auth
+// tokens pushed to a client.
+var journalRedacted = [][]byte{
+	[]byte("CLIENT:ENV,password="),
+	[]byte("CLIENT:CR_RESPONSE,"),
+	[]byte("PASSWORD:Auth-Token:"),
+}
+
+// Journal records the events received from OpenVPN, along with the time
+// each was received, so that they can later be fed back through the same
+// consumers using Replay, such as for post-incident analysis.
+//
+// Events are recorded from the connection to the management interface,
+// wrapped using Journal.Conn, as it is read by the client. Each event is
+// written as a line holding the time it was received, in RFC 3339 format,
+// followed by a space and the message as OpenVPN sent it, without the
+// leading '>'. Replies to commands are not recorded, and passwords sent by
+// clients authenticating to a server and auth tokens are redacted.
+type Journal struct {
+	mu      sync.Mutex
+	w       io.Writer
+	partial []byte
+	err     error
+}
+
+// NewJournal returns a journal writing to w.
+func NewJournal(w io.Writer) *Journal {
+	return &Journal{w: w}
+}
+
+// OpenJournal returns a journal appending to the file at the given path,
+// creating it if necessary. The file is closed by closing the journal.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewJournal(f), nil
+}
+
+// Close closes the journal's writer, if it is an io.Closer.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if c, ok := j.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Err returns the first error encountered while writing the journal, if
+// any. Once an error occurs, no further events are recorded, but the
+// connection is not otherwise affected.
+func (j *Journal) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// Conn wraps a connection to the management interface so that the events
+// read from it are recorded in the journal. The result should be passed
+// to NewClient in place of conn.
+func (j *Journal) Conn(conn io.ReadWriteCloser) io.ReadWriteCloser {
+	return &journalConn{ReadWriteCloser: conn, j: j}
+}
+
+type journalConn struct {
+	io.ReadWriteCloser
+	j *Journal
+}
+
+func (c *journalConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	if n > 0 {
+		c.j.record(p[:n], time.Now())
+	}
+	return n, err
+}
+
+// record writes the events among the given data, which may end or begin
+// part way through a line.
+func (j *Journal) record(data []byte, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	splitLines(&j.partial, data, func(line []byte) {
+		if line[0] != '>' {
+			return
+		}
+		buf.WriteString(now.Format(time.RFC3339Nano))
+		buf.WriteByte(' ')
+		buf.Write(redactJournalLine(line[1:]))
+		buf.WriteByte('\n')
+	})
+	if buf.Len() > 0 {
+		_, j.err = j.w.Write(buf.Bytes())
+	}
+}
+
+func redactJournalLine(line []byte) []byte {
+	for _, prefix := range journalRedacted {
+		if bytes.HasPrefix(line, prefix) {
+			return append(append([]byte(nil), prefix...), "[redacted]"...)
+		}
+	}
+	return line
+}
+
+// Replay reads a journal written by Journal and calls fn with each of the
+// events it records, in order, along with the time each was received.
+// The events are parsed using ParseEvent and assembled into the same
+// events as the client emits, so they can be passed to the HandleEvent
+// methods of consumers such as Session and ClientRegistry.
+//
+// Replay stops and returns the error if fn returns one, and fails if the
+// journal is malformed.
+func Replay(r io.Reader, fn func(received time.Time, e Event) error) error {
+	var dec eventDecoder
+	var last time.Time
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		idx := bytes.IndexByte(line, ' ')
+		if idx == -1 {
+			return fmt.Errorf("journal line %d: missing timestamp", lineNum)
+		}
+		received, err := time.Parse(time.RFC3339Nano, string(line[:idx]))
+		if err != nil {
+			return fmt.Errorf("journal line %d: %w", lineNum, err)
+		}
+		last = received
+
+		// The scanner reuses its buffer, and events may retain their raw
+		// message.
+		raw := append([]byte(nil), line[idx+1:]...)
+		for _, e := range dec.decode(raw) {
+			if err := fn(received, e); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if e := dec.envs.flush(); e != nil {
+		return fn(last, e)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package openvpn
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	var buf bytes.Buffer
+	j := NewJournal(&buf)
+	server, conn := net.Pipe()
+	events := make(chan Event, 10)
+	NewClient(j.Conn(conn), events)
+
+	go func() {
+		io.WriteString(server, ">STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1\r\n>CLIENT:CONNECT,1,0\n>CLIENT:ENV,common_")
+		io.WriteString(server, "name=alice\n>CLIENT:ENV,password=secret\n>CLIENT:ENV,END\n>PASSWORD:Auth-Token:secret\n>CLIENT:CR_RESPONSE,1,0,secret\n>CLIENT:ENV,END\n")
+		server.Close()
+	}()
+	var received []Event
+	for e := range events {
+		received = append(received, e)
+	}
+	if len(received) != 4 {
+		t.Fatalf("client emitted %d events; want 4", len(received))
+	}
+	if err := j.Err(); err != nil {
+		t.Fatalf("journal failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("journal contains a secret:\n%s", buf.String())
+	}
+
+	var s Session
+	r := &ClientRegistry{}
+	var times []time.Time
+	err := Replay(&buf, func(at time.Time, e Event) error {
+		times = append(times, at)
+		s.HandleEvent(e)
+		r.HandleEvent(e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(times) != 4 || times[0].IsZero() {
+		t.Errorf("got receive times %v; want four", times)
+	}
+	if !s.Connected() {
+		t.Errorf("replayed session is in state %q; want CONNECTED", s.State())
+	}
+	if cc, ok := r.Client(1); !ok || cc.CommonName != "alice" {
+		t.Errorf("replayed registry has client %+v, %v; want alice", cc, ok)
+	}
+}
+
+func TestReplayMalformed(t *testing.T) {
+	for i, journal := range []string{
+		"STATE:1,CONNECTED,SUCCESS,,\n",
+		"yesterday STATE:1,CONNECTED,SUCCESS,,\n",
+	} {
+		err := Replay(strings.NewReader(journal), func(time.Time, Event) error { return nil })
+		if err == nil {
+			t.Errorf("test %d: Replay succeeded; want error", i)
+		}
+	}
+}
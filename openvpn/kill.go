@@ -0,0 +1,139 @@
+package openvpn
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"time"
+)
+
+// ClientKill disconnects the client of a server with the given client id.
+// The message, if not empty, is sent to the client as the reason, and is
+// typically "HALT", to tell the client not to reconnect, or "RESTART", to
+// tell it to reconnect immediately. It requires OpenVPN 2.5 or later to be
+// honored.
+func (c *MgmtClient) ClientKill(cid int64, message string) error {
+	if err := checkArg("client-kill message", message); err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf("client-kill %d", cid)
+	if message != "" {
+		cmd += " " + quoteArg(message)
+	}
+	_, err := c.simpleCommand(cmd)
+	return err
+}
+
+// Kill disconnects the clients of a server with the given common name, or
+// the client connected from the given real address, in the form
+// "proto:ip:port" or "ip:port".
+func (c *MgmtClient) Kill(target string) error {
+	if err := checkArg("kill target", target); err != nil {
+		return err
+	}
+	_, err := c.simpleCommand("kill " + quoteArg(target))
+	return err
+}
+
+// ClientFilter selects clients from a ClientRegistry. A client matches if
+// it satisfies every criterion that is set, so the zero value matches all
+// clients.
+type ClientFilter struct {
+	// CommonName is a pattern matched against the client's common name, in
+	// the syntax of path.Match, such as "contractor-*".
+	CommonName string
+
+	// ConnectedBefore selects clients that connected before the given time.
+	ConnectedBefore time.Time
+
+	// IdleFor selects clients whose byte counts have not changed for at
+	// least the given duration, as measured by ConnectedClient.LastActivity.
+	IdleFor time.Duration
+
+	// VirtualSubnet selects clients with a virtual IPv4 or IPv6 address
+	// within the given CIDR subnet, such as "10.8.1.0/24".
+	VirtualSubnet string
+}
+
+// matcher returns a function reporting whether a client matches the
+// filter, failing if the filter is malformed.
+func (f ClientFilter) matcher(now time.Time) (func(ConnectedClient) bool, error) {
+	if _, err := path.Match(f.CommonName, ""); err != nil {
+		return nil, fmt.Errorf("invalid common name pattern %q: %w", f.CommonName, err)
+	}
+	var subnet *net.IPNet
+	if f.VirtualSubnet != "" {
+		var err error
+		_, subnet, err = net.ParseCIDR(f.VirtualSubnet)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(cc ConnectedClient) bool {
+		if f.CommonName != "" {
+			if ok, _ := path.Match(f.CommonName, cc.CommonName); !ok {
+				return false
+			}
+		}
+		if !f.ConnectedBefore.IsZero() && !cc.ConnectedSince.Before(f.ConnectedBefore) {
+			return false
+		}
+		if f.IdleFor > 0 && now.Sub(cc.LastActivity) < f.IdleFor {
+			return false
+		}
+		if subnet != nil {
+			v4, v6 := net.ParseIP(cc.VirtualAddress), net.ParseIP(cc.VirtualIPv6Address)
+			if !(v4 != nil && subnet.Contains(v4)) && !(v6 != nil && subnet.Contains(v6)) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// Match returns the clients in the registry that match the given filter,
+// ordered by client id.
+func (r *ClientRegistry) Match(filter ClientFilter) ([]ConnectedClient, error) {
+	match, err := filter.matcher(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var ret []ConnectedClient
+	for _, cc := range r.Clients() {
+		if match(cc) {
+			ret = append(ret, cc)
+		}
+	}
+	return ret, nil
+}
+
+// KillResult is the outcome of disconnecting one of the clients selected
+// by ClientRegistry.KillClients.
+type KillResult struct {
+	Client ConnectedClient
+	Err    error
+}
+
+// KillClients disconnects all of the clients in the registry that match
+// the given filter, using client-kill with the given message as described
+// for MgmtClient.ClientKill, for example to drain a server for maintenance
+// or to disconnect clients whose certificates have been revoked.
+//
+// It returns the outcome for each client, ordered by client id, and fails
+// only if the filter is malformed. The registry itself is updated once the
+// server reports that the clients have disconnected.
+func (r *ClientRegistry) KillClients(client *MgmtClient, filter ClientFilter, message string) ([]KillResult, error) {
+	matched, err := r.Match(filter)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]KillResult, len(matched))
+	for i, cc := range matched {
+		results[i] = KillResult{
+			Client: cc,
+			Err:    client.ClientKill(cc.ClientID, message),
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,79 @@
+package openvpn
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKillClients(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+	r := &ClientRegistry{}
+	old := time.Now().Add(-2 * time.Hour)
+	r.Sync([]ClientStatus{
+		{ClientID: 1, CommonName: "contractor-alice", VirtualAddress: "10.8.1.2", ConnectedSince: old},
+		{ClientID: 2, CommonName: "contractor-bob", VirtualAddress: "10.8.0.3", ConnectedSince: old},
+		{ClientID: 3, CommonName: "carol", VirtualAddress: "10.8.1.4", ConnectedSince: old},
+		{ClientID: 4, CommonName: "contractor-dave", VirtualIPv6Address: "fd00:1::4", ConnectedSince: time.Now()},
+	})
+
+	tests := []struct {
+		filter ClientFilter
+		want   []int64
+	}{
+		{ClientFilter{}, []int64{1, 2, 3, 4}},
+		{ClientFilter{CommonName: "contractor-*"}, []int64{1, 2, 4}},
+		{ClientFilter{CommonName: "contractor-*", ConnectedBefore: time.Now().Add(-time.Hour)}, []int64{1, 2}},
+		{ClientFilter{VirtualSubnet: "10.8.1.0/24"}, []int64{1, 3}},
+		{ClientFilter{VirtualSubnet: "fd00:1::/64"}, []int64{4}},
+		{ClientFilter{IdleFor: time.Hour}, nil},
+	}
+	for i, test := range tests {
+		matched, err := r.Match(test.filter)
+		if err != nil {
+			t.Errorf("test %d failed: %v", i, err)
+			continue
+		}
+		var got []int64
+		for _, cc := range matched {
+			got = append(got, cc.ClientID)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got clients %v; want %v", i, got, test.want)
+		}
+	}
+
+	for _, filter := range []ClientFilter{{CommonName: "["}, {VirtualSubnet: "10.8.1.0"}} {
+		if _, err := r.KillClients(client, filter, ""); err == nil {
+			t.Errorf("KillClients accepted malformed filter %+v", filter)
+		}
+	}
+
+	results, err := r.KillClients(client, ClientFilter{VirtualSubnet: "10.8.1.0/24"}, "HALT")
+	if err != nil {
+		t.Fatalf("KillClients failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Err != nil || results[1].Client.CommonName != "carol" {
+		t.Errorf("got results %+v", results)
+	}
+	if got, want := server.Commands(), []string{`client-kill 1 "HALT"`, `client-kill 3 "HALT"`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+}
+
+func TestKillRejectsLineBreaks(t *testing.T) {
+	server, client, _ := newFakeServer(t)
+
+	if err := client.Kill("alice\nsignal SIGTERM"); err == nil {
+		t.Errorf("Kill accepted a target with a line break")
+	}
+	if err := client.ClientKill(1, "HALT\r\nsignal SIGTERM"); err == nil {
+		t.Errorf("ClientKill accepted a message with a line break")
+	}
+	if err := client.Kill(`alice "the admin"`); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := server.Commands(), []string{`kill "alice \"the admin\""`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q; want %q", got, want)
+	}
+}
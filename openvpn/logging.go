@@ -0,0 +1,54 @@
+package openvpn
+
+import (
+	"io"
+	"log/slog"
+	"math"
+)
+
+// SetLogger sets the logger used for the client's diagnostics, such as
+// failed commands, events the client doesn't recognize and the loss of its
+// connection, which are otherwise not reported beyond the errors returned
+// to callers. Messages are logged at the Debug level, except for the loss
+// of the connection, which is logged at the Warn level. Passing nil, the
+// default, disables logging.
+//
+// The logger is shared with clients derived from c using WithReason.
+func (c *MgmtClient) SetLogger(l *slog.Logger) {
+	c.logger.Store(l)
+}
+
+// log returns the logger set with SetLogger, or one that discards all
+// messages.
+func (c *mgmtConn) log() *slog.Logger {
+	if l := c.logger.Load(); l != nil {
+		return l
+	}
+	return discardLogger
+}
+
+// logEvent logs the diagnostics arising from an event received from
+// OpenVPN.
+func (c *mgmtConn) logEvent(e Event) {
+	switch e := e.(type) {
+	case *MalformedEvent:
+		c.log().Debug("malformed management event", "event", e.String())
+	case *UnknownEvent:
+		c.log().Debug("unrecognized management event", "event", e.String())
+	case *FatalEvent:
+		if string(e.body) == readErrorMessage {
+			c.log().Warn("management connection failed")
+		}
+	}
+}
+
+// logCommand logs the failure of a command, whose secrets are redacted.
+func (c *mgmtConn) logCommand(cmd string, err error) {
+	if err != nil {
+		c.log().Debug("management command failed", "command", redactCommand(cmd), "error", err)
+	}
+}
+
+// discardLogger is a logger whose level is too high for any message to be
+// enabled.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.Level(math.MaxInt)}))
@@ -0,0 +1,68 @@
+package openvpn
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClientLogger(t *testing.T) {
+	server, conn := net.Pipe()
+	events := make(chan Event, 10)
+	client := NewClient(conn, events)
+
+	var mu sync.Mutex
+	var buf bytes.Buffer
+	client.SetLogger(slog.New(slog.NewTextHandler(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	}), &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	go func() {
+		scanner := bufio.NewScanner(server)
+		scanner.Scan()
+		server.Write([]byte("ERROR: unknown command, enter 'help' for more options\n"))
+		server.Write([]byte(">FOO:bar\n"))
+		server.Close()
+	}()
+	if err := client.Credentials("Auth", "alice", "secret"); err == nil {
+		t.Errorf("command succeeded; want error")
+	}
+	for range events {
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		`level=DEBUG msg="management command failed" command="username \"Auth\" \"alice\"" error="unknown command, enter 'help' for more options"`,
+		`level=DEBUG msg="management connection closed"`,
+		`level=DEBUG msg="unrecognized management event" event="FOO: bar"`,
+	}
+	// Events and replies are handled concurrently.
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong log\ngot  %q\nwant %q", got, want)
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,210 @@
+package openvpn
+
+import (
+	"sort"
+	"time"
+)
+
+// RegistryState is the state of a ClientRegistry, as returned by
+// ClientRegistry.SaveState, in a form that can be serialized, such as with
+// encoding/json, and restored when the managing application restarts.
+type RegistryState struct {
+	// Clients are the clients known to the registry, without their Env,
+	// which may contain passwords and so is not saved.
+	Clients []ConnectedClient
+
+	// LastAddresses holds the most recent tunnel addresses assigned to each
+	// common name.
+	LastAddresses map[string]AssignedAddress
+}
+
+// AssignedAddress is the tunnel address assigned to a client.
+type AssignedAddress struct {
+	VirtualAddress     string
+	VirtualIPv6Address string
+}
+
+// SaveState returns the state of the registry.
+func (r *ClientRegistry) SaveState() RegistryState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st := RegistryState{
+		Clients:       make([]ConnectedClient, 0, len(r.clients)),
+		LastAddresses: make(map[string]AssignedAddress, len(r.lastAddrs)),
+	}
+	for _, cc := range r.clients {
+		c := *cc
+		c.Env = nil
+		st.Clients = append(st.Clients, c)
+	}
+	sort.Slice(st.Clients, func(i, j int) bool {
+		return st.Clients[i].ClientID < st.Clients[j].ClientID
+	})
+	for cn, addr := range r.lastAddrs {
+		st.LastAddresses[cn] = addr
+	}
+	return st
+}
+
+// RestoreState replaces the contents of the registry with a saved state,
+// without calling OnChange. The restored clients may since have
+// disconnected, so the registry should be resynchronized with a fresh
+// client list using Sync, or by calling Resume instead, which reports the
+// differences as changes.
+func (r *ClientRegistry) RestoreState(st RegistryState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clients = make(map[int64]*ConnectedClient, len(st.Clients))
+	r.byCommonName, r.byVirtual, r.byReal = nil, nil, nil
+	for _, cc := range st.Clients {
+		cc := cc
+		// A client that was not yet established when the state was saved
+		// has either since become established or gone, so treat it as
+		// established to have the next Sync remove it if it is gone.
+		cc.Established = true
+		r.clients[cc.ClientID] = &cc
+		r.reindexLocked(&ConnectedClient{}, &cc)
+	}
+	r.lastAddrs = make(map[string]AssignedAddress, len(st.LastAddresses))
+	for cn, addr := range st.LastAddresses {
+		r.lastAddrs[cn] = addr
+	}
+}
+
+// Resume restores a saved state and then resynchronizes the registry with
+// the client list retrieved from the given client, calling OnChange for
+// the clients that have connected, changed or disconnected in the
+// meantime.
+func (r *ClientRegistry) Resume(client *MgmtClient, st RegistryState) error {
+	r.RestoreState(st)
+	list, err := client.ClientList()
+	if err != nil {
+		return err
+	}
+	r.Sync(list)
+	return nil
+}
+
+// LastAddress returns the tunnel addresses most recently assigned to
+// clients with the given common name, including ones that have since
+// disconnected, and false if none is known. It can be used to give
+// a client the same address when it reconnects.
+func (r *ClientRegistry) LastAddress(cn string) (AssignedAddress, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	addr, ok := r.lastAddrs[cn]
+	return addr, ok
+}
+
+// recordAddressLocked records the addresses of the given client for
+// LastAddress. It must be called with r.mu held.
+func (r *ClientRegistry) recordAddressLocked(cc *ConnectedClient) {
+	if cc.CommonName == "" || (cc.VirtualAddress == "" && cc.VirtualIPv6Address == "") {
+		return
+	}
+	if r.lastAddrs == nil {
+		r.lastAddrs = map[string]AssignedAddress{}
+	}
+	r.lastAddrs[cc.CommonName] = AssignedAddress{
+		VirtualAddress:     cc.VirtualAddress,
+		VirtualIPv6Address: cc.VirtualIPv6Address,
+	}
+}
+
+// SessionCounters are the byte counts of a client connection last added
+// to the usage tracked by Accounting.
+type SessionCounters struct {
+	ClientID      int64
+	CommonName    string
+	BytesReceived int64
+	BytesSent     int64
+}
+
+// SessionCounters returns the byte counts of the client connections in
+// progress, which can be saved along with the usage and restored using
+// RestoreSessionCounters when the managing application restarts, so that
+// data counted before the restart is not counted again.
+func (a *Accounting) SessionCounters() []SessionCounters {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ret := make([]SessionCounters, 0, len(a.sessions))
+	for cid, s := range a.sessions {
+		ret = append(ret, SessionCounters{
+			ClientID:      cid,
+			CommonName:    s.commonName,
+			BytesReceived: s.received,
+			BytesSent:     s.sent,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ClientID < ret[j].ClientID
+	})
+	return ret
+}
+
+// RestoreSessionCounters replaces the byte counts of the client
+// connections in progress with saved ones.
+func (a *Accounting) RestoreSessionCounters(counters []SessionCounters) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessions = make(map[int64]accountingSession, len(counters))
+	for _, c := range counters {
+		a.sessions[c.ClientID] = accountingSession{
+			commonName: c.CommonName,
+			received:   c.BytesReceived,
+			sent:       c.BytesSent,
+		}
+	}
+}
+
+// SessionState is the state of a Session, as returned by
+// Session.SaveState, in a form that can be serialized and restored when
+// the managing application restarts.
+type SessionState struct {
+	State             State
+	Since             time.Time
+	ConnectedSince    time.Time
+	LocalTunnelAddr   string
+	RemoteAddr        string
+	BytesIn, BytesOut int64
+
+	// Reconnects are the times of the reconnections counted in Health
+	// reports.
+	Reconnects []time.Time
+}
+
+// SaveState returns the state of the session. The transition history is
+// not included.
+func (s *Session) SaveState() SessionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SessionState{
+		State:           s.state,
+		Since:           s.since,
+		ConnectedSince:  s.connectedAt,
+		LocalTunnelAddr: s.localAddr,
+		RemoteAddr:      s.remoteAddr,
+		BytesIn:         s.bytesIn,
+		BytesOut:        s.bytesOut,
+		Reconnects:      append([]time.Time(nil), s.reconnects...),
+	}
+}
+
+// RestoreState restores a saved state, which should then be brought up to
+// date by passing the result of MgmtClient.LatestState to HandleEvent.
+// If the tunnel is still in the saved state, the time it entered it is
+// retained, so that for example its uptime covers the restart.
+func (s *Session) RestoreState(st SessionState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = st.State
+	s.since = st.Since
+	s.connectedAt = st.ConnectedSince
+	s.localAddr = st.LocalTunnelAddr
+	s.remoteAddr = st.RemoteAddr
+	s.bytesIn, s.bytesOut = st.BytesIn, st.BytesOut
+	s.reconnects = append([]time.Time(nil), st.Reconnects...)
+	s.notifyLocked()
+}
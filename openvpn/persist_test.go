@@ -0,0 +1,95 @@
+package openvpn
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryState(t *testing.T) {
+	r := &ClientRegistry{}
+	var envs envAssembler
+	for _, raw := range []string{
+		"CLIENT:CONNECT,1,0",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,password=secret",
+		"CLIENT:ENV,END",
+		"CLIENT:ESTABLISHED,1",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,ifconfig_pool_remote_ip=10.8.0.2",
+		"CLIENT:ENV,END",
+		"CLIENT:CONNECT,2,0",
+		"CLIENT:ENV,common_name=bob",
+		"CLIENT:ENV,END",
+	} {
+		if e := envs.push(upgradeEvent([]byte(raw))); e != nil {
+			r.HandleEvent(e)
+		}
+	}
+
+	buf, err := json.Marshal(r.SaveState())
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var st RegistryState
+	if err := json.Unmarshal(buf, &st); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	for _, cc := range st.Clients {
+		if cc.Env != nil {
+			t.Errorf("client %d was saved with its environment", cc.ClientID)
+		}
+	}
+
+	var changes []string
+	restored := &ClientRegistry{OnChange: func(c ClientChange) {
+		changes = append(changes, c.Kind.String()+" "+c.Client.CommonName)
+	}}
+	restored.RestoreState(st)
+	if got := restored.Len(); got != 2 {
+		t.Fatalf("Len returned %d after restoring; want 2", got)
+	}
+	if got, _ := restored.LastAddress("alice"); got.VirtualAddress != "10.8.0.2" {
+		t.Errorf("got last address %+v for alice; want 10.8.0.2", got)
+	}
+
+	// bob went away while the application was down, and carol connected.
+	restored.Sync([]ClientStatus{
+		{ClientID: 1, CommonName: "alice", VirtualAddress: "10.8.0.2"},
+		{ClientID: 3, CommonName: "carol", VirtualAddress: "10.8.0.4"},
+	})
+	if want := []string{"disconnected bob", "connected carol"}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("got changes %q; want %q", changes, want)
+	}
+}
+
+func TestSessionState(t *testing.T) {
+	s := &Session{}
+	for _, raw := range []string{
+		"STATE:100,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"BYTECOUNT:10,20",
+	} {
+		s.HandleEvent(upgradeEvent([]byte(raw)))
+	}
+
+	restored := &Session{}
+	restored.RestoreState(s.SaveState())
+	restored.HandleEvent(upgradeEvent([]byte("STATE:300,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+	snap := restored.Snapshot()
+	if !snap.ConnectedSince.Equal(time.Unix(100, 0)) || snap.BytesIn != 10 || snap.RemoteAddr != "192.0.2.1" {
+		t.Errorf("got snapshot %+v after restoring", snap)
+	}
+}
+
+func TestAccountingSessionCounters(t *testing.T) {
+	a := &Accounting{}
+	a.HandleChange(ClientChange{Kind: ClientUpdated, Client: ConnectedClient{ClientID: 1, CommonName: "alice", BytesReceived: 100, BytesSent: 200}})
+
+	restored := &Accounting{}
+	restored.RestoreSessionCounters(a.SessionCounters())
+	restored.HandleChange(ClientChange{Kind: ClientUpdated, Client: ConnectedClient{ClientID: 1, CommonName: "alice", BytesReceived: 150, BytesSent: 200}})
+	if u, _ := restored.Usage("alice"); u.BytesReceived != 50 || u.BytesSent != 0 {
+		t.Errorf("got usage %+v after restoring; want only the data since", u)
+	}
+}
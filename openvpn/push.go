@@ -0,0 +1,148 @@
+package openvpn
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ClientConfig is a set of directives to apply to a client of an OpenVPN
+// server, as would otherwise be written to its client-config-dir file.
+// It is applied when accepting the client with AcceptConfig.
+//
+// Fields left at their zero value produce no directives.
+type ClientConfig struct {
+	// Address and Netmask are the client's IPv4 address and netmask
+	// within the tunnel, set with ifconfig-push. For topology net30 or p2p,
+	// Netmask is instead the address of the server end of the tunnel.
+	Address string
+	Netmask string
+
+	// IPv6Address is the client's IPv6 address within the tunnel, with
+	// a prefix length, and IPv6Remote the address of the server end, set
+	// with ifconfig-ipv6-push.
+	IPv6Address string
+	IPv6Remote  string
+
+	// Routes and IPv6Routes are pushed to the client, so that it routes
+	// those networks through the tunnel.
+	Routes     []Route
+	IPv6Routes []string
+
+	// IRoutes are the networks behind the client, set with iroute, so
+	// that the server routes them to it.
+	IRoutes []Route
+
+	// RedirectGateway, if not empty, holds the flags of a pushed
+	// redirect-gateway option, such as "def1 bypass-dhcp".
+	RedirectGateway string
+
+	// DNS and Domain are pushed as dhcp-option DNS and DOMAIN.
+	DNS    []string
+	Domain string
+
+	// Shaper, if not zero, is pushed to limit the rate at which the client
+	// sends data into the tunnel, in bytes per second.
+	Shaper int
+
+	// Push lists other options to push to the client, such as
+	// "ping-restart 60".
+	Push []string
+
+	// Directives lists other directives to apply to the client, such as
+	// "disable" or "push-reset".
+	Directives []string
+}
+
+// Route is a network to be routed through the tunnel. Gateway and Metric
+// are optional.
+type Route struct {
+	Network string
+	Netmask string
+	Gateway string
+	Metric  int
+}
+
+func (r Route) args() string {
+	args := []string{r.Network}
+	if r.Netmask != "" || r.Gateway != "" || r.Metric != 0 {
+		args = append(args, r.Netmask)
+	}
+	if r.Gateway != "" || r.Metric != 0 {
+		args = append(args, r.Gateway)
+	}
+	if r.Metric != 0 {
+		args = append(args, strconv.Itoa(r.Metric))
+	}
+	for i, arg := range args {
+		if arg == "" {
+			args[i] = "default"
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// Lines returns the directives making up the config, in the form expected
+// by MgmtClient.ClientAuth. It fails if a value would break the
+// directive it belongs to, such as by containing a quote or line break.
+func (c ClientConfig) Lines() ([]string, error) {
+	var lines []string
+	add := func(line string) {
+		lines = append(lines, line)
+	}
+	push := func(opt string) {
+		add(`push "` + opt + `"`)
+	}
+
+	if c.Address != "" {
+		add("ifconfig-push " + c.Address + " " + c.Netmask)
+	}
+	if c.IPv6Address != "" {
+		add(strings.TrimSpace("ifconfig-ipv6-push " + c.IPv6Address + " " + c.IPv6Remote))
+	}
+	for _, r := range c.Routes {
+		push("route " + r.args())
+	}
+	for _, r := range c.IPv6Routes {
+		push("route-ipv6 " + r)
+	}
+	for _, r := range c.IRoutes {
+		add("iroute " + r.Network + " " + r.Netmask)
+	}
+	if c.RedirectGateway != "" {
+		push("redirect-gateway " + c.RedirectGateway)
+	}
+	for _, addr := range c.DNS {
+		push("dhcp-option DNS " + addr)
+	}
+	if c.Domain != "" {
+		push("dhcp-option DOMAIN " + c.Domain)
+	}
+	if c.Shaper != 0 {
+		push("shaper " + strconv.Itoa(c.Shaper))
+	}
+	for _, opt := range c.Push {
+		push(opt)
+	}
+	lines = append(lines, c.Directives...)
+
+	for _, line := range lines {
+		if strings.ContainsAny(line, "\r\n") {
+			return nil, fmt.Errorf("client config directive %q contains a line break", line)
+		}
+		if strings.HasPrefix(line, "push ") && strings.Count(line, `"`) != 2 {
+			return nil, fmt.Errorf("pushed option %q contains a quote", line)
+		}
+	}
+	return lines, nil
+}
+
+// AcceptConfig returns a decision allowing the client to connect, applying
+// the given config to it.
+func AcceptConfig(config ClientConfig) (Decision, error) {
+	lines, err := config.Lines()
+	if err != nil {
+		return Decision{}, err
+	}
+	return Accept(lines...), nil
+}
@@ -0,0 +1,65 @@
+package openvpn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClientConfigLines(t *testing.T) {
+	tests := []struct {
+		config ClientConfig
+		want   []string
+		err    bool
+	}{
+		{ClientConfig{}, nil, false},
+		{
+			ClientConfig{
+				Address:         "10.8.0.6",
+				Netmask:         "255.255.255.0",
+				IPv6Address:     "fd00::6/64",
+				IPv6Remote:      "fd00::1",
+				Routes:          []Route{{Network: "192.168.1.0", Netmask: "255.255.255.0"}, {Network: "192.168.2.0", Metric: 10}},
+				IPv6Routes:      []string{"fd01::/64"},
+				IRoutes:         []Route{{Network: "10.10.0.0", Netmask: "255.255.0.0"}},
+				RedirectGateway: "def1",
+				DNS:             []string{"10.8.0.1"},
+				Domain:          "example.com",
+				Shaper:          100000,
+				Push:            []string{"ping-restart 60"},
+				Directives:      []string{"push-reset"},
+			},
+			[]string{
+				"ifconfig-push 10.8.0.6 255.255.255.0",
+				"ifconfig-ipv6-push fd00::6/64 fd00::1",
+				`push "route 192.168.1.0 255.255.255.0"`,
+				`push "route 192.168.2.0 default default 10"`,
+				`push "route-ipv6 fd01::/64"`,
+				"iroute 10.10.0.0 255.255.0.0",
+				`push "redirect-gateway def1"`,
+				`push "dhcp-option DNS 10.8.0.1"`,
+				`push "dhcp-option DOMAIN example.com"`,
+				`push "shaper 100000"`,
+				`push "ping-restart 60"`,
+				"push-reset",
+			},
+			false,
+		},
+		{ClientConfig{Domain: `example.com" x`}, nil, true},
+		{ClientConfig{Directives: []string{"disable\nfoo"}}, nil, true},
+	}
+	for i, test := range tests {
+		got, err := test.config.Lines()
+		if (err != nil) != test.err {
+			t.Errorf("test %d got error %v; want error %v", i, err, test.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+
+	d, err := AcceptConfig(ClientConfig{Address: "10.8.0.6", Netmask: "255.255.255.0"})
+	if err != nil || d.Kind != DecisionAccept || len(d.Config) != 1 {
+		t.Errorf("AcceptConfig returned %+v, %v", d, err)
+	}
+}
@@ -0,0 +1,207 @@
+package openvpn
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRateWindow is the smoothing window used by a RateTracker whose
+// Windows field is empty.
+const DefaultRateWindow = time.Minute
+
+// Rate is the rate of data transfer in each direction, in bytes per
+// second.
+type Rate struct {
+	In, Out float64
+}
+
+// Rates describes the data transfer rates of a tunnel or of one of
+// a server's clients.
+type Rates struct {
+	// ClientID identifies the client, or is -1 for the rates of a tunnel.
+	ClientID int64
+
+	// Current is the rate between the two most recent byte counts.
+	Current Rate
+
+	// Smoothed holds an exponentially-weighted moving average of the rate
+	// for each of the RateTracker's windows, in the same order.
+	Smoothed []Rate
+
+	// BytesIn and BytesOut are the most recent byte counts.
+	BytesIn, BytesOut int64
+
+	// Updated is when the most recent byte count was received.
+	Updated time.Time
+}
+
+// RateTracker calculates data transfer rates from the byte counts reported
+// by ByteCountEvents, both for the tunnel of an OpenVPN client and for each
+// client of an OpenVPN server.
+//
+// In addition to the current rate, it maintains exponentially-weighted
+// moving averages over each of the given windows, so that for example
+// a dashboard can display both the rate over the last few seconds and over
+// the last few minutes. A decrease in a byte count, such as happens when
+// a client reconnects, starts a new series rather than producing
+// a negative rate.
+//
+// The zero value tracks with DefaultRateWindow. A RateTracker is safe for
+// concurrent use.
+type RateTracker struct {
+	// Windows are the periods over which rates are smoothed. They must be
+	// set before the first event is handled.
+	Windows []time.Duration
+
+	mu     sync.Mutex
+	tunnel *rateSeries
+	series map[int64]*rateSeries
+}
+
+type rateSeries struct {
+	rates    Rates
+	smoothed bool
+}
+
+// HandleEvent updates the rates if the given event is a ByteCountEvent,
+// and forgets the rates of a client that has disconnected if it is
+// a DISCONNECT ClientEvent, returning true if it was either. The caller
+// should pass each event received from the client's event channel.
+func (t *RateTracker) HandleEvent(e Event) bool {
+	if ce, ok := e.(*ClientEvent); ok && ce.Type() == "DISCONNECT" {
+		t.Forget(ce.ClientID())
+		return true
+	}
+	bc, ok := e.(*ByteCountEvent)
+	if !ok {
+		return false
+	}
+
+	cid := int64(-1)
+	if bc.hasClient {
+		var ok bool
+		if cid, ok = bc.clientID(); !ok {
+			return true
+		}
+	}
+	t.Update(cid, int64(bc.BytesIn()), int64(bc.BytesOut()), time.Now())
+	return true
+}
+
+// Update records byte counts received at the given time for the given
+// client, or for the tunnel if cid is -1. HandleEvent calls it for each
+// ByteCountEvent, but it can also be called directly with counts obtained
+// by other means, such as from status polls.
+func (t *RateTracker) Update(cid int64, bytesIn, bytesOut int64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var s *rateSeries
+	if cid < 0 {
+		if t.tunnel == nil {
+			t.tunnel = t.newSeries(cid)
+		}
+		s = t.tunnel
+	} else {
+		if t.series == nil {
+			t.series = map[int64]*rateSeries{}
+		}
+		s = t.series[cid]
+		if s == nil {
+			s = t.newSeries(cid)
+			t.series[cid] = s
+		}
+	}
+
+	r := &s.rates
+	elapsed := now.Sub(r.Updated).Seconds()
+	if !r.Updated.IsZero() && elapsed > 0 && bytesIn >= r.BytesIn && bytesOut >= r.BytesOut {
+		r.Current = Rate{
+			In:  float64(bytesIn-r.BytesIn) / elapsed,
+			Out: float64(bytesOut-r.BytesOut) / elapsed,
+		}
+		for i, window := range t.windows() {
+			if !s.smoothed {
+				r.Smoothed[i] = r.Current
+				continue
+			}
+			alpha := 1 - math.Exp(-elapsed/window.Seconds())
+			r.Smoothed[i].In += alpha * (r.Current.In - r.Smoothed[i].In)
+			r.Smoothed[i].Out += alpha * (r.Current.Out - r.Smoothed[i].Out)
+		}
+		s.smoothed = true
+	} else if bytesIn < r.BytesIn || bytesOut < r.BytesOut {
+		// The counters were reset, so there is no meaningful rate until
+		// the next count arrives.
+		r.Current = Rate{}
+		s.smoothed = false
+	}
+	r.BytesIn, r.BytesOut = bytesIn, bytesOut
+	r.Updated = now
+}
+
+func (t *RateTracker) newSeries(cid int64) *rateSeries {
+	return &rateSeries{rates: Rates{
+		ClientID: cid,
+		Smoothed: make([]Rate, len(t.windows())),
+	}}
+}
+
+func (t *RateTracker) windows() []time.Duration {
+	if len(t.Windows) == 0 {
+		return []time.Duration{DefaultRateWindow}
+	}
+	return t.Windows
+}
+
+// Tunnel returns the rates of the tunnel of an OpenVPN client, and false
+// if no byte counts have been received for it.
+func (t *RateTracker) Tunnel() (Rates, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tunnel == nil {
+		return Rates{}, false
+	}
+	return t.tunnel.rates.copy(), true
+}
+
+// Client returns the rates of the given client of an OpenVPN server, and
+// false if no byte counts have been received for it.
+func (t *RateTracker) Client(cid int64) (Rates, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.series[cid]
+	if !ok {
+		return Rates{}, false
+	}
+	return s.rates.copy(), true
+}
+
+// Clients returns the rates of all of the clients of an OpenVPN server for
+// which byte counts have been received, ordered by client id.
+func (t *RateTracker) Clients() []Rates {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ret := make([]Rates, 0, len(t.series))
+	for _, s := range t.series {
+		ret = append(ret, s.rates.copy())
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ClientID < ret[j].ClientID
+	})
+	return ret
+}
+
+// Forget discards the rates of the given client.
+func (t *RateTracker) Forget(cid int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.series, cid)
+}
+
+func (r Rates) copy() Rates {
+	r.Smoothed = append([]Rate(nil), r.Smoothed...)
+	return r
+}
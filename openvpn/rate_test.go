@@ -0,0 +1,82 @@
+package openvpn
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRateTracker(t *testing.T) {
+	tr := &RateTracker{Windows: []time.Duration{time.Second, time.Minute}}
+	start := time.Unix(1000, 0)
+
+	if _, ok := tr.Tunnel(); ok {
+		t.Errorf("Tunnel returned rates before any counts")
+	}
+
+	tests := []struct {
+		offset         time.Duration
+		bytesIn        int64
+		bytesOut       int64
+		wantCurrent    Rate
+		wantFastSmooth Rate
+		wantSlowSmooth Rate
+	}{
+		{0, 0, 0, Rate{}, Rate{}, Rate{}},
+		{time.Second, 1000, 500, Rate{1000, 500}, Rate{1000, 500}, Rate{1000, 500}},
+		{2 * time.Second, 1000, 500, Rate{0, 0}, Rate{1000 / math.E, 500 / math.E}, Rate{1000 * math.Exp(-1.0/60), 500 * math.Exp(-1.0/60)}},
+		// A counter reset starts a new series.
+		{3 * time.Second, 10, 10, Rate{}, Rate{1000 / math.E, 500 / math.E}, Rate{1000 * math.Exp(-1.0/60), 500 * math.Exp(-1.0/60)}},
+		{5 * time.Second, 210, 410, Rate{100, 200}, Rate{100, 200}, Rate{100, 200}},
+	}
+
+	for i, test := range tests {
+		tr.Update(-1, test.bytesIn, test.bytesOut, start.Add(test.offset))
+		r, ok := tr.Tunnel()
+		if !ok {
+			t.Fatalf("test %d Tunnel returned no rates", i)
+		}
+		if !closeRate(r.Current, test.wantCurrent) {
+			t.Errorf("test %d got current %v; want %v", i, r.Current, test.wantCurrent)
+		}
+		if !closeRate(r.Smoothed[0], test.wantFastSmooth) {
+			t.Errorf("test %d got fast smoothed %v; want %v", i, r.Smoothed[0], test.wantFastSmooth)
+		}
+		if !closeRate(r.Smoothed[1], test.wantSlowSmooth) {
+			t.Errorf("test %d got slow smoothed %v; want %v", i, r.Smoothed[1], test.wantSlowSmooth)
+		}
+	}
+}
+
+func TestRateTrackerClients(t *testing.T) {
+	var tr RateTracker
+	for _, raw := range []string{
+		"BYTECOUNT_CLI:2,100,100",
+		"BYTECOUNT_CLI:1,100,100",
+		"BYTECOUNT:1,1",
+	} {
+		if !tr.HandleEvent(upgradeEvent([]byte(raw))) {
+			t.Errorf("HandleEvent rejected %q", raw)
+		}
+	}
+
+	clients := tr.Clients()
+	if len(clients) != 2 || clients[0].ClientID != 1 || clients[1].ClientID != 2 {
+		t.Errorf("got clients %+v; want clients 1 and 2", clients)
+	}
+	if r, ok := tr.Client(2); !ok || r.BytesIn != 100 || len(r.Smoothed) != 1 {
+		t.Errorf("got client 2 rates %+v; want 100 bytes in with one window", r)
+	}
+	if r, ok := tr.Tunnel(); !ok || r.ClientID != -1 {
+		t.Errorf("got tunnel rates %+v; want client id -1", r)
+	}
+
+	tr.HandleEvent(upgradeEvent([]byte("CLIENT:DISCONNECT,2")))
+	if _, ok := tr.Client(2); ok {
+		t.Errorf("client 2 rates retained after disconnect")
+	}
+}
+
+func closeRate(a, b Rate) bool {
+	return math.Abs(a.In-b.In) < 1e-6 && math.Abs(a.Out-b.Out) < 1e-6
+}
@@ -0,0 +1,432 @@
+package openvpn
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConnectedClient describes a client connected to an OpenVPN server, as
+// tracked by a ClientRegistry.
+type ConnectedClient struct {
+	ClientID           int64
+	CommonName         string
+	Username           string
+	RealAddress        string
+	VirtualAddress     string
+	VirtualIPv6Address string
+	BytesReceived      int64
+	BytesSent          int64
+	ConnectedSince     time.Time
+
+	// LastActivity is when the registry last saw the client's byte counts
+	// change, or when it first learned of the client.
+	LastActivity time.Time
+
+	// Established is true once OpenVPN has reported that the client's
+	// connection is fully established, or once the client has appeared in
+	// a status poll.
+	Established bool
+
+	// Env is the environment from the most recent client event, or nil
+	// if the client is known only from a status poll.
+	Env Env
+}
+
+// ClientChangeKind is the kind of change described by a ClientChange.
+type ClientChangeKind int
+
+const (
+	ClientConnected ClientChangeKind = iota
+	ClientUpdated
+	ClientDisconnected
+)
+
+func (k ClientChangeKind) String() string {
+	switch k {
+	case ClientConnected:
+		return "connected"
+	case ClientUpdated:
+		return "updated"
+	case ClientDisconnected:
+		return "disconnected"
+	default:
+		return "ClientChangeKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// The reasons given by ClientChange.Reason for a client disconnecting.
+const (
+	DisconnectReported = "disconnect"
+	DisconnectMissing  = "missing"
+)
+
+// ClientChange is a notification of a change to the clients known to
+// a ClientRegistry. Client describes the client after the change, or as
+// it was last known if it has disconnected.
+type ClientChange struct {
+	Kind   ClientChangeKind
+	Client ConnectedClient
+
+	// Reason is why the client disconnected, for a ClientDisconnected
+	// change: DisconnectReported if the server reported it, or
+	// DisconnectMissing if the client was missing from a status poll.
+	Reason string
+
+	// duplicates are the duplicates detected as a result of the change,
+	// and float the float it applied, if any.
+	duplicates []*DuplicateClientEvent
+	float      *PeerFloatEvent
+}
+
+// ClientRegistry maintains a view of the clients connected to an OpenVPN
+// server by combining the ClientEvents the server reports with periodic
+// polls of its status, which fill in details that the events lack and
+// correct for any events that were missed.
+//
+// The server must be running with --management-client-auth for it to
+// report client events. Per-client byte counts are kept up to date
+// between polls if ByteCountEvents are also enabled.
+//
+// The zero value is an empty registry, ready to use. A ClientRegistry is
+// safe for concurrent use.
+type ClientRegistry struct {
+	// OnChange, if set, is called for each change to the registry. It is
+	// called from the goroutine that caused the change, without any locks
+	// held, so calls may be concurrent if events are handled concurrently
+	// with a poll.
+	OnChange func(ClientChange)
+
+	// OnDuplicate, if set, is called when a client connects with the same
+	// common name as another client already connected from a different
+	// real address, such as to enforce a policy forbidding the sharing of
+	// credentials. It is called in the same way as OnChange, after OnChange
+	// has been called for the change revealing the duplicate.
+	OnDuplicate func(*DuplicateClientEvent)
+
+	// OnFloat, if set, is called when a client floats to a different real
+	// address, as reported by a PeerFloatEvent or detected by Sync. It is
+	// called in the same way as OnChange, after OnChange has been called
+	// for the change updating the client's address.
+	OnFloat func(*PeerFloatEvent)
+
+	mu        sync.Mutex
+	clients   map[int64]*ConnectedClient
+	lastAddrs map[string]AssignedAddress
+
+	// The indexes map the values of fields to the ids of the clients with
+	// those values, and are maintained by reindexLocked.
+	byCommonName map[string]map[int64]struct{}
+	byVirtual    map[string]int64
+	byReal       map[string]int64
+}
+
+// HandleEvent updates the registry if the given event is a ClientEvent,
+// a per-client ByteCountEvent or a PeerFloatEvent, returning true if it
+// was. The caller
+// should pass each event received from the client's event channel.
+func (r *ClientRegistry) HandleEvent(e Event) bool {
+	var changes []ClientChange
+	switch e := e.(type) {
+	case *ClientEvent:
+		changes = r.clientEvent(e)
+	case *ByteCountEvent:
+		if !e.hasClient {
+			return false
+		}
+		changes = r.byteCountEvent(e)
+	case *PeerFloatEvent:
+		changes = r.peerFloat(e)
+	default:
+		return false
+	}
+	r.notify(changes)
+	return true
+}
+
+func (r *ClientRegistry) clientEvent(e *ClientEvent) []ClientChange {
+	cid := e.ClientID()
+	if cid < 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.clients == nil {
+		r.clients = map[int64]*ConnectedClient{}
+	}
+	cc, known := r.clients[cid]
+
+	switch e.Type() {
+	case "CONNECT", "REAUTH", "ESTABLISHED":
+		kind := ClientUpdated
+		if !known {
+			now := time.Now()
+			cc = &ConnectedClient{ClientID: cid, ConnectedSince: now, LastActivity: now}
+			r.clients[cid] = cc
+			kind = ClientConnected
+		}
+		before := *cc
+		cc.updateFromEnv(e.Env())
+		if e.Type() == "ESTABLISHED" {
+			cc.Established = true
+		}
+		r.recordAddressLocked(cc)
+		r.reindexLocked(&before, cc)
+		return []ClientChange{{Kind: kind, Client: *cc, duplicates: r.duplicatesLocked(&before, cc)}}
+
+	case "ADDRESS":
+		if !known {
+			return nil
+		}
+		before := *cc
+		addr, _ := e.Address()
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+			cc.VirtualIPv6Address = addr
+		} else {
+			cc.VirtualAddress = addr
+		}
+		r.recordAddressLocked(cc)
+		r.reindexLocked(&before, cc)
+		return []ClientChange{{Kind: ClientUpdated, Client: *cc}}
+
+	case "DISCONNECT":
+		if !known {
+			return nil
+		}
+		r.reindexLocked(cc, nil)
+		cc.updateFromEnv(e.Env())
+		delete(r.clients, cid)
+		return []ClientChange{{Kind: ClientDisconnected, Client: *cc, Reason: DisconnectReported}}
+	}
+	return nil
+}
+
+func (r *ClientRegistry) byteCountEvent(e *ByteCountEvent) []ClientChange {
+	cid, ok := e.clientID()
+	if !ok {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cc, known := r.clients[cid]
+	if !known {
+		return nil
+	}
+	received, sent := int64(e.BytesIn()), int64(e.BytesOut())
+	if received != cc.BytesReceived || sent != cc.BytesSent {
+		cc.LastActivity = time.Now()
+	}
+	cc.BytesReceived, cc.BytesSent = received, sent
+	return []ClientChange{{Kind: ClientUpdated, Client: *cc}}
+}
+
+// updateFromEnv populates the client's details from the environment of
+// a client event, leaving unchanged any that the environment lacks.
+func (cc *ConnectedClient) updateFromEnv(env Env) {
+	if env == nil {
+		return
+	}
+	cc.Env = env
+	if v := env.Get("common_name"); v != "" {
+		cc.CommonName = v
+	}
+	if v := env.Get("username"); v != "" {
+		cc.Username = v
+	}
+	if ip := env.Get("untrusted_ip"); ip != "" {
+		cc.RealAddress = net.JoinHostPort(ip, env.Get("untrusted_port"))
+	} else if ip := env.Get("untrusted_ip6"); ip != "" {
+		cc.RealAddress = net.JoinHostPort(ip, env.Get("untrusted_port"))
+	}
+	if v := env.Get("ifconfig_pool_remote_ip"); v != "" {
+		cc.VirtualAddress = v
+	}
+	if v := env.Get("ifconfig_pool_remote_ip6"); v != "" {
+		cc.VirtualIPv6Address = v
+	}
+	if v, err := strconv.ParseInt(env.Get("bytes_received"), 10, 64); err == nil {
+		cc.BytesReceived = v
+	}
+	if v, err := strconv.ParseInt(env.Get("bytes_sent"), 10, 64); err == nil {
+		cc.BytesSent = v
+	}
+	if v, err := strconv.ParseInt(env.Get("time_unix"), 10, 64); err == nil {
+		cc.ConnectedSince = time.Unix(v, 0)
+	}
+}
+
+// Sync reconciles the registry with a client list retrieved from the
+// server, as returned by MgmtClient.ClientList: clients missing from the
+// registry are added, the details of known clients are updated, and
+// clients no longer listed are removed.
+//
+// A client that has sent CONNECT but is not yet fully established does
+// not appear in the server's client list, so it is retained.
+func (r *ClientRegistry) Sync(list []ClientStatus) {
+	var changes []ClientChange
+
+	r.mu.Lock()
+	if r.clients == nil {
+		r.clients = map[int64]*ConnectedClient{}
+	}
+	listed := make(map[int64]bool, len(list))
+	for _, cs := range list {
+		listed[cs.ClientID] = true
+		cc, known := r.clients[cs.ClientID]
+		if !known {
+			cc = &ConnectedClient{ClientID: cs.ClientID}
+			r.clients[cs.ClientID] = cc
+		}
+		before := *cc
+		cc.updateFromStatus(cs)
+		var float *PeerFloatEvent
+		if known {
+			if float = statusFloat(&before, cs); float != nil {
+				cc.RealAddress = float.To
+			}
+		}
+		if !known || cc.BytesReceived != before.BytesReceived || cc.BytesSent != before.BytesSent {
+			cc.LastActivity = time.Now()
+		}
+		r.recordAddressLocked(cc)
+		r.reindexLocked(&before, cc)
+		dups := r.duplicatesLocked(&before, cc)
+		switch {
+		case !known:
+			changes = append(changes, ClientChange{Kind: ClientConnected, Client: *cc, duplicates: dups})
+		case !before.sameStatus(cc):
+			changes = append(changes, ClientChange{Kind: ClientUpdated, Client: *cc, duplicates: dups, float: float})
+		}
+	}
+	for cid, cc := range r.clients {
+		if !listed[cid] && cc.Established {
+			r.reindexLocked(cc, nil)
+			delete(r.clients, cid)
+			changes = append(changes, ClientChange{Kind: ClientDisconnected, Client: *cc, Reason: DisconnectMissing})
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Client.ClientID < changes[j].Client.ClientID
+	})
+	r.notify(changes)
+}
+
+func (cc *ConnectedClient) updateFromStatus(cs ClientStatus) {
+	cc.Established = true
+	if cs.CommonName != "" {
+		cc.CommonName = cs.CommonName
+	}
+	if cs.Username != "" {
+		cc.Username = cs.Username
+	}
+	if cc.RealAddress == "" {
+		cc.RealAddress = cs.RealAddress
+	}
+	if cs.VirtualAddress != "" {
+		cc.VirtualAddress = cs.VirtualAddress
+	}
+	if cs.VirtualIPv6Address != "" {
+		cc.VirtualIPv6Address = cs.VirtualIPv6Address
+	}
+	cc.BytesReceived = cs.BytesReceived
+	cc.BytesSent = cs.BytesSent
+	if !cs.ConnectedSince.IsZero() {
+		cc.ConnectedSince = cs.ConnectedSince
+	}
+}
+
+// sameStatus reports whether the fields a status poll may change are
+// equal in both clients.
+func (cc *ConnectedClient) sameStatus(other *ConnectedClient) bool {
+	return cc.Established == other.Established &&
+		cc.CommonName == other.CommonName &&
+		cc.Username == other.Username &&
+		cc.RealAddress == other.RealAddress &&
+		cc.VirtualAddress == other.VirtualAddress &&
+		cc.VirtualIPv6Address == other.VirtualIPv6Address &&
+		cc.BytesReceived == other.BytesReceived &&
+		cc.BytesSent == other.BytesSent &&
+		cc.ConnectedSince.Equal(other.ConnectedSince)
+}
+
+// Poll calls Sync with the client list retrieved from the given client
+// immediately and then at the given interval, until ctx is cancelled or
+// the client list cannot be retrieved. It returns the error that caused
+// it to stop.
+func (r *ClientRegistry) Poll(ctx context.Context, client *MgmtClient, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		list, err := client.ClientList()
+		if err != nil {
+			return err
+		}
+		r.Sync(list)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clients returns all of the clients currently known to the registry,
+// ordered by client id.
+func (r *ClientRegistry) Clients() []ConnectedClient {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret := make([]ConnectedClient, 0, len(r.clients))
+	for _, cc := range r.clients {
+		ret = append(ret, *cc)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ClientID < ret[j].ClientID
+	})
+	return ret
+}
+
+// Client returns the client with the given client id, if it is known.
+func (r *ClientRegistry) Client(cid int64) (ConnectedClient, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cc, ok := r.clients[cid]
+	if !ok {
+		return ConnectedClient{}, false
+	}
+	return *cc, true
+}
+
+// Len returns the number of clients currently known to the registry.
+func (r *ClientRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.clients)
+}
+
+func (r *ClientRegistry) notify(changes []ClientChange) {
+	for _, change := range changes {
+		if r.OnChange != nil {
+			r.OnChange(change)
+		}
+		if r.OnDuplicate != nil {
+			for _, dup := range change.duplicates {
+				r.OnDuplicate(dup)
+			}
+		}
+		if r.OnFloat != nil && change.float != nil {
+			r.OnFloat(change.float)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package openvpn
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClientRegistry(t *testing.T) {
+	var changes []string
+	r := &ClientRegistry{OnChange: func(c ClientChange) {
+		changes = append(changes, c.Kind.String()+" "+c.Client.CommonName)
+	}}
+
+	var envs envAssembler
+	for _, raw := range []string{
+		"CLIENT:CONNECT,1,0",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,untrusted_ip=192.0.2.10",
+		"CLIENT:ENV,untrusted_port=50000",
+		"CLIENT:ENV,END",
+		"CLIENT:ESTABLISHED,1",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,ifconfig_pool_remote_ip=10.8.0.2",
+		"CLIENT:ENV,time_unix=1682931600",
+		"CLIENT:ENV,END",
+		"CLIENT:ADDRESS,1,fd00::2,1",
+		"BYTECOUNT_CLI:1,100,200",
+		"CLIENT:CONNECT,2,0",
+		"CLIENT:ENV,common_name=bob",
+		"CLIENT:ENV,END",
+		"BYTECOUNT:5,6",
+	} {
+		if e := envs.push(upgradeEvent([]byte(raw))); e != nil {
+			r.HandleEvent(e)
+		}
+	}
+
+	alice, ok := r.Client(1)
+	if !ok {
+		t.Fatalf("client 1 not found")
+	}
+	want := ConnectedClient{
+		ClientID:           1,
+		CommonName:         "alice",
+		RealAddress:        "192.0.2.10:50000",
+		VirtualAddress:     "10.8.0.2",
+		VirtualIPv6Address: "fd00::2",
+		BytesReceived:      100,
+		BytesSent:          200,
+		ConnectedSince:     time.Unix(1682931600, 0),
+		Established:        true,
+	}
+	if alice.LastActivity.IsZero() {
+		t.Errorf("client has no last activity time")
+	}
+	alice.Env = nil
+	alice.LastActivity = time.Time{}
+	if !reflect.DeepEqual(alice, want) {
+		t.Errorf("wrong client\ngot  %+v\nwant %+v", alice, want)
+	}
+
+	// bob is still authenticating, so is not yet in the status list, and
+	// carol was connected before we started watching events.
+	r.Sync([]ClientStatus{
+		{ClientID: 1, CommonName: "alice", VirtualAddress: "10.8.0.2", BytesReceived: 150, BytesSent: 250, ConnectedSince: time.Unix(1682931600, 0)},
+		{ClientID: 3, CommonName: "carol", RealAddress: "192.0.2.12:40000"},
+	})
+	if got := r.Len(); got != 3 {
+		t.Errorf("Len returned %d after first sync; want 3", got)
+	}
+
+	r.Sync([]ClientStatus{
+		{ClientID: 3, CommonName: "carol", RealAddress: "192.0.2.12:40000"},
+	})
+	r.HandleEvent(upgradeEvent([]byte("CLIENT:DISCONNECT,2")))
+
+	var cns []string
+	for _, cc := range r.Clients() {
+		cns = append(cns, cc.CommonName)
+	}
+	if want := []string{"carol"}; !reflect.DeepEqual(cns, want) {
+		t.Errorf("got clients %q; want %q", cns, want)
+	}
+
+	wantChanges := []string{
+		"connected alice",
+		"updated alice",
+		"updated alice",
+		"updated alice",
+		"connected bob",
+		"updated alice",
+		"connected carol",
+		"disconnected alice",
+		"disconnected bob",
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("wrong changes\ngot  %q\nwant %q", changes, wantChanges)
+	}
+}
@@ -0,0 +1,66 @@
+package openvpn
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/NordSecurity/gopenvpn/demux"
+)
+
+// byteCountEvents recycles the ByteCountEvents passed to ReleaseEvent, by
+// far the most numerous events from a busy server.
+var byteCountEvents = sync.Pool{
+	New: func() any { return new(ByteCountEvent) },
+}
+
+// ReleaseEvent returns the buffer holding the message of an event received
+// from a MgmtClient to a pool, for reuse by later events, along with the
+// event itself. It suits long-running managers handling thousands of
+// events per second, for example from a server with many clients sending
+// BYTECOUNT_CLI events, which would otherwise spend much of their time in
+// the garbage collector.
+//
+// An event received on the client's event channel belongs to the receiver,
+// which may keep it for as long as it likes, so releasing it is optional.
+// Once it is released, though, neither the caller nor anything the caller
+// passed it to may use it again, since it will be overwritten by a later
+// event. The values its methods returned beforehand remain valid. The
+// HandleEvent methods of this package's types, such as those of
+// ClientRegistry, RateTracker and Session, don't retain ByteCountEvents,
+// so an event may be released once they return; but an EventBus delivers
+// events to its subscribers asynchronously, so events published to a bus
+// must not be released.
+//
+// Only ByteCountEvents are currently recycled; other events are left to
+// the garbage collector.
+func ReleaseEvent(e Event) {
+	switch e := e.(type) {
+	case *ByteCountEvent:
+		if e.scratch {
+			return
+		}
+		demux.Release(e.body)
+		*e = ByteCountEvent{}
+		byteCountEvents.Put(e)
+	}
+}
+
+// CopyEvent returns an event equivalent to e that remains valid after e
+// has been passed to ReleaseEvent, for keeping an event that is also
+// passed on to consumers that may release it. Events that ReleaseEvent
+// doesn't recycle are returned as they are.
+func CopyEvent(e Event) Event {
+	if e, ok := e.(*ByteCountEvent); ok {
+		return &ByteCountEvent{hasClient: e.hasClient, body: bytes.Clone(e.body)}
+	}
+	return e
+}
+
+// newByteCountEvent returns a ByteCountEvent for the given message body,
+// reusing a released one if possible.
+func newByteCountEvent(hasClient bool, body []byte) *ByteCountEvent {
+	e := byteCountEvents.Get().(*ByteCountEvent)
+	e.hasClient = hasClient
+	e.body = body
+	return e
+}
@@ -0,0 +1,106 @@
+package openvpn
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReleaseEvent(t *testing.T) {
+	tests := []struct {
+		input        string
+		wantClientId string
+		wantBytesIn  int
+		wantBytesOut int
+	}{
+		{"BYTECOUNT_CLI:1,2,3", "1", 2, 3},
+		{"BYTECOUNT:4,5", "", 4, 5},
+		{"BYTECOUNT_CLI:6,7", "6", 7, 0},
+		{"BYTECOUNT_CLI:8,9,10", "8", 9, 10},
+		{"BYTECOUNT:", "", 0, 0},
+	}
+
+	// Each event is parsed after the previous one has been released, so
+	// may reuse it, and must not be affected by it.
+	for i, test := range tests {
+		event := upgradeEvent([]byte(test.input))
+		bc, ok := event.(*ByteCountEvent)
+		if !ok {
+			t.Errorf("test %d got %T; want %T", i, event, bc)
+			continue
+		}
+		if got, want := bc.ClientId(), test.wantClientId; got != want {
+			t.Errorf("test %d ClientId returned %q; want %q", i, got, want)
+		}
+		if got, want := bc.BytesIn(), test.wantBytesIn; got != want {
+			t.Errorf("test %d BytesIn returned %d; want %d", i, got, want)
+		}
+		if got, want := bc.BytesOut(), test.wantBytesOut; got != want {
+			t.Errorf("test %d BytesOut returned %d; want %d", i, got, want)
+		}
+		ReleaseEvent(bc)
+	}
+
+	// Events that aren't recycled are unaffected.
+	state := upgradeEvent([]byte("STATE:1,CONNECTED,SUCCESS,10.8.0.2,1.2.3.4"))
+	ReleaseEvent(state)
+	if got, want := state.String(), "CONNECTED: 1.2.3.4"; got != want {
+		t.Errorf("released StateEvent String returned %q; want %q", got, want)
+	}
+}
+
+func TestLazyEnvRelease(t *testing.T) {
+	var dec eventDecoder
+	lines := []string{
+		"CLIENT:CONNECT,1,2",
+		"CLIENT:ENV,common_name=alice",
+		"CLIENT:ENV,untrusted_ip=10.0.0.1",
+		"CLIENT:ENV,END",
+	}
+	var got []Event
+	var envBufs [][]byte
+	for _, line := range lines {
+		buf := []byte(line)
+		got = append(got, dec.decode(buf)...)
+		if bytes.HasPrefix(buf, []byte("CLIENT:ENV,")) {
+			envBufs = append(envBufs, buf)
+		}
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d events; want 1", len(got))
+	}
+	ce, ok := got[0].(*ClientEvent)
+	if !ok {
+		t.Fatalf("got %T; want %T", got[0], ce)
+	}
+
+	// The environment is parsed when it is first asked for, after which
+	// the buffers of its variables are released, so may be overwritten
+	// by later messages.
+	env := ce.Env()
+	for _, buf := range envBufs {
+		copy(buf, bytes.Repeat([]byte("x"), len(buf)))
+	}
+	if got, want := env.Get("common_name"), "alice"; got != want {
+		t.Errorf("common_name is %q; want %q", got, want)
+	}
+	if got, want := ce.Env().Get("untrusted_ip"), "10.0.0.1"; got != want {
+		t.Errorf("untrusted_ip is %q; want %q", got, want)
+	}
+	if got, want := ce.ClientID(), int64(1); got != want {
+		t.Errorf("ClientID returned %d; want %d", got, want)
+	}
+}
+
+func TestCopyEvent(t *testing.T) {
+	e := upgradeEvent([]byte("BYTECOUNT_CLI:3,10,20"))
+	copied := CopyEvent(e)
+	ReleaseEvent(e)
+	if got, want := copied.String(), "Client 3: 10 in, 20 out"; got != want {
+		t.Errorf("copied event is %q after release; want %q", got, want)
+	}
+
+	hold := &HoldEvent{body: []byte("hold")}
+	if CopyEvent(hold) != Event(hold) {
+		t.Errorf("CopyEvent copied an event that isn't recycled")
+	}
+}
@@ -0,0 +1,490 @@
+package openvpn
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultReconnectWindow is the period over which reconnections are
+// counted in a Health report when Session.ReconnectWindow is zero.
+const DefaultReconnectWindow = 10 * time.Minute
+
+// DefaultHistoryLimit is the number of transitions kept by a Session whose
+// HistoryLimit is zero.
+const DefaultHistoryLimit = 1000
+
+// readErrorMessage is the message of the synthetic FatalEvent emitted by
+// the client when its connection fails.
+const readErrorMessage = "Error reading from OpenVPN"
+
+// State is an OpenVPN connection state, as reported by StateEvent.NewState.
+type State string
+
+// The connection states reported by OpenVPN. See the OpenVPN management
+// interface documentation for the meaning of each.
+const (
+	StateConnecting   State = "CONNECTING"
+	StateResolve      State = "RESOLVE"
+	StateTCPConnect   State = "TCP_CONNECT"
+	StateWait         State = "WAIT"
+	StateAuth         State = "AUTH"
+	StateAuthPending  State = "AUTH_PENDING"
+	StateGetConfig    State = "GET_CONFIG"
+	StateAssignIP     State = "ASSIGN_IP"
+	StateAddRoutes    State = "ADD_ROUTES"
+	StateConnected    State = "CONNECTED"
+	StateReconnecting State = "RECONNECTING"
+	StateExiting      State = "EXITING"
+)
+
+// connectSequence lists the states OpenVPN passes through on its way to
+// CONNECTED, in order. Any of them may be skipped, depending on the
+// configuration, but they are never visited out of order.
+var connectSequence = []State{
+	StateConnecting,
+	StateResolve,
+	StateTCPConnect,
+	StateWait,
+	StateAuth,
+	StateAuthPending,
+	StateGetConfig,
+	StateAssignIP,
+	StateAddRoutes,
+	StateConnected,
+}
+
+// Transition records a change of connection state observed by a Session.
+type Transition struct {
+	From, To State
+
+	// Time is when the transition happened, according to the timestamp
+	// OpenVPN gave the state event, or when the event was received if
+	// that timestamp could not be parsed.
+	Time time.Time
+
+	// Event is the state event reporting the transition.
+	Event *StateEvent
+
+	// Legal is false if OpenVPN is not expected to move directly between
+	// the two states, which may indicate missed events.
+	Legal bool
+}
+
+// Session tracks the connection state of an OpenVPN process from the
+// StateEvents it reports, so that applications don't have to re-derive it
+// from the raw events.
+//
+// State events must be enabled using SetStateEvents for the session to be
+// kept up to date. Calling LatestState and passing the result to
+// HandleEvent provides the initial state.
+//
+// The zero value is a Session in no state, ready to use. A Session is
+// safe for concurrent use.
+type Session struct {
+	// Client is the management client used by methods that control the
+	// tunnel, such as Disconnect and Reconnect. It is not needed merely to
+	// track the tunnel's state.
+	Client *MgmtClient
+
+	// OnIllegalTransition, if set, is called with each transition that
+	// OpenVPN is not expected to make. The transition is applied
+	// regardless. It is called from the goroutine calling HandleEvent,
+	// without any locks held.
+	OnIllegalTransition func(Transition)
+
+	// ReconnectWindow is the period over which reconnections are counted
+	// in Health reports. If zero, DefaultReconnectWindow is used.
+	ReconnectWindow time.Duration
+
+	// HistoryLimit is the number of most recent transitions kept for
+	// History, so that a tunnel that keeps reconnecting doesn't use ever
+	// more memory. If zero, DefaultHistoryLimit is used.
+	HistoryLimit int
+
+	mu            sync.Mutex
+	state         State
+	since         time.Time
+	history       []Transition
+	evicted       int // transitions discarded from the start of history
+	mgmtConnected bool
+	lastEvent     time.Time
+	lastByteCount time.Time
+	reconnects    []time.Time
+	lastErr       error
+	connectedAt   time.Time
+	bytesIn       int64
+	bytesOut      int64
+	localAddr     string
+	remoteAddr    string
+	tunnel        TunnelAddresses
+	dns           DNSConfig
+	dataChannel   DataChannel
+
+	// attemptFailed is true if a recognized connection failure has been
+	// reported since the tunnel last connected, in which case lastErr is
+	// not overwritten by less specific errors.
+	attemptFailed bool
+
+	hooks     []*transitionHook
+	analytics sessionAnalytics
+
+	// changed is closed and replaced whenever the state changes or the
+	// management connection is lost, to wake up waiters.
+	changed chan struct{}
+}
+
+// HandleEvent updates the session from the given event, returning true if
+// it was a StateEvent. The caller should pass each event received from the
+// client's event channel.
+//
+// Events of all types are taken into account in Health reports, but only
+// StateEvents change the session's state. An event reporting the state the
+// session is already in is not considered a transition, and so is ignored.
+func (s *Session) HandleEvent(e Event) bool {
+	s.mu.Lock()
+	now := time.Now()
+	s.mgmtConnected = true
+	s.lastEvent = now
+	switch e := e.(type) {
+	case *ByteCountEvent:
+		if !e.hasClient {
+			s.lastByteCount = now
+			s.bytesIn, s.bytesOut = int64(e.BytesIn()), int64(e.BytesOut())
+		}
+	case *FatalEvent:
+		s.setErrLocked(errors.New(string(e.body)))
+		if string(e.body) == readErrorMessage {
+			s.mgmtConnected = false
+			s.notifyLocked()
+		}
+	case *LogEvent:
+		// Not reset on reconnecting, since DCO is only logged when the
+		// tunnel device is opened, which --persist-tun avoids.
+		s.dataChannel.update(e)
+	case *UpDownEvent:
+		if e.Direction() == "UP" {
+			s.tunnel.updateFromEnv(e.Env())
+			s.dns = ParseDNSConfig(e.Env())
+		}
+	}
+	if err := ClassifyEvent(e); err != nil {
+		s.lastErr = err
+		s.attemptFailed = true
+	}
+
+	se, ok := e.(*StateEvent)
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+
+	// Addresses are taken even from a repeated state, so that they are
+	// brought up to date after RestoreState.
+	s.tunnel.updateFromState(se)
+	to := State(se.NewState())
+	if to == s.state {
+		s.mu.Unlock()
+		return true
+	}
+	t := Transition{
+		From:  s.state,
+		To:    to,
+		Time:  stateEventTime(se),
+		Event: se,
+		Legal: legalTransition(s.state, to),
+	}
+	s.state = to
+	s.since = t.Time
+	s.appendHistoryLocked(t)
+	s.analytics.update(t)
+	if addr := se.LocalTunnelAddr(); addr != "" {
+		s.localAddr = addr
+	}
+	switch to {
+	case StateReconnecting:
+		s.pruneReconnectsLocked(now)
+		s.reconnects = append(s.reconnects, now)
+		countReconnect()
+		if reason := se.Description(); reason != "" {
+			s.setErrLocked(fmt.Errorf("reconnecting: %s", reason))
+		}
+	case StateConnected:
+		s.attemptFailed = false
+		s.connectedAt = t.Time
+		s.remoteAddr = se.RemoteAddr()
+	}
+	if to == StateReconnecting || to == StateExiting {
+		s.connectedAt = time.Time{}
+		s.localAddr, s.remoteAddr = "", ""
+		s.tunnel = TunnelAddresses{}
+		s.dns = DNSConfig{}
+	}
+	hooks := s.matchingHooksLocked(t)
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	if !t.Legal && s.OnIllegalTransition != nil {
+		s.OnIllegalTransition(t)
+	}
+	for _, fn := range hooks {
+		fn(t)
+	}
+	return true
+}
+
+type transitionHook struct {
+	from, to State
+	fn       func(Transition)
+}
+
+// RegisterTransitionHook arranges for fn to be called whenever the session
+// moves from one given state to the other, for example to engage a kill
+// switch as soon as a connected tunnel starts reconnecting. Either state
+// may be empty to match any state. It returns a function that removes the
+// hook.
+//
+// Hooks are called by HandleEvent for the event reporting the transition,
+// after the session has been updated and before HandleEvent returns, so
+// they see the transitions in the order OpenVPN reported them and finish
+// before the next event is handled. Hooks matching the same transition are
+// called in the order they were registered, without any locks held, so
+// they may call the session's methods. Hooks registered or removed while
+// a transition is being handled take effect from the next transition.
+func (s *Session) RegisterTransitionHook(from, to State, fn func(Transition)) (remove func()) {
+	h := &transitionHook{from: from, to: to, fn: fn}
+	s.mu.Lock()
+	s.hooks = append(s.hooks, h)
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, other := range s.hooks {
+			if other == h {
+				s.hooks = append(s.hooks[:i:i], s.hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (s *Session) matchingHooksLocked(t Transition) []func(Transition) {
+	var ret []func(Transition)
+	for _, h := range s.hooks {
+		if (h.from == "" || h.from == t.From) && (h.to == "" || h.to == t.To) {
+			ret = append(ret, h.fn)
+		}
+	}
+	return ret
+}
+
+// State returns the current connection state, or the empty string if no
+// state has been reported yet.
+func (s *Session) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Since returns the time the session entered its current state.
+func (s *Session) Since() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.since
+}
+
+// Connected reports whether the session is currently in the CONNECTED
+// state.
+func (s *Session) Connected() bool {
+	return s.State() == StateConnected
+}
+
+// History returns the transitions observed so far, oldest first, up to
+// HistoryLimit of the most recent.
+func (s *Session) History() []Transition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Transition(nil), s.history...)
+}
+
+// IllegalTransitions returns the transitions among History that OpenVPN
+// is not expected to make, oldest first.
+func (s *Session) IllegalTransitions() []Transition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ret []Transition
+	for _, t := range s.history {
+		if !t.Legal {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// appendHistoryLocked adds a transition to the history, discarding the
+// oldest if it is full. It must be called with s.mu held.
+func (s *Session) appendHistoryLocked(t Transition) {
+	limit := s.HistoryLimit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	if len(s.history) >= limit {
+		// Shift rather than reslice, so that the array doesn't grow.
+		n := copy(s.history, s.history[len(s.history)-limit+1:])
+		clear(s.history[n:])
+		s.evicted += len(s.history) - n
+		s.history = s.history[:n]
+	}
+	s.history = append(s.history, t)
+}
+
+// pruneReconnectsLocked discards the reconnections that happened before
+// the reconnect window. It must be called with s.mu held.
+func (s *Session) pruneReconnectsLocked(now time.Time) {
+	window := s.ReconnectWindow
+	if window <= 0 {
+		window = DefaultReconnectWindow
+	}
+	n := 0
+	for n < len(s.reconnects) && now.Sub(s.reconnects[n]) > window {
+		n++
+	}
+	if n > 0 {
+		s.reconnects = append(s.reconnects[:0], s.reconnects[n:]...)
+	}
+}
+
+// setErrLocked records an unclassified error, unless a more specific one
+// has already been recorded for the current connection attempt. It must be
+// called with s.mu held.
+func (s *Session) setErrLocked(err error) {
+	if !s.attemptFailed {
+		s.lastErr = err
+	}
+}
+
+// Run handles each event received from events until the channel is
+// closed, and then records that the management connection has been lost.
+// It is a convenience for applications that dedicate an event channel to
+// the session.
+func (s *Session) Run(events <-chan Event) {
+	for e := range events {
+		s.HandleEvent(e)
+	}
+	s.mu.Lock()
+	s.mgmtConnected = false
+	s.notifyLocked()
+	s.mu.Unlock()
+}
+
+// Health is a report on the health of a tunnel, as returned by
+// Session.Health.
+type Health struct {
+	// ManagementConnected is true if events have been received from the
+	// management interface and the connection has not since been lost.
+	ManagementConnected bool
+
+	// State is the current connection state, and StateSince is when the
+	// tunnel entered it.
+	State      State
+	StateSince time.Time
+
+	// SinceLastEvent and SinceLastByteCount are the times since any event
+	// and since a ByteCountEvent for the tunnel were last received, or
+	// zero if none have been.
+	SinceLastEvent     time.Duration
+	SinceLastByteCount time.Duration
+
+	// RecentReconnects counts the times the tunnel entered the
+	// RECONNECTING state within the session's ReconnectWindow.
+	RecentReconnects int
+
+	// LastError describes the most recent problem reported by OpenVPN,
+	// such as a fatal error or the reason for a reconnection, if any.
+	// Recognized failures are reported as a *ConnectionError wrapping
+	// one of ErrAuthFailed, ErrTLSHandshake and so on.
+	LastError error
+}
+
+// Ready reports whether the management interface is connected and the
+// tunnel is in the CONNECTED state, as is typically required by
+// a readiness probe.
+func (h Health) Ready() bool {
+	return h.ManagementConnected && h.State == StateConnected
+}
+
+// Health returns a report on the health of the tunnel.
+func (s *Session) Health() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.pruneReconnectsLocked(now)
+
+	h := Health{
+		ManagementConnected: s.mgmtConnected,
+		State:               s.state,
+		StateSince:          s.since,
+		RecentReconnects:    len(s.reconnects),
+		LastError:           s.lastErr,
+	}
+	if !s.lastEvent.IsZero() {
+		h.SinceLastEvent = now.Sub(s.lastEvent)
+	}
+	if !s.lastByteCount.IsZero() {
+		h.SinceLastByteCount = now.Sub(s.lastByteCount)
+	}
+	return h
+}
+
+// legalTransition reports whether OpenVPN may move directly from one
+// state to another. Moves involving states this package doesn't know
+// about are assumed to be legal.
+func legalTransition(from, to State) bool {
+	if from == "" {
+		return true
+	}
+	if from == StateExiting {
+		return false
+	}
+	if to == StateReconnecting || to == StateExiting {
+		return true
+	}
+
+	fromIdx, toIdx := sequenceIndex(from), sequenceIndex(to)
+	switch {
+	case toIdx == -1:
+		return true
+	case from == StateReconnecting:
+		return true
+	case fromIdx == -1:
+		return true
+	case from == StateAuthPending && to == StateAuth:
+		// The client may resend credentials after a pending
+		// authentication.
+		return true
+	}
+	return toIdx > fromIdx
+}
+
+func sequenceIndex(state State) int {
+	for i, s := range connectSequence {
+		if s == state {
+			return i
+		}
+	}
+	return -1
+}
+
+// stateEventTime returns the time a state event was reported, falling back
+// on the current time if its timestamp is missing or malformed.
+func stateEventTime(e *StateEvent) time.Time {
+	secs, err := strconv.ParseInt(e.RawTimestamp(), 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Now()
+	}
+	return time.Unix(secs, 0)
+}
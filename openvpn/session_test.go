@@ -0,0 +1,207 @@
+package openvpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	var illegal []Transition
+	s := &Session{OnIllegalTransition: func(t Transition) {
+		illegal = append(illegal, t)
+	}}
+
+	if s.HandleEvent(&HoldEvent{body: []byte("hold")}) {
+		t.Errorf("HandleEvent accepted a HoldEvent")
+	}
+
+	for _, raw := range []string{
+		"STATE:100,CONNECTING,,,",
+		"STATE:101,WAIT,,,",
+		"STATE:101,WAIT,,,",
+		"STATE:102,AUTH,,,",
+		"STATE:103,GET_CONFIG,,,",
+		"STATE:104,ASSIGN_IP,,10.8.0.2,",
+		"STATE:105,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"STATE:200,RECONNECTING,ping-restart,,",
+		"STATE:201,WAIT,,,",
+		"STATE:202,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"STATE:203,AUTH,,,",
+		"STATE:204,EXITING,SIGTERM,,",
+	} {
+		if !s.HandleEvent(upgradeEvent([]byte(raw))) {
+			t.Fatalf("HandleEvent rejected %q", raw)
+		}
+	}
+
+	var got []State
+	for _, tr := range s.History() {
+		got = append(got, tr.To)
+	}
+	want := []State{
+		StateConnecting, StateWait, StateAuth, StateGetConfig, StateAssignIP, StateConnected,
+		StateReconnecting, StateWait, StateConnected, StateAuth, StateExiting,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got history %q; want %q", got, want)
+	}
+
+	if len(illegal) != 1 || illegal[0].From != StateConnected || illegal[0].To != StateAuth {
+		t.Errorf("got illegal transitions %v; want CONNECTED to AUTH", illegal)
+	}
+	if got := s.IllegalTransitions(); len(got) != 1 {
+		t.Errorf("IllegalTransitions returned %d transitions; want 1", len(got))
+	}
+	if got, want := s.State(), StateExiting; got != want {
+		t.Errorf("State returned %q; want %q", got, want)
+	}
+	if got, want := s.Since(), time.Unix(204, 0); !got.Equal(want) {
+		t.Errorf("Since returned %v; want %v", got, want)
+	}
+	if s.Connected() {
+		t.Errorf("Connected returned true after EXITING")
+	}
+}
+
+func TestLegalTransition(t *testing.T) {
+	tests := []struct {
+		from, to State
+		want     bool
+	}{
+		{"", StateConnected, true},
+		{StateConnecting, StateResolve, true},
+		{StateResolve, StateConnecting, false},
+		{StateWait, StateConnected, true},
+		{StateConnected, StateReconnecting, true},
+		{StateConnected, StateWait, false},
+		{StateReconnecting, StateTCPConnect, true},
+		{StateAuthPending, StateAuth, true},
+		{StateExiting, StateConnecting, false},
+		{StateConnected, "FUTURE_STATE", true},
+	}
+	for i, test := range tests {
+		if got := legalTransition(test.from, test.to); got != test.want {
+			t.Errorf("test %d (%s to %s) got %v; want %v", i, test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestSessionHealth(t *testing.T) {
+	s := &Session{}
+	if h := s.Health(); h.ManagementConnected || h.Ready() || h.SinceLastEvent != 0 {
+		t.Errorf("got %+v before any events; want an empty report", h)
+	}
+
+	events := make(chan Event, 10)
+	for _, raw := range []string{
+		"STATE:100,CONNECTING,,,",
+		"STATE:105,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"STATE:200,RECONNECTING,ping-restart,,",
+		"STATE:205,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"BYTECOUNT:10,20",
+	} {
+		events <- upgradeEvent([]byte(raw))
+	}
+	for len(events) > 0 {
+		s.HandleEvent(<-events)
+	}
+
+	h := s.Health()
+	if !h.Ready() || h.State != StateConnected || !h.StateSince.Equal(time.Unix(205, 0)) {
+		t.Errorf("got %+v; want ready in CONNECTED since 205", h)
+	}
+	if h.RecentReconnects != 1 {
+		t.Errorf("got %d recent reconnects; want 1", h.RecentReconnects)
+	}
+	if !errors.Is(h.LastError, ErrPingRestart) {
+		t.Errorf("got last error %v; want ErrPingRestart", h.LastError)
+	}
+	if h.SinceLastByteCount <= 0 || h.SinceLastByteCount > time.Minute {
+		t.Errorf("got %s since last byte count; want a small positive duration", h.SinceLastByteCount)
+	}
+
+	s.ReconnectWindow = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if got := s.Health().RecentReconnects; got != 0 {
+		t.Errorf("got %d recent reconnects outside the window; want 0", got)
+	}
+
+	events <- upgradeEvent(readErrSynthEvent())
+	close(events)
+	s.Run(events)
+	h = s.Health()
+	if h.ManagementConnected || h.Ready() {
+		t.Errorf("got %+v after the connection closed; want not connected", h)
+	}
+	if h.LastError == nil || h.LastError.Error() != readErrorMessage {
+		t.Errorf("got last error %v; want %q", h.LastError, readErrorMessage)
+	}
+}
+
+func readErrSynthEvent() []byte {
+	return []byte("FATAL:" + readErrorMessage)
+}
+
+func TestSessionTransitionHooks(t *testing.T) {
+	s := &Session{}
+	var calls []string
+	s.RegisterTransitionHook(StateConnected, StateReconnecting, func(tr Transition) {
+		calls = append(calls, "kill switch "+tr.Event.Description())
+	})
+	remove := s.RegisterTransitionHook("", StateConnected, func(tr Transition) {
+		calls = append(calls, "connected from "+string(tr.From))
+		if s.State() != StateConnected {
+			t.Errorf("hook saw state %q; want the session already updated", s.State())
+		}
+	})
+
+	for _, raw := range []string{
+		"STATE:1,WAIT,,,",
+		"STATE:2,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"STATE:3,RECONNECTING,ping-restart,,",
+		"STATE:4,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+	} {
+		s.HandleEvent(upgradeEvent([]byte(raw)))
+		if raw == "STATE:3,RECONNECTING,ping-restart,," {
+			remove()
+		}
+	}
+
+	want := []string{"connected from WAIT", "kill switch ping-restart"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got hook calls %q; want %q", calls, want)
+	}
+}
+
+func TestSessionHistoryLimit(t *testing.T) {
+	s := &Session{HistoryLimit: 3}
+	for i, state := range []string{"CONNECTING", "WAIT", "AUTH", "GET_CONFIG", "ASSIGN_IP"} {
+		s.HandleEvent(upgradeEvent([]byte(fmt.Sprintf("STATE:%d,%s,,,", 100+i, state))))
+	}
+
+	var got []State
+	for _, tr := range s.History() {
+		got = append(got, tr.To)
+	}
+	if want := []State{StateAuth, StateGetConfig, StateAssignIP}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got history %q; want %q", got, want)
+	}
+	if got := s.transitionCount(); got != 5 {
+		t.Errorf("transitionCount returned %d; want 5", got)
+	}
+
+	// Waiting from an index already discarded skips to the oldest kept.
+	tr, i, err := s.waitTransition(context.Background(), "test", 0, func(Transition) bool { return true })
+	if err != nil || i != 2 || tr.To != StateAuth {
+		t.Errorf("waitTransition returned %v, %d, %v; want AUTH, 2, nil", tr.To, i, err)
+	}
+	s.HandleEvent(upgradeEvent([]byte("STATE:105,CONNECTED,SUCCESS,10.8.0.2,")))
+	tr, i, err = s.waitTransition(context.Background(), "test", 5, func(Transition) bool { return true })
+	if err != nil || i != 5 || tr.To != StateConnected {
+		t.Errorf("waitTransition returned %v, %d, %v; want CONNECTED, 5, nil", tr.To, i, err)
+	}
+}
@@ -0,0 +1,57 @@
+package openvpn
+
+import "time"
+
+// Snapshot is the status of a tunnel at a point in time, as returned by
+// Session.Snapshot. It is a copy, so it doesn't change as the session is
+// updated.
+type Snapshot struct {
+	// State is the current connection state.
+	State State
+
+	// LocalTunnelAddr is the address of the local interface within the
+	// tunnel, and RemoteAddr the address of the remote system, as reported
+	// by OpenVPN. They are empty unless the tunnel is connected or being
+	// assigned an address.
+	LocalTunnelAddr string
+	RemoteAddr      string
+
+	// BytesIn and BytesOut are the most recent byte counts reported for the
+	// tunnel, which requires byte count events to be enabled using
+	// SetByteCountEvents.
+	BytesIn, BytesOut int64
+
+	// ConnectedSince is when the tunnel last connected, and Uptime is the
+	// time since then. Both are zero unless the tunnel is connected.
+	ConnectedSince time.Time
+	Uptime         time.Duration
+
+	// LastError is as described for Health.
+	LastError error
+
+	// LastEvent is when an event was last received, or the zero time if
+	// none has been.
+	LastEvent time.Time
+}
+
+// Snapshot returns the current status of the tunnel in a single call, for
+// use by status displays.
+func (s *Session) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := Snapshot{
+		State:           s.state,
+		LocalTunnelAddr: s.localAddr,
+		RemoteAddr:      s.remoteAddr,
+		BytesIn:         s.bytesIn,
+		BytesOut:        s.bytesOut,
+		LastError:       s.lastErr,
+		LastEvent:       s.lastEvent,
+	}
+	if s.state == StateConnected {
+		snap.ConnectedSince = s.connectedAt
+		snap.Uptime = time.Since(s.connectedAt)
+	}
+	return snap
+}
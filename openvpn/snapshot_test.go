@@ -0,0 +1,50 @@
+package openvpn
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSessionSnapshot(t *testing.T) {
+	s := &Session{}
+	connected := time.Now().Add(-time.Hour).Unix()
+	for _, raw := range []string{
+		"STATE:100,CONNECTING,,,",
+		"STATE:101,ASSIGN_IP,,10.8.0.2,",
+		"STATE:" + strconv.FormatInt(connected, 10) + ",CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+		"BYTECOUNT:1024,2048",
+		"BYTECOUNT_CLI:3,5,6",
+	} {
+		s.HandleEvent(upgradeEvent([]byte(raw)))
+	}
+
+	snap := s.Snapshot()
+	if snap.State != StateConnected {
+		t.Errorf("got state %q; want %q", snap.State, StateConnected)
+	}
+	if snap.LocalTunnelAddr != "10.8.0.2" || snap.RemoteAddr != "192.0.2.1" {
+		t.Errorf("got addresses %q and %q; want 10.8.0.2 and 192.0.2.1", snap.LocalTunnelAddr, snap.RemoteAddr)
+	}
+	if snap.BytesIn != 1024 || snap.BytesOut != 2048 {
+		t.Errorf("got byte counts %d and %d; want 1024 and 2048", snap.BytesIn, snap.BytesOut)
+	}
+	if !snap.ConnectedSince.Equal(time.Unix(connected, 0)) {
+		t.Errorf("got connected since %v; want %v", snap.ConnectedSince, time.Unix(connected, 0))
+	}
+	if snap.Uptime < time.Hour || snap.Uptime > time.Hour+time.Minute {
+		t.Errorf("got uptime %v; want about an hour", snap.Uptime)
+	}
+	if snap.LastEvent.IsZero() {
+		t.Errorf("got zero last event time")
+	}
+
+	s.HandleEvent(upgradeEvent([]byte("STATE:200,RECONNECTING,ping-restart,,")))
+	snap = s.Snapshot()
+	if snap.LocalTunnelAddr != "" || snap.RemoteAddr != "" || snap.Uptime != 0 || !snap.ConnectedSince.IsZero() {
+		t.Errorf("got %+v; want no addresses or uptime while reconnecting", snap)
+	}
+	if snap.LastError == nil {
+		t.Errorf("got no last error after reconnecting")
+	}
+}
@@ -0,0 +1,158 @@
+package openvpn
+
+import (
+	"bytes"
+	"time"
+)
+
+var (
+	statusHeaderKW   = []byte("HEADER")
+	statusClientList = []byte("CLIENT_LIST")
+	statusUndef      = []byte("UNDEF")
+)
+
+// ClientStatus describes a client connected to an OpenVPN server, as
+// listed in the server's status output.
+type ClientStatus struct {
+	CommonName         string
+	RealAddress        string
+	VirtualAddress     string
+	VirtualIPv6Address string
+	BytesReceived      int64
+	BytesSent          int64
+	ConnectedSince     time.Time
+	Username           string
+	ClientID           int64
+	PeerID             int
+
+	// Cipher is the data channel cipher, reported only by OpenVPN 2.5 and
+	// later.
+	Cipher string
+}
+
+// ClientList retrieves the list of clients connected to an OpenVPN server.
+func (c *MgmtClient) ClientList() ([]ClientStatus, error) {
+	var ret []ClientStatus
+	err := c.EachClient(func(cs ClientStatus) error {
+		ret = append(ret, cs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// EachClient retrieves the list of clients connected to an OpenVPN server
+// like ClientList, but passes each client to fn as its line of the status
+// output is received, so that a controller polling a server with tens of
+// thousands of clients needn't hold the whole list in memory.
+//
+// If fn returns an error then it is not called again, and EachClient
+// returns the error once the rest of the status has been read.
+func (c *MgmtClient) EachClient(fn func(ClientStatus) error) error {
+	var p ClientListParser
+	return c.StreamStatus(StatusFormatV3, func(line []byte) error {
+		if cs, ok := p.ParseLine(line); ok {
+			return fn(cs)
+		}
+		return nil
+	})
+}
+
+// ParseClientList extracts the client list from the lines of status output
+// returned by LatestStatus when called with StatusFormatV2 or
+// StatusFormatV3, as described for ClientListParser.
+func ParseClientList(lines [][]byte) []ClientStatus {
+	var p ClientListParser
+	var ret []ClientStatus
+	for _, line := range lines {
+		if cs, ok := p.ParseLine(line); ok {
+			ret = append(ret, cs)
+		}
+	}
+	return ret
+}
+
+// ClientListParser extracts the client list from status output one line at
+// a time, in either StatusFormatV2 or StatusFormatV3, for use with
+// StreamStatus. The zero value is ready to parse the first line.
+//
+// The columns are identified using the header line OpenVPN sends before
+// the list, so that fields added or reordered in other versions of OpenVPN
+// are handled correctly. Fields that cannot be parsed are left as their
+// zero values, as are those OpenVPN reports as "UNDEF".
+type ClientListParser struct {
+	columns map[string]int
+
+	// sep is the field separator, learned from the first header line.
+	sep byte
+
+	// fields holds the fields of the current line, and is reused for each
+	// line to avoid allocating.
+	fields [][]byte
+}
+
+// ParseLine parses the next line of status output, returning the client
+// it describes and true if it is an entry in the client list. The line is
+// not retained.
+func (p *ClientListParser) ParseLine(line []byte) (ClientStatus, bool) {
+	if bytes.HasPrefix(line, statusHeaderKW) && len(line) > len(statusHeaderKW) {
+		if sep := line[len(statusHeaderKW)]; sep == '\t' || sep == ',' {
+			p.sep = sep
+		}
+	}
+	if p.sep == 0 {
+		return ClientStatus{}, false
+	}
+
+	p.fields = splitFields(p.fields[:0], line, p.sep)
+	fields := p.fields
+	switch {
+	case len(fields) > 1 && bytes.Equal(fields[0], statusHeaderKW) && bytes.Equal(fields[1], statusClientList):
+		p.columns = map[string]int{}
+		for i, name := range fields[2:] {
+			p.columns[string(name)] = i + 1
+		}
+	case bytes.Equal(fields[0], statusClientList) && p.columns != nil:
+		cs := ClientStatus{
+			CommonName:         string(p.field("Common Name")),
+			RealAddress:        string(p.field("Real Address")),
+			VirtualAddress:     string(p.field("Virtual Address")),
+			VirtualIPv6Address: string(p.field("Virtual IPv6 Address")),
+			Username:           string(p.field("Username")),
+			Cipher:             string(p.field("Data Channel Cipher")),
+		}
+		cs.BytesReceived, _ = parseDecimal(p.field("Bytes Received"))
+		cs.BytesSent, _ = parseDecimal(p.field("Bytes Sent"))
+		cs.ClientID, _ = parseDecimal(p.field("Client ID"))
+		peerID, _ := parseDecimal(p.field("Peer ID"))
+		cs.PeerID = int(peerID)
+		if secs, ok := parseDecimal(p.field("Connected Since (time_t)")); ok {
+			cs.ConnectedSince = time.Unix(secs, 0)
+		}
+		return cs, true
+	}
+	return ClientStatus{}, false
+}
+
+// field returns the named field of the current line, or nil if it is
+// missing or "UNDEF".
+func (p *ClientListParser) field(name string) []byte {
+	if i, ok := p.columns[name]; ok && i < len(p.fields) && !bytes.Equal(p.fields[i], statusUndef) {
+		return p.fields[i]
+	}
+	return nil
+}
+
+// splitFields appends the fields of line separated by sep to dst.
+func splitFields(dst [][]byte, line []byte, sep byte) [][]byte {
+	for {
+		idx := bytes.IndexByte(line, sep)
+		if idx == -1 {
+			return append(dst, line)
+		}
+		dst = append(dst, line[:idx])
+		line = line[idx+1:]
+	}
+}
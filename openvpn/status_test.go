@@ -0,0 +1,147 @@
+package openvpn
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClientList(t *testing.T) {
+	status := "TITLE\tOpenVPN 2.6.3 x86_64-pc-linux-gnu\n" +
+		"TIME\t2023-05-01 10:00:00\t1682935200\n" +
+		"HEADER\tCLIENT_LIST\tCommon Name\tReal Address\tVirtual Address\tVirtual IPv6 Address\tBytes Received\tBytes Sent\tConnected Since\tConnected Since (time_t)\tUsername\tClient ID\tPeer ID\tData Channel Cipher\n" +
+		"CLIENT_LIST\talice\t192.0.2.10:50000\t10.8.0.2\t\t1000\t2000\t2023-05-01 09:00:00\t1682931600\tUNDEF\t3\t0\tAES-256-GCM\n" +
+		"CLIENT_LIST\tbob\t192.0.2.11:50001\t10.8.0.3\tfd00::3\t10\t20\t2023-05-01 09:30:00\t1682933400\tbob\t7\t1\tCHACHA20-POLY1305\n" +
+		"HEADER\tROUTING_TABLE\tVirtual Address\tCommon Name\tReal Address\tLast Ref\tLast Ref (time_t)\n" +
+		"ROUTING_TABLE\t10.8.0.2\talice\t192.0.2.10:50000\t2023-05-01 10:00:00\t1682935200\n" +
+		"GLOBAL_STATS\tMax bcast/mcast queue length\t0"
+	lines := bytes.Split([]byte(status), []byte("\n"))
+
+	got := ParseClientList(lines)
+	want := []ClientStatus{
+		{
+			CommonName:     "alice",
+			RealAddress:    "192.0.2.10:50000",
+			VirtualAddress: "10.8.0.2",
+			BytesReceived:  1000,
+			BytesSent:      2000,
+			ConnectedSince: time.Unix(1682931600, 0),
+			ClientID:       3,
+			Cipher:         "AES-256-GCM",
+		},
+		{
+			CommonName:         "bob",
+			RealAddress:        "192.0.2.11:50001",
+			VirtualAddress:     "10.8.0.3",
+			VirtualIPv6Address: "fd00::3",
+			BytesReceived:      10,
+			BytesSent:          20,
+			ConnectedSince:     time.Unix(1682933400, 0),
+			Username:           "bob",
+			ClientID:           7,
+			PeerID:             1,
+			Cipher:             "CHACHA20-POLY1305",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong client list\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseClientListV2(t *testing.T) {
+	status := "TITLE,OpenVPN 2.6.3 x86_64-pc-linux-gnu\n" +
+		"TIME,2023-05-01 10:00:00,1682935200\n" +
+		"HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID\n" +
+		"CLIENT_LIST,alice,192.0.2.10:50000,10.8.0.2,,1000,2000,2023-05-01 09:00:00,1682931600,UNDEF,3,0\n" +
+		"HEADER,ROUTING_TABLE,Virtual Address,Common Name,Real Address,Last Ref,Last Ref (time_t)\n" +
+		"ROUTING_TABLE,10.8.0.2,alice,192.0.2.10:50000,2023-05-01 10:00:00,1682935200\n" +
+		"GLOBAL_STATS,Max bcast/mcast queue length,0"
+	lines := bytes.Split([]byte(status), []byte("\n"))
+
+	got := ParseClientList(lines)
+	want := []ClientStatus{
+		{
+			CommonName:     "alice",
+			RealAddress:    "192.0.2.10:50000",
+			VirtualAddress: "10.8.0.2",
+			BytesReceived:  1000,
+			BytesSent:      2000,
+			ConnectedSince: time.Unix(1682931600, 0),
+			ClientID:       3,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong client list\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestEachClient(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	events := make(chan Event, 10)
+	client := NewClient(conn, events)
+	go func() {
+		for range events {
+		}
+	}()
+
+	const numClients = 1000
+	go func() {
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			var reply strings.Builder
+			switch cmd := scanner.Text(); cmd {
+			case "status 3":
+				reply.WriteString("TITLE\tOpenVPN 2.6.3\n")
+				reply.WriteString("HEADER\tCLIENT_LIST\tCommon Name\tClient ID\n")
+				for i := 0; i < numClients; i++ {
+					fmt.Fprintf(&reply, "CLIENT_LIST\tclient-%d\t%d\n", i, i)
+				}
+				reply.WriteString("END\n")
+			default:
+				reply.WriteString("SUCCESS: " + cmd + "\n")
+			}
+			if _, err := server.Write([]byte(reply.String())); err != nil {
+				return
+			}
+		}
+	}()
+
+	n := 0
+	err := client.EachClient(func(cs ClientStatus) error {
+		if want := fmt.Sprintf("client-%d", n); cs.CommonName != want || cs.ClientID != int64(n) {
+			t.Errorf("client %d is %q, %d; want %q, %d", n, cs.CommonName, cs.ClientID, want, n)
+		}
+		n++
+		return nil
+	})
+	if err != nil || n != numClients {
+		t.Errorf("EachClient returned %v after %d clients; want nil after %d", err, n, numClients)
+	}
+
+	// An error from the callback stops it being called, but the rest of
+	// the status is still read so that the next command gets its reply.
+	stop := errors.New("stop")
+	n = 0
+	err = client.EachClient(func(cs ClientStatus) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("EachClient returned %v after %d clients; want %v after 1", err, n, stop)
+	}
+	if got, err := client.simpleCommand("echo"); err != nil || string(got) != "echo" {
+		t.Errorf("next command got result %q, %v; want %q", got, err, "echo")
+	}
+
+	list, err := client.ClientList()
+	if err != nil || len(list) != numClients {
+		t.Errorf("ClientList returned %d clients, %v; want %d", len(list), err, numClients)
+	}
+}
@@ -0,0 +1,94 @@
+package openvpn
+
+import (
+	"bytes"
+	"sync"
+)
+
+var passwordAuthTokenPrefix = []byte("Auth-Token:")
+
+// AuthToken returns the token carried by an event reporting that the
+// server pushed an auth-token, if it is one. The token can be given in
+// place of the password when OpenVPN next asks for credentials, such as
+// after a soft reconnect, so that the user need not be asked again.
+func (e *PasswordEvent) AuthToken() (string, bool) {
+	if !bytes.HasPrefix(e.body, passwordAuthTokenPrefix) {
+		return "", false
+	}
+	return string(e.body[len(passwordAuthTokenPrefix):]), true
+}
+
+// TokenStore stores the auth-tokens pushed by servers, by username, for
+// use by a CredentialResponder. Implementations might keep tokens in
+// memory, like MemoryTokenStore, or in a system secret store so that they
+// survive restarts of the application.
+type TokenStore interface {
+	// Token returns the token stored for the given username, or the empty
+	// string if there is none.
+	Token(username string) (string, error)
+
+	SetToken(username, token string) error
+	DeleteToken(username string) error
+}
+
+// MemoryTokenStore is a TokenStore that keeps tokens in memory. The zero
+// value is an empty store, ready to use. It is safe for concurrent use.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func (s *MemoryTokenStore) Token(username string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[username], nil
+}
+
+func (s *MemoryTokenStore) SetToken(username, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens == nil {
+		s.tokens = map[string]string{}
+	}
+	s.tokens[username] = token
+	return nil
+}
+
+func (s *MemoryTokenStore) DeleteToken(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, username)
+	return nil
+}
+
+// handleAuthToken stores a pushed auth-token, or forgets a stored one that
+// the server has rejected, returning true if the event was dealt with.
+func (r *CredentialResponder) handleAuthToken(pe *PasswordEvent) (bool, error) {
+	if token, ok := pe.AuthToken(); ok {
+		if r.Tokens == nil || r.username == "" {
+			return true, nil
+		}
+		return true, r.Tokens.SetToken(r.username, token)
+	}
+	if r.tokenSent && pe.VerificationFailed() && pe.Realm() == "Auth" {
+		// The token has expired or been revoked, so the next request is
+		// answered by the provider instead.
+		r.tokenSent = false
+		return true, r.Tokens.DeleteToken(r.username)
+	}
+	return false, nil
+}
+
+// answerWithToken answers a request for credentials using a stored token,
+// if there is one, returning true if it did.
+func (r *CredentialResponder) answerWithToken(pe *PasswordEvent) (bool, error) {
+	if r.Tokens == nil || r.username == "" || pe.Realm() != "Auth" || !pe.NeedsUsername() {
+		return false, nil
+	}
+	token, err := r.Tokens.Token(r.username)
+	if err != nil || token == "" {
+		return false, err
+	}
+	r.tokenSent = true
+	return true, r.Client.Credentials("Auth", r.username, token)
+}
@@ -0,0 +1,78 @@
+package openvpn
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+)
+
+// TunnelAddresses are the addresses negotiated for the local end of a
+// tunnel, as returned by Session.TunnelAddresses. Fields that are not known
+// are left at their zero value, which is not valid.
+type TunnelAddresses struct {
+	// IPv4 and IPv6 are the addresses of the local interface within the
+	// tunnel, along with the prefix length of the tunnel network. If the
+	// prefix length is not known, the prefix covers only the address.
+	IPv4 netip.Prefix
+	IPv6 netip.Prefix
+
+	// IPv4Peer and IPv6Peer are the addresses of the remote end of the
+	// tunnel, known only for point-to-point topologies such as net30.
+	IPv4Peer netip.Addr
+	IPv6Peer netip.Addr
+}
+
+// TunnelAddresses returns the addresses assigned to the local end of the
+// tunnel, and false if the tunnel is not connected.
+//
+// The addresses are taken from STATE messages, and completed with the
+// netmask and peer addresses from UPDOWN messages if OpenVPN was launched
+// with --management-up-down.
+func (s *Session) TunnelAddresses() (TunnelAddresses, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != StateConnected {
+		return TunnelAddresses{}, false
+	}
+	return s.tunnel, true
+}
+
+// updateFromState records the local tunnel addresses reported in a STATE
+// message, keeping the known prefix length if the address is unchanged.
+func (a *TunnelAddresses) updateFromState(e *StateEvent) {
+	if addr, err := netip.ParseAddr(string(e.field(3))); err == nil && addr != a.IPv4.Addr() {
+		a.IPv4 = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	if ipv6 := e.field(8); ipv6 != nil {
+		if addr, err := netip.ParseAddr(string(ipv6)); err == nil && addr != a.IPv6.Addr() {
+			a.IPv6 = netip.PrefixFrom(addr, addr.BitLen())
+		}
+	}
+}
+
+// updateFromEnv records the tunnel addresses given in the environment of an
+// UPDOWN message.
+func (a *TunnelAddresses) updateFromEnv(env Env) {
+	if addr, err := netip.ParseAddr(env.Get("ifconfig_local")); err == nil {
+		bits := addr.BitLen()
+		if mask, err := netip.ParseAddr(env.Get("ifconfig_netmask")); err == nil && mask.Is4() {
+			if ones, _ := net.IPMask(mask.AsSlice()).Size(); ones > 0 {
+				bits = ones
+			}
+		}
+		a.IPv4 = netip.PrefixFrom(addr, bits)
+	}
+	if addr, err := netip.ParseAddr(env.Get("ifconfig_remote")); err == nil {
+		a.IPv4Peer = addr
+	}
+	if addr, err := netip.ParseAddr(env.Get("ifconfig_ipv6_local")); err == nil {
+		bits, err := strconv.Atoi(env.Get("ifconfig_ipv6_netbits"))
+		if err != nil || bits <= 0 || bits > addr.BitLen() {
+			bits = addr.BitLen()
+		}
+		a.IPv6 = netip.PrefixFrom(addr, bits)
+	}
+	if addr, err := netip.ParseAddr(env.Get("ifconfig_ipv6_remote")); err == nil {
+		a.IPv6Peer = addr
+	}
+}
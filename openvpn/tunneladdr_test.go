@@ -0,0 +1,81 @@
+package openvpn
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestSessionTunnelAddresses(t *testing.T) {
+	tests := []struct {
+		lines  []string
+		want   TunnelAddresses
+		wantOK bool
+	}{
+		{
+			lines: []string{
+				"STATE:1,ASSIGN_IP,,10.8.0.2,,,,",
+			},
+		},
+		{
+			lines: []string{
+				"STATE:1,ASSIGN_IP,,10.8.0.2,,,,",
+				"STATE:2,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1,1194,,,fd00::2",
+			},
+			want: TunnelAddresses{
+				IPv4: netip.MustParsePrefix("10.8.0.2/32"),
+				IPv6: netip.MustParsePrefix("fd00::2/128"),
+			},
+			wantOK: true,
+		},
+		{
+			lines: []string{
+				"STATE:1,ASSIGN_IP,,10.8.0.2,,,,",
+				"UPDOWN:UP",
+				"UPDOWN:ENV,ifconfig_local=10.8.0.2",
+				"UPDOWN:ENV,ifconfig_netmask=255.255.255.0",
+				"UPDOWN:ENV,ifconfig_ipv6_local=fd00::2",
+				"UPDOWN:ENV,ifconfig_ipv6_netbits=64",
+				"UPDOWN:ENV,END",
+				"STATE:2,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1,1194,,,fd00::2",
+			},
+			want: TunnelAddresses{
+				IPv4: netip.MustParsePrefix("10.8.0.2/24"),
+				IPv6: netip.MustParsePrefix("fd00::2/64"),
+			},
+			wantOK: true,
+		},
+		{
+			lines: []string{
+				"UPDOWN:UP",
+				"UPDOWN:ENV,ifconfig_local=10.8.0.6",
+				"UPDOWN:ENV,ifconfig_remote=10.8.0.5",
+				"UPDOWN:ENV,END",
+				"STATE:2,CONNECTED,SUCCESS,10.8.0.6,192.0.2.1,1194,,",
+			},
+			want: TunnelAddresses{
+				IPv4:     netip.MustParsePrefix("10.8.0.6/32"),
+				IPv4Peer: netip.MustParseAddr("10.8.0.5"),
+			},
+			wantOK: true,
+		},
+		{
+			lines: []string{
+				"STATE:2,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1,1194,,",
+				"STATE:3,RECONNECTING,ping-restart,,,,,",
+			},
+		},
+	}
+	for i, test := range tests {
+		var s Session
+		var envs envAssembler
+		for _, line := range test.lines {
+			if e := envs.push(upgradeEvent([]byte(line))); e != nil {
+				s.HandleEvent(e)
+			}
+		}
+		got, ok := s.TunnelAddresses()
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("test %d got %+v, %v; want %+v, %v", i, got, ok, test.want, test.wantOK)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package openvpn
+
+// TunnelHooks allows Go functions to take the place of the up and down
+// scripts that OpenVPN would otherwise run when the tunnel comes up or
+// goes down, for example to configure routing or DNS.
+//
+// OpenVPN reports these transitions to the management interface only when
+// launched with the following option:
+//
+//	--management-up-down
+//
+// Each hook receives the same environment that OpenVPN would pass to the
+// corresponding script, including variables such as ifconfig_local,
+// route_network_N and foreign_option_N. Either hook may be nil.
+//
+// OpenVPN does not wait for the hooks to complete before continuing, so
+// unlike a script they cannot delay the tunnel from becoming active.
+type TunnelHooks struct {
+	OnTunnelUp   func(env Env)
+	OnTunnelDown func(env Env)
+}
+
+// HandleEvent calls the appropriate hook if the given event is an
+// UpDownEvent, returning true if it was. The caller should pass each event
+// received from the client's event channel.
+func (h *TunnelHooks) HandleEvent(e Event) bool {
+	ud, ok := e.(*UpDownEvent)
+	if !ok {
+		return false
+	}
+
+	switch ud.Direction() {
+	case "UP":
+		if h.OnTunnelUp != nil {
+			h.OnTunnelUp(ud.Env())
+		}
+	case "DOWN":
+		if h.OnTunnelDown != nil {
+			h.OnTunnelDown(ud.Env())
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package openvpn
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// WireTap mirrors all of the traffic on a management connection to
+// a writer, so that protocol problems can be reproduced without a packet
+// capture.
+//
+// Each line is written with the time it was sent or received, in RFC 3339
+// format, and a marker for its direction: "<" for lines received from
+// OpenVPN, including replies and events, and ">" for lines sent to it. For
+// example:
+//
+//	2024-05-01T12:00:00.123456789Z > state
+//	2024-05-01T12:00:00.124012345Z < 1714564800,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1,1194,,
+//	2024-05-01T12:00:00.124098765Z < END
+//
+// Passwords are redacted, both in the commands that send them and in the
+// environment of clients authenticating to a server.
+type WireTap struct {
+	mu      sync.Mutex
+	w       io.Writer
+	in, out []byte
+	err     error
+}
+
+// NewWireTap returns a tap writing to w.
+func NewWireTap(w io.Writer) *WireTap {
+	return &WireTap{w: w}
+}
+
+// Conn wraps a connection to the management interface so that its traffic
+// is mirrored by the tap. The result should be passed to NewClient in
+// place of conn, or to Journal.Conn to combine the two.
+func (t *WireTap) Conn(conn io.ReadWriteCloser) io.ReadWriteCloser {
+	return &recordingConn{ReadWriteCloser: conn, record: t.record}
+}
+
+// Err returns the first error encountered while writing to the tap's
+// writer, if any. Once an error occurs, no further traffic is mirrored,
+// but the connection is not otherwise affected.
+func (t *WireTap) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// recordingConn passes the data read from and written to a connection to
+// a function, such as to record it in a WireTap or DebugTrace.
+type recordingConn struct {
+	io.ReadWriteCloser
+	record func(out bool, data []byte, now time.Time)
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	if n > 0 {
+		c.record(false, p[:n], time.Now())
+	}
+	return n, err
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	c.record(true, p, time.Now())
+	return c.ReadWriteCloser.Write(p)
+}
+
+// record writes the lines among the given data, sent to OpenVPN if out is
+// true and received from it otherwise.
+func (t *WireTap) record(out bool, data []byte, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+
+	partial, marker := &t.in, " < "
+	if out {
+		partial, marker = &t.out, " > "
+	}
+	var buf bytes.Buffer
+	splitLines(partial, data, func(line []byte) {
+		buf.WriteString(now.Format(time.RFC3339Nano))
+		buf.WriteString(marker)
+		buf.Write(redactWireLine(out, line))
+		buf.WriteByte('\n')
+	})
+	if buf.Len() > 0 {
+		_, t.err = t.w.Write(buf.Bytes())
+	}
+}
+
+// redactWireLine returns a line sent to OpenVPN if out is true, or
+// received from it otherwise, with any password redacted.
+func redactWireLine(out bool, line []byte) []byte {
+	if out {
+		return []byte(redactCommand(string(line)))
+	}
+	if len(line) > 0 && line[0] == '>' {
+		return append([]byte{'>'}, redactJournalLine(line[1:])...)
+	}
+	return line
+}
+
+// splitLines calls fn with each complete line in data, without its line
+// ending, prepending the incomplete line left in partial by the previous
+// call and leaving any incomplete line at the end of data there in turn.
+// Empty lines are skipped.
+func splitLines(partial *[]byte, data []byte, fn func(line []byte)) {
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx == -1 {
+			*partial = append(*partial, data...)
+			return
+		}
+		line := data[:idx]
+		if len(*partial) > 0 {
+			line = append(*partial, line...)
+			*partial = (*partial)[:0]
+		}
+		data = data[idx+1:]
+
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(line) > 0 {
+			fn(line)
+		}
+	}
+}
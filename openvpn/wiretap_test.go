@@ -0,0 +1,81 @@
+package openvpn
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWireTap(t *testing.T) {
+	var buf bytes.Buffer
+	tap := NewWireTap(&buf)
+	server, conn := net.Pipe()
+	events := make(chan Event, 10)
+	client := NewClient(tap.Conn(conn), events)
+
+	go func() {
+		// Split an event across writes to check that lines are reassembled.
+		server.Write([]byte(">CLIENT:ENV,pass"))
+		server.Write([]byte("word=secret\r\n>CLIENT:ENV,END\r\n"))
+		scanner := bufio.NewScanner(server)
+		for scanner.Scan() {
+			server.Write([]byte("SUCCESS: 'Auth' password entered, but not yet verified\r\n"))
+		}
+	}()
+	<-events
+	if err := client.Credentials("Auth", "", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	server.Close()
+	for range events {
+	}
+	if err := tap.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		fields := strings.SplitN(line, " ", 2)
+		if _, err := time.Parse(time.RFC3339Nano, fields[0]); err != nil {
+			t.Errorf("line %q has malformed timestamp: %s", line, err)
+		}
+		got = append(got, fields[1])
+	}
+	want := []string{
+		"< >CLIENT:ENV,password=[redacted]",
+		"< >CLIENT:ENV,END",
+		`> password "Auth" [redacted]`,
+		"< SUCCESS: 'Auth' password entered, but not yet verified",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong tap output\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestRedactWireLine(t *testing.T) {
+	tests := []struct {
+		out        bool
+		line, want string
+	}{
+		{false, ">PASSWORD:Auth-Token:s3cret", ">PASSWORD:Auth-Token:[redacted]"},
+		{false, ">PASSWORD:Need 'Auth' username/password", ">PASSWORD:Need 'Auth' username/password"},
+		{false, ">CLIENT:ENV,password=s3cret", ">CLIENT:ENV,password=[redacted]"},
+		{false, ">CLIENT:CR_RESPONSE,3,1,MTIzNDU2", ">CLIENT:CR_RESPONSE,[redacted]"},
+		{false, "SUCCESS: ok", "SUCCESS: ok"},
+		{true, `password "Auth" s3cret`, `password "Auth" [redacted]`},
+		{true, `push "auth-token s3cret"`, `push "auth-token [redacted]"`},
+		{true, `push "auth-token-user YWxpY2U="`, `push "auth-token-user [redacted]"`},
+		{true, `push "route 10.0.0.0"`, `push "route 10.0.0.0"`},
+	}
+
+	for i, test := range tests {
+		if got := string(redactWireLine(test.out, []byte(test.line))); got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
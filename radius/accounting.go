@@ -0,0 +1,280 @@
+package radius
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/internal/queue"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// Defaults for the fields of an Accounting.
+const (
+	DefaultTimeout     = 3 * time.Second
+	DefaultMaxAttempts = 3
+	DefaultQueueSize   = 256
+)
+
+// ErrTimeout is reported when a RADIUS server does not respond to
+// a request.
+var ErrTimeout = errors.New("timed out")
+
+// Accounting sends RADIUS accounting requests for the sessions of the
+// clients of an OpenVPN server. The fields must not be changed once it is
+// in use.
+type Accounting struct {
+	// Server is the address of the RADIUS accounting server, such as
+	// "radius.example.com:1813", and Secret the secret shared with it.
+	Server string
+	Secret []byte
+
+	// NASIdentifier and NASIPAddress, if set, identify the OpenVPN server
+	// in each request. RFC 2865 requires at least one of them.
+	NASIdentifier string
+	NASIPAddress  net.IP
+
+	// InterimInterval is how often an Interim-Update is sent for each
+	// session, or zero for none.
+	InterimInterval time.Duration
+
+	// Timeout is how long to wait for a response to each request before
+	// retransmitting it, up to MaxAttempts times in all.
+	Timeout     time.Duration
+	MaxAttempts int
+
+	// QueueSize is the number of requests queued to be sent, beyond which
+	// requests are dropped, as counted by Dropped.
+	QueueSize int
+
+	// OnError, if set, is called when a request could not be sent or was
+	// not acknowledged after all attempts.
+	OnError func(err error)
+
+	pending queue.Queue[attributes]
+
+	mu       sync.Mutex
+	sessions map[int64]*session
+}
+
+// session is a client connection for which a Start has been sent.
+type session struct {
+	id     string
+	client openvpn.ConnectedClient
+}
+
+func (a *Accounting) queue() *queue.Queue[attributes] {
+	return a.pending.Init(a.QueueSize, DefaultQueueSize)
+}
+
+// HandleChange queues the accounting request for a client whose
+// connection has been established, or whose established connection has
+// ended. It never blocks, dropping the request if the queue is full. It
+// can be called from a ClientRegistry's OnChange function.
+func (a *Accounting) HandleChange(c openvpn.ClientChange) {
+	cc := c.Client
+	a.mu.Lock()
+	if a.sessions == nil {
+		a.sessions = map[int64]*session{}
+	}
+	s := a.sessions[cc.ClientID]
+	var attrs attributes
+	switch {
+	case c.Kind == openvpn.ClientDisconnected:
+		if s == nil {
+			break
+		}
+		delete(a.sessions, cc.ClientID)
+		s.client = cc
+		cause := uint32(causeUserRequest)
+		if c.Reason == openvpn.DisconnectMissing {
+			cause = causeLostCarrier
+		}
+		attrs = a.attributes(s, statusStop, time.Now())
+		attrs.addInt(attrAcctTerminateCause, cause)
+	case s != nil:
+		s.client = cc
+	case cc.Established:
+		s = &session{id: sessionID(cc), client: cc}
+		a.sessions[cc.ClientID] = s
+		attrs = a.attributes(s, statusStart, time.Now())
+	}
+	a.mu.Unlock()
+
+	if attrs != nil {
+		a.queue().Add(attrs)
+	}
+}
+
+// Dropped returns the number of requests dropped because the queue was
+// full.
+func (a *Accounting) Dropped() uint64 {
+	return a.pending.Dropped()
+}
+
+// Run sends queued requests, in the order they were queued, and the
+// Interim-Updates, until ctx is cancelled, returning ctx.Err(). Requests
+// still queued are then discarded.
+func (a *Accounting) Run(ctx context.Context) error {
+	queued := a.queue().C()
+	var interim <-chan time.Time
+	if a.InterimInterval > 0 {
+		ticker := time.NewTicker(a.InterimInterval)
+		defer ticker.Stop()
+		interim = ticker.C
+	}
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	var id byte
+	send := func(attrs attributes) {
+		var err error
+		if conn == nil {
+			if conn, err = net.Dial("udp", a.Server); err != nil {
+				conn = nil
+			}
+		}
+		if err == nil {
+			id++
+			err = a.send(ctx, conn, id, attrs)
+		}
+		if err != nil && ctx.Err() == nil && a.OnError != nil {
+			a.OnError(err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case attrs := <-queued:
+			send(attrs)
+		case now := <-interim:
+			for _, attrs := range a.interims(now) {
+				send(attrs)
+			}
+		}
+	}
+}
+
+// interims returns the attributes of the Interim-Updates for the sessions
+// in progress.
+func (a *Accounting) interims(now time.Time) []attributes {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ret := make([]attributes, 0, len(a.sessions))
+	for _, s := range a.sessions {
+		ret = append(ret, a.attributes(s, statusInterim, now))
+	}
+	return ret
+}
+
+// send sends a request, retransmitting it until it is acknowledged as
+// described for MaxAttempts.
+func (a *Accounting) send(ctx context.Context, conn net.Conn, id byte, attrs attributes) error {
+	req, err := accountingRequest(id, attrs, a.Secret)
+	if err != nil {
+		return err
+	}
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	attempts := a.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultMaxAttempts
+	}
+
+	buf := make([]byte, maxPacket)
+	for attempt := 0; attempt < attempts && ctx.Err() == nil; attempt++ {
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			n, err := conn.Read(buf)
+			if isTimeout(err) {
+				break
+			} else if err != nil {
+				return err
+			}
+			// Responses to earlier requests, and forgeries, are ignored.
+			if validResponse(buf[:n], req, a.Secret) {
+				return nil
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("no response from RADIUS server %s: %w", a.Server, ErrTimeout)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// attributes returns the attributes of a request with the given status
+// for a session.
+func (a *Accounting) attributes(s *session, status uint32, now time.Time) attributes {
+	cc := s.client
+	var attrs attributes
+	attrs.addInt(attrAcctStatusType, status)
+	attrs.addString(attrAcctSessionID, s.id)
+	attrs.addString(attrUserName, userName(cc))
+	attrs.addString(attrNASIdentifier, a.NASIdentifier)
+	if ip := a.NASIPAddress.To4(); ip != nil {
+		attrs.add(attrNASIPAddress, ip)
+	}
+	attrs.addInt(attrNASPortType, nasPortTypeVirtual)
+	if ip := net.ParseIP(cc.VirtualAddress).To4(); ip != nil {
+		attrs.add(attrFramedIPAddress, ip)
+	}
+	attrs.addString(attrCallingStationID, cc.RealAddress)
+	attrs.addInt(attrEventTimestamp, uint32(now.Unix()))
+	if status != statusStart {
+		addOctets(&attrs, attrAcctInputOctets, attrAcctInputGigawords, cc.BytesReceived)
+		addOctets(&attrs, attrAcctOutputOctets, attrAcctOutputGigawords, cc.BytesSent)
+		if !cc.ConnectedSince.IsZero() {
+			attrs.addInt(attrAcctSessionTime, uint32(now.Sub(cc.ConnectedSince)/time.Second))
+		}
+	}
+	return attrs
+}
+
+// addOctets adds a byte count, split between an octets attribute holding
+// the low 32 bits and a gigawords attribute holding the rest, as described
+// by RFC 2869 section 5.1.
+func addOctets(attrs *attributes, octets, gigawords byte, n int64) {
+	if n < 0 {
+		n = 0
+	}
+	attrs.addInt(octets, uint32(n))
+	if high := n >> 32; high > 0 {
+		attrs.addInt(gigawords, uint32(min(high, math.MaxUint32)))
+	}
+}
+
+// userName returns the name identifying a client to the RADIUS server,
+// which is its username if it authenticated with one and otherwise its
+// common name.
+func userName(cc openvpn.ConnectedClient) string {
+	if cc.Username != "" {
+		return cc.Username
+	}
+	return cc.CommonName
+}
+
+// sessionID returns a unique Acct-Session-Id for a client connection.
+func sessionID(cc openvpn.ConnectedClient) string {
+	return fmt.Sprintf("%08X-%d", cc.ConnectedSince.Unix(), cc.ClientID)
+}
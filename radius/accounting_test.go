@@ -0,0 +1,171 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestAddOctets(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want []byte
+	}{
+		{1000, []byte{42, 6, 0, 0, 3, 232}},
+		{5<<32 + 7, []byte{42, 6, 0, 0, 0, 7, 52, 6, 0, 0, 0, 5}},
+		{-1, []byte{42, 6, 0, 0, 0, 0}},
+	}
+	for i, test := range tests {
+		var attrs attributes
+		addOctets(&attrs, attrAcctInputOctets, attrAcctInputGigawords, test.n)
+		if !bytes.Equal(attrs, test.want) {
+			t.Errorf("test %d got %v; want %v", i, []byte(attrs), test.want)
+		}
+	}
+}
+
+// request is an accounting request received by fakeServer.
+type request struct {
+	attrs map[byte][]byte
+}
+
+func (r request) int(typ byte) uint32 {
+	if len(r.attrs[typ]) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(r.attrs[typ])
+}
+
+// fakeServer runs a RADIUS accounting server that acknowledges each valid
+// request, except that it ignores the first request with the given
+// status, to test retransmission.
+func fakeServer(t *testing.T, secret []byte, ignore uint32) (string, <-chan request) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP unavailable: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	requests := make(chan request, 10)
+	go func() {
+		buf := make([]byte, maxPacket)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			p := append([]byte(nil), buf[:n]...)
+			h := md5.New()
+			h.Write(p[:4])
+			h.Write(make([]byte, 16))
+			h.Write(p[20:])
+			h.Write(secret)
+			if !bytes.Equal(h.Sum(nil), p[4:20]) {
+				t.Errorf("request has invalid authenticator")
+				continue
+			}
+			req := request{attrs: map[byte][]byte{}}
+			for a := p[20:]; len(a) >= 2; a = a[a[1]:] {
+				req.attrs[a[0]] = a[2:a[1]]
+			}
+			if req.int(attrAcctStatusType) == ignore {
+				ignore = 0
+				continue
+			}
+			requests <- req
+
+			resp := []byte{codeAccountingResponse, p[1], 0, 20}
+			h = md5.New()
+			h.Write(resp)
+			h.Write(p[4:20])
+			h.Write(secret)
+			conn.WriteTo(h.Sum(resp), addr)
+		}
+	}()
+	return conn.LocalAddr().String(), requests
+}
+
+func TestAccounting(t *testing.T) {
+	secret := []byte("s3cret")
+	addr, requests := fakeServer(t, secret, statusStart)
+	a := &Accounting{
+		Server:          addr,
+		Secret:          secret,
+		NASIdentifier:   "vpn1",
+		InterimInterval: 50 * time.Millisecond,
+		Timeout:         50 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx)
+
+	next := func() request {
+		select {
+		case req := <-requests:
+			return req
+		case <-time.After(5 * time.Second):
+			t.Fatal("no request received")
+		}
+		return request{}
+	}
+
+	r := &openvpn.ClientRegistry{OnChange: a.HandleChange}
+	alice := openvpn.ClientStatus{
+		ClientID:       7,
+		CommonName:     "alice",
+		RealAddress:    "203.0.113.7:51820",
+		VirtualAddress: "10.8.0.6",
+		ConnectedSince: time.Now().Add(-time.Minute),
+	}
+	r.Sync([]openvpn.ClientStatus{alice})
+	start := next()
+	if got := start.int(attrAcctStatusType); got != statusStart {
+		t.Fatalf("got status %d; want Start", got)
+	}
+	if string(start.attrs[attrUserName]) != "alice" || string(start.attrs[attrNASIdentifier]) != "vpn1" {
+		t.Errorf("got User-Name %q, NAS-Identifier %q", start.attrs[attrUserName], start.attrs[attrNASIdentifier])
+	}
+	if !net.IP(start.attrs[attrFramedIPAddress]).Equal(net.ParseIP("10.8.0.6")) {
+		t.Errorf("got Framed-IP-Address %v", start.attrs[attrFramedIPAddress])
+	}
+
+	alice.BytesReceived, alice.BytesSent = 1000, 2000
+	r.Sync([]openvpn.ClientStatus{alice})
+	// Interim-Updates sent before the sync have the old counts.
+	interim := next()
+	for interim.int(attrAcctStatusType) == statusInterim && interim.int(attrAcctInputOctets) == 0 {
+		interim = next()
+	}
+	if got := interim.int(attrAcctStatusType); got != statusInterim {
+		t.Fatalf("got status %d; want Interim-Update", got)
+	}
+	if in, out := interim.int(attrAcctInputOctets), interim.int(attrAcctOutputOctets); in != 1000 || out != 2000 {
+		t.Errorf("got octets %d in, %d out; want 1000, 2000", in, out)
+	}
+	if string(interim.attrs[attrAcctSessionID]) != string(start.attrs[attrAcctSessionID]) {
+		t.Errorf("Acct-Session-Id changed from %q to %q", start.attrs[attrAcctSessionID], interim.attrs[attrAcctSessionID])
+	}
+
+	r.Sync(nil)
+	for {
+		req := next()
+		if req.int(attrAcctStatusType) == statusInterim {
+			continue
+		}
+		if got := req.int(attrAcctStatusType); got != statusStop {
+			t.Fatalf("got status %d; want Stop", got)
+		}
+		if got := req.int(attrAcctTerminateCause); got != causeLostCarrier {
+			t.Errorf("got Acct-Terminate-Cause %d; want Lost-Carrier", got)
+		}
+		if got := req.int(attrAcctSessionTime); got < 60 {
+			t.Errorf("got Acct-Session-Time %d; want at least 60", got)
+		}
+		break
+	}
+}
@@ -0,0 +1,22 @@
+// Package radius sends RADIUS accounting for the clients of an OpenVPN
+// server, as described by RFC 2866, replacing the client-connect and
+// client-disconnect scripts otherwise used to report sessions to
+// a RADIUS server.
+//
+// An Accounting sends an Accounting-Request with an Acct-Status-Type of
+// Start when a client's connection is established, Interim-Update
+// periodically while it lasts, and Stop when it ends, from the changes
+// reported by an openvpn.ClientRegistry. The registry takes client
+// connections from CLIENT events and their byte counts from the
+// per-client ByteCountEvents enabled by SetByteCountEvents, so a server
+// should have both enabled:
+//
+//	acct := &radius.Accounting{
+//		Server:          "radius.example.com:1813",
+//		Secret:          []byte("shared secret"),
+//		NASIdentifier:   "vpn1",
+//		InterimInterval: 5 * time.Minute,
+//	}
+//	registry := &openvpn.ClientRegistry{OnChange: acct.HandleChange}
+//	go acct.Run(ctx)
+package radius
@@ -0,0 +1,115 @@
+package radius
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+)
+
+// Packet codes.
+const (
+	codeAccountingRequest  = 4
+	codeAccountingResponse = 5
+)
+
+// Attribute types used in accounting requests.
+const (
+	attrUserName            = 1
+	attrNASIPAddress        = 4
+	attrFramedIPAddress     = 8
+	attrCallingStationID    = 31
+	attrNASIdentifier       = 32
+	attrAcctStatusType      = 40
+	attrAcctInputOctets     = 42
+	attrAcctOutputOctets    = 43
+	attrAcctSessionID       = 44
+	attrAcctSessionTime     = 46
+	attrAcctTerminateCause  = 49
+	attrAcctInputGigawords  = 52
+	attrAcctOutputGigawords = 53
+	attrEventTimestamp      = 55
+	attrNASPortType         = 61
+)
+
+// Values of the Acct-Status-Type attribute.
+const (
+	statusStart   = 1
+	statusStop    = 2
+	statusInterim = 3
+)
+
+// Values of the Acct-Terminate-Cause attribute.
+const (
+	causeUserRequest = 1
+	causeLostCarrier = 2
+)
+
+// nasPortTypeVirtual is the NAS-Port-Type of a VPN connection.
+const nasPortTypeVirtual = 5
+
+// maxPacket is the largest packet allowed by RFC 2865.
+const maxPacket = 4096
+
+var errAttributeTooLong = errors.New("RADIUS attribute too long")
+
+// attributes accumulates the attributes of a packet.
+type attributes []byte
+
+func (a *attributes) add(typ byte, value []byte) error {
+	if len(value) > 253 {
+		return errAttributeTooLong
+	}
+	*a = append(*a, typ, byte(len(value)+2))
+	*a = append(*a, value...)
+	return nil
+}
+
+func (a *attributes) addString(typ byte, s string) error {
+	if s == "" {
+		return nil
+	}
+	return a.add(typ, []byte(s))
+}
+
+func (a *attributes) addInt(typ byte, v uint32) {
+	a.add(typ, binary.BigEndian.AppendUint32(nil, v))
+}
+
+// accountingRequest returns an Accounting-Request packet with the given
+// identifier and attributes, its authenticator computed with the given
+// secret as described by RFC 2866 section 3.
+func accountingRequest(id byte, attrs attributes, secret []byte) ([]byte, error) {
+	length := 20 + len(attrs)
+	if length > maxPacket {
+		return nil, errors.New("RADIUS packet too long")
+	}
+	p := make([]byte, 20, length)
+	p[0] = codeAccountingRequest
+	p[1] = id
+	binary.BigEndian.PutUint16(p[2:], uint16(length))
+	p = append(p, attrs...)
+	h := md5.New()
+	h.Write(p)
+	h.Write(secret)
+	copy(p[4:20], h.Sum(nil))
+	return p, nil
+}
+
+// validResponse reports whether resp is an Accounting-Response to the
+// given request, with a valid authenticator.
+func validResponse(resp, req, secret []byte) bool {
+	if len(resp) < 20 || resp[0] != codeAccountingResponse || resp[1] != req[1] {
+		return false
+	}
+	length := int(binary.BigEndian.Uint16(resp[2:]))
+	if length < 20 || length > len(resp) {
+		return false
+	}
+	h := md5.New()
+	h.Write(resp[:4])
+	h.Write(req[4:20])
+	h.Write(resp[20:length])
+	h.Write(secret)
+	return hmac.Equal(h.Sum(nil), resp[4:20])
+}
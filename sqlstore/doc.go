@@ -0,0 +1,25 @@
+// Package sqlstore persists the sessions of the clients of OpenVPN servers
+// and their accounting in an SQL database through database/sql, so that
+// they can be queried by billing and reporting systems and survive
+// restarts of the process managing the servers.
+//
+// A Store records each client connection reported by an
+// openvpn.ClientRegistry as a row of the openvpn_sessions table, updated
+// as the connection's byte counts change and when it ends, and implements
+// openvpn.UsageStore, keeping the cumulative usage tracked by
+// openvpn.Accounting in the openvpn_usage table. Rows are written with
+// upserts keyed by values that are the same when the process restarts and
+// rediscovers the connections in progress, so that records are neither
+// duplicated nor lost across restarts.
+//
+// Postgres and SQLite are supported, and the store works with any driver
+// for them, which must be imported by the program:
+//
+//	db, _ := sql.Open("pgx", "postgres://localhost/vpn")
+//	store := &sqlstore.Store{DB: db, Dialect: sqlstore.Postgres, Instance: "vpn1"}
+//	if err := store.Migrate(ctx); err != nil {
+//		return err
+//	}
+//	registry := &openvpn.ClientRegistry{OnChange: store.HandleChange}
+//	accounting := &openvpn.Accounting{Store: store}
+package sqlstore
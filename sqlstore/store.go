@@ -0,0 +1,311 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// DefaultTimeout limits the statements executed by Store.HandleChange and
+// the openvpn.UsageStore methods when Store.Timeout is zero.
+const DefaultTimeout = 5 * time.Second
+
+// Dialect is the SQL dialect of a database.
+type Dialect int
+
+const (
+	Postgres Dialect = iota
+	SQLite
+)
+
+// timestampType returns the column type used for timestamps.
+func (d Dialect) timestampType() string {
+	if d == Postgres {
+		return "TIMESTAMPTZ"
+	}
+	return "TIMESTAMP"
+}
+
+// rebind replaces the "?" placeholders of a query with those of the
+// dialect.
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Schema returns the statements creating the tables used by a Store, if
+// they don't exist, for databases managed by other tools.
+func Schema(d Dialect) []string {
+	ts := d.timestampType()
+	return []string{
+		`CREATE TABLE IF NOT EXISTS openvpn_sessions (
+	session_id TEXT PRIMARY KEY,
+	instance TEXT NOT NULL,
+	client_id BIGINT NOT NULL,
+	common_name TEXT NOT NULL,
+	username TEXT NOT NULL,
+	real_address TEXT NOT NULL,
+	virtual_address TEXT NOT NULL,
+	virtual_ipv6_address TEXT NOT NULL,
+	connected_at ` + ts + ` NOT NULL,
+	updated_at ` + ts + ` NOT NULL,
+	disconnected_at ` + ts + `,
+	bytes_received BIGINT NOT NULL,
+	bytes_sent BIGINT NOT NULL,
+	disconnect_reason TEXT NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS openvpn_sessions_open ON openvpn_sessions (instance, disconnected_at)`,
+		`CREATE TABLE IF NOT EXISTS openvpn_usage (
+	common_name TEXT PRIMARY KEY,
+	bytes_received BIGINT NOT NULL,
+	bytes_sent BIGINT NOT NULL,
+	sessions BIGINT NOT NULL,
+	last_seen ` + ts + ` NOT NULL
+)`,
+	}
+}
+
+// Session is a client connection recorded in the openvpn_sessions table.
+type Session struct {
+	// ID identifies the session, and is derived from the instance, the
+	// client id and the time the client connected.
+	ID       string
+	Instance string
+	ClientID int64
+
+	// Disconnected is zero while the session is in progress.
+	openvpn.SessionRecord
+
+	// Updated is when the row was last written.
+	Updated time.Time
+}
+
+// Store persists sessions and usage in a database. The fields must not be
+// changed once it is in use.
+type Store struct {
+	DB      *sql.DB
+	Dialect Dialect
+
+	// Instance identifies the OpenVPN server whose sessions are recorded,
+	// so that the sessions of several servers can share the tables.
+	Instance string
+
+	// Timeout limits the statements executed by HandleChange, LoadUsage
+	// and SaveUsage, and defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// OnError, if set, is called when HandleChange fails to record
+	// a change.
+	OnError func(err error)
+}
+
+// Migrate creates the tables, if they don't exist.
+func (s *Store) Migrate(ctx context.Context) error {
+	for _, stmt := range Schema(s.Dialect) {
+		if _, err := s.DB.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) context() (context.Context, context.CancelFunc) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// HandleChange records the session of the client described by a change.
+// It can be called from a ClientRegistry's OnChange function, and waits
+// for the database, so a slow database holds up the registry.
+func (s *Store) HandleChange(c openvpn.ClientChange) {
+	now := time.Now()
+	sess := s.session(c.Client, now)
+	if c.Kind == openvpn.ClientDisconnected {
+		sess.Disconnected = now
+		sess.Reason = c.Reason
+	}
+	ctx, cancel := s.context()
+	defer cancel()
+	if err := s.SaveSession(ctx, sess); err != nil && s.OnError != nil {
+		s.OnError(err)
+	}
+}
+
+// session returns the session of a connected client.
+func (s *Store) session(cc openvpn.ConnectedClient, now time.Time) Session {
+	return Session{
+		ID:       fmt.Sprintf("%s/%d/%d", s.Instance, cc.ClientID, cc.ConnectedSince.Unix()),
+		Instance: s.Instance,
+		ClientID: cc.ClientID,
+		SessionRecord: openvpn.SessionRecord{
+			CommonName:         cc.CommonName,
+			Username:           cc.Username,
+			RealAddress:        cc.RealAddress,
+			VirtualAddress:     cc.VirtualAddress,
+			VirtualIPv6Address: cc.VirtualIPv6Address,
+			Connected:          cc.ConnectedSince,
+			BytesReceived:      cc.BytesReceived,
+			BytesSent:          cc.BytesSent,
+		},
+		Updated: now,
+	}
+}
+
+// SaveSession inserts a session, or updates it if it has already been
+// recorded. A session that has ended is not reopened, and the reason it
+// ended is kept if the update gives none.
+func (s *Store) SaveSession(ctx context.Context, sess Session) error {
+	var disconnected interface{}
+	if !sess.Disconnected.IsZero() {
+		disconnected = sess.Disconnected.UTC()
+	}
+	_, err := s.DB.ExecContext(ctx, s.Dialect.rebind(`INSERT INTO openvpn_sessions (
+	session_id, instance, client_id, common_name, username, real_address,
+	virtual_address, virtual_ipv6_address, connected_at, updated_at,
+	disconnected_at, bytes_received, bytes_sent, disconnect_reason
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (session_id) DO UPDATE SET
+	common_name = excluded.common_name,
+	username = excluded.username,
+	real_address = excluded.real_address,
+	virtual_address = excluded.virtual_address,
+	virtual_ipv6_address = excluded.virtual_ipv6_address,
+	updated_at = excluded.updated_at,
+	disconnected_at = COALESCE(openvpn_sessions.disconnected_at, excluded.disconnected_at),
+	bytes_received = excluded.bytes_received,
+	bytes_sent = excluded.bytes_sent,
+	disconnect_reason = CASE WHEN excluded.disconnect_reason = '' THEN openvpn_sessions.disconnect_reason ELSE excluded.disconnect_reason END`),
+		sess.ID, sess.Instance, sess.ClientID, sess.CommonName, sess.Username, sess.RealAddress,
+		sess.VirtualAddress, sess.VirtualIPv6Address, sess.Connected.UTC(), sess.Updated.UTC(),
+		disconnected, sess.BytesReceived, sess.BytesSent, sess.Reason)
+	return err
+}
+
+// OpenSessions returns the sessions of the instance that are recorded as
+// in progress, ordered by client id.
+func (s *Store) OpenSessions(ctx context.Context) ([]Session, error) {
+	rows, err := s.DB.QueryContext(ctx, s.Dialect.rebind(`SELECT
+	session_id, instance, client_id, common_name, username, real_address,
+	virtual_address, virtual_ipv6_address, connected_at, updated_at,
+	bytes_received, bytes_sent
+FROM openvpn_sessions WHERE instance = ? AND disconnected_at IS NULL ORDER BY client_id`), s.Instance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []Session
+	for rows.Next() {
+		var sess Session
+		err := rows.Scan(&sess.ID, &sess.Instance, &sess.ClientID, &sess.CommonName, &sess.Username,
+			&sess.RealAddress, &sess.VirtualAddress, &sess.VirtualIPv6Address, &sess.Connected,
+			&sess.Updated, &sess.BytesReceived, &sess.BytesSent)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, sess)
+	}
+	return ret, rows.Err()
+}
+
+// CloseMissing records the sessions of the instance that are in progress
+// but whose clients are not among the given ones as having ended with the
+// given reason, as of when they were last updated. It should be called
+// once the registry has been brought up to date after a restart, such as
+// by a poll, to close the sessions that ended while the process was not
+// running. It returns the number of sessions closed.
+func (s *Store) CloseMissing(ctx context.Context, clients []openvpn.ConnectedClient, reason string) (int, error) {
+	current := make(map[string]bool, len(clients))
+	for _, cc := range clients {
+		current[s.session(cc, time.Time{}).ID] = true
+	}
+	open, err := s.OpenSessions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, sess := range open {
+		if current[sess.ID] {
+			continue
+		}
+		_, err := s.DB.ExecContext(ctx, s.Dialect.rebind(`UPDATE openvpn_sessions
+SET disconnected_at = updated_at, disconnect_reason = ?
+WHERE session_id = ? AND disconnected_at IS NULL`), reason, sess.ID)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// LoadUsage returns the usage of every common name, as required by
+// openvpn.UsageStore.
+func (s *Store) LoadUsage() ([]openvpn.Usage, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	rows, err := s.DB.QueryContext(ctx, `SELECT common_name, bytes_received, bytes_sent, sessions, last_seen
+FROM openvpn_usage ORDER BY common_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []openvpn.Usage
+	for rows.Next() {
+		var u openvpn.Usage
+		if err := rows.Scan(&u.CommonName, &u.BytesReceived, &u.BytesSent, &u.Sessions, &u.LastSeen); err != nil {
+			return nil, err
+		}
+		ret = append(ret, u)
+	}
+	return ret, rows.Err()
+}
+
+// SaveUsage stores the given usage in a single transaction, replacing any
+// stored for the same common names, as required by openvpn.UsageStore.
+func (s *Store) SaveUsage(usage []openvpn.Usage) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, s.Dialect.rebind(`INSERT INTO openvpn_usage (
+	common_name, bytes_received, bytes_sent, sessions, last_seen
+) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (common_name) DO UPDATE SET
+	bytes_received = excluded.bytes_received,
+	bytes_sent = excluded.bytes_sent,
+	sessions = excluded.sessions,
+	last_seen = excluded.last_seen`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, u := range usage {
+		if _, err := stmt.ExecContext(ctx, u.CommonName, u.BytesReceived, u.BytesSent, u.Sessions, u.LastSeen.UTC()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,110 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	_ "modernc.org/sqlite"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{Postgres, "SELECT a FROM t WHERE b = ? AND c = ?", "SELECT a FROM t WHERE b = $1 AND c = $2"},
+		{SQLite, "SELECT a FROM t WHERE b = ?", "SELECT a FROM t WHERE b = ?"},
+	}
+	for i, test := range tests {
+		if got := test.dialect.rebind(test.query); got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
+
+func openStore(t *testing.T, path string) *Store {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := &Store{DB: db, Dialect: SQLite, Instance: "vpn1", OnError: func(err error) { t.Error(err) }}
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vpn.db")
+	s := openStore(t, path)
+
+	connected := time.Now().Add(-time.Hour).Truncate(time.Second)
+	alice := openvpn.ClientStatus{ClientID: 1, CommonName: "alice", RealAddress: "203.0.113.7:1194", ConnectedSince: connected}
+	bob := openvpn.ClientStatus{ClientID: 2, CommonName: "bob", ConnectedSince: connected}
+	r := &openvpn.ClientRegistry{OnChange: s.HandleChange}
+	r.Sync([]openvpn.ClientStatus{alice, bob})
+	alice.BytesReceived = 500
+	r.Sync([]openvpn.ClientStatus{alice, bob})
+
+	open, err := s.OpenSessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 2 {
+		t.Fatalf("got %d open sessions; want 2", len(open))
+	}
+	if open[0].CommonName != "alice" || open[0].BytesReceived != 500 || !open[0].Connected.Equal(connected) {
+		t.Errorf("got session %+v", open[0])
+	}
+
+	// The process restarts while bob disconnects, and rediscovers alice
+	// with the same client id and connection time.
+	s = openStore(t, path)
+	r = &openvpn.ClientRegistry{OnChange: s.HandleChange}
+	r.Sync([]openvpn.ClientStatus{alice})
+	n, err := s.CloseMissing(ctx, r.Clients(), openvpn.DisconnectMissing)
+	if err != nil || n != 1 {
+		t.Errorf("CloseMissing returned %d, %v; want 1, nil", n, err)
+	}
+	r.Sync(nil)
+
+	var count, closed int
+	var reason string
+	s.DB.QueryRow(`SELECT COUNT(*), COUNT(disconnected_at) FROM openvpn_sessions`).Scan(&count, &closed)
+	if count != 2 || closed != 2 {
+		t.Errorf("got %d sessions, %d closed; want 2, 2", count, closed)
+	}
+	s.DB.QueryRow(`SELECT disconnect_reason FROM openvpn_sessions WHERE common_name = 'bob'`).Scan(&reason)
+	if reason != openvpn.DisconnectMissing {
+		t.Errorf("got reason %q for bob; want %q", reason, openvpn.DisconnectMissing)
+	}
+}
+
+func TestUsageStore(t *testing.T) {
+	s := openStore(t, filepath.Join(t.TempDir(), "vpn.db"))
+	a := &openvpn.Accounting{Store: s}
+	a.HandleChange(openvpn.ClientChange{Kind: openvpn.ClientConnected, Client: openvpn.ConnectedClient{ClientID: 1, CommonName: "alice", BytesReceived: 10}})
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	a.HandleChange(openvpn.ClientChange{Kind: openvpn.ClientUpdated, Client: openvpn.ConnectedClient{ClientID: 1, CommonName: "alice", BytesReceived: 30}})
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &openvpn.Accounting{Store: s}
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	u, ok := loaded.Usage("alice")
+	if !ok || u.BytesReceived != 30 || u.Sessions != 1 || u.LastSeen.IsZero() {
+		t.Errorf("got usage %+v, %v", u, ok)
+	}
+}
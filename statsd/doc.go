@@ -0,0 +1,10 @@
+// Package statsd pushes the state tracked by package openvpn to a StatsD
+// server, for deployments that collect metrics with StatsD or Datadog
+// rather than Prometheus or OpenTelemetry.
+//
+// An Emitter sends a counter for each state change of a Session as it
+// happens, and periodically sends the tunnel's throughput and reconnects
+// and the number of clients connected to a server. Tags are written in
+// the DogStatsD format, which is also understood by Telegraf and the
+// Prometheus statsd_exporter.
+package statsd
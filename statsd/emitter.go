@@ -0,0 +1,190 @@
+package statsd
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// DefaultPrefix is the prefix of metric names used when Emitter.Prefix is
+// empty.
+const DefaultPrefix = "openvpn."
+
+// maxPacket is the largest datagram written, so that packets are not
+// fragmented on typical networks.
+const maxPacket = 1432
+
+// Emitter sends metrics about an OpenVPN tunnel or server to a StatsD
+// server. Each of its sources is optional, and only the metrics for those
+// that are set are sent:
+//
+//   - Session, for an OpenVPN client, yields a state_changes counter tagged
+//     with the new state, sent by Watch, and a connected gauge and
+//     bytes_received, bytes_sent and reconnects counters, sent by Flush.
+//   - Registry, for an OpenVPN server, yields a connected_clients gauge,
+//     sent by Flush.
+//
+// The sources must not be changed once the emitter is in use.
+type Emitter struct {
+	Session  *openvpn.Session
+	Registry *openvpn.ClientRegistry
+
+	// Prefix is prepended to the name of every metric, and defaults to
+	// DefaultPrefix.
+	Prefix string
+
+	// Tags are added to every metric, in the form "key:value", such as to
+	// identify the tunnel when a process emits for several.
+	Tags []string
+
+	mu     sync.Mutex
+	w      io.Writer
+	primed bool
+	last   openvpn.Snapshot
+	recons int
+	err    error
+}
+
+// NewEmitter returns an emitter writing packets to w, which is typically
+// a UDP connection.
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{w: w}
+}
+
+// Dial returns an emitter sending to the StatsD server at the given UDP
+// address, such as "127.0.0.1:8125".
+func Dial(addr string) (*Emitter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewEmitter(conn), nil
+}
+
+// Watch arranges for a state_changes counter to be sent whenever the
+// session's state changes, returning a function that stops doing so. It
+// does nothing if Session is nil.
+func (e *Emitter) Watch() (remove func()) {
+	if e.Session == nil {
+		return func() {}
+	}
+	return e.Session.RegisterTransitionHook("", "", func(t openvpn.Transition) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.sendLocked([]string{e.line("state_changes", "1", "c", "state:"+string(t.To))})
+	})
+}
+
+// Flush sends the current value of each gauge, and the change in each
+// counter since the previous call. The first call only records the
+// counters, so that a restarted process doesn't report the traffic of the
+// whole connection again.
+func (e *Emitter) Flush() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var lines []string
+	if e.Session != nil {
+		snap := e.Session.Snapshot()
+		recons := e.Session.Analytics().Reconnects
+		lines = append(lines, e.line("connected", gauge(snap.State == openvpn.StateConnected), "g"))
+		if e.primed {
+			lines = append(lines,
+				e.line("bytes_received", strconv.FormatInt(delta(e.last.BytesIn, snap.BytesIn), 10), "c"),
+				e.line("bytes_sent", strconv.FormatInt(delta(e.last.BytesOut, snap.BytesOut), 10), "c"),
+				e.line("reconnects", strconv.Itoa(recons-e.recons), "c"),
+			)
+		}
+		e.primed, e.last, e.recons = true, snap, recons
+	}
+	if e.Registry != nil {
+		lines = append(lines, e.line("connected_clients", strconv.Itoa(len(e.Registry.Clients())), "g"))
+	}
+	e.sendLocked(lines)
+}
+
+// Run calls Watch, and Flush at the given interval, until ctx is
+// cancelled, returning ctx.Err().
+func (e *Emitter) Run(ctx context.Context, interval time.Duration) error {
+	defer e.Watch()()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			e.Flush()
+		}
+	}
+}
+
+// Err returns the most recent error encountered while sending metrics, if
+// any. Sending continues after an error, since a StatsD server that is
+// briefly unavailable should not stop metrics once it returns.
+func (e *Emitter) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// line formats a metric in the DogStatsD format, such as
+// "openvpn.connected:1|g|#tunnel:home".
+func (e *Emitter) line(name, value, typ string, tags ...string) string {
+	prefix := e.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	s := prefix + name + ":" + value + "|" + typ
+	if tags = append(tags, e.Tags...); len(tags) > 0 {
+		s += "|#" + strings.Join(tags, ",")
+	}
+	return s
+}
+
+// sendLocked writes the lines in as few packets as possible. It must be
+// called with e.mu held.
+func (e *Emitter) sendLocked(lines []string) {
+	var packet []byte
+	flush := func() {
+		if len(packet) == 0 {
+			return
+		}
+		if _, err := e.w.Write(packet); err != nil {
+			e.err = err
+		}
+		packet = packet[:0]
+	}
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > maxPacket {
+			flush()
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	flush()
+}
+
+// delta returns the change in a byte count from before to after, treating
+// a decrease as the count having been reset by a new connection.
+func delta(before, after int64) int64 {
+	if after < before {
+		return after
+	}
+	return after - before
+}
+
+func gauge(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,83 @@
+package statsd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+type packets []string
+
+func (p *packets) Write(b []byte) (int, error) {
+	*p = append(*p, string(b))
+	return len(b), nil
+}
+
+func TestEmitterSession(t *testing.T) {
+	var got packets
+	s := &openvpn.Session{}
+	e := NewEmitter(&got)
+	e.Session = s
+	e.Tags = []string{"tunnel:home"}
+	remove := e.Watch()
+	defer remove()
+
+	handle := func(lines ...string) {
+		for _, raw := range lines {
+			s.HandleEvent(openvpn.ParseEvent([]byte(raw)))
+		}
+	}
+	handle("STATE:1,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1", "BYTECOUNT:1000,2000")
+	e.Flush()
+	handle("STATE:2,RECONNECTING,ping-restart,,", "STATE:3,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1", "BYTECOUNT:300,400")
+	e.Flush()
+	handle("BYTECOUNT:500,900")
+	e.Prefix = "vpn."
+	e.Flush()
+
+	want := packets{
+		"openvpn.state_changes:1|c|#state:CONNECTED,tunnel:home",
+		"openvpn.connected:1|g|#tunnel:home",
+		"openvpn.state_changes:1|c|#state:RECONNECTING,tunnel:home",
+		"openvpn.state_changes:1|c|#state:CONNECTED,tunnel:home",
+		"openvpn.connected:1|g|#tunnel:home\n" +
+			"openvpn.bytes_received:300|c|#tunnel:home\n" +
+			"openvpn.bytes_sent:400|c|#tunnel:home\n" +
+			"openvpn.reconnects:1|c|#tunnel:home",
+		"vpn.connected:1|g|#tunnel:home\n" +
+			"vpn.bytes_received:200|c|#tunnel:home\n" +
+			"vpn.bytes_sent:500|c|#tunnel:home\n" +
+			"vpn.reconnects:0|c|#tunnel:home",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got packets\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEmitterRegistry(t *testing.T) {
+	var got packets
+	r := &openvpn.ClientRegistry{}
+	r.Sync([]openvpn.ClientStatus{{ClientID: 1, CommonName: "alice"}, {ClientID: 2, CommonName: "bob"}})
+	e := NewEmitter(&got)
+	e.Registry = r
+	e.Flush()
+
+	want := packets{"openvpn.connected_clients:2|g"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got packets %q; want %q", got, want)
+	}
+}
+
+func TestSendSplitsPackets(t *testing.T) {
+	var got packets
+	e := NewEmitter(&got)
+	line := strings.Repeat("x", 1000)
+	e.sendLocked([]string{line, line, "a", "b"})
+
+	want := packets{line, line + "\na\nb"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d packets; want %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,9 @@
+// Package syslog forwards the log messages of OpenVPN processes to a
+// syslog server, for environments that centralize logs using syslog.
+//
+// A Forwarder sends each LogEvent received from the management interface,
+// and each line an OpenVPN process writes to its standard output when it
+// is given the forwarder's Writer, as a message in the format of RFC 5424,
+// over UDP, TCP or a Unix datagram socket. The severity of each message is
+// derived from the flags OpenVPN attached to it, as for LogEvent.Level.
+package syslog
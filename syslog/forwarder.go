@@ -0,0 +1,271 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// Facility is a syslog facility, which identifies the part of the system
+// a message comes from.
+type Facility int
+
+// The facilities most suited to OpenVPN; see RFC 5424 for the rest.
+const (
+	Daemon Facility = 3
+	Local0 Facility = 16
+	Local1 Facility = 17
+	Local2 Facility = 18
+	Local3 Facility = 19
+	Local4 Facility = 20
+	Local5 Facility = 21
+	Local6 Facility = 22
+	Local7 Facility = 23
+)
+
+// Severity is the severity of a syslog message.
+type Severity int
+
+// The severities, from the most severe to the least.
+const (
+	Emergency Severity = iota
+	Alert
+	Critical
+	Error
+	Warning
+	Notice
+	Informational
+	Debug
+)
+
+// Forwarder sends log messages to a syslog server. It is safe for
+// concurrent use.
+type Forwarder struct {
+	// Facility is the facility of each message, and defaults to Daemon.
+	Facility Facility
+
+	// Hostname, AppName and ProcID identify the source of each message,
+	// and default to the name of the host, "openvpn" and nothing. ProcID
+	// can be set to the process id of the OpenVPN process, or to the name
+	// of the tunnel when a process forwards for several.
+	Hostname string
+	AppName  string
+	ProcID   string
+
+	network, addr string
+
+	mu   sync.Mutex
+	conn net.Conn
+	err  error
+}
+
+// Dial returns a forwarder sending to the syslog server at the given
+// address, where network is "udp", "tcp" or "unixgram", such as
+// Dial("udp", "logs.example.com:514") or Dial("unixgram", "/dev/log").
+// Messages sent over TCP are framed using octet counting, as described in
+// RFC 6587, and the connection is re-established if it fails.
+func Dial(network, addr string) (*Forwarder, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", network)
+	}
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Forwarder{network: network, addr: addr, conn: conn}, nil
+}
+
+// HandleEvent forwards the message of the given event if it is
+// a LogEvent, returning true if it was. The caller should pass each event
+// received from the client's event channel.
+func (f *Forwarder) HandleEvent(e openvpn.Event) bool {
+	le, ok := e.(*openvpn.LogEvent)
+	if !ok {
+		return false
+	}
+	t := time.Now()
+	if secs, err := strconv.ParseInt(le.RawTimestamp(), 10, 64); err == nil && secs > 0 {
+		t = time.Unix(secs, 0)
+	}
+	f.Send(levelSeverity(le.Level()), "LOG", le.Message(), t)
+	return true
+}
+
+// Writer returns a writer that forwards each line written to it, such as
+// for use as the Stdout of a launcher.Options, so that the output of the
+// OpenVPN process is forwarded too. Lines are sent at Informational
+// severity unless OpenVPN marked them as errors or warnings.
+func (f *Forwarder) Writer() io.Writer {
+	return &lineWriter{f: f}
+}
+
+// Send sends a message with the given severity, message id and time. It
+// fails only if the message could not be written, in which case the error
+// is also kept for Err.
+func (f *Forwarder) Send(sev Severity, msgID, msg string, t time.Time) error {
+	buf := f.format(sev, msgID, msg, t)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.writeLocked(buf)
+	if err != nil && f.stream() {
+		// The server may have closed the connection, so try once more
+		// on a new one.
+		if f.conn != nil {
+			f.conn.Close()
+		}
+		if f.conn, err = net.Dial(f.network, f.addr); err == nil {
+			err = f.writeLocked(buf)
+		}
+	}
+	if err != nil {
+		f.err = err
+	}
+	return err
+}
+
+// Err returns the most recent error encountered while forwarding, if any.
+// Forwarding continues after an error, since a syslog server that is
+// briefly unavailable should not stop messages once it returns.
+func (f *Forwarder) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// Close closes the connection to the syslog server.
+func (f *Forwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.conn == nil {
+		return nil
+	}
+	return f.conn.Close()
+}
+
+func (f *Forwarder) stream() bool {
+	return strings.HasPrefix(f.network, "tcp")
+}
+
+func (f *Forwarder) writeLocked(msg []byte) error {
+	if f.conn == nil {
+		return net.ErrClosed
+	}
+	if f.stream() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_, err := f.conn.Write(msg)
+	return err
+}
+
+// format returns a message in the format of RFC 5424, such as
+// "<30>1 2024-05-01T12:00:00Z vpn1 openvpn - LOG - Initialization Sequence Completed".
+func (f *Forwarder) format(sev Severity, msgID, msg string, t time.Time) []byte {
+	facility := f.Facility
+	if facility == 0 {
+		facility = Daemon
+	}
+	hostname := f.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	appName := f.AppName
+	if appName == "" {
+		appName = "openvpn"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s - ",
+		int(facility)*8+int(sev),
+		t.UTC().Format(time.RFC3339Nano),
+		header(hostname, 255), header(appName, 48), header(f.ProcID, 128), header(msgID, 32))
+	buf.WriteString(msg)
+	return buf.Bytes()
+}
+
+// header returns a header field, which must be printable ASCII without
+// spaces, truncated to the given length, or "-" if it is empty.
+func header(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
+
+// levelSeverity returns the severity of a message at the given level, as
+// returned by LogEvent.Level.
+func levelSeverity(level slog.Level) Severity {
+	switch {
+	case level >= openvpn.LevelFatal:
+		return Critical
+	case level >= slog.LevelError:
+		return Error
+	case level >= slog.LevelWarn:
+		return Warning
+	case level >= slog.LevelInfo:
+		return Informational
+	}
+	return Debug
+}
+
+// lineSeverity returns the severity of a line of process output, which
+// OpenVPN marks as an error or warning with a prefix on the message, as in
+// "2024-05-01 12:00:00 WARNING: file 'ta.key' is group or others accessible".
+func lineSeverity(line string) Severity {
+	switch {
+	case strings.Contains(line, "Exiting due to fatal error"):
+		return Critical
+	case strings.Contains(line, "ERROR:"):
+		return Error
+	case strings.Contains(line, "WARNING:"):
+		return Warning
+	}
+	return Informational
+}
+
+type lineWriter struct {
+	f *Forwarder
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	data := append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx == -1 {
+			break
+		}
+		line := string(bytes.TrimSuffix(data[:idx], []byte{'\r'}))
+		data = data[idx+1:]
+		if line != "" {
+			w.f.Send(lineSeverity(line), "STDOUT", line, now)
+		}
+	}
+	w.partial = append(w.partial[:0], data...)
+	return len(p), nil
+}
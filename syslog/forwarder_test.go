@@ -0,0 +1,162 @@
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		f    *Forwarder
+		sev  Severity
+		want string
+	}{
+		{&Forwarder{Hostname: "vpn1"}, Informational, "<30>1 2024-05-01T12:00:00Z vpn1 openvpn - LOG - hello"},
+		{&Forwarder{Hostname: "vpn 1", AppName: "ovpn", ProcID: "1234", Facility: Local3}, Critical, "<154>1 2024-05-01T12:00:00Z vpn_1 ovpn 1234 LOG - hello"},
+	}
+	for i, test := range tests {
+		got := string(test.f.format(test.sev, "LOG", "hello", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+		if got != test.want {
+			t.Errorf("test %d got %q; want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		line  string
+		want  Severity
+	}{
+		{slog.LevelInfo, "2024-05-01 12:00:00 Initialization Sequence Completed", Informational},
+		{openvpn.LevelFatal, "2024-05-01 12:00:00 Exiting due to fatal error", Critical},
+		{slog.LevelError, "2024-05-01 12:00:00 ERROR: Cannot open TUN/TAP dev /dev/net/tun", Error},
+		{slog.LevelWarn, "2024-05-01 12:00:00 WARNING: file 'ta.key' is group or others accessible", Warning},
+		{slog.LevelDebug, "", Debug},
+	}
+	for i, test := range tests {
+		if got := levelSeverity(test.level); got != test.want {
+			t.Errorf("test %d got level severity %d; want %d", i, got, test.want)
+		}
+		if test.line == "" {
+			continue
+		}
+		if got := lineSeverity(test.line); got != test.want {
+			t.Errorf("test %d got line severity %d; want %d", i, got, test.want)
+		}
+	}
+}
+
+var messageRE = regexp.MustCompile(`^<(\d+)>1 (\S+) host openvpn - (\S+) - (.*)$`)
+
+func TestForwarderUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	f, err := Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Hostname = "host"
+
+	if !f.HandleEvent(openvpn.ParseEvent([]byte("LOG:1714564800,W,WARNING: 'link-mtu' is used inconsistently"))) {
+		t.Error("LogEvent not handled")
+	}
+	if f.HandleEvent(openvpn.ParseEvent([]byte("STATE:1,CONNECTED,SUCCESS,,"))) {
+		t.Error("StateEvent handled")
+	}
+	w := f.Writer()
+	w.Write([]byte("2024-05-01 12:00:00 Initialization "))
+	w.Write([]byte("Sequence Completed\n"))
+
+	want := [][]string{
+		{"28", "2024-05-01T12:00:00Z", "LOG", "WARNING: 'link-mtu' is used inconsistently"},
+		{"30", "", "STDOUT", "2024-05-01 12:00:00 Initialization Sequence Completed"},
+	}
+	buf := make([]byte, 2048)
+	for i, w := range want {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := messageRE.FindStringSubmatch(string(buf[:n]))
+		if m == nil || m[1] != w[0] || (w[1] != "" && m[2] != w[1]) || m[3] != w[2] || m[4] != w[3] {
+			t.Errorf("message %d got %q; want %q", i, buf[:n], w)
+		}
+	}
+}
+
+func TestForwarderTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			// Read one message, then drop the connection so that the
+			// forwarder must reconnect.
+			size, err := r.ReadString(' ')
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			n := 0
+			for _, c := range strings.TrimSpace(size) {
+				n = n*10 + int(c-'0')
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err == nil {
+				lines <- string(msg)
+			}
+			conn.Close()
+		}
+	}()
+
+	f, err := Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Hostname = "host"
+
+	for _, msg := range []string{"first", "second"} {
+		// The first write to a connection closed by the server may
+		// succeed, so keep sending until the server receives it.
+		deadline := time.Now().Add(5 * time.Second)
+		got := false
+		for !got && time.Now().Before(deadline) {
+			f.Send(Notice, "TEST", msg, time.Now())
+			select {
+			case line := <-lines:
+				m := messageRE.FindStringSubmatch(line)
+				if m == nil {
+					t.Fatalf("got malformed message %q", line)
+				}
+				got = m[4] == msg
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		if !got {
+			t.Errorf("message %q not received", msg)
+		}
+	}
+}
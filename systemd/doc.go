@@ -0,0 +1,10 @@
+// Package systemd integrates programs that manage OpenVPN with systemd
+// service supervision.
+//
+// It implements the small subset of the sd_notify and socket activation
+// protocols needed to report tunnel readiness to the service manager, to
+// keep a watchdog satisfied while the tunnel is up, and to receive
+// management listen sockets passed in by a systemd socket unit. None of
+// these features require linking against libsystemd, and all of them are
+// harmless no-ops when the program is not running under systemd.
+package systemd
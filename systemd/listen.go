@@ -0,0 +1,95 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// listenFdsStart is the first file descriptor passed by socket activation.
+const listenFdsStart = 3
+
+// Listeners returns the listen sockets passed to the process by systemd
+// socket activation, in the order they are declared in the socket unit.
+//
+// The LISTEN_* environment variables are removed once they have been read,
+// so that they will not be inherited by any OpenVPN processes launched
+// later. As a consequence, only the first call returns any listeners.
+//
+// If the process was not socket-activated then the result is empty.
+func Listeners() ([]net.Listener, error) {
+	files, err := listenFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(files))
+	for _, f := range files {
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket %s is not a listener: %s", f.Name(), err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// MgmtListeners is like Listeners but wraps each listener as an OpenVPN
+// management listener, ready for OpenVPN processes launched with the
+// --management-client option to connect to.
+func MgmtListeners() ([]*openvpn.MgmtListener, error) {
+	listeners, err := Listeners()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*openvpn.MgmtListener, len(listeners))
+	for i, l := range listeners {
+		ret[i] = openvpn.NewMgmtListener(l)
+	}
+	return ret, nil
+}
+
+func listenFiles() ([]*os.File, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pidStr := os.Getenv("LISTEN_PID")
+	if pidStr == "" {
+		return nil, nil
+	}
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_PID %q", pidStr)
+	}
+	if pid != os.Getpid() {
+		// The sockets were intended for some other process.
+		return nil, nil
+	}
+
+	countStr := os.Getenv("LISTEN_FDS")
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", countStr)
+	}
+
+	files := make([]*os.File, count)
+	for i := range files {
+		fd := listenFdsStart + i
+		// The sockets are inherited without close-on-exec, which would
+		// leak them into every OpenVPN process launched later.
+		closeOnExec(fd)
+		files[i] = os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+	}
+	return files, nil
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package systemd
+
+// closeOnExec does nothing, as socket activation passes file descriptors
+// only to Unix processes.
+func closeOnExec(fd int) {}
@@ -0,0 +1,10 @@
+//go:build unix
+
+package systemd
+
+import "syscall"
+
+// closeOnExec marks fd to be closed in child processes.
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
@@ -0,0 +1,155 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// Notify sends the given state string, such as "READY=1", to the service
+// manager.
+//
+// It returns false with no error if the process is not running under
+// a service manager that expects notifications.
+func Notify(state string) (bool, error) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return false, nil
+	}
+
+	// A leading @ denotes a socket in the Linux abstract namespace.
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the interval within which the service manager
+// expects to receive watchdog keep-alive notifications, or zero if the
+// watchdog is not enabled for this process.
+func WatchdogInterval() (time.Duration, error) {
+	usecStr := os.Getenv("WATCHDOG_USEC")
+	if usecStr == "" {
+		return 0, nil
+	}
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %q", pidStr)
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+
+	usec, err := strconv.ParseInt(usecStr, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usecStr)
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
+
+// TunnelNotifier reports the state of an OpenVPN tunnel to the service
+// manager.
+//
+// The service is reported as ready the first time the tunnel reaches the
+// CONNECTED state, and the status text shown by systemctl is updated on
+// each subsequent state change. If the service has a watchdog configured
+// then keep-alive notifications are sent only while the tunnel is
+// connected, so that the service manager can restart a service whose
+// tunnel stays down for longer than the watchdog timeout.
+//
+// The caller must pass each event received from the management client to
+// HandleEvent, with state events enabled using client.SetStateEvents(true).
+type TunnelNotifier struct {
+	mu        sync.Mutex
+	ready     bool
+	connected bool
+	lastErr   error
+}
+
+// NewTunnelNotifier creates a TunnelNotifier.
+func NewTunnelNotifier() *TunnelNotifier {
+	return &TunnelNotifier{}
+}
+
+// HandleEvent updates the notifier with an event from the management
+// client. Events other than StateEvent are ignored.
+func (n *TunnelNotifier) HandleEvent(e openvpn.Event) {
+	st, ok := e.(*openvpn.StateEvent)
+	if !ok {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	state := st.NewState()
+	n.connected = state == "CONNECTED"
+
+	msg := "STATUS=" + st.String()
+	if n.connected && !n.ready {
+		n.ready = true
+		msg = "READY=1\n" + msg
+	}
+	if state == "EXITING" {
+		msg = "STOPPING=1\n" + msg
+	}
+	n.notify(msg)
+}
+
+// RunWatchdog sends watchdog keep-alive notifications while the tunnel is
+// connected, until the given channel is closed. It returns immediately
+// if the service manager has not enabled the watchdog for this process.
+func (n *TunnelNotifier) RunWatchdog(stop <-chan struct{}) error {
+	interval, err := WatchdogInterval()
+	if err != nil || interval == 0 {
+		return err
+	}
+
+	// The systemd documentation recommends notifying at half the interval
+	// to allow for scheduling delays.
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			n.mu.Lock()
+			if n.connected {
+				n.notify("WATCHDOG=1")
+			}
+			n.mu.Unlock()
+		}
+	}
+}
+
+// Err returns the error from the most recent failed notification, if any.
+func (n *TunnelNotifier) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.lastErr
+}
+
+func (n *TunnelNotifier) notify(msg string) {
+	if _, err := Notify(msg); err != nil {
+		n.lastErr = err
+	}
+}
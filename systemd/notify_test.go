@@ -0,0 +1,82 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+func TestNotifyUnset(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	sent, err := Notify("READY=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent {
+		t.Errorf("Notify reports sending without NOTIFY_SOCKET")
+	}
+}
+
+func TestTunnelNotifier(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %s", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", addr)
+
+	n := NewTunnelNotifier()
+	n.HandleEvent(openvpn.ParseEvent([]byte("STATE:1,CONNECTING,,,")))
+	n.HandleEvent(openvpn.ParseEvent([]byte("STATE:2,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+	n.HandleEvent(openvpn.ParseEvent([]byte("STATE:3,RECONNECTING,ping-restart,,")))
+	n.HandleEvent(openvpn.ParseEvent([]byte("STATE:4,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+	if err := n.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"STATUS=CONNECTING",
+		"READY=1\nSTATUS=CONNECTED: 192.0.2.1",
+		"STATUS=RECONNECTING: ping-restart",
+		"STATUS=CONNECTED: 192.0.2.1",
+	}
+	buf := make([]byte, 1024)
+	for i, wantMsg := range want {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("message %d: %s", i, err)
+		}
+		if got := string(buf[:n]); got != wantMsg {
+			t.Errorf("message %d is %q; want %q", i, got, wantMsg)
+		}
+	}
+}
+
+func TestListenersNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+
+	listeners, err := Listeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 0 {
+		t.Errorf("got %d listeners; want none", len(listeners))
+	}
+
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	if listeners, _ := Listeners(); len(listeners) != 0 {
+		t.Errorf("got %d listeners for another process; want none", len(listeners))
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Errorf("LISTEN_FDS was not removed from the environment")
+	}
+}
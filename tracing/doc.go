@@ -0,0 +1,9 @@
+// Package tracing records the activity of OpenVPN processes as
+// OpenTelemetry spans, so that the latency of setting up a VPN can be
+// examined alongside the rest of a distributed trace.
+//
+// A Tracer produces a span for each management command, from the audit
+// records passed to it by MgmtClient.SetAuditHook, and spans for each
+// attempt to establish the tunnel and for each of the phases it passes
+// through, from the transitions of a Session.
+package tracing
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans' source when Tracer.Provider is
+// not set.
+const instrumentationName = "github.com/NordSecurity/gopenvpn/tracing"
+
+// Attribute keys set on the spans.
+const (
+	CommandKey    = attribute.Key("openvpn.command")
+	OutcomeKey    = attribute.Key("openvpn.outcome")
+	ErrorClassKey = attribute.Key("openvpn.error_class")
+	StateKey      = attribute.Key("openvpn.state")
+	ReconnectKey  = attribute.Key("openvpn.reconnect")
+	ReasonKey     = attribute.Key("openvpn.reason")
+)
+
+// Tracer records spans for the commands sent to an OpenVPN process and for
+// the establishment of its tunnel.
+//
+// Each command is recorded as a span named "openvpn <command>", such as
+// "openvpn state", timed from when it was sent to when its reply arrived,
+// with its outcome, "success" or "error", and, for failures, the class of
+// error: "server" if OpenVPN rejected the command, or "connection" if the
+// management connection failed.
+//
+// Each attempt to establish the tunnel, from when it starts connecting or
+// reconnecting until it reaches CONNECTED or gives up, is recorded as
+// a span named "openvpn connect", with a child span for each state it
+// passes through, named "openvpn state <STATE>", such as "openvpn state
+// AUTH", so that for example slow authentication stands out.
+//
+// The zero value uses the global tracer provider, with spans starting new
+// traces. A Tracer is safe for concurrent use.
+type Tracer struct {
+	// Provider provides the tracer used. If nil, the global provider
+	// is used.
+	Provider trace.TracerProvider
+
+	// Context is the parent of the spans recorded, if set, such as the
+	// context of the request that caused the tunnel to be brought up.
+	Context context.Context
+
+	mu      sync.Mutex
+	attempt trace.Span
+	phase   trace.Span
+}
+
+func (t *Tracer) tracer() trace.Tracer {
+	if t.Provider != nil {
+		return t.Provider.Tracer(instrumentationName)
+	}
+	return otel.Tracer(instrumentationName)
+}
+
+func (t *Tracer) context() context.Context {
+	if t.Context != nil {
+		return t.Context
+	}
+	return context.Background()
+}
+
+// RecordCommand records a span for a management command. It is intended
+// to be passed to MgmtClient.SetAuditHook, possibly from a hook that
+// passes the records to an AuditLog too.
+func (t *Tracer) RecordCommand(rec openvpn.AuditRecord) {
+	name, _, _ := strings.Cut(rec.Command, " ")
+	_, span := t.tracer().Start(t.context(), "openvpn "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(rec.Sent),
+		trace.WithAttributes(CommandKey.String(name)))
+	if rec.Error == "" {
+		span.SetAttributes(OutcomeKey.String("success"))
+	} else {
+		span.SetAttributes(OutcomeKey.String("error"), ErrorClassKey.String(errorClass(rec.Err)))
+		span.SetStatus(codes.Error, rec.Error)
+	}
+	span.End(trace.WithTimestamp(rec.Replied))
+}
+
+// errorClass returns the class of error a command failed with.
+func errorClass(err error) string {
+	var serverErr openvpn.ErrorFromServer
+	if errors.As(err, &serverErr) {
+		return "server"
+	}
+	return "connection"
+}
+
+// TraceSession records spans for the tunnel establishment attempts observed
+// by the given session from now on, until the returned function is called.
+func (t *Tracer) TraceSession(s *openvpn.Session) (remove func()) {
+	return s.RegisterTransitionHook("", "", t.transition)
+}
+
+// transition updates the spans for a transition of the session's state.
+// Spans are timed by when the transition was handled, since OpenVPN only
+// timestamps transitions to the second.
+func (t *Tracer) transition(tr openvpn.Transition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.phase != nil {
+		t.phase.End(trace.WithTimestamp(now))
+		t.phase = nil
+	}
+	switch tr.To {
+	case openvpn.StateConnected:
+		if t.attempt != nil {
+			t.attempt.SetStatus(codes.Ok, "")
+			t.endAttemptLocked(now)
+		}
+		return
+	case openvpn.StateExiting:
+		if t.attempt != nil {
+			t.failAttemptLocked(tr, "exiting", now)
+		}
+		return
+	case openvpn.StateReconnecting:
+		if t.attempt != nil {
+			t.failAttemptLocked(tr, "reconnecting", now)
+		}
+	}
+
+	if t.attempt == nil {
+		_, t.attempt = t.tracer().Start(t.context(), "openvpn connect",
+			trace.WithTimestamp(now),
+			trace.WithAttributes(ReconnectKey.Bool(tr.To == openvpn.StateReconnecting || tr.From == openvpn.StateReconnecting)))
+	}
+	ctx := trace.ContextWithSpan(t.context(), t.attempt)
+	_, t.phase = t.tracer().Start(ctx, "openvpn state "+string(tr.To),
+		trace.WithTimestamp(now),
+		trace.WithAttributes(StateKey.String(string(tr.To))))
+}
+
+// failAttemptLocked ends the current attempt as having failed because of
+// the given transition. It must be called with t.mu held.
+func (t *Tracer) failAttemptLocked(tr openvpn.Transition, msg string, now time.Time) {
+	if reason := tr.Event.Description(); reason != "" {
+		t.attempt.SetAttributes(ReasonKey.String(reason))
+		msg += ": " + reason
+	}
+	if err := openvpn.ClassifyEvent(tr.Event); err != nil {
+		msg = err.Error()
+	}
+	t.attempt.SetStatus(codes.Error, msg)
+	t.endAttemptLocked(now)
+}
+
+func (t *Tracer) endAttemptLocked(now time.Time) {
+	t.attempt.End(trace.WithTimestamp(now))
+	t.attempt = nil
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestTracer() (*Tracer, *tracetest.SpanRecorder) {
+	rec := tracetest.NewSpanRecorder()
+	return &Tracer{Provider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))}, rec
+}
+
+func TestRecordCommand(t *testing.T) {
+	tr, rec := newTestTracer()
+	sent := time.Unix(1700000000, 0)
+	tr.RecordCommand(openvpn.AuditRecord{Sent: sent, Replied: sent.Add(time.Second), Command: "state"})
+	tr.RecordCommand(openvpn.AuditRecord{
+		Sent:    sent,
+		Replied: sent,
+		Command: "client-kill 5 HALT",
+		Error:   "client-kill command failed",
+		Err:     openvpn.ErrorFromServer("client-kill command failed"),
+	})
+
+	tests := []struct {
+		name    string
+		outcome string
+		class   string
+		latency time.Duration
+	}{
+		{"openvpn state", "success", "", time.Second},
+		{"openvpn client-kill", "error", "server", 0},
+	}
+	spans := rec.Ended()
+	if len(spans) != len(tests) {
+		t.Fatalf("got %d spans; want %d", len(spans), len(tests))
+	}
+	for i, test := range tests {
+		span := spans[i]
+		attrs := map[string]string{}
+		for _, kv := range span.Attributes() {
+			attrs[string(kv.Key)] = kv.Value.Emit()
+		}
+		if span.Name() != test.name || attrs[string(OutcomeKey)] != test.outcome || attrs[string(ErrorClassKey)] != test.class {
+			t.Errorf("test %d got span %q with attributes %v", i, span.Name(), attrs)
+		}
+		if got := span.EndTime().Sub(span.StartTime()); got != test.latency {
+			t.Errorf("test %d got latency %v; want %v", i, got, test.latency)
+		}
+	}
+}
+
+func TestTraceSession(t *testing.T) {
+	tr, rec := newTestTracer()
+	var s openvpn.Session
+	tr.TraceSession(&s)
+	for _, raw := range []string{
+		"STATE:1,CONNECTING,,,",
+		"STATE:2,AUTH,,,",
+		"STATE:3,RECONNECTING,auth-failure,,",
+		"STATE:4,WAIT,,,",
+		"STATE:5,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1",
+	} {
+		s.HandleEvent(openvpn.ParseEvent([]byte(raw)))
+	}
+
+	var got []string
+	for _, span := range rec.Ended() {
+		got = append(got, span.Name()+" "+span.Status().Code.String())
+	}
+	want := []string{
+		"openvpn state CONNECTING Unset",
+		"openvpn state AUTH Unset",
+		"openvpn connect Error",
+		"openvpn state RECONNECTING Unset",
+		"openvpn state WAIT Unset",
+		"openvpn connect Ok",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got spans %q; want %q", got, want)
+	}
+
+	spans := rec.Ended()
+	if parent := spans[0].Parent().SpanID(); parent != spans[2].SpanContext().SpanID() {
+		t.Errorf("phase span is not a child of its attempt")
+	}
+	if spans[2].Status().Code != codes.Error || spans[2].Status().Description != openvpn.ClassifyEvent(openvpn.ParseEvent([]byte("STATE:3,RECONNECTING,auth-failure,,"))).Error() {
+		t.Errorf("got failed attempt status %+v", spans[2].Status())
+	}
+}
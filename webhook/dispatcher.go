@@ -0,0 +1,267 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/internal/queue"
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+// SignatureHeader is the header carrying the signature of the body of each
+// request when Dispatcher.Secret is set, in the form "sha256=" followed by
+// the hex-encoded HMAC-SHA256 of the body keyed with the secret.
+const SignatureHeader = "X-Gopenvpn-Signature"
+
+// Defaults for the fields of a Dispatcher.
+const (
+	DefaultQueueSize     = 256
+	DefaultMaxAttempts   = 5
+	DefaultRetryDelay    = time.Second
+	DefaultMaxRetryDelay = time.Minute
+)
+
+// Payload is the data a Dispatcher's Template is executed with.
+type Payload struct {
+	// Time is when the event was handled, and Source the dispatcher's
+	// Source.
+	Time   time.Time
+	Source string
+
+	// Event is the event, Type the name of its type, such as
+	// "StateEvent", and JSON its encoding by openvpn.MarshalEvent.
+	Event openvpn.Event
+	Type  string
+	JSON  string
+}
+
+// Dispatcher posts events to webhooks. The fields must not be changed once
+// it is in use.
+type Dispatcher struct {
+	// URLs are the endpoints each event is posted to.
+	URLs []string
+
+	// Filter selects the events to post, and defaults to DefaultFilter.
+	Filter openvpn.EventFilter
+
+	// Source, if set, identifies the tunnel or server in each payload.
+	Source string
+
+	// Template, if set, produces the body of each request from
+	// a Payload, with the given ContentType. Otherwise the body is
+	// a JSON object with "time", "source" and "event" members, the last
+	// holding the event encoded by openvpn.MarshalEvent.
+	Template    *template.Template
+	ContentType string
+
+	// Secret, if set, is used to sign each request, as described for
+	// SignatureHeader.
+	Secret []byte
+
+	// Client is used to make requests, and defaults to
+	// http.DefaultClient. It should have a timeout.
+	Client *http.Client
+
+	// QueueSize is the number of events queued for delivery, beyond which
+	// events are dropped, as counted by Dropped. It defaults to
+	// DefaultQueueSize.
+	QueueSize int
+
+	// MaxAttempts is the number of times to try posting each event to
+	// each URL, and RetryDelay the delay before the first retry, which
+	// doubles with each retry up to MaxRetryDelay. Requests are retried
+	// if they fail or receive a 5xx or 429 status.
+	MaxAttempts   int
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// OnError, if set, is called when an event could not be posted to
+	// a URL after all attempts.
+	OnError func(url string, err error)
+
+	pending queue.Queue[Payload]
+}
+
+// DefaultFilter accepts the events reporting that a tunnel has connected,
+// is reconnecting or has exited, that a client of a server has connected
+// or disconnected, and authentication failures.
+func DefaultFilter(e openvpn.Event) bool {
+	switch e := e.(type) {
+	case *openvpn.StateEvent:
+		switch openvpn.State(e.NewState()) {
+		case openvpn.StateConnected, openvpn.StateReconnecting, openvpn.StateExiting:
+			return true
+		}
+	case *openvpn.ClientEvent:
+		switch e.Type() {
+		case "ESTABLISHED", "DISCONNECT":
+			return true
+		}
+	}
+	return errors.Is(openvpn.ClassifyEvent(e), openvpn.ErrAuthFailed)
+}
+
+func (d *Dispatcher) queue() *queue.Queue[Payload] {
+	return d.pending.Init(d.QueueSize, DefaultQueueSize)
+}
+
+// HandleEvent queues the given event to be posted to the URLs by Run, if
+// it is accepted by the filter. It never blocks, dropping the event if the
+// queue is full, so a slow webhook doesn't hold up the handling of events.
+// The event is copied if necessary, so it may be released with
+// openvpn.ReleaseEvent once HandleEvent returns.
+func (d *Dispatcher) HandleEvent(e openvpn.Event) {
+	filter := d.Filter
+	if filter == nil {
+		filter = DefaultFilter
+	}
+	if filter(e) {
+		d.queue().Add(Payload{Time: time.Now(), Source: d.Source, Event: openvpn.CopyEvent(e)})
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was
+// full.
+func (d *Dispatcher) Dropped() uint64 {
+	return d.pending.Dropped()
+}
+
+// Run posts queued events, in the order they were queued, until ctx is
+// cancelled, returning ctx.Err(). Events still queued are then discarded.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	queued := d.queue().C()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case p := <-queued:
+			body, contentType, bodyErr := d.body(p)
+			for _, url := range d.URLs {
+				// Each URL is posted to regardless of whether the
+				// others failed; only a body that can't be made
+				// fails them all.
+				err := bodyErr
+				if err == nil {
+					err = d.post(ctx, url, body, contentType)
+				}
+				if err != nil && ctx.Err() == nil && d.OnError != nil {
+					d.OnError(url, err)
+				}
+			}
+		}
+	}
+}
+
+// body returns the body of the requests posting the given payload.
+func (d *Dispatcher) body(p Payload) ([]byte, string, error) {
+	eventJSON, err := openvpn.MarshalEvent(p.Event)
+	if err != nil {
+		return nil, "", err
+	}
+	if d.Template == nil {
+		body, err := json.Marshal(struct {
+			Time   time.Time       `json:"time"`
+			Source string          `json:"source,omitempty"`
+			Event  json.RawMessage `json:"event"`
+		}{p.Time, p.Source, eventJSON})
+		return body, "application/json", err
+	}
+
+	p.JSON = string(eventJSON)
+	p.Type = openvpn.EventTypeName(p.Event)
+	var buf bytes.Buffer
+	if err := d.Template.Execute(&buf, p); err != nil {
+		return nil, "", err
+	}
+	contentType := d.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+// post posts a body to a URL, retrying as described for MaxAttempts.
+func (d *Dispatcher) post(ctx context.Context, url string, body []byte, contentType string) error {
+	attempts := d.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultMaxAttempts
+	}
+	delay := d.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	maxDelay := d.MaxRetryDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxRetryDelay
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		if retry, err = d.postOnce(ctx, url, body, contentType); err == nil || !retry || attempt == attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// postOnce makes a single request, returning whether it is worth retrying
+// if it fails.
+func (d *Dispatcher) postOnce(ctx context.Context, url string, body []byte, contentType string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if len(d.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(d.Secret, body))
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook %s returned %s", url, resp.Status)
+}
+
+// Sign returns the value of the SignatureHeader for the given body signed
+// with the given secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature, the value of a request's
+// SignatureHeader, is valid for the given body and secret, for use by
+// receivers of webhooks.
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, body)))
+}
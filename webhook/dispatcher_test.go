@@ -0,0 +1,210 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/NordSecurity/gopenvpn/openvpn"
+)
+
+type request struct {
+	body, contentType, signature string
+}
+
+// recorder is a webhook receiver that fails the first failures requests
+// with the given status.
+type recorder struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	got      []request
+	ch       chan request
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(r.status)
+		return
+	}
+	r.ch <- request{string(body), req.Header.Get("Content-Type"), req.Header.Get(SignatureHeader)}
+}
+
+func TestDispatcher(t *testing.T) {
+	rec := &recorder{failures: 2, status: http.StatusServiceUnavailable, ch: make(chan request, 10)}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	secret := []byte("s3cret")
+	d := &Dispatcher{
+		URLs:       []string{srv.URL},
+		Source:     "office",
+		Secret:     secret,
+		RetryDelay: time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.HandleEvent(openvpn.ParseEvent([]byte("STATE:1,CONNECTING,,,")))
+	d.HandleEvent(openvpn.ParseEvent([]byte("STATE:2,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+
+	var req request
+	select {
+	case req = <-rec.ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received")
+	}
+	if !Verify(secret, []byte(req.body), req.signature) {
+		t.Errorf("signature %q doesn't verify", req.signature)
+	}
+	if req.contentType != "application/json" {
+		t.Errorf("got content type %q; want application/json", req.contentType)
+	}
+	var payload struct {
+		Time   time.Time
+		Source string
+		Event  map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(req.body), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Source != "office" || payload.Time.IsZero() || payload.Event["type"] != "StateEvent" || payload.Event["state"] != "CONNECTED" {
+		t.Errorf("got payload %s", req.body)
+	}
+}
+
+func TestDispatcherTemplate(t *testing.T) {
+	rec := &recorder{failures: 1, status: http.StatusBadRequest, ch: make(chan request, 10)}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	errs := make(chan error, 10)
+	d := &Dispatcher{
+		URLs:        []string{srv.URL},
+		Filter:      func(e openvpn.Event) bool { return true },
+		Template:    template.Must(template.New("").Parse(`{{.Type}}: {{.Event}}`)),
+		ContentType: "text/plain",
+		OnError:     func(url string, err error) { errs <- err },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.HandleEvent(openvpn.ParseEvent([]byte("HOLD:Waiting for hold release:0")))
+	d.HandleEvent(openvpn.ParseEvent([]byte("FATAL:Cannot open TUN/TAP dev")))
+
+	// The first event is rejected without retrying.
+	select {
+	case err := <-errs:
+		if want := "webhook " + srv.URL + " returned 400 Bad Request"; err.Error() != want {
+			t.Errorf("got error %q; want %q", err, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error reported")
+	}
+	req := <-rec.ch
+	if want := "FatalEvent: FATAL: Cannot open TUN/TAP dev"; req.body != want || req.contentType != "text/plain" || req.signature != "" {
+		t.Errorf("got request %+v; want body %q", req, want)
+	}
+}
+
+func TestDispatcherFailingURL(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	rec := &recorder{ch: make(chan request, 10)}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	errs := make(chan string, 10)
+	d := &Dispatcher{
+		URLs:    []string{failing.URL, srv.URL},
+		OnError: func(url string, err error) { errs <- url },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.HandleEvent(openvpn.ParseEvent([]byte("STATE:2,CONNECTED,SUCCESS,10.8.0.2,192.0.2.1")))
+
+	// The second URL is posted to even though the first failed, and only
+	// the first is reported.
+	select {
+	case <-rec.ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received by second URL")
+	}
+	if url := <-errs; url != failing.URL {
+		t.Errorf("got error for %q; want %q", url, failing.URL)
+	}
+	select {
+	case url := <-errs:
+		t.Errorf("got unexpected error for %q", url)
+	default:
+	}
+}
+
+func TestDispatcherReleasedEvent(t *testing.T) {
+	rec := &recorder{ch: make(chan request, 10)}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	d := &Dispatcher{
+		URLs:   []string{srv.URL},
+		Filter: func(openvpn.Event) bool { return true },
+	}
+	// The event is released, and its memory reused, before it is posted.
+	e := openvpn.ParseEvent([]byte("BYTECOUNT:100,200"))
+	d.HandleEvent(e)
+	openvpn.ReleaseEvent(e)
+	openvpn.ParseEvent([]byte("BYTECOUNT:1,2"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	var body struct {
+		Event map[string]interface{} `json:"event"`
+	}
+	select {
+	case req := <-rec.ch:
+		json.Unmarshal([]byte(req.body), &body)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received")
+	}
+	if body.Event["bytes_in"] != 100.0 || body.Event["bytes_out"] != 200.0 {
+		t.Errorf("got event %v; want 100 bytes in and 200 out", body.Event)
+	}
+}
+
+func TestDefaultFilter(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"STATE:1,CONNECTED,SUCCESS,,", true},
+		{"STATE:1,RECONNECTING,ping-restart,,", true},
+		{"STATE:1,WAIT,,,", false},
+		{"PASSWORD:Verification Failed: 'Auth'", true},
+		{"BYTECOUNT:1,2", false},
+		{"CLIENT:DISCONNECT,3", true},
+		{"CLIENT:CONNECT,3,1", false},
+	}
+	for i, test := range tests {
+		if got := DefaultFilter(openvpn.ParseEvent([]byte(test.raw))); got != test.want {
+			t.Errorf("test %d got %v; want %v", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Package webhook posts selected events of an OpenVPN process to HTTP
+// endpoints, so that external systems can react to them, such as by
+// notifying a chat channel when a tunnel goes down or a client connects.
+//
+// A Dispatcher queues each event accepted by its filter, and posts it to
+// each of its URLs with retries, optionally signing the body so that the
+// receiver can verify that it came from the dispatcher:
+//
+//	d := &webhook.Dispatcher{
+//		URLs:   []string{"https://hooks.example.com/vpn"},
+//		Secret: []byte(secret),
+//	}
+//	go d.Run(ctx)
+//
+// The dispatcher's HandleEvent method should then be passed each event
+// received from the client's event channel.
+package webhook